@@ -0,0 +1,81 @@
+package vcblobstore
+
+import (
+	"sort"
+	"sync"
+)
+
+// AccessStats holds the number of reads and writes of a key since the tracking started
+type AccessStats struct {
+	Key    string
+	Reads  int64
+	Writes int64
+}
+
+func (stats AccessStats) Total() int64 {
+	return stats.Reads + stats.Writes
+}
+
+// AccessTracker counts the reads and writes of the keys in memory.
+// A nil tracker is valid and records nothing, so that backends can call it unconditionally
+type AccessTracker struct {
+	mutex    sync.Mutex
+	statsFor map[string]*AccessStats
+}
+
+func NewAccessTracker() *AccessTracker {
+	return &AccessTracker{statsFor: map[string]*AccessStats{}}
+}
+
+func (tracker *AccessTracker) RecordRead(key string) {
+	if tracker == nil {
+		return
+	}
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	tracker.stats(key).Reads++
+}
+
+func (tracker *AccessTracker) RecordWrite(keys ...string) {
+	if tracker == nil {
+		return
+	}
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	for _, key := range keys {
+		tracker.stats(key).Writes++
+	}
+}
+
+func (tracker *AccessTracker) stats(key string) *AccessStats {
+	stats, ok := tracker.statsFor[key]
+	if !ok {
+		stats = &AccessStats{Key: key}
+		tracker.statsFor[key] = stats
+	}
+	return stats
+}
+
+// HotKeys returns the topN most frequently accessed keys (all of them if topN < 1), the busiest first
+func (tracker *AccessTracker) HotKeys(topN int) []AccessStats {
+	if tracker == nil {
+		return []AccessStats{}
+	}
+	tracker.mutex.Lock()
+	hotKeys := make([]AccessStats, 0, len(tracker.statsFor))
+	for _, stats := range tracker.statsFor {
+		hotKeys = append(hotKeys, *stats)
+	}
+	tracker.mutex.Unlock()
+
+	sort.Slice(hotKeys, func(i, j int) bool {
+		if hotKeys[i].Total() != hotKeys[j].Total() {
+			return hotKeys[i].Total() > hotKeys[j].Total()
+		}
+		return hotKeys[i].Key < hotKeys[j].Key
+	})
+	if topN > 0 && topN < len(hotKeys) {
+		hotKeys = hotKeys[:topN]
+	}
+	return hotKeys
+}
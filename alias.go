@@ -0,0 +1,93 @@
+package vcblobstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// InternalKeyPrefix is the prefix of the keys under which the store keeps its own records (e.g. alias pointers).
+// Internal keys are not included in the key listings
+const InternalKeyPrefix = ".vcblobstore/"
+
+// AliasKeyPrefix is the prefix of the keys of the pointer entries of aliases
+const AliasKeyPrefix = InternalKeyPrefix + "aliases/"
+
+var ErrAliasLoop = errors.New("alias loop detected")
+
+// Alias redirects reads of the Alias key to the Target key
+type Alias struct {
+	Alias  string
+	Target string
+}
+
+type aliasPointer struct {
+	Target string `json:"target"`
+}
+
+func IsInternalKey(key string) bool {
+	return strings.HasPrefix(key, InternalKeyPrefix)
+}
+
+// AliasPointerKey returns the key of the pointer entry storing the alias
+func AliasPointerKey(alias string) string {
+	return AliasKeyPrefix + alias
+}
+
+// AliasFromPointerKey returns the alias stored in the pointer entry with the specified key
+func AliasFromPointerKey(pointerKey string) string {
+	return strings.TrimPrefix(pointerKey, AliasKeyPrefix)
+}
+
+func EncodeAliasPointer(target string) ([]byte, error) {
+	content, err := json.Marshal(aliasPointer{Target: target})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode alias pointer to %s: %w", target, err)
+	}
+	return content, nil
+}
+
+func DecodeAliasPointer(content []byte) (string, error) {
+	pointer := aliasPointer{}
+	if err := json.Unmarshal(content, &pointer); err != nil {
+		return "", fmt.Errorf("failed to decode alias pointer: %w", err)
+	}
+	return pointer.Target, nil
+}
+
+// AliasLookup returns the target of the alias and false in case key is not an alias
+type AliasLookup func(key string) (string, bool, error)
+
+// ResolveAlias follows the chain of aliases starting at key and returns the canonical key.
+// Keys which are not aliases resolve to themselves
+func ResolveAlias(key string, lookup AliasLookup) (string, error) {
+	visited := map[string]bool{}
+	current := key
+	for {
+		if visited[current] {
+			return "", fmt.Errorf("failed to resolve alias %s: %w", key, ErrAliasLoop)
+		}
+		visited[current] = true
+
+		target, isAlias, err := lookup(current)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve alias %s: %w", key, err)
+		}
+		if !isAlias {
+			return current, nil
+		}
+		current = target
+	}
+}
+
+// CheckNewAlias verifies that adding the alias pointing to target wouldn't create a loop
+func CheckNewAlias(alias string, target string, lookup AliasLookup) error {
+	_, err := ResolveAlias(alias, func(key string) (string, bool, error) {
+		if key == alias {
+			return target, true, nil
+		}
+		return lookup(key)
+	})
+	return err
+}
@@ -0,0 +1,21 @@
+package vcblobstore
+
+import (
+	"context"
+	"time"
+)
+
+// BlobAnnotation is the last change of a blob: the version which made it with its author and date.
+// The annotation of a blob which doesn't exist has an empty Version
+type BlobAnnotation struct {
+	Key        string
+	Version    string
+	Author     string
+	AuthorDate time.Time
+}
+
+// BlobAnnotator is implemented by the backends able to tell the last change of several blobs at once
+type BlobAnnotator interface {
+	// GetBlobAnnotations returns the annotation of each of the keys, in the order of the keys
+	GetBlobAnnotations(ctx context.Context, keys []string) ([]BlobAnnotation, error)
+}
@@ -0,0 +1,16 @@
+package vcblobstore
+
+import (
+	"context"
+	"io"
+)
+
+// Archiver is implemented by the backends able to export every file of a version of the repository at once.
+// The archive holds the files as they are committed: the internal entries, the sharding directories and the
+// pointers to LFS and external objects are included as is
+type Archiver interface {
+	// ExportAll writes the tar.gz archive of the current version of the repository to w
+	ExportAll(ctx context.Context, w io.Writer) error
+	// ExportAllAt writes the tar.gz archive of the version of the repository to w
+	ExportAllAt(ctx context.Context, version string, w io.Writer) error
+}
@@ -0,0 +1,114 @@
+package vcblobstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// AttributeIndexKey is the key of the index mapping the metadata attributes (labels) to the keys of the blobs having them
+const AttributeIndexKey = InternalKeyPrefix + "attribute-index.json"
+
+// AttributeSelector matches the blobs having every listed attribute with the listed value
+type AttributeSelector map[string]string
+
+// ParseAttributeSelector parses selectors of the form "team=payments,tier=gold"
+func ParseAttributeSelector(selector string) (AttributeSelector, error) {
+	parsed := AttributeSelector{}
+	for _, term := range strings.Split(selector, ",") {
+		term = strings.TrimSpace(term)
+		if len(term) == 0 {
+			continue
+		}
+		name, value, found := strings.Cut(term, "=")
+		if !found || len(strings.TrimSpace(name)) == 0 {
+			return nil, fmt.Errorf("invalid attribute selector term: %s", term)
+		}
+		parsed[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return parsed, nil
+}
+
+// AttributeIndex maps "name=value" labels to the sorted list of the keys of the blobs having them
+type AttributeIndex map[string][]string
+
+func attributeLabel(name string, value string) string {
+	return name + "=" + value
+}
+
+func DecodeAttributeIndex(content []byte) (AttributeIndex, error) {
+	index := AttributeIndex{}
+	if err := json.Unmarshal(content, &index); err != nil {
+		return nil, fmt.Errorf("failed to decode attribute index: %w", err)
+	}
+	return index, nil
+}
+
+func (index AttributeIndex) Encode() ([]byte, error) {
+	content, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode attribute index: %w", err)
+	}
+	return content, nil
+}
+
+// Update replaces the labels of the blob with the ones derived from its metadata and reports whether the index changed
+func (index AttributeIndex) Update(key string, metadata map[string]string) bool {
+	changed := false
+
+	for label, keys := range index {
+		position, found := slices.BinarySearch(keys, key)
+		if !found {
+			continue
+		}
+		name, value, _ := strings.Cut(label, "=")
+		if currentValue, has := metadata[name]; has && currentValue == value {
+			continue
+		}
+		keys = slices.Delete(keys, position, position+1)
+		if len(keys) == 0 {
+			delete(index, label)
+		} else {
+			index[label] = keys
+		}
+		changed = true
+	}
+
+	for name, value := range metadata {
+		label := attributeLabel(name, value)
+		keys := index[label]
+		position, found := slices.BinarySearch(keys, key)
+		if found {
+			continue
+		}
+		index[label] = slices.Insert(keys, position, key)
+		changed = true
+	}
+
+	return changed
+}
+
+// Query returns the sorted keys of the blobs matching the selector. An empty selector matches nothing
+func (index AttributeIndex) Query(selector AttributeSelector) []string {
+	var matches []string
+	first := true
+	for name, value := range selector {
+		keys := index[attributeLabel(name, value)]
+		if first {
+			matches = slices.Clone(keys)
+			first = false
+			continue
+		}
+		matches = slices.DeleteFunc(matches, func(key string) bool {
+			_, found := slices.BinarySearch(keys, key)
+			return !found
+		})
+	}
+	if matches == nil {
+		return []string{}
+	}
+	sort.Strings(matches)
+	return matches
+}
@@ -0,0 +1,115 @@
+package vcblobstore
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"strings"
+	"time"
+)
+
+type AuditLogFormat string
+
+const (
+	AuditLogJSONL AuditLogFormat = "jsonl"
+	AuditLogCSV   AuditLogFormat = "csv"
+)
+
+// AuditRecord is a version of the repository in an audit log: who made it, when, why and which blobs it changed
+type AuditRecord struct {
+	Version string      `json:"version"`
+	Author  string      `json:"author"`
+	Time    time.Time   `json:"time"`
+	Message string      `json:"message"`
+	Changes []KeyChange `json:"changes"`
+}
+
+// auditLogCSVHeader is the first line of an audit log in CSV. The changes of a version are listed in a single
+// column as "operation:key" items separated by semicolons
+var auditLogCSVHeader = []string{"version", "time", "author", "changes", "message"}
+
+// VersionStreamer is implemented by the stores which can list the versions authored since a time without listing the
+// whole history first
+type VersionStreamer interface {
+	// IterateVersionsSince yields the versions authored at or after since (all of them for the zero time), oldest first
+	IterateVersionsSince(ctx context.Context, since time.Time) iter.Seq2[RepositoryVersion, error]
+}
+
+// ExportAuditLog writes every version of the repository authored at or after since (all of them for the zero time)
+// to w in the format, oldest first: a JSON line or a CSV row per version. The records are written as the store
+// yields the versions if it is a VersionStreamer
+func ExportAuditLog(ctx context.Context, store Admin, since time.Time, w io.Writer, format AuditLogFormat) error {
+	var writeRecord func(record AuditRecord) error
+	var flush func() error
+	switch format {
+	case AuditLogJSONL:
+		encoder := json.NewEncoder(w)
+		writeRecord = func(record AuditRecord) error {
+			return encoder.Encode(record)
+		}
+		flush = func() error { return nil }
+	case AuditLogCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(auditLogCSVHeader); err != nil {
+			return fmt.Errorf("failed to write audit log header: %w", err)
+		}
+		writeRecord = func(record AuditRecord) error {
+			changes := make([]string, len(record.Changes))
+			for index, change := range record.Changes {
+				changes[index] = string(change.Operation) + ":" + change.Key
+			}
+			return writer.Write([]string{record.Version, record.Time.Format(time.RFC3339), record.Author, strings.Join(changes, ";"), record.Message})
+		}
+		flush = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+	default:
+		return fmt.Errorf("unknown audit log format %q", format)
+	}
+
+	for version, err := range versionsSince(ctx, store, since) {
+		if err != nil {
+			return fmt.Errorf("failed to list the versions for the audit log: %w", err)
+		}
+		if version.AuthorDate.Before(since) {
+			continue
+		}
+		record := AuditRecord{
+			Version: version.Version,
+			Author:  version.Author,
+			Time:    version.AuthorDate,
+			Message: version.Message,
+			Changes: version.Changes,
+		}
+		if err := writeRecord(record); err != nil {
+			return fmt.Errorf("failed to write audit record of %s: %w", record.Version, err)
+		}
+	}
+	if err := flush(); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// versionsSince yields the versions from the VersionStreamer of the store or else from the whole list of its versions
+func versionsSince(ctx context.Context, store Admin, since time.Time) iter.Seq2[RepositoryVersion, error] {
+	if streamer, ok := store.(VersionStreamer); ok {
+		return streamer.IterateVersionsSince(ctx, since)
+	}
+	return func(yield func(RepositoryVersion, error) bool) {
+		versions, listErr := store.ListVersions(ctx, "", "")
+		if listErr != nil {
+			yield(RepositoryVersion{}, listErr)
+			return
+		}
+		for _, version := range versions {
+			if !yield(version, nil) {
+				return
+			}
+		}
+	}
+}
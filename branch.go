@@ -0,0 +1,31 @@
+package vcblobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidBranchName signals that the branch selected with WithBranch isn't a valid branch name, e.g. main~1
+var ErrInvalidBranchName = errors.New("invalid branch name")
+
+// ErrBranchesUnsupported signals that a branch is selected with WithBranch for a backend with a single line of versions
+var ErrBranchesUnsupported = fmt.Errorf("branches: %w", errors.ErrUnsupported)
+
+type branchKey struct{}
+
+// WithBranch selects, for the reads and writes made with the returned context, the branch of the repository instead
+// of the one the backend is configured with. The first write to a branch missing from the repository creates it from
+// the main branch; reading a branch before that finds nothing
+func WithBranch(ctx context.Context, branch string) context.Context {
+	return context.WithValue(ctx, branchKey{}, branch)
+}
+
+// BranchOf returns the branch the reads and writes made with the context go to: as set by WithBranch or, without
+// that, as configured for the backend
+func BranchOf(ctx context.Context, configured string) string {
+	if branch, set := ctx.Value(branchKey{}).(string); set && len(branch) > 0 {
+		return branch
+	}
+	return configured
+}
@@ -0,0 +1,462 @@
+package vcblobstore
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const defaultCacheCapacity = 64 << 20
+
+const maxLatencySamples = 1024
+
+const maxPinCandidates = 10
+
+// CacheConfig configures a CachingStore. Entries expire TTL after they have been cached, never with a zero TTL
+type CacheConfig struct {
+	// CapacityBytes bounds the size of the cached content, 64MiB by default
+	CapacityBytes int64
+	TTL           time.Duration
+}
+
+func (config CacheConfig) capacity() int64 {
+	if config.CapacityBytes < 1 {
+		return defaultCacheCapacity
+	}
+	return config.CapacityBytes
+}
+
+// LatencySummary summarizes the latencies of the most recent reads of a kind
+type LatencySummary struct {
+	Samples int
+	Mean    time.Duration
+	P50     time.Duration
+	P99     time.Duration
+}
+
+// ReadPathStats describes the reads observed since the creation of the store or the last ResetStats. Warm reads
+// are served by the cache, cold ones by the decorated store. The working set is made up of the distinct keys read
+type ReadPathStats struct {
+	WarmReads       int64
+	ColdReads       int64
+	WarmBytes       int64
+	ColdBytes       int64
+	WarmLatency     LatencySummary
+	ColdLatency     LatencySummary
+	Evictions       int64
+	Expirations     int64
+	CachedKeys      int
+	CachedBytes     int64
+	WorkingSetKeys  int
+	WorkingSetBytes int64
+}
+
+// HitRatio returns the ratio of the warm reads (0 if there were no reads at all)
+func (stats ReadPathStats) HitRatio() float64 {
+	if stats.WarmReads+stats.ColdReads == 0 {
+		return 0
+	}
+	return float64(stats.WarmReads) / float64(stats.WarmReads+stats.ColdReads)
+}
+
+// CacheRecommendation is a sizing hint derived from the observed reads
+type CacheRecommendation struct {
+	Stats ReadPathStats
+	// CapacityBytes holds the whole working set with some headroom, the current capacity if nothing has been read
+	CapacityBytes int64
+	// TTL outlives the typical interval between two reads of a key, zero if no key has been read twice
+	TTL time.Duration
+	// PinCandidates are the busiest keys read cold more than once, i.e. evicted or expired despite being read again
+	PinCandidates []string
+}
+
+// cacheKey identifies a cached blob by its key and the branch selected for reading it (empty for the configured one)
+type cacheKey struct {
+	branch string
+	key    string
+}
+
+func cacheKeyOf(ctx context.Context, key string) cacheKey {
+	return cacheKey{branch: BranchOf(ctx, ""), key: key}
+}
+
+type cacheEntry struct {
+	key      cacheKey
+	blob     BlobInfo
+	complete bool
+	cachedAt time.Time
+}
+
+type keyReads struct {
+	size      int64
+	reads     int64
+	coldReads int64
+	lastRead  time.Time
+	gaps      time.Duration
+}
+
+type latencySamples struct {
+	samples []time.Duration
+	next    int
+}
+
+func (latencies *latencySamples) add(latency time.Duration) {
+	if len(latencies.samples) < maxLatencySamples {
+		latencies.samples = append(latencies.samples, latency)
+		return
+	}
+	latencies.samples[latencies.next] = latency
+	latencies.next = (latencies.next + 1) % maxLatencySamples
+}
+
+func (latencies *latencySamples) summary() LatencySummary {
+	if len(latencies.samples) == 0 {
+		return LatencySummary{}
+	}
+	sorted := append([]time.Duration{}, latencies.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var total time.Duration
+	for _, latency := range sorted {
+		total += latency
+	}
+	return LatencySummary{
+		Samples: len(sorted),
+		Mean:    total / time.Duration(len(sorted)),
+		P50:     sorted[len(sorted)/2],
+		P99:     sorted[len(sorted)*99/100],
+	}
+}
+
+// CachingStore is a Store decorator caching the content (and metadata) of the blobs read, apart for each branch
+// selected with WithBranch, the least recently used entries evicted first. The writes through the store invalidate the keys they touch, but an alias is cached under
+// its own key, so a read through an alias may be served stale until the entry expires if its target is changed
+type CachingStore struct {
+	Store
+	config CacheConfig
+
+	mutex   sync.Mutex
+	entries map[cacheKey]*list.Element
+	lru     *list.List
+	size    int64
+	// invalidations counts the invalidations, so that a read overlapping a write doesn't cache what it read
+	invalidations uint64
+
+	stats         ReadPathStats
+	warmLatencies latencySamples
+	coldLatencies latencySamples
+	workingSet    map[string]*keyReads
+}
+
+func NewCachingStore(store Store, config CacheConfig) *CachingStore {
+	return &CachingStore{
+		Store:      store,
+		config:     config,
+		entries:    map[cacheKey]*list.Element{},
+		lru:        list.New(),
+		workingSet: map[string]*keyReads{},
+	}
+}
+
+// lookup returns the cached blob of the key, provided it is cached with its metadata if complete is required
+func (store *CachingStore) lookup(key cacheKey, complete bool) (BlobInfo, bool) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	element, found := store.entries[key]
+	if !found {
+		return BlobInfo{}, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if store.config.TTL > 0 && time.Since(entry.cachedAt) > store.config.TTL {
+		store.remove(element)
+		store.stats.Expirations++
+		return BlobInfo{}, false
+	}
+	if complete && !entry.complete {
+		return BlobInfo{}, false
+	}
+	store.lru.MoveToFront(element)
+	return entry.blob, true
+}
+
+// generation returns the number of invalidations so far, which a read passes on to put
+func (store *CachingStore) generation() uint64 {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return store.invalidations
+}
+
+// put caches a copy of the blob read when the cache was at the generation, unless it has been invalidated since
+func (store *CachingStore) put(key cacheKey, blob BlobInfo, complete bool, generation uint64) {
+	size := int64(len(blob.Content))
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if store.invalidations != generation {
+		return
+	}
+	if element, found := store.entries[key]; found {
+		store.remove(element)
+	}
+	if size > store.config.capacity() {
+		return
+	}
+	for store.size+size > store.config.capacity() {
+		store.remove(store.lru.Back())
+		store.stats.Evictions++
+	}
+	store.entries[key] = store.lru.PushFront(&cacheEntry{key: key, blob: cloneBlobInfo(blob), complete: complete, cachedAt: time.Now()})
+	store.size += size
+}
+
+// cloneBlobInfo copies the content and the metadata of the blob, which the cache and its callers mustn't share
+func cloneBlobInfo(blob BlobInfo) BlobInfo {
+	blob.Content = bytes.Clone(blob.Content)
+	blob.Metadata = maps.Clone(blob.Metadata)
+	return blob
+}
+
+// remove drops the entry, the lock being held
+func (store *CachingStore) remove(element *list.Element) {
+	entry := store.lru.Remove(element).(*cacheEntry)
+	delete(store.entries, entry.key)
+	store.size -= int64(len(entry.blob.Content))
+}
+
+// invalidate drops the cached blobs of the keys on the branch the write goes to. The writes call it both before and
+// after changing the store, so that neither a read before nor one during the write leaves the old content in the cache
+func (store *CachingStore) invalidate(ctx context.Context, keys ...string) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.invalidations++
+	for _, key := range keys {
+		if element, found := store.entries[cacheKeyOf(ctx, key)]; found {
+			store.remove(element)
+		}
+	}
+}
+
+func (store *CachingStore) observe(key string, size int, warm bool, start time.Time) {
+	latency := time.Since(start)
+	now := time.Now()
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if warm {
+		store.stats.WarmReads++
+		store.stats.WarmBytes += int64(size)
+		store.warmLatencies.add(latency)
+	} else {
+		store.stats.ColdReads++
+		store.stats.ColdBytes += int64(size)
+		store.coldLatencies.add(latency)
+	}
+
+	reads, known := store.workingSet[key]
+	if !known {
+		reads = &keyReads{}
+		store.workingSet[key] = reads
+	} else {
+		reads.gaps += now.Sub(reads.lastRead)
+	}
+	reads.size = int64(size)
+	reads.reads++
+	if !warm {
+		reads.coldReads++
+	}
+	reads.lastRead = now
+}
+
+// Stats returns the reads observed since the creation of the store or the last ResetStats
+func (store *CachingStore) Stats() ReadPathStats {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return store.statsLocked()
+}
+
+func (store *CachingStore) statsLocked() ReadPathStats {
+	stats := store.stats
+	stats.WarmLatency = store.warmLatencies.summary()
+	stats.ColdLatency = store.coldLatencies.summary()
+	stats.CachedKeys = len(store.entries)
+	stats.CachedBytes = store.size
+	stats.WorkingSetKeys = len(store.workingSet)
+	for _, reads := range store.workingSet {
+		stats.WorkingSetBytes += reads.size
+	}
+	return stats
+}
+
+// ResetStats starts the observation over, the cached entries are kept
+func (store *CachingStore) ResetStats() {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.stats = ReadPathStats{}
+	store.warmLatencies = latencySamples{}
+	store.coldLatencies = latencySamples{}
+	store.workingSet = map[string]*keyReads{}
+}
+
+// Recommend derives the cache sizing hints from the reads observed since the creation of the store or the last ResetStats
+func (store *CachingStore) Recommend() CacheRecommendation {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	stats := store.statsLocked()
+	recommendation := CacheRecommendation{
+		Stats:         stats,
+		CapacityBytes: store.config.capacity(),
+		PinCandidates: []string{},
+	}
+	if stats.WorkingSetKeys > 0 {
+		recommendation.CapacityBytes = stats.WorkingSetBytes + stats.WorkingSetBytes/4
+	}
+
+	gaps := []time.Duration{}
+	candidates := []string{}
+	for key, reads := range store.workingSet {
+		if reads.reads > 1 {
+			gaps = append(gaps, reads.gaps/time.Duration(reads.reads-1))
+		}
+		if reads.coldReads > 1 {
+			candidates = append(candidates, key)
+		}
+	}
+	if len(gaps) > 0 {
+		sort.Slice(gaps, func(i, j int) bool { return gaps[i] < gaps[j] })
+		recommendation.TTL = (2 * gaps[len(gaps)/2]).Round(time.Second)
+		if recommendation.TTL < time.Second {
+			recommendation.TTL = time.Second
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		left, right := store.workingSet[candidates[i]], store.workingSet[candidates[j]]
+		if left.reads != right.reads {
+			return left.reads > right.reads
+		}
+		return candidates[i] < candidates[j]
+	})
+	if len(candidates) > maxPinCandidates {
+		candidates = candidates[:maxPinCandidates]
+	}
+	recommendation.PinCandidates = append(recommendation.PinCandidates, candidates...)
+	return recommendation
+}
+
+// ReportEvery hands the recommendation over to report (logs it if report is nil), then starts the observation
+// over, at every interval until the context is done
+func (store *CachingStore) ReportEvery(ctx context.Context, interval time.Duration, report func(recommendation CacheRecommendation)) {
+	if report == nil {
+		report = func(recommendation CacheRecommendation) {
+			stats := recommendation.Stats
+			zerolog.Ctx(ctx).Info().
+				Int64("warmReads", stats.WarmReads).
+				Int64("coldReads", stats.ColdReads).
+				Dur("warmP99", stats.WarmLatency.P99).
+				Dur("coldP99", stats.ColdLatency.P99).
+				Int("workingSetKeys", stats.WorkingSetKeys).
+				Int64("workingSetBytes", stats.WorkingSetBytes).
+				Int64("suggestedCapacityBytes", recommendation.CapacityBytes).
+				Dur("suggestedTTL", recommendation.TTL).
+				Strs("pinCandidates", recommendation.PinCandidates).
+				Msg("cache recommendation")
+		}
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				report(store.Recommend())
+				store.ResetStats()
+			}
+		}
+	}()
+}
+
+func (store *CachingStore) GetBlob(ctx context.Context, key string) ([]byte, error) {
+	start := time.Now()
+	if blob, found := store.lookup(cacheKeyOf(ctx, key), false); found {
+		store.observe(key, len(blob.Content), true, start)
+		return bytes.Clone(blob.Content), nil
+	}
+	generation := store.generation()
+	content, err := store.Store.GetBlob(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	store.observe(key, len(content), false, start)
+	store.put(cacheKeyOf(ctx, key), BlobInfo{Key: key, Content: content}, false, generation)
+	return content, nil
+}
+
+func (store *CachingStore) GetBlobInfo(ctx context.Context, key string) (BlobInfo, error) {
+	start := time.Now()
+	if blob, found := store.lookup(cacheKeyOf(ctx, key), true); found {
+		store.observe(key, len(blob.Content), true, start)
+		return cloneBlobInfo(blob), nil
+	}
+	generation := store.generation()
+	blob, err := store.Store.GetBlobInfo(ctx, key)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	store.observe(key, len(blob.Content), false, start)
+	store.put(cacheKeyOf(ctx, key), blob, true, generation)
+	return blob, nil
+}
+
+func (store *CachingStore) AddBlob(ctx context.Context, blob BlobInfo) error {
+	store.invalidate(ctx, blob.Key)
+	defer store.invalidate(ctx, blob.Key)
+	return store.Store.AddBlob(ctx, blob)
+}
+
+func (store *CachingStore) UpdateBlobMetadata(ctx context.Context, key string, metadata map[string]string, modifiedBy string) error {
+	store.invalidate(ctx, key)
+	defer store.invalidate(ctx, key)
+	return store.Store.UpdateBlobMetadata(ctx, key, metadata, modifiedBy)
+}
+
+func (store *CachingStore) DeleteBlob(ctx context.Context, key string, modifiedBy string) error {
+	store.invalidate(ctx, key)
+	defer store.invalidate(ctx, key)
+	return store.Store.DeleteBlob(ctx, key, modifiedBy)
+}
+
+func (store *CachingStore) DeleteBlobs(ctx context.Context, keys []string, modifiedBy string) error {
+	store.invalidate(ctx, keys...)
+	defer store.invalidate(ctx, keys...)
+	return store.Store.DeleteBlobs(ctx, keys, modifiedBy)
+}
+
+func (store *CachingStore) CopyBlob(ctx context.Context, sourceKey string, destinationKey string, modifiedBy string) error {
+	store.invalidate(ctx, destinationKey)
+	defer store.invalidate(ctx, destinationKey)
+	return store.Store.CopyBlob(ctx, sourceKey, destinationKey, modifiedBy)
+}
+
+func (store *CachingStore) RenameBlob(ctx context.Context, oldKey string, newKey string, modifiedBy string) error {
+	store.invalidate(ctx, oldKey, newKey)
+	defer store.invalidate(ctx, oldKey, newKey)
+	return store.Store.RenameBlob(ctx, oldKey, newKey, modifiedBy)
+}
+
+func (store *CachingStore) RestoreBlob(ctx context.Context, key string, commitId string, modifiedBy string) error {
+	store.invalidate(ctx, key)
+	defer store.invalidate(ctx, key)
+	return store.Store.RestoreBlob(ctx, key, commitId, modifiedBy)
+}
+
+func (store *CachingStore) CreateAlias(ctx context.Context, alias string, target string, modifiedBy string) error {
+	store.invalidate(ctx, alias)
+	defer store.invalidate(ctx, alias)
+	return store.Store.CreateAlias(ctx, alias, target, modifiedBy)
+}
@@ -0,0 +1,182 @@
+package vcblobstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"vcblobstore/git"
+)
+
+// KeyChange is the change a version made to a blob. Changing only the metadata of a blob counts as an update
+type KeyChange struct {
+	Key       string        `json:"key"`
+	Operation BlobOperation `json:"operation"`
+}
+
+// RepositoryVersion is a version of the repository with the changes it made to the blobs
+type RepositoryVersion struct {
+	Version    string
+	Author     string
+	AuthorDate time.Time
+	Message    string
+	Changes    []KeyChange
+}
+
+// AddEntryChange records the change of the entry with the key: the change of a blob, or an update of the blob
+// if the entry is its metadata sidecar. Changes of the other internal entries are left out. The change of a
+// blob takes precedence over the change of its metadata
+func (version *RepositoryVersion) AddEntryChange(naming NamingStrategy, entryKey string, operation BlobOperation) {
+	key := entryKey
+	metadataOnly := false
+	if naming.IsInternalKey(entryKey) {
+		blobKey, isSidecar := metadataOwner(naming, entryKey)
+		if !isSidecar {
+			return
+		}
+		key, operation, metadataOnly = blobKey, BlobOperationUpdate, true
+	}
+	for index, change := range version.Changes {
+		if change.Key == key {
+			if !metadataOnly {
+				version.Changes[index].Operation = operation
+			}
+			return
+		}
+	}
+	version.Changes = append(version.Changes, KeyChange{Key: key, Operation: operation})
+}
+
+// ChangedKeys returns the changes of the version as the changes of git.CommitMetadata
+func (version RepositoryVersion) ChangedKeys() []git.ChangedKey {
+	changedKeys := []git.ChangedKey{}
+	for _, change := range version.Changes {
+		changedKeys = append(changedKeys, git.ChangedKey{Key: change.Key, Operation: string(change.Operation)})
+	}
+	return changedKeys
+}
+
+// Stats counts the changes of the version by operation
+func (version RepositoryVersion) Stats() map[BlobOperation]int {
+	stats := map[BlobOperation]int{}
+	for _, change := range version.Changes {
+		stats[change.Operation]++
+	}
+	return stats
+}
+
+// metadataOwner returns the key of the blob the internal entry is the metadata sidecar of
+func metadataOwner(naming NamingStrategy, entryKey string) (string, bool) {
+	sidecarPrefix := naming.MetadataSidecarKey("")
+	if len(sidecarPrefix) == 0 || !strings.HasPrefix(entryKey, sidecarPrefix) {
+		return "", false
+	}
+	key := strings.TrimPrefix(entryKey, sidecarPrefix)
+	if len(key) == 0 || naming.MetadataSidecarKey(key) != entryKey {
+		return "", false
+	}
+	return key, true
+}
+
+type ChangelogFormat string
+
+const (
+	ChangelogJSON     ChangelogFormat = "json"
+	ChangelogMarkdown ChangelogFormat = "markdown"
+)
+
+// ChangelogEntry is a change of a blob in a changelog
+type ChangelogEntry struct {
+	Key       string        `json:"key"`
+	Operation BlobOperation `json:"operation"`
+	Author    string        `json:"author"`
+	Time      time.Time     `json:"time"`
+	Message   string        `json:"message"`
+	Version   string        `json:"version"`
+}
+
+// Changelog lists the changes of the blobs between two states of the repository, oldest first
+type Changelog struct {
+	From    string                `json:"from"`
+	To      string                `json:"to"`
+	Entries []ChangelogEntry      `json:"entries"`
+	Stats   map[BlobOperation]int `json:"stats"`
+}
+
+// GenerateChangelog lists the changes made after fromStateID up to and including toStateID (see ListVersions)
+// in the format, e.g. as the release notes of the configuration changes
+func GenerateChangelog(ctx context.Context, store Admin, fromStateID string, toStateID string, format ChangelogFormat) ([]byte, error) {
+	versions, listErr := store.ListVersions(ctx, fromStateID, toStateID)
+	if listErr != nil {
+		return nil, fmt.Errorf("failed to list the versions from %s to %s: %w", fromStateID, toStateID, listErr)
+	}
+
+	changelog := Changelog{From: fromStateID, To: toStateID, Entries: []ChangelogEntry{}, Stats: map[BlobOperation]int{}}
+	for _, version := range versions {
+		for _, change := range version.Changes {
+			changelog.Entries = append(changelog.Entries, ChangelogEntry{
+				Key:       change.Key,
+				Operation: change.Operation,
+				Author:    version.Author,
+				Time:      version.AuthorDate,
+				Message:   version.Message,
+				Version:   version.Version,
+			})
+			changelog.Stats[change.Operation]++
+		}
+	}
+
+	switch format {
+	case ChangelogJSON:
+		content, marshalErr := json.MarshalIndent(changelog, "", "  ")
+		if marshalErr != nil {
+			return nil, fmt.Errorf("failed to marshal changelog: %w", marshalErr)
+		}
+		return content, nil
+	case ChangelogMarkdown:
+		return changelog.markdown(), nil
+	default:
+		return nil, fmt.Errorf("unknown changelog format %q", format)
+	}
+}
+
+func (changelog Changelog) markdown() []byte {
+	builder := strings.Builder{}
+	fmt.Fprintf(&builder, "# Changes from %s to %s\n\n", stateOrDefault(changelog.From, "the beginning"), stateOrDefault(changelog.To, "now"))
+	fmt.Fprintf(&builder, "%d created, %d updated, %d deleted\n\n",
+		changelog.Stats[BlobOperationCreate], changelog.Stats[BlobOperationUpdate], changelog.Stats[BlobOperationDelete])
+	if len(changelog.Entries) == 0 {
+		return []byte(builder.String())
+	}
+	builder.WriteString("| Time | Key | Operation | Author | Message |\n")
+	builder.WriteString("|------|-----|-----------|--------|---------|\n")
+	for _, entry := range changelog.Entries {
+		fmt.Fprintf(&builder, "| %s | %s | %s | %s | %s |\n",
+			entry.Time.Format(time.RFC3339), markdownKey(entry.Key), entry.Operation, markdownCell(entry.Author), markdownCell(entry.Message))
+	}
+	return []byte(builder.String())
+}
+
+func stateOrDefault(stateID string, fallback string) string {
+	if len(stateID) == 0 {
+		return fallback
+	}
+	return stateID
+}
+
+// markdownKeyEscaper backslash-escapes the characters of the keys markdown would take for emphasis, code spans, links,
+// HTML or cell boundaries. Line breaks become spaces
+var markdownKeyEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "|", `\|`, "[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "\r\n", " ", "\n", " ",
+)
+
+// markdownKey renders the key verbatim in a cell of a markdown table
+func markdownKey(key string) string {
+	return markdownKeyEscaper.Replace(key)
+}
+
+// markdownCell keeps the text within a cell of a markdown table
+func markdownCell(text string) string {
+	return strings.NewReplacer("|", `\|`, "\r\n", " ", "\n", " ").Replace(strings.TrimSpace(text))
+}
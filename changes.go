@@ -0,0 +1,59 @@
+package vcblobstore
+
+import (
+	"context"
+	"fmt"
+)
+
+// BlobChange is a change of a change set: the blob (with its metadata) is written, or removed if Delete is set
+type BlobChange struct {
+	Blob   BlobInfo
+	Delete bool
+}
+
+// ChangeApplier is implemented by the backends able to apply a set of changes as a single version
+type ChangeApplier interface {
+	// ApplyChanges records the changes in a single version described by the message. Either every change is applied or none
+	ApplyChanges(ctx context.Context, changes []BlobChange, message string, author Author) error
+}
+
+// PrepareChanges validates the written blobs of the changes and returns their content as it is to be stored
+func PrepareChanges(ctx context.Context, changes []BlobChange, naming NamingStrategy, textMode TextMode, external *ExternalStorage) ([][]byte, error) {
+	contents := make([][]byte, len(changes))
+	for index, change := range changes {
+		if keyErr := ValidateBlobKey(naming, change.Blob.Key); keyErr != nil {
+			return nil, keyErr
+		}
+		if change.Delete {
+			continue
+		}
+		if checksumErr := change.Blob.VerifyChecksum(); checksumErr != nil {
+			return nil, checksumErr
+		}
+		if modeErr := ValidateFileMode(change.Blob.Mode); modeErr != nil {
+			return nil, modeErr
+		}
+		content, textModeErr := textMode.Apply(change.Blob.Key, change.Blob.Content)
+		if textModeErr != nil {
+			return nil, textModeErr
+		}
+		content, offloadErr := external.Offload(ctx, content)
+		if offloadErr != nil {
+			return nil, fmt.Errorf("failed to prepare %s: %w", change.Blob.Key, offloadErr)
+		}
+		if content == nil {
+			content = []byte{}
+		}
+		contents[index] = content
+	}
+	return contents, nil
+}
+
+// ChangedKeys returns the keys of the changes
+func ChangedKeys(changes []BlobChange) []string {
+	keys := make([]string, 0, len(changes))
+	for _, change := range changes {
+		keys = append(keys, change.Blob.Key)
+	}
+	return keys
+}
@@ -0,0 +1,27 @@
+package vcblobstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ContentSHA256 returns the hex encoded SHA-256 digest of the content
+func ContentSHA256(content []byte) string {
+	digest := sha256.Sum256(content)
+	return hex.EncodeToString(digest[:])
+}
+
+// VerifyChecksum checks the content of the blob against its SHA256 field. Blobs without a checksum pass
+func (blob BlobInfo) VerifyChecksum() error {
+	if len(blob.SHA256) == 0 {
+		return nil
+	}
+	if actual := ContentSHA256(blob.Content); actual != blob.SHA256 {
+		return fmt.Errorf("content of %s has SHA-256 %s instead of the expected %s: %w", blob.Key, actual, blob.SHA256, ErrChecksumMismatch)
+	}
+	return nil
+}
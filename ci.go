@@ -0,0 +1,35 @@
+package vcblobstore
+
+import (
+	"context"
+	"strings"
+)
+
+// SkipCIMarker in a commit message tells GitLab (as well as GitHub Actions and most CI services) not to run
+// pipelines for the commit
+const SkipCIMarker = "[skip ci]"
+
+type skipCIKey struct{}
+
+// WithSkipCI overrides, for the writes made with the returned context, whether the backends mark their commits
+// to be skipped by CI
+func WithSkipCI(ctx context.Context, skip bool) context.Context {
+	return context.WithValue(ctx, skipCIKey{}, skip)
+}
+
+// ShouldSkipCI tells whether the commits made with the context are to be skipped by CI: as set by WithSkipCI or,
+// without that, as configured for the backend
+func ShouldSkipCI(ctx context.Context, configured bool) bool {
+	if skip, set := ctx.Value(skipCIKey{}).(bool); set {
+		return skip
+	}
+	return configured
+}
+
+// MarkSkipCI appends SkipCIMarker to the commit message on a line of its own, unless the message already has it
+func MarkSkipCI(message string) string {
+	if strings.Contains(message, SkipCIMarker) || strings.Contains(message, "[ci skip]") {
+		return message
+	}
+	return strings.TrimRight(message, "\n") + "\n\n" + SkipCIMarker
+}
@@ -0,0 +1,326 @@
+package composite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"sort"
+	"sync"
+	"vcblobstore"
+
+	"github.com/rs/zerolog"
+)
+
+// Store is a read-through Store reading from the primary store and falling back to the secondary one
+// when the blob is missing from the primary or the primary fails in a way its degradation policy says to
+// fall back on, e.g. while the blobs are being migrated from local git to GitLab. The writes go to the primary only, except for the deletions
+// (and the old keys of renamed blobs), which are applied to both so that deleted blobs don't resurface
+// through the fallback
+type Store struct {
+	vcblobstore.Store
+	secondary  vcblobstore.Store
+	policy     vcblobstore.DegradationPolicy
+	backfill   bool
+	backfillBy string
+	mutex      sync.Mutex
+	backfills  map[string]bool
+	pending    sync.WaitGroup
+}
+
+var _ vcblobstore.Store = (*Store)(nil)
+
+func New(primary vcblobstore.Store, secondary vcblobstore.Store) *Store {
+	return &Store{
+		Store:     primary,
+		secondary: secondary,
+		policy:    DefaultPolicy,
+		backfills: map[string]bool{},
+	}
+}
+
+// WithBackfill makes the store copy the blobs read from the secondary store to the primary one in
+// the background. The copies are attributed to modifiedBy, or to the author of the blob if it is empty
+func (store *Store) WithBackfill(modifiedBy string) *Store {
+	store.backfill = true
+	store.backfillBy = modifiedBy
+	return store
+}
+
+// Wait blocks until the pending backfills are done
+func (store *Store) Wait() {
+	store.pending.Wait()
+}
+
+func (store *Store) String() string {
+	return fmt.Sprintf("composite(%s, %s)", store.Store.String(), store.secondary.String())
+}
+
+// DefaultPolicy falls back to the secondary store on every failure of the primary store
+var DefaultPolicy = vcblobstore.DegradationPolicy{
+	Actions: map[vcblobstore.FailureClass]vcblobstore.DegradationAction{
+		vcblobstore.FailureBackendDown: vcblobstore.DegradeFallBack,
+		vcblobstore.FailureRateLimited: vcblobstore.DegradeFallBack,
+		vcblobstore.FailureConflict:    vcblobstore.DegradeFallBack,
+		vcblobstore.FailureCorruption:  vcblobstore.DegradeFallBack,
+	},
+}
+
+// WithPolicy sets the failures of the primary store the reads fall back to the secondary one on (besides
+// the blob being missing): those for which the policy says DegradeFallBack
+func (store *Store) WithPolicy(policy vcblobstore.DegradationPolicy) *Store {
+	store.policy = policy
+	return store
+}
+
+// fallsBack tells whether the read failing with err in the primary store is to be retried in the secondary one
+func (store *Store) fallsBack(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	if errors.Is(err, vcblobstore.ErrBlobNotFound) {
+		return true
+	}
+	action, _ := store.policy.ActionFor(err)
+	return action == vcblobstore.DegradeFallBack
+}
+
+// scheduleBackfill copies the blob from the secondary store to the primary one in the background,
+// unless it is already being copied or the primary store has it by the time the copy starts
+func (store *Store) scheduleBackfill(ctx context.Context, key string, primaryErr error) {
+	if !store.backfill || !errors.Is(primaryErr, vcblobstore.ErrBlobNotFound) {
+		return
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if store.backfills[key] {
+		return
+	}
+	store.backfills[key] = true
+	store.pending.Add(1)
+
+	backfillCtx := context.WithoutCancel(ctx)
+	go func() {
+		defer store.pending.Done()
+		defer func() {
+			store.mutex.Lock()
+			delete(store.backfills, key)
+			store.mutex.Unlock()
+		}()
+		logger := zerolog.Ctx(backfillCtx).With().Str("method", "backfill").Str("key", key).Logger()
+		if err := store.copyToPrimary(backfillCtx, key, store.backfillBy); err != nil {
+			logger.Error().Err(err).Msg("failed to backfill blob")
+			return
+		}
+		logger.Debug().Msg("blob backfilled")
+	}()
+}
+
+// copyToPrimary copies the blob from the secondary store to the primary one unless the primary already has it
+func (store *Store) copyToPrimary(ctx context.Context, key string, modifiedBy string) error {
+	head, headErr := store.Store.HeadBlob(ctx, key)
+	if headErr != nil {
+		return fmt.Errorf("failed to check %s in primary store: %w", key, headErr)
+	}
+	if head.Exists {
+		return nil
+	}
+	blob, getErr := store.secondary.GetBlobInfo(ctx, key)
+	if getErr != nil {
+		return fmt.Errorf("failed to get %s from secondary store: %w", key, getErr)
+	}
+	if len(modifiedBy) > 0 {
+		blob.ModifiedBy = modifiedBy
+		blob.AuthorName = ""
+		blob.AuthorEmail = ""
+	}
+	if addErr := store.Store.AddBlob(ctx, blob); addErr != nil {
+		return fmt.Errorf("failed to copy %s to primary store: %w", key, addErr)
+	}
+	return nil
+}
+
+func (store *Store) GetBlob(ctx context.Context, key string) ([]byte, error) {
+	content, err := store.Store.GetBlob(ctx, key)
+	if !store.fallsBack(ctx, err) {
+		return content, err
+	}
+	content, secondaryErr := store.secondary.GetBlob(ctx, key)
+	if secondaryErr != nil {
+		return nil, secondaryErr
+	}
+	store.scheduleBackfill(ctx, key, err)
+	return content, nil
+}
+
+func (store *Store) GetBlobInfo(ctx context.Context, key string) (vcblobstore.BlobInfo, error) {
+	blob, err := store.Store.GetBlobInfo(ctx, key)
+	if !store.fallsBack(ctx, err) {
+		return blob, err
+	}
+	blob, secondaryErr := store.secondary.GetBlobInfo(ctx, key)
+	if secondaryErr != nil {
+		return vcblobstore.BlobInfo{}, secondaryErr
+	}
+	store.scheduleBackfill(ctx, key, err)
+	return blob, nil
+}
+
+func (store *Store) GetBlobWithChecksum(ctx context.Context, key string) ([]byte, string, error) {
+	content, checksum, err := store.Store.GetBlobWithChecksum(ctx, key)
+	if !store.fallsBack(ctx, err) {
+		return content, checksum, err
+	}
+	content, checksum, secondaryErr := store.secondary.GetBlobWithChecksum(ctx, key)
+	if secondaryErr != nil {
+		return nil, "", secondaryErr
+	}
+	store.scheduleBackfill(ctx, key, err)
+	return content, checksum, nil
+}
+
+// GetBlobAtVersion falls back to the secondary store as the version may well have been recorded there
+func (store *Store) GetBlobAtVersion(ctx context.Context, key string, commitId string) ([]byte, error) {
+	content, err := store.Store.GetBlobAtVersion(ctx, key, commitId)
+	if !store.fallsBack(ctx, err) {
+		return content, err
+	}
+	return store.secondary.GetBlobAtVersion(ctx, key, commitId)
+}
+
+func (store *Store) HeadBlob(ctx context.Context, key string) (vcblobstore.BlobHead, error) {
+	head, err := store.Store.HeadBlob(ctx, key)
+	if err == nil && head.Exists {
+		return head, nil
+	}
+	if err != nil && !store.fallsBack(ctx, err) {
+		return head, err
+	}
+	return store.secondary.HeadBlob(ctx, key)
+}
+
+func (store *Store) ResolveAlias(ctx context.Context, key string) (string, error) {
+	canonicalKey, err := store.Store.ResolveAlias(ctx, key)
+	if err == nil && canonicalKey != key {
+		return canonicalKey, nil
+	}
+	if err != nil && !store.fallsBack(ctx, err) {
+		return canonicalKey, err
+	}
+	return store.secondary.ResolveAlias(ctx, key)
+}
+
+// ListBlobKeys lists the keys of both stores in lexical order
+func (store *Store) ListBlobKeys(ctx context.Context, opts vcblobstore.ListOptions) ([]string, error) {
+	keys := []string{}
+	for key, err := range store.IterateBlobKeys(ctx, opts) {
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// IterateBlobKeys yields the keys of the primary store, then the keys only the secondary store has
+func (store *Store) IterateBlobKeys(ctx context.Context, opts vcblobstore.ListOptions) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		seen := map[string]bool{}
+		for key, err := range store.Store.IterateBlobKeys(ctx, opts) {
+			if err != nil {
+				yield("", err)
+				return
+			}
+			seen[key] = true
+			if !yield(key, nil) {
+				return
+			}
+		}
+		for key, err := range store.secondary.IterateBlobKeys(ctx, opts) {
+			if err != nil {
+				yield("", err)
+				return
+			}
+			if seen[key] {
+				continue
+			}
+			if !yield(key, nil) {
+				return
+			}
+		}
+	}
+}
+
+// deleteFromSecondary deletes the blobs from the secondary store, ignoring those it doesn't have
+func (store *Store) deleteFromSecondary(ctx context.Context, keys []string, modifiedBy string) error {
+	for _, key := range keys {
+		head, headErr := store.secondary.HeadBlob(ctx, key)
+		if headErr != nil {
+			return fmt.Errorf("failed to check %s in secondary store: %w", key, headErr)
+		}
+		if !head.Exists {
+			continue
+		}
+		if deleteErr := store.secondary.DeleteBlob(ctx, key, modifiedBy); deleteErr != nil && !errors.Is(deleteErr, vcblobstore.ErrBlobNotFound) {
+			return fmt.Errorf("failed to delete %s from secondary store: %w", key, deleteErr)
+		}
+	}
+	return nil
+}
+
+func (store *Store) DeleteBlob(ctx context.Context, key string, modifiedBy string) error {
+	primaryErr := store.Store.DeleteBlob(ctx, key, modifiedBy)
+	if primaryErr != nil && !errors.Is(primaryErr, vcblobstore.ErrBlobNotFound) {
+		return primaryErr
+	}
+	head, headErr := store.secondary.HeadBlob(ctx, key)
+	if headErr != nil {
+		return fmt.Errorf("failed to check %s in secondary store: %w", key, headErr)
+	}
+	if !head.Exists {
+		return primaryErr
+	}
+	if deleteErr := store.secondary.DeleteBlob(ctx, key, modifiedBy); deleteErr != nil {
+		return fmt.Errorf("failed to delete %s from secondary store: %w", key, deleteErr)
+	}
+	return nil
+}
+
+func (store *Store) DeleteBlobs(ctx context.Context, keys []string, modifiedBy string) error {
+	primaryKeys := []string{}
+	for _, key := range keys {
+		head, headErr := store.Store.HeadBlob(ctx, key)
+		if headErr != nil {
+			return fmt.Errorf("failed to check %s in primary store: %w", key, headErr)
+		}
+		if head.Exists {
+			primaryKeys = append(primaryKeys, key)
+		}
+	}
+	if len(primaryKeys) > 0 {
+		if err := store.Store.DeleteBlobs(ctx, primaryKeys, modifiedBy); err != nil {
+			return err
+		}
+	}
+	return store.deleteFromSecondary(ctx, keys, modifiedBy)
+}
+
+// CopyBlob copies the blob within the primary store, copying it there first if only the secondary store has it
+func (store *Store) CopyBlob(ctx context.Context, sourceKey string, destinationKey string, modifiedBy string) error {
+	if err := store.copyToPrimary(ctx, sourceKey, modifiedBy); err != nil {
+		return err
+	}
+	return store.Store.CopyBlob(ctx, sourceKey, destinationKey, modifiedBy)
+}
+
+// RenameBlob renames the blob in the primary store, copying it there first if only the secondary store has it
+func (store *Store) RenameBlob(ctx context.Context, oldKey string, newKey string, modifiedBy string) error {
+	if err := store.copyToPrimary(ctx, oldKey, modifiedBy); err != nil {
+		return err
+	}
+	if err := store.Store.RenameBlob(ctx, oldKey, newKey, modifiedBy); err != nil {
+		return err
+	}
+	return store.deleteFromSecondary(ctx, []string{oldKey}, modifiedBy)
+}
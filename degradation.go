@@ -0,0 +1,409 @@
+package vcblobstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+// FailureClass groups the failures of the store by how they can be dealt with
+type FailureClass string
+
+const (
+	// FailureBackendDown covers the failures of the backend itself: unreachable, erroring or timing out
+	FailureBackendDown FailureClass = "backend-down"
+	FailureRateLimited FailureClass = "rate-limited"
+	FailureConflict    FailureClass = "conflict"
+	FailureCorruption  FailureClass = "corruption"
+)
+
+// DegradationAction is what the store does when an operation fails with a failure class
+type DegradationAction string
+
+const (
+	// DegradeFail returns the error to the caller, which is what happens for classes the policy doesn't mention
+	DegradeFail DegradationAction = "fail"
+	// DegradeServeStale answers reads with the content last seen for the key, if any
+	DegradeServeStale DegradationAction = "serve-stale"
+	// DegradeQueue keeps writes in memory and replays them, in order, once the store works again. The queued writes
+	// fail with ErrWriteQueued, as they are lost if the process exits before they could be replayed
+	DegradeQueue DegradationAction = "queue"
+	// DegradeFallBack answers reads from the secondary store
+	DegradeFallBack DegradationAction = "fall-back"
+)
+
+var unclassifiedErrors = []error{
+	ErrBlobNotFound,
+	ErrInvalidKey,
+	ErrRepoNotFound,
+	ErrUnauthorized,
+	ErrForbidden,
+	ErrChecksumMismatch,
+	ErrInvalidTextContent,
+	ErrAliasLoop,
+	context.Canceled,
+}
+
+// ClassifyFailure returns the failure class of err and false in case err is no failure of the store,
+// but e.g. a consequence of the input of the caller
+func ClassifyFailure(err error) (FailureClass, bool) {
+	if err == nil {
+		return "", false
+	}
+	for _, unclassifiedErr := range unclassifiedErrors {
+		if errors.Is(err, unclassifiedErr) {
+			return "", false
+		}
+	}
+	switch {
+	case errors.Is(err, ErrRateLimited):
+		return FailureRateLimited, true
+	case errors.Is(err, ErrConflict):
+		return FailureConflict, true
+	case errors.Is(err, ErrExternalObjectCorrupted):
+		return FailureCorruption, true
+	default:
+		return FailureBackendDown, true
+	}
+}
+
+// DegradationPolicy is the degradation matrix: the action to take per failure class. The actions only
+// apply where they make sense: serving stale content and falling back to the secondary store to reads,
+// queueing to writes; elsewhere (and for the classes not listed) the operation fails
+type DegradationPolicy struct {
+	Actions map[FailureClass]DegradationAction
+	// MaxStaleEntries bounds the number of blobs kept for serving stale content (1024 if unset)
+	MaxStaleEntries int
+	// MaxQueuedWrites bounds the number of queued writes, the writes beyond it fail (256 if unset)
+	MaxQueuedWrites int
+	// OnDropped, if set, is called with the queued writes Flush drops as they fail when replayed
+	OnDropped func(operation string, err error)
+}
+
+const (
+	defaultMaxStaleEntries = 1024
+	defaultMaxQueuedWrites = 256
+)
+
+// ActionFor returns the action the policy prescribes for the failure err
+func (policy DegradationPolicy) ActionFor(err error) (DegradationAction, FailureClass) {
+	class, classified := ClassifyFailure(err)
+	if !classified {
+		return DegradeFail, class
+	}
+	action, found := policy.Actions[class]
+	if !found {
+		return DegradeFail, class
+	}
+	return action, class
+}
+
+func (policy DegradationPolicy) maxStaleEntries() int {
+	if policy.MaxStaleEntries <= 0 {
+		return defaultMaxStaleEntries
+	}
+	return policy.MaxStaleEntries
+}
+
+func (policy DegradationPolicy) maxQueuedWrites() int {
+	if policy.MaxQueuedWrites <= 0 {
+		return defaultMaxQueuedWrites
+	}
+	return policy.MaxQueuedWrites
+}
+
+var ErrWriteQueueFull = errors.New("write queue full")
+
+// ErrWriteQueued tells that the write failed, but has been queued for Flush to replay it. Until then, it is
+// neither visible in the store nor durable
+var ErrWriteQueued = errors.New("write queued")
+
+type queuedWrite struct {
+	operation string
+	// ctx is the context of the caller without its cancellation: the branch, the import and the CI settings it
+	// carries apply to the replay too
+	ctx  context.Context
+	call func(ctx context.Context) error
+}
+
+type staleEntry struct {
+	blob BlobInfo
+	// complete tells whether the metadata of the blob is known, not just its content
+	complete bool
+}
+
+// DegradingStore is a Store decorator applying a degradation policy to the failures of the store it decorates.
+// Queued writes are replayed by Flush, which every write calls first while the queue isn't empty, so that the
+// writes reach the store in the order they were accepted. The queue is kept in memory only: Pending tells how
+// many writes would be lost if the process exited
+type DegradingStore struct {
+	Store
+	policy    DegradationPolicy
+	secondary Store
+
+	mutex sync.Mutex
+	// stale is keyed by branch and key like the entries of CachingStore
+	stale map[cacheKey]staleEntry
+	queue []queuedWrite
+
+	flushMutex sync.Mutex
+}
+
+func NewDegradingStore(store Store, policy DegradationPolicy) *DegradingStore {
+	return &DegradingStore{
+		Store:  store,
+		policy: policy,
+		stale:  map[cacheKey]staleEntry{},
+	}
+}
+
+// WithSecondary sets the store the reads fall back to
+func (store *DegradingStore) WithSecondary(secondary Store) *DegradingStore {
+	store.secondary = secondary
+	return store
+}
+
+// Pending returns the number of queued writes
+func (store *DegradingStore) Pending() int {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return len(store.queue)
+}
+
+func (store *DegradingStore) degraded(ctx context.Context, operation string, class FailureClass, action DegradationAction, err error) {
+	zerolog.Ctx(ctx).Warn().Err(err).
+		Str("operation", operation).
+		Str("failureClass", string(class)).
+		Str("action", string(action)).
+		Msg("store degraded")
+}
+
+func (store *DegradingStore) remember(ctx context.Context, key string, blob BlobInfo, complete bool) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	staleKey := cacheKeyOf(ctx, key)
+	if _, known := store.stale[staleKey]; !known && len(store.stale) >= store.policy.maxStaleEntries() {
+		for evicted := range store.stale {
+			delete(store.stale, evicted)
+			break
+		}
+	}
+	store.stale[staleKey] = staleEntry{blob: blob, complete: complete}
+}
+
+func (store *DegradingStore) forget(ctx context.Context, keys ...string) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	for _, key := range keys {
+		delete(store.stale, cacheKeyOf(ctx, key))
+	}
+}
+
+func (store *DegradingStore) lookupStale(ctx context.Context, key string) (staleEntry, bool) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	entry, found := store.stale[cacheKeyOf(ctx, key)]
+	return entry, found
+}
+
+// read runs the read against the decorated store and, if it fails, against the stale copy or the secondary store
+// as the policy says. The read is given the store to run against and whatever stale copy exists of the key
+func (store *DegradingStore) read(ctx context.Context, operation string, key string, call func(target Store) error, serveStale func(entry staleEntry) bool) error {
+	err := call(store.Store)
+	if err == nil {
+		return nil
+	}
+	action, class := store.policy.ActionFor(err)
+	switch action {
+	case DegradeServeStale:
+		if entry, found := store.lookupStale(ctx, key); found && serveStale(entry) {
+			store.degraded(ctx, operation, class, action, err)
+			return nil
+		}
+	case DegradeFallBack:
+		if store.secondary != nil {
+			store.degraded(ctx, operation, class, action, err)
+			return call(store.secondary)
+		}
+	}
+	return err
+}
+
+func (store *DegradingStore) GetBlob(ctx context.Context, key string) ([]byte, error) {
+	var content []byte
+	err := store.read(ctx, "GetBlob", key, func(target Store) error {
+		var getErr error
+		content, getErr = target.GetBlob(ctx, key)
+		return getErr
+	}, func(entry staleEntry) bool {
+		content = entry.blob.Content
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if entry, found := store.lookupStale(ctx, key); !found || !entry.complete || !bytes.Equal(entry.blob.Content, content) {
+		store.remember(ctx, key, BlobInfo{Key: key, Content: content}, false)
+	}
+	return content, nil
+}
+
+func (store *DegradingStore) GetBlobInfo(ctx context.Context, key string) (BlobInfo, error) {
+	var blob BlobInfo
+	err := store.read(ctx, "GetBlobInfo", key, func(target Store) error {
+		var getErr error
+		blob, getErr = target.GetBlobInfo(ctx, key)
+		return getErr
+	}, func(entry staleEntry) bool {
+		blob = entry.blob
+		return entry.complete
+	})
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	store.remember(ctx, key, blob, true)
+	return blob, nil
+}
+
+func (store *DegradingStore) GetBlobWithChecksum(ctx context.Context, key string) ([]byte, string, error) {
+	var content []byte
+	var checksum string
+	err := store.read(ctx, "GetBlobWithChecksum", key, func(target Store) error {
+		var getErr error
+		content, checksum, getErr = target.GetBlobWithChecksum(ctx, key)
+		return getErr
+	}, func(entry staleEntry) bool {
+		content = entry.blob.Content
+		checksum = ContentSHA256(content)
+		return true
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return content, checksum, nil
+}
+
+// write runs the write against the decorated store, queueing it if the policy says so. While writes are queued,
+// the new ones are only attempted after the queued ones could be replayed
+func (store *DegradingStore) write(ctx context.Context, operation string, call func(ctx context.Context) error) error {
+	err := store.Flush(ctx)
+	if err == nil {
+		err = call(ctx)
+	}
+	if err == nil {
+		return nil
+	}
+
+	action, class := store.policy.ActionFor(err)
+	if action != DegradeQueue {
+		return err
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if len(store.queue) >= store.policy.maxQueuedWrites() {
+		return errors.Join(err, ErrWriteQueueFull)
+	}
+	store.queue = append(store.queue, queuedWrite{operation: operation, ctx: context.WithoutCancel(ctx), call: call})
+	store.degraded(ctx, operation, class, action, err)
+	return fmt.Errorf("%w: %s: %w", ErrWriteQueued, operation, err)
+}
+
+// Flush replays the queued writes in order, each with the context it was queued with. It stops at the first one
+// failing with a failure the policy queues, which stays queued, or once ctx is done. The writes failing otherwise
+// (e.g. as the state changed since they were queued) are dropped and reported to the OnDropped callback of the policy
+func (store *DegradingStore) Flush(ctx context.Context) error {
+	store.flushMutex.Lock()
+	defer store.flushMutex.Unlock()
+	logger := zerolog.Ctx(ctx)
+
+	for {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		store.mutex.Lock()
+		if len(store.queue) == 0 {
+			store.mutex.Unlock()
+			return nil
+		}
+		next := store.queue[0]
+		store.mutex.Unlock()
+
+		err := next.call(next.ctx)
+		if err != nil {
+			if action, _ := store.policy.ActionFor(err); action == DegradeQueue {
+				return err
+			}
+			logger.Error().Err(err).Str("operation", next.operation).Msg("queued write dropped")
+			if store.policy.OnDropped != nil {
+				store.policy.OnDropped(next.operation, err)
+			}
+		} else {
+			logger.Debug().Str("operation", next.operation).Msg("queued write replayed")
+		}
+
+		store.mutex.Lock()
+		store.queue = store.queue[1:]
+		store.mutex.Unlock()
+	}
+}
+
+func (store *DegradingStore) AddBlob(ctx context.Context, blob BlobInfo) error {
+	store.forget(ctx, blob.Key)
+	return store.write(ctx, "AddBlob", func(ctx context.Context) error {
+		return store.Store.AddBlob(ctx, blob)
+	})
+}
+
+func (store *DegradingStore) UpdateBlobMetadata(ctx context.Context, key string, metadata map[string]string, modifiedBy string) error {
+	store.forget(ctx, key)
+	return store.write(ctx, "UpdateBlobMetadata", func(ctx context.Context) error {
+		return store.Store.UpdateBlobMetadata(ctx, key, metadata, modifiedBy)
+	})
+}
+
+func (store *DegradingStore) DeleteBlob(ctx context.Context, key string, modifiedBy string) error {
+	store.forget(ctx, key)
+	return store.write(ctx, "DeleteBlob", func(ctx context.Context) error {
+		return store.Store.DeleteBlob(ctx, key, modifiedBy)
+	})
+}
+
+func (store *DegradingStore) DeleteBlobs(ctx context.Context, keys []string, modifiedBy string) error {
+	store.forget(ctx, keys...)
+	return store.write(ctx, "DeleteBlobs", func(ctx context.Context) error {
+		return store.Store.DeleteBlobs(ctx, keys, modifiedBy)
+	})
+}
+
+func (store *DegradingStore) CopyBlob(ctx context.Context, sourceKey string, destinationKey string, modifiedBy string) error {
+	store.forget(ctx, destinationKey)
+	return store.write(ctx, "CopyBlob", func(ctx context.Context) error {
+		return store.Store.CopyBlob(ctx, sourceKey, destinationKey, modifiedBy)
+	})
+}
+
+func (store *DegradingStore) RenameBlob(ctx context.Context, oldKey string, newKey string, modifiedBy string) error {
+	store.forget(ctx, oldKey, newKey)
+	return store.write(ctx, "RenameBlob", func(ctx context.Context) error {
+		return store.Store.RenameBlob(ctx, oldKey, newKey, modifiedBy)
+	})
+}
+
+func (store *DegradingStore) RestoreBlob(ctx context.Context, key string, commitId string, modifiedBy string) error {
+	store.forget(ctx, key)
+	return store.write(ctx, "RestoreBlob", func(ctx context.Context) error {
+		return store.Store.RestoreBlob(ctx, key, commitId, modifiedBy)
+	})
+}
+
+func (store *DegradingStore) CreateAlias(ctx context.Context, alias string, target string, modifiedBy string) error {
+	store.forget(ctx, alias)
+	return store.write(ctx, "CreateAlias", func(ctx context.Context) error {
+		return store.Store.CreateAlias(ctx, alias, target, modifiedBy)
+	})
+}
@@ -0,0 +1,45 @@
+package vcblobstore
+
+import (
+	"context"
+	"fmt"
+)
+
+// BlobDiff is the difference between two versions of a blob
+type BlobDiff struct {
+	Key string
+	// Patch is the unified diff of the content, starting with its ---/+++ header. It is empty if the content is binary,
+	// too large for the backend to diff or the same at both versions
+	Patch  string
+	Binary bool
+	// TooLarge is set if the content changed but the backend left out the patch for its size
+	TooLarge bool
+	// FromSize and ToSize are the sizes of the content at the two versions, -1 if the blob didn't exist at the version
+	FromSize int64
+	ToSize   int64
+}
+
+// Summary describes the change of the blob in a line, e.g. for binary content which has no patch
+func (diff BlobDiff) Summary() string {
+	switch {
+	case diff.FromSize < 0:
+		return fmt.Sprintf("%s created (%d bytes)", diff.Key, diff.ToSize)
+	case diff.ToSize < 0:
+		return fmt.Sprintf("%s deleted (%d bytes)", diff.Key, diff.FromSize)
+	case diff.Binary:
+		return fmt.Sprintf("%s binary content changed (%d -> %d bytes)", diff.Key, diff.FromSize, diff.ToSize)
+	case diff.TooLarge:
+		return fmt.Sprintf("%s changed, too large to diff (%d -> %d bytes)", diff.Key, diff.FromSize, diff.ToSize)
+	case len(diff.Patch) == 0:
+		return fmt.Sprintf("%s unchanged (%d bytes)", diff.Key, diff.ToSize)
+	default:
+		return fmt.Sprintf("%s changed (%d -> %d bytes)", diff.Key, diff.FromSize, diff.ToSize)
+	}
+}
+
+// BlobDiffer is implemented by the backends able to compare two versions of a blob
+type BlobDiffer interface {
+	// DiffBlob returns the difference between the blob at fromVersion and at toVersion. The difference of LFS and
+	// externally stored blobs is that of their pointers. ErrBlobNotFound is returned if the blob exists at neither version
+	DiffBlob(ctx context.Context, key string, fromVersion string, toVersion string) (BlobDiff, error)
+}
@@ -1,7 +1,77 @@
 package vcblobstore
 
+import "strings"
+
 type BlobInfo struct {
 	Key        string
 	Content    []byte
 	ModifiedBy string
+	// AuthorName and AuthorEmail, when set, identify the author of the change more precisely than ModifiedBy
+	AuthorName  string
+	AuthorEmail string
+	// Metadata holds arbitrary attributes (e.g. content-type, origin) stored along with the blob. Writes with nil
+	// Metadata keep the attributes of an existing blob, an empty map removes them
+	Metadata map[string]string
+	// SHA256 is the hex encoded SHA-256 digest of Content. If set on write, the write fails unless the content matches it
+	SHA256 string
+	// Mode, if set, is the file mode of the blob. Writes without it keep the mode of an existing blob
+	// (FileModeRegular for a new one)
+	Mode FileMode
+}
+
+// Author is the user a change is attributed to
+type Author struct {
+	Name  string
+	Email string
+}
+
+// Author returns the author of the change writing the blob. The name falls back to ModifiedBy if AuthorName is not set
+func (blob BlobInfo) Author() Author {
+	name := blob.AuthorName
+	if len(name) == 0 {
+		name = blob.ModifiedBy
+	}
+	return Author{Name: name, Email: blob.AuthorEmail}
+}
+
+// BlobHead describes a blob without transferring its content
+type BlobHead struct {
+	Key     string
+	Exists  bool
+	Size    int64
+	Version string
+	Mode    FileMode
+}
+
+// KeyVersion is a key with the version of the repository its blob was last changed in
+type KeyVersion struct {
+	Key     string
+	Version string
+}
+
+// ListOptions narrows down the keys returned by ListBlobKeys
+type ListOptions struct {
+	// Prefix, if not empty, restricts the listing to the keys starting with it (e.g. "icons/")
+	Prefix string
+	// Mode, if set, restricts the listing to the blobs with this file mode
+	Mode FileMode
+}
+
+// Directory returns the deepest directory containing every key matching the prefix ("" for the root)
+func (opts ListOptions) Directory() string {
+	lastSlash := strings.LastIndex(opts.Prefix, "/")
+	if lastSlash < 0 {
+		return ""
+	}
+	return opts.Prefix[:lastSlash]
+}
+
+// MatchesMode tells whether a blob with the mode is to be listed
+func (opts ListOptions) MatchesMode(mode FileMode) bool {
+	return len(opts.Mode) == 0 || opts.Mode == mode
+}
+
+// Matches tells whether the key is a blob key (as opposed to an internal one according to naming) matching the prefix
+func (opts ListOptions) Matches(naming NamingStrategy, key string) bool {
+	return strings.HasPrefix(key, opts.Prefix) && !naming.IsInternalKey(key)
 }
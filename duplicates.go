@@ -0,0 +1,85 @@
+package vcblobstore
+
+import (
+	"sort"
+	"sync"
+)
+
+// ContentHash is the SHA-256 digest and the size of the content of a blob as stored in the repository
+// (for content offloaded to external storage that is the pointer manifest, which is the same for identical content)
+type ContentHash struct {
+	Key    string
+	SHA256 string
+	Size   int64
+}
+
+// DuplicateGroup holds the keys of the blobs with identical content
+type DuplicateGroup struct {
+	SHA256 string
+	Size   int64
+	Keys   []string
+}
+
+// GroupDuplicates returns the groups of at least two keys sharing the same content hash, the groups wasting
+// the most space first, the keys of a group in lexical order
+func GroupDuplicates(hashes []ContentHash) []DuplicateGroup {
+	groupOf := map[string]*DuplicateGroup{}
+	for _, hash := range hashes {
+		group, found := groupOf[hash.SHA256]
+		if !found {
+			group = &DuplicateGroup{SHA256: hash.SHA256, Size: hash.Size}
+			groupOf[hash.SHA256] = group
+		}
+		group.Keys = append(group.Keys, hash.Key)
+	}
+
+	groups := []DuplicateGroup{}
+	for _, group := range groupOf {
+		if len(group.Keys) < 2 {
+			continue
+		}
+		sort.Strings(group.Keys)
+		groups = append(groups, *group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		wasteI := groups[i].Size * int64(len(groups[i].Keys)-1)
+		wasteJ := groups[j].Size * int64(len(groups[j].Keys)-1)
+		if wasteI != wasteJ {
+			return wasteI > wasteJ
+		}
+		return groups[i].SHA256 < groups[j].SHA256
+	})
+	return groups
+}
+
+// ContentHashCache remembers the content hashes of git objects, so that only the objects which are new since the
+// previous scan need to be hashed (or asked for). Git object ids are content addressed, so the entries never go stale.
+// The zero value is an empty cache, a nil cache is valid and remembers nothing
+type ContentHashCache struct {
+	mutex  sync.Mutex
+	hashes map[string]ContentHash
+}
+
+// Get returns the hash recorded for the object (without the key, which the object doesn't determine)
+func (cache *ContentHashCache) Get(objectId string) (ContentHash, bool) {
+	if cache == nil {
+		return ContentHash{}, false
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	hash, found := cache.hashes[objectId]
+	return hash, found
+}
+
+func (cache *ContentHashCache) Put(objectId string, hash ContentHash) {
+	if cache == nil {
+		return
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if cache.hashes == nil {
+		cache.hashes = map[string]ContentHash{}
+	}
+	hash.Key = ""
+	cache.hashes[objectId] = hash
+}
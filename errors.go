@@ -1,5 +1,53 @@
 package vcblobstore
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The errors below are returned (wrapped) by every backend, so that callers can tell failures apart with errors.Is
 
 var ErrBlobNotFound = errors.New("blob not found")
+
+var ErrInvalidKey = errors.New("invalid key")
+
+var ErrRepoNotFound = errors.New("repository not found")
+
+// ErrConflict signals that the operation clashes with the current state of the repository or with a concurrent modification
+var ErrConflict = errors.New("conflict")
+
+var ErrRateLimited = errors.New("rate limited")
+
+// RateLimitError is an ErrRateLimited telling when the request may be retried
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (err *RateLimitError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrRateLimited, err.RetryAfter)
+}
+
+func (err *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// RetryAfter returns how long to wait before retrying the operation failed with err and false if err doesn't tell it
+func RetryAfter(err error) (time.Duration, bool) {
+	var rateLimitErr *RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		return rateLimitErr.RetryAfter, true
+	}
+	return 0, false
+}
+
+var ErrUnauthorized = errors.New("unauthorized")
+
+// ErrForbidden signals that the user of the store is known to the backend, but isn't allowed to do the operation
+var ErrForbidden = errors.New("forbidden")
+
+// ErrCommitTooLarge signals that the changes of an atomic write don't fit in a single commit request of the backend
+var ErrCommitTooLarge = errors.New("commit too large")
+
+// ErrBranchProtected signals that the branch the backend commits to doesn't accept pushes from the user of the store
+var ErrBranchProtected = errors.New("branch protected")
@@ -0,0 +1,30 @@
+package vcblobstore
+
+// ChangeEvent notifies of the versions pushed to a branch of the repository, possibly by others than the store,
+// e.g. by editing the files in the web UI of the git server
+type ChangeEvent struct {
+	Branch string
+	// Before and After are the states of the branch before and after the push
+	Before string
+	After  string
+	// Versions are the pushed versions, oldest first
+	Versions []RepositoryVersion
+	// Truncated tells that the notification carried only some of the pushed versions. The complete list is returned
+	// by ListVersions(Before, After)
+	Truncated bool
+}
+
+// ChangedKeys returns the keys changed by any of the versions of the event
+func (event ChangeEvent) ChangedKeys() []string {
+	keys := []string{}
+	seen := map[string]bool{}
+	for _, version := range event.Versions {
+		for _, change := range version.Changes {
+			if !seen[change.Key] {
+				seen[change.Key] = true
+				keys = append(keys, change.Key)
+			}
+		}
+	}
+	return keys
+}
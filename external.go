@@ -0,0 +1,157 @@
+package vcblobstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ExternalPointerHeader starts the content of the pointer manifests committed in place of offloaded content
+const ExternalPointerHeader = "vcblobstore-external-object v1\n"
+
+var ErrExternalObjectCorrupted = errors.New("external object corrupted")
+
+// ObjectStore is the external storage oversized content is offloaded to.
+// Adapters for S3, GCS, Azure Blob etc. implement it on the application side
+type ObjectStore interface {
+	Put(ctx context.Context, name string, content []byte) error
+	Get(ctx context.Context, name string) ([]byte, error)
+	Delete(ctx context.Context, name string) error
+	List(ctx context.Context) ([]string, error)
+}
+
+// ExternalStorage offloads content larger than Threshold bytes to Store and commits only a pointer manifest to git
+type ExternalStorage struct {
+	Store     ObjectStore
+	Threshold int
+}
+
+type externalPointer struct {
+	Object string `json:"object"`
+	Size   int    `json:"size"`
+}
+
+// Offload uploads oversized content to the object store and returns the pointer manifest to commit instead.
+// Content not exceeding the threshold is returned as is
+func (storage *ExternalStorage) Offload(ctx context.Context, content []byte) ([]byte, error) {
+	if storage == nil || len(content) <= storage.Threshold || IsExternalPointer(content) {
+		return content, nil
+	}
+
+	objectName := ContentSHA256(content)
+	if err := storage.Store.Put(ctx, objectName, content); err != nil {
+		return nil, fmt.Errorf("failed to upload external object %s: %w", objectName, err)
+	}
+
+	manifest, marshalErr := json.Marshal(externalPointer{Object: objectName, Size: len(content)})
+	if marshalErr != nil {
+		return nil, fmt.Errorf("failed to encode pointer to external object %s: %w", objectName, marshalErr)
+	}
+	return append([]byte(ExternalPointerHeader), manifest...), nil
+}
+
+// Resolve returns the content the pointer manifest refers to. Content other than pointer manifests is returned as is
+func (storage *ExternalStorage) Resolve(ctx context.Context, content []byte) ([]byte, error) {
+	if storage == nil || !IsExternalPointer(content) {
+		return content, nil
+	}
+
+	pointer, decodeErr := decodeExternalPointer(content)
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+
+	object, getErr := storage.Store.Get(ctx, pointer.Object)
+	if getErr != nil {
+		return nil, fmt.Errorf("failed to download external object %s: %w", pointer.Object, getErr)
+	}
+	if ContentSHA256(object) != pointer.Object || len(object) != pointer.Size {
+		return nil, fmt.Errorf("failed to verify external object %s: %w", pointer.Object, ErrExternalObjectCorrupted)
+	}
+	return object, nil
+}
+
+// CollectGarbage deletes the objects of the object store which are not among the referenced ones and returns their names
+func (storage *ExternalStorage) CollectGarbage(ctx context.Context, referencedObjects map[string]bool) ([]string, error) {
+	objectNames, listErr := storage.Store.List(ctx)
+	if listErr != nil {
+		return nil, fmt.Errorf("failed to list external objects: %w", listErr)
+	}
+
+	deleted := []string{}
+	for _, objectName := range objectNames {
+		if referencedObjects[objectName] {
+			continue
+		}
+		if deleteErr := storage.Store.Delete(ctx, objectName); deleteErr != nil {
+			return deleted, fmt.Errorf("failed to delete unreferenced external object %s: %w", objectName, deleteErr)
+		}
+		deleted = append(deleted, objectName)
+	}
+	return deleted, nil
+}
+
+func IsExternalPointer(content []byte) bool {
+	return bytes.HasPrefix(content, []byte(ExternalPointerHeader))
+}
+
+// ExternalObjectOf returns the name of the external object the pointer manifest refers to and false for other content
+func ExternalObjectOf(content []byte) (string, bool) {
+	if !IsExternalPointer(content) {
+		return "", false
+	}
+	pointer, err := decodeExternalPointer(content)
+	if err != nil {
+		return "", false
+	}
+	return pointer.Object, true
+}
+
+func decodeExternalPointer(content []byte) (externalPointer, error) {
+	pointer := externalPointer{}
+	if err := json.Unmarshal(bytes.TrimPrefix(content, []byte(ExternalPointerHeader)), &pointer); err != nil {
+		return pointer, fmt.Errorf("failed to decode pointer to external object: %w", err)
+	}
+	return pointer, nil
+}
+
+// DirectoryObjectStore is an ObjectStore keeping the objects as files in a local (or mounted network) directory
+type DirectoryObjectStore struct {
+	Root string
+}
+
+func (store DirectoryObjectStore) Put(ctx context.Context, name string, content []byte) error {
+	if err := os.MkdirAll(store.Root, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(store.Root, name), content, 0600)
+}
+
+func (store DirectoryObjectStore) Get(ctx context.Context, name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(store.Root, name))
+}
+
+func (store DirectoryObjectStore) Delete(ctx context.Context, name string) error {
+	return os.Remove(filepath.Join(store.Root, name))
+}
+
+func (store DirectoryObjectStore) List(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(store.Root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
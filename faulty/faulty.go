@@ -0,0 +1,257 @@
+package faulty
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"sync"
+	"time"
+	"vcblobstore"
+)
+
+// ErrInjected is the error the injected faults return unless they specify their own
+var ErrInjected = errors.New("injected fault")
+
+// Fault describes a failure to inject into the calls of an operation (a vcblobstore.Store method, e.g. "AddBlob")
+type Fault struct {
+	// Operation is the name of the affected method; empty means every method
+	Operation string
+	// OnCall, if set, restricts the fault to the OnCall-th call (counting from 1) of the operation
+	OnCall int
+	// Latency delays the affected calls
+	Latency time.Duration
+	// Err is the error the affected calls fail with: ErrInjected if unset and no Latency is given either
+	Err error
+	// AfterCall lets the affected calls reach the store and fails them only afterwards, like a lost response would
+	AfterCall bool
+}
+
+func (fault Fault) err() error {
+	if fault.Err == nil && fault.Latency == 0 {
+		return ErrInjected
+	}
+	return fault.Err
+}
+
+// FaultPlan lists the faults to inject. Every fault matching a call applies
+type FaultPlan []Fault
+
+// Store is a vcblobstore.Store decorator injecting the faults of its plan into the calls it forwards
+type Store struct {
+	vcblobstore.Store
+	plan  FaultPlan
+	mutex sync.Mutex
+	calls map[string]int
+}
+
+// Wrap returns store decorated with the faults of plan
+func Wrap(store vcblobstore.Store, plan FaultPlan) *Store {
+	return &Store{Store: store, plan: plan, calls: map[string]int{}}
+}
+
+// Calls returns the number of calls of the operation so far
+func (store *Store) Calls(operation string) int {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return store.calls[operation]
+}
+
+// faultsFor counts the call and returns the faults applying to it
+func (store *Store) faultsFor(operation string) []Fault {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.calls[operation]++
+	call := store.calls[operation]
+
+	faults := []Fault{}
+	for _, fault := range store.plan {
+		if len(fault.Operation) > 0 && fault.Operation != operation {
+			continue
+		}
+		if fault.OnCall > 0 && fault.OnCall != call {
+			continue
+		}
+		faults = append(faults, fault)
+	}
+	return faults
+}
+
+// inject runs call with the faults planned for the operation applied
+func (store *Store) inject(ctx context.Context, operation string, call func() error) error {
+	faults := store.faultsFor(operation)
+
+	var afterErr error
+	for _, fault := range faults {
+		if fault.Latency > 0 {
+			select {
+			case <-time.After(fault.Latency):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if err := fault.err(); err != nil {
+			if !fault.AfterCall {
+				return err
+			}
+			afterErr = err
+		}
+	}
+
+	if err := call(); err != nil {
+		return err
+	}
+	return afterErr
+}
+
+func (store *Store) CreateRepository(ctx context.Context) error {
+	return store.inject(ctx, "CreateRepository", func() error {
+		return store.Store.CreateRepository(ctx)
+	})
+}
+
+func (store *Store) GetBlob(ctx context.Context, key string) (content []byte, err error) {
+	err = store.inject(ctx, "GetBlob", func() error {
+		content, err = store.Store.GetBlob(ctx, key)
+		return err
+	})
+	return content, err
+}
+
+func (store *Store) GetBlobInfo(ctx context.Context, key string) (blob vcblobstore.BlobInfo, err error) {
+	err = store.inject(ctx, "GetBlobInfo", func() error {
+		blob, err = store.Store.GetBlobInfo(ctx, key)
+		return err
+	})
+	return blob, err
+}
+
+func (store *Store) GetBlobWithChecksum(ctx context.Context, key string) (content []byte, checksum string, err error) {
+	err = store.inject(ctx, "GetBlobWithChecksum", func() error {
+		content, checksum, err = store.Store.GetBlobWithChecksum(ctx, key)
+		return err
+	})
+	return content, checksum, err
+}
+
+func (store *Store) GetBlobAtVersion(ctx context.Context, key string, commitId string) (content []byte, err error) {
+	err = store.inject(ctx, "GetBlobAtVersion", func() error {
+		content, err = store.Store.GetBlobAtVersion(ctx, key, commitId)
+		return err
+	})
+	return content, err
+}
+
+func (store *Store) QueryByAttributes(ctx context.Context, selector vcblobstore.AttributeSelector) (keys []string, err error) {
+	err = store.inject(ctx, "QueryByAttributes", func() error {
+		keys, err = store.Store.QueryByAttributes(ctx, selector)
+		return err
+	})
+	return keys, err
+}
+
+func (store *Store) HeadBlob(ctx context.Context, key string) (head vcblobstore.BlobHead, err error) {
+	err = store.inject(ctx, "HeadBlob", func() error {
+		head, err = store.Store.HeadBlob(ctx, key)
+		return err
+	})
+	return head, err
+}
+
+func (store *Store) GetTree(ctx context.Context, prefix string, depth int) (tree *vcblobstore.TreeNode, err error) {
+	err = store.inject(ctx, "GetTree", func() error {
+		tree, err = store.Store.GetTree(ctx, prefix, depth)
+		return err
+	})
+	return tree, err
+}
+
+func (store *Store) AddBlob(ctx context.Context, blob vcblobstore.BlobInfo) error {
+	return store.inject(ctx, "AddBlob", func() error {
+		return store.Store.AddBlob(ctx, blob)
+	})
+}
+
+func (store *Store) UpdateBlobMetadata(ctx context.Context, key string, metadata map[string]string, modifiedBy string) error {
+	return store.inject(ctx, "UpdateBlobMetadata", func() error {
+		return store.Store.UpdateBlobMetadata(ctx, key, metadata, modifiedBy)
+	})
+}
+
+func (store *Store) DeleteBlob(ctx context.Context, key string, modifiedBy string) error {
+	return store.inject(ctx, "DeleteBlob", func() error {
+		return store.Store.DeleteBlob(ctx, key, modifiedBy)
+	})
+}
+
+func (store *Store) DeleteBlobs(ctx context.Context, keys []string, modifiedBy string) error {
+	return store.inject(ctx, "DeleteBlobs", func() error {
+		return store.Store.DeleteBlobs(ctx, keys, modifiedBy)
+	})
+}
+
+func (store *Store) ListBlobKeys(ctx context.Context, opts vcblobstore.ListOptions) (keys []string, err error) {
+	err = store.inject(ctx, "ListBlobKeys", func() error {
+		keys, err = store.Store.ListBlobKeys(ctx, opts)
+		return err
+	})
+	return keys, err
+}
+
+// IterateBlobKeys applies the faults when the iteration starts: a failing call yields the error only
+func (store *Store) IterateBlobKeys(ctx context.Context, opts vcblobstore.ListOptions) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		stopped := false
+		err := store.inject(ctx, "IterateBlobKeys", func() error {
+			for key, err := range store.Store.IterateBlobKeys(ctx, opts) {
+				if !yield(key, err) {
+					stopped = true
+					return nil
+				}
+			}
+			return nil
+		})
+		if err != nil && !stopped {
+			yield("", err)
+		}
+	}
+}
+
+func (store *Store) CopyBlob(ctx context.Context, sourceKey string, destinationKey string, modifiedBy string) error {
+	return store.inject(ctx, "CopyBlob", func() error {
+		return store.Store.CopyBlob(ctx, sourceKey, destinationKey, modifiedBy)
+	})
+}
+
+func (store *Store) RenameBlob(ctx context.Context, oldKey string, newKey string, modifiedBy string) error {
+	return store.inject(ctx, "RenameBlob", func() error {
+		return store.Store.RenameBlob(ctx, oldKey, newKey, modifiedBy)
+	})
+}
+
+func (store *Store) RestoreBlob(ctx context.Context, key string, commitId string, modifiedBy string) error {
+	return store.inject(ctx, "RestoreBlob", func() error {
+		return store.Store.RestoreBlob(ctx, key, commitId, modifiedBy)
+	})
+}
+
+func (store *Store) CreateAlias(ctx context.Context, alias string, target string, modifiedBy string) error {
+	return store.inject(ctx, "CreateAlias", func() error {
+		return store.Store.CreateAlias(ctx, alias, target, modifiedBy)
+	})
+}
+
+func (store *Store) ResolveAlias(ctx context.Context, key string) (canonicalKey string, err error) {
+	err = store.inject(ctx, "ResolveAlias", func() error {
+		canonicalKey, err = store.Store.ResolveAlias(ctx, key)
+		return err
+	})
+	return canonicalKey, err
+}
+
+func (store *Store) ListAliases(ctx context.Context) (aliases []vcblobstore.Alias, err error) {
+	err = store.inject(ctx, "ListAliases", func() error {
+		aliases, err = store.Store.ListAliases(ctx)
+		return err
+	})
+	return aliases, err
+}
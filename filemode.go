@@ -0,0 +1,34 @@
+package vcblobstore
+
+import (
+	"errors"
+	"fmt"
+)
+
+// FileMode is the git file mode of a blob
+type FileMode string
+
+const (
+	FileModeRegular    FileMode = "100644"
+	FileModeExecutable FileMode = "100755"
+)
+
+var ErrInvalidFileMode = errors.New("invalid file mode")
+
+// ValidateFileMode fails with ErrInvalidFileMode unless the mode is empty (unspecified) or one of the modes of blobs
+func ValidateFileMode(mode FileMode) error {
+	switch mode {
+	case "", FileModeRegular, FileModeExecutable:
+		return nil
+	default:
+		return fmt.Errorf("%q: %w", mode, ErrInvalidFileMode)
+	}
+}
+
+// FileModeOf returns the mode of a blob with the execute flag set as specified
+func FileModeOf(executable bool) FileMode {
+	if executable {
+		return FileModeExecutable
+	}
+	return FileModeRegular
+}
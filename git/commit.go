@@ -7,11 +7,6 @@ import (
 	"time"
 )
 
-var (
-	SimulateGitCommitFailureEnvvarName = "GIT_COMMIT_FAIL_INTRUSIVE_TEST"
-	GitCommitFailureTestCommand        = "procyon lotor"
-)
-
 type CommitQueryResponseItem struct {
 	Id             string `json:"id"`
 	CommittedDate  string `json:"committed_date"`
@@ -23,12 +18,68 @@ type CommitQueryResponseItem struct {
 	CommitterEmail string `json:"committer_email"`
 }
 
+// CommitMetadata describes a commit. The dates are in fixed zones of the offsets they were recorded with, which
+// AuthorOffset and CommitOffset (in seconds east of UTC) keep also for when the dates get converted to another zone
 type CommitMetadata struct {
-	Author     string
-	AuthorDate time.Time
-	Commit     string
-	CommitDate time.Time
-	Message    string
+	Author       string
+	AuthorDate   time.Time
+	AuthorOffset int
+	Commit       string
+	CommitDate   time.Time
+	CommitOffset int
+	Message      string
+	// Changes lists the keys the commit touched
+	Changes []ChangedKey
+}
+
+// ChangedKey is a key touched by a commit with the kind of the change, one of the values of vcblobstore.BlobOperation
+type ChangedKey struct {
+	Key       string
+	Operation string
+}
+
+// DisplayTimeLayout is the layout the commit dates are displayed with, e.g. in audit trails
+const DisplayTimeLayout = "2006-01-02 15:04:05 -07:00"
+
+// PinOffsets pins the dates to fixed zones of their current offsets and records the offsets
+func (metadata CommitMetadata) PinOffsets() CommitMetadata {
+	metadata.AuthorDate, metadata.AuthorOffset = pinOffset(metadata.AuthorDate)
+	metadata.CommitDate, metadata.CommitOffset = pinOffset(metadata.CommitDate)
+	return metadata
+}
+
+// FormatAuthorDate formats the author date in the zone it was recorded in
+func (metadata CommitMetadata) FormatAuthorDate(layout string) string {
+	return metadata.AuthorDate.In(offsetZone(metadata.AuthorOffset)).Format(layout)
+}
+
+// FormatCommitDate formats the commit date in the zone it was recorded in
+func (metadata CommitMetadata) FormatCommitDate(layout string) string {
+	return metadata.CommitDate.In(offsetZone(metadata.CommitOffset)).Format(layout)
+}
+
+// FormatOffset formats an offset in seconds east of UTC as e.g. +02:00
+func FormatOffset(offset int) string {
+	sign := '+'
+	if offset < 0 {
+		sign = '-'
+		offset = -offset
+	}
+	return fmt.Sprintf("%c%02d:%02d", sign, offset/3600, offset%3600/60)
+}
+
+func offsetZone(offset int) *time.Location {
+	return time.FixedZone(FormatOffset(offset), offset)
+}
+
+// pinOffset returns the time in a fixed zone of its offset, so that it isn't tied to the local zone
+// (as time.Parse does with the offsets the local zone uses) and the offset itself
+func pinOffset(t time.Time) (time.Time, int) {
+	if t.IsZero() {
+		return t, 0
+	}
+	_, offset := t.Zone()
+	return t.In(offsetZone(offset)), offset
 }
 
 var (
@@ -81,7 +132,7 @@ func ParseLocalCommitMetadata(metadata string) (CommitMetadata, error) {
 
 	commitMetadata.Message = strings.Join(commitMessageBuffer, "\n")
 
-	return commitMetadata, nil
+	return commitMetadata.PinOffsets(), nil
 }
 
 func parseTimeFromLocalCommitOutput(rexp regexp.Regexp, line string) (time.Time, bool, error) {
@@ -113,5 +164,6 @@ func GitlabCommitResponseToMetadata(response CommitQueryResponseItem) (CommitMet
 		AuthorDate: authorDate,
 		Commit:     fmt.Sprintf("%s <%s>", response.CommitterName, response.CommitterEmail),
 		CommitDate: commitDate,
-	}, nil
+		Message:    strings.TrimSpace(response.Message),
+	}.PinOffsets(), nil
 }
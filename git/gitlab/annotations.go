@@ -0,0 +1,70 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"vcblobstore"
+)
+
+// GetBlobAnnotations returns the last change of each of the blobs. With Config.GraphQL set, the last commits of
+// graphQLBatchSize blobs are asked for in a single query; otherwise the version of every blob takes a request of its
+// own and the metadata of every distinct version another one
+func (g *Gitlab) GetBlobAnnotations(ctx context.Context, keys []string) ([]vcblobstore.BlobAnnotation, error) {
+	annotations := make([]vcblobstore.BlobAnnotation, len(keys))
+	paths := make([]string, len(keys))
+	for index, key := range keys {
+		if keyErr := vcblobstore.ValidateKey(key); keyErr != nil {
+			return nil, keyErr
+		}
+		annotations[index].Key = key
+		paths[index] = g.repoPath(key)
+	}
+
+	if g.graphQL {
+		for start := 0; start < len(paths); start += graphQLBatchSize {
+			end := min(start+graphQLBatchSize, len(paths))
+			commits, commitsErr := g.graphQLLastCommits(ctx, paths[start:end])
+			if commitsErr != nil {
+				return nil, commitsErr
+			}
+			for index, commit := range commits {
+				if len(commit.Sha) == 0 {
+					continue
+				}
+				authorDate, parseErr := time.Parse(time.RFC3339, commit.AuthoredDate)
+				if parseErr != nil {
+					return nil, fmt.Errorf("failed to parse author date of commit %s: %w", commit.Sha, parseErr)
+				}
+				annotation := &annotations[start+index]
+				annotation.Version = commit.Sha
+				annotation.Author = fmt.Sprintf("%s <%s>", commit.AuthorName, commit.AuthorEmail)
+				annotation.AuthorDate = authorDate
+			}
+		}
+		return annotations, nil
+	}
+
+	authors := map[string]vcblobstore.BlobAnnotation{}
+	for index := range annotations {
+		version, versionErr := g.GetVersionFor(ctx, annotations[index].Key)
+		if versionErr != nil {
+			return nil, versionErr
+		}
+		if len(version) == 0 {
+			continue
+		}
+		author, known := authors[version]
+		if !known {
+			metadata, metadataErr := g.getCommitMetadata(ctx, version)
+			if metadataErr != nil {
+				return nil, metadataErr
+			}
+			author = vcblobstore.BlobAnnotation{Version: version, Author: metadata.Author, AuthorDate: metadata.AuthorDate}
+			authors[version] = author
+		}
+		author.Key = annotations[index].Key
+		annotations[index] = author
+	}
+	return annotations, nil
+}
@@ -0,0 +1,83 @@
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+	"vcblobstore"
+)
+
+var _ vcblobstore.Archiver = (*Gitlab)(nil)
+
+// maxErrorBodyBytes limits how much of the body of a failed streamed response is reported
+const maxErrorBodyBytes = 4096
+
+// ExportAll writes the tar.gz archive of the main branch to w
+func (g *Gitlab) ExportAll(ctx context.Context, w io.Writer) error {
+	return g.ExportAllAt(ctx, g.readBranch(ctx), w)
+}
+
+// ExportAllAt writes the tar.gz archive of the commit (or ref) to w, as made by the repository archive API.
+// The files of the archive are under a directory named after the project and the commit. The archive is streamed
+// into w as it is downloaded, so that neither its size nor the time its download takes is limited
+func (g *Gitlab) ExportAllAt(ctx context.Context, version string, w io.Writer) error {
+	query := url.Values{}
+	query.Set("sha", version)
+	if err := g.streamResponse(ctx, fmt.Sprintf("/projects/%s/repository/archive.tar.gz?%s", g.escapedProjectPath(), query.Encode()), w); err != nil {
+		return fmt.Errorf("failed to export version %s of GitLab repo: %w", version, err)
+	}
+	return nil
+}
+
+// streamResponse GETs the API path and copies the body of the response into w. Unlike the other requests, the
+// request isn't limited by the timeout of the clients (but only by ctx), since downloading the body may take long
+func (g *Gitlab) streamResponse(ctx context.Context, apiCallPath string, w io.Writer) error {
+	if capabilityErr := g.credentials.check(apiCallCapability("GET", apiCallPath)); capabilityErr != nil {
+		return capabilityErr
+	}
+	poolItem, _ := g.clientPool.Get()
+	defer func() {
+		_, _ = g.clientPool.Put(poolItem)
+	}()
+	client, ok := poolItem.(http.Client)
+	if !ok {
+		return errors.New("type asssertion error")
+	}
+	client.Timeout = 0
+
+	if waitErr := g.throttle.wait(ctx); waitErr != nil {
+		return waitErr
+	}
+	request, requestCreationErr := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("https://gitlab.com/api/v4%s", apiCallPath), nil)
+	if requestCreationErr != nil {
+		return fmt.Errorf("failed to create request: %w", requestCreationErr)
+	}
+	if authErr := g.credentials.authorizeAPI(ctx, request); authErr != nil {
+		return authErr
+	}
+
+	response, requestErr := client.Do(request)
+	if requestErr != nil {
+		return fmt.Errorf("failed to execute request: %w", requestErr)
+	}
+	defer response.Body.Close()
+	if rateLimitErr := g.throttle.observe(response.Header, time.Now()); rateLimitErr != nil {
+		return rateLimitErr
+	}
+	if response.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, maxErrorBodyBytes))
+		var statusErr error
+		if response.StatusCode == http.StatusTooManyRequests {
+			statusErr = g.throttle.limited(response.Header, time.Now())
+		}
+		return fmt.Errorf("(%d) %s -- %w", response.StatusCode, body, typedStatusError(response.StatusCode, string(body), statusErr))
+	}
+	if _, copyErr := io.Copy(w, response.Body); copyErr != nil {
+		return fmt.Errorf("failed to stream the response: %w", copyErr)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package gitlab
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"vcblobstore"
+
+	"github.com/rs/zerolog"
+)
+
+// commitBranch is the branch a commit goes to and the branch to create it from if it doesn't exist yet
+type commitBranch struct {
+	name      string
+	startFrom string
+}
+
+type mergeRequestProperties struct {
+	SourceBranch       string `json:"source_branch"`
+	TargetBranch       string `json:"target_branch"`
+	Title              string `json:"title"`
+	RemoveSourceBranch bool   `json:"remove_source_branch"`
+}
+
+// commitBranch returns the branch selected with vcblobstore.WithBranch or the staging branch, if either is set, and
+// the main branch otherwise. A missing branch is created from the main branch
+func (g *Gitlab) commitBranch(ctx context.Context) (commitBranch, error) {
+	branch := vcblobstore.BranchOf(ctx, g.stagingBranch)
+	if len(branch) == 0 || branch == g.mainBranch {
+		return commitBranch{name: g.mainBranch}, nil
+	}
+	exists, existsErr := g.branchExists(ctx, branch)
+	if existsErr != nil {
+		return commitBranch{}, existsErr
+	}
+	if exists {
+		return commitBranch{name: branch}, nil
+	}
+	return commitBranch{name: branch, startFrom: g.mainBranch}, nil
+}
+
+// commitBaseContext returns the context reading the entries the next commit starts from: those of the commit branch
+// or, if it is yet to be created, of the branch it is created from. The writes decide with it whether to create,
+// update or delete the entries
+func (g *Gitlab) commitBaseContext(ctx context.Context) (context.Context, error) {
+	branch, branchErr := g.commitBranch(ctx)
+	if branchErr != nil {
+		return nil, branchErr
+	}
+	if len(branch.startFrom) > 0 {
+		return vcblobstore.WithBranch(ctx, branch.startFrom), nil
+	}
+	return vcblobstore.WithBranch(ctx, branch.name), nil
+}
+
+// readBranch returns the branch selected with vcblobstore.WithBranch, if any, and the main branch otherwise
+func (g *Gitlab) readBranch(ctx context.Context) string {
+	return vcblobstore.BranchOf(ctx, g.mainBranch)
+}
+
+func (g *Gitlab) branchExists(ctx context.Context, branch string) (bool, error) {
+	statusCode, _, body, err := g.sendRequest(ctx, "GET", fmt.Sprintf("/projects/%s/repository/branches/%s", g.escapedProjectPath(), url.PathEscape(branch)), nil)
+	if err != nil || (statusCode != http.StatusOK && statusCode != http.StatusNotFound) {
+		return false, fmt.Errorf("failed to look up branch %s: (%d) %s -- %w", branch, statusCode, body, typedStatusError(statusCode, body, err))
+	}
+	return statusCode == http.StatusOK, nil
+}
+
+// checkBranchProtected returns ErrBranchProtected if the branch is protected. It is called to explain a commit
+// rejected as forbidden, which GitLab doesn't tell apart from a missing permission on the project
+func (g *Gitlab) checkBranchProtected(ctx context.Context, branch string) error {
+	statusCode, _, body, err := g.sendRequest(ctx, "GET", fmt.Sprintf("/projects/%s/protected_branches/%s", g.escapedProjectPath(), url.PathEscape(branch)), nil)
+	if err != nil || statusCode != http.StatusOK {
+		zerolog.Ctx(ctx).Debug().Str("branch", branch).Int("statusCode", statusCode).Str("body", body).Err(err).Msg("no protection found for branch")
+		return nil
+	}
+	return fmt.Errorf("%s: %w", branch, vcblobstore.ErrBranchProtected)
+}
+
+// openStagingMergeRequest opens a merge request of the staging branch into the main branch unless one is already open
+func (g *Gitlab) openStagingMergeRequest(ctx context.Context) error {
+	requestBody, marshalErr := json.Marshal(mergeRequestProperties{
+		SourceBranch:       g.stagingBranch,
+		TargetBranch:       g.mainBranch,
+		Title:              fmt.Sprintf("Merge %s into %s", g.stagingBranch, g.mainBranch),
+		RemoveSourceBranch: true,
+	})
+	if marshalErr != nil {
+		return fmt.Errorf("failed to marshal merge request: %w", marshalErr)
+	}
+
+	statusCode, _, body, err := g.sendRequest(ctx, "POST", fmt.Sprintf("/projects/%s/merge_requests", g.escapedProjectPath()), bytes.NewReader(requestBody))
+	if err == nil && statusCode == http.StatusConflict {
+		return nil
+	}
+	if err != nil || statusCode != http.StatusCreated {
+		return fmt.Errorf("failed to open merge request of %s into %s: (%d) %s -- %w", g.stagingBranch, g.mainBranch, statusCode, body, typedStatusError(statusCode, body, err))
+	}
+	zerolog.Ctx(ctx).Info().Str("stagingBranch", g.stagingBranch).Msg("merge request of the staging branch opened")
+	return nil
+}
@@ -0,0 +1,262 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"vcblobstore"
+
+	"github.com/theodesp/blockingQueues"
+)
+
+type roundTripperFunc func(request *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
+
+func newStubGitlab(transport roundTripperFunc) *Gitlab {
+	clientPool, _ := blockingQueues.NewLinkedBlockingQueue(1)
+	_, _ = clientPool.Put(http.Client{Transport: transport})
+	return &Gitlab{
+		project:     gitlabProject{namespacePath: "group", path: "project"},
+		mainBranch:  "main",
+		credentials: credentials{tokens: StaticToken("token")},
+		throttle:    newThrottle(0, 0),
+		clientPool:  clientPool,
+	}
+}
+
+func stubResponse(statusCode int, body string) *http.Response {
+	return &http.Response{StatusCode: statusCode, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}
+}
+
+func TestCommitToProtectedBranch(t *testing.T) {
+	g := newStubGitlab(func(request *http.Request) (*http.Response, error) {
+		switch {
+		case strings.HasSuffix(request.URL.Path, "/repository/commits"):
+			return stubResponse(http.StatusForbidden, `{"message":"403 Forbidden"}`), nil
+		case strings.HasSuffix(request.URL.Path, "/protected_branches/main"):
+			return stubResponse(http.StatusOK, `{"name":"main"}`), nil
+		}
+		return stubResponse(http.StatusNotFound, ""), nil
+	})
+
+	commitErr := g.commit(context.Background(), vcblobstore.Author{Name: "tester"}, "test", nil)
+	if !errors.Is(commitErr, vcblobstore.ErrBranchProtected) {
+		t.Errorf("commit() = %v; want ErrBranchProtected", commitErr)
+	}
+}
+
+func TestCommitThroughStagingBranch(t *testing.T) {
+	requests := []string{}
+	g := newStubGitlab(func(request *http.Request) (*http.Response, error) {
+		body := ""
+		if request.Body != nil {
+			content, _ := io.ReadAll(request.Body)
+			body = string(content)
+		}
+		requests = append(requests, request.Method+" "+request.URL.Path+" "+body)
+		switch {
+		case strings.HasSuffix(request.URL.Path, "/repository/branches/staging"):
+			return stubResponse(http.StatusNotFound, `{"message":"404 Branch Not Found"}`), nil
+		case strings.HasSuffix(request.URL.Path, "/repository/commits"):
+			return stubResponse(http.StatusCreated, "{}"), nil
+		case strings.HasSuffix(request.URL.Path, "/merge_requests"):
+			return stubResponse(http.StatusConflict, `{"message":["Another open merge request already exists for this source branch"]}`), nil
+		}
+		return stubResponse(http.StatusNotFound, ""), nil
+	})
+	g.stagingBranch = "staging"
+	g.stagingMergeRequest = true
+
+	if commitErr := g.commit(context.Background(), vcblobstore.Author{Name: "tester"}, "test", nil); commitErr != nil {
+		t.Fatalf("commit() = %v; want nil", commitErr)
+	}
+	if len(requests) != 3 {
+		t.Fatalf("requests = %v; want a branch lookup, a commit and a merge request", requests)
+	}
+	if !strings.Contains(requests[1], `"branch":"staging","start_branch":"main"`) {
+		t.Errorf("commit request = %s; want the staging branch started from main", requests[1])
+	}
+}
+
+func TestCommitToSelectedBranch(t *testing.T) {
+	requests := []string{}
+	g := newStubGitlab(func(request *http.Request) (*http.Response, error) {
+		body := ""
+		if request.Body != nil {
+			content, _ := io.ReadAll(request.Body)
+			body = string(content)
+		}
+		requests = append(requests, request.Method+" "+request.URL.Path+" "+body)
+		switch {
+		case strings.HasSuffix(request.URL.Path, "/repository/branches/feature"):
+			return stubResponse(http.StatusNotFound, `{"message":"404 Branch Not Found"}`), nil
+		case strings.HasSuffix(request.URL.Path, "/repository/commits"):
+			return stubResponse(http.StatusCreated, "{}"), nil
+		}
+		return stubResponse(http.StatusNotFound, ""), nil
+	})
+	g.stagingBranch = "staging"
+	g.stagingMergeRequest = true
+
+	ctx := vcblobstore.WithBranch(context.Background(), "feature")
+	if commitErr := g.commit(ctx, vcblobstore.Author{Name: "tester"}, "test", nil); commitErr != nil {
+		t.Fatalf("commit() = %v; want nil", commitErr)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("requests = %v; want a branch lookup and a commit", requests)
+	}
+	if !strings.Contains(requests[1], `"branch":"feature","start_branch":"main"`) {
+		t.Errorf("commit request = %s; want the feature branch started from main", requests[1])
+	}
+	if branch := g.readBranch(ctx); branch != "feature" {
+		t.Errorf("readBranch() = %s; want feature", branch)
+	}
+}
+
+func TestMergeBranch(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		strategy     vcblobstore.MergeStrategy
+		mergeBase    string
+		hasConflicts bool
+		wantErr      error
+		wantMerged   bool
+	}{
+		{name: "diverged", mergeBase: "base", wantMerged: true},
+		{name: "already merged", mergeBase: "staging-head"},
+		{name: "fast-forward only", strategy: vcblobstore.MergeFastForward, mergeBase: "base", wantErr: vcblobstore.ErrNotFastForward},
+		{name: "conflict", mergeBase: "base", hasConflicts: true, wantErr: vcblobstore.ErrConflict},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			merged := false
+			g := newStubGitlab(func(request *http.Request) (*http.Response, error) {
+				path := request.URL.Path
+				switch {
+				case strings.HasSuffix(path, "/repository/branches/staging"):
+					return stubResponse(http.StatusOK, `{"commit": {"id": "staging-head"}}`), nil
+				case strings.HasSuffix(path, "/repository/branches/production"):
+					return stubResponse(http.StatusOK, `{"commit": {"id": "production-head"}}`), nil
+				case strings.HasSuffix(path, "/repository/merge_base"):
+					return stubResponse(http.StatusOK, `{"id": "`+test.mergeBase+`"}`), nil
+				case strings.HasSuffix(path, "/repository/compare"):
+					return stubResponse(http.StatusOK, `{"diffs": [{"new_path": "shared"}, {"new_path": "`+request.URL.Query().Get("to")+`"}]}`), nil
+				case strings.HasSuffix(path, "/merge_requests") && request.Method == "POST":
+					return stubResponse(http.StatusConflict, `{"message": ["Another open merge request already exists for this source branch"]}`), nil
+				case strings.HasSuffix(path, "/merge_requests"):
+					return stubResponse(http.StatusOK, `[{"iid": 7, "detailed_merge_status": "checking"}]`), nil
+				case strings.HasSuffix(path, "/merge_requests/7"):
+					if test.hasConflicts {
+						return stubResponse(http.StatusOK, `{"iid": 7, "detailed_merge_status": "conflict", "has_conflicts": true}`), nil
+					}
+					return stubResponse(http.StatusOK, `{"iid": 7, "detailed_merge_status": "mergeable"}`), nil
+				case strings.HasSuffix(path, "/merge_requests/7/merge") && request.Method == "PUT":
+					merged = true
+					return stubResponse(http.StatusOK, `{"iid": 7, "state": "merged"}`), nil
+				}
+				return stubResponse(http.StatusNotFound, ""), nil
+			})
+			g.naming = vcblobstore.NamingOrDefault(nil)
+
+			ctx := vcblobstore.WithMergeStrategy(context.Background(), test.strategy)
+			mergeErr := g.MergeBranch(ctx, "staging", "production", "promoter")
+			if !errors.Is(mergeErr, test.wantErr) || (test.wantErr == nil && mergeErr != nil) {
+				t.Fatalf("MergeBranch() = %v; want %v", mergeErr, test.wantErr)
+			}
+			if merged != test.wantMerged {
+				t.Errorf("merged = %v; want %v", merged, test.wantMerged)
+			}
+			conflict := &vcblobstore.MergeConflictError{}
+			if test.hasConflicts && (!errors.As(mergeErr, &conflict) || len(conflict.Keys) != 1 || conflict.Keys[0] != "shared") {
+				t.Errorf("MergeBranch() = %v; want a conflict on shared", mergeErr)
+			}
+		})
+	}
+}
+
+func TestWritesProbeStagingBranch(t *testing.T) {
+	g := newStubGitlab(func(request *http.Request) (*http.Response, error) {
+		switch {
+		case strings.HasSuffix(request.URL.Path, "/repository/branches/staging"):
+			return stubResponse(http.StatusOK, `{"commit": {"id": "staging-head"}}`), nil
+		case strings.Contains(request.URL.Path, "/repository/files/") && request.URL.Query().Get("ref") == "staging":
+			response := stubResponse(http.StatusOK, "")
+			response.Header.Set("X-Gitlab-Size", "1")
+			return response, nil
+		}
+		return stubResponse(http.StatusNotFound, ""), nil
+	})
+	g.naming = vcblobstore.DefaultNaming
+	g.stagingBranch = "staging"
+
+	action, actionErr := g.createOrUpdateAction(context.Background(), "staged")
+	if actionErr != nil || action != commitActionUpdate {
+		t.Errorf("createOrUpdateAction() = %s, %v; want update of the blob staged", action, actionErr)
+	}
+	actions, actionsErr := g.metadataActions(context.Background(), "staged", nil)
+	if actionsErr != nil || len(actions) != 1 || actions[0].Action != commitActionDelete {
+		t.Errorf("metadataActions() = %v, %v; want the deletion of the sidecar staged", actions, actionsErr)
+	}
+}
+
+func TestMergeBranchDerivedEntries(t *testing.T) {
+	indexes := map[string]string{
+		"base":            `{"team=payments": ["base"]}`,
+		"staging-head":    `{"team=payments": ["base", "staged"]}`,
+		"production-head": `{"team=payments": ["base", "hotfix"]}`,
+	}
+	committed := map[string]string{}
+	g := newStubGitlab(func(request *http.Request) (*http.Response, error) {
+		path := request.URL.Path
+		switch {
+		case strings.HasSuffix(path, "/repository/branches/staging"):
+			return stubResponse(http.StatusOK, `{"commit": {"id": "staging-head"}}`), nil
+		case strings.HasSuffix(path, "/repository/branches/production"):
+			return stubResponse(http.StatusOK, `{"commit": {"id": "production-head"}}`), nil
+		case strings.HasSuffix(path, "/repository/merge_base"):
+			return stubResponse(http.StatusOK, `{"id": "base"}`), nil
+		case strings.HasSuffix(path, "/repository/files/"+vcblobstore.DefaultNaming.AttributeIndexKey()):
+			index, found := indexes[request.URL.Query().Get("ref")]
+			if !found {
+				return stubResponse(http.StatusNotFound, ""), nil
+			}
+			return stubResponse(http.StatusOK, `{"encoding": "base64", "content": "`+base64.StdEncoding.EncodeToString([]byte(index))+`"}`), nil
+		case strings.HasSuffix(path, "/repository/commits") && request.Method == "POST":
+			properties := commitProperties{}
+			if jsonErr := json.NewDecoder(request.Body).Decode(&properties); jsonErr != nil || len(properties.Actions) != 1 {
+				return stubResponse(http.StatusBadRequest, ""), nil
+			}
+			content, _ := base64.StdEncoding.DecodeString(*properties.Actions[0].Content)
+			committed[properties.Branch] = string(content)
+			return stubResponse(http.StatusCreated, "{}"), nil
+		case strings.HasSuffix(path, "/repository/compare"):
+			return stubResponse(http.StatusOK, `{"diffs": [{"new_path": "`+vcblobstore.DefaultNaming.AttributeIndexKey()+`"}]}`), nil
+		case strings.HasSuffix(path, "/merge_requests") && request.Method == "POST":
+			return stubResponse(http.StatusCreated, `{"iid": 7, "detailed_merge_status": "checking"}`), nil
+		case strings.HasSuffix(path, "/merge_requests/7"):
+			return stubResponse(http.StatusOK, `{"iid": 7, "detailed_merge_status": "conflict", "has_conflicts": true}`), nil
+		}
+		return stubResponse(http.StatusNotFound, ""), nil
+	})
+	g.naming = vcblobstore.DefaultNaming
+	g.maxCommitPayload = defaultMaxCommitPayloadBytes
+
+	mergeErr := g.MergeBranch(context.Background(), "staging", "production", "promoter")
+	conflict := &vcblobstore.MergeConflictError{}
+	if !errors.Is(mergeErr, vcblobstore.ErrConflict) || errors.As(mergeErr, &conflict) {
+		t.Errorf("MergeBranch() = %v; want a conflict naming no blob", mergeErr)
+	}
+	for _, branch := range []string{"staging", "production"} {
+		index, decodeErr := vcblobstore.DecodeAttributeIndex([]byte(committed[branch]))
+		if decodeErr != nil || strings.Join(index["team=payments"], ",") != "base,hotfix,staged" {
+			t.Errorf("attribute index committed to %s = %s; want the union of both branches", branch, committed[branch])
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"vcblobstore"
+
+	"github.com/rs/zerolog"
+)
+
+// ApplyChanges records the changes in a single commit. Of the changes of the same key only the last one is applied.
+// Changes too large for a single commit request fail with vcblobstore.ErrCommitTooLarge
+func (g *Gitlab) ApplyChanges(ctx context.Context, changes []vcblobstore.BlobChange, message string, author vcblobstore.Author) error {
+	logger := zerolog.Ctx(ctx).With().Int("changeCount", len(changes)).Str("method", "ApplyChanges").Logger()
+
+	// GitLab rejects a commit with several actions on the same file
+	changes = lastChangePerKey(changes)
+
+	contents, prepareErr := vcblobstore.PrepareChanges(ctx, changes, g.naming, g.textMode, g.external)
+	if prepareErr != nil {
+		return prepareErr
+	}
+	for index, change := range changes {
+		if change.Delete {
+			continue
+		}
+		content, lfsErr := g.offloadLFS(ctx, change.Blob.Key, contents[index])
+		if lfsErr != nil {
+			return fmt.Errorf("failed to apply changes to GitLab repo: %w", lfsErr)
+		}
+		contents[index] = content
+	}
+
+	actions := []commitActionOnByteSlice{}
+	changedMetadata := map[string]map[string]string{}
+	for index, change := range changes {
+		key := change.Blob.Key
+		if change.Delete {
+			actions = append(actions, commitActionOnByteSlice{
+				Action:   commitActionDelete,
+				FilePath: g.repoPath(key),
+			})
+		} else {
+			action, actionErr := g.createOrUpdateAction(ctx, key)
+			if actionErr != nil {
+				return fmt.Errorf("failed to apply changes to GitLab repo: %w", actionErr)
+			}
+			actions = append(actions, commitActionOnByteSlice{
+				Action:   action,
+				FilePath: g.repoPath(key),
+				Content:  contents[index],
+			})
+			actions = append(actions, g.modeActions(key, change.Blob.Mode)...)
+			if change.Blob.Metadata == nil {
+				continue
+			}
+		}
+		metadataActions, metadataErr := g.metadataActions(ctx, key, change.Blob.Metadata)
+		if metadataErr != nil {
+			return fmt.Errorf("failed to apply changes to GitLab repo: %w", metadataErr)
+		}
+		actions = append(actions, metadataActions...)
+		changedMetadata[key] = change.Blob.Metadata
+	}
+	indexActions, indexErr := g.attributeIndexActions(ctx, changedMetadata)
+	if indexErr != nil {
+		return fmt.Errorf("failed to apply changes to GitLab repo: %w", indexErr)
+	}
+	actions = append(actions, indexActions...)
+
+	commitErr := g.commitAtomically(ctx, author, message, actions)
+	if commitErr != nil {
+		return fmt.Errorf("failed to apply %d changes to GitLab repo: %w", len(changes), commitErr)
+	}
+
+	g.access.RecordWrite(vcblobstore.ChangedKeys(changes)...)
+	logger.Info().Msg("Changes applied to GitLab repository")
+	return nil
+}
+
+// lastChangePerKey returns the last change of each key, in the order of the changes
+func lastChangePerKey(changes []vcblobstore.BlobChange) []vcblobstore.BlobChange {
+	last := map[string]int{}
+	for index, change := range changes {
+		last[change.Blob.Key] = index
+	}
+	merged := make([]vcblobstore.BlobChange, 0, len(last))
+	for index, change := range changes {
+		if last[change.Blob.Key] == index {
+			merged = append(merged, change)
+		}
+	}
+	return merged
+}
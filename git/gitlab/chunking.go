@@ -0,0 +1,109 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"vcblobstore"
+
+	"github.com/rs/zerolog"
+)
+
+// defaultMaxCommitPayloadBytes stays well below the request size limits of GitLab
+const defaultMaxCommitPayloadBytes = 20 * 1024 * 1024
+
+// commitActionOverhead estimates the JSON of an action beside its content and paths
+const commitActionOverhead = 128
+
+// CommitProgress is told the number of actions committed so far and the total number of actions of a write
+type CommitProgress func(committedActions int, totalActions int)
+
+func actionPayloadSize(action commitActionOnByteSlice) int {
+	return base64.StdEncoding.EncodedLen(len(action.Content)) + len(action.FilePath) + len(action.PreviousPath) + commitActionOverhead
+}
+
+func payloadSize(actions []commitActionOnByteSlice) int {
+	size := 0
+	for _, action := range actions {
+		size += actionPayloadSize(action)
+	}
+	return size
+}
+
+// groupActions puts the actions in the same group, so that they are committed together
+func groupActions(group string, actions []commitActionOnByteSlice) []commitActionOnByteSlice {
+	for index := range actions {
+		actions[index].group = group
+	}
+	return actions
+}
+
+// chunkActions splits the actions into consecutive chunks whose payload doesn't exceed maxPayload. The actions of
+// a group are never split, a group larger than maxPayload alone makes up a chunk
+func chunkActions(actions []commitActionOnByteSlice, maxPayload int) [][]commitActionOnByteSlice {
+	groups := [][]commitActionOnByteSlice{}
+	for index, action := range actions {
+		if index == 0 || action.group != actions[index-1].group {
+			groups = append(groups, []commitActionOnByteSlice{})
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], action)
+	}
+
+	chunks := [][]commitActionOnByteSlice{}
+	chunk := []commitActionOnByteSlice{}
+	chunkSize := 0
+	for _, group := range groups {
+		size := payloadSize(group)
+		if len(chunk) > 0 && chunkSize+size > maxPayload {
+			chunks = append(chunks, chunk)
+			chunk = []commitActionOnByteSlice{}
+			chunkSize = 0
+		}
+		chunk = append(chunk, group...)
+		chunkSize += size
+	}
+	return append(chunks, chunk)
+}
+
+// commitAtomically records the actions in a single commit and fails with ErrCommitTooLarge rather than splitting them
+func (g *Gitlab) commitAtomically(ctx context.Context, author vcblobstore.Author, commitMessage string, actions []commitActionOnByteSlice) error {
+	if size := payloadSize(actions); size > g.maxCommitPayload {
+		return fmt.Errorf("%w: %d actions make up %d bytes, the limit is %d bytes", vcblobstore.ErrCommitTooLarge, len(actions), size, g.maxCommitPayload)
+	}
+	if err := g.commitChunk(ctx, author, commitMessage, actions); err != nil {
+		return err
+	}
+	g.reportCommitProgress(len(actions), len(actions))
+	return nil
+}
+
+// commit records the actions, in as many sequential commits as needed to keep each request below the payload limit
+func (g *Gitlab) commit(ctx context.Context, author vcblobstore.Author, commitMessage string, actions []commitActionOnByteSlice) error {
+	chunks := chunkActions(actions, g.maxCommitPayload)
+	if len(chunks) == 1 {
+		if err := g.commitChunk(ctx, author, commitMessage, actions); err != nil {
+			return err
+		}
+		g.reportCommitProgress(len(actions), len(actions))
+		return nil
+	}
+
+	logger := zerolog.Ctx(ctx).With().Str("method", "commit").Int("actionCount", len(actions)).Int("chunkCount", len(chunks)).Logger()
+	logger.Info().Msg("splitting oversized commit")
+	committed := 0
+	for index, chunk := range chunks {
+		chunkMessage := fmt.Sprintf("%s (part %d/%d)", commitMessage, index+1, len(chunks))
+		if err := g.commitChunk(ctx, author, chunkMessage, chunk); err != nil {
+			return fmt.Errorf("failed to commit part %d of %d after %d of %d actions: %w", index+1, len(chunks), committed, len(actions), err)
+		}
+		committed += len(chunk)
+		g.reportCommitProgress(committed, len(actions))
+	}
+	return nil
+}
+
+func (g *Gitlab) reportCommitProgress(committedActions int, totalActions int) {
+	if g.onCommitProgress != nil {
+		g.onCommitProgress(committedActions, totalActions)
+	}
+}
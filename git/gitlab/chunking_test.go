@@ -0,0 +1,126 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"vcblobstore"
+)
+
+func TestChunkActions(t *testing.T) {
+	small := func(key string) commitActionOnByteSlice {
+		return commitActionOnByteSlice{Action: commitActionCreate, FilePath: key, Content: make([]byte, 200), group: key}
+	}
+	large := commitActionOnByteSlice{Action: commitActionCreate, FilePath: "large", Content: make([]byte, 3000), group: "large"}
+
+	chunks := chunkActions([]commitActionOnByteSlice{small("a"), small("b"), large, small("c")}, 1000)
+	sizes := []int{}
+	for _, chunk := range chunks {
+		sizes = append(sizes, len(chunk))
+	}
+	if len(sizes) != 3 || sizes[0] != 2 || sizes[1] != 1 || sizes[2] != 1 {
+		t.Errorf("chunk sizes = %v; want [2 1 1]", sizes)
+	}
+
+	if chunks := chunkActions(nil, 1000); len(chunks) != 1 || len(chunks[0]) != 0 {
+		t.Errorf("chunkActions(nil) = %v; want a single empty chunk", chunks)
+	}
+
+	// The blob and its sidecar stay together
+	blob := commitActionOnByteSlice{Action: commitActionCreate, FilePath: "blob", Content: make([]byte, 600), group: "blob"}
+	sidecar := commitActionOnByteSlice{Action: commitActionCreate, FilePath: ".vcblobstore/metadata/blob", Content: make([]byte, 200), group: "blob"}
+	other := commitActionOnByteSlice{Action: commitActionCreate, FilePath: "other", Content: make([]byte, 200), group: "other"}
+	chunks = chunkActions([]commitActionOnByteSlice{other, blob, sidecar}, 1000)
+	if len(chunks) != 2 || len(chunks[0]) != 1 || len(chunks[1]) != 2 {
+		t.Errorf("chunkActions() = %v; want [[other] [blob sidecar]]", chunks)
+	}
+}
+
+func TestCommitInChunks(t *testing.T) {
+	messages := []string{}
+	g := newStubGitlab(func(request *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(request.Body)
+		messages = append(messages, string(body))
+		return stubResponse(http.StatusCreated, "{}"), nil
+	})
+	g.maxCommitPayload = 1000
+	progress := []int{}
+	g.onCommitProgress = func(committedActions int, totalActions int) {
+		progress = append(progress, committedActions, totalActions)
+	}
+
+	actions := []commitActionOnByteSlice{
+		{Action: commitActionCreate, FilePath: "a", Content: make([]byte, 600), group: "a"},
+		{Action: commitActionCreate, FilePath: "b", Content: make([]byte, 600), group: "b"},
+	}
+	if commitErr := g.commit(context.Background(), vcblobstore.Author{Name: "tester"}, "add", actions); commitErr != nil {
+		t.Fatalf("commit() = %v; want nil", commitErr)
+	}
+	if len(messages) != 2 || !strings.Contains(messages[0], `"add (part 1/2)"`) || !strings.Contains(messages[1], `"add (part 2/2)"`) {
+		t.Errorf("commit requests = %v; want two parts", messages)
+	}
+	if len(progress) != 4 || progress[0] != 1 || progress[2] != 2 || progress[3] != 2 {
+		t.Errorf("progress = %v; want [1 2 2 2]", progress)
+	}
+}
+
+func TestApplyChangesTooLarge(t *testing.T) {
+	g := newStubGitlab(func(request *http.Request) (*http.Response, error) {
+		if request.Method == http.MethodPost {
+			t.Errorf("unexpected commit request")
+		}
+		return stubResponse(http.StatusNotFound, "{}"), nil
+	})
+	g.maxCommitPayload = 1000
+	g.naming = vcblobstore.DefaultNaming
+
+	changes := []vcblobstore.BlobChange{
+		{Blob: vcblobstore.BlobInfo{Key: "a", Content: make([]byte, 600)}},
+		{Blob: vcblobstore.BlobInfo{Key: "b", Content: make([]byte, 600)}},
+	}
+	applyErr := g.ApplyChanges(context.Background(), changes, "add", vcblobstore.Author{Name: "tester"})
+	if !errors.Is(applyErr, vcblobstore.ErrCommitTooLarge) {
+		t.Errorf("ApplyChanges() = %v; want ErrCommitTooLarge", applyErr)
+	}
+}
+
+func TestApplyChangesRepeatedKey(t *testing.T) {
+	commitBody := ""
+	g := newStubGitlab(func(request *http.Request) (*http.Response, error) {
+		if request.Method == http.MethodPost {
+			content, _ := io.ReadAll(request.Body)
+			commitBody = string(content)
+			return stubResponse(http.StatusCreated, "{}"), nil
+		}
+		return stubResponse(http.StatusNotFound, `{"message": "404 File Not Found"}`), nil
+	})
+	g.maxCommitPayload = defaultMaxCommitPayloadBytes
+	g.naming = vcblobstore.DefaultNaming
+
+	changes := []vcblobstore.BlobChange{
+		{Blob: vcblobstore.BlobInfo{Key: "a", Content: []byte("first")}},
+		{Blob: vcblobstore.BlobInfo{Key: "b", Content: []byte("b")}},
+		{Blob: vcblobstore.BlobInfo{Key: "a", Content: []byte("last")}},
+	}
+	if applyErr := g.ApplyChanges(context.Background(), changes, "add", vcblobstore.Author{Name: "tester"}); applyErr != nil {
+		t.Fatalf("ApplyChanges() = %v; want nil", applyErr)
+	}
+	props := commitProperties{}
+	if jsonErr := json.Unmarshal([]byte(commitBody), &props); jsonErr != nil {
+		t.Fatal(jsonErr)
+	}
+	paths := []string{}
+	for _, action := range props.Actions {
+		paths = append(paths, action.FilePath)
+	}
+	if !slices.Equal(paths, []string{"b", "a"}) || props.Actions[1].Content == nil ||
+		*props.Actions[1].Content != base64.StdEncoding.EncodeToString([]byte("last")) {
+		t.Errorf("actions = %s; want a single action per key with the last change of a", commitBody)
+	}
+}
@@ -1,8 +1,102 @@
 package gitlab
 
+import (
+	"net/http"
+	"time"
+	"vcblobstore"
+)
+
+type Visibility string
+
+const (
+	VisibilityPrivate  Visibility = "private"
+	VisibilityInternal Visibility = "internal"
+	VisibilityPublic   Visibility = "public"
+)
+
+// ProjectAttributes are the attributes CreateRepository sets on the project besides its namespace and path.
+// Those left empty are set by GitLab according to the defaults of the instance and the namespace
+type ProjectAttributes struct {
+	Visibility  Visibility
+	Description string
+	// DefaultBranch is the main branch (GitlabMainBranch) by default
+	DefaultBranch string
+	Topics        []string
+	// InitializeWithReadme makes GitLab create the default branch with a README commit
+	InitializeWithReadme bool
+}
+
 type Config struct {
+	// GitlabNamespacePath is the full path of the user or group owning the project, e.g. org/team/subteam
 	GitlabNamespacePath string
 	GitlabProjectPath   string
 	GitlabMainBranch    string
+	// StagingBranch, if set, receives the commits instead of the (protected) main branch. It is branched off the
+	// main branch when it doesn't exist. Reads keep being served from the main branch, so writes become visible
+	// only once the staging branch is merged
+	StagingBranch string
+	// StagingMergeRequest tells to open a merge request of the staging branch into the main branch after each commit,
+	// unless one is already open. The staging branch is removed when the merge request is merged
+	StagingMergeRequest bool
 	GitlabAccessToken   string
+	// ProjectAttributes are applied by CreateRepository
+	ProjectAttributes ProjectAttributes
+	// TokenProvider, if set, supplies the token of the requests instead of GitlabAccessToken
+	TokenProvider TokenProvider
+	// TokenType is the type of the token, TokenTypeAccess by default. With TokenTypeJob the token is taken from
+	// the CI_JOB_TOKEN environment variable unless GitlabAccessToken or TokenProvider is set
+	TokenType TokenType
+	// DeployTokenUsername is the username of the deploy token, required with TokenTypeDeploy
+	DeployTokenUsername string
+	// TextMode lists the key patterns of text blobs to normalize on write
+	TextMode vcblobstore.TextMode
+	// ExternalStorage, if set, receives the content of oversized blobs
+	ExternalStorage *vcblobstore.ExternalStorage
+	// LFS, if set, selects the blobs to upload through the LFS batch API of the project. GitLab serves them as LFS
+	// files to git clients only if the repository has a matching .gitattributes, which is left to the application
+	LFS *vcblobstore.LFS
+	// AccessTracker, if set, counts the reads and writes of the keys
+	AccessTracker *vcblobstore.AccessTracker
+	// Naming decides the keys of the entries derived from the blobs (DefaultNaming if unset)
+	Naming vcblobstore.NamingStrategy
+	// Sharding, if set, spreads the blobs over hash prefix directories
+	Sharding *vcblobstore.KeySharding
+	// SkipCI marks the commits with vcblobstore.SkipCIMarker, so that GitLab doesn't run pipelines for them.
+	// vcblobstore.WithSkipCI overrides it for a single write
+	SkipCI bool
+	// MaxCommitPayloadBytes is the largest commit request to send (20 MiB by default). Larger writes are split into
+	// sequential commits, each with the message of the write suffixed with the part number, keeping the entries of a
+	// key in the same commit. The write is then not atomic: if a part fails, the parts before it stay committed.
+	// ApplyChanges and RevertToState are never split, they fail with vcblobstore.ErrCommitTooLarge instead
+	MaxCommitPayloadBytes int
+	// OnCommitProgress, if set, is called after each commit of a write with the number of actions committed so far
+	// and the total number of actions of the write
+	OnCommitProgress CommitProgress
+	// GraphQL tells ListBlobKeysWithVersions to fetch the paths and their last commits in batches through the GraphQL
+	// API instead of requesting the version of every key on its own
+	GraphQL bool
+	// TreePageSize is the number of items to request per page when listing the repository tree (at most 100, the default)
+	TreePageSize int
+	// RateLimitThreshold is the number of remaining requests of the rate limit budget below which the client waits
+	// for the budget to be reset before sending further requests (5 by default)
+	RateLimitThreshold int
+	// MaxRateLimitWait is the longest the client waits for the rate limit budget to be reset (1 minute by default).
+	// Requests which would have to wait longer fail with a vcblobstore.RateLimitError instead
+	MaxRateLimitWait time.Duration
+	// MaxAttempts is the number of times a request is sent at most while it fails with 429 or a 5xx status (3 by default).
+	// Note that a commit may have been recorded despite a failure reported by a proxy, so retrying it may fail with
+	// a conflict or, for updates, repeat the change
+	MaxAttempts int
+	// RetryBaseDelay is the delay before the first retry (500ms by default), doubled for each further retry and jittered.
+	// A Retry-After header overrides it
+	RetryBaseDelay time.Duration
+	// Transport, if set, sends the requests to GitLab, e.g. through a corporate proxy. By default the requests go
+	// through the proxy configured by the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+	Transport http.RoundTripper
+	// CABundleFile, if set, is a PEM file of the certificates to trust besides the system roots, e.g. those of a
+	// TLS intercepting proxy. It is ignored if Transport is set
+	CABundleFile string
+	// OnRepositoryMoved, if set, is called with the old and the new path of the project when it turns out to have been
+	// renamed or transferred. The client switches over to the new path automatically
+	OnRepositoryMoved func(oldPath string, newPath string)
 }
@@ -0,0 +1,110 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"vcblobstore"
+)
+
+type compareResponse struct {
+	Diffs []commitDiffItem `json:"diffs"`
+}
+
+// DiffBlob returns the difference between the blob at the two commits as computed by the compare endpoint of GitLab
+func (g *Gitlab) DiffBlob(ctx context.Context, key string, fromVersion string, toVersion string) (vcblobstore.BlobDiff, error) {
+	if keyErr := vcblobstore.ValidateKey(key); keyErr != nil {
+		return vcblobstore.BlobDiff{}, keyErr
+	}
+	path := g.repoPath(key)
+
+	diff := vcblobstore.BlobDiff{Key: key}
+	sizes := []*int64{&diff.FromSize, &diff.ToSize}
+	for index, version := range []string{fromVersion, toVersion} {
+		size, sizeErr := g.fileSizeAtRef(ctx, path, version)
+		if sizeErr != nil {
+			return vcblobstore.BlobDiff{}, sizeErr
+		}
+		*sizes[index] = size
+	}
+	if diff.FromSize < 0 && diff.ToSize < 0 {
+		return vcblobstore.BlobDiff{}, fmt.Errorf("failed to diff %s between %s and %s: %w", key, fromVersion, toVersion, vcblobstore.ErrBlobNotFound)
+	}
+
+	diffItems, compareErr := g.compare(ctx, fromVersion, toVersion)
+	if compareErr != nil {
+		return vcblobstore.BlobDiff{}, compareErr
+	}
+	for _, diffItem := range diffItems {
+		if diffItem.NewPath != path && diffItem.OldPath != path {
+			continue
+		}
+		if diffItem.Collapsed || diffItem.TooLarge {
+			// The diff is empty though the content changed
+			diff.TooLarge = true
+			break
+		}
+		if strings.HasPrefix(diffItem.Diff, "Binary files ") {
+			diff.Binary = true
+			break
+		}
+		if len(diffItem.Diff) > 0 {
+			// GitLab leaves out the ---/+++ header
+			diff.Patch = patchHeader(path, diff.FromSize >= 0, diff.ToSize >= 0) + diffItem.Diff
+		}
+		break
+	}
+	return diff, nil
+}
+
+// compare returns the differences of the files between the two commits
+func (g *Gitlab) compare(ctx context.Context, from string, to string) ([]commitDiffItem, error) {
+	query := url.Values{}
+	query.Set("from", from)
+	query.Set("to", to)
+	query.Set("straight", "true")
+	statusCode, _, body, err := g.sendRequest(ctx, "GET", fmt.Sprintf("/projects/%s/repository/compare?%s", g.escapedProjectPath(), query.Encode()), nil)
+	if err != nil || statusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to compare %s and %s in GitLab repo: (%d) %s -- %w", from, to, statusCode, body, typedStatusError(statusCode, body, err))
+	}
+	comparison := compareResponse{}
+	if jsonErr := json.Unmarshal([]byte(body), &comparison); jsonErr != nil {
+		return nil, fmt.Errorf("failed to unmarshal GitLab comparison of %s and %s: %w", from, to, jsonErr)
+	}
+	return comparison.Diffs, nil
+}
+
+// patchHeader returns the ---/+++ header of the patch of the file at the path
+func patchHeader(path string, fromExists bool, toExists bool) string {
+	fromFile, toFile := "a/"+path, "b/"+path
+	if !fromExists {
+		fromFile = "/dev/null"
+	}
+	if !toExists {
+		toFile = "/dev/null"
+	}
+	return fmt.Sprintf("--- %s\n+++ %s\n", fromFile, toFile)
+}
+
+// fileSizeAtRef returns the size of the file at the path of the repository as of the ref, -1 if it doesn't exist
+func (g *Gitlab) fileSizeAtRef(ctx context.Context, path string, ref string) (int64, error) {
+	statusCode, header, body, err := g.sendRequest(ctx, "HEAD", fmt.Sprintf("/projects/%s/repository/files/%s?%s", g.escapedProjectPath(), url.PathEscape(path), url.Values{"ref": {ref}}.Encode()), nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get the size of %s at %s from GitLab repo: (%d) %s -- %w", path, ref, statusCode, body, err)
+	}
+	if statusCode == http.StatusNotFound && !projectMissing(body) {
+		return -1, nil
+	}
+	if statusCode != http.StatusOK {
+		return 0, fmt.Errorf("failed to get the size of %s at %s from GitLab repo: (%d) %s -- %w", path, ref, statusCode, body, typedStatusError(statusCode, body, err))
+	}
+	size, parseErr := strconv.ParseInt(header.Get("X-Gitlab-Size"), 10, 64)
+	if parseErr != nil {
+		return 0, fmt.Errorf("failed to parse %s header for %s: %w", "X-Gitlab-Size", path, parseErr)
+	}
+	return size, nil
+}
@@ -0,0 +1,72 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"vcblobstore"
+)
+
+// FindDuplicates reports the groups of blobs with identical content, based on the content_sha256 GitLab
+// computes for the files. The hashes are cached by git object id, so repeated scans only ask for the
+// blobs changed in the meantime
+func (g *Gitlab) FindDuplicates(ctx context.Context) ([]vcblobstore.DuplicateGroup, error) {
+	hashes := []vcblobstore.ContentHash{}
+	for treeItem, err := range g.iterateRepositoryTree(ctx, "") {
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the blobs of GitLab repo: %w", err)
+		}
+		if treeItem.Type != "blob" {
+			continue
+		}
+		key, ok := g.sharding.KeyOf(g.naming, treeItem.Path)
+		if !ok || g.naming.IsInternalKey(key) {
+			continue
+		}
+
+		hash, cached := g.contentHashes.Get(treeItem.Id)
+		if !cached {
+			var hashErr error
+			hash, hashErr = g.getContentHash(ctx, treeItem.Path)
+			if hashErr != nil {
+				return nil, hashErr
+			}
+			g.contentHashes.Put(treeItem.Id, hash)
+		}
+		hash.Key = key
+		hashes = append(hashes, hash)
+	}
+	return vcblobstore.GroupDuplicates(hashes), nil
+}
+
+// getContentHash returns the content hash of the file at the path as reported by the HEAD of the file
+func (g *Gitlab) getContentHash(ctx context.Context, path string) (vcblobstore.ContentHash, error) {
+	statusCode, header, body, err := g.sendRequest(
+		ctx,
+		"HEAD",
+		fmt.Sprintf(
+			"/projects/%s/repository/files/%s?%s",
+			g.escapedProjectPath(),
+			url.PathEscape(path),
+			url.PathEscape("ref="+g.readBranch(ctx)),
+		),
+		nil,
+	)
+	if err != nil {
+		return vcblobstore.ContentHash{}, fmt.Errorf("failed to get content hash of %s from GitLab repo: (%d) %s -- %w", path, statusCode, body, err)
+	}
+	if statusCode != 200 {
+		return vcblobstore.ContentHash{}, fmt.Errorf("failed to get content hash of %s from GitLab repo: (%d) %s -- %w", path, statusCode, body, typedBlobStatusError(statusCode, body, err))
+	}
+
+	size, parseErr := strconv.ParseInt(header.Get("X-Gitlab-Size"), 10, 64)
+	if parseErr != nil {
+		return vcblobstore.ContentHash{}, fmt.Errorf("failed to parse %s header for %s: %w", "X-Gitlab-Size", path, parseErr)
+	}
+	sha256 := header.Get("X-Gitlab-Content-Sha256")
+	if len(sha256) == 0 {
+		return vcblobstore.ContentHash{}, fmt.Errorf("no %s header for %s", "X-Gitlab-Content-Sha256", path)
+	}
+	return vcblobstore.ContentHash{SHA256: sha256, Size: size}, nil
+}
@@ -10,11 +10,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"iter"
+	"maps"
 	"net/http"
 	"net/url"
-	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -23,6 +26,11 @@ import (
 
 const gitlabRepoHasAlreadyBeenTaken = "has already been taken"
 
+// maxTreePageSize is the largest page size the tree endpoint accepts
+const maxTreePageSize = 100
+
+const fastResetAuthor = "vcblobstore"
+
 var transientGitlabRepoCreationErrMessages = []string{
 	"The project is still being deleted. Please try again later.",
 	gitlabRepoHasAlreadyBeenTaken,
@@ -55,6 +63,8 @@ type gitlabProject struct {
 	namespacePath string
 	path          string
 	namespaceId   int
+	// id stays the same when the project is renamed or transferred; 0 until known
+	id int
 }
 
 func (g gitlabProject) String() string {
@@ -62,12 +72,46 @@ func (g gitlabProject) String() string {
 }
 
 type Gitlab struct {
-	project    gitlabProject
-	mainBranch string
-	apikey     string
-	clientPool *blockingQueues.BlockingQueue
+	project      gitlabProject
+	mainBranch   string
+	credentials  credentials
+	textMode     vcblobstore.TextMode
+	external     *vcblobstore.ExternalStorage
+	lfs          *vcblobstore.LFS
+	access       *vcblobstore.AccessTracker
+	naming       vcblobstore.NamingStrategy
+	sharding     *vcblobstore.KeySharding
+	treePageSize int
+	throttle     *throttle
+	retry        retryPolicy
+	clientPool   *blockingQueues.BlockingQueue
+
+	stagingBranch       string
+	stagingMergeRequest bool
+	maxCommitPayload    int
+	onCommitProgress    CommitProgress
+	graphQL             bool
+	projectAttributes   ProjectAttributes
+	skipCI              bool
+
+	projectMutex      sync.RWMutex
+	onRepositoryMoved func(oldPath string, newPath string)
+	storeMetadata     vcblobstore.StoreMetadataCache
+	contentHashes     vcblobstore.ContentHashCache
 }
 
+var (
+	_ vcblobstore.Store              = (*Gitlab)(nil)
+	_ vcblobstore.Admin              = (*Gitlab)(nil)
+	_ vcblobstore.ChangeApplier      = (*Gitlab)(nil)
+	_ vcblobstore.RepositoryVerifier = (*Gitlab)(nil)
+	_ vcblobstore.BlobDiffer         = (*Gitlab)(nil)
+	_ vcblobstore.Tagger             = (*Gitlab)(nil)
+	_ vcblobstore.StateReverter      = (*Gitlab)(nil)
+	_ vcblobstore.BlobAnnotator      = (*Gitlab)(nil)
+	_ vcblobstore.BranchMerger       = (*Gitlab)(nil)
+)
+
 func (repo *Gitlab) String() string {
 	return fmt.Sprintf("GitLab repository at %s?ref=%s", repo.project, repo.mainBranch)
 }
@@ -83,23 +127,32 @@ const (
 )
 
 type commitActionOnByteSlice struct {
-	Action   commitActionType
-	FilePath string
-	Content  []byte
+	Action          commitActionType
+	FilePath        string
+	PreviousPath    string
+	Content         []byte
+	ExecuteFilemode *bool
+	// group ties consecutive actions together, e.g. those of the same key: they are committed in the same chunk
+	group string
 }
 
 type commitProperties struct {
 	Branch        string         `json:"branch"`
+	StartBranch   string         `json:"start_branch,omitempty"`
 	AuthorName    string         `json:"author_name"`
+	AuthorEmail   string         `json:"author_email,omitempty"`
 	CommitMessage string         `json:"commit_message"`
 	Actions       []commitAction `json:"actions"`
 }
 
 type commitAction struct {
-	Action   commitActionType `json:"action"`
-	FilePath string           `json:"file_path"`
-	Content  *string          `json:"content"`
-	Encoding *string          `json:"encoding"`
+	Action       commitActionType `json:"action"`
+	FilePath     string           `json:"file_path"`
+	PreviousPath string           `json:"previous_path,omitempty"`
+	Content      *string          `json:"content"`
+	Encoding     *string          `json:"encoding"`
+	// ExecuteFilemode is considered by the chmod action only
+	ExecuteFilemode *bool `json:"execute_filemode,omitempty"`
 }
 
 type repositoryTreeItem struct {
@@ -111,31 +164,64 @@ type repositoryTreeItem struct {
 }
 
 type projectProperties struct {
-	NamespaceId          int    `json:"namespace_id"`
-	Path                 string `json:"path"`
-	Description          string `json:"description"`
-	InitializeWithReadme string `json:"initialize_with_readme"`
+	NamespaceId          int        `json:"namespace_id"`
+	Path                 string     `json:"path"`
+	Description          string     `json:"description,omitempty"`
+	Visibility           Visibility `json:"visibility,omitempty"`
+	DefaultBranch        string     `json:"default_branch,omitempty"`
+	Topics               []string   `json:"topics,omitempty"`
+	InitializeWithReadme bool       `json:"initialize_with_readme"`
 }
 
 func NewGitlabRepositoryClient(ctx context.Context, config *Config) (*Gitlab, error) {
-	if len(config.GitlabAccessToken) == 0 {
-		return &Gitlab{}, fmt.Errorf("no API token for GitLab repository")
+	creds, credentialsErr := newCredentials(config)
+	if credentialsErr != nil {
+		return &Gitlab{}, credentialsErr
 	}
 
 	gitlab := Gitlab{
 		project: gitlabProject{
-			namespacePath: config.GitlabNamespacePath,
-			path:          config.GitlabNamespacePath,
+			namespacePath: strings.Trim(config.GitlabNamespacePath, "/"),
+			path:          config.GitlabProjectPath,
 		},
-		mainBranch: config.GitlabMainBranch,
-		apikey:     config.GitlabAccessToken,
+		mainBranch:          config.GitlabMainBranch,
+		credentials:         creds,
+		textMode:            config.TextMode,
+		external:            config.ExternalStorage,
+		lfs:                 config.LFS,
+		access:              config.AccessTracker,
+		naming:              vcblobstore.NamingOrDefault(config.Naming),
+		sharding:            config.Sharding,
+		treePageSize:        config.TreePageSize,
+		throttle:            newThrottle(config.RateLimitThreshold, config.MaxRateLimitWait),
+		onRepositoryMoved:   config.OnRepositoryMoved,
+		stagingBranch:       config.StagingBranch,
+		stagingMergeRequest: config.StagingMergeRequest,
+		maxCommitPayload:    config.MaxCommitPayloadBytes,
+		graphQL:             config.GraphQL,
+		projectAttributes:   config.ProjectAttributes,
+		retry:               newRetryPolicy(config.MaxAttempts, config.RetryBaseDelay),
+		skipCI:              config.SkipCI,
+		onCommitProgress:    config.OnCommitProgress,
+	}
+	if gitlab.maxCommitPayload <= 0 {
+		gitlab.maxCommitPayload = defaultMaxCommitPayloadBytes
+	}
+	if gitlab.treePageSize <= 0 || gitlab.treePageSize > maxTreePageSize {
+		gitlab.treePageSize = maxTreePageSize
+	}
+
+	transport, transportErr := newTransport(config)
+	if transportErr != nil {
+		return &gitlab, transportErr
 	}
 
 	var poolSize uint64 = 20
 	gitlab.clientPool, _ = blockingQueues.NewLinkedBlockingQueue(poolSize)
 	for i := 0; i < int(poolSize); i++ {
 		client := http.Client{
-			Timeout: 5 * time.Second,
+			Transport: transport,
+			Timeout:   5 * time.Second,
 		}
 		_, _ = gitlab.clientPool.Put(client)
 	}
@@ -146,13 +232,111 @@ func NewGitlabRepositoryClient(ctx context.Context, config *Config) (*Gitlab, er
 	}
 	gitlab.project.namespaceId = namespaceId
 
+	projectId, projectIdErr := gitlab.lookupProjectId(ctx)
+	if projectIdErr != nil {
+		return &gitlab, projectIdErr
+	}
+	gitlab.project.id = projectId
+
 	return &gitlab, nil
 }
 
+func (g *Gitlab) projectPath() string {
+	g.projectMutex.RLock()
+	defer g.projectMutex.RUnlock()
+	return g.project.String()
+}
+
+func (g *Gitlab) escapedProjectPath() string {
+	return url.PathEscape(g.projectPath())
+}
+
+type projectInfo struct {
+	Id                int    `json:"id"`
+	Path              string `json:"path"`
+	PathWithNamespace string `json:"path_with_namespace"`
+	Namespace         struct {
+		Id       int    `json:"id"`
+		FullPath string `json:"full_path"`
+	} `json:"namespace"`
+	// MarkedForDeletionOn is set while the (asynchronous) deletion of the project is pending
+	MarkedForDeletionOn string `json:"marked_for_deletion_on"`
+}
+
+func (g *Gitlab) getProjectInfo(ctx context.Context, projectRef string) (projectInfo, bool, error) {
+	info := projectInfo{}
+	statusCode, _, body, err := g.sendRequestOnce(ctx, "GET", fmt.Sprintf("/projects/%s", projectRef), nil)
+	if err != nil {
+		return info, false, fmt.Errorf("failed to send request to get GitLab project %s: %w", projectRef, err)
+	}
+	if statusCode == 404 {
+		return info, false, nil
+	}
+	if statusCode != 200 {
+		return info, false, fmt.Errorf("failed to get GitLab project %s: (%d) %s -- %w", projectRef, statusCode, body, typedStatusError(statusCode, body, err))
+	}
+	if jsonErr := json.Unmarshal([]byte(body), &info); jsonErr != nil {
+		return info, false, fmt.Errorf("failed to unmarshal GitLab project %s: %w", projectRef, jsonErr)
+	}
+	return info, true, nil
+}
+
+// lookupProjectId returns the ID of the project or 0 if the project doesn't exist (yet)
+func (g *Gitlab) lookupProjectId(ctx context.Context) (int, error) {
+	info, found, err := g.getProjectInfo(ctx, g.escapedProjectPath())
+	if err != nil || !found {
+		return 0, err
+	}
+	return info.Id, nil
+}
+
+// relocateProject looks the project up by its ID and, in case it has been renamed or transferred since,
+// switches over to its new path and notifies OnRepositoryMoved. It returns true if the path has changed
+func (g *Gitlab) relocateProject(ctx context.Context) (bool, error) {
+	g.projectMutex.RLock()
+	projectId := g.project.id
+	g.projectMutex.RUnlock()
+	if projectId == 0 {
+		return false, nil
+	}
+
+	info, found, err := g.getProjectInfo(ctx, strconv.Itoa(projectId))
+	if err != nil || !found {
+		return false, err
+	}
+
+	g.projectMutex.Lock()
+	oldPath := g.project.String()
+	if info.PathWithNamespace == oldPath {
+		g.projectMutex.Unlock()
+		return false, nil
+	}
+	g.project.namespacePath = info.Namespace.FullPath
+	g.project.path = info.Path
+	g.project.namespaceId = info.Namespace.Id
+	g.projectMutex.Unlock()
+
+	zerolog.Ctx(ctx).Warn().Str("oldPath", oldPath).Str("newPath", info.PathWithNamespace).Msg("GitLab repository has moved")
+	if g.onRepositoryMoved != nil {
+		g.onRepositoryMoved(oldPath, info.PathWithNamespace)
+	}
+	return true, nil
+}
+
 func (g *Gitlab) createCreateProjectBody() (io.Reader, error) {
+	g.projectMutex.RLock()
+	defer g.projectMutex.RUnlock()
 	projectProps := projectProperties{
-		NamespaceId: g.project.namespaceId,
-		Path:        g.project.path,
+		NamespaceId:          g.project.namespaceId,
+		Path:                 g.project.path,
+		Description:          g.projectAttributes.Description,
+		Visibility:           g.projectAttributes.Visibility,
+		DefaultBranch:        g.projectAttributes.DefaultBranch,
+		Topics:               g.projectAttributes.Topics,
+		InitializeWithReadme: g.projectAttributes.InitializeWithReadme,
+	}
+	if len(projectProps.DefaultBranch) == 0 {
+		projectProps.DefaultBranch = g.mainBranch
 	}
 	jsonInBytes, marshalErr := json.Marshal(&projectProps)
 	if marshalErr != nil {
@@ -175,12 +359,26 @@ func (g *Gitlab) CreateRepository(ctx context.Context) error {
 		}
 		statusCode, _, responseBody, err := g.sendRequest(ctx, "POST", "/projects", requestBody)
 		if err != nil || (statusCode != 201 && statusCode != 400) {
-			return fmt.Errorf("failed to create project: (%d) %s -- %w", statusCode, responseBody, err)
+			return fmt.Errorf("failed to create project: (%d) %s -- %w", statusCode, responseBody, typedStatusError(statusCode, responseBody, err))
+		}
+		if statusCode == 400 && strings.Contains(responseBody, gitlabRepoHasAlreadyBeenTaken) {
+			existingProject, exists, lookupErr := g.getProjectInfo(ctx, g.escapedProjectPath())
+			if lookupErr != nil {
+				return fmt.Errorf("failed to look up existing project: %w", lookupErr)
+			}
+			if exists && len(existingProject.MarkedForDeletionOn) == 0 {
+				// Another replica has created it meanwhile (or it has been there all along)
+				g.projectMutex.Lock()
+				g.project.id = existingProject.Id
+				g.projectMutex.Unlock()
+				logger.Info().Str("project", g.projectPath()).Msg("GitLab repository already exists")
+				return nil
+			}
 		}
 		if statusCode == 400 && isTransientGitlabRepoCreationErrMessage(responseBody) {
 			retryCount++
 			if retryCount >= maxRetryCount {
-				panic("Too many retries creating GitLab repo")
+				return fmt.Errorf("failed to create project %s: too many retries -- %s", g.projectPath(), responseBody)
 			}
 
 			requestBodyStr, readRequestBodyErr := io.ReadAll(requestBody)
@@ -189,17 +387,23 @@ func (g *Gitlab) CreateRepository(ctx context.Context) error {
 			}
 			logger.Debug().Err(requestBodyErr).
 				Str("request-body", string(requestBodyStr)).
-				Str("project", g.project.String()).
+				Str("project", g.projectPath()).
 				Int("sleep-ms-before-retry", sleepBeforeRetryMs).
 				Msg("Transient error while creating repository")
 			time.Sleep(time.Duration(sleepBeforeRetryMs) * time.Millisecond)
-			if strings.Contains(responseBody, gitlabRepoHasAlreadyBeenTaken) {
-				_ = g.DeleteRepository(ctx)
-				time.Sleep(time.Duration(sleepBeforeRetryMs) * time.Millisecond)
-			}
 			continue
 		}
-		logger.Info().Str("project", g.project.String()).Msg("GitLab repository created")
+		if statusCode == 400 {
+			return fmt.Errorf("failed to create project: (%d) %s -- %w", statusCode, responseBody, typedStatusError(statusCode, responseBody, err))
+		}
+		createdProject := projectInfo{}
+		if jsonErr := json.Unmarshal([]byte(responseBody), &createdProject); jsonErr != nil {
+			return fmt.Errorf("failed to unmarshal GitLab project creation response: %w", jsonErr)
+		}
+		g.projectMutex.Lock()
+		g.project.id = createdProject.Id
+		g.projectMutex.Unlock()
+		logger.Info().Str("project", g.projectPath()).Msg("GitLab repository created")
 		return nil
 	}
 }
@@ -212,44 +416,176 @@ func (g *Gitlab) ResetRepository(ctx context.Context) error {
 	return g.CreateRepository(ctx)
 }
 
+// FastReset empties the repository by deleting every entry in a single commit. Unlike ResetRepository, it keeps
+// the project (and its history), which is considerably faster and doesn't race with GitLab's asynchronous project deletion
+func (g *Gitlab) FastReset(ctx context.Context) error {
+	logger := zerolog.Ctx(ctx).With().Str("method", "FastReset").Logger()
+
+	actions := []commitActionOnByteSlice{}
+	for treeItem, err := range g.iterateRepositoryTree(ctx, "") {
+		if err != nil {
+			return fmt.Errorf("failed to reset GitLab repository: %w", err)
+		}
+		if treeItem.Type == "blob" {
+			actions = append(actions, commitActionOnByteSlice{Action: commitActionDelete, FilePath: treeItem.Path, group: treeItem.Path})
+		}
+	}
+	if len(actions) == 0 {
+		return nil
+	}
+
+	commitErr := g.commit(ctx, vcblobstore.Author{Name: fastResetAuthor}, "Resetting repository", actions)
+	g.storeMetadata.Invalidate()
+	if commitErr != nil {
+		return fmt.Errorf("failed to reset GitLab repository: %w", commitErr)
+	}
+
+	logger.Info().Int("deletedCount", len(actions)).Msg("GitLab repository reset")
+	return nil
+}
+
 func (g *Gitlab) DeleteRepository(ctx context.Context) error {
 	logger := zerolog.Ctx(ctx).With().Str("method", "DeleteRepository").Logger()
 
-	statusCode, _, body, err := g.sendRequest(ctx, "DELETE", fmt.Sprintf("/projects/%s", url.PathEscape(g.project.String())), nil)
+	statusCode, _, body, err := g.sendRequest(ctx, "DELETE", fmt.Sprintf("/projects/%s", g.escapedProjectPath()), nil)
 	if err != nil || (statusCode != 202 && statusCode != 404) {
-		return fmt.Errorf("failed to delete gitlab repository: (%d) %s -- %w", statusCode, body, err)
+		return fmt.Errorf("failed to delete gitlab repository: (%d) %s -- %w", statusCode, body, typedStatusError(statusCode, body, err))
 	}
-	logger.Info().Str("project", g.project.String()).Msg("GitLab repository deleted")
+	g.projectMutex.Lock()
+	g.project.id = 0
+	g.projectMutex.Unlock()
+	g.storeMetadata.Invalidate()
+	logger.Info().Str("project", g.projectPath()).Msg("GitLab repository deleted")
 	return nil
 }
 
-func (g *Gitlab) ListBlobKeys(ctx context.Context) ([]string, error) {
-	statusCode, _, body, err := g.sendRequest(ctx, "GET", fmt.Sprintf("/projects/%s/repository/tree?ref=%s&recursive=true", url.PathEscape(g.project.String()), g.mainBranch), nil)
+// getRepositoryTree returns the full recursive tree listing, fetching every page of it
+func (g *Gitlab) getRepositoryTree(ctx context.Context, path string) ([]repositoryTreeItem, error) {
+	tree := []repositoryTreeItem{}
+	for treeItem, err := range g.iterateRepositoryTree(ctx, path) {
+		if err != nil {
+			return nil, err
+		}
+		tree = append(tree, treeItem)
+	}
+	return tree, nil
+}
+
+// getRepositoryTreePage returns the specified page of the recursive tree listing of the ref ("" for the first one)
+// along with the number of the next page ("" for the last one)
+func (g *Gitlab) getRepositoryTreePage(ctx context.Context, ref string, path string, page string) ([]repositoryTreeItem, string, error) {
+	query := url.Values{}
+	query.Set("ref", ref)
+	query.Set("recursive", "true")
+	if len(path) > 0 {
+		query.Set("path", path)
+	}
+	if len(page) > 0 {
+		query.Set("page", page)
+	}
+	query.Set("per_page", strconv.Itoa(g.treePageSize))
+
+	statusCode, header, body, err := g.sendRequest(ctx, "GET", fmt.Sprintf("/projects/%s/repository/tree?%s", g.escapedProjectPath(), query.Encode()), nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request to get repository tree from GitLab repo: %w", err)
+		return nil, "", fmt.Errorf("failed to send request to get repository tree from GitLab repo: %w", err)
+	}
+	if statusCode == 404 && len(path) > 0 {
+		return []repositoryTreeItem{}, "", nil
 	}
 	if statusCode != 200 {
-		return nil, fmt.Errorf("failed to get repository tree from GitLab repo (%d) %s -- %w", statusCode, body, err)
+		return nil, "", fmt.Errorf("failed to get repository tree from GitLab repo (%d) %s -- %w", statusCode, body, typedStatusError(statusCode, body, err))
 	}
 
 	tree := []repositoryTreeItem{}
 	jsonErr := json.Unmarshal([]byte(body), &tree)
 	if jsonErr != nil {
-		return nil, fmt.Errorf("failed to unmarshal GitLab repository tree response: %w", jsonErr)
+		return nil, "", fmt.Errorf("failed to unmarshal GitLab repository tree response: %w", jsonErr)
+	}
+
+	return tree, header.Get("X-Next-Page"), nil
+}
+
+// iterateRepositoryTree walks the recursive tree listing of the main branch page by page, fetching the next page
+// only when needed
+func (g *Gitlab) iterateRepositoryTree(ctx context.Context, path string) iter.Seq2[repositoryTreeItem, error] {
+	return g.iterateRepositoryTreeAt(ctx, g.readBranch(ctx), path)
+}
+
+// iterateRepositoryTreeAt walks the recursive tree listing of the ref page by page
+func (g *Gitlab) iterateRepositoryTreeAt(ctx context.Context, ref string, path string) iter.Seq2[repositoryTreeItem, error] {
+	return func(yield func(repositoryTreeItem, error) bool) {
+		page := "1"
+		for len(page) > 0 {
+			tree, nextPage, err := g.getRepositoryTreePage(ctx, ref, path, page)
+			if err != nil {
+				yield(repositoryTreeItem{}, err)
+				return
+			}
+			for _, treeItem := range tree {
+				if !yield(treeItem, nil) {
+					return
+				}
+			}
+			page = nextPage
+		}
+	}
+}
+
+// IterateBlobKeys lazily walks the keys of the repository, fetching the pages of the tree listing on demand
+func (g *Gitlab) IterateBlobKeys(ctx context.Context, opts vcblobstore.ListOptions) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		for treeItem, err := range g.iterateRepositoryTree(ctx, g.sharding.ListingDirectory(g.naming, opts.Directory())) {
+			if err != nil {
+				yield("", err)
+				return
+			}
+			key, ok := g.sharding.KeyOf(g.naming, treeItem.Path)
+			if treeItem.Type == "blob" && ok && opts.Matches(g.naming, key) && opts.MatchesMode(vcblobstore.FileMode(treeItem.Mode)) {
+				if !yield(key, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (g *Gitlab) ListBlobKeys(ctx context.Context, opts vcblobstore.ListOptions) ([]string, error) {
+	tree, err := g.getRepositoryTree(ctx, g.sharding.ListingDirectory(g.naming, opts.Directory()))
+	if err != nil {
+		return nil, err
 	}
 
 	keyList := []string{}
 
 	for _, treeItem := range tree {
-		if treeItem.Type == "blob" {
-			keyList = append(keyList, treeItem.Path)
+		key, ok := g.sharding.KeyOf(g.naming, treeItem.Path)
+		if treeItem.Type == "blob" && ok && opts.Matches(g.naming, key) && opts.MatchesMode(vcblobstore.FileMode(treeItem.Mode)) {
+			keyList = append(keyList, key)
 		}
 	}
 
 	return keyList, nil
 }
 
-func (g *Gitlab) createCommitBody(authorName string, commitMessage string, actionsIn []commitActionOnByteSlice) (io.Reader, error) {
+// GetTree returns the hierarchy of directories and blobs under prefix down to depth levels (depth < 1 means no limit)
+func (g *Gitlab) GetTree(ctx context.Context, prefix string, depth int) (*vcblobstore.TreeNode, error) {
+	tree, err := g.getRepositoryTree(ctx, g.sharding.ListingDirectory(g.naming, strings.Trim(prefix, "/")))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []vcblobstore.TreeEntry{}
+	for _, treeItem := range tree {
+		key, ok := g.sharding.KeyOf(g.naming, treeItem.Path)
+		if treeItem.Type == "blob" && ok {
+			entries = append(entries, vcblobstore.TreeEntry{Path: key, BlobId: treeItem.Id})
+		}
+	}
+
+	return vcblobstore.BuildTree(g.naming, prefix, depth, entries), nil
+}
+
+func (g *Gitlab) createCommitBody(branch commitBranch, author vcblobstore.Author, commitMessage string, actionsIn []commitActionOnByteSlice) (io.Reader, error) {
 	commActs := make([]commitAction, len(actionsIn))
 
 	for index, actionIn := range actionsIn {
@@ -262,11 +598,15 @@ func (g *Gitlab) createCommitBody(authorName string, commitMessage string, actio
 		}
 		commActs[index].Action = actionIn.Action
 		commActs[index].FilePath = actionIn.FilePath
+		commActs[index].PreviousPath = actionIn.PreviousPath
+		commActs[index].ExecuteFilemode = actionIn.ExecuteFilemode
 	}
 
 	commitProps := commitProperties{
-		Branch:        g.mainBranch,
-		AuthorName:    authorName,
+		Branch:        branch.name,
+		StartBranch:   branch.startFrom,
+		AuthorName:    author.Name,
+		AuthorEmail:   author.Email,
 		CommitMessage: commitMessage,
 		Actions:       commActs,
 	}
@@ -280,21 +620,16 @@ func (g *Gitlab) createCommitBody(authorName string, commitMessage string, actio
 // GetAbsolutePathToBlob implements repositories_tests.gitTestRepo
 // GetStateID implements repositories_tests.gitTestRepo
 func (g *Gitlab) GetStateID(ctx context.Context) (string, error) {
-	statusCode, _, body, err := g.sendRequest(
-		ctx,
-		"GET",
-		fmt.Sprintf(
-			"/projects/%s/repository/commits?%s",
-			url.PathEscape(g.project.String()),
-			url.PathEscape(fmt.Sprintf("ref=%s", g.mainBranch)),
-		),
-		nil,
-	)
+	query := url.Values{}
+	query.Set("ref_name", g.readBranch(ctx))
+	query.Set("per_page", "1")
+
+	statusCode, _, body, err := g.sendRequest(ctx, "GET", fmt.Sprintf("/projects/%s/repository/commits?%s", g.escapedProjectPath(), query.Encode()), nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to send request to get commit list from GitLab repo: %w", err)
 	}
 	if statusCode != 200 {
-		return "", fmt.Errorf("failed to get commit list from GitLab repo (%d) %s -- %w", statusCode, body, err)
+		return "", fmt.Errorf("failed to get commit list from GitLab repo (%d) %s -- %w", statusCode, body, typedStatusError(statusCode, body, err))
 	}
 
 	metadataListResponse := []git.CommitQueryResponseItem{}
@@ -304,12 +639,17 @@ func (g *Gitlab) GetStateID(ctx context.Context) (string, error) {
 	}
 
 	if len(metadataListResponse) < 1 {
-		return "", fmt.Errorf("no commit yet in GitLab repository %s", g.project.String())
+		return "", fmt.Errorf("no commit yet in GitLab repository %s", g.projectPath())
 	}
 
 	return metadataListResponse[0].Id, nil
 }
 
+// SelfTest exercises writing, reading and deleting a probe blob and reports the outcome and the latency of each step
+func (g *Gitlab) SelfTest(ctx context.Context, modifiedBy string) vcblobstore.SelfTestReport {
+	return vcblobstore.RunSelfTest(ctx, g, g.naming, modifiedBy)
+}
+
 // CheckStatus always returns true for the GitLab repo, since the GitLab service handles consistency (and returns error if it cannot)
 func (g *Gitlab) CheckStatus() (bool, error) {
 	return true, nil
@@ -325,33 +665,90 @@ func (g *Gitlab) GetVersionFor(ctx context.Context, key string) (string, error)
 		"HEAD",
 		fmt.Sprintf(
 			"/projects/%s/repository/files/%s?%s",
-			url.PathEscape(g.project.String()),
-			url.PathEscape(key),
-			url.PathEscape("ref="+g.mainBranch),
+			g.escapedProjectPath(),
+			url.PathEscape(g.repoPath(key)),
+			url.PathEscape("ref="+g.readBranch(ctx)),
 		),
 		nil,
 	)
 	if err != nil {
 		return "", fmt.Errorf("failed to get Blob commit ID from GitLab repo %s: (%d) %s -- %w", key, statusCode, body, err)
 	}
-	if statusCode == 404 {
+	if statusCode == 404 && !projectMissing(body) {
 		return "", nil
 	}
 	if statusCode != 200 {
-		return "", fmt.Errorf("failed to get Blob commit ID from GitLab repo %s: (%d) %s -- %w", key, statusCode, body, err)
+		return "", fmt.Errorf("failed to get Blob commit ID from GitLab repo %s: (%d) %s -- %w", key, statusCode, body, typedStatusError(statusCode, body, err))
 	}
 	return header.Get(commitIdHeaderKey), nil
 }
 
+// HeadBlob returns the size and the current version of the blob without downloading its content.
+// The returned BlobHead has Exists set to false in case the blob doesn't exist in the repository
+func (g *Gitlab) HeadBlob(ctx context.Context, key string) (vcblobstore.BlobHead, error) {
+	blobHead := vcblobstore.BlobHead{Key: key}
+
+	statusCode, header, body, err := g.sendRequest(
+		ctx,
+		"HEAD",
+		fmt.Sprintf(
+			"/projects/%s/repository/files/%s?%s",
+			g.escapedProjectPath(),
+			url.PathEscape(g.repoPath(key)),
+			url.PathEscape("ref="+g.readBranch(ctx)),
+		),
+		nil,
+	)
+	if err != nil {
+		return blobHead, fmt.Errorf("failed to get Blob head from GitLab repo %s: (%d) %s -- %w", key, statusCode, body, err)
+	}
+	if statusCode == 404 && !projectMissing(body) {
+		return blobHead, nil
+	}
+	if statusCode != 200 {
+		return blobHead, fmt.Errorf("failed to get Blob head from GitLab repo %s: (%d) %s -- %w", key, statusCode, body, typedStatusError(statusCode, body, err))
+	}
+
+	size, parseErr := strconv.ParseInt(header.Get("X-Gitlab-Size"), 10, 64)
+	if parseErr != nil {
+		return blobHead, fmt.Errorf("failed to parse %s header for %s: %w", "X-Gitlab-Size", key, parseErr)
+	}
+
+	blobHead.Exists = true
+	blobHead.Size = size
+	blobHead.Version = header.Get("X-Gitlab-Last-Commit-Id")
+	blobHead.Mode = vcblobstore.FileModeOf(header.Get("X-Gitlab-Execute-Filemode") == "true")
+	return blobHead, nil
+}
+
+// GetVersionMetadata returns the metadata of the commit with the changes of the blobs listed by its diff
 func (g *Gitlab) GetVersionMetadata(ctx context.Context, commitId string) (git.CommitMetadata, error) {
+	commitMetadata, metadataErr := g.getCommitMetadata(ctx, commitId)
+	if metadataErr != nil {
+		return commitMetadata, metadataErr
+	}
+	diff, diffErr := g.getCommitDiff(ctx, commitId)
+	if diffErr != nil {
+		return git.CommitMetadata{}, diffErr
+	}
+	version := vcblobstore.RepositoryVersion{}
+	for _, diffItem := range diff {
+		g.addDiffItemChanges(&version, diffItem)
+	}
+	commitMetadata.Changes = version.ChangedKeys()
+	return commitMetadata, nil
+}
+
+// getCommitMetadata returns the metadata of the commit without its changes
+func (g *Gitlab) getCommitMetadata(ctx context.Context, commitId string) (git.CommitMetadata, error) {
 	commitMetadata := git.CommitMetadata{}
 
-	statusCode, _, body, err := g.sendRequest(ctx, "GET", fmt.Sprintf("/projects/%s/repository/commits/%s", url.PathEscape(g.project.String()), commitId), nil)
+	statusCode, _, body, err := g.sendRequest(ctx, "GET", fmt.Sprintf("/projects/%s/repository/commits/%s", g.escapedProjectPath(), commitId), nil)
 	if err != nil {
 		return commitMetadata, fmt.Errorf("failed to send request to get commit meta-data for %s from GitLab repo: %w", commitId, err)
 	}
 	if statusCode != 200 {
-		return commitMetadata, fmt.Errorf("failed to get commit meta-data for %s from GitLab repo (%d) %s -- %w", commitId, statusCode, body, err)
+		return commitMetadata, fmt.Errorf("failed to get commit meta-data for %s from GitLab repo (%d) %s -- %w", commitId, statusCode, body, typedStatusError(statusCode, body, err))
 	}
 
 	metadataResponse := git.CommitQueryResponseItem{}
@@ -368,180 +765,1257 @@ func (g *Gitlab) GetVersionMetadata(ctx context.Context, commitId string) (git.C
 	return commitMetadata, nil
 }
 
-func (g *Gitlab) AddBlob(ctx context.Context, blob vcblobstore.BlobInfo) error {
-	logger := zerolog.Ctx(ctx).With().Str("unit", "gitlab-client").Str("method", "AddBlob").Int("Content length", len(blob.Content)).Logger()
-	modifiedBy := blob.ModifiedBy
+// repoPath returns the path of the entry with the key relative to the root of the repository
+func (g *Gitlab) repoPath(key string) string {
+	return g.sharding.PathOf(g.naming, key)
+}
 
-	logger.Debug().Str("key", blob.Key).Msg("about to commit...")
-	commitErr := g.commit(ctx, modifiedBy, fmt.Sprintf("Adding Blob: %s", blob.Key), []commitActionOnByteSlice{
-		{
-			Action:   commitActionCreate,
-			FilePath: blob.Key,
-			Content:  blob.Content,
-		},
-	})
-	if commitErr != nil {
-		return fmt.Errorf("failed to add Blob to GitLab repo %s: %w", blob.Key, commitErr)
+// createOrUpdateAction returns the commit action which writes the blob: update, if it already exists, create otherwise
+func (g *Gitlab) createOrUpdateAction(ctx context.Context, key string) (commitActionType, error) {
+	baseCtx, baseErr := g.commitBaseContext(ctx)
+	if baseErr != nil {
+		return "", baseErr
 	}
-	logger.Info().Msg("Blob added to GitLab repository")
-	return nil
+	blobHead, headErr := g.HeadBlob(baseCtx, key)
+	if headErr != nil {
+		return "", headErr
+	}
+	if blobHead.Exists {
+		return commitActionUpdate, nil
+	}
+	return commitActionCreate, nil
 }
 
-func (g *Gitlab) DeleteBlob(ctx context.Context, key string, modifiedBy string) error {
-	logger := zerolog.Ctx(ctx).With().Str("filePath", key).Str("method", "DeleteBlob").Logger()
+// modeActions returns the chmod action setting the file mode of the blob, if it is specified
+func (g *Gitlab) modeActions(key string, mode vcblobstore.FileMode) []commitActionOnByteSlice {
+	if len(mode) == 0 {
+		return nil
+	}
+	executable := mode == vcblobstore.FileModeExecutable
+	return []commitActionOnByteSlice{{
+		Action:          commitActionChmod,
+		FilePath:        g.repoPath(key),
+		ExecuteFilemode: &executable,
+	}}
+}
 
-	commitErr := g.commit(ctx, modifiedBy, fmt.Sprintf("Deleting blob: %s", key), []commitActionOnByteSlice{
-		{
-			Action:   commitActionDelete,
-			FilePath: key,
-		},
-	})
-	if commitErr != nil {
-		return fmt.Errorf("failed to delete blob from GitLab repo %s: %w", key, commitErr)
+// metadataActions returns the commit actions which store the metadata attributes of the blob in its sidecar file
+// or remove the sidecar file if there are none
+func (g *Gitlab) metadataActions(ctx context.Context, key string, metadata map[string]string) ([]commitActionOnByteSlice, error) {
+	sidecarKey := g.naming.MetadataSidecarKey(key)
+
+	baseCtx, baseErr := g.commitBaseContext(ctx)
+	if baseErr != nil {
+		return nil, baseErr
+	}
+	sidecarHead, headErr := g.HeadBlob(baseCtx, sidecarKey)
+	if headErr != nil {
+		return nil, headErr
 	}
 
-	logger.Info().Msg("Blob deleted from GitLab repository")
-	return nil
-}
+	if len(metadata) == 0 {
+		if !sidecarHead.Exists {
+			return nil, nil
+		}
+		return []commitActionOnByteSlice{{Action: commitActionDelete, FilePath: sidecarKey}}, nil
+	}
 
-func (g *Gitlab) GetBlob(ctx context.Context, key string) ([]byte, error) {
-	statusCode, _, body, err := g.sendRequest(
-		ctx,
-		"GET",
-		fmt.Sprintf(
-			"/projects/%s/repository/files/%s?%s",
-			url.PathEscape(g.project.String()),
-			url.PathEscape(key),
-			fmt.Sprintf("ref=%s", g.mainBranch),
-		),
-		nil,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request to get blobfile from GitLab repo %s: %w", key, err)
+	content, encodeErr := vcblobstore.EncodeMetadata(metadata)
+	if encodeErr != nil {
+		return nil, encodeErr
 	}
-	if statusCode != 200 {
-		return nil, fmt.Errorf("failed to get Blob from GitLab repo %s: (%d) %s -- %w", key, statusCode, body, err)
+	action := commitActionCreate
+	if sidecarHead.Exists {
+		action = commitActionUpdate
 	}
+	return []commitActionOnByteSlice{{Action: action, FilePath: sidecarKey, Content: content}}, nil
+}
 
-	respFileItem := responseFileItem{}
-	jsonErr := json.Unmarshal([]byte(body), &respFileItem)
-	if jsonErr != nil {
-		return nil, fmt.Errorf("failed to unmarshal GitLab namespace list: %w", jsonErr)
+// attributeIndexActions returns the commit action updating the attribute index with the new metadata of the blobs (none if the index doesn't change)
+func (g *Gitlab) attributeIndexActions(ctx context.Context, metadataByKey map[string]map[string]string) ([]commitActionOnByteSlice, error) {
+	baseCtx, baseErr := g.commitBaseContext(ctx)
+	if baseErr != nil {
+		return nil, baseErr
+	}
+	index, found, readErr := g.readAttributeIndex(baseCtx)
+	if readErr != nil {
+		return nil, readErr
 	}
 
-	if respFileItem.Encoding != "base64" {
-		return nil, fmt.Errorf("unexpected encoding for Blob from GitLab repo %s: %s", key, respFileItem.Encoding)
+	changed := false
+	for key, metadata := range metadataByKey {
+		if index.Update(key, metadata) {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil, nil
 	}
 
-	content, decodeErr := base64.StdEncoding.DecodeString(respFileItem.Content)
-	if decodeErr != nil {
-		return nil, fmt.Errorf("failed to decode Blob content (%s) for %s: %w", string(body), key, decodeErr)
+	content, encodeErr := index.Encode()
+	if encodeErr != nil {
+		return nil, encodeErr
+	}
+	action := commitActionCreate
+	if found {
+		action = commitActionUpdate
 	}
+	return []commitActionOnByteSlice{{Action: action, FilePath: g.naming.AttributeIndexKey(), Content: content}}, nil
+}
 
-	return content, nil
+func (g *Gitlab) readAttributeIndex(ctx context.Context) (vcblobstore.AttributeIndex, bool, error) {
+	content, found, err := g.getBlobAtRef(ctx, g.naming.AttributeIndexKey(), g.readBranch(ctx))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read attribute index: %w", err)
+	}
+	if !found {
+		return vcblobstore.AttributeIndex{}, false, nil
+	}
+	index, decodeErr := vcblobstore.DecodeAttributeIndex(content)
+	return index, true, decodeErr
 }
 
-func (g *Gitlab) commit(ctx context.Context, authorName string, commitMessage string, actions []commitActionOnByteSlice) error {
-	if os.Getenv(git.SimulateGitCommitFailureEnvvarName) == "true" {
-		return fmt.Errorf("simulate git commit failure")
+// QueryByAttributes returns the keys of the blobs whose metadata attributes match the selector
+func (g *Gitlab) QueryByAttributes(ctx context.Context, selector vcblobstore.AttributeSelector) ([]string, error) {
+	index, _, readErr := g.readAttributeIndex(ctx)
+	if readErr != nil {
+		return nil, readErr
 	}
+	return index.Query(selector), nil
+}
 
-	commitBody, createCommitBodyErr := g.createCommitBody(authorName, commitMessage, actions)
-	if createCommitBodyErr != nil {
-		return fmt.Errorf("failed to create commit request body: %w", createCommitBodyErr)
+// GetStoreMetadata returns the document in which the store records its own configuration
+func (g *Gitlab) GetStoreMetadata(ctx context.Context) (vcblobstore.StoreMetadata, error) {
+	if metadata, cached := g.storeMetadata.Get(); cached {
+		return metadata, nil
 	}
 
-	statusCode, _, body, err := g.sendRequest(
-		ctx,
-		"POST",
-		fmt.Sprintf("/projects/%s/repository/commits?%s", url.PathEscape(g.project.String()), url.PathEscape(fmt.Sprintf("ref=%s", g.mainBranch))),
-		commitBody,
-	)
-	if err != nil || statusCode != 201 {
-		return fmt.Errorf("failed to commit to GitLab repo: (%d) %s -- %w", statusCode, body, err)
+	metadata := vcblobstore.DefaultStoreMetadata()
+	content, found, err := g.getBlobAtRef(ctx, g.naming.StoreMetadataKey(), g.readBranch(ctx))
+	if err != nil {
+		return vcblobstore.StoreMetadata{}, fmt.Errorf("failed to get store metadata from GitLab repo: %w", err)
 	}
-	return nil
+	if found {
+		var decodeErr error
+		if metadata, decodeErr = vcblobstore.DecodeStoreMetadata(content); decodeErr != nil {
+			return vcblobstore.StoreMetadata{}, decodeErr
+		}
+	}
+
+	g.storeMetadata.Set(metadata)
+	return metadata, nil
 }
 
-func (g *Gitlab) sendRequest(ctx context.Context, method string, apiCallPath string, body io.Reader) (int, http.Header, string, error) {
-	poolItem, _ := g.clientPool.Get()
-	defer func() {
-		_, _ = g.clientPool.Put(poolItem)
-	}()
+// SetStoreMetadata commits the document in which the store records its own configuration
+func (g *Gitlab) SetStoreMetadata(ctx context.Context, metadata vcblobstore.StoreMetadata, modifiedBy string) error {
+	logger := zerolog.Ctx(ctx).With().Str("method", "SetStoreMetadata").Logger()
 
-	client, ok := poolItem.(http.Client)
-	if !ok {
-		return 0, nil, "", errors.New("type asssertion error")
+	content, encodeErr := vcblobstore.EncodeStoreMetadata(metadata)
+	if encodeErr != nil {
+		return encodeErr
+	}
+	action, actionErr := g.createOrUpdateAction(ctx, g.naming.StoreMetadataKey())
+	if actionErr != nil {
+		return fmt.Errorf("failed to set store metadata in GitLab repo: %w", actionErr)
 	}
 
-	logger := zerolog.Ctx(ctx).With().Str("method", "sendRequest").Str("request-method", method).Str("apiCallPath", apiCallPath).Logger()
-	urlString := fmt.Sprintf("https://gitlab.com/api/v4%s", apiCallPath)
+	commitErr := g.commit(ctx, vcblobstore.Author{Name: modifiedBy}, "Setting store metadata", []commitActionOnByteSlice{
+		{
+			Action:   action,
+			FilePath: g.naming.StoreMetadataKey(),
+			Content:  content,
+		},
+	})
+	if commitErr != nil {
+		g.storeMetadata.Invalidate()
+		return fmt.Errorf("failed to set store metadata in GitLab repo: %w", commitErr)
+	}
 
-	logger.Debug().Msg("send request")
-	request, requestCreationError := http.NewRequest(
-		method,
-		urlString,
-		body,
-	)
+	g.storeMetadata.Set(metadata)
+	logger.Info().Msg("Store metadata set in GitLab repository")
+	return nil
+}
 
-	if requestCreationError != nil {
-		return 0, nil, "", fmt.Errorf("failed to create request: %w", requestCreationError)
+func (g *Gitlab) readMetadata(ctx context.Context, key string) (map[string]string, error) {
+	content, found, err := g.getBlobAtRef(ctx, g.naming.MetadataSidecarKey(key), g.readBranch(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata of %s: %w", key, err)
 	}
+	if !found {
+		return map[string]string{}, nil
+	}
+	return vcblobstore.DecodeMetadata(content)
+}
 
-	request.Header.Set("Content-Type", "application/json")
-	request.Header.Set("PRIVATE-TOKEN", g.apikey)
-
-	resp, requestExecutionError := client.Do(request)
-	if requestExecutionError != nil {
-		return 0, nil, "", fmt.Errorf("failed to execute request: %w", requestExecutionError)
+// GetBlobInfo returns the content of the blob along with its metadata attributes
+func (g *Gitlab) GetBlobInfo(ctx context.Context, key string) (vcblobstore.BlobInfo, error) {
+	canonicalKey, resolveErr := g.ResolveAlias(ctx, key)
+	if resolveErr != nil {
+		return vcblobstore.BlobInfo{}, resolveErr
 	}
-	defer resp.Body.Close()
 
-	respBody, errBody := io.ReadAll(resp.Body)
-	if errBody != nil {
-		return resp.StatusCode, nil, "", fmt.Errorf("failed to read body: %w", errBody)
+	content, getErr := g.GetBlob(ctx, canonicalKey)
+	if getErr != nil {
+		return vcblobstore.BlobInfo{}, getErr
 	}
 
-	rateLimitRemainingHeader := resp.Header.Get("RateLimit-Remaining")
-	if len(rateLimitRemainingHeader) > 0 {
-		rateLimitRemainning, rateLimitParseErr := strconv.ParseInt(resp.Header.Get("RateLimit-Remaining"), 10, 0)
-		if rateLimitParseErr != nil {
-			debugLogger := logger.Debug()
-			for key, value := range resp.Header {
-				debugLogger.Any(key, value)
-			}
-			debugLogger.Send()
-			return resp.StatusCode, nil, "", fmt.Errorf("failed to parse %s header: %w", "RateLimit-Remaining", rateLimitParseErr)
-		}
-		if rateLimitRemainning < 5 {
-			logger.Warn().Int64("rateLimitRemainning", rateLimitRemainning).Msg("Rate limit remaining to low")
-		}
+	metadata, metadataErr := g.readMetadata(ctx, canonicalKey)
+	if metadataErr != nil {
+		return vcblobstore.BlobInfo{}, metadataErr
 	}
-	return resp.StatusCode, resp.Header, string(respBody), nil
-}
 
-type namespaceInfo struct {
-	Id   int    `json:"id"`
-	Path string `json:"path"`
+	return vcblobstore.BlobInfo{
+		Key:      key,
+		Content:  content,
+		Metadata: metadata,
+		SHA256:   vcblobstore.ContentSHA256(content),
+	}, nil
 }
 
-func getNamespaceID(ctx context.Context, gitlabCli *Gitlab) (int, error) {
-	statusCode, _, body, err := gitlabCli.sendRequest(ctx, "GET", "/namespaces?owned_only=true", nil)
-	if err != nil || statusCode != 200 {
-		return 0, fmt.Errorf("failed to retreive GitLab namespaces (%d) %s -- %w", statusCode, body, err)
+// GetBlobWithChecksum returns the content of the blob along with its SHA-256 digest
+func (g *Gitlab) GetBlobWithChecksum(ctx context.Context, key string) ([]byte, string, error) {
+	content, err := g.GetBlob(ctx, key)
+	if err != nil {
+		return nil, "", err
 	}
+	return content, vcblobstore.ContentSHA256(content), nil
+}
 
-	namespaceInfoList := []namespaceInfo{}
-	jsonErr := json.Unmarshal([]byte(body), &namespaceInfoList)
-	if jsonErr != nil {
-		return 0, fmt.Errorf("failed to unmarshal GitLab namespace list: %w", jsonErr)
+// UpdateBlobMetadata replaces the metadata attributes of the blob without rewriting its content
+func (g *Gitlab) UpdateBlobMetadata(ctx context.Context, key string, metadata map[string]string, modifiedBy string) error {
+	logger := zerolog.Ctx(ctx).With().Str("key", key).Str("method", "UpdateBlobMetadata").Logger()
+
+	if keyErr := vcblobstore.ValidateBlobKey(g.naming, key); keyErr != nil {
+		return keyErr
 	}
 
-	for _, info := range namespaceInfoList {
-		if info.Path == gitlabCli.project.namespacePath {
-			return info.Id, nil
-		}
+	baseCtx, baseErr := g.commitBaseContext(ctx)
+	if baseErr != nil {
+		return fmt.Errorf("failed to update metadata of %s: %w", key, baseErr)
+	}
+	blobHead, headErr := g.HeadBlob(baseCtx, key)
+	if headErr != nil {
+		return fmt.Errorf("failed to update metadata of %s: %w", key, headErr)
+	}
+	if !blobHead.Exists {
+		return fmt.Errorf("failed to update metadata of %s: %w", key, vcblobstore.ErrBlobNotFound)
 	}
 
-	return 0, fmt.Errorf("no namespace found with path %s", gitlabCli.project.namespacePath)
+	actions, actionsErr := g.metadataActions(ctx, key, metadata)
+	if actionsErr != nil {
+		return fmt.Errorf("failed to update metadata of %s: %w", key, actionsErr)
+	}
+	indexActions, indexErr := g.attributeIndexActions(ctx, map[string]map[string]string{key: metadata})
+	if indexErr != nil {
+		return fmt.Errorf("failed to update metadata of %s: %w", key, indexErr)
+	}
+	actions = append(actions, indexActions...)
+	if len(actions) == 0 {
+		return nil
+	}
+
+	commitErr := g.commit(ctx, vcblobstore.Author{Name: modifiedBy}, fmt.Sprintf("Updating blob metadata: %s", key), actions)
+	if commitErr != nil {
+		return fmt.Errorf("failed to update metadata of %s in GitLab repo: %w", key, commitErr)
+	}
+
+	g.access.RecordWrite(key)
+	logger.Info().Msg("Blob metadata updated in GitLab repository")
+	return nil
+}
+
+func (g *Gitlab) AddBlob(ctx context.Context, blob vcblobstore.BlobInfo) error {
+	if keyErr := vcblobstore.ValidateBlobKey(g.naming, blob.Key); keyErr != nil {
+		return keyErr
+	}
+	return g.addBlob(ctx, blob)
+}
+
+// addBlob writes the blob or the internal entry
+func (g *Gitlab) addBlob(ctx context.Context, blob vcblobstore.BlobInfo) error {
+	logger := zerolog.Ctx(ctx).With().Str("unit", "gitlab-client").Str("method", "AddBlob").Int("Content length", len(blob.Content)).Logger()
+
+	if checksumErr := blob.VerifyChecksum(); checksumErr != nil {
+		return checksumErr
+	}
+	if modeErr := vcblobstore.ValidateFileMode(blob.Mode); modeErr != nil {
+		return modeErr
+	}
+
+	content, textModeErr := g.textMode.Apply(blob.Key, blob.Content)
+	if textModeErr != nil {
+		return textModeErr
+	}
+	content, offloadErr := g.external.Offload(ctx, content)
+	if offloadErr != nil {
+		return fmt.Errorf("failed to add Blob to GitLab repo %s: %w", blob.Key, offloadErr)
+	}
+	content, lfsErr := g.offloadLFS(ctx, blob.Key, content)
+	if lfsErr != nil {
+		return fmt.Errorf("failed to add Blob to GitLab repo %s: %w", blob.Key, lfsErr)
+	}
+
+	action, actionErr := g.createOrUpdateAction(ctx, blob.Key)
+	if actionErr != nil {
+		return fmt.Errorf("failed to add Blob to GitLab repo %s: %w", blob.Key, actionErr)
+	}
+
+	actions := []commitActionOnByteSlice{
+		{
+			Action:   action,
+			FilePath: g.repoPath(blob.Key),
+			Content:  content,
+		},
+	}
+	actions = append(actions, g.modeActions(blob.Key, blob.Mode)...)
+	if blob.Metadata != nil {
+		metadataActions, metadataErr := g.metadataActions(ctx, blob.Key, blob.Metadata)
+		if metadataErr != nil {
+			return fmt.Errorf("failed to add Blob to GitLab repo %s: %w", blob.Key, metadataErr)
+		}
+		actions = append(actions, metadataActions...)
+		indexActions, indexErr := g.attributeIndexActions(ctx, map[string]map[string]string{blob.Key: blob.Metadata})
+		if indexErr != nil {
+			return fmt.Errorf("failed to add Blob to GitLab repo %s: %w", blob.Key, indexErr)
+		}
+		actions = append(actions, indexActions...)
+	}
+
+	logger.Debug().Str("key", blob.Key).Msg("about to commit...")
+	commitErr := g.commit(ctx, blob.Author(), fmt.Sprintf("Adding Blob: %s", blob.Key), actions)
+	if commitErr != nil {
+		return fmt.Errorf("failed to add Blob to GitLab repo %s: %w", blob.Key, commitErr)
+	}
+	g.access.RecordWrite(blob.Key)
+	logger.Info().Msg("Blob added to GitLab repository")
+	return nil
+}
+
+func (g *Gitlab) DeleteBlob(ctx context.Context, key string, modifiedBy string) error {
+	logger := zerolog.Ctx(ctx).With().Str("filePath", key).Str("method", "DeleteBlob").Logger()
+
+	if keyErr := vcblobstore.ValidateBlobKey(g.naming, key); keyErr != nil {
+		return keyErr
+	}
+
+	actions := []commitActionOnByteSlice{
+		{
+			Action:   commitActionDelete,
+			FilePath: g.repoPath(key),
+		},
+	}
+	metadataActions, metadataErr := g.metadataActions(ctx, key, nil)
+	if metadataErr != nil {
+		return fmt.Errorf("failed to delete blob from GitLab repo %s: %w", key, metadataErr)
+	}
+	actions = append(actions, metadataActions...)
+	indexActions, indexErr := g.attributeIndexActions(ctx, map[string]map[string]string{key: nil})
+	if indexErr != nil {
+		return fmt.Errorf("failed to delete blob from GitLab repo %s: %w", key, indexErr)
+	}
+	actions = append(actions, indexActions...)
+
+	commitErr := g.commit(ctx, vcblobstore.Author{Name: modifiedBy}, fmt.Sprintf("Deleting blob: %s", key), actions)
+	if commitErr != nil {
+		return fmt.Errorf("failed to delete blob from GitLab repo %s: %w", key, commitErr)
+	}
+
+	g.access.RecordWrite(key)
+	logger.Info().Msg("Blob deleted from GitLab repository")
+	return nil
+}
+
+// DeleteBlobs removes all the specified blobs in a single commit
+func (g *Gitlab) DeleteBlobs(ctx context.Context, keys []string, modifiedBy string) error {
+	for _, key := range keys {
+		if keyErr := vcblobstore.ValidateBlobKey(g.naming, key); keyErr != nil {
+			return keyErr
+		}
+	}
+	logger := zerolog.Ctx(ctx).With().Int("keyCount", len(keys)).Str("method", "DeleteBlobs").Logger()
+
+	actions := []commitActionOnByteSlice{}
+	removedMetadata := map[string]map[string]string{}
+	for _, key := range keys {
+		metadataActions, metadataErr := g.metadataActions(ctx, key, nil)
+		if metadataErr != nil {
+			return fmt.Errorf("failed to delete blobs from GitLab repo: %w", metadataErr)
+		}
+		keyActions := append([]commitActionOnByteSlice{{Action: commitActionDelete, FilePath: g.repoPath(key)}}, metadataActions...)
+		actions = append(actions, groupActions(key, keyActions)...)
+		removedMetadata[key] = nil
+	}
+	indexActions, indexErr := g.attributeIndexActions(ctx, removedMetadata)
+	if indexErr != nil {
+		return fmt.Errorf("failed to delete blobs from GitLab repo: %w", indexErr)
+	}
+	actions = append(actions, indexActions...)
+
+	commitErr := g.commit(ctx, vcblobstore.Author{Name: modifiedBy}, fmt.Sprintf("Deleting %d blobs: %s", len(keys), strings.Join(keys, ", ")), actions)
+	if commitErr != nil {
+		return fmt.Errorf("failed to delete %d blobs from GitLab repo: %w", len(keys), commitErr)
+	}
+
+	g.access.RecordWrite(keys...)
+	logger.Info().Msg("Blobs deleted from GitLab repository")
+	return nil
+}
+
+// CopyBlob fetches the content of the source blob and writes the destination blob with it in a single commit
+func (g *Gitlab) CopyBlob(ctx context.Context, sourceKey string, destinationKey string, modifiedBy string) error {
+	logger := zerolog.Ctx(ctx).With().Str("sourceKey", sourceKey).Str("destinationKey", destinationKey).Str("method", "CopyBlob").Logger()
+
+	if keyErr := vcblobstore.ValidateBlobKey(g.naming, destinationKey); keyErr != nil {
+		return keyErr
+	}
+
+	content, getErr := g.GetBlob(ctx, sourceKey)
+	if getErr != nil {
+		return fmt.Errorf("failed to get source blob for copying %s -> %s: %w", sourceKey, destinationKey, getErr)
+	}
+	content, offloadErr := g.external.Offload(ctx, content)
+	if offloadErr != nil {
+		return fmt.Errorf("failed to copy blob in GitLab repo %s -> %s: %w", sourceKey, destinationKey, offloadErr)
+	}
+	content, lfsErr := g.offloadLFS(ctx, destinationKey, content)
+	if lfsErr != nil {
+		return fmt.Errorf("failed to copy blob in GitLab repo %s -> %s: %w", sourceKey, destinationKey, lfsErr)
+	}
+
+	metadata, metadataErr := g.readMetadata(ctx, sourceKey)
+	if metadataErr != nil {
+		return fmt.Errorf("failed to get source blob metadata for copying %s -> %s: %w", sourceKey, destinationKey, metadataErr)
+	}
+	mode, modeErr := g.fileModeAtRef(ctx, g.repoPath(sourceKey), g.readBranch(ctx))
+	if modeErr != nil {
+		return fmt.Errorf("failed to get source blob mode for copying %s -> %s: %w", sourceKey, destinationKey, modeErr)
+	}
+
+	// The destination is overwritten if it exists
+	action, actionErr := g.createOrUpdateAction(ctx, destinationKey)
+	if actionErr != nil {
+		return fmt.Errorf("failed to copy blob in GitLab repo %s -> %s: %w", sourceKey, destinationKey, actionErr)
+	}
+
+	actions := []commitActionOnByteSlice{
+		{
+			Action:   action,
+			FilePath: g.repoPath(destinationKey),
+			Content:  content,
+		},
+	}
+	actions = append(actions, g.modeActions(destinationKey, mode)...)
+	metadataActions, metadataActionsErr := g.metadataActions(ctx, destinationKey, metadata)
+	if metadataActionsErr != nil {
+		return fmt.Errorf("failed to copy blob in GitLab repo %s -> %s: %w", sourceKey, destinationKey, metadataActionsErr)
+	}
+	actions = append(actions, metadataActions...)
+	indexActions, indexErr := g.attributeIndexActions(ctx, map[string]map[string]string{destinationKey: metadata})
+	if indexErr != nil {
+		return fmt.Errorf("failed to copy blob in GitLab repo %s -> %s: %w", sourceKey, destinationKey, indexErr)
+	}
+	actions = append(actions, indexActions...)
+
+	commitErr := g.commit(ctx, vcblobstore.Author{Name: modifiedBy}, fmt.Sprintf("Copying blob: %s -> %s", sourceKey, destinationKey), actions)
+	if commitErr != nil {
+		return fmt.Errorf("failed to copy blob in GitLab repo %s -> %s: %w", sourceKey, destinationKey, commitErr)
+	}
+
+	g.access.RecordWrite(destinationKey)
+	logger.Info().Msg("Blob copied in GitLab repository")
+	return nil
+}
+
+// RestoreBlob commits the content the blob had at the specified version as its new version
+func (g *Gitlab) RestoreBlob(ctx context.Context, key string, commitId string, modifiedBy string) error {
+	if keyErr := vcblobstore.ValidateBlobKey(g.naming, key); keyErr != nil {
+		return keyErr
+	}
+	logger := zerolog.Ctx(ctx).With().Str("key", key).Str("commitId", commitId).Str("method", "RestoreBlob").Logger()
+
+	content, contentErr := g.GetBlobAtVersion(ctx, key, commitId)
+	if contentErr != nil {
+		return fmt.Errorf("failed to restore blob %s to version %s: %w", key, commitId, contentErr)
+	}
+	content, offloadErr := g.external.Offload(ctx, content)
+	if offloadErr != nil {
+		return fmt.Errorf("failed to restore blob %s to version %s: %w", key, commitId, offloadErr)
+	}
+	content, lfsErr := g.offloadLFS(ctx, key, content)
+	if lfsErr != nil {
+		return fmt.Errorf("failed to restore blob %s to version %s: %w", key, commitId, lfsErr)
+	}
+	// The blob gets back the mode of the version too, not the one of its current file
+	mode, modeErr := g.fileModeAtRef(ctx, g.repoPath(key), commitId)
+	if modeErr != nil {
+		return fmt.Errorf("failed to restore blob %s to version %s: %w", key, commitId, modeErr)
+	}
+
+	action, actionErr := g.createOrUpdateAction(ctx, key)
+	if actionErr != nil {
+		return fmt.Errorf("failed to restore blob %s to version %s: %w", key, commitId, actionErr)
+	}
+
+	actions := []commitActionOnByteSlice{
+		{
+			Action:   action,
+			FilePath: g.repoPath(key),
+			Content:  content,
+		},
+	}
+	actions = append(actions, g.modeActions(key, mode)...)
+	commitErr := g.commit(ctx, vcblobstore.Author{Name: modifiedBy}, fmt.Sprintf("Restoring blob: %s to version %s", key, commitId), actions)
+	if commitErr != nil {
+		return fmt.Errorf("failed to restore blob %s to version %s in GitLab repo: %w", key, commitId, commitErr)
+	}
+
+	g.access.RecordWrite(key)
+	logger.Info().Msg("Blob restored in GitLab repository")
+	return nil
+}
+
+// RenameBlob moves the blob to a new key using GitLab's native move action, which preserves the history of the blob
+func (g *Gitlab) RenameBlob(ctx context.Context, oldKey string, newKey string, modifiedBy string) error {
+	logger := zerolog.Ctx(ctx).With().Str("oldKey", oldKey).Str("newKey", newKey).Str("method", "RenameBlob").Logger()
+
+	for _, key := range []string{oldKey, newKey} {
+		if keyErr := vcblobstore.ValidateBlobKey(g.naming, key); keyErr != nil {
+			return keyErr
+		}
+	}
+
+	actions := []commitActionOnByteSlice{
+		{
+			Action:       commitActionMove,
+			FilePath:     g.repoPath(newKey),
+			PreviousPath: g.repoPath(oldKey),
+		},
+	}
+	baseCtx, baseErr := g.commitBaseContext(ctx)
+	if baseErr != nil {
+		return fmt.Errorf("failed to rename blob in GitLab repo %s -> %s: %w", oldKey, newKey, baseErr)
+	}
+	sidecarHead, headErr := g.HeadBlob(baseCtx, g.naming.MetadataSidecarKey(oldKey))
+	if headErr != nil {
+		return fmt.Errorf("failed to rename blob in GitLab repo %s -> %s: %w", oldKey, newKey, headErr)
+	}
+	if sidecarHead.Exists {
+		actions = append(actions, commitActionOnByteSlice{
+			Action:       commitActionMove,
+			FilePath:     g.naming.MetadataSidecarKey(newKey),
+			PreviousPath: g.naming.MetadataSidecarKey(oldKey),
+		})
+
+		metadata, metadataErr := g.readMetadata(baseCtx, oldKey)
+		if metadataErr != nil {
+			return fmt.Errorf("failed to rename blob in GitLab repo %s -> %s: %w", oldKey, newKey, metadataErr)
+		}
+		indexActions, indexErr := g.attributeIndexActions(ctx, map[string]map[string]string{oldKey: nil, newKey: metadata})
+		if indexErr != nil {
+			return fmt.Errorf("failed to rename blob in GitLab repo %s -> %s: %w", oldKey, newKey, indexErr)
+		}
+		actions = append(actions, indexActions...)
+	}
+
+	commitErr := g.commit(ctx, vcblobstore.Author{Name: modifiedBy}, fmt.Sprintf("Renaming blob: %s -> %s", oldKey, newKey), actions)
+	if commitErr != nil {
+		return fmt.Errorf("failed to rename blob in GitLab repo %s -> %s: %w", oldKey, newKey, commitErr)
+	}
+
+	g.access.RecordWrite(oldKey, newKey)
+	logger.Info().Msg("Blob renamed in GitLab repository")
+	return nil
+}
+
+func (g *Gitlab) GetBlob(ctx context.Context, key string) ([]byte, error) {
+	content, found, err := g.getBlobAtRef(ctx, key, g.readBranch(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		canonicalKey, resolveErr := g.ResolveAlias(ctx, key)
+		if resolveErr != nil {
+			return nil, resolveErr
+		}
+		if canonicalKey != key {
+			return g.GetBlob(ctx, canonicalKey)
+		}
+		return nil, fmt.Errorf("failed to get Blob from GitLab repo %s: %w", key, vcblobstore.ErrBlobNotFound)
+	}
+	g.access.RecordRead(key)
+	content, lfsErr := g.resolveLFS(ctx, content)
+	if lfsErr != nil {
+		return nil, lfsErr
+	}
+	return g.external.Resolve(ctx, content)
+}
+
+// HotKeys returns the topN most frequently accessed keys. It is empty unless access tracking is configured
+func (g *Gitlab) HotKeys(ctx context.Context, topN int) []vcblobstore.AccessStats {
+	return g.access.HotKeys(topN)
+}
+
+// CollectExternalGarbage deletes the objects of the external storage which are referenced by none of the current blobs.
+// Unlike the local backend, it doesn't scan the history: earlier versions of offloaded blobs become unreadable
+func (g *Gitlab) CollectExternalGarbage(ctx context.Context) ([]string, error) {
+	if g.external == nil {
+		return []string{}, nil
+	}
+
+	referenced := map[string]bool{}
+	for item, err := range g.iterateRepositoryTree(ctx, "") {
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up pointers to external objects: %w", err)
+		}
+		if item.Type != "blob" {
+			continue
+		}
+		content, found, contentErr := g.getFileAtRef(ctx, item.Path, g.readBranch(ctx))
+		if contentErr != nil {
+			return nil, fmt.Errorf("failed to look up pointers to external objects: %w", contentErr)
+		}
+		if objectName, ok := vcblobstore.ExternalObjectOf(content); found && ok {
+			referenced[objectName] = true
+		}
+	}
+
+	return g.external.CollectGarbage(ctx, referenced)
+}
+
+func (g *Gitlab) aliasLookup(ctx context.Context) vcblobstore.AliasLookup {
+	return func(key string) (string, bool, error) {
+		content, found, err := g.getBlobAtRef(ctx, g.naming.AliasPointerKey(key), g.readBranch(ctx))
+		if err != nil || !found {
+			return "", false, err
+		}
+
+		target, decodeErr := vcblobstore.DecodeAliasPointer(content)
+		if decodeErr != nil {
+			return "", false, fmt.Errorf("failed to read alias %s: %w", key, decodeErr)
+		}
+		return target, true, nil
+	}
+}
+
+// CreateAlias makes reads of the alias key redirect to target
+func (g *Gitlab) CreateAlias(ctx context.Context, alias string, target string, modifiedBy string) error {
+	loopErr := vcblobstore.CheckNewAlias(alias, target, g.aliasLookup(ctx))
+	if loopErr != nil {
+		return fmt.Errorf("failed to create alias %s -> %s: %w", alias, target, loopErr)
+	}
+
+	pointer, encodeErr := vcblobstore.EncodeAliasPointer(target)
+	if encodeErr != nil {
+		return encodeErr
+	}
+
+	return g.addBlob(ctx, vcblobstore.BlobInfo{
+		Key:        g.naming.AliasPointerKey(alias),
+		Content:    pointer,
+		ModifiedBy: modifiedBy,
+	})
+}
+
+// ResolveAlias returns the canonical key the key redirects to (the key itself in case it is not an alias)
+func (g *Gitlab) ResolveAlias(ctx context.Context, key string) (string, error) {
+	return vcblobstore.ResolveAlias(key, g.aliasLookup(ctx))
+}
+
+func (g *Gitlab) ListAliases(ctx context.Context) ([]vcblobstore.Alias, error) {
+	tree, treeErr := g.getRepositoryTree(ctx, strings.TrimSuffix(g.naming.AliasDirectory(), "/"))
+	if treeErr != nil {
+		return nil, fmt.Errorf("failed to list alias pointers: %w", treeErr)
+	}
+
+	lookup := g.aliasLookup(ctx)
+	aliases := []vcblobstore.Alias{}
+	for _, treeItem := range tree {
+		if treeItem.Type != "blob" {
+			continue
+		}
+		alias := g.naming.AliasFromPointerKey(treeItem.Path)
+		target, _, lookupErr := lookup(alias)
+		if lookupErr != nil {
+			return nil, lookupErr
+		}
+		aliases = append(aliases, vcblobstore.Alias{Alias: alias, Target: target})
+	}
+	return aliases, nil
+}
+
+// ListAliasesAtVersion returns the aliases as of the commit
+func (g *Gitlab) ListAliasesAtVersion(ctx context.Context, commitId string) ([]vcblobstore.Alias, error) {
+	aliases := []vcblobstore.Alias{}
+	for treeItem, err := range g.iterateRepositoryTreeAt(ctx, commitId, strings.TrimSuffix(g.naming.AliasDirectory(), "/")) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to list alias pointers at %s: %w", commitId, err)
+		}
+		if treeItem.Type != "blob" {
+			continue
+		}
+		content, found, readErr := g.getFileAtRef(ctx, treeItem.Path, commitId)
+		if readErr != nil {
+			return nil, fmt.Errorf("failed to read alias pointer %s at %s: %w", treeItem.Path, commitId, readErr)
+		}
+		if !found {
+			continue
+		}
+		alias := g.naming.AliasFromPointerKey(treeItem.Path)
+		target, decodeErr := vcblobstore.DecodeAliasPointer(content)
+		if decodeErr != nil {
+			return nil, fmt.Errorf("failed to read alias %s at %s: %w", alias, commitId, decodeErr)
+		}
+		aliases = append(aliases, vcblobstore.Alias{Alias: alias, Target: target})
+	}
+	return aliases, nil
+}
+
+// GetBlobMetadataAtVersion returns the metadata of the blob as it was at the commit
+func (g *Gitlab) GetBlobMetadataAtVersion(ctx context.Context, key string, commitId string) (map[string]string, error) {
+	size, sizeErr := g.fileSizeAtRef(ctx, g.repoPath(key), commitId)
+	if sizeErr != nil {
+		return nil, sizeErr
+	}
+	if size < 0 {
+		return nil, fmt.Errorf("failed to get the metadata of %s at version %s from GitLab repo: %w", key, commitId, vcblobstore.ErrBlobNotFound)
+	}
+	content, found, err := g.getBlobAtRef(ctx, g.naming.MetadataSidecarKey(key), commitId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata of %s at %s: %w", key, commitId, err)
+	}
+	if !found {
+		return map[string]string{}, nil
+	}
+	return vcblobstore.DecodeMetadata(content)
+}
+
+// GetBlobModeAtVersion returns the file mode of the blob as it was at the commit
+func (g *Gitlab) GetBlobModeAtVersion(ctx context.Context, key string, commitId string) (vcblobstore.FileMode, error) {
+	return g.fileModeAtRef(ctx, g.repoPath(key), commitId)
+}
+
+// GetBlobAtVersion returns the content of the blob as it existed at the specified commit
+func (g *Gitlab) GetBlobAtVersion(ctx context.Context, key string, commitId string) ([]byte, error) {
+	content, found, err := g.getBlobAtRef(ctx, key, commitId)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("failed to get Blob %s at version %s from GitLab repo: %w", key, commitId, vcblobstore.ErrBlobNotFound)
+	}
+	content, lfsErr := g.resolveLFS(ctx, content)
+	if lfsErr != nil {
+		return nil, lfsErr
+	}
+	return g.external.Resolve(ctx, content)
+}
+
+// getBlobAtRef returns the content of the blob as of the specified ref and false in case the blob doesn't exist at that ref
+func (g *Gitlab) getBlobAtRef(ctx context.Context, key string, ref string) ([]byte, bool, error) {
+	return g.getFileAtRef(ctx, g.repoPath(key), ref)
+}
+
+// getFileAtRef returns the content of the file at the path of the repository as of the specified ref
+func (g *Gitlab) getFileAtRef(ctx context.Context, path string, ref string) ([]byte, bool, error) {
+	statusCode, _, body, err := g.sendRequest(
+		ctx,
+		"GET",
+		fmt.Sprintf(
+			"/projects/%s/repository/files/%s?%s",
+			g.escapedProjectPath(),
+			url.PathEscape(path),
+			fmt.Sprintf("ref=%s", url.QueryEscape(ref)),
+		),
+		nil,
+	)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to send request to get blobfile from GitLab repo %s: %w", path, err)
+	}
+	if statusCode == 404 && !projectMissing(body) {
+		return nil, false, nil
+	}
+	if statusCode != 200 {
+		return nil, false, fmt.Errorf("failed to get Blob from GitLab repo %s: (%d) %s -- %w", path, statusCode, body, typedStatusError(statusCode, body, err))
+	}
+
+	respFileItem := responseFileItem{}
+	jsonErr := json.Unmarshal([]byte(body), &respFileItem)
+	if jsonErr != nil {
+		return nil, false, fmt.Errorf("failed to unmarshal GitLab namespace list: %w", jsonErr)
+	}
+
+	if respFileItem.Encoding != "base64" {
+		return nil, false, fmt.Errorf("unexpected encoding for Blob from GitLab repo %s: %s", path, respFileItem.Encoding)
+	}
+
+	content, decodeErr := base64.StdEncoding.DecodeString(respFileItem.Content)
+	if decodeErr != nil {
+		return nil, false, fmt.Errorf("failed to decode Blob content (%s) for %s: %w", string(body), path, decodeErr)
+	}
+	if checksum := vcblobstore.ContentSHA256(content); len(respFileItem.ContentSha256) > 0 && checksum != respFileItem.ContentSha256 {
+		return nil, false, fmt.Errorf("content of %s received from GitLab has SHA-256 %s instead of %s: %w", path, checksum, respFileItem.ContentSha256, vcblobstore.ErrChecksumMismatch)
+	}
+
+	return content, true, nil
+}
+
+// fileModeAtRef returns the mode of the file at the path of the repository as of the specified ref
+func (g *Gitlab) fileModeAtRef(ctx context.Context, path string, ref string) (vcblobstore.FileMode, error) {
+	statusCode, header, body, err := g.sendRequest(ctx, "HEAD", fmt.Sprintf("/projects/%s/repository/files/%s?%s", g.escapedProjectPath(), url.PathEscape(path), url.Values{"ref": {ref}}.Encode()), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the mode of %s at %s from GitLab repo: (%d) %s -- %w", path, ref, statusCode, body, err)
+	}
+	if statusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get the mode of %s at %s from GitLab repo: (%d) %s -- %w", path, ref, statusCode, body, typedBlobStatusError(statusCode, body, err))
+	}
+	return vcblobstore.FileModeOf(header.Get("X-Gitlab-Execute-Filemode") == "true"), nil
+}
+
+// maxCommitPageSize is the largest page size the commit endpoints accept
+const maxCommitPageSize = 100
+
+// iterateCommits walks the commits matching the query, newest first, fetching the pages of the list on demand
+func (g *Gitlab) iterateCommits(ctx context.Context, query url.Values) iter.Seq2[git.CommitQueryResponseItem, error] {
+	return func(yield func(git.CommitQueryResponseItem, error) bool) {
+		page := "1"
+		for len(page) > 0 {
+			pageQuery := maps.Clone(query)
+			pageQuery.Set("page", page)
+			pageQuery.Set("per_page", strconv.Itoa(maxCommitPageSize))
+
+			statusCode, header, body, err := g.sendRequest(ctx, "GET", fmt.Sprintf("/projects/%s/repository/commits?%s", g.escapedProjectPath(), pageQuery.Encode()), nil)
+			if err != nil {
+				yield(git.CommitQueryResponseItem{}, fmt.Errorf("failed to send request to get commit list from GitLab repo: %w", err))
+				return
+			}
+			if statusCode != 200 {
+				yield(git.CommitQueryResponseItem{}, fmt.Errorf("failed to get commit list from GitLab repo (%d) %s -- %w", statusCode, body, typedStatusError(statusCode, body, err)))
+				return
+			}
+
+			commitList := []git.CommitQueryResponseItem{}
+			jsonErr := json.Unmarshal([]byte(body), &commitList)
+			if jsonErr != nil {
+				yield(git.CommitQueryResponseItem{}, fmt.Errorf("failed to unmarshal GitLab commit list response: %w", jsonErr))
+				return
+			}
+			for _, commitItem := range commitList {
+				if !yield(commitItem, nil) {
+					return
+				}
+			}
+			page = header.Get("X-Next-Page")
+		}
+	}
+}
+
+// listVersionsFor returns the IDs of the commits which modified the blob, oldest first
+func (g *Gitlab) listVersionsFor(ctx context.Context, key string) ([]string, error) {
+	query := url.Values{}
+	query.Set("ref_name", g.readBranch(ctx))
+	query.Set("path", g.repoPath(key))
+
+	versions := []string{}
+	for commitItem, err := range g.iterateCommits(ctx, query) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the versions of %s: %w", key, err)
+		}
+		versions = append(versions, commitItem.Id)
+	}
+	slices.Reverse(versions)
+	return versions, nil
+}
+
+type commitDiffItem struct {
+	OldPath     string `json:"old_path"`
+	NewPath     string `json:"new_path"`
+	NewFile     bool   `json:"new_file"`
+	RenamedFile bool   `json:"renamed_file"`
+	DeletedFile bool   `json:"deleted_file"`
+	Diff        string `json:"diff"`
+	// Collapsed and TooLarge mark the diffs GitLab leaves out for their size
+	Collapsed bool `json:"collapsed"`
+	TooLarge  bool `json:"too_large"`
+}
+
+func (g *Gitlab) getCommitDiff(ctx context.Context, commitId string) ([]commitDiffItem, error) {
+	diff := []commitDiffItem{}
+	page := "1"
+	for len(page) > 0 {
+		query := url.Values{}
+		query.Set("page", page)
+		query.Set("per_page", strconv.Itoa(maxCommitPageSize))
+
+		statusCode, header, body, err := g.sendRequest(ctx, "GET", fmt.Sprintf("/projects/%s/repository/commits/%s/diff?%s", g.escapedProjectPath(), commitId, query.Encode()), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to send request to get the diff of commit %s from GitLab repo: %w", commitId, err)
+		}
+		if statusCode != 200 {
+			return nil, fmt.Errorf("failed to get the diff of commit %s from GitLab repo (%d) %s -- %w", commitId, statusCode, body, typedStatusError(statusCode, body, err))
+		}
+
+		diffPage := []commitDiffItem{}
+		jsonErr := json.Unmarshal([]byte(body), &diffPage)
+		if jsonErr != nil {
+			return nil, fmt.Errorf("failed to unmarshal GitLab diff response for commit %s: %w", commitId, jsonErr)
+		}
+		diff = append(diff, diffPage...)
+		page = header.Get("X-Next-Page")
+	}
+	return diff, nil
+}
+
+// blobOperationIn returns the kind of change the commit made to the file at the path
+func (g *Gitlab) blobOperationIn(ctx context.Context, commitId string, path string) (vcblobstore.BlobOperation, error) {
+	diff, diffErr := g.getCommitDiff(ctx, commitId)
+	if diffErr != nil {
+		return "", diffErr
+	}
+
+	for _, diffItem := range diff {
+		switch {
+		case diffItem.DeletedFile && diffItem.OldPath == path:
+			return vcblobstore.BlobOperationDelete, nil
+		case diffItem.RenamedFile && diffItem.OldPath == path:
+			return vcblobstore.BlobOperationDelete, nil
+		case (diffItem.NewFile || diffItem.RenamedFile) && diffItem.NewPath == path:
+			return vcblobstore.BlobOperationCreate, nil
+		case diffItem.NewPath == path:
+			return vcblobstore.BlobOperationUpdate, nil
+		}
+	}
+	return vcblobstore.BlobOperationUpdate, nil
+}
+
+// GetBlobHistory returns the versions of the blob matching the filter, newest first.
+// Author, time range and SinceVersion are evaluated by GitLab, the rest of the criteria on the client side. The diffs
+// telling the operations of the versions are requested in batches of concurrent requests like in ListVersions
+func (g *Gitlab) GetBlobHistory(ctx context.Context, key string, filter vcblobstore.HistoryFilter) ([]vcblobstore.BlobVersion, error) {
+	revisionRange := g.readBranch(ctx)
+	if len(filter.SinceVersion) > 0 {
+		// An unknown SinceVersion fails the call instead of making the history empty
+		if _, sinceVersionErr := g.getCommitMetadata(ctx, filter.SinceVersion); sinceVersionErr != nil {
+			return nil, fmt.Errorf("failed to get the history of %s since %s: %w", key, filter.SinceVersion, sinceVersionErr)
+		}
+		revisionRange = filter.SinceVersion + ".." + revisionRange
+	}
+	query := url.Values{}
+	query.Set("ref_name", revisionRange)
+	query.Set("path", g.repoPath(key))
+	if len(filter.Author) > 0 {
+		query.Set("author", filter.Author)
+	}
+	if !filter.Since.IsZero() {
+		query.Set("since", filter.Since.Format(time.RFC3339))
+	}
+	if !filter.Until.IsZero() {
+		query.Set("until", filter.Until.Format(time.RFC3339))
+	}
+
+	versions := []vcblobstore.BlobVersion{}
+	for commitItem, err := range g.iterateCommits(ctx, query) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the history of %s: %w", key, err)
+		}
+		metadata, conversionErr := git.GitlabCommitResponseToMetadata(commitItem)
+		if conversionErr != nil {
+			return nil, fmt.Errorf("failed to parse git.CommitQueryResponseItem for GitLab commit %s: %w", commitItem.Id, conversionErr)
+		}
+		version := vcblobstore.BlobVersion{
+			Version:    commitItem.Id,
+			Author:     metadata.Author,
+			AuthorDate: metadata.AuthorDate,
+			Message:    metadata.Message,
+		}
+		if len(filter.MessageContains) > 0 && !strings.Contains(version.Message, filter.MessageContains) {
+			continue
+		}
+		versions = append(versions, version)
+	}
+
+	operationErr := inBatches(len(versions), func(index int) error {
+		operation, err := g.blobOperationIn(ctx, versions[index].Version, g.repoPath(key))
+		versions[index].Operation = operation
+		return err
+	})
+	if operationErr != nil {
+		return nil, operationErr
+	}
+	return slices.DeleteFunc(versions, func(version vcblobstore.BlobVersion) bool {
+		return !filter.Matches(version)
+	}), nil
+}
+
+// ExportHistory writes every version of the specified blobs to w as a history bundle
+func (g *Gitlab) ExportHistory(ctx context.Context, keys []string, w io.Writer) error {
+	records := []vcblobstore.HistoryRecord{}
+
+	for _, key := range keys {
+		versions, listErr := g.listVersionsFor(ctx, key)
+		if listErr != nil {
+			return listErr
+		}
+
+		for _, version := range versions {
+			metadata, metadataErr := g.getCommitMetadata(ctx, version)
+			if metadataErr != nil {
+				return metadataErr
+			}
+
+			content, exists, contentErr := g.getBlobAtRef(ctx, key, version)
+			if contentErr != nil {
+				return contentErr
+			}
+			if exists {
+				// The bundle carries the content itself: the pointers are of no use to the store importing it
+				var resolveErr error
+				if content, resolveErr = g.resolveLFS(ctx, content); resolveErr != nil {
+					return resolveErr
+				}
+				if content, resolveErr = g.external.Resolve(ctx, content); resolveErr != nil {
+					return resolveErr
+				}
+			}
+
+			records = append(records, vcblobstore.HistoryRecord{
+				Key:        key,
+				Version:    version,
+				Author:     metadata.Author,
+				AuthorDate: metadata.AuthorDate,
+				Message:    metadata.Message,
+				Deleted:    !exists,
+				Content:    content,
+			})
+		}
+	}
+
+	return vcblobstore.WriteHistory(w, records)
+}
+
+// ImportHistory replays the versions of a history bundle as new commits, preserving the order, the authors and the
+// messages of the changes. The commits API doesn't take author dates, so the commits are dated at the import
+func (g *Gitlab) ImportHistory(ctx context.Context, r io.Reader) error {
+	return vcblobstore.ReadHistory(r, func(record vcblobstore.HistoryRecord) error {
+		ctx := vcblobstore.WithImportedRecord(ctx, record)
+		if record.Deleted {
+			return g.DeleteBlob(ctx, record.Key, record.AuthorName())
+		}
+		return g.AddBlob(ctx, vcblobstore.BlobInfo{
+			Key:         record.Key,
+			Content:     record.Content,
+			AuthorName:  record.AuthorName(),
+			AuthorEmail: record.AuthorEmail(),
+		})
+	})
+}
+
+// commitChunk records the actions in a single commit. Callers go through commit, which splits oversized payloads
+func (g *Gitlab) commitChunk(ctx context.Context, author vcblobstore.Author, commitMessage string, actions []commitActionOnByteSlice) error {
+	branch, branchErr := g.commitBranch(ctx)
+	if branchErr != nil {
+		return branchErr
+	}
+	if record, imported := vcblobstore.ImportedRecordOf(ctx); imported && len(record.Message) > 0 {
+		commitMessage = record.Message
+	}
+	if vcblobstore.ShouldSkipCI(ctx, g.skipCI) {
+		commitMessage = vcblobstore.MarkSkipCI(commitMessage)
+	}
+	commitBody, createCommitBodyErr := g.createCommitBody(branch, author, commitMessage, actions)
+	if createCommitBodyErr != nil {
+		return fmt.Errorf("failed to create commit request body: %w", createCommitBodyErr)
+	}
+
+	statusCode, _, body, err := g.sendRequest(
+		ctx,
+		"POST",
+		fmt.Sprintf("/projects/%s/repository/commits?%s", g.escapedProjectPath(), url.PathEscape(fmt.Sprintf("ref=%s", branch.name))),
+		commitBody,
+	)
+	if err == nil && statusCode == http.StatusForbidden {
+		if protectedErr := g.checkBranchProtected(ctx, branch.name); protectedErr != nil {
+			return fmt.Errorf("failed to commit to GitLab repo: %w", protectedErr)
+		}
+	}
+	if err != nil || statusCode != 201 {
+		return fmt.Errorf("failed to commit to GitLab repo: (%d) %s -- %w", statusCode, body, typedStatusError(statusCode, body, err))
+	}
+
+	if branch.name == g.stagingBranch && g.stagingMergeRequest {
+		if mergeRequestErr := g.openStagingMergeRequest(ctx); mergeRequestErr != nil {
+			return fmt.Errorf("committed to %s, but %w", branch.name, mergeRequestErr)
+		}
+	}
+	return nil
+}
+
+// typedStatusError returns the transport error of a failed GitLab API call or, if there was none,
+// the vcblobstore error corresponding to the status of the response. A 404 is ErrRepoNotFound only if GitLab says
+// the project is missing: what else is missing depends on the call (see typedBlobStatusError)
+func typedStatusError(statusCode int, body string, err error) error {
+	if err != nil {
+		return err
+	}
+	switch {
+	case statusCode == http.StatusUnauthorized:
+		return vcblobstore.ErrUnauthorized
+	case statusCode == http.StatusForbidden:
+		return vcblobstore.ErrForbidden
+	case statusCode == http.StatusNotFound && projectMissing(body):
+		return vcblobstore.ErrRepoNotFound
+	case statusCode == http.StatusConflict:
+		return vcblobstore.ErrConflict
+	case statusCode == http.StatusTooManyRequests:
+		return vcblobstore.ErrRateLimited
+	case statusCode == http.StatusBadRequest && strings.Contains(body, "already exists"):
+		return vcblobstore.ErrConflict
+	case statusCode == http.StatusBadRequest && strings.Contains(body, "doesn't exist"):
+		return vcblobstore.ErrBlobNotFound
+	default:
+		return fmt.Errorf("unexpected response status %d", statusCode)
+	}
+}
+
+// typedBlobStatusError is typedStatusError for the calls addressing a file: their 404 means ErrBlobNotFound, unless
+// GitLab says the project is missing
+func typedBlobStatusError(statusCode int, body string, err error) error {
+	if err == nil && statusCode == http.StatusNotFound && !projectMissing(body) {
+		return vcblobstore.ErrBlobNotFound
+	}
+	return typedStatusError(statusCode, body, err)
+}
+
+// projectMissing tells whether the body of a 404 response says the project (or its namespace) doesn't exist,
+// e.g. {"message":"404 Project Not Found"}
+func projectMissing(body string) bool {
+	for _, message := range []string{"Project Not Found", "Namespace Not Found", "Group Not Found"} {
+		if strings.Contains(body, message) {
+			return true
+		}
+	}
+	return false
+}
+
+// sendRequest sends the request to the GitLab API. Requests addressing the project by its path are resent
+// to the new path once if they fail with 404 because the project has been renamed or transferred meanwhile
+func (g *Gitlab) sendRequest(ctx context.Context, method string, apiCallPath string, body io.Reader) (int, http.Header, string, error) {
+	var bodyBytes []byte
+	if body != nil {
+		var readErr error
+		if bodyBytes, readErr = io.ReadAll(body); readErr != nil {
+			return 0, nil, "", fmt.Errorf("failed to read request body: %w", readErr)
+		}
+	}
+	projectPrefix := fmt.Sprintf("/projects/%s", g.escapedProjectPath())
+
+	statusCode, header, responseBody, err := g.sendRequestOnce(ctx, method, apiCallPath, bytesReader(bodyBytes))
+	projectNotFound := statusCode == http.StatusNotFound && strings.Contains(responseBody, "Project Not Found")
+	if err != nil || !projectNotFound || !strings.HasPrefix(apiCallPath, projectPrefix) {
+		return statusCode, header, responseBody, err
+	}
+
+	moved, relocateErr := g.relocateProject(ctx)
+	if relocateErr != nil || !moved {
+		return statusCode, header, responseBody, err
+	}
+	movedApiCallPath := fmt.Sprintf("/projects/%s%s", g.escapedProjectPath(), strings.TrimPrefix(apiCallPath, projectPrefix))
+	return g.sendRequestOnce(ctx, method, movedApiCallPath, bytesReader(bodyBytes))
+}
+
+func bytesReader(content []byte) io.Reader {
+	if content == nil {
+		return nil
+	}
+	return bytes.NewReader(content)
+}
+
+func (g *Gitlab) sendRequestOnce(ctx context.Context, method string, apiCallPath string, body io.Reader) (int, http.Header, string, error) {
+	if capabilityErr := g.credentials.check(apiCallCapability(method, apiCallPath)); capabilityErr != nil {
+		return 0, nil, "", capabilityErr
+	}
+	return g.sendRequestTo(ctx, method, fmt.Sprintf("https://gitlab.com/api/v4%s", apiCallPath), body)
+}
+
+// sendAttempt sends the request to the URL with the token of the client, observing the rate limits
+func (g *Gitlab) sendAttempt(ctx context.Context, method string, urlString string, body io.Reader) (int, http.Header, string, error) {
+	poolItem, _ := g.clientPool.Get()
+	defer func() {
+		_, _ = g.clientPool.Put(poolItem)
+	}()
+
+	client, ok := poolItem.(http.Client)
+	if !ok {
+		return 0, nil, "", errors.New("type asssertion error")
+	}
+
+	logger := zerolog.Ctx(ctx).With().Str("method", "sendRequest").Str("request-method", method).Str("url", urlString).Logger()
+
+	if waitErr := g.throttle.wait(ctx); waitErr != nil {
+		return 0, nil, "", waitErr
+	}
+
+	logger.Debug().Msg("send request")
+	request, requestCreationError := http.NewRequest(
+		method,
+		urlString,
+		body,
+	)
+
+	if requestCreationError != nil {
+		return 0, nil, "", fmt.Errorf("failed to create request: %w", requestCreationError)
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	if authErr := g.credentials.authorizeAPI(ctx, request); authErr != nil {
+		return 0, nil, "", authErr
+	}
+
+	resp, requestExecutionError := client.Do(request)
+	if requestExecutionError != nil {
+		return 0, nil, "", fmt.Errorf("failed to execute request: %w", requestExecutionError)
+	}
+	defer resp.Body.Close()
+
+	respBody, errBody := io.ReadAll(resp.Body)
+	if errBody != nil {
+		return resp.StatusCode, nil, "", fmt.Errorf("failed to read body: %w", errBody)
+	}
+
+	if rateLimitErr := g.throttle.observe(resp.Header, time.Now()); rateLimitErr != nil {
+		debugLogger := logger.Debug()
+		for key, value := range resp.Header {
+			debugLogger.Any(key, value)
+		}
+		debugLogger.Send()
+		return resp.StatusCode, nil, "", rateLimitErr
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return resp.StatusCode, resp.Header, string(respBody), g.throttle.limited(resp.Header, time.Now())
+	}
+	return resp.StatusCode, resp.Header, string(respBody), nil
+}
+
+type namespaceInfo struct {
+	Id       int    `json:"id"`
+	Path     string `json:"path"`
+	FullPath string `json:"full_path"`
+}
+
+// getNamespaceID looks the namespace of the project up by its full path, e.g. org/team/subteam for a nested group
+func getNamespaceID(ctx context.Context, gitlabCli *Gitlab) (int, error) {
+	namespacePath := gitlabCli.project.namespacePath
+	statusCode, _, body, err := gitlabCli.sendRequest(ctx, "GET", fmt.Sprintf("/namespaces/%s", url.PathEscape(namespacePath)), nil)
+	if err != nil || statusCode != 200 {
+		if err == nil && statusCode == http.StatusNotFound {
+			return 0, fmt.Errorf("failed to retreive GitLab namespace %s: %w", namespacePath, vcblobstore.ErrRepoNotFound)
+		}
+		return 0, fmt.Errorf("failed to retreive GitLab namespace %s (%d) %s -- %w", namespacePath, statusCode, body, typedStatusError(statusCode, body, err))
+	}
+
+	info := namespaceInfo{}
+	jsonErr := json.Unmarshal([]byte(body), &info)
+	if jsonErr != nil {
+		return 0, fmt.Errorf("failed to unmarshal GitLab namespace %s: %w", namespacePath, jsonErr)
+	}
+	return info.Id, nil
 }
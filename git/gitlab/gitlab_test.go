@@ -0,0 +1,497 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"vcblobstore"
+
+	"github.com/theodesp/blockingQueues"
+)
+
+func TestCreateProjectBody(t *testing.T) {
+	g := newStubGitlab(nil)
+	g.project.namespaceId = 42
+	g.projectAttributes = ProjectAttributes{
+		Visibility:           VisibilityInternal,
+		Description:          "Blobs of the application",
+		Topics:               []string{"blobs", "config"},
+		InitializeWithReadme: true,
+	}
+
+	body, bodyErr := g.createCreateProjectBody()
+	if bodyErr != nil {
+		t.Fatalf("createCreateProjectBody() = %v; want nil", bodyErr)
+	}
+	content, _ := io.ReadAll(body)
+	props := projectProperties{}
+	if jsonErr := json.Unmarshal(content, &props); jsonErr != nil {
+		t.Fatal(jsonErr)
+	}
+	if props.NamespaceId != 42 || props.Path != "project" || props.Visibility != VisibilityInternal ||
+		props.Description != "Blobs of the application" || props.DefaultBranch != "main" ||
+		len(props.Topics) != 2 || !props.InitializeWithReadme {
+		t.Errorf("createCreateProjectBody() = %s; want the configured attributes with main as the default branch", content)
+	}
+}
+
+func TestModeActions(t *testing.T) {
+	g := newStubGitlab(nil)
+
+	if actions := g.modeActions("a/b", ""); len(actions) != 0 {
+		t.Errorf("modeActions() = %v; want no action without a mode", actions)
+	}
+
+	body, bodyErr := g.createCommitBody(commitBranch{name: "main"}, vcblobstore.Author{Name: "tester"}, "test", g.modeActions("a/b", vcblobstore.FileModeExecutable))
+	if bodyErr != nil {
+		t.Fatalf("createCommitBody() = %v; want nil", bodyErr)
+	}
+	content, _ := io.ReadAll(body)
+	props := commitProperties{}
+	if jsonErr := json.Unmarshal(content, &props); jsonErr != nil {
+		t.Fatal(jsonErr)
+	}
+	if len(props.Actions) != 1 || props.Actions[0].Action != commitActionChmod || props.Actions[0].FilePath != "a/b" ||
+		props.Actions[0].ExecuteFilemode == nil || !*props.Actions[0].ExecuteFilemode {
+		t.Errorf("actions = %s; want a chmod action making a/b executable", content)
+	}
+}
+
+func TestVerifyRepository(t *testing.T) {
+	branchFound := true
+	g := newStubGitlab(func(request *http.Request) (*http.Response, error) {
+		switch {
+		case strings.HasSuffix(request.URL.Path, "/repository/branches/main") && branchFound:
+			return stubResponse(http.StatusOK, `{"name":"main","commit":{"id":"abc"}}`), nil
+		case strings.HasSuffix(request.URL.Path, "/repository/tree") && request.URL.Query().Get("ref") == "abc":
+			return stubResponse(http.StatusOK, "[]"), nil
+		}
+		return stubResponse(http.StatusNotFound, `{"message":"404 Not Found"}`), nil
+	})
+
+	report, verifyErr := g.VerifyRepository(context.Background(), vcblobstore.RepairOptions{})
+	if verifyErr != nil {
+		t.Fatalf("VerifyRepository() = %v; want nil", verifyErr)
+	}
+	if !report.Healthy || len(report.Checks) != 2 {
+		t.Errorf("report = %+v; want two checks passed", report)
+	}
+
+	branchFound = false
+	report, verifyErr = g.VerifyRepository(context.Background(), vcblobstore.RepairOptions{})
+	if verifyErr != nil {
+		t.Fatalf("VerifyRepository() = %v; want nil", verifyErr)
+	}
+	if report.Healthy || len(report.Checks) != 1 || report.Checks[0].Passed {
+		t.Errorf("report = %+v; want the check of the main branch failed", report)
+	}
+}
+
+func TestDiffBlob(t *testing.T) {
+	g := newStubGitlab(func(request *http.Request) (*http.Response, error) {
+		switch {
+		case request.Method == "HEAD" && request.URL.Query().Get("ref") == "old" && !strings.HasSuffix(request.URL.Path, "large"):
+			return stubResponse(http.StatusNotFound, ""), nil
+		case request.Method == "HEAD":
+			response := stubResponse(http.StatusOK, "")
+			response.Header.Set("X-Gitlab-Size", "4")
+			return response, nil
+		case strings.HasSuffix(request.URL.Path, "/repository/compare"):
+			return stubResponse(http.StatusOK, `{"diffs":[{"old_path":"other","new_path":"other","diff":"@@ -1 +1 @@\n-x\n+y\n"},{"old_path":"a/b","new_path":"a/b","new_file":true,"diff":"@@ -0,0 +1 @@\n+abc\n"},`+
+				`{"old_path":"a/large","new_path":"a/large","diff":"","collapsed":true}]}`), nil
+		}
+		return stubResponse(http.StatusNotFound, ""), nil
+	})
+
+	diff, diffErr := g.DiffBlob(context.Background(), "a/b", "old", "new")
+	if diffErr != nil {
+		t.Fatalf("DiffBlob() = %v; want nil", diffErr)
+	}
+	if diff.FromSize != -1 || diff.ToSize != 4 || diff.Binary || diff.Patch != "--- /dev/null\n+++ b/a/b\n@@ -0,0 +1 @@\n+abc\n" {
+		t.Errorf("diff = %+v; want the patch creating a/b", diff)
+	}
+
+	diff, diffErr = g.DiffBlob(context.Background(), "a/large", "old", "new")
+	if diffErr != nil {
+		t.Fatalf("DiffBlob() = %v; want nil", diffErr)
+	}
+	if !diff.TooLarge || len(diff.Patch) > 0 || !strings.Contains(diff.Summary(), "changed") {
+		t.Errorf("diff = %+v; want the collapsed diff reported as a change", diff)
+	}
+}
+
+func TestTagState(t *testing.T) {
+	g := newStubGitlab(func(request *http.Request) (*http.Response, error) {
+		content, _ := io.ReadAll(request.Body)
+		switch {
+		case strings.Contains(string(content), `"tag_name":"v1"`):
+			return stubResponse(http.StatusBadRequest, `{"message":"Tag v1 already exists"}`), nil
+		case strings.Contains(string(content), `"ref":"main"`):
+			return stubResponse(http.StatusCreated, `{"name":"v2"}`), nil
+		}
+		return stubResponse(http.StatusNotFound, ""), nil
+	})
+
+	if tagErr := g.TagState(context.Background(), "v2", ""); tagErr != nil {
+		t.Errorf("TagState() = %v; want nil", tagErr)
+	}
+	if tagErr := g.TagState(context.Background(), "v1", "abc"); !errors.Is(tagErr, vcblobstore.ErrConflict) {
+		t.Errorf("TagState() = %v; want ErrConflict", tagErr)
+	}
+}
+
+func TestRevertToState(t *testing.T) {
+	commitBody := ""
+	g := newStubGitlab(func(request *http.Request) (*http.Response, error) {
+		switch {
+		case strings.HasSuffix(request.URL.Path, "/repository/tree") && request.URL.Query().Get("ref") == "abc":
+			return stubResponse(http.StatusOK, `[{"id":"1","type":"blob","path":"removed","mode":"100644"},{"id":"2","type":"blob","path":"changed","mode":"100644"},{"id":"3","type":"blob","path":"same","mode":"100644"},{"id":"4","type":"blob","path":"chmod","mode":"100755"}]`), nil
+		case strings.HasSuffix(request.URL.Path, "/repository/tree"):
+			return stubResponse(http.StatusOK, `[{"id":"5","type":"blob","path":"added","mode":"100644"},{"id":"6","type":"blob","path":"changed","mode":"100644"},{"id":"3","type":"blob","path":"same","mode":"100644"},{"id":"4","type":"blob","path":"chmod","mode":"100644"}]`), nil
+		case strings.Contains(request.URL.Path, "/repository/files/") && request.URL.Query().Get("ref") == "abc":
+			return stubResponse(http.StatusOK, `{"encoding":"base64","content":"b2xkIGNvbnRlbnQ="}`), nil
+		case strings.HasSuffix(request.URL.Path, "/repository/commits"):
+			content, _ := io.ReadAll(request.Body)
+			commitBody = string(content)
+			return stubResponse(http.StatusCreated, "{}"), nil
+		}
+		return stubResponse(http.StatusNotFound, ""), nil
+	})
+	g.maxCommitPayload = defaultMaxCommitPayloadBytes
+	g.treePageSize = maxTreePageSize
+
+	if revertErr := g.RevertToState(context.Background(), "abc", "tester"); revertErr != nil {
+		t.Fatalf("RevertToState() = %v; want nil", revertErr)
+	}
+	props := commitProperties{}
+	if jsonErr := json.Unmarshal([]byte(commitBody), &props); jsonErr != nil {
+		t.Fatal(jsonErr)
+	}
+	actions := []string{}
+	for _, action := range props.Actions {
+		actions = append(actions, string(action.Action)+" "+action.FilePath)
+		if action.Action == commitActionChmod && (action.ExecuteFilemode == nil || !*action.ExecuteFilemode) {
+			t.Errorf("chmod = %v; want the file made executable again", action.ExecuteFilemode)
+		}
+	}
+	if strings.Join(actions, ", ") != "delete added, update changed, chmod chmod, create removed" {
+		t.Errorf("actions = %v; want the changes since abc undone", actions)
+	}
+}
+
+func TestRestoreBlobMode(t *testing.T) {
+	commitBody := ""
+	g := newStubGitlab(func(request *http.Request) (*http.Response, error) {
+		switch {
+		case request.Method == "HEAD" && strings.Contains(request.URL.Path, "/repository/files/"):
+			response := stubResponse(http.StatusOK, "")
+			response.Header.Set("X-Gitlab-Size", "11")
+			response.Header.Set("X-Gitlab-Execute-Filemode", strconv.FormatBool(request.URL.Query().Get("ref") == "abc"))
+			return response, nil
+		case strings.Contains(request.URL.Path, "/repository/files/") && request.URL.Query().Get("ref") == "abc":
+			return stubResponse(http.StatusOK, `{"encoding":"base64","content":"b2xkIGNvbnRlbnQ="}`), nil
+		case strings.HasSuffix(request.URL.Path, "/repository/commits"):
+			content, _ := io.ReadAll(request.Body)
+			commitBody = string(content)
+			return stubResponse(http.StatusCreated, "{}"), nil
+		}
+		return stubResponse(http.StatusNotFound, ""), nil
+	})
+	g.naming = vcblobstore.DefaultNaming
+	g.maxCommitPayload = defaultMaxCommitPayloadBytes
+
+	if restoreErr := g.RestoreBlob(context.Background(), "scripts/run", "abc", "tester"); restoreErr != nil {
+		t.Fatalf("RestoreBlob() = %v; want nil", restoreErr)
+	}
+	props := commitProperties{}
+	if jsonErr := json.Unmarshal([]byte(commitBody), &props); jsonErr != nil {
+		t.Fatal(jsonErr)
+	}
+	if len(props.Actions) != 2 || props.Actions[0].Action != commitActionUpdate || props.Actions[1].Action != commitActionChmod ||
+		props.Actions[1].ExecuteFilemode == nil || !*props.Actions[1].ExecuteFilemode {
+		t.Errorf("actions = %s; want the content restored and the file made executable again", commitBody)
+	}
+}
+
+func TestCopyBlob(t *testing.T) {
+	commitBody := ""
+	g := newStubGitlab(func(request *http.Request) (*http.Response, error) {
+		file, _ := url.PathUnescape(strings.TrimPrefix(request.URL.EscapedPath(), "/api/v4/projects/group%2Fproject/repository/files/"))
+		switch {
+		case request.Method == "HEAD" && (file == "source" || file == "destination"):
+			response := stubResponse(http.StatusOK, "")
+			response.Header.Set("X-Gitlab-Size", "7")
+			response.Header.Set("X-Gitlab-Execute-Filemode", strconv.FormatBool(file == "source"))
+			return response, nil
+		case file == "source":
+			return stubResponse(http.StatusOK, `{"encoding":"base64","content":"Y29udGVudA=="}`), nil
+		case strings.HasSuffix(request.URL.Path, "/repository/commits"):
+			content, _ := io.ReadAll(request.Body)
+			commitBody = string(content)
+			return stubResponse(http.StatusCreated, "{}"), nil
+		}
+		return stubResponse(http.StatusNotFound, `{"message": "404 File Not Found"}`), nil
+	})
+	g.naming = vcblobstore.DefaultNaming
+	g.maxCommitPayload = defaultMaxCommitPayloadBytes
+
+	if copyErr := g.CopyBlob(context.Background(), "source", "destination", "tester"); copyErr != nil {
+		t.Fatalf("CopyBlob() = %v; want nil", copyErr)
+	}
+	props := commitProperties{}
+	if jsonErr := json.Unmarshal([]byte(commitBody), &props); jsonErr != nil {
+		t.Fatal(jsonErr)
+	}
+	if len(props.Actions) != 2 || props.Actions[0].Action != commitActionUpdate || props.Actions[0].FilePath != "destination" ||
+		props.Actions[1].Action != commitActionChmod || props.Actions[1].ExecuteFilemode == nil || !*props.Actions[1].ExecuteFilemode {
+		t.Errorf("actions = %s; want the destination updated and made executable like the source", commitBody)
+	}
+}
+
+func TestTypedStatusError(t *testing.T) {
+	projectMissing := `{"message":"404 Project Not Found"}`
+	fileMissing := `{"message":"404 File Not Found"}`
+	for _, test := range []struct {
+		err  error
+		want error
+	}{
+		{typedStatusError(http.StatusNotFound, projectMissing, nil), vcblobstore.ErrRepoNotFound},
+		{typedBlobStatusError(http.StatusNotFound, projectMissing, nil), vcblobstore.ErrRepoNotFound},
+		{typedBlobStatusError(http.StatusNotFound, fileMissing, nil), vcblobstore.ErrBlobNotFound},
+		{typedStatusError(http.StatusUnauthorized, "", nil), vcblobstore.ErrUnauthorized},
+		{typedStatusError(http.StatusForbidden, "", nil), vcblobstore.ErrForbidden},
+	} {
+		if !errors.Is(test.err, test.want) {
+			t.Errorf("error = %v; want %v", test.err, test.want)
+		}
+	}
+	if err := typedStatusError(http.StatusNotFound, `{"message":"404 Commit Not Found"}`, nil); errors.Is(err, vcblobstore.ErrRepoNotFound) {
+		t.Errorf("typedStatusError() = %v; want no ErrRepoNotFound for a missing commit", err)
+	}
+
+	g := newStubGitlab(func(request *http.Request) (*http.Response, error) {
+		return stubResponse(http.StatusNotFound, projectMissing), nil
+	})
+	if _, _, getErr := g.getFileAtRef(context.Background(), "a/b", "main"); !errors.Is(getErr, vcblobstore.ErrRepoNotFound) {
+		t.Errorf("getFileAtRef() = %v; want ErrRepoNotFound", getErr)
+	}
+}
+
+func TestExportAllAtOutlivesClientTimeout(t *testing.T) {
+	transport := roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+		select {
+		case <-request.Context().Done():
+			return nil, request.Context().Err()
+		case <-time.After(50 * time.Millisecond):
+		}
+		if request.URL.Query().Get("sha") != "abc" {
+			return stubResponse(http.StatusNotFound, `{"message":"404 Project Not Found"}`), nil
+		}
+		return stubResponse(http.StatusOK, "archive"), nil
+	})
+	g := newStubGitlab(transport)
+	g.clientPool.Get()
+	_, _ = g.clientPool.Put(http.Client{Transport: transport, Timeout: time.Millisecond})
+
+	archive := strings.Builder{}
+	if exportErr := g.ExportAllAt(context.Background(), "abc", &archive); exportErr != nil {
+		t.Fatalf("ExportAllAt() = %v; want nil", exportErr)
+	}
+	if archive.String() != "archive" {
+		t.Errorf("archive = %q; want the body of the response", archive.String())
+	}
+	if exportErr := g.ExportAllAt(context.Background(), "missing", io.Discard); !errors.Is(exportErr, vcblobstore.ErrRepoNotFound) {
+		t.Errorf("ExportAllAt() = %v; want ErrRepoNotFound", exportErr)
+	}
+}
+
+func TestGetBlobHistorySinceVersion(t *testing.T) {
+	commit := func(id string, date string) string {
+		return `{"id": "` + id + `", "committed_date": "` + date + `", "authored_date": "` + date + `", "message": "` + id + `"}`
+	}
+	g := newStubGitlab(func(request *http.Request) (*http.Response, error) {
+		path := request.URL.Path
+		switch {
+		case strings.HasSuffix(path, "/repository/commits/since"):
+			return stubResponse(http.StatusOK, commit("since", "2024-01-02T00:00:00Z")), nil
+		case strings.HasSuffix(path, "/diff"):
+			return stubResponse(http.StatusOK, `[{"new_path": "blob"}]`), nil
+		case strings.HasSuffix(path, "/repository/commits"):
+			if refName := request.URL.Query().Get("ref_name"); refName != "since..main" {
+				t.Errorf("ref_name = %q; want the commits of main not reachable from since", refName)
+			}
+			// The commits of the range, one of them made in the same second as since
+			return stubResponse(http.StatusOK, "["+commit("merge", "2024-01-04T00:00:00Z")+", "+commit("newer", "2024-01-03T00:00:00Z")+", "+
+				commit("same-second", "2024-01-02T00:00:00Z")+"]"), nil
+		}
+		return stubResponse(http.StatusNotFound, `{"message": "404 Commit Not Found"}`), nil
+	})
+	g.naming = vcblobstore.DefaultNaming
+
+	versions, historyErr := g.GetBlobHistory(context.Background(), "blob", vcblobstore.HistoryFilter{SinceVersion: "since"})
+	ids := []string{}
+	for _, version := range versions {
+		ids = append(ids, version.Version)
+	}
+	if historyErr != nil || !slices.Equal(ids, []string{"merge", "newer", "same-second"}) {
+		t.Errorf("GetBlobHistory() = %v, %v; want the versions committed after since", ids, historyErr)
+	}
+	if _, historyErr = g.GetBlobHistory(context.Background(), "blob", vcblobstore.HistoryFilter{SinceVersion: "unknown"}); historyErr == nil {
+		t.Error("GetBlobHistory() = nil; want the error of the unknown version")
+	}
+}
+
+func TestIterateVersionsSince(t *testing.T) {
+	commit := func(id string, committed string, authored string) string {
+		return `{"id": "` + id + `", "committed_date": "` + committed + `", "authored_date": "` + authored + `", "message": "` + id + `"}`
+	}
+	diffs := []string{}
+	g := newStubGitlab(func(request *http.Request) (*http.Response, error) {
+		path := request.URL.Path
+		switch {
+		case strings.HasSuffix(path, "/diff"):
+			diffs = append(diffs, path)
+			return stubResponse(http.StatusOK, `[{"new_path": "blob"}]`), nil
+		case strings.HasSuffix(path, "/repository/commits"):
+			if since := request.URL.Query().Get("since"); since != "2024-01-02T00:00:00Z" {
+				t.Errorf("since = %q; want the start of the audit log", since)
+			}
+			// A commit authored before since may be committed after it
+			return stubResponse(http.StatusOK, "["+commit("newer", "2024-01-03T00:00:00Z", "2024-01-03T00:00:00Z")+", "+
+				commit("rebased", "2024-01-02T12:00:00Z", "2024-01-01T00:00:00Z")+", "+commit("since", "2024-01-02T00:00:00Z", "2024-01-02T00:00:00Z")+"]"), nil
+		}
+		return stubResponse(http.StatusNotFound, `{"message": "404 Commit Not Found"}`), nil
+	})
+	g.naming = vcblobstore.DefaultNaming
+
+	ids := []string{}
+	for version, err := range g.IterateVersionsSince(context.Background(), time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)) {
+		if err != nil {
+			t.Fatalf("IterateVersionsSince() = %v; want nil", err)
+		}
+		ids = append(ids, version.Version)
+	}
+	if !slices.Equal(ids, []string{"since", "newer"}) {
+		t.Errorf("versions = %v; want the ones authored since, oldest first", ids)
+	}
+	if len(diffs) != 2 {
+		t.Errorf("diffs requested = %v; want only those of the versions yielded", diffs)
+	}
+}
+
+func TestListVersionsBatchesDiffs(t *testing.T) {
+	commits := []string{}
+	for index := 10; index > 0; index-- {
+		commits = append(commits, `{"id": "c`+strconv.Itoa(index)+`", "committed_date": "2024-01-01T00:00:00Z", "authored_date": "2024-01-01T00:00:00Z", "message": "m"}`)
+	}
+	var mutex sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	g := newStubGitlab(func(request *http.Request) (*http.Response, error) {
+		path := request.URL.Path
+		switch {
+		case strings.HasSuffix(path, "/diff"):
+			mutex.Lock()
+			inFlight++
+			maxInFlight = max(maxInFlight, inFlight)
+			mutex.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			mutex.Lock()
+			inFlight--
+			mutex.Unlock()
+			commitId := strings.Split(strings.TrimSuffix(path, "/diff"), "/commits/")[1]
+			return stubResponse(http.StatusOK, `[{"new_path": "blob-`+commitId+`", "new_file": true}]`), nil
+		case strings.HasSuffix(path, "/repository/commits"):
+			return stubResponse(http.StatusOK, "["+strings.Join(commits, ", ")+"]"), nil
+		}
+		return stubResponse(http.StatusNotFound, `{"message": "404 Commit Not Found"}`), nil
+	})
+	g.naming = vcblobstore.DefaultNaming
+	client, _ := g.clientPool.Get()
+	g.clientPool, _ = blockingQueues.NewLinkedBlockingQueue(diffBatchSize)
+	for range diffBatchSize {
+		_, _ = g.clientPool.Put(client)
+	}
+
+	versions, listErr := g.ListVersions(context.Background(), "", "")
+	if listErr != nil {
+		t.Fatalf("ListVersions() = %v; want nil", listErr)
+	}
+	for index, version := range versions {
+		key := "blob-c" + strconv.Itoa(index+1)
+		if version.Version != "c"+strconv.Itoa(index+1) || len(version.Changes) != 1 || version.Changes[0].Key != key {
+			t.Errorf("versions[%d] = %+v; want the creation of %s", index, version, key)
+		}
+	}
+	if maxInFlight < 2 || maxInFlight > diffBatchSize {
+		t.Errorf("diffs requested at a time = %d; want a batch of up to %d", maxInFlight, diffBatchSize)
+	}
+}
+
+func TestGetBlobHistoryBatchesDiffs(t *testing.T) {
+	commits := []string{}
+	for index := 10; index > 0; index-- {
+		commits = append(commits, `{"id": "c`+strconv.Itoa(index)+`", "committed_date": "2024-01-01T00:00:00Z", "authored_date": "2024-01-01T00:00:00Z", "message": "m"}`)
+	}
+	var mutex sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	g := newStubGitlab(func(request *http.Request) (*http.Response, error) {
+		path := request.URL.Path
+		switch {
+		case strings.HasSuffix(path, "/diff"):
+			mutex.Lock()
+			inFlight++
+			maxInFlight = max(maxInFlight, inFlight)
+			mutex.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			mutex.Lock()
+			inFlight--
+			mutex.Unlock()
+			// The first commit creates the blob, the rest update it
+			return stubResponse(http.StatusOK, `[{"new_path": "blob", "new_file": `+strconv.FormatBool(strings.HasSuffix(path, "/c1/diff"))+`}]`), nil
+		case strings.HasSuffix(path, "/repository/commits"):
+			return stubResponse(http.StatusOK, "["+strings.Join(commits, ", ")+"]"), nil
+		}
+		return stubResponse(http.StatusNotFound, `{"message": "404 Commit Not Found"}`), nil
+	})
+	g.naming = vcblobstore.DefaultNaming
+	client, _ := g.clientPool.Get()
+	g.clientPool, _ = blockingQueues.NewLinkedBlockingQueue(diffBatchSize)
+	for range diffBatchSize {
+		_, _ = g.clientPool.Put(client)
+	}
+
+	versions, historyErr := g.GetBlobHistory(context.Background(), "blob", vcblobstore.HistoryFilter{})
+	if historyErr != nil {
+		t.Fatalf("GetBlobHistory() = %v; want nil", historyErr)
+	}
+	if len(versions) != 10 {
+		t.Fatalf("versions = %+v; want every commit of the blob", versions)
+	}
+	for index, version := range versions {
+		id := "c" + strconv.Itoa(10-index)
+		operation := vcblobstore.BlobOperationUpdate
+		if id == "c1" {
+			operation = vcblobstore.BlobOperationCreate
+		}
+		if version.Version != id || version.Operation != operation {
+			t.Errorf("versions[%d] = %+v; want the %s of %s", index, version, operation, id)
+		}
+	}
+	if maxInFlight < 2 || maxInFlight > diffBatchSize {
+		t.Errorf("diffs requested at a time = %d; want a batch of up to %d", maxInFlight, diffBatchSize)
+	}
+
+	versions, historyErr = g.GetBlobHistory(context.Background(), "blob", vcblobstore.HistoryFilter{Operations: []vcblobstore.BlobOperation{vcblobstore.BlobOperationCreate}})
+	if historyErr != nil || len(versions) != 1 || versions[0].Version != "c1" {
+		t.Errorf("GetBlobHistory() = %+v, %v; want only the creation of the blob", versions, historyErr)
+	}
+}
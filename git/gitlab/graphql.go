@@ -0,0 +1,215 @@
+package gitlab
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"vcblobstore"
+)
+
+// graphQLBatchSize is the number of paths whose last commit is asked for in a single query
+const graphQLBatchSize = 50
+
+const treePathsQuery = `query($fullPath: ID!, $ref: String!, $path: String, $after: String) {
+  project(fullPath: $fullPath) {
+    repository {
+      paginatedTree(path: $path, ref: $ref, recursive: true, after: $after) {
+        pageInfo { hasNextPage endCursor }
+        nodes { blobs { nodes { path mode } } }
+      }
+    }
+  }
+}`
+
+type graphQLRequest struct {
+	Query     string         `json:"query"`
+	Variables map[string]any `json:"variables"`
+}
+
+type graphQLError struct {
+	Message string `json:"message"`
+}
+
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []graphQLError  `json:"errors"`
+}
+
+type treePathsResult struct {
+	Project *struct {
+		Repository struct {
+			PaginatedTree struct {
+				PageInfo struct {
+					HasNextPage bool   `json:"hasNextPage"`
+					EndCursor   string `json:"endCursor"`
+				} `json:"pageInfo"`
+				Nodes []struct {
+					Blobs struct {
+						Nodes []struct {
+							Path string `json:"path"`
+							Mode string `json:"mode"`
+						} `json:"nodes"`
+					} `json:"blobs"`
+				} `json:"nodes"`
+			} `json:"paginatedTree"`
+		} `json:"repository"`
+	} `json:"project"`
+}
+
+type lastCommit struct {
+	Sha          string `json:"sha"`
+	AuthorName   string `json:"authorName"`
+	AuthorEmail  string `json:"authorEmail"`
+	AuthoredDate string `json:"authoredDate"`
+}
+
+type lastCommitsResult struct {
+	Project *struct {
+		Repository map[string]*struct {
+			LastCommit *lastCommit `json:"lastCommit"`
+		} `json:"repository"`
+	} `json:"project"`
+}
+
+// ListBlobKeysWithVersions returns the keys matching the options with the versions their blobs were last changed in.
+// Unless Config.GraphQL is set, the version of every key takes a request of its own
+func (g *Gitlab) ListBlobKeysWithVersions(ctx context.Context, opts vcblobstore.ListOptions) ([]vcblobstore.KeyVersion, error) {
+	if !g.graphQL {
+		keys, listErr := g.ListBlobKeys(ctx, opts)
+		if listErr != nil {
+			return nil, listErr
+		}
+		keyVersions := make([]vcblobstore.KeyVersion, len(keys))
+		for index, key := range keys {
+			version, versionErr := g.GetVersionFor(ctx, key)
+			if versionErr != nil {
+				return nil, versionErr
+			}
+			keyVersions[index] = vcblobstore.KeyVersion{Key: key, Version: version}
+		}
+		return keyVersions, nil
+	}
+
+	files, filesErr := g.graphQLTreeFiles(ctx, g.sharding.ListingDirectory(g.naming, opts.Directory()))
+	if filesErr != nil {
+		return nil, filesErr
+	}
+	keyVersions := []vcblobstore.KeyVersion{}
+	keyPaths := []string{}
+	for _, file := range files {
+		key, ok := g.sharding.KeyOf(g.naming, file.Path)
+		if ok && opts.Matches(g.naming, key) && opts.MatchesMode(vcblobstore.FileMode(file.Mode)) {
+			keyVersions = append(keyVersions, vcblobstore.KeyVersion{Key: key})
+			keyPaths = append(keyPaths, file.Path)
+		}
+	}
+	for start := 0; start < len(keyPaths); start += graphQLBatchSize {
+		end := min(start+graphQLBatchSize, len(keyPaths))
+		commits, commitsErr := g.graphQLLastCommits(ctx, keyPaths[start:end])
+		if commitsErr != nil {
+			return nil, commitsErr
+		}
+		for index, commit := range commits {
+			keyVersions[start+index].Version = commit.Sha
+		}
+	}
+	return keyVersions, nil
+}
+
+// graphQLTreeFiles returns the files under the directory of the main branch
+func (g *Gitlab) graphQLTreeFiles(ctx context.Context, directory string) ([]repositoryTreeItem, error) {
+	files := []repositoryTreeItem{}
+	variables := map[string]any{
+		"fullPath": g.projectPath(),
+		"ref":      g.readBranch(ctx),
+		"path":     directory,
+	}
+	for {
+		result := treePathsResult{}
+		if queryErr := g.queryGraphQL(ctx, treePathsQuery, variables, &result); queryErr != nil {
+			return nil, fmt.Errorf("failed to list the files under %q: %w", directory, queryErr)
+		}
+		if result.Project == nil {
+			return nil, fmt.Errorf("failed to list the files under %q: %w", directory, vcblobstore.ErrRepoNotFound)
+		}
+		tree := result.Project.Repository.PaginatedTree
+		for _, node := range tree.Nodes {
+			for _, blob := range node.Blobs.Nodes {
+				files = append(files, repositoryTreeItem{Type: "blob", Path: blob.Path, Mode: blob.Mode})
+			}
+		}
+		if !tree.PageInfo.HasNextPage {
+			return files, nil
+		}
+		variables["after"] = tree.PageInfo.EndCursor
+	}
+}
+
+// graphQLLastCommits returns the last commits of the main branch changing the paths, in the order of the paths.
+// The commit of a path without one is the zero value
+func (g *Gitlab) graphQLLastCommits(ctx context.Context, paths []string) ([]lastCommit, error) {
+	var query strings.Builder
+	query.WriteString("query($fullPath: ID!, $ref: String!")
+	for index := range paths {
+		fmt.Fprintf(&query, ", $p%d: String", index)
+	}
+	query.WriteString(") {\n  project(fullPath: $fullPath) {\n    repository {\n")
+	variables := map[string]any{
+		"fullPath": g.projectPath(),
+		"ref":      g.readBranch(ctx),
+	}
+	for index, path := range paths {
+		fmt.Fprintf(&query, "      p%d: tree(path: $p%d, ref: $ref) { lastCommit { sha authorName authorEmail authoredDate } }\n", index, index)
+		variables[fmt.Sprintf("p%d", index)] = path
+	}
+	query.WriteString("    }\n  }\n}")
+
+	result := lastCommitsResult{}
+	if queryErr := g.queryGraphQL(ctx, query.String(), variables, &result); queryErr != nil {
+		return nil, fmt.Errorf("failed to get the last commits of %d files: %w", len(paths), queryErr)
+	}
+	if result.Project == nil {
+		return nil, fmt.Errorf("failed to get the last commits of %d files: %w", len(paths), vcblobstore.ErrRepoNotFound)
+	}
+	commits := make([]lastCommit, len(paths))
+	for index := range paths {
+		if tree := result.Project.Repository[fmt.Sprintf("p%d", index)]; tree != nil && tree.LastCommit != nil {
+			commits[index] = *tree.LastCommit
+		}
+	}
+	return commits, nil
+}
+
+// queryGraphQL sends the query to the GraphQL API and unmarshals the data of the response into result
+func (g *Gitlab) queryGraphQL(ctx context.Context, query string, variables map[string]any, result any) error {
+	if capabilityErr := g.credentials.check(capabilityReadRepository); capabilityErr != nil {
+		return capabilityErr
+	}
+	requestBody, marshalErr := json.Marshal(graphQLRequest{Query: query, Variables: variables})
+	if marshalErr != nil {
+		return fmt.Errorf("failed to marshal GraphQL query: %w", marshalErr)
+	}
+	statusCode, _, body, err := g.sendRequestTo(ctx, "POST", "https://gitlab.com/api/graphql", bytes.NewReader(requestBody))
+	if err != nil || statusCode != http.StatusOK {
+		return fmt.Errorf("failed to query GitLab GraphQL API: (%d) %s -- %w", statusCode, body, typedStatusError(statusCode, body, err))
+	}
+
+	response := graphQLResponse{}
+	if jsonErr := json.Unmarshal([]byte(body), &response); jsonErr != nil {
+		return fmt.Errorf("failed to unmarshal GraphQL response: %w", jsonErr)
+	}
+	if len(response.Errors) > 0 {
+		messages := make([]string, len(response.Errors))
+		for index, queryErr := range response.Errors {
+			messages[index] = queryErr.Message
+		}
+		return fmt.Errorf("failed to query GitLab GraphQL API: %s", strings.Join(messages, "; "))
+	}
+	if jsonErr := json.Unmarshal(response.Data, result); jsonErr != nil {
+		return fmt.Errorf("failed to unmarshal GraphQL data: %w", jsonErr)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"vcblobstore"
+)
+
+func TestListBlobKeysWithVersionsThroughGraphQL(t *testing.T) {
+	queries := 0
+	g := newStubGitlab(func(request *http.Request) (*http.Response, error) {
+		queries++
+		query := graphQLRequest{}
+		if decodeErr := json.NewDecoder(request.Body).Decode(&query); decodeErr != nil || request.URL.Path != "/api/graphql" {
+			return stubResponse(http.StatusBadRequest, ""), nil
+		}
+		if strings.Contains(query.Query, "paginatedTree") {
+			if query.Variables["after"] == nil {
+				return stubResponse(http.StatusOK, `{"data": {"project": {"repository": {"paginatedTree": {
+					"pageInfo": {"hasNextPage": true, "endCursor": "next"},
+					"nodes": [{"blobs": {"nodes": [{"path": "a"}, {"path": ".vcblobstore/metadata/a"}]}}]}}}}}`), nil
+			}
+			return stubResponse(http.StatusOK, `{"data": {"project": {"repository": {"paginatedTree": {
+				"pageInfo": {"hasNextPage": false, "endCursor": ""},
+				"nodes": [{"blobs": {"nodes": [{"path": "b"}]}}]}}}}}`), nil
+		}
+		if query.Variables["p0"] != "a" || query.Variables["p1"] != "b" {
+			return stubResponse(http.StatusOK, `{"errors": [{"message": "unexpected paths"}]}`), nil
+		}
+		return stubResponse(http.StatusOK, `{"data": {"project": {"repository": {
+			"p0": {"lastCommit": {"sha": "commit-a"}},
+			"p1": {"lastCommit": {"sha": "commit-b"}}}}}}`), nil
+	})
+	g.naming = vcblobstore.NamingOrDefault(nil)
+	g.graphQL = true
+
+	keyVersions, listErr := g.ListBlobKeysWithVersions(context.Background(), vcblobstore.ListOptions{})
+	if listErr != nil {
+		t.Fatalf("ListBlobKeysWithVersions() = %v; want nil", listErr)
+	}
+	want := []vcblobstore.KeyVersion{{Key: "a", Version: "commit-a"}, {Key: "b", Version: "commit-b"}}
+	if len(keyVersions) != len(want) || keyVersions[0] != want[0] || keyVersions[1] != want[1] {
+		t.Errorf("ListBlobKeysWithVersions() = %v; want %v", keyVersions, want)
+	}
+	if queries != 3 {
+		t.Errorf("queries = %d; want 3", queries)
+	}
+}
+
+func TestGetBlobAnnotationsThroughGraphQL(t *testing.T) {
+	g := newStubGitlab(func(request *http.Request) (*http.Response, error) {
+		query := graphQLRequest{}
+		if decodeErr := json.NewDecoder(request.Body).Decode(&query); decodeErr != nil || query.Variables["p0"] != "a" || query.Variables["p1"] != "b" {
+			return stubResponse(http.StatusBadRequest, ""), nil
+		}
+		return stubResponse(http.StatusOK, `{"data": {"project": {"repository": {
+			"p0": {"lastCommit": {"sha": "commit-a", "authorName": "Zazie", "authorEmail": "zazie@metro.example", "authoredDate": "2024-05-01T10:00:00+02:00"}},
+			"p1": null}}}}`), nil
+	})
+	g.naming = vcblobstore.NamingOrDefault(nil)
+	g.graphQL = true
+
+	annotations, annotateErr := g.GetBlobAnnotations(context.Background(), []string{"a", "b"})
+	if annotateErr != nil {
+		t.Fatalf("GetBlobAnnotations() = %v; want nil", annotateErr)
+	}
+	if len(annotations) != 2 {
+		t.Fatalf("len(annotations) = %d; want 2", len(annotations))
+	}
+	if annotations[0].Version != "commit-a" || annotations[0].Author != "Zazie <zazie@metro.example>" || annotations[0].AuthorDate.Hour() != 10 {
+		t.Errorf("annotations[0] = %+v; want the last commit of a", annotations[0])
+	}
+	if annotations[1] != (vcblobstore.BlobAnnotation{Key: "b"}) {
+		t.Errorf("annotations[1] = %+v; want no version", annotations[1])
+	}
+}
@@ -0,0 +1,174 @@
+package gitlab
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"vcblobstore"
+)
+
+const lfsMediaType = "application/vnd.git-lfs+json"
+
+type lfsAction struct {
+	Href   string            `json:"href"`
+	Header map[string]string `json:"header,omitempty"`
+}
+
+type lfsObjectError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type lfsBatchObject struct {
+	OID     string               `json:"oid"`
+	Size    int64                `json:"size"`
+	Actions map[string]lfsAction `json:"actions,omitempty"`
+	Error   *lfsObjectError      `json:"error,omitempty"`
+}
+
+type lfsBatchRequest struct {
+	Operation string           `json:"operation"`
+	Transfers []string         `json:"transfers"`
+	Objects   []lfsBatchObject `json:"objects"`
+}
+
+type lfsBatchResponse struct {
+	Objects []lfsBatchObject `json:"objects"`
+}
+
+// lfsObjects keeps the LFS objects in the LFS storage of the project, transferring them as the LFS batch API says
+type lfsObjects struct {
+	g *Gitlab
+}
+
+func (objects lfsObjects) Upload(ctx context.Context, pointer vcblobstore.LFSPointer, content []byte) error {
+	object, batchErr := objects.g.lfsBatch(ctx, "upload", pointer)
+	if batchErr != nil {
+		return batchErr
+	}
+	upload, uploadNeeded := object.Actions["upload"]
+	if !uploadNeeded {
+		return nil
+	}
+	if _, uploadErr := objects.g.lfsTransfer(ctx, http.MethodPut, upload, "application/octet-stream", content); uploadErr != nil {
+		return fmt.Errorf("failed to upload LFS object %s: %w", pointer.OID, uploadErr)
+	}
+	if verify, verifyNeeded := object.Actions["verify"]; verifyNeeded {
+		verifyBody, marshalErr := json.Marshal(lfsBatchObject{OID: pointer.OID, Size: pointer.Size})
+		if marshalErr != nil {
+			return fmt.Errorf("failed to marshal verification of LFS object %s: %w", pointer.OID, marshalErr)
+		}
+		if _, verifyErr := objects.g.lfsTransfer(ctx, http.MethodPost, verify, lfsMediaType, verifyBody); verifyErr != nil {
+			return fmt.Errorf("failed to verify LFS object %s: %w", pointer.OID, verifyErr)
+		}
+	}
+	return nil
+}
+
+func (objects lfsObjects) Download(ctx context.Context, pointer vcblobstore.LFSPointer) ([]byte, error) {
+	object, batchErr := objects.g.lfsBatch(ctx, "download", pointer)
+	if batchErr != nil {
+		return nil, batchErr
+	}
+	download, found := object.Actions["download"]
+	if !found {
+		return nil, fmt.Errorf("%s: %w", pointer.OID, vcblobstore.ErrLFSObjectMissing)
+	}
+	return objects.g.lfsTransfer(ctx, http.MethodGet, download, "", nil)
+}
+
+func (g *Gitlab) offloadLFS(ctx context.Context, key string, content []byte) ([]byte, error) {
+	return g.lfs.Offload(ctx, lfsObjects{g}, key, content)
+}
+
+func (g *Gitlab) resolveLFS(ctx context.Context, content []byte) ([]byte, error) {
+	return g.lfs.Resolve(ctx, lfsObjects{g}, content)
+}
+
+// lfsBatch asks the LFS batch API of the project how to transfer the object
+func (g *Gitlab) lfsBatch(ctx context.Context, operation string, pointer vcblobstore.LFSPointer) (lfsBatchObject, error) {
+	requestBody, marshalErr := json.Marshal(lfsBatchRequest{
+		Operation: operation,
+		Transfers: []string{"basic"},
+		Objects:   []lfsBatchObject{{OID: pointer.OID, Size: pointer.Size}},
+	})
+	if marshalErr != nil {
+		return lfsBatchObject{}, fmt.Errorf("failed to marshal LFS batch request: %w", marshalErr)
+	}
+	if capabilityErr := g.credentials.check(capabilityLFS); capabilityErr != nil {
+		return lfsBatchObject{}, capabilityErr
+	}
+
+	batch := lfsAction{
+		Href:   fmt.Sprintf("https://gitlab.com/%s.git/info/lfs/objects/batch", g.projectPath()),
+		Header: map[string]string{"Accept": lfsMediaType},
+	}
+	responseBody, batchErr := g.lfsTransfer(ctx, http.MethodPost, batch, lfsMediaType, requestBody, func(request *http.Request) error {
+		return g.credentials.authorizeGit(ctx, request)
+	})
+	if batchErr != nil {
+		return lfsBatchObject{}, fmt.Errorf("failed to %s LFS object %s: %w", operation, pointer.OID, batchErr)
+	}
+
+	response := lfsBatchResponse{}
+	if jsonErr := json.Unmarshal(responseBody, &response); jsonErr != nil {
+		return lfsBatchObject{}, fmt.Errorf("failed to unmarshal LFS batch response: %w", jsonErr)
+	}
+	if len(response.Objects) != 1 {
+		return lfsBatchObject{}, fmt.Errorf("failed to %s LFS object %s: %d objects in batch response", operation, pointer.OID, len(response.Objects))
+	}
+	object := response.Objects[0]
+	if object.Error != nil {
+		if object.Error.Code == http.StatusNotFound {
+			return lfsBatchObject{}, fmt.Errorf("%s: %w", pointer.OID, vcblobstore.ErrLFSObjectMissing)
+		}
+		return lfsBatchObject{}, fmt.Errorf("failed to %s LFS object %s: (%d) %s", operation, pointer.OID, object.Error.Code, object.Error.Message)
+	}
+	return object, nil
+}
+
+// lfsTransfer sends the request the action describes and returns the body of the response
+func (g *Gitlab) lfsTransfer(ctx context.Context, method string, action lfsAction, contentType string, body []byte, prepare ...func(request *http.Request) error) ([]byte, error) {
+	poolItem, _ := g.clientPool.Get()
+	defer func() {
+		_, _ = g.clientPool.Put(poolItem)
+	}()
+	client, ok := poolItem.(http.Client)
+	if !ok {
+		return nil, errors.New("type asssertion error")
+	}
+
+	request, requestCreationErr := http.NewRequestWithContext(ctx, method, action.Href, bytes.NewReader(body))
+	if requestCreationErr != nil {
+		return nil, fmt.Errorf("failed to create request: %w", requestCreationErr)
+	}
+	for name, value := range action.Header {
+		request.Header.Set(name, value)
+	}
+	if len(contentType) > 0 {
+		request.Header.Set("Content-Type", contentType)
+	}
+	for _, prepareRequest := range prepare {
+		if prepareErr := prepareRequest(request); prepareErr != nil {
+			return nil, prepareErr
+		}
+	}
+
+	response, requestErr := client.Do(request)
+	if requestErr != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", requestErr)
+	}
+	defer response.Body.Close()
+	responseBody, readErr := io.ReadAll(response.Body)
+	if readErr != nil {
+		return nil, fmt.Errorf("failed to read body: %w", readErr)
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, fmt.Errorf("(%d) %s -- %w", response.StatusCode, responseBody, typedStatusError(response.StatusCode, string(responseBody), nil))
+	}
+	return responseBody, nil
+}
@@ -0,0 +1,258 @@
+package gitlab
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+	"vcblobstore"
+)
+
+// mergeabilityChecks is the number of times a merge request is looked up while GitLab is checking whether it can be
+// merged, mergeabilityCheckInterval apart
+const mergeabilityChecks = 20
+
+var mergeabilityCheckInterval = 500 * time.Millisecond
+
+type mergeRequest struct {
+	IID                 int    `json:"iid"`
+	DetailedMergeStatus string `json:"detailed_merge_status"`
+	HasConflicts        bool   `json:"has_conflicts"`
+}
+
+type mergeBaseResponse struct {
+	Id string `json:"id"`
+}
+
+type acceptMergeRequestProperties struct {
+	MergeCommitMessage       string `json:"merge_commit_message"`
+	ShouldRemoveSourceBranch bool   `json:"should_remove_source_branch"`
+}
+
+// MergeBranch merges the branch from into the branch to through a merge request, which GitLab merges as the merge
+// method of the project says (with a merge commit by default): the REST API can't move a branch otherwise. So
+// MergeFastForward only refuses diverged branches up front, and a fast-forward takes the "ff" merge method.
+// Before merging diverged branches, the derived entries (the attribute index, the store metadata) changed on both are
+// merged by their meaning and committed to both branches, so that GitLab doesn't see them as conflicting
+func (g *Gitlab) MergeBranch(ctx context.Context, from string, to string, modifiedBy string) error {
+	mergeErr := g.mergeBranch(ctx, from, to, modifiedBy)
+	if mergeErr != nil {
+		return fmt.Errorf("failed to merge branch %s into %s in GitLab repo: %w", from, to, mergeErr)
+	}
+	return nil
+}
+
+func (g *Gitlab) mergeBranch(ctx context.Context, from string, to string, modifiedBy string) error {
+	fromHead, fromErr := g.branchHead(ctx, from)
+	if fromErr != nil {
+		return fromErr
+	}
+	toHead, toErr := g.branchHead(ctx, to)
+	if toErr != nil {
+		return toErr
+	}
+	query := url.Values{"refs[]": {fromHead, toHead}}
+	statusCode, _, body, err := g.sendRequest(ctx, "GET", fmt.Sprintf("/projects/%s/repository/merge_base?%s", g.escapedProjectPath(), query.Encode()), nil)
+	if err != nil || statusCode != http.StatusOK {
+		return fmt.Errorf("failed to get the merge base of %s and %s: (%d) %s -- %w", from, to, statusCode, body, typedStatusError(statusCode, body, err))
+	}
+	mergeBase := mergeBaseResponse{}
+	if jsonErr := json.Unmarshal([]byte(body), &mergeBase); jsonErr != nil {
+		return fmt.Errorf("failed to unmarshal the merge base of %s and %s: %w", from, to, jsonErr)
+	}
+	if mergeBase.Id == fromHead {
+		return nil
+	}
+	if mergeBase.Id != toHead && vcblobstore.MergeStrategyOf(ctx) == vcblobstore.MergeFastForward {
+		return vcblobstore.ErrNotFastForward
+	}
+
+	if mergeBase.Id != toHead {
+		if alignErr := g.alignDerivedEntries(ctx, from, to, mergeBase.Id, fromHead, toHead, modifiedBy); alignErr != nil {
+			return alignErr
+		}
+	}
+
+	request, openErr := g.openMergeRequest(ctx, from, to)
+	if openErr != nil {
+		return openErr
+	}
+	request, checkErr := g.awaitMergeability(ctx, request.IID)
+	if checkErr != nil {
+		return checkErr
+	}
+	if request.HasConflicts {
+		return g.mergeConflict(ctx, from, to, mergeBase.Id, fromHead, toHead)
+	}
+
+	requestBody, marshalErr := json.Marshal(acceptMergeRequestProperties{
+		MergeCommitMessage: fmt.Sprintf("merge branch %s into %s by %s", from, to, modifiedBy),
+	})
+	if marshalErr != nil {
+		return fmt.Errorf("failed to marshal merge request acceptance: %w", marshalErr)
+	}
+	statusCode, _, body, err = g.sendRequest(ctx, "PUT", fmt.Sprintf("/projects/%s/merge_requests/%d/merge", g.escapedProjectPath(), request.IID), bytes.NewReader(requestBody))
+	if err == nil && (statusCode == http.StatusMethodNotAllowed || statusCode == http.StatusNotAcceptable || statusCode == http.StatusConflict || statusCode == http.StatusUnprocessableEntity) {
+		return fmt.Errorf("merge request !%d can't be merged: (%d) %s -- %w", request.IID, statusCode, body, vcblobstore.ErrConflict)
+	}
+	if err != nil || statusCode != http.StatusOK {
+		return fmt.Errorf("failed to merge merge request !%d: (%d) %s -- %w", request.IID, statusCode, body, typedStatusError(statusCode, body, err))
+	}
+	return nil
+}
+
+// alignDerivedEntries commits the derived entries changed differently on both branches since their merge base, merged
+// by vcblobstore.MergeDerivedEntry, to both branches
+func (g *Gitlab) alignDerivedEntries(ctx context.Context, from string, to string, mergeBase string, fromHead string, toHead string, modifiedBy string) error {
+	actions := map[string][]commitActionOnByteSlice{}
+	for _, key := range []string{g.naming.AttributeIndexKey(), g.naming.StoreMetadataKey()} {
+		contents := map[string][]byte{}
+		for _, commit := range []string{mergeBase, fromHead, toHead} {
+			content, _, readErr := g.getBlobAtRef(ctx, key, commit)
+			if readErr != nil {
+				return readErr
+			}
+			contents[commit] = content
+		}
+		base, fromContent, toContent := contents[mergeBase], contents[fromHead], contents[toHead]
+		if bytes.Equal(base, fromContent) || bytes.Equal(base, toContent) || bytes.Equal(fromContent, toContent) {
+			continue
+		}
+		merged, mergeErr := vcblobstore.MergeDerivedEntry(g.naming, key, base, toContent, fromContent)
+		if mergeErr != nil {
+			return mergeErr
+		}
+		for branch, content := range map[string][]byte{from: fromContent, to: toContent} {
+			switch {
+			case merged == nil && content != nil:
+				actions[branch] = append(actions[branch], commitActionOnByteSlice{Action: commitActionDelete, FilePath: key})
+			case merged != nil && content == nil:
+				actions[branch] = append(actions[branch], commitActionOnByteSlice{Action: commitActionCreate, FilePath: key, Content: merged})
+			case merged != nil:
+				actions[branch] = append(actions[branch], commitActionOnByteSlice{Action: commitActionUpdate, FilePath: key, Content: merged})
+			}
+		}
+	}
+
+	for _, branch := range []string{to, from} {
+		if len(actions[branch]) == 0 {
+			continue
+		}
+		commitMessage := fmt.Sprintf("Merging the derived entries of %s and %s", from, to)
+		if commitErr := g.commitAtomically(vcblobstore.WithBranch(ctx, branch), vcblobstore.Author{Name: modifiedBy}, commitMessage, actions[branch]); commitErr != nil {
+			return fmt.Errorf("failed to merge the derived entries of %s and %s: %w", from, to, commitErr)
+		}
+	}
+	return nil
+}
+
+// branchHead returns the ID of the last commit of the branch
+func (g *Gitlab) branchHead(ctx context.Context, branch string) (string, error) {
+	statusCode, _, body, err := g.sendRequest(ctx, "GET", fmt.Sprintf("/projects/%s/repository/branches/%s", g.escapedProjectPath(), url.PathEscape(branch)), nil)
+	if err == nil && statusCode == http.StatusNotFound {
+		return "", fmt.Errorf("no such branch %s", branch)
+	}
+	if err != nil || statusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to look up branch %s: (%d) %s -- %w", branch, statusCode, body, typedStatusError(statusCode, body, err))
+	}
+	head := branchResponse{}
+	if jsonErr := json.Unmarshal([]byte(body), &head); jsonErr != nil {
+		return "", fmt.Errorf("failed to unmarshal branch %s: %w", branch, jsonErr)
+	}
+	return head.Commit.Id, nil
+}
+
+// openMergeRequest opens a merge request of the branch from into the branch to, or returns the one already open
+func (g *Gitlab) openMergeRequest(ctx context.Context, from string, to string) (mergeRequest, error) {
+	requestBody, marshalErr := json.Marshal(mergeRequestProperties{
+		SourceBranch: from,
+		TargetBranch: to,
+		Title:        fmt.Sprintf("Merge %s into %s", from, to),
+	})
+	if marshalErr != nil {
+		return mergeRequest{}, fmt.Errorf("failed to marshal merge request: %w", marshalErr)
+	}
+	statusCode, _, body, err := g.sendRequest(ctx, "POST", fmt.Sprintf("/projects/%s/merge_requests", g.escapedProjectPath()), bytes.NewReader(requestBody))
+	if err == nil && statusCode == http.StatusConflict {
+		query := url.Values{"state": {"opened"}, "source_branch": {from}, "target_branch": {to}}
+		statusCode, _, body, err = g.sendRequest(ctx, "GET", fmt.Sprintf("/projects/%s/merge_requests?%s", g.escapedProjectPath(), query.Encode()), nil)
+		if err != nil || statusCode != http.StatusOK {
+			return mergeRequest{}, fmt.Errorf("failed to look up the open merge request of %s into %s: (%d) %s -- %w", from, to, statusCode, body, typedStatusError(statusCode, body, err))
+		}
+		requests := []mergeRequest{}
+		if jsonErr := json.Unmarshal([]byte(body), &requests); jsonErr != nil || len(requests) == 0 {
+			return mergeRequest{}, fmt.Errorf("failed to find the open merge request of %s into %s: %s", from, to, body)
+		}
+		return requests[0], nil
+	}
+	if err != nil || statusCode != http.StatusCreated {
+		return mergeRequest{}, fmt.Errorf("failed to open merge request of %s into %s: (%d) %s -- %w", from, to, statusCode, body, typedStatusError(statusCode, body, err))
+	}
+	request := mergeRequest{}
+	if jsonErr := json.Unmarshal([]byte(body), &request); jsonErr != nil {
+		return mergeRequest{}, fmt.Errorf("failed to unmarshal merge request of %s into %s: %w", from, to, jsonErr)
+	}
+	return request, nil
+}
+
+// awaitMergeability looks up the merge request until GitLab is done checking whether it can be merged
+func (g *Gitlab) awaitMergeability(ctx context.Context, iid int) (mergeRequest, error) {
+	request := mergeRequest{}
+	for check := 0; check < mergeabilityChecks; check++ {
+		statusCode, _, body, err := g.sendRequest(ctx, "GET", fmt.Sprintf("/projects/%s/merge_requests/%d", g.escapedProjectPath(), iid), nil)
+		if err != nil || statusCode != http.StatusOK {
+			return request, fmt.Errorf("failed to look up merge request !%d: (%d) %s -- %w", iid, statusCode, body, typedStatusError(statusCode, body, err))
+		}
+		if jsonErr := json.Unmarshal([]byte(body), &request); jsonErr != nil {
+			return request, fmt.Errorf("failed to unmarshal merge request !%d: %w", iid, jsonErr)
+		}
+		switch request.DetailedMergeStatus {
+		case "unchecked", "checking", "preparing", "approvals_syncing":
+		default:
+			return request, nil
+		}
+		select {
+		case <-ctx.Done():
+			return request, ctx.Err()
+		case <-time.After(mergeabilityCheckInterval):
+		}
+	}
+	return request, fmt.Errorf("merge request !%d is still being checked by GitLab", iid)
+}
+
+// mergeConflict returns the vcblobstore.MergeConflictError of the merge listing the keys changed on both branches
+// since their merge base: GitLab doesn't tell the conflicting files through its API. If no blob changed on both, the
+// merge conflicts on something else and a plain vcblobstore.ErrConflict is returned
+func (g *Gitlab) mergeConflict(ctx context.Context, from string, to string, mergeBase string, fromHead string, toHead string) error {
+	changed := []map[string]bool{}
+	for _, head := range []string{fromHead, toHead} {
+		diffItems, compareErr := g.compare(ctx, mergeBase, head)
+		if compareErr != nil {
+			return compareErr
+		}
+		version := vcblobstore.RepositoryVersion{}
+		for _, diffItem := range diffItems {
+			g.addDiffItemChanges(&version, diffItem)
+		}
+		keys := map[string]bool{}
+		for _, change := range version.Changes {
+			keys[change.Key] = true
+		}
+		changed = append(changed, keys)
+	}
+	conflict := &vcblobstore.MergeConflictError{From: from, To: to, Keys: []string{}}
+	for key := range changed[0] {
+		if changed[1][key] {
+			conflict.Keys = append(conflict.Keys, key)
+		}
+	}
+	if len(conflict.Keys) == 0 {
+		return fmt.Errorf("%w: merging %s into %s conflicts, but no blob changed on both branches", vcblobstore.ErrConflict, from, to)
+	}
+	sort.Strings(conflict.Keys)
+	return conflict
+}
@@ -0,0 +1,100 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+	"vcblobstore"
+
+	"github.com/rs/zerolog"
+)
+
+const defaultMaxAttempts = 3
+
+const defaultRetryBaseDelay = 500 * time.Millisecond
+
+// maxRetryDelay caps the exponential backoff
+const maxRetryDelay = 30 * time.Second
+
+// retryPolicy decides whether and when to resend a request failed with 429, or a read failed with a 5xx status
+type retryPolicy struct {
+	maxAttempts int
+	baseDelay   time.Duration
+}
+
+func newRetryPolicy(maxAttempts int, baseDelay time.Duration) retryPolicy {
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	if baseDelay <= 0 {
+		baseDelay = defaultRetryBaseDelay
+	}
+	return retryPolicy{maxAttempts: maxAttempts, baseDelay: baseDelay}
+}
+
+// retryableStatus tells whether the request may be resent after the status. GitLab rejects a request with 429
+// before processing it, but a 5xx may come after a write took effect: resending a commit POST (or a PUT or DELETE
+// of a file, each a commit too) could then commit it twice, so only reads are retried on server errors
+func retryableStatus(method string, statusCode int) bool {
+	if statusCode == http.StatusTooManyRequests {
+		return true
+	}
+	return statusCode >= http.StatusInternalServerError && (method == http.MethodGet || method == http.MethodHead)
+}
+
+// backoff returns the delay before the attempt (2 for the first retry): the base delay doubled for each further
+// retry, with its upper half jittered
+func (policy retryPolicy) backoff(attempt int) time.Duration {
+	delay := min(policy.baseDelay<<(attempt-2), maxRetryDelay)
+	if delay <= 0 {
+		delay = maxRetryDelay
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// delay returns how long to wait before the attempt after a failed one. A 429 response leaves the waiting for
+// its Retry-After to the throttle
+func (policy retryPolicy) delay(attempt int, header http.Header, err error) time.Duration {
+	if retryAfter, limited := vcblobstore.RetryAfter(err); limited && retryAfter > 0 {
+		return 0
+	}
+	if retryAfter, parseErr := strconv.ParseInt(header.Get("Retry-After"), 10, 64); parseErr == nil && retryAfter >= 0 {
+		return time.Duration(retryAfter) * time.Second
+	}
+	return policy.backoff(attempt)
+}
+
+// sendRequestTo sends the request to the URL, resending it while it fails with a retryable status
+func (g *Gitlab) sendRequestTo(ctx context.Context, method string, urlString string, body io.Reader) (int, http.Header, string, error) {
+	var bodyBytes []byte
+	if body != nil {
+		var readErr error
+		if bodyBytes, readErr = io.ReadAll(body); readErr != nil {
+			return 0, nil, "", fmt.Errorf("failed to read request body: %w", readErr)
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		statusCode, header, responseBody, err := g.sendAttempt(ctx, method, urlString, bytesReader(bodyBytes))
+		if !retryableStatus(method, statusCode) || attempt >= g.retry.maxAttempts {
+			return statusCode, header, responseBody, err
+		}
+
+		delay := g.retry.delay(attempt+1, header, err)
+		if delay > g.throttle.maxWait {
+			return statusCode, header, responseBody, err
+		}
+		zerolog.Ctx(ctx).Warn().Str("url", urlString).Int("statusCode", statusCode).Int("attempt", attempt).Dur("delay", delay).Msg("retrying GitLab request")
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return statusCode, header, responseBody, fmt.Errorf("failed to wait for retrying the request: %w", ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	"vcblobstore"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := newRetryPolicy(5, 100*time.Millisecond)
+	for attempt, maxDelay := range map[int]time.Duration{2: 100 * time.Millisecond, 3: 200 * time.Millisecond, 4: 400 * time.Millisecond} {
+		if delay := policy.delay(attempt, http.Header{}, nil); delay < maxDelay/2 || delay > maxDelay {
+			t.Errorf("delay(%d) = %s; want between %s and %s", attempt, delay, maxDelay/2, maxDelay)
+		}
+	}
+
+	header := http.Header{}
+	header.Set("Retry-After", "7")
+	if delay := policy.delay(2, header, nil); delay != 7*time.Second {
+		t.Errorf("delay() = %s; want the 7s of Retry-After", delay)
+	}
+	if delay := policy.delay(2, header, &vcblobstore.RateLimitError{RetryAfter: 7 * time.Second}); delay != 0 {
+		t.Errorf("delay() = %s; want 0 leaving the wait to the throttle", delay)
+	}
+}
+
+func TestSendRequestRetries(t *testing.T) {
+	statuses := []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK}
+	attempts := 0
+	g := newStubGitlab(func(request *http.Request) (*http.Response, error) {
+		status := statuses[attempts]
+		attempts++
+		return stubResponse(status, "{}"), nil
+	})
+	g.retry = newRetryPolicy(3, time.Millisecond)
+
+	statusCode, _, _, err := g.sendRequest(context.Background(), "GET", "/projects/group%2Fproject", nil)
+	if err != nil || statusCode != http.StatusOK || attempts != 3 {
+		t.Errorf("sendRequest() = %d, %v after %d attempts; want 200 after 3 attempts", statusCode, err, attempts)
+	}
+
+	attempts = 0
+	statuses = []int{http.StatusTooManyRequests, http.StatusTooManyRequests}
+	g.retry = newRetryPolicy(2, time.Millisecond)
+	_, _, _, err = g.sendRequest(context.Background(), "GET", "/projects/group%2Fproject", nil)
+	if !errors.Is(err, vcblobstore.ErrRateLimited) || attempts != 2 {
+		t.Errorf("sendRequest() = %v after %d attempts; want ErrRateLimited after 2 attempts", err, attempts)
+	}
+
+	attempts = 0
+	statuses = []int{http.StatusBadGateway, http.StatusCreated}
+	statusCode, _, _, _ = g.sendRequest(context.Background(), "POST", "/projects/group%2Fproject/repository/commits", strings.NewReader("{}"))
+	if statusCode != http.StatusBadGateway || attempts != 1 {
+		t.Errorf("sendRequest() = %d after %d attempts; want the 502 of the commit not resent", statusCode, attempts)
+	}
+}
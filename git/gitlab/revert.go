@@ -0,0 +1,76 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"vcblobstore"
+)
+
+// RevertToState commits the files of the commit on top of the main branch: the differences between the commit and
+// the main branch are undone file by file. The differences are found by comparing the blob IDs of the trees of both,
+// since the compare endpoint truncates the diffs of large changes
+func (g *Gitlab) RevertToState(ctx context.Context, stateID string, modifiedBy string) error {
+	stateFiles, stateErr := g.treeFilesAt(ctx, stateID)
+	if stateErr != nil {
+		return fmt.Errorf("failed to revert GitLab repository to state %s: %w", stateID, stateErr)
+	}
+	headFiles, headErr := g.treeFilesAt(ctx, g.readBranch(ctx))
+	if headErr != nil {
+		return fmt.Errorf("failed to revert GitLab repository to state %s: %w", stateID, headErr)
+	}
+
+	actions := []commitActionOnByteSlice{}
+	for _, path := range slices.Sorted(maps.Keys(headFiles)) {
+		if _, kept := stateFiles[path]; !kept {
+			actions = append(actions, commitActionOnByteSlice{Action: commitActionDelete, FilePath: path})
+		}
+	}
+	for _, path := range slices.Sorted(maps.Keys(stateFiles)) {
+		stateItem := stateFiles[path]
+		headItem, exists := headFiles[path]
+		if !exists || headItem.Id != stateItem.Id {
+			content, found, getErr := g.getFileAtRef(ctx, path, stateID)
+			if getErr != nil {
+				return fmt.Errorf("failed to revert GitLab repository to state %s: %w", stateID, getErr)
+			}
+			if !found {
+				return fmt.Errorf("failed to revert GitLab repository to state %s: %s is missing", stateID, path)
+			}
+			action := commitActionOnByteSlice{Action: commitActionUpdate, FilePath: path, Content: content, group: path}
+			if !exists {
+				action.Action = commitActionCreate
+			}
+			actions = append(actions, action)
+		}
+		executable := vcblobstore.FileMode(stateItem.Mode) == vcblobstore.FileModeExecutable
+		if exists && headItem.Mode != stateItem.Mode || !exists && executable {
+			actions = append(actions, commitActionOnByteSlice{Action: commitActionChmod, FilePath: path, ExecuteFilemode: &executable, group: path})
+		}
+	}
+	if len(actions) == 0 {
+		return nil
+	}
+
+	commitErr := g.commitAtomically(ctx, vcblobstore.Author{Name: modifiedBy}, fmt.Sprintf("Reverting to state %s", stateID), actions)
+	g.storeMetadata.Invalidate()
+	if commitErr != nil {
+		return fmt.Errorf("failed to revert GitLab repository to state %s: %w", stateID, commitErr)
+	}
+	return nil
+}
+
+// treeFilesAt returns the files of the tree of the ref by their paths
+func (g *Gitlab) treeFilesAt(ctx context.Context, ref string) (map[string]repositoryTreeItem, error) {
+	files := map[string]repositoryTreeItem{}
+	for treeItem, err := range g.iterateRepositoryTreeAt(ctx, ref, "") {
+		if err != nil {
+			return nil, err
+		}
+		if treeItem.Type == "blob" {
+			files[treeItem.Path] = treeItem
+		}
+	}
+	return files, nil
+}
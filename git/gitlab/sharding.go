@@ -0,0 +1,46 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"vcblobstore"
+
+	"github.com/rs/zerolog"
+)
+
+// ShardKeys migrates the blobs of a flat (or differently sharded) layout to the configured sharding scheme
+// in a single commit and returns the number of blobs moved. Blobs already in place are left alone
+func (g *Gitlab) ShardKeys(ctx context.Context, modifiedBy string) (int, error) {
+	logger := zerolog.Ctx(ctx).With().Str("method", "ShardKeys").Logger()
+
+	actions := []commitActionOnByteSlice{}
+	for treeItem, err := range g.iterateRepositoryTree(ctx, "") {
+		if err != nil {
+			return 0, fmt.Errorf("failed to list the blobs to shard: %w", err)
+		}
+		if treeItem.Type != "blob" {
+			continue
+		}
+		if _, ok := g.sharding.KeyOf(g.naming, treeItem.Path); ok {
+			continue
+		}
+		// The blobs out of place are taken for flat ones: their path is their key
+		actions = append(actions, commitActionOnByteSlice{
+			Action:       commitActionMove,
+			FilePath:     g.repoPath(treeItem.Path),
+			PreviousPath: treeItem.Path,
+			group:        treeItem.Path,
+		})
+	}
+	if len(actions) == 0 {
+		return 0, nil
+	}
+
+	commitErr := g.commit(ctx, vcblobstore.Author{Name: modifiedBy}, fmt.Sprintf("Moving %d blobs to sharded layout", len(actions)), actions)
+	if commitErr != nil {
+		return 0, fmt.Errorf("failed to shard the blobs of GitLab repo: %w", commitErr)
+	}
+
+	logger.Info().Int("movedCount", len(actions)).Msg("Blobs moved to sharded layout")
+	return len(actions), nil
+}
@@ -0,0 +1,83 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"vcblobstore"
+)
+
+type tagProperties struct {
+	TagName string `json:"tag_name"`
+	Ref     string `json:"ref"`
+}
+
+type tagResponse struct {
+	Name   string `json:"name"`
+	Commit struct {
+		Id string `json:"id"`
+	} `json:"commit"`
+}
+
+// TagState creates a lightweight tag of the commit through the tags API of GitLab
+func (g *Gitlab) TagState(ctx context.Context, name string, stateID string) error {
+	ref := stateID
+	if len(ref) == 0 {
+		ref = g.readBranch(ctx)
+	}
+	requestBody, marshalErr := json.Marshal(tagProperties{TagName: name, Ref: ref})
+	if marshalErr != nil {
+		return fmt.Errorf("failed to marshal tag %s: %w", name, marshalErr)
+	}
+
+	statusCode, _, body, err := g.sendRequest(ctx, "POST", fmt.Sprintf("/projects/%s/repository/tags", g.escapedProjectPath()), bytesReader(requestBody))
+	if err != nil {
+		return fmt.Errorf("failed to send request to create tag %s: %w", name, err)
+	}
+	switch {
+	case statusCode == http.StatusCreated:
+		return nil
+	case statusCode == http.StatusBadRequest && strings.Contains(body, "already exists"):
+		return fmt.Errorf("%w: tag %s already exists", vcblobstore.ErrConflict, name)
+	case statusCode == http.StatusBadRequest && strings.Contains(body, "name is invalid"):
+		return fmt.Errorf("%s: %w", name, vcblobstore.ErrInvalidTagName)
+	default:
+		return fmt.Errorf("failed to create tag %s of %s: (%d) %s -- %w", name, ref, statusCode, body, typedStatusError(statusCode, body, err))
+	}
+}
+
+// ListTags returns the tags of the project
+func (g *Gitlab) ListTags(ctx context.Context) ([]vcblobstore.Tag, error) {
+	tags := []vcblobstore.Tag{}
+	page := "1"
+	for len(page) > 0 {
+		query := url.Values{}
+		query.Set("page", page)
+		query.Set("per_page", strconv.Itoa(maxCommitPageSize))
+
+		statusCode, header, body, err := g.sendRequest(ctx, "GET", fmt.Sprintf("/projects/%s/repository/tags?%s", g.escapedProjectPath(), query.Encode()), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to send request to list tags: %w", err)
+		}
+		if statusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to list tags (%d) %s -- %w", statusCode, body, typedStatusError(statusCode, body, err))
+		}
+		tagPage := []tagResponse{}
+		if jsonErr := json.Unmarshal([]byte(body), &tagPage); jsonErr != nil {
+			return nil, fmt.Errorf("failed to unmarshal GitLab tag list response: %w", jsonErr)
+		}
+		for _, tag := range tagPage {
+			tags = append(tags, vcblobstore.Tag{Name: tag.Name, StateID: tag.Commit.Id})
+		}
+		page = header.Get("X-Next-Page")
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Name < tags[j].Name
+	})
+	return tags, nil
+}
@@ -0,0 +1,111 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"vcblobstore"
+
+	"github.com/rs/zerolog"
+)
+
+const defaultRateLimitThreshold = 5
+
+const defaultMaxRateLimitWait = time.Minute
+
+// throttle keeps track of the rate limit budget GitLab reports in the RateLimit-* headers of its responses and
+// holds the requests back while the budget is nearly exhausted
+type throttle struct {
+	threshold int64
+	maxWait   time.Duration
+
+	mutex sync.Mutex
+	// remaining is -1 until a response reports it
+	remaining int64
+	reset     time.Time
+}
+
+func newThrottle(threshold int, maxWait time.Duration) *throttle {
+	if threshold <= 0 {
+		threshold = defaultRateLimitThreshold
+	}
+	if maxWait <= 0 {
+		maxWait = defaultMaxRateLimitWait
+	}
+	return &throttle{threshold: int64(threshold), maxWait: maxWait, remaining: -1}
+}
+
+// wait sleeps until the budget is reset, if it is nearly exhausted, or fails with a vcblobstore.RateLimitError
+// if that would take longer than the maximum wait
+func (t *throttle) wait(ctx context.Context) error {
+	t.mutex.Lock()
+	var delay time.Duration
+	if t.remaining >= 0 && t.remaining < t.threshold {
+		delay = time.Until(t.reset)
+	}
+	t.mutex.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	if delay > t.maxWait {
+		return &vcblobstore.RateLimitError{RetryAfter: delay}
+	}
+
+	zerolog.Ctx(ctx).Warn().Dur("delay", delay).Msg("rate limit budget nearly exhausted, waiting for its reset")
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("failed to wait for the rate limit reset: %w", ctx.Err())
+	case <-timer.C:
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if !t.reset.After(time.Now()) {
+		t.remaining = -1
+	}
+	return nil
+}
+
+// observe records the budget reported by the headers of a response
+func (t *throttle) observe(header http.Header, now time.Time) error {
+	remainingHeader := header.Get("RateLimit-Remaining")
+	if len(remainingHeader) == 0 {
+		return nil
+	}
+	remaining, remainingParseErr := strconv.ParseInt(remainingHeader, 10, 0)
+	if remainingParseErr != nil {
+		return fmt.Errorf("failed to parse %s header: %w", "RateLimit-Remaining", remainingParseErr)
+	}
+	reset := now
+	if resetHeader := header.Get("RateLimit-Reset"); len(resetHeader) > 0 {
+		resetSeconds, resetParseErr := strconv.ParseInt(resetHeader, 10, 64)
+		if resetParseErr != nil {
+			return fmt.Errorf("failed to parse %s header: %w", "RateLimit-Reset", resetParseErr)
+		}
+		reset = time.Unix(resetSeconds, 0)
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.remaining = remaining
+	t.reset = reset
+	return nil
+}
+
+// limited records the exhaustion of the budget by a response with status 429 and returns the error telling
+// when to retry (as the Retry-After header says or, without it, when the budget is reset)
+func (t *throttle) limited(header http.Header, now time.Time) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if retryAfter, parseErr := strconv.ParseInt(header.Get("Retry-After"), 10, 64); parseErr == nil {
+		t.reset = now.Add(time.Duration(retryAfter) * time.Second)
+	}
+	t.remaining = 0
+	return &vcblobstore.RateLimitError{RetryAfter: max(t.reset.Sub(now), 0)}
+}
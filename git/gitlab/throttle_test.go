@@ -0,0 +1,55 @@
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+	"vcblobstore"
+)
+
+func TestThrottleWaitsForReset(t *testing.T) {
+	throttle := newThrottle(5, time.Minute)
+	now := time.Now()
+	header := http.Header{}
+	header.Set("RateLimit-Remaining", "2")
+	header.Set("RateLimit-Reset", strconv.FormatInt(now.Add(time.Hour).Unix(), 10))
+	if observeErr := throttle.observe(header, now); observeErr != nil {
+		t.Fatalf("throttle.observe() = %v; want nil", observeErr)
+	}
+
+	waitErr := throttle.wait(context.Background())
+	retryAfter, ok := vcblobstore.RetryAfter(waitErr)
+	if !errors.Is(waitErr, vcblobstore.ErrRateLimited) || !ok || retryAfter < 59*time.Minute {
+		t.Errorf("throttle.wait() = %v; want a rate limit error to retry after about an hour", waitErr)
+	}
+
+	header.Set("RateLimit-Remaining", "100")
+	if observeErr := throttle.observe(header, now); observeErr != nil {
+		t.Fatalf("throttle.observe() = %v; want nil", observeErr)
+	}
+	if waitErr := throttle.wait(context.Background()); waitErr != nil {
+		t.Errorf("throttle.wait() = %v; want nil", waitErr)
+	}
+}
+
+func TestThrottleRetryAfter(t *testing.T) {
+	throttle := newThrottle(0, 0)
+	header := http.Header{}
+	header.Set("Retry-After", "1")
+
+	limitedErr := throttle.limited(header, time.Now())
+	if retryAfter, ok := vcblobstore.RetryAfter(limitedErr); !ok || retryAfter != time.Second {
+		t.Errorf("throttle.limited() = %v; want a rate limit error to retry after 1s", limitedErr)
+	}
+
+	start := time.Now()
+	if waitErr := throttle.wait(context.Background()); waitErr != nil {
+		t.Errorf("throttle.wait() = %v; want nil", waitErr)
+	}
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Errorf("throttle.wait() returned after %s; want about 1s", elapsed)
+	}
+}
@@ -0,0 +1,145 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"vcblobstore"
+)
+
+// TokenProvider supplies the token the requests are authenticated with. It is asked for every request, so that
+// short-lived OAuth tokens, CI job tokens or rotated access tokens can be used without recreating the client
+type TokenProvider interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// StaticToken is the TokenProvider of a token which never changes, e.g. a personal access token
+type StaticToken string
+
+func (token StaticToken) Token(ctx context.Context) (string, error) {
+	return string(token), nil
+}
+
+// TokenType tells how the token authenticates the requests and, with that, what the client may do with it
+type TokenType string
+
+const (
+	// TokenTypeAccess is a personal, project or group access token or an OAuth token, sent as a bearer token.
+	// It may do anything its scopes and the role of its user allow
+	TokenTypeAccess TokenType = ""
+	// TokenTypeJob is the CI_JOB_TOKEN of a CI/CD job, sent in the JOB-TOKEN header. GitLab accepts it only on
+	// a few API endpoints: it may read the repository of projects allowing access to it and transfer LFS objects,
+	// but neither commit nor administer the project
+	TokenTypeJob TokenType = "job"
+	// TokenTypeDeploy is a deploy token, sent with its username in basic authentication. Its read_repository
+	// scope grants reading the repository and downloading LFS objects, committing needs a different token
+	TokenTypeDeploy TokenType = "deploy"
+)
+
+// jobTokenUsername is the user name GitLab expects along with a job token in basic authentication
+const jobTokenUsername = "gitlab-ci-token"
+
+// capability is a class of GitLab API calls with the same permission requirements
+type capability string
+
+const (
+	capabilityReadRepository  capability = "read the repository"
+	capabilityWriteRepository capability = "commit to the repository"
+	capabilityManageProject   capability = "manage the project"
+	capabilityLFS             capability = "transfer LFS objects"
+)
+
+// allows tells whether GitLab accepts tokens of the type for calls of the capability
+func (tokenType TokenType) allows(required capability) bool {
+	switch tokenType {
+	case TokenTypeJob, TokenTypeDeploy:
+		return required == capabilityReadRepository || required == capabilityLFS
+	default:
+		return true
+	}
+}
+
+// apiCallCapability classifies the API call by the permission it requires
+func apiCallCapability(method string, apiCallPath string) capability {
+	apiCallPath, _, _ = strings.Cut(apiCallPath, "?")
+	switch {
+	case apiCallPath == "/projects" || strings.Contains(apiCallPath, "/hooks") ||
+		strings.Contains(apiCallPath, "/merge_requests") || strings.Contains(apiCallPath, "/protected_branches"):
+		return capabilityManageProject
+	case method == http.MethodDelete && strings.Count(apiCallPath, "/") == 2:
+		return capabilityManageProject
+	case method == http.MethodPost && strings.HasSuffix(apiCallPath, "/repository/commits"):
+		return capabilityWriteRepository
+	default:
+		return capabilityReadRepository
+	}
+}
+
+// credentials authenticate the requests with the token of the provider the way its type requires
+type credentials struct {
+	tokens         TokenProvider
+	tokenType      TokenType
+	deployUsername string
+}
+
+func newCredentials(config *Config) (credentials, error) {
+	tokens := config.TokenProvider
+	if tokens == nil {
+		token := config.GitlabAccessToken
+		if len(token) == 0 && config.TokenType == TokenTypeJob {
+			token = os.Getenv("CI_JOB_TOKEN")
+		}
+		if len(token) == 0 {
+			return credentials{}, fmt.Errorf("no API token for GitLab repository")
+		}
+		tokens = StaticToken(token)
+	}
+	if config.TokenType == TokenTypeDeploy && len(config.DeployTokenUsername) == 0 {
+		return credentials{}, fmt.Errorf("no username for the deploy token of GitLab repository")
+	}
+	return credentials{tokens: tokens, tokenType: config.TokenType, deployUsername: config.DeployTokenUsername}, nil
+}
+
+// check fails with ErrUnauthorized, without bothering GitLab, if the token can't be used for the capability
+func (creds credentials) check(required capability) error {
+	if !creds.tokenType.allows(required) {
+		return fmt.Errorf("a %s token cannot %s: %w", creds.tokenType, required, vcblobstore.ErrUnauthorized)
+	}
+	return nil
+}
+
+// authorizeAPI sets the token on a request to the REST or the GraphQL API
+func (creds credentials) authorizeAPI(ctx context.Context, request *http.Request) error {
+	token, tokenErr := creds.tokens.Token(ctx)
+	if tokenErr != nil {
+		return fmt.Errorf("failed to get API token: %w", tokenErr)
+	}
+	switch creds.tokenType {
+	case TokenTypeJob:
+		request.Header.Set("JOB-TOKEN", token)
+	case TokenTypeDeploy:
+		request.SetBasicAuth(creds.deployUsername, token)
+	default:
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	return nil
+}
+
+// authorizeGit sets the token on a request to the git HTTP endpoints, like the LFS batch API
+func (creds credentials) authorizeGit(ctx context.Context, request *http.Request) error {
+	token, tokenErr := creds.tokens.Token(ctx)
+	if tokenErr != nil {
+		return fmt.Errorf("failed to get API token: %w", tokenErr)
+	}
+	switch creds.tokenType {
+	case TokenTypeJob:
+		request.SetBasicAuth(jobTokenUsername, token)
+	case TokenTypeDeploy:
+		request.SetBasicAuth(creds.deployUsername, token)
+	default:
+		request.SetBasicAuth("oauth2", token)
+	}
+	return nil
+}
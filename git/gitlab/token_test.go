@@ -0,0 +1,64 @@
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"vcblobstore"
+)
+
+func TestApiCallCapability(t *testing.T) {
+	for _, testCase := range []struct {
+		method      string
+		apiCallPath string
+		want        capability
+	}{
+		{"GET", "/projects/group%2Fproject/repository/files/key?ref=main", capabilityReadRepository},
+		{"POST", "/projects/group%2Fproject/repository/commits?ref=main", capabilityWriteRepository},
+		{"POST", "/projects", capabilityManageProject},
+		{"DELETE", "/projects/group%2Fproject", capabilityManageProject},
+		{"POST", "/projects/group%2Fproject/hooks", capabilityManageProject},
+	} {
+		if got := apiCallCapability(testCase.method, testCase.apiCallPath); got != testCase.want {
+			t.Errorf("apiCallCapability(%s, %s) = %q; want %q", testCase.method, testCase.apiCallPath, got, testCase.want)
+		}
+	}
+}
+
+func TestJobTokenCredentials(t *testing.T) {
+	t.Setenv("CI_JOB_TOKEN", "job-token")
+	creds, credentialsErr := newCredentials(&Config{TokenType: TokenTypeJob})
+	if credentialsErr != nil {
+		t.Fatalf("newCredentials() = %v; want nil", credentialsErr)
+	}
+
+	request, _ := http.NewRequest(http.MethodGet, "https://gitlab.com/api/v4/projects", nil)
+	if authErr := creds.authorizeAPI(context.Background(), request); authErr != nil || request.Header.Get("JOB-TOKEN") != "job-token" {
+		t.Errorf("authorizeAPI() = %v with headers %v; want the job token in the JOB-TOKEN header", authErr, request.Header)
+	}
+	if checkErr := creds.check(capabilityWriteRepository); !errors.Is(checkErr, vcblobstore.ErrUnauthorized) {
+		t.Errorf("check(write) = %v; want ErrUnauthorized", checkErr)
+	}
+	if checkErr := creds.check(capabilityReadRepository); checkErr != nil {
+		t.Errorf("check(read) = %v; want nil", checkErr)
+	}
+}
+
+func TestDeployTokenCredentials(t *testing.T) {
+	if _, credentialsErr := newCredentials(&Config{TokenType: TokenTypeDeploy, GitlabAccessToken: "deploy-token"}); credentialsErr == nil {
+		t.Errorf("newCredentials() = nil error; want an error for a deploy token without username")
+	}
+
+	creds, credentialsErr := newCredentials(&Config{TokenType: TokenTypeDeploy, GitlabAccessToken: "deploy-token", DeployTokenUsername: "gitlab+deploy-token-1"})
+	if credentialsErr != nil {
+		t.Fatalf("newCredentials() = %v; want nil", credentialsErr)
+	}
+	request, _ := http.NewRequest(http.MethodPost, "https://gitlab.com/group/project.git/info/lfs/objects/batch", nil)
+	if authErr := creds.authorizeGit(context.Background(), request); authErr != nil {
+		t.Fatal(authErr)
+	}
+	if username, password, ok := request.BasicAuth(); !ok || username != "gitlab+deploy-token-1" || password != "deploy-token" {
+		t.Errorf("BasicAuth() = %s, %s, %v; want the deploy token with its username", username, password, ok)
+	}
+}
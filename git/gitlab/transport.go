@@ -0,0 +1,38 @@
+package gitlab
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// newTransport returns the transport of the clients: the configured one or, by default, a transport honoring
+// the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables and trusting the configured CA bundle on top
+// of the system roots
+func newTransport(config *Config) (http.RoundTripper, error) {
+	if config.Transport != nil {
+		return config.Transport, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if len(config.CABundleFile) == 0 {
+		return transport, nil
+	}
+
+	bundle, readErr := os.ReadFile(config.CABundleFile)
+	if readErr != nil {
+		return nil, fmt.Errorf("failed to read CA bundle %s: %w", config.CABundleFile, readErr)
+	}
+	roots, rootsErr := x509.SystemCertPool()
+	if rootsErr != nil {
+		roots = x509.NewCertPool()
+	}
+	if !roots.AppendCertsFromPEM(bundle) {
+		return nil, fmt.Errorf("failed to read CA bundle %s: no PEM certificate found", config.CABundleFile)
+	}
+	transport.TLSClientConfig = &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+	return transport, nil
+}
@@ -0,0 +1,29 @@
+package gitlab
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewTransport(t *testing.T) {
+	custom := &http.Transport{}
+	transport, transportErr := newTransport(&Config{Transport: custom, CABundleFile: "ignored"})
+	if transportErr != nil || transport != custom {
+		t.Errorf("newTransport() = %v, %v; want the configured transport", transport, transportErr)
+	}
+
+	transport, transportErr = newTransport(&Config{})
+	if defaultTransport, ok := transport.(*http.Transport); transportErr != nil || !ok || defaultTransport.Proxy == nil {
+		t.Errorf("newTransport() = %v, %v; want a transport honoring the proxy environment variables", transport, transportErr)
+	}
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	if writeErr := os.WriteFile(bundle, []byte("no certificate"), 0o600); writeErr != nil {
+		t.Fatal(writeErr)
+	}
+	if _, transportErr = newTransport(&Config{CABundleFile: bundle}); transportErr == nil {
+		t.Errorf("newTransport() = nil error; want an error for a CA bundle without certificates")
+	}
+}
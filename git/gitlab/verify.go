@@ -0,0 +1,47 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"vcblobstore"
+)
+
+type branchResponse struct {
+	Commit struct {
+		Id string `json:"id"`
+	} `json:"commit"`
+}
+
+// VerifyRepository checks that the main branch exists and that its tree can be read. GitLab keeps the repository
+// consistent itself, so there is nothing to repair
+func (g *Gitlab) VerifyRepository(ctx context.Context, opts vcblobstore.RepairOptions) (vcblobstore.RepositoryReport, error) {
+	report := vcblobstore.NewRepositoryReport()
+
+	statusCode, _, body, err := g.sendRequest(ctx, "GET", fmt.Sprintf("/projects/%s/repository/branches/%s", g.escapedProjectPath(), url.PathEscape(g.mainBranch)), nil)
+	if err != nil || (statusCode != http.StatusOK && statusCode != http.StatusNotFound) {
+		return vcblobstore.RepositoryReport{}, fmt.Errorf("failed to look up branch %s: (%d) %s -- %w", g.mainBranch, statusCode, body, typedStatusError(statusCode, body, err))
+	}
+	if statusCode == http.StatusNotFound {
+		report.Add(vcblobstore.RepositoryCheck{Name: "main branch", Details: fmt.Sprintf("branch %s doesn't exist", g.mainBranch)})
+		return report, nil
+	}
+	branch := branchResponse{}
+	if jsonErr := json.Unmarshal([]byte(body), &branch); jsonErr != nil {
+		return vcblobstore.RepositoryReport{}, fmt.Errorf("failed to unmarshal branch %s: %w", g.mainBranch, jsonErr)
+	}
+	report.Add(vcblobstore.RepositoryCheck{Name: "main branch", Passed: true})
+
+	statusCode, _, body, err = g.sendRequest(ctx, "GET", fmt.Sprintf("/projects/%s/repository/tree?%s", g.escapedProjectPath(), url.Values{"ref": {branch.Commit.Id}, "per_page": {"1"}}.Encode()), nil)
+	if err != nil {
+		return vcblobstore.RepositoryReport{}, fmt.Errorf("failed to read the tree of %s: %w", branch.Commit.Id, err)
+	}
+	if statusCode != http.StatusOK {
+		report.Add(vcblobstore.RepositoryCheck{Name: "head commit", Details: fmt.Sprintf("tree of %s can't be read: (%d) %s", branch.Commit.Id, statusCode, body)})
+		return report, nil
+	}
+	report.Add(vcblobstore.RepositoryCheck{Name: "head commit", Passed: true})
+	return report, nil
+}
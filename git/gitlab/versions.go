@@ -0,0 +1,206 @@
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+	"vcblobstore"
+	"vcblobstore/git"
+)
+
+// diffBatchSize is the number of commit diffs ListVersions requests at a time
+const diffBatchSize = 8
+
+// ListVersions returns the commits made after fromStateID up to and including toStateID, oldest first.
+// GitLab lists the commits without their changes, so the diffs of the commits are requested in batches of concurrent
+// requests
+func (g *Gitlab) ListVersions(ctx context.Context, fromStateID string, toStateID string) ([]vcblobstore.RepositoryVersion, error) {
+	revisionRange := toStateID
+	if len(revisionRange) == 0 {
+		revisionRange = g.readBranch(ctx)
+	}
+	if len(fromStateID) > 0 {
+		revisionRange = fromStateID + ".." + revisionRange
+	}
+	query := url.Values{}
+	query.Set("ref_name", revisionRange)
+
+	versions := []vcblobstore.RepositoryVersion{}
+	for commitItem, err := range g.iterateCommits(ctx, query) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the versions in %s: %w", revisionRange, err)
+		}
+		version, versionErr := versionOf(commitItem)
+		if versionErr != nil {
+			return nil, versionErr
+		}
+		versions = append(versions, version)
+	}
+	if diffErr := g.addCommitChangesInBatches(ctx, versions); diffErr != nil {
+		return nil, diffErr
+	}
+	slices.Reverse(versions)
+	return versions, nil
+}
+
+// addCommitChangesInBatches adds the changes of the versions, requesting diffBatchSize diffs at a time
+func (g *Gitlab) addCommitChangesInBatches(ctx context.Context, versions []vcblobstore.RepositoryVersion) error {
+	return inBatches(len(versions), func(index int) error {
+		return g.addCommitChanges(ctx, &versions[index])
+	})
+}
+
+// inBatches calls fetch for the indexes up to count, diffBatchSize calls at a time, and stops at the first batch
+// with a failed call
+func inBatches(count int, fetch func(index int) error) error {
+	for start := 0; start < count; start += diffBatchSize {
+		end := min(start+diffBatchSize, count)
+		fetchErrs := make([]error, end-start)
+		var wg sync.WaitGroup
+		for index := start; index < end; index++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				fetchErrs[index-start] = fetch(index)
+			}()
+		}
+		wg.Wait()
+		if fetchErr := errors.Join(fetchErrs...); fetchErr != nil {
+			return fetchErr
+		}
+	}
+	return nil
+}
+
+// versionOf returns the version made by the commit without its changes
+func versionOf(commitItem git.CommitQueryResponseItem) (vcblobstore.RepositoryVersion, error) {
+	metadata, conversionErr := git.GitlabCommitResponseToMetadata(commitItem)
+	if conversionErr != nil {
+		return vcblobstore.RepositoryVersion{}, fmt.Errorf("failed to parse git.CommitQueryResponseItem for GitLab commit %s: %w", commitItem.Id, conversionErr)
+	}
+	return vcblobstore.RepositoryVersion{
+		Version:    commitItem.Id,
+		Author:     metadata.Author,
+		AuthorDate: metadata.AuthorDate,
+		Message:    metadata.Message,
+		Changes:    []vcblobstore.KeyChange{},
+	}, nil
+}
+
+// addCommitChanges adds the changes of the version fetched from the diff of its commit
+func (g *Gitlab) addCommitChanges(ctx context.Context, version *vcblobstore.RepositoryVersion) error {
+	diff, diffErr := g.getCommitDiff(ctx, version.Version)
+	if diffErr != nil {
+		return diffErr
+	}
+	for _, diffItem := range diff {
+		g.addDiffItemChanges(version, diffItem)
+	}
+	return nil
+}
+
+// IterateVersionsSince yields the versions of the branch authored at or after since, oldest first. Only the commits
+// GitLab lists since then are queried, the changes of each being fetched just before it is yielded. GitLab bounds
+// the committer date, which is never earlier than the author date, so the commits are filtered by the author date too
+func (g *Gitlab) IterateVersionsSince(ctx context.Context, since time.Time) iter.Seq2[vcblobstore.RepositoryVersion, error] {
+	return func(yield func(vcblobstore.RepositoryVersion, error) bool) {
+		query := url.Values{}
+		query.Set("ref_name", g.readBranch(ctx))
+		if !since.IsZero() {
+			query.Set("since", since.Format(time.RFC3339))
+		}
+
+		// GitLab lists the newest commit first
+		commitItems := []git.CommitQueryResponseItem{}
+		for commitItem, err := range g.iterateCommits(ctx, query) {
+			if err != nil {
+				yield(vcblobstore.RepositoryVersion{}, fmt.Errorf("failed to list the versions since %v: %w", since, err))
+				return
+			}
+			commitItems = append(commitItems, commitItem)
+		}
+		slices.Reverse(commitItems)
+
+		for _, commitItem := range commitItems {
+			version, versionErr := versionOf(commitItem)
+			if versionErr != nil {
+				yield(vcblobstore.RepositoryVersion{}, versionErr)
+				return
+			}
+			if version.AuthorDate.Before(since) {
+				continue
+			}
+			if diffErr := g.addCommitChanges(ctx, &version); diffErr != nil {
+				yield(vcblobstore.RepositoryVersion{}, diffErr)
+				return
+			}
+			if !yield(version, nil) {
+				return
+			}
+		}
+	}
+}
+
+// ListChangesBetween returns the net changes of the blobs between the states as computed by the compare endpoint of
+// GitLab. Without fromStateID there is nothing to compare with: every blob of the tree of toStateID is created
+func (g *Gitlab) ListChangesBetween(ctx context.Context, fromStateID string, toStateID string) ([]vcblobstore.KeyChange, error) {
+	to := toStateID
+	if len(to) == 0 {
+		to = g.readBranch(ctx)
+	}
+	if len(fromStateID) == 0 {
+		return g.listBlobsCreated(ctx, to)
+	}
+
+	diffItems, compareErr := g.compare(ctx, fromStateID, to)
+	if compareErr != nil {
+		return nil, compareErr
+	}
+	net := vcblobstore.RepositoryVersion{Changes: []vcblobstore.KeyChange{}}
+	for _, diffItem := range diffItems {
+		g.addDiffItemChanges(&net, diffItem)
+	}
+	vcblobstore.SortKeyChanges(net.Changes)
+	return net.Changes, nil
+}
+
+// listBlobsCreated returns the creation of every blob of the tree of the ref
+func (g *Gitlab) listBlobsCreated(ctx context.Context, ref string) ([]vcblobstore.KeyChange, error) {
+	created := vcblobstore.RepositoryVersion{Changes: []vcblobstore.KeyChange{}}
+	for treeItem, err := range g.iterateRepositoryTreeAt(ctx, ref, "") {
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the blobs of %s: %w", ref, err)
+		}
+		if treeItem.Type == "blob" {
+			g.addPathChange(&created, treeItem.Path, vcblobstore.BlobOperationCreate)
+		}
+	}
+	vcblobstore.SortKeyChanges(created.Changes)
+	return created.Changes, nil
+}
+
+// addDiffItemChanges records the changes of the blobs the difference of the file makes
+func (g *Gitlab) addDiffItemChanges(version *vcblobstore.RepositoryVersion, diffItem commitDiffItem) {
+	switch {
+	case diffItem.DeletedFile:
+		g.addPathChange(version, diffItem.OldPath, vcblobstore.BlobOperationDelete)
+	case diffItem.RenamedFile:
+		g.addPathChange(version, diffItem.OldPath, vcblobstore.BlobOperationDelete)
+		g.addPathChange(version, diffItem.NewPath, vcblobstore.BlobOperationCreate)
+	case diffItem.NewFile:
+		g.addPathChange(version, diffItem.NewPath, vcblobstore.BlobOperationCreate)
+	default:
+		g.addPathChange(version, diffItem.NewPath, vcblobstore.BlobOperationUpdate)
+	}
+}
+
+func (g *Gitlab) addPathChange(version *vcblobstore.RepositoryVersion, path string, operation vcblobstore.BlobOperation) {
+	if key, ok := g.sharding.KeyOf(g.naming, path); ok {
+		version.AddEntryChange(g.naming, key, operation)
+	}
+}
@@ -0,0 +1,173 @@
+package gitlab
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"vcblobstore"
+
+	"github.com/rs/zerolog"
+)
+
+// maxPushEventSize limits the webhook payloads the handler reads
+const maxPushEventSize = 16 * 1024 * 1024
+
+type webhookProperties struct {
+	URL                    string `json:"url"`
+	Token                  string `json:"token,omitempty"`
+	PushEvents             bool   `json:"push_events"`
+	PushEventsBranchFilter string `json:"push_events_branch_filter,omitempty"`
+	EnableSSLVerification  bool   `json:"enable_ssl_verification"`
+}
+
+type webhookInfo struct {
+	Id int `json:"id"`
+}
+
+type pushEventCommit struct {
+	Id        string    `json:"id"`
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+	Author    struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	} `json:"author"`
+	Added    []string `json:"added"`
+	Modified []string `json:"modified"`
+	Removed  []string `json:"removed"`
+}
+
+type pushEvent struct {
+	ObjectKind        string            `json:"object_kind"`
+	Ref               string            `json:"ref"`
+	Before            string            `json:"before"`
+	After             string            `json:"after"`
+	TotalCommitsCount int               `json:"total_commits_count"`
+	Commits           []pushEventCommit `json:"commits"`
+}
+
+// RegisterPushWebhook registers a webhook notifying hookURL of the pushes to the main branch and returns its ID.
+// GitLab sends secretToken in the X-Gitlab-Token header of the notifications
+func (g *Gitlab) RegisterPushWebhook(ctx context.Context, hookURL string, secretToken string) (int, error) {
+	requestBody, marshalErr := json.Marshal(webhookProperties{
+		URL:                    hookURL,
+		Token:                  secretToken,
+		PushEvents:             true,
+		PushEventsBranchFilter: g.mainBranch,
+		EnableSSLVerification:  true,
+	})
+	if marshalErr != nil {
+		return 0, fmt.Errorf("failed to marshal webhook: %w", marshalErr)
+	}
+
+	statusCode, _, body, err := g.sendRequest(ctx, "POST", fmt.Sprintf("/projects/%s/hooks", g.escapedProjectPath()), bytes.NewReader(requestBody))
+	if err != nil || statusCode != http.StatusCreated {
+		return 0, fmt.Errorf("failed to register webhook for %s: (%d) %s -- %w", hookURL, statusCode, body, typedStatusError(statusCode, body, err))
+	}
+	hook := webhookInfo{}
+	if jsonErr := json.Unmarshal([]byte(body), &hook); jsonErr != nil {
+		return 0, fmt.Errorf("failed to unmarshal webhook: %w", jsonErr)
+	}
+	zerolog.Ctx(ctx).Info().Str("hookURL", hookURL).Int("hookId", hook.Id).Msg("push webhook registered")
+	return hook.Id, nil
+}
+
+// DeleteWebhook removes the webhook with the ID from the project
+func (g *Gitlab) DeleteWebhook(ctx context.Context, hookID int) error {
+	statusCode, _, body, err := g.sendRequest(ctx, "DELETE", fmt.Sprintf("/projects/%s/hooks/%d", g.escapedProjectPath(), hookID), nil)
+	if err != nil || statusCode != http.StatusNoContent {
+		return fmt.Errorf("failed to delete webhook %d: (%d) %s -- %w", hookID, statusCode, body, typedStatusError(statusCode, body, err))
+	}
+	return nil
+}
+
+// ParsePushEvent converts the payload of a push webhook notification into a change event, mapping the changed
+// files to keys as the client created with the config does
+func ParsePushEvent(payload []byte, config *Config) (vcblobstore.ChangeEvent, error) {
+	event := pushEvent{}
+	if jsonErr := json.Unmarshal(payload, &event); jsonErr != nil {
+		return vcblobstore.ChangeEvent{}, fmt.Errorf("failed to unmarshal push event: %w", jsonErr)
+	}
+	if event.ObjectKind != "push" {
+		return vcblobstore.ChangeEvent{}, fmt.Errorf("failed to parse push event: unexpected object kind %q", event.ObjectKind)
+	}
+
+	naming := vcblobstore.NamingOrDefault(config.Naming)
+	addPathChange := func(version *vcblobstore.RepositoryVersion, path string, operation vcblobstore.BlobOperation) {
+		if key, ok := config.Sharding.KeyOf(naming, path); ok {
+			version.AddEntryChange(naming, key, operation)
+		}
+	}
+
+	changeEvent := vcblobstore.ChangeEvent{
+		Branch:    strings.TrimPrefix(event.Ref, "refs/heads/"),
+		Before:    event.Before,
+		After:     event.After,
+		Versions:  []vcblobstore.RepositoryVersion{},
+		Truncated: event.TotalCommitsCount > len(event.Commits),
+	}
+	for _, commit := range event.Commits {
+		version := vcblobstore.RepositoryVersion{
+			Version:    commit.Id,
+			Author:     fmt.Sprintf("%s <%s>", commit.Author.Name, commit.Author.Email),
+			AuthorDate: commit.Timestamp,
+			Message:    strings.TrimSpace(commit.Message),
+			Changes:    []vcblobstore.KeyChange{},
+		}
+		for _, path := range commit.Added {
+			addPathChange(&version, path, vcblobstore.BlobOperationCreate)
+		}
+		for _, path := range commit.Modified {
+			addPathChange(&version, path, vcblobstore.BlobOperationUpdate)
+		}
+		for _, path := range commit.Removed {
+			addPathChange(&version, path, vcblobstore.BlobOperationDelete)
+		}
+		changeEvent.Versions = append(changeEvent.Versions, version)
+	}
+	return changeEvent, nil
+}
+
+// NewWebhookHandler returns the handler of the push webhook notifications of the project the config is of.
+// It checks the secret token, then passes the change events of pushes to the main branch to onChange. The secret
+// token is required: with an empty one, every request without a token would pass the check
+func NewWebhookHandler(config *Config, secretToken string, onChange func(ctx context.Context, event vcblobstore.ChangeEvent)) (http.Handler, error) {
+	if len(secretToken) == 0 {
+		return nil, errors.New("the webhook handler needs a secret token")
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := zerolog.Ctx(r.Context()).With().Str("method", "webhookHandler").Logger()
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Gitlab-Token")), []byte(secretToken)) != 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("X-Gitlab-Event") != "Push Hook" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		payload, readErr := io.ReadAll(io.LimitReader(r.Body, maxPushEventSize))
+		if readErr != nil {
+			logger.Error().Err(readErr).Msg("failed to read push event")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		event, parseErr := ParsePushEvent(payload, config)
+		if parseErr != nil {
+			logger.Error().Err(parseErr).Msg("failed to parse push event")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if event.Branch == config.GitlabMainBranch {
+			onChange(r.Context(), event)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}), nil
+}
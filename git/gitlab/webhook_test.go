@@ -0,0 +1,96 @@
+package gitlab
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"vcblobstore"
+)
+
+const testPushEvent = `{
+	"object_kind": "push",
+	"ref": "refs/heads/main",
+	"before": "95790bf891e76fee5e1747ab589903a6a1f80f22",
+	"after": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+	"total_commits_count": 2,
+	"commits": [
+		{
+			"id": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+			"message": "Edit in the web UI\n",
+			"timestamp": "2026-01-02T10:00:00+02:00",
+			"author": {"name": "Jane", "email": "jane@example.com"},
+			"added": ["new-blob"],
+			"modified": ["%s"],
+			"removed": ["old-blob"]
+		}
+	]
+}`
+
+func TestParsePushEvent(t *testing.T) {
+	config := &Config{GitlabMainBranch: "main"}
+	sidecar := vcblobstore.NamingOrDefault(nil).MetadataSidecarKey("edited-blob")
+	event, parseErr := ParsePushEvent([]byte(strings.Replace(testPushEvent, "%s", sidecar, 1)), config)
+	if parseErr != nil {
+		t.Fatalf("ParsePushEvent() = %v; want nil", parseErr)
+	}
+	if event.Branch != "main" || !event.Truncated || len(event.Versions) != 1 {
+		t.Fatalf("ParsePushEvent() = %#v; want a truncated event of one version on main", event)
+	}
+	version := event.Versions[0]
+	if version.Author != "Jane <jane@example.com>" || version.Message != "Edit in the web UI" {
+		t.Errorf("version = %#v; want the author and the trimmed message of the commit", version)
+	}
+	want := []vcblobstore.KeyChange{
+		{Key: "new-blob", Operation: vcblobstore.BlobOperationCreate},
+		{Key: "edited-blob", Operation: vcblobstore.BlobOperationUpdate},
+		{Key: "old-blob", Operation: vcblobstore.BlobOperationDelete},
+	}
+	if len(version.Changes) != len(want) {
+		t.Fatalf("changes = %v; want %v", version.Changes, want)
+	}
+	for index, change := range want {
+		if version.Changes[index] != change {
+			t.Errorf("changes[%d] = %v; want %v", index, version.Changes[index], change)
+		}
+	}
+
+	if _, parseErr := ParsePushEvent([]byte(`{"object_kind": "tag_push"}`), config); parseErr == nil {
+		t.Errorf("ParsePushEvent() = nil error; want an error for a tag push")
+	}
+}
+
+func TestWebhookHandler(t *testing.T) {
+	events := []vcblobstore.ChangeEvent{}
+	onChange := func(ctx context.Context, event vcblobstore.ChangeEvent) {
+		events = append(events, event)
+	}
+	if _, handlerErr := NewWebhookHandler(&Config{GitlabMainBranch: "main"}, "", onChange); handlerErr == nil {
+		t.Error("NewWebhookHandler() = nil error; want an error for the empty secret token")
+	}
+	handler, handlerErr := NewWebhookHandler(&Config{GitlabMainBranch: "main"}, "secret", onChange)
+	if handlerErr != nil {
+		t.Fatalf("NewWebhookHandler() = %v; want nil", handlerErr)
+	}
+	notify := func(token string) int {
+		request := httptest.NewRequest(http.MethodPost, "/hook", strings.NewReader(strings.Replace(testPushEvent, "%s", "edited-blob", 1)))
+		request.Header.Set("X-Gitlab-Token", token)
+		request.Header.Set("X-Gitlab-Event", "Push Hook")
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	for _, token := range []string{"wrong", ""} {
+		if status := notify(token); status != http.StatusUnauthorized || len(events) != 0 {
+			t.Errorf("status = %d with %d events for token %q; want %d with none", status, len(events), token, http.StatusUnauthorized)
+		}
+	}
+	if status := notify("secret"); status != http.StatusNoContent || len(events) != 1 {
+		t.Fatalf("status = %d with %d events; want %d with one", status, len(events), http.StatusNoContent)
+	}
+	if keys := events[0].ChangedKeys(); len(keys) != 3 {
+		t.Errorf("ChangedKeys() = %v; want three keys", keys)
+	}
+}
@@ -0,0 +1,66 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"vcblobstore"
+)
+
+// GetBlobAnnotations returns the last change of each of the blobs, found by a single git log over their paths
+func (repo *Git) GetBlobAnnotations(ctx context.Context, keys []string) ([]vcblobstore.BlobAnnotation, error) {
+	annotations := make([]vcblobstore.BlobAnnotation, len(keys))
+	indexesByPath := map[string][]int{}
+	ref, refErr := repo.branchRef(ctx)
+	if refErr != nil {
+		return nil, refErr
+	}
+	args := []string{"log", "--no-renames", "--name-status", "-z", "--format=%x1e%H%x1f%an <%ae>%x1f%aI%x1f", ref, "--"}
+	for index, key := range keys {
+		path, pathErr := repo.entryPath(key)
+		if pathErr != nil {
+			return nil, pathErr
+		}
+		annotations[index].Key = key
+		if _, listed := indexesByPath[path]; !listed {
+			args = append(args, path)
+		}
+		indexesByPath[path] = append(indexesByPath[path], index)
+	}
+	if len(keys) == 0 {
+		return annotations, nil
+	}
+
+	output, execErr := repo.ExecuteGitCommand(ctx, args)
+	if execErr != nil {
+		return nil, fmt.Errorf("failed to get the last changes of %d blobs: %w", len(keys), execErr)
+	}
+	for _, record := range strings.Split(output, logRecordSeparator) {
+		fields := strings.SplitN(record, logFieldSeparator, 4)
+		if len(fields) < 4 {
+			continue
+		}
+		authorDate, parseErr := time.Parse(time.RFC3339, fields[2])
+		if parseErr != nil {
+			return nil, fmt.Errorf("failed to parse author date of commit %s: %w", fields[0], parseErr)
+		}
+		for _, change := range parseNameStatus(fields[3]) {
+			indexes, pending := indexesByPath[change.path]
+			if !pending {
+				continue
+			}
+			// The most recent commit changing the path comes first; a blob deleted by it doesn't exist
+			delete(indexesByPath, change.path)
+			if operationByChangeStatus[change.status] == vcblobstore.BlobOperationDelete {
+				continue
+			}
+			for _, index := range indexes {
+				annotations[index].Version = fields[0]
+				annotations[index].Author = fields[1]
+				annotations[index].AuthorDate = authorDate
+			}
+		}
+	}
+	return annotations, nil
+}
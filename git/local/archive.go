@@ -0,0 +1,36 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"vcblobstore"
+)
+
+var _ vcblobstore.Archiver = (*Git)(nil)
+
+// ExportAll writes the tar.gz archive of the last commit of the branch to w
+func (repo *Git) ExportAll(ctx context.Context, w io.Writer) error {
+	ref, refErr := repo.branchRef(ctx)
+	if refErr != nil {
+		return refErr
+	}
+	return repo.ExportAllAt(ctx, ref, w)
+}
+
+// ExportAllAt writes the tar.gz archive of the commit to w, as made by git archive
+func (repo *Git) ExportAllAt(ctx context.Context, version string, w io.Writer) error {
+	if len(version) == 0 || strings.HasPrefix(version, "-") {
+		return fmt.Errorf("failed to export version %q of git repository at %s: invalid version", version, repo.location)
+	}
+	err := ExecuteCommandToWriter(ctx, ExecCmdParams{
+		Name: repo.gitBinary(),
+		Args: []string{"archive", "--format=tar.gz", version},
+		Opts: &CmdOpts{Cwd: repo.location},
+	}, repo.logger, w)
+	if err != nil {
+		return fmt.Errorf("failed to export version %s of git repository at %s: %w", version, repo.location, err)
+	}
+	return nil
+}
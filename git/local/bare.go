@@ -0,0 +1,72 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"vcblobstore"
+)
+
+// ErrBareRemote is returned when a bare repository is configured with a remote: the working copy of the remote
+// is needed to catch up with it
+var ErrBareRemote = errors.New("bare repositories can't have a remote")
+
+// gitDir returns the directory of the git metadata of the repository
+func (repo *Git) gitDir() string {
+	if repo.bare {
+		return repo.location
+	}
+	return filepath.Join(repo.location, ".git")
+}
+
+// headCommit returns the ID of the last commit and false in case there is none yet
+func (repo *Git) headCommit(ctx context.Context) (string, bool) {
+	return repo.refCommit(ctx, "HEAD")
+}
+
+// refCommit returns the ID of the commit the ref points to and false in case there is none
+func (repo *Git) refCommit(ctx context.Context, ref string) (string, bool) {
+	out, err := repo.ExecuteGitCommand(ctx, []string{"rev-parse", "--verify", "--quiet", ref + "^{commit}"})
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(out), true
+}
+
+// parentCommit returns the commit the next commit to the ref goes on top of: the one the ref points to or, for a
+// branch yet to be created, the last commit of HEAD. exists tells whether the ref exists
+func (repo *Git) parentCommit(ctx context.Context, ref string) (parent string, hasParent bool, exists bool) {
+	parent, exists = repo.refCommit(ctx, ref)
+	if exists || ref == "HEAD" {
+		return parent, exists, exists
+	}
+	parent, hasParent = repo.headCommit(ctx)
+	return parent, hasParent, false
+}
+
+// commitBare runs the blob operation on a temporary index filled from the last commit of the ref and commits the
+// index to the ref. There is no working tree to roll back: a failed operation just leaves the index behind
+func (repo *Git) commitBare(ctx context.Context, ref string, blobOperation func(tree entryWriter) error, message string, author vcblobstore.Author) error {
+	indexDirectory, tempErr := os.MkdirTemp("", "vcblobstore-index-")
+	if tempErr != nil {
+		return fmt.Errorf("failed to create temporary index: %w", tempErr)
+	}
+	defer os.RemoveAll(indexDirectory)
+	index := bareIndex{ctx, repo, []string{"GIT_INDEX_FILE=" + filepath.Join(indexDirectory, "index")}}
+
+	parent, hasParent, _ := repo.parentCommit(ctx, ref)
+	if hasParent {
+		if out, readErr := repo.executeGitCommandWithEnv(ctx, []string{"read-tree", parent}, index.env); readErr != nil {
+			return fmt.Errorf("failed to read %s into the index: %w -> %s", parent, readErr, out)
+		}
+	}
+
+	if operationErr := blobOperation(index); operationErr != nil {
+		return fmt.Errorf("failed blob operation: %w", operationErr)
+	}
+
+	return repo.commitIndex(ctx, ref, index.env, message, author)
+}
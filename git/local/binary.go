@@ -0,0 +1,82 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+const defaultGitBinary = "git"
+
+// minGitVersion is the oldest git providing every command and option the backend uses
+var minGitVersion = GitVersion{2, 20, 0}
+
+// ErrGitUnavailable signals that the git executable is missing or too old for the backend
+var ErrGitUnavailable = errors.New("git unavailable")
+
+var gitVersionPattern = regexp.MustCompile(`git version (\d+)\.(\d+)(?:\.(\d+))?`)
+
+// GitVersion is the major, minor and patch version of a git executable
+type GitVersion [3]int
+
+func (version GitVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", version[0], version[1], version[2])
+}
+
+// Less tells whether the version precedes the other one
+func (version GitVersion) Less(other GitVersion) bool {
+	for index := range version {
+		if version[index] != other[index] {
+			return version[index] < other[index]
+		}
+	}
+	return false
+}
+
+// ParseGitVersion parses the output of git --version, e.g. "git version 2.39.3 (Apple Git-146)"
+func ParseGitVersion(out string) (GitVersion, error) {
+	match := gitVersionPattern.FindStringSubmatch(out)
+	if match == nil {
+		return GitVersion{}, fmt.Errorf("failed to parse git version from %q", out)
+	}
+	version := GitVersion{}
+	for index, part := range match[1:] {
+		if len(part) == 0 {
+			continue
+		}
+		number, parseErr := strconv.Atoi(part)
+		if parseErr != nil {
+			return GitVersion{}, fmt.Errorf("failed to parse git version from %q: %w", out, parseErr)
+		}
+		version[index] = number
+	}
+	return version, nil
+}
+
+// checkGitBinary fails with ErrGitUnavailable unless the binary runs and is at least minGitVersion.
+// It records the version of the binary
+func (repo *Git) checkGitBinary(ctx context.Context) error {
+	out, err := ExecuteCommand(ctx, ExecCmdParams{Name: repo.gitBinary(), Args: []string{"--version"}}, repo.logger)
+	if err != nil {
+		return fmt.Errorf("%w: failed to run %s: %w -> %s", ErrGitUnavailable, repo.gitBinary(), err, out)
+	}
+	version, parseErr := ParseGitVersion(out)
+	if parseErr != nil {
+		return fmt.Errorf("%w: %w", ErrGitUnavailable, parseErr)
+	}
+	if version.Less(minGitVersion) {
+		return fmt.Errorf("%w: %s is git %s, at least %s is required", ErrGitUnavailable, repo.gitBinary(), version, minGitVersion)
+	}
+	repo.gitVersion = version
+	return nil
+}
+
+// gitBinary returns the git executable to run
+func (repo Git) gitBinary() string {
+	if len(repo.binary) == 0 {
+		return defaultGitBinary
+	}
+	return repo.binary
+}
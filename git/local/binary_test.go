@@ -0,0 +1,36 @@
+package local
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestParseGitVersion(t *testing.T) {
+	for out, want := range map[string]GitVersion{
+		"git version 2.39.3 (Apple Git-146)\n": {2, 39, 3},
+		"git version 2.45.1.windows.1\n":       {2, 45, 1},
+		"git version 2.7\n":                    {2, 7, 0},
+	} {
+		version, parseErr := ParseGitVersion(out)
+		if parseErr != nil || version != want {
+			t.Errorf("ParseGitVersion(%q) = %s, %v; want %s", out, version, parseErr, want)
+		}
+	}
+	if _, parseErr := ParseGitVersion("command not found"); parseErr == nil {
+		t.Errorf("ParseGitVersion() = nil error; want an error for unexpected output")
+	}
+	if !(GitVersion{2, 7, 0}).Less(minGitVersion) || minGitVersion.Less(GitVersion{2, 20, 0}) {
+		t.Errorf("GitVersion.Less() compares the versions wrongly")
+	}
+}
+
+func TestMissingGitBinary(t *testing.T) {
+	logger := zerolog.New(os.Stdout)
+	_, createErr := NewLocalGitRepository(&Config{Location: t.TempDir(), GitBinary: "/nonexistent/git"}, &logger)
+	if !errors.Is(createErr, ErrGitUnavailable) {
+		t.Errorf("NewLocalGitRepository() = %v; want ErrGitUnavailable", createErr)
+	}
+}
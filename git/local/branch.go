@@ -0,0 +1,68 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"vcblobstore"
+)
+
+// branchRef returns the ref the reads and writes made with the context go to: HEAD, unless a branch other than the
+// one checked out is selected with vcblobstore.WithBranch or Config.Branch. It fails with
+// vcblobstore.ErrInvalidBranchName if the selected branch isn't a valid branch name: a name like main~1 would
+// resolve to another commit
+func (repo *Git) branchRef(ctx context.Context) (string, error) {
+	branch := vcblobstore.BranchOf(ctx, repo.branch)
+	if len(branch) == 0 {
+		return "HEAD", nil
+	}
+	out, formatErr := repo.ExecuteGitCommand(ctx, []string{"check-ref-format", "--branch", branch})
+	if formatErr != nil || strings.TrimSpace(out) != branch || strings.HasPrefix(branch, "-") {
+		return "", fmt.Errorf("%s: %w", branch, vcblobstore.ErrInvalidBranchName)
+	}
+	out, err := repo.ExecuteGitCommand(ctx, []string{"symbolic-ref", "--quiet", "--short", "HEAD"})
+	if err == nil && strings.TrimSpace(out) == branch {
+		return "HEAD", nil
+	}
+	return "refs/heads/" + branch, nil
+}
+
+// commitBranch commits the blob operation to a branch other than the one checked out, without checking it out: the
+// operation runs on a temporary index as in a bare repository. A working copy fetches the branch from the remote
+// before and pushes it right after the commit
+func (repo *Git) commitBranch(ctx context.Context, ref string, blobOperation func(tree entryWriter) error, message string, author vcblobstore.Author) error {
+	if fetchErr := repo.fetchBranch(ctx, ref); fetchErr != nil {
+		return fetchErr
+	}
+	if commitErr := repo.commitBare(ctx, ref, blobOperation, message, author); commitErr != nil {
+		return commitErr
+	}
+	return repo.pushBranch(ctx, ref)
+}
+
+// fetchBranch updates the branch (other than the one checked out) of a working copy from the remote, if it is there
+func (repo *Git) fetchBranch(ctx context.Context, ref string) error {
+	if !repo.hasRemote() {
+		return nil
+	}
+	out, fetchErr := repo.ExecuteGitCommand(ctx, []string{"fetch", "origin", "+" + ref + ":" + ref})
+	if fetchErr != nil && !strings.Contains(out, "couldn't find remote ref") {
+		return fmt.Errorf("failed to fetch %s from %s: %w -> %s", strings.TrimPrefix(ref, "refs/heads/"), repo.remoteURL, fetchErr, out)
+	}
+	return nil
+}
+
+// pushBranch pushes the branch (other than the one checked out) of a working copy to the remote
+func (repo *Git) pushBranch(ctx context.Context, ref string) error {
+	if !repo.hasRemote() {
+		return nil
+	}
+	out, pushErr := repo.ExecuteGitCommand(ctx, repo.pushArgs(ref+":"+ref))
+	if pushErr != nil {
+		if isPushRejection(out) {
+			pushErr = fmt.Errorf("%w: %w", vcblobstore.ErrConflict, pushErr)
+		}
+		return fmt.Errorf("failed to push %s to %s: %w -> %s", strings.TrimPrefix(ref, "refs/heads/"), repo.remoteURL, pushErr, out)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"vcblobstore"
+)
+
+// ApplyChanges records the changes in a single commit
+func (repo *Git) ApplyChanges(ctx context.Context, changes []vcblobstore.BlobChange, message string, author vcblobstore.Author) error {
+	contents, prepareErr := vcblobstore.PrepareChanges(ctx, changes, repo.naming, repo.textMode, repo.external)
+	if prepareErr != nil {
+		return prepareErr
+	}
+	for index, change := range changes {
+		if change.Delete {
+			continue
+		}
+		content, lfsErr := repo.offloadLFS(ctx, change.Blob.Key, contents[index])
+		if lfsErr != nil {
+			return lfsErr
+		}
+		contents[index] = content
+	}
+
+	blobOperation := func(tree entryWriter) error {
+		for index, change := range changes {
+			key := change.Blob.Key
+			if change.Delete {
+				if deletionErr := repo.deleteBlob(tree, key); deletionErr != nil {
+					return deletionErr
+				}
+				continue
+			}
+			if createErr := repo.createBlob(tree, key, contents[index], change.Blob.Mode); createErr != nil {
+				return fmt.Errorf("failed to create blobfile %s: %w", key, createErr)
+			}
+			if change.Blob.Metadata == nil {
+				continue
+			}
+			if metadataErr := repo.writeMetadata(tree, key, change.Blob.Metadata); metadataErr != nil {
+				return metadataErr
+			}
+		}
+		return nil
+	}
+
+	jobTextProvider := gitJobMessages{
+		fmt.Sprintf("apply %d changes", len(changes)),
+		message,
+	}
+
+	err := repo.queue.enqueue(ctx, func(ctx context.Context) error {
+		return repo.executeBlobManipulationJob(ctx, blobOperation, jobTextProvider, author)
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to apply %d changes to git repository at %s: %w", len(changes), repo.location, err)
+	}
+	repo.access.RecordWrite(vcblobstore.ChangedKeys(changes)...)
+	return nil
+}
@@ -1,8 +1,12 @@
 package local
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
 
 	"github.com/rs/zerolog"
@@ -10,10 +14,22 @@ import (
 
 type CmdOpts struct {
 	Cwd string
+	// Env lists the variables (in "key=value" form) to set on top of the environment of the current process
+	Env []string
+	// Stdin, if set, is the input of the command
+	Stdin io.Reader
 }
 
 func (o CmdOpts) String() string {
-	return fmt.Sprintf("{Cwd: %v}", o.Cwd)
+	return fmt.Sprintf("{Cwd: %v, Env: %v}", o.Cwd, o.Env)
+}
+
+func (o CmdOpts) apply(cmd *exec.Cmd) {
+	cmd.Dir = o.Cwd
+	cmd.Stdin = o.Stdin
+	if len(o.Env) > 0 {
+		cmd.Env = append(os.Environ(), o.Env...)
+	}
 }
 
 type ExecCmdParams struct {
@@ -30,37 +46,119 @@ func (e ExecCmdParams) String() string {
 	return fmt.Sprintf("%v, %v, %v", e.Name, e.Args, option_string)
 }
 
-func ExecuteCommand(params ExecCmdParams, logger *zerolog.Logger) (string, error) {
+// ExecuteCommand runs the command to completion. The command is killed and ctx.Err() is returned in case ctx is done before that
+func ExecuteCommand(ctx context.Context, params ExecCmdParams, logger *zerolog.Logger) (string, error) {
 	execCmdLogger := logger.With().Str("function", "ExecuteCommand").Logger()
 	execCmdLogger.Info().Interface("params", params).Msg("Starting execution...")
 
-	cmd := exec.Command(params.Name, params.Args...)
+	cmd := exec.CommandContext(ctx, params.Name, params.Args...)
 	if params.Opts != nil {
-		cmd.Dir = params.Opts.Cwd
+		params.Opts.apply(cmd)
+	}
+	// Buffers rather than pipes read one after the other: the latter deadlocks once the
+	// output of the command (e.g. the content of a larger blob) fills up the pipe
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return stderr.String(), ctx.Err()
+	}
+	if err != nil {
+		errMsg := stderr.Bytes()
+		if len(errMsg) == 0 {
+			errMsg = stdout.Bytes()
+		}
+		return string(errMsg), err
 	}
-	stderr, errStderr := cmd.StderrPipe()
-	if errStderr != nil {
-		return "", errStderr
+	return stdout.String(), nil
+}
+
+// StreamCommandOutput executes the command and passes its output to yield line by line as it is produced.
+// The command is stopped as soon as yield returns false
+func StreamCommandOutput(ctx context.Context, params ExecCmdParams, logger *zerolog.Logger, yield func(line string) bool) error {
+	return streamCommand(ctx, params, logger, "StreamCommandOutput", bufio.ScanLines, yield)
+}
+
+// StreamCommandRecords executes the command and passes its output to yield record by record as it is produced, the
+// records being separated by the separator byte. The command is stopped as soon as yield returns false
+func StreamCommandRecords(ctx context.Context, params ExecCmdParams, logger *zerolog.Logger, separator byte, yield func(record string) bool) error {
+	split := func(data []byte, atEOF bool) (int, []byte, error) {
+		if index := bytes.IndexByte(data, separator); index >= 0 {
+			return index + 1, data[:index], nil
+		}
+		if atEOF && len(data) > 0 {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
 	}
+	return streamCommand(ctx, params, logger, "StreamCommandRecords", split, yield)
+}
+
+// maxStreamedTokenBytes limits the size of a line or a record of a streamed output
+const maxStreamedTokenBytes = 64 << 20
+
+func streamCommand(ctx context.Context, params ExecCmdParams, logger *zerolog.Logger, function string, split bufio.SplitFunc, yield func(token string) bool) error {
+	execCmdLogger := logger.With().Str("function", function).Logger()
+	execCmdLogger.Info().Interface("params", params).Msg("Starting execution...")
+
+	cmd := exec.CommandContext(ctx, params.Name, params.Args...)
+	if params.Opts != nil {
+		params.Opts.apply(cmd)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
 	stdout, errStdout := cmd.StdoutPipe()
 	if errStdout != nil {
-		return "", errStdout
+		return errStdout
 	}
 
 	if err := cmd.Start(); err != nil {
-		return "", err
+		return err
 	}
 
-	slurpErr, _ := io.ReadAll(stderr)
-	slurpOut, _ := io.ReadAll(stdout)
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(nil, maxStreamedTokenBytes)
+	scanner.Split(split)
+	for scanner.Scan() {
+		if !yield(scanner.Text()) {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+			return nil
+		}
+	}
+	scanErr := scanner.Err()
 
 	err := cmd.Wait()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 	if err != nil {
-		errMsg := slurpErr
-		if len(errMsg) == 0 {
-			errMsg = slurpOut
-		}
-		return string(errMsg), err
+		return fmt.Errorf("%w: %s", err, stderr.String())
+	}
+	return scanErr
+}
+
+// ExecuteCommandToWriter executes the command and copies its output to w as it is produced
+func ExecuteCommandToWriter(ctx context.Context, params ExecCmdParams, logger *zerolog.Logger, w io.Writer) error {
+	execCmdLogger := logger.With().Str("function", "ExecuteCommandToWriter").Logger()
+	execCmdLogger.Info().Interface("params", params).Msg("Starting execution...")
+
+	cmd := exec.CommandContext(ctx, params.Name, params.Args...)
+	if params.Opts != nil {
+		params.Opts.apply(cmd)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	cmd.Stdout = w
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, stderr.String())
 	}
-	return string(slurpOut), nil
+	return nil
 }
@@ -0,0 +1,92 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"vcblobstore"
+)
+
+// stage updates the index with the files written or removed, sparing the scan of the whole working tree
+func (repo *Git) stage(ctx context.Context, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	out, updateErr := repo.ExecuteGitCommand(ctx, append([]string{"update-index", "--add", "--remove", "--"}, paths...))
+	if updateErr != nil {
+		return fmt.Errorf("failed to add files to index: %w -> %s", updateErr, out)
+	}
+	return nil
+}
+
+// commitIndex commits the tree written from the index (the one env points to, if any) on top of the ref (HEAD or a
+// branch, which is created if missing)
+func (repo *Git) commitIndex(ctx context.Context, ref string, env []string, message string, author vcblobstore.Author) error {
+	if repo.beforeCommit != nil {
+		if injectedErr := repo.beforeCommit(ctx); injectedErr != nil {
+			return fmt.Errorf("failed to commit: %w", injectedErr)
+		}
+	}
+	if hookErr := repo.runPreCommitHook(ctx, env); hookErr != nil {
+		return hookErr
+	}
+
+	out, writeErr := repo.executeGitCommandWithEnv(ctx, []string{"write-tree"}, env)
+	if writeErr != nil {
+		return fmt.Errorf("failed to write tree: %w -> %s", writeErr, out)
+	}
+	tree := strings.TrimSpace(out)
+
+	commitArgs := []string{"commit-tree", tree}
+	parent, hasParent, refExists := repo.parentCommit(ctx, ref)
+	if hasParent {
+		parentTree, treeErr := repo.ExecuteGitCommand(ctx, []string{"rev-parse", parent + "^{tree}"})
+		if treeErr != nil {
+			return fmt.Errorf("failed to get the tree of %s: %w -> %s", parent, treeErr, parentTree)
+		}
+		if strings.TrimSpace(parentTree) == tree {
+			return fmt.Errorf("failed to commit: nothing to commit")
+		}
+		commitArgs = append(commitArgs, "-p", parent)
+	}
+	authorEnv := append([]string{
+		"GIT_AUTHOR_NAME=" + author.Name,
+		"GIT_AUTHOR_EMAIL=" + authorEmail(author),
+	}, repo.commitEnv(author)...)
+	fullMessage := commitMessage(message, author)
+	if record, imported := vcblobstore.ImportedRecordOf(ctx); imported {
+		if len(record.Message) > 0 {
+			fullMessage = record.Message
+			if vcblobstore.ShouldSkipCI(ctx, repo.skipCI) {
+				fullMessage = vcblobstore.MarkSkipCI(fullMessage)
+			}
+		}
+		if !record.AuthorDate.IsZero() {
+			authorEnv = append(authorEnv, fmt.Sprintf("GIT_AUTHOR_DATE=@%d %s", record.AuthorDate.Unix(), record.AuthorDate.Format("-0700")))
+		}
+	}
+	fullMessage, hookErr := repo.runCommitMessageHooks(ctx, env, fullMessage)
+	if hookErr != nil {
+		return hookErr
+	}
+	commitArgs = append(commitArgs, "-m", fullMessage)
+	out, commitErr := repo.executeGitCommandWithEnv(ctx, commitArgs, authorEnv)
+	if commitErr != nil {
+		return fmt.Errorf("failed to commit: %w -> %s", commitErr, out)
+	}
+
+	updateArgs := []string{"update-ref", ref, strings.TrimSpace(out)}
+	switch {
+	case refExists:
+		// Guards against the branch having moved since the tree was written
+		updateArgs = append(updateArgs, parent)
+	case ref != "HEAD":
+		// Guards against the branch having been created meanwhile
+		updateArgs = append(updateArgs, "")
+	}
+	if out, updateErr := repo.ExecuteGitCommand(ctx, updateArgs); updateErr != nil {
+		return fmt.Errorf("%w: failed to update HEAD: %w -> %s", vcblobstore.ErrConflict, updateErr, out)
+	}
+	repo.runPostCommitHook(ctx, env)
+	return nil
+}
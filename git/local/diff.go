@@ -0,0 +1,60 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"vcblobstore"
+	"vcblobstore/git/local/config"
+)
+
+// DiffBlob returns the difference between the blob at the two commits as computed by git diff
+func (repo *Git) DiffBlob(ctx context.Context, key string, fromVersion string, toVersion string) (vcblobstore.BlobDiff, error) {
+	path, pathErr := repo.entryPath(key)
+	if pathErr != nil {
+		return vcblobstore.BlobDiff{}, pathErr
+	}
+	for _, version := range []string{fromVersion, toVersion} {
+		if fetchErr := repo.ensureVersion(ctx, version); fetchErr != nil {
+			return vcblobstore.BlobDiff{}, fetchErr
+		}
+	}
+
+	diff := vcblobstore.BlobDiff{Key: key}
+	sizes := []*int64{&diff.FromSize, &diff.ToSize}
+	for index, version := range []string{fromVersion, toVersion} {
+		size, found, sizeErr := objectSize(ctx, repo, nil, version+":"+path)
+		if sizeErr != nil {
+			return vcblobstore.BlobDiff{}, sizeErr
+		}
+		if !found {
+			size = -1
+		}
+		*sizes[index] = size
+	}
+	if diff.FromSize < 0 && diff.ToSize < 0 {
+		return vcblobstore.BlobDiff{}, fmt.Errorf("failed to diff %s between %s and %s: %w", key, fromVersion, toVersion, vcblobstore.ErrBlobNotFound)
+	}
+
+	out, diffErr := repo.ExecuteGitCommand(ctx, []string{"diff", "--no-color", "--no-ext-diff", "--no-textconv", fromVersion, toVersion, "--", path})
+	if diffErr != nil {
+		return vcblobstore.BlobDiff{}, fmt.Errorf("failed to diff %s between %s and %s: %w -> %s", key, fromVersion, toVersion, diffErr, out)
+	}
+	diff.Patch, diff.Binary = unifiedPatch(out)
+	return diff, nil
+}
+
+// unifiedPatch returns the patch of the output of git diff without the extended header lines preceding ---/+++ and
+// true in case git found the content binary
+func unifiedPatch(out string) (string, bool) {
+	lines := strings.SplitAfter(out, config.LineBreak)
+	for index, line := range lines {
+		if strings.HasPrefix(line, "Binary files ") {
+			return "", true
+		}
+		if strings.HasPrefix(line, "--- ") {
+			return strings.Join(lines[index:], ""), false
+		}
+	}
+	return "", false
+}
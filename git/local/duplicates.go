@@ -0,0 +1,48 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"vcblobstore"
+)
+
+// FindDuplicates reports the groups of blobs with identical content. The content is hashed once per git object:
+// the hashes are cached by object id, so repeated scans only hash the blobs changed in the meantime
+func (repo *Git) FindDuplicates(ctx context.Context) ([]vcblobstore.DuplicateGroup, error) {
+	ref, refErr := repo.branchRef(ctx)
+	if refErr != nil {
+		return nil, refErr
+	}
+	files, lsErr := repo.listFilesAt(ctx, ref, "")
+	if lsErr != nil {
+		return nil, fmt.Errorf("failed to list the blobs of git repository at %s: %w", repo.location, lsErr)
+	}
+
+	hashes := []vcblobstore.ContentHash{}
+	for _, file := range files {
+		if file.objectType != "blob" {
+			continue
+		}
+		key, ok := repo.sharding.KeyOf(repo.naming, file.path)
+		if !ok || repo.naming.IsInternalKey(key) {
+			continue
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+
+		objectId := file.object
+		hash, cached := repo.contentHashes.Get(objectId)
+		if !cached {
+			content, _, readErr := readObject(ctx, repo, nil, objectId)
+			if readErr != nil {
+				return nil, fmt.Errorf("failed to read %s to hash its content: %w", file.path, readErr)
+			}
+			hash = vcblobstore.ContentHash{SHA256: vcblobstore.ContentSHA256(content), Size: int64(len(content))}
+			repo.contentHashes.Put(objectId, hash)
+		}
+		hash.Key = key
+		hashes = append(hashes, hash)
+	}
+	return vcblobstore.GroupDuplicates(hashes), nil
+}
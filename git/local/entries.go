@@ -0,0 +1,375 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"vcblobstore"
+	"vcblobstore/git/local/config"
+)
+
+// entryReader reads the entries of the repository by their paths relative to its root
+type entryReader interface {
+	// read returns the content of the entry and false in case it doesn't exist
+	read(path string) ([]byte, bool, error)
+	// size returns the size of the entry and false in case it doesn't exist
+	size(path string) (int64, bool, error)
+	// mode returns the file mode of the entry and false in case it doesn't exist
+	mode(path string) (vcblobstore.FileMode, bool, error)
+}
+
+// entryWriter changes the entries of the repository in a blob manipulation job
+type entryWriter interface {
+	entryReader
+	// write keeps the mode of an existing entry if mode is empty
+	write(path string, content []byte, mode vcblobstore.FileMode) error
+	// writeFile writes the content of the file on disk into the entry. The file may be moved in place of the entry
+	writeFile(path string, file string, mode vcblobstore.FileMode) error
+	// remove deletes the entry and returns false in case it didn't exist
+	remove(path string) (bool, error)
+	// removeAll deletes every entry of the repository
+	removeAll() error
+	// resetTo replaces every entry of the repository with those of the commit
+	resetTo(commit string) error
+}
+
+// entries returns the reader of the entries as of the last commit of the branch the context selects
+func (repo *Git) entries(ctx context.Context) (entryReader, error) {
+	ref, refErr := repo.branchRef(ctx)
+	if refErr != nil {
+		return nil, refErr
+	}
+	if repo.bare || ref != "HEAD" {
+		return refTree{ctx, repo, ref}, nil
+	}
+	return workTree{ctx: ctx, repo: repo}, nil
+}
+
+// entryPath returns the path of the entry with the key relative to the root of the repository after validating the key
+func (repo *Git) entryPath(key string) (string, error) {
+	if _, pathErr := repo.pathToFile(key); pathErr != nil {
+		return "", pathErr
+	}
+	return repo.repoPath(key), nil
+}
+
+// workTree keeps the entries as the files of the working tree
+type workTree struct {
+	ctx  context.Context
+	repo *Git
+	// touched, if set, collects the paths written or removed, which are the only ones to stage
+	touched *[]string
+}
+
+func (tree workTree) touch(path string) {
+	if tree.touched != nil {
+		*tree.touched = append(*tree.touched, path)
+	}
+}
+
+func (tree workTree) file(path string) string {
+	return filepath.Join(tree.repo.location, filepath.FromSlash(path))
+}
+
+func (tree workTree) read(path string) ([]byte, bool, error) {
+	content, readErr := os.ReadFile(tree.file(path))
+	if readErr != nil {
+		if os.IsNotExist(readErr) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to read file %s: %w", path, readErr)
+	}
+	return content, true, nil
+}
+
+func (tree workTree) size(path string) (int64, bool, error) {
+	fileInfo, statErr := os.Stat(tree.file(path))
+	if statErr != nil {
+		if os.IsNotExist(statErr) {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("failed to stat file %s: %w", path, statErr)
+	}
+	return fileInfo.Size(), true, nil
+}
+
+func (tree workTree) mode(path string) (vcblobstore.FileMode, bool, error) {
+	fileInfo, statErr := os.Stat(tree.file(path))
+	if statErr != nil {
+		if os.IsNotExist(statErr) {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("failed to stat file %s: %w", path, statErr)
+	}
+	// git tracks the execute flag of the owner only
+	return vcblobstore.FileModeOf(fileInfo.Mode().Perm()&0100 != 0), true, nil
+}
+
+func (tree workTree) write(path string, content []byte, mode vcblobstore.FileMode) error {
+	file := tree.file(path)
+	if mkdirErr := os.MkdirAll(filepath.Dir(file), 0700); mkdirErr != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, mkdirErr)
+	}
+	if writeErr := os.WriteFile(file, content, filePermissions(mode)); writeErr != nil {
+		return fmt.Errorf("failed to write file %s: %w", path, writeErr)
+	}
+	if len(mode) > 0 {
+		// WriteFile leaves the permissions of an existing file alone
+		if chmodErr := os.Chmod(file, filePermissions(mode)); chmodErr != nil {
+			return fmt.Errorf("failed to change the mode of %s: %w", path, chmodErr)
+		}
+	}
+	tree.touch(path)
+	return nil
+}
+
+func (tree workTree) writeFile(path string, file string, mode vcblobstore.FileMode) error {
+	mode, modeErr := resolveMode(tree, path, mode)
+	if modeErr != nil {
+		return modeErr
+	}
+	target := tree.file(path)
+	if mkdirErr := os.MkdirAll(filepath.Dir(target), 0700); mkdirErr != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, mkdirErr)
+	}
+	if chmodErr := os.Chmod(file, filePermissions(mode)); chmodErr != nil {
+		return fmt.Errorf("failed to change the mode of %s: %w", path, chmodErr)
+	}
+	if renameErr := os.Rename(file, target); renameErr != nil {
+		return fmt.Errorf("failed to move file in place of %s: %w", path, renameErr)
+	}
+	tree.touch(path)
+	return nil
+}
+
+// filePermissions returns the permissions of the files of the entries with the mode
+func filePermissions(mode vcblobstore.FileMode) os.FileMode {
+	if mode == vcblobstore.FileModeExecutable {
+		return 0700
+	}
+	return 0600
+}
+
+func (tree workTree) remove(path string) (bool, error) {
+	file := tree.file(path)
+	if removeErr := os.Remove(file); removeErr != nil {
+		if os.IsNotExist(removeErr) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to remove file %s: %w", path, removeErr)
+	}
+	tree.touch(path)
+	return true, tree.repo.removeEmptyParents(file)
+}
+
+// removeAll stages the removal itself
+func (tree workTree) removeAll() error {
+	out, rmErr := tree.repo.ExecuteGitCommand(tree.ctx, []string{"rm", "-r", "-q", "--", "."})
+	if rmErr != nil {
+		return fmt.Errorf("failed to remove the files of the working tree: %w -> %s", rmErr, out)
+	}
+	return nil
+}
+
+// resetTo stages the entries of the commit itself
+func (tree workTree) resetTo(commit string) error {
+	out, readErr := tree.repo.ExecuteGitCommand(tree.ctx, []string{"read-tree", "-u", "--reset", commit})
+	if readErr != nil {
+		return fmt.Errorf("failed to check out the tree of %s: %w -> %s", commit, readErr, out)
+	}
+	return nil
+}
+
+// refTree reads the entries of the last commit of the ref from the object database
+type refTree struct {
+	ctx  context.Context
+	repo *Git
+	ref  string
+}
+
+func (tree refTree) read(path string) ([]byte, bool, error) {
+	return readObject(tree.ctx, tree.repo, nil, tree.ref+":"+path)
+}
+
+func (tree refTree) size(path string) (int64, bool, error) {
+	return objectSize(tree.ctx, tree.repo, nil, tree.ref+":"+path)
+}
+
+func (tree refTree) mode(path string) (vcblobstore.FileMode, bool, error) {
+	out, lsErr := tree.repo.ExecuteGitCommand(tree.ctx, []string{"ls-tree", tree.ref, "--", path})
+	if lsErr != nil {
+		return "", false, fmt.Errorf("failed to look up %s: %w -> %s", path, lsErr, out)
+	}
+	return modeOfListedEntry(out)
+}
+
+// bareIndex stages the entries in a temporary index, from which the tree of the next commit of a bare repository
+// is written
+type bareIndex struct {
+	ctx  context.Context
+	repo *Git
+	// env points git to the temporary index
+	env []string
+}
+
+func (index bareIndex) read(path string) ([]byte, bool, error) {
+	return readObject(index.ctx, index.repo, index.env, ":"+path)
+}
+
+func (index bareIndex) size(path string) (int64, bool, error) {
+	return objectSize(index.ctx, index.repo, index.env, ":"+path)
+}
+
+func (index bareIndex) mode(path string) (vcblobstore.FileMode, bool, error) {
+	out, lsErr := index.repo.executeGitCommandWithEnv(index.ctx, []string{"ls-files", "--stage", "--", literalPathspec(path)}, index.env)
+	if lsErr != nil {
+		return "", false, fmt.Errorf("failed to look up %s: %w -> %s", path, lsErr, out)
+	}
+	return modeOfListedEntry(out)
+}
+
+func (index bareIndex) write(path string, content []byte, mode vcblobstore.FileMode) error {
+	out, hashErr := index.repo.executeGitCommandWithInput(index.ctx, []string{"hash-object", "-w", "--stdin"}, index.env, content)
+	if hashErr != nil {
+		return fmt.Errorf("failed to store the content of %s: %w -> %s", path, hashErr, out)
+	}
+	return index.stage(path, strings.TrimSpace(out), mode)
+}
+
+func (index bareIndex) writeFile(path string, file string, mode vcblobstore.FileMode) error {
+	absoluteFile, absErr := filepath.Abs(file)
+	if absErr != nil {
+		return fmt.Errorf("failed to locate the content of %s: %w", path, absErr)
+	}
+	out, hashErr := index.repo.executeGitCommandWithEnv(index.ctx, []string{"hash-object", "-w", "--no-filters", "--", absoluteFile}, index.env)
+	if hashErr != nil {
+		return fmt.Errorf("failed to store the content of %s: %w -> %s", path, hashErr, out)
+	}
+	return index.stage(path, strings.TrimSpace(out), mode)
+}
+
+// stage records the object as the content of the entry in the index
+func (index bareIndex) stage(path string, object string, mode vcblobstore.FileMode) error {
+	mode, modeErr := resolveMode(index, path, mode)
+	if modeErr != nil {
+		return modeErr
+	}
+	cacheInfo := fmt.Sprintf("%s,%s,%s", mode, object, path)
+	out, updateErr := index.repo.executeGitCommandWithEnv(index.ctx, []string{"update-index", "--add", "--cacheinfo", cacheInfo}, index.env)
+	if updateErr != nil {
+		return fmt.Errorf("failed to stage %s: %w -> %s", path, updateErr, out)
+	}
+	return nil
+}
+
+func (index bareIndex) remove(path string) (bool, error) {
+	out, lsErr := index.repo.executeGitCommandWithEnv(index.ctx, []string{"ls-files", "--cached", "--", literalPathspec(path)}, index.env)
+	if lsErr != nil {
+		return false, fmt.Errorf("failed to look up %s: %w -> %s", path, lsErr, out)
+	}
+	if len(strings.TrimSpace(out)) == 0 {
+		return false, nil
+	}
+	// The zero mode removes the entry: --force-remove would need a working tree
+	indexInfo := fmt.Sprintf("0 %s\t%s\n", strings.Repeat("0", 40), path)
+	out, updateErr := index.repo.executeGitCommandWithInput(index.ctx, []string{"update-index", "--index-info"}, index.env, []byte(indexInfo))
+	if updateErr != nil {
+		return false, fmt.Errorf("failed to unstage %s: %w -> %s", path, updateErr, out)
+	}
+	return true, nil
+}
+
+func (index bareIndex) removeAll() error {
+	out, readErr := index.repo.executeGitCommandWithEnv(index.ctx, []string{"read-tree", "--empty"}, index.env)
+	if readErr != nil {
+		return fmt.Errorf("failed to empty the index: %w -> %s", readErr, out)
+	}
+	return nil
+}
+
+func (index bareIndex) resetTo(commit string) error {
+	out, readErr := index.repo.executeGitCommandWithEnv(index.ctx, []string{"read-tree", commit}, index.env)
+	if readErr != nil {
+		return fmt.Errorf("failed to read the tree of %s into the index: %w -> %s", commit, readErr, out)
+	}
+	return nil
+}
+
+// resolveMode returns the mode to write the entry with: mode itself or, if it is empty, the mode of the existing entry
+// (FileModeRegular for a new one)
+func resolveMode(tree entryReader, path string, mode vcblobstore.FileMode) (vcblobstore.FileMode, error) {
+	if len(mode) > 0 {
+		return mode, nil
+	}
+	existingMode, found, modeErr := tree.mode(path)
+	if modeErr != nil {
+		return "", modeErr
+	}
+	if !found {
+		return vcblobstore.FileModeRegular, nil
+	}
+	return existingMode, nil
+}
+
+// literalPathspec makes git match the path as is: glob characters, which keys may contain, would otherwise match
+// other entries
+func literalPathspec(path string) string {
+	return ":(literal)" + path
+}
+
+// modeOfListedEntry returns the mode in the first field of the output of ls-tree or ls-files --stage listing a
+// single entry and false in case the output is empty
+func modeOfListedEntry(out string) (vcblobstore.FileMode, bool, error) {
+	line := strings.TrimSpace(strings.Split(out, config.LineBreak)[0])
+	if len(line) == 0 {
+		return "", false, nil
+	}
+	return vcblobstore.FileMode(strings.Fields(line)[0]), true, nil
+}
+
+// readObject returns the content of the object and false in case it doesn't exist
+func readObject(ctx context.Context, repo *Git, env []string, object string) ([]byte, bool, error) {
+	if _, found, lookupErr := objectSize(ctx, repo, env, object); lookupErr != nil || !found {
+		return nil, false, lookupErr
+	}
+	out, catErr := repo.executeGitCommandWithEnv(ctx, []string{"cat-file", "blob", object}, env)
+	if catErr != nil {
+		return nil, false, fmt.Errorf("failed to read %s: %w", object, catErr)
+	}
+	return []byte(out), true, nil
+}
+
+// objectSize returns the size of the object and false in case it doesn't exist. cat-file --batch-check reports
+// a missing object (or path) as such, whereas cat-file -e fails the same way for it as for a broken repository
+func objectSize(ctx context.Context, repo *Git, env []string, object string) (int64, bool, error) {
+	out, checkErr := repo.executeGitCommandWithInput(ctx, []string{"cat-file", "--batch-check", "-z"}, env, []byte(object+"\x00"))
+	if checkErr != nil {
+		return 0, false, fmt.Errorf("failed to look up %s: %w -> %s", object, checkErr, out)
+	}
+	if out == object+" missing\n" {
+		return 0, false, nil
+	}
+	fields := strings.Fields(out)
+	if len(fields) != 3 {
+		return 0, false, fmt.Errorf("failed to look up %s: unexpected output %q", object, out)
+	}
+	size, parseErr := strconv.ParseInt(fields[2], 10, 64)
+	if parseErr != nil {
+		return 0, false, fmt.Errorf("failed to parse the size of %s: %w", object, parseErr)
+	}
+	return size, true, nil
+}
+
+// executeGitCommandWithInput runs the git command with the input on its standard input
+func (repo *Git) executeGitCommandWithInput(ctx context.Context, args []string, env []string, input []byte) (string, error) {
+	out, err := ExecuteCommand(ctx, ExecCmdParams{
+		Name: repo.gitBinary(),
+		Args: repo.gitArgs(args),
+		Opts: &CmdOpts{Cwd: repo.location, Env: append(repo.committerEnv(), env...), Stdin: bytes.NewReader(input)},
+	}, repo.logger)
+	return out, typedGitError(out, err)
+}
@@ -0,0 +1,171 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// HooksMode tells which git hooks run in the repository
+type HooksMode string
+
+const (
+	// HooksRepository runs the hooks installed in the repository (or in the core.hooksPath of the host), if any.
+	// The commits of the writes run the pre-commit, prepare-commit-msg, commit-msg and post-commit hooks as git commit
+	// does. The commits of merges, reverts of whole states and history pruning run none of them
+	HooksRepository HooksMode = ""
+	// HooksDisabled runs no hooks at all
+	HooksDisabled HooksMode = "disabled"
+	// HooksValidation runs only the pre-commit hook provided by vcblobstore, which rejects the commits of entries
+	// whose paths aren't valid keys, e.g. those committed by other tools in the working copy
+	HooksValidation HooksMode = "validation"
+)
+
+// ErrHookRejected is returned when the pre-commit or the commit-msg hook rejects a commit
+var ErrHookRejected = errors.New("rejected by hook")
+
+// validationHooksDirectory is the directory of the hooks provided by vcblobstore in the git directory
+const validationHooksDirectory = "vcblobstore-hooks"
+
+const validationPreCommitHook = `#!/bin/sh
+# Installed by vcblobstore: rejects the commits of entries whose paths aren't valid keys
+against=HEAD
+git rev-parse --verify --quiet HEAD >/dev/null || against=$(git hash-object -t tree /dev/null)
+git diff-index --cached --name-only --diff-filter=ACMR "$against" | while IFS= read -r path; do
+	case "$path" in
+	*\\* | .git | .git/* | */.git | */.git/* | . | ./* | */. | */./* | .. | ../* | */.. | */../*)
+		echo "vcblobstore: invalid key: $path" >&2
+		exit 1
+		;;
+	esac
+done
+`
+
+// hooksArgs returns the options of git selecting the hooks to run
+func (repo *Git) hooksArgs() []string {
+	switch repo.hooks {
+	case HooksDisabled:
+		return []string{"-c", "core.hooksPath=/dev/null"}
+	case HooksValidation:
+		hooksPath, absErr := filepath.Abs(filepath.Join(repo.gitDir(), validationHooksDirectory))
+		if absErr != nil {
+			hooksPath = filepath.Join(repo.gitDir(), validationHooksDirectory)
+		}
+		return []string{"-c", "core.hooksPath=" + hooksPath}
+	default:
+		return nil
+	}
+}
+
+// gitArgs returns the arguments of git running the command with the hooks selected
+func (repo *Git) gitArgs(args []string) []string {
+	return append(repo.hooksArgs(), args...)
+}
+
+// installHooks installs the hooks provided by vcblobstore, if they are selected
+func (repo *Git) installHooks() error {
+	if repo.hooks != HooksValidation {
+		return nil
+	}
+	hooksDirectory := filepath.Join(repo.gitDir(), validationHooksDirectory)
+	if mkdirErr := os.MkdirAll(hooksDirectory, 0700); mkdirErr != nil {
+		return fmt.Errorf("failed to create directory of hooks: %w", mkdirErr)
+	}
+	if writeErr := os.WriteFile(filepath.Join(hooksDirectory, "pre-commit"), []byte(validationPreCommitHook), 0700); writeErr != nil {
+		return fmt.Errorf("failed to install pre-commit hook: %w", writeErr)
+	}
+	return nil
+}
+
+// runPreCommitHook runs the pre-commit hook on the index env points to (the default one if unset), as git commit
+// does, the commits being created by plumbing commands
+func (repo *Git) runPreCommitHook(ctx context.Context, env []string) error {
+	return repo.runHook(ctx, "pre-commit", env)
+}
+
+// runCommitMessageHooks runs the prepare-commit-msg and the commit-msg hooks on the message, as git commit does, and
+// returns the message as they left it
+func (repo *Git) runCommitMessageHooks(ctx context.Context, env []string, message string) (string, error) {
+	installed := false
+	for _, name := range []string{"prepare-commit-msg", "commit-msg"} {
+		_, found, pathErr := repo.hookPath(ctx, name)
+		if pathErr != nil {
+			return "", pathErr
+		}
+		installed = installed || found
+	}
+	if !installed {
+		return message, nil
+	}
+
+	messageFile, createErr := os.CreateTemp("", "vcblobstore-message-")
+	if createErr != nil {
+		return "", fmt.Errorf("failed to create commit message file: %w", createErr)
+	}
+	defer os.Remove(messageFile.Name())
+	_, writeErr := messageFile.WriteString(message + "\n")
+	if closeErr := messageFile.Close(); writeErr != nil || closeErr != nil {
+		return "", fmt.Errorf("failed to write commit message file: %w", errors.Join(writeErr, closeErr))
+	}
+	if hookErr := repo.runHook(ctx, "prepare-commit-msg", env, messageFile.Name(), "message"); hookErr != nil {
+		return "", hookErr
+	}
+	if hookErr := repo.runHook(ctx, "commit-msg", env, messageFile.Name()); hookErr != nil {
+		return "", hookErr
+	}
+	content, readErr := os.ReadFile(messageFile.Name())
+	if readErr != nil {
+		return "", fmt.Errorf("failed to read commit message file: %w", readErr)
+	}
+	return strings.TrimRight(string(content), "\n"), nil
+}
+
+// runPostCommitHook runs the post-commit hook, whose failure is only logged, since the commit is made already
+func (repo *Git) runPostCommitHook(ctx context.Context, env []string) {
+	if hookErr := repo.runHook(ctx, "post-commit", env); hookErr != nil {
+		repo.logger.Warn().Err(hookErr).Msg("post-commit hook failed")
+	}
+}
+
+// hookPath returns the path of the hook and false if it isn't installed or isn't executable (git ignores such hooks)
+func (repo *Git) hookPath(ctx context.Context, name string) (string, bool, error) {
+	if repo.hooks == HooksDisabled {
+		return "", false, nil
+	}
+	out, pathErr := repo.ExecuteGitCommand(ctx, []string{"rev-parse", "--git-path", "hooks/" + name})
+	if pathErr != nil {
+		return "", false, fmt.Errorf("failed to look up %s hook: %w -> %s", name, pathErr, out)
+	}
+	hook := strings.TrimSpace(out)
+	if !filepath.IsAbs(hook) {
+		hook = filepath.Join(repo.location, hook)
+	}
+	hookInfo, statErr := os.Stat(hook)
+	if statErr != nil || hookInfo.IsDir() || hookInfo.Mode()&0111 == 0 {
+		return "", false, nil
+	}
+	return hook, true, nil
+}
+
+// runHook runs the hook with the arguments, if it is installed
+func (repo *Git) runHook(ctx context.Context, name string, env []string, args ...string) error {
+	hook, found, pathErr := repo.hookPath(ctx, name)
+	if pathErr != nil || !found {
+		return pathErr
+	}
+	out, hookErr := ExecuteCommand(ctx, ExecCmdParams{
+		Name: hook,
+		Args: args,
+		Opts: &CmdOpts{Cwd: repo.location, Env: append(repo.committerEnv(), env...)},
+	}, repo.logger)
+	if hookErr != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("%w: %s: %w -> %s", ErrHookRejected, name, hookErr, out)
+	}
+	return nil
+}
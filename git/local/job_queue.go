@@ -1,27 +1,155 @@
 package local
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-var in = make(chan func())
+const defaultQueueCapacity = 128
 
-func queueProcessor() {
-	for job := range in {
-		job()
+// ErrRepositoryClosed is returned for the operations requested after the repository has been closed
+var ErrRepositoryClosed = errors.New("repository closed")
+
+// ErrQueueFull is returned for the operations requested while the queue of the repository is full
+var ErrQueueFull = errors.New("job queue full")
+
+const (
+	jobPending int32 = iota
+	jobClaimed
+)
+
+type queuedJob struct {
+	ctx   context.Context
+	run   func(ctx context.Context) error
+	state atomic.Int32
+	done  chan error
+}
+
+// claim makes sure that the job is either run (or rejected) by the queue or abandoned by its caller, not both
+func (job *queuedJob) claim() bool {
+	return job.state.CompareAndSwap(jobPending, jobClaimed)
+}
+
+// jobQueue runs the jobs of a repository one by one, so that the git commands of concurrent operations don't interleave
+type jobQueue struct {
+	in         chan *queuedJob
+	jobTimeout time.Duration
+
+	mutex    sync.Mutex
+	closing  bool
+	drainCtx context.Context
+	rejected int
+
+	closeOnce sync.Once
+	closed    chan struct{}
+	stopped   chan struct{}
+}
+
+func newJobQueue(capacity int, jobTimeout time.Duration) *jobQueue {
+	if capacity <= 0 {
+		capacity = defaultQueueCapacity
+	}
+	queue := &jobQueue{
+		in:         make(chan *queuedJob, capacity),
+		jobTimeout: jobTimeout,
+		closed:     make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+	go queue.process()
+	return queue
+}
+
+func (queue *jobQueue) process() {
+	defer close(queue.stopped)
+	for {
+		select {
+		case job := <-queue.in:
+			queue.runJob(job)
+		case <-queue.closed:
+			queue.drain()
+			return
+		}
+	}
+}
+
+func (queue *jobQueue) runJob(job *queuedJob) {
+	if !job.claim() {
+		return
+	}
+	if job.ctx.Err() != nil {
+		job.done <- job.ctx.Err()
+		return
+	}
+	job.done <- job.run(job.ctx)
+}
+
+// drain runs the jobs still waiting as long as the context of the shutdown allows and rejects the rest
+func (queue *jobQueue) drain() {
+	for {
+		select {
+		case job := <-queue.in:
+			if queue.drainCtx.Err() == nil {
+				queue.runJob(job)
+			} else if job.claim() {
+				queue.rejected++
+				job.done <- ErrRepositoryClosed
+			}
+		default:
+			return
+		}
 	}
 }
 
-func Enqueue(job func()) {
-	var wg sync.WaitGroup
-	wg.Add(1)
-	in <- func() {
-		job()
-		wg.Done()
+// enqueue waits for its turn to run the job and returns the error of the job. The job is run with ctx, limited by the
+// job timeout unless ctx has a deadline. Waiting for the turn is given up with ctx.Err() when ctx is done. A job already
+// started is waited for: it is expected to notice the cancellation of ctx (the git commands it executes are killed)
+// and to roll back
+func (queue *jobQueue) enqueue(ctx context.Context, run func(ctx context.Context) error) error {
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline && queue.jobTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, queue.jobTimeout)
+		defer cancel()
+	}
+	job := &queuedJob{ctx: ctx, run: run, done: make(chan error, 1)}
+
+	queue.mutex.Lock()
+	if queue.closing {
+		queue.mutex.Unlock()
+		return ErrRepositoryClosed
+	}
+	select {
+	case queue.in <- job:
+		queue.mutex.Unlock()
+	default:
+		queue.mutex.Unlock()
+		return fmt.Errorf("%w: %d operations waiting", ErrQueueFull, cap(queue.in))
+	}
+
+	select {
+	case err := <-job.done:
+		return err
+	case <-ctx.Done():
+		if job.claim() {
+			return ctx.Err()
+		}
+		return <-job.done
 	}
-	wg.Wait()
 }
 
-func init() {
-	go queueProcessor()
+// shutdown stops accepting jobs, runs the waiting ones until ctx is done, rejects the rest with ErrRepositoryClosed
+// and waits for the job in progress to finish. It returns the number of rejected jobs
+func (queue *jobQueue) shutdown(ctx context.Context) int {
+	queue.closeOnce.Do(func() {
+		queue.mutex.Lock()
+		queue.closing = true
+		queue.drainCtx = ctx
+		queue.mutex.Unlock()
+		close(queue.closed)
+	})
+	<-queue.stopped
+	return queue.rejected
 }
@@ -0,0 +1,97 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func noopJob(ctx context.Context) error {
+	return nil
+}
+
+// occupy runs a job on the queue which blocks until the returned function is called
+func occupy(t *testing.T, queue *jobQueue) func() {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- queue.enqueue(context.Background(), func(ctx context.Context) error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+	return func() {
+		close(release)
+		if err := <-done; err != nil {
+			t.Errorf("enqueue() = %v; want nil", err)
+		}
+	}
+}
+
+func TestJobQueuesAreIndependent(t *testing.T) {
+	first := newJobQueue(0, 0)
+	second := newJobQueue(0, 0)
+	defer second.shutdown(context.Background())
+
+	release := occupy(t, first)
+	if err := second.enqueue(context.Background(), noopJob); err != nil {
+		t.Errorf("second.enqueue() = %v; want nil while the first queue is busy", err)
+	}
+	release()
+
+	first.shutdown(context.Background())
+	if err := first.enqueue(context.Background(), noopJob); !errors.Is(err, ErrRepositoryClosed) {
+		t.Errorf("enqueue() after shutdown = %v; want ErrRepositoryClosed", err)
+	}
+}
+
+func TestJobQueueBoundsAndTimeouts(t *testing.T) {
+	queue := newJobQueue(1, 20*time.Millisecond)
+	release := occupy(t, queue)
+
+	waitingDone := make(chan error, 1)
+	go func() {
+		waitingDone <- queue.enqueue(context.Background(), noopJob)
+	}()
+	time.Sleep(5 * time.Millisecond)
+	if err := queue.enqueue(context.Background(), noopJob); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("enqueue() on a full queue = %v; want ErrQueueFull", err)
+	}
+	if err := <-waitingDone; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("enqueue() waiting beyond the job timeout = %v; want context.DeadlineExceeded", err)
+	}
+	release()
+	queue.shutdown(context.Background())
+}
+
+func TestJobQueueShutdown(t *testing.T) {
+	queue := newJobQueue(0, 0)
+	release := occupy(t, queue)
+
+	ran := false
+	drainedDone := make(chan error, 1)
+	go func() {
+		drainedDone <- queue.enqueue(context.Background(), func(ctx context.Context) error {
+			ran = true
+			return nil
+		})
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	shutdownDone := make(chan int, 1)
+	go func() {
+		shutdownDone <- queue.shutdown(context.Background())
+	}()
+	time.Sleep(5 * time.Millisecond)
+	release()
+	if rejected := <-shutdownDone; rejected != 0 || !ran {
+		t.Errorf("shutdown() = %d rejected, waiting job run: %v; want the waiting job drained", rejected, ran)
+	}
+	if err := <-drainedDone; err != nil {
+		t.Errorf("enqueue() = %v; want nil", err)
+	}
+}
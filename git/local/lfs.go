@@ -0,0 +1,92 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"vcblobstore"
+)
+
+// lfsObjects keeps the LFS objects where git-lfs does, in the .git/lfs/objects directory of the repository
+type lfsObjects struct {
+	gitDir string
+}
+
+func (objects lfsObjects) path(oid string) string {
+	return filepath.Join(objects.gitDir, "lfs", "objects", oid[0:2], oid[2:4], oid)
+}
+
+func (objects lfsObjects) Upload(ctx context.Context, pointer vcblobstore.LFSPointer, content []byte) error {
+	path := objects.path(pointer.OID)
+	if _, statErr := os.Stat(path); statErr == nil {
+		return nil
+	}
+	if mkdirErr := os.MkdirAll(filepath.Dir(path), 0700); mkdirErr != nil {
+		return fmt.Errorf("failed to create directory of LFS object %s: %w", pointer.OID, mkdirErr)
+	}
+	temporaryPath := path + ".tmp"
+	if writeErr := os.WriteFile(temporaryPath, content, 0600); writeErr != nil {
+		return fmt.Errorf("failed to write LFS object %s: %w", pointer.OID, writeErr)
+	}
+	if renameErr := os.Rename(temporaryPath, path); renameErr != nil {
+		return fmt.Errorf("failed to write LFS object %s: %w", pointer.OID, renameErr)
+	}
+	return nil
+}
+
+func (objects lfsObjects) Download(ctx context.Context, pointer vcblobstore.LFSPointer) ([]byte, error) {
+	content, readErr := os.ReadFile(objects.path(pointer.OID))
+	if os.IsNotExist(readErr) {
+		return nil, fmt.Errorf("%s: %w", pointer.OID, vcblobstore.ErrLFSObjectMissing)
+	}
+	return content, readErr
+}
+
+// offloadLFS stores the content of the blob as an LFS object if the LFS configuration tracks it and returns the
+// content to commit. The path of the blob is marked as an LFS file in .git/info/attributes, so that git-lfs (if
+// installed) treats the pointer committed as such
+func (repo *Git) offloadLFS(ctx context.Context, key string, content []byte) ([]byte, error) {
+	if !repo.lfs.Tracks(key, len(content)) {
+		return content, nil
+	}
+	pointer, offloadErr := repo.lfs.Offload(ctx, lfsObjects{repo.gitDir()}, key, content)
+	if offloadErr != nil {
+		return nil, offloadErr
+	}
+	if trackErr := repo.trackLFS(repo.repoPath(key)); trackErr != nil {
+		return nil, trackErr
+	}
+	return pointer, nil
+}
+
+func (repo *Git) resolveLFS(ctx context.Context, content []byte) ([]byte, error) {
+	return repo.lfs.Resolve(ctx, lfsObjects{repo.gitDir()}, content)
+}
+
+func (repo *Git) trackLFS(path string) error {
+	attributesPath := filepath.Join(repo.gitDir(), "info", "attributes")
+	attributes, readErr := os.ReadFile(attributesPath)
+	if readErr != nil && !os.IsNotExist(readErr) {
+		return fmt.Errorf("failed to read git attributes: %w", readErr)
+	}
+	line := fmt.Sprintf("/%s filter=lfs diff=lfs merge=lfs -text", strings.ReplaceAll(path, " ", "[[:space:]]"))
+	if slices.Contains(strings.Split(string(attributes), "\n"), line) {
+		return nil
+	}
+
+	if mkdirErr := os.MkdirAll(filepath.Dir(attributesPath), 0700); mkdirErr != nil {
+		return fmt.Errorf("failed to create directory of git attributes: %w", mkdirErr)
+	}
+	attributesFile, openErr := os.OpenFile(attributesPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if openErr != nil {
+		return fmt.Errorf("failed to open git attributes: %w", openErr)
+	}
+	defer attributesFile.Close()
+	if _, writeErr := attributesFile.WriteString(line + "\n"); writeErr != nil {
+		return fmt.Errorf("failed to track %s in git attributes: %w", path, writeErr)
+	}
+	return nil
+}
@@ -2,11 +2,16 @@ package local
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"iter"
+	"maps"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 	"vcblobstore"
 	"vcblobstore/git"
 	"vcblobstore/git/local/config"
@@ -16,9 +21,76 @@ import (
 
 const cleanStatusMessageTail = "nothing to commit, working tree clean"
 
+const fastResetAuthor = "vcblobstore"
+
 type Git struct {
-	location string
-	logger   *zerolog.Logger
+	location   string
+	bare       bool
+	binary     string
+	gitVersion GitVersion
+	hooks      HooksMode
+	logger     *zerolog.Logger
+	queue      *jobQueue
+	textMode   vcblobstore.TextMode
+	external   *vcblobstore.ExternalStorage
+	lfs        *vcblobstore.LFS
+	access     *vcblobstore.AccessTracker
+	naming     vcblobstore.NamingStrategy
+	sharding   *vcblobstore.KeySharding
+
+	storeMetadata *vcblobstore.StoreMetadataCache
+	contentHashes *vcblobstore.ContentHashCache
+
+	reproducible          bool
+	reproducibleTimestamp time.Time
+
+	remoteURL    string
+	remoteBranch string
+	pushOptions  []string
+	pushInterval time.Duration
+	pushStatus   *pushStatus
+	pullStrategy PullStrategy
+	skipCI       bool
+	beforeCommit func(ctx context.Context) error
+	committer    vcblobstore.Author
+	cloneDepth   int
+	cloneFilter  string
+
+	branch              string
+	maintenanceInterval time.Duration
+	retention           vcblobstore.RetentionPolicy
+	retentionInterval   time.Duration
+}
+
+var (
+	_ vcblobstore.Store              = (*Git)(nil)
+	_ vcblobstore.Admin              = (*Git)(nil)
+	_ vcblobstore.ChangeApplier      = (*Git)(nil)
+	_ vcblobstore.RepositoryVerifier = (*Git)(nil)
+	_ vcblobstore.BlobDiffer         = (*Git)(nil)
+	_ vcblobstore.Tagger             = (*Git)(nil)
+	_ vcblobstore.StateReverter      = (*Git)(nil)
+	_ vcblobstore.BlobAnnotator      = (*Git)(nil)
+	_ vcblobstore.HistoryPruner      = (*Git)(nil)
+	_ vcblobstore.BranchMerger       = (*Git)(nil)
+)
+
+// Close stops the queue of the operations of the repository after the one in progress. The operations waiting
+// for their turn as well as those requested afterwards fail with ErrRepositoryClosed
+func (repo *Git) Close() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	repo.queue.shutdown(ctx)
+	return nil
+}
+
+// Shutdown stops accepting operations and runs those waiting for their turn until ctx is done. The operations still
+// waiting then fail with ErrRepositoryClosed and so does Shutdown
+func (repo *Git) Shutdown(ctx context.Context) error {
+	if rejected := repo.queue.shutdown(ctx); rejected > 0 {
+		return fmt.Errorf("failed to run %d operations before the shutdown of git repository at %s: %w", rejected, repo.location, ErrRepositoryClosed)
+	}
+	return nil
 }
 
 func (repo Git) String() string {
@@ -26,7 +98,7 @@ func (repo Git) String() string {
 }
 
 func (repo *Git) CreateRepository(ctx context.Context) error {
-	return repo.initMaybe()
+	return repo.initMaybe(ctx)
 }
 
 func (repo *Git) ResetRepository(ctx context.Context) error {
@@ -37,16 +109,71 @@ func (repo *Git) ResetRepository(ctx context.Context) error {
 	return repo.CreateRepository(ctx)
 }
 
+// FastReset empties the repository by deleting every entry in a single commit, keeping the repository and its history
+func (repo *Git) FastReset(ctx context.Context) error {
+	// The tree of HEAD rather than listKeys: the latter fails in a repository without commits
+	head, hasHead := repo.headCommit(ctx)
+	if !hasHead {
+		return nil
+	}
+	trackedFiles, listErr := repo.ExecuteGitCommand(ctx, []string{"ls-tree", "--name-only", head})
+	if listErr != nil {
+		return fmt.Errorf("failed to reset git repository at %s: %w", repo.location, listErr)
+	}
+	if len(strings.TrimSpace(trackedFiles)) == 0 {
+		return nil
+	}
+
+	blobOperation := func(tree entryWriter) error {
+		return tree.removeAll()
+	}
+
+	jobTextProvider := gitJobMessages{
+		"fast reset",
+		"repository reset",
+	}
+
+	err := repo.queue.enqueue(ctx, func(ctx context.Context) error {
+		return repo.executeBlobManipulationJob(ctx, blobOperation, jobTextProvider, vcblobstore.Author{Name: fastResetAuthor})
+	})
+	repo.storeMetadata.Invalidate()
+
+	if err != nil {
+		return fmt.Errorf("failed to reset git repository at %s: %w", repo.location, err)
+	}
+	return nil
+}
+
 func (repo *Git) DeleteRepository(ctx context.Context) error {
+	repo.storeMetadata.Invalidate()
 	return os.RemoveAll(repo.location)
 }
 
-func (repo *Git) ExecuteGitCommand(args []string) (string, error) {
-	return ExecuteCommand(ExecCmdParams{
-		Name: "git",
-		Args: args,
-		Opts: &CmdOpts{Cwd: repo.location},
+func (repo *Git) ExecuteGitCommand(ctx context.Context, args []string) (string, error) {
+	return repo.executeGitCommandWithEnv(ctx, args, nil)
+}
+
+func (repo *Git) executeGitCommandWithEnv(ctx context.Context, args []string, env []string) (string, error) {
+	out, err := ExecuteCommand(ctx, ExecCmdParams{
+		Name: repo.gitBinary(),
+		Args: repo.gitArgs(args),
+		Opts: &CmdOpts{Cwd: repo.location, Env: append(repo.committerEnv(), env...)},
 	}, repo.logger)
+	return out, typedGitError(out, err)
+}
+
+// typedGitError wraps the failure of a git command into the matching vcblobstore error
+func typedGitError(out string, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, fs.ErrNotExist) || strings.Contains(out, "not a git repository"):
+		return fmt.Errorf("%w: %w", vcblobstore.ErrRepoNotFound, err)
+	case strings.Contains(out, "index.lock': File exists"):
+		return fmt.Errorf("%w: %w", vcblobstore.ErrConflict, err)
+	default:
+		return err
+	}
 }
 
 type gitJobMessages struct {
@@ -54,19 +181,41 @@ type gitJobMessages struct {
 	commitMessage string
 }
 
-func getCommitCommand() string {
-	if os.Getenv(git.SimulateGitCommitFailureEnvvarName) == "true" {
-		return git.GitCommitFailureTestCommand
-	} else {
-		return "commit"
+func authorEmail(author vcblobstore.Author) string {
+	if len(author.Email) == 0 {
+		return author.Name
+	}
+	return author.Email
+}
+
+func commitMessage(messageBase string, author vcblobstore.Author) string {
+	return messageBase + " by " + author.Name
+}
+
+// committerEnv returns the environment setting the configured committer identity, which takes precedence over
+// the git config and the environment of the host. The commits of rebases are attributed to the committer as well
+func (repo *Git) committerEnv() []string {
+	env := []string{}
+	if len(repo.committer.Name) > 0 {
+		env = append(env, "GIT_COMMITTER_NAME="+repo.committer.Name)
 	}
+	if len(repo.committer.Email) > 0 {
+		env = append(env, "GIT_COMMITTER_EMAIL="+repo.committer.Email)
+	}
+	return env
 }
 
-func commit(messageBase string, userName string) []string {
+// commitEnv returns the environment pinning the dates and the committer of the commits in reproducible mode
+func (repo *Git) commitEnv(author vcblobstore.Author) []string {
+	if !repo.reproducible {
+		return nil
+	}
+	date := fmt.Sprintf("@%d +0000", repo.reproducibleTimestamp.Unix())
 	return []string{
-		getCommitCommand(),
-		"-m", messageBase + " by " + userName,
-		fmt.Sprintf("--author=%s <%s>", userName, userName),
+		"GIT_AUTHOR_DATE=" + date,
+		"GIT_COMMITTER_DATE=" + date,
+		"GIT_COMMITTER_NAME=" + author.Name,
+		"GIT_COMMITTER_EMAIL=" + authorEmail(author),
 	}
 }
 
@@ -75,94 +224,130 @@ var rollbackCommands = [][]string{
 	{"clean", "-qfdx"},
 }
 
-func (repo *Git) rollback() {
+func (repo *Git) rollback(ctx context.Context) {
+	if repo.bare {
+		return
+	}
 	for _, rollbackCmd := range rollbackCommands {
-		_, _ = repo.ExecuteGitCommand(rollbackCmd)
+		_, _ = repo.ExecuteGitCommand(ctx, rollbackCmd)
 	}
 }
 
-func (repo *Git) executeBlobManipulationJob(blobOperation func() error, messages gitJobMessages, userName string) error {
+func (repo *Git) executeBlobManipulationJob(ctx context.Context, blobOperation func(tree entryWriter) error, messages gitJobMessages, author vcblobstore.Author) error {
 	logger := repo.logger.With().Str("method", fmt.Sprintf("git: %s", messages.logContext)).Logger()
 
-	if len(userName) == 0 {
+	if len(author.Name) == 0 {
 		logger.Warn().Msg("Modifying user is not specified")
 	}
 
-	var out string
 	var err error
 
 	defer func() {
 		if err != nil {
-			logger.Debug().Err(err).Str("out", out).Msg("failed GIT operation")
-			repo.rollback()
+			logger.Debug().Err(err).Msg("failed GIT operation")
+			// The rollback has to complete even if it was the cancellation of ctx which made the job fail
+			repo.rollback(context.WithoutCancel(ctx))
 		} else {
 			logger.Debug().Msg("Success")
 		}
 	}()
 
-	err = blobOperation()
-	if err != err {
+	message := messages.commitMessage
+	if vcblobstore.ShouldSkipCI(ctx, repo.skipCI) {
+		message = vcblobstore.MarkSkipCI(message)
+	}
+	ref, refErr := repo.branchRef(ctx)
+	if refErr != nil {
+		err = refErr
+		return err
+	}
+	if ref != "HEAD" {
+		err = repo.commitBranch(ctx, ref, blobOperation, message, author)
+		return err
+	}
+	if repo.bare {
+		err = repo.commitBare(ctx, "HEAD", blobOperation, message, author)
+		return err
+	}
+
+	err = repo.pull(ctx)
+	if err != nil {
+		return err
+	}
+	touched := []string{}
+	err = blobOperation(workTree{ctx, repo, &touched})
+	if err != nil {
 		return fmt.Errorf("failed blob operation: %w", err)
 	}
-	out, err = repo.ExecuteGitCommand([]string{"add", "-A"})
+	err = repo.stage(ctx, touched)
 	if err != nil {
-		return fmt.Errorf("failed to add files to index: %w -> %s", err, out)
+		return err
 	}
-
-	commitMessage := messages.commitMessage
-	out, err = repo.ExecuteGitCommand(commit(commitMessage, userName))
+	err = repo.commitIndex(ctx, "HEAD", nil, message, author)
 	if err != nil {
-		return fmt.Errorf("failed to commit: %w -> %s", err, out)
+		return err
 	}
 
+	if !repo.pushesPeriodically() {
+		err = repo.push(ctx)
+	}
 	return err
 }
 
-func (repo *Git) createBlob(key string, content []byte) error {
-	var err error
-
-	logOp := func(opmsg string) string {
-		repo.logger.Debug().Str("operation", opmsg).Msg("operation starting")
-		return opmsg
-	}
-
-	path, pathErr := repo.pathToFile(key)
+func (repo *Git) createBlob(tree entryWriter, key string, content []byte, mode vcblobstore.FileMode) error {
+	path, pathErr := repo.entryPath(key)
 	if pathErr != nil {
 		return pathErr
 	}
-	directory := filepath.Dir(path)
+	repo.logger.Debug().Str("operation", fmt.Sprintf("write file %s", path)).Msg("operation starting")
+	return tree.write(path, content, mode)
+}
 
-	operationMsg := logOp(fmt.Sprintf("create directory %s", directory))
-	err = os.MkdirAll(directory, 0700)
-	if err == nil {
-		operationMsg = logOp(fmt.Sprintf("create directory %s", directory))
-		err = os.MkdirAll(directory, 0700)
-		if err == nil {
-			operationMsg = logOp(fmt.Sprintf("write file %s", directory))
-			err = os.WriteFile(path, content, 0700)
-		}
-	}
-	if err != nil {
-		err = fmt.Errorf("%s: %w", operationMsg, err)
+func (repo *Git) AddBlob(ctx context.Context, blob vcblobstore.BlobInfo) error {
+	if keyErr := vcblobstore.ValidateBlobKey(repo.naming, blob.Key); keyErr != nil {
+		return keyErr
 	}
-	return err
+	return repo.addBlob(ctx, blob)
 }
 
-func (repo *Git) AddBlob(ctx context.Context, blob vcblobstore.BlobInfo) error {
+// addBlob writes the blob or the internal entry
+func (repo *Git) addBlob(ctx context.Context, blob vcblobstore.BlobInfo) error {
 	key := blob.Key
-	content := blob.Content
+
+	if checksumErr := blob.VerifyChecksum(); checksumErr != nil {
+		return checksumErr
+	}
+	if modeErr := vcblobstore.ValidateFileMode(blob.Mode); modeErr != nil {
+		return modeErr
+	}
 
 	path, pathErr := repo.pathToFile(key)
 	if pathErr != nil {
 		return pathErr
 	}
 
-	blobOperation := func() error {
-		err := repo.createBlob(key, content)
+	content, textModeErr := repo.textMode.Apply(key, blob.Content)
+	if textModeErr != nil {
+		return textModeErr
+	}
+	content, offloadErr := repo.external.Offload(ctx, content)
+	if offloadErr != nil {
+		return offloadErr
+	}
+	content, lfsErr := repo.offloadLFS(ctx, key, content)
+	if lfsErr != nil {
+		return lfsErr
+	}
+
+	blobOperation := func(tree entryWriter) error {
+		err := repo.createBlob(tree, key, content, blob.Mode)
 		if err != nil {
 			return fmt.Errorf("failed to create blobfile %s as %s: %w", key, path, err)
 		}
-		return nil
+		if blob.Metadata == nil {
+			return nil
+		}
+		return repo.writeMetadata(tree, key, blob.Metadata)
 	}
 
 	jobTextProvider := gitJobMessages{
@@ -170,246 +355,1307 @@ func (repo *Git) AddBlob(ctx context.Context, blob vcblobstore.BlobInfo) error {
 		"blob file version added",
 	}
 
-	var err error
-	Enqueue(func() {
-		err = repo.executeBlobManipulationJob(blobOperation, jobTextProvider, blob.ModifiedBy)
+	err := repo.queue.enqueue(ctx, func(ctx context.Context) error {
+		return repo.executeBlobManipulationJob(ctx, blobOperation, jobTextProvider, blob.Author())
 	})
 
 	if err != nil {
 		return fmt.Errorf("failed to add blobfile %v to git repository at %s: %w", path, repo.location, err)
 	}
+	repo.access.RecordWrite(key)
 	return nil
 }
 
-func copyBlobContents(src, dst string) error {
-	in, err := os.Open(src)
-	if err != nil {
-		return err
+// writeMetadata stores the metadata attributes of the blob in its sidecar file or removes the sidecar file if there are none
+func (repo *Git) writeMetadata(tree entryWriter, key string, metadata map[string]string) error {
+	if len(metadata) == 0 {
+		sidecarPath, pathErr := repo.entryPath(repo.naming.MetadataSidecarKey(key))
+		if pathErr != nil {
+			return pathErr
+		}
+		if _, removeErr := tree.remove(sidecarPath); removeErr != nil {
+			return fmt.Errorf("failed to remove metadata of %s: %w", key, removeErr)
+		}
+		return repo.updateAttributeIndex(tree, key, nil)
 	}
-	defer in.Close()
-	out, err := os.Create(dst)
-	if err != nil {
-		return err
+
+	content, encodeErr := vcblobstore.EncodeMetadata(metadata)
+	if encodeErr != nil {
+		return encodeErr
 	}
-	defer func() {
-		cerr := out.Close()
-		if err == nil {
-			err = cerr
-		}
-	}()
-	if _, err = io.Copy(out, in); err != nil {
-		return err
+	createErr := repo.createBlob(tree, repo.naming.MetadataSidecarKey(key), content, "")
+	if createErr != nil {
+		return fmt.Errorf("failed to write metadata of %s: %w", key, createErr)
 	}
-	err = out.Sync()
-	return err
+	return repo.updateAttributeIndex(tree, key, metadata)
 }
 
-func (repo *Git) CopyBlob(_ context.Context, sourceKey string, destinationKey string, modifiedBy string) error {
-	jobTextProvider := gitJobMessages{
-		"copy blob file",
-		"blob file version added",
+func (repo *Git) readAttributeIndex(tree entryReader) (vcblobstore.AttributeIndex, error) {
+	indexPath, pathErr := repo.entryPath(repo.naming.AttributeIndexKey())
+	if pathErr != nil {
+		return nil, pathErr
 	}
 
-	sourcePath, srcPathErr := repo.pathToFile(sourceKey)
-	if srcPathErr != nil {
-		return srcPathErr
+	content, found, readErr := tree.read(indexPath)
+	if readErr != nil {
+		return nil, fmt.Errorf("failed to read attribute index: %w", readErr)
 	}
-	destinationPath, destPathErr := repo.pathToFile(destinationKey)
-	if destPathErr != nil {
-		return destPathErr
+	if !found {
+		return vcblobstore.AttributeIndex{}, nil
 	}
+	return vcblobstore.DecodeAttributeIndex(content)
+}
 
-	blobOperation := func() error {
-		err := copyBlobContents(sourcePath, destinationPath)
-		if err != nil {
-			return fmt.Errorf("failed to copy file contents from %s to %s: %w", sourceKey, destinationKey, err)
-		}
+func (repo *Git) updateAttributeIndex(tree entryWriter, key string, metadata map[string]string) error {
+	index, readErr := repo.readAttributeIndex(tree)
+	if readErr != nil {
+		return readErr
+	}
+	if !index.Update(key, metadata) {
 		return nil
 	}
 
-	var err error
-	Enqueue(func() {
-		err = repo.executeBlobManipulationJob(blobOperation, jobTextProvider, modifiedBy)
-	})
-
-	if err != nil {
-		repo.logger.Debug().Err(err).Msg("executeBlobManipulationJob failed while copying blob")
-		return fmt.Errorf("failed to copy blobfile from %s to %s to git repository at %s: %w", sourceKey, destinationKey, repo.location, err)
+	content, encodeErr := index.Encode()
+	if encodeErr != nil {
+		return encodeErr
+	}
+	createErr := repo.createBlob(tree, repo.naming.AttributeIndexKey(), content, "")
+	if createErr != nil {
+		return fmt.Errorf("failed to write attribute index: %w", createErr)
 	}
 	return nil
 }
 
-func (repo *Git) GetBlob(ctx context.Context, key string) ([]byte, error) {
-	path, pathErr := repo.pathToFile(key)
-	if pathErr != nil {
-		return nil, pathErr
+// QueryByAttributes returns the keys of the blobs whose metadata attributes match the selector
+func (repo *Git) QueryByAttributes(ctx context.Context, selector vcblobstore.AttributeSelector) ([]string, error) {
+	tree, treeErr := repo.entries(ctx)
+	if treeErr != nil {
+		return nil, treeErr
 	}
-
-	bytes, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read file %s from local git repo: %w", path, err)
+	index, readErr := repo.readAttributeIndex(tree)
+	if readErr != nil {
+		return nil, readErr
 	}
-	return bytes, nil
+	return index.Query(selector), nil
 }
 
-func (repo *Git) deleteBlob(key string) error {
-	path, pathErr := repo.pathToFile(key)
-	if pathErr != nil {
-		return pathErr
+// GetStoreMetadata returns the document in which the store records its own configuration
+func (repo *Git) GetStoreMetadata(ctx context.Context) (vcblobstore.StoreMetadata, error) {
+	if metadata, cached := repo.storeMetadata.Get(); cached {
+		return metadata, nil
 	}
 
-	removeFileErr := os.Remove(path)
-	if removeFileErr != nil {
-		if removeFileErr == os.ErrNotExist {
-			return fmt.Errorf("failed to remove blob %s: %w", key, vcblobstore.ErrBlobNotFound)
+	path, pathErr := repo.entryPath(repo.naming.StoreMetadataKey())
+	if pathErr != nil {
+		return vcblobstore.StoreMetadata{}, pathErr
+	}
+	tree, treeErr := repo.entries(ctx)
+	if treeErr != nil {
+		return vcblobstore.StoreMetadata{}, treeErr
+	}
+	metadata := vcblobstore.DefaultStoreMetadata()
+	content, found, readErr := tree.read(path)
+	if readErr != nil {
+		return vcblobstore.StoreMetadata{}, fmt.Errorf("failed to read store metadata: %w", readErr)
+	}
+	if found {
+		var decodeErr error
+		if metadata, decodeErr = vcblobstore.DecodeStoreMetadata(content); decodeErr != nil {
+			return vcblobstore.StoreMetadata{}, decodeErr
 		}
-		return fmt.Errorf("failed to remove blob %s: %w", key, removeFileErr)
 	}
-	return nil
+
+	repo.storeMetadata.Set(metadata)
+	return metadata, nil
 }
 
-func (repo *Git) DeleteBlob(ctx context.Context, key string, modifiedBy string) error {
-	blobOperation := func() error {
-		deletionError := repo.deleteBlob(key)
-		return deletionError
+// SetStoreMetadata commits the document in which the store records its own configuration
+func (repo *Git) SetStoreMetadata(ctx context.Context, metadata vcblobstore.StoreMetadata, modifiedBy string) error {
+	content, encodeErr := vcblobstore.EncodeStoreMetadata(metadata)
+	if encodeErr != nil {
+		return encodeErr
+	}
+
+	blobOperation := func(tree entryWriter) error {
+		return repo.createBlob(tree, repo.naming.StoreMetadataKey(), content, "")
 	}
 
 	jobTextProvider := gitJobMessages{
-		fmt.Sprintf("delete blob %s", key),
-		"blob deleted",
+		"set store metadata",
+		"store metadata set",
 	}
 
-	var err error
-	Enqueue(func() {
-		err = repo.executeBlobManipulationJob(blobOperation, jobTextProvider, modifiedBy)
+	err := repo.queue.enqueue(ctx, func(ctx context.Context) error {
+		return repo.executeBlobManipulationJob(ctx, blobOperation, jobTextProvider, vcblobstore.Author{Name: modifiedBy})
 	})
 
 	if err != nil {
-		return fmt.Errorf("failed to remove blob %s from git repository: %w", key, err)
+		repo.storeMetadata.Invalidate()
+		return fmt.Errorf("failed to set store metadata in git repository at %s: %w", repo.location, err)
 	}
+	repo.storeMetadata.Set(metadata)
 	return nil
 }
 
-func (repo Git) CheckStatus() (bool, error) {
-	out, err := repo.ExecuteGitCommand([]string{"status"})
-	if err != nil {
-		return false, fmt.Errorf("failed to get current git commit: %w", err)
+func (repo *Git) readMetadata(tree entryReader, key string) (map[string]string, error) {
+	sidecarPath, pathErr := repo.entryPath(repo.naming.MetadataSidecarKey(key))
+	if pathErr != nil {
+		return nil, pathErr
 	}
-	status := strings.TrimSpace(out)
-	return strings.Contains(status, cleanStatusMessageTail), nil
-}
 
-func (repo Git) GetStateID(ctx context.Context) (string, error) {
-	out, err := repo.ExecuteGitCommand([]string{"rev-parse", "HEAD"})
-	if err != nil {
-		return "", fmt.Errorf("failed to get current git commit: %w", err)
+	content, found, readErr := tree.read(sidecarPath)
+	if readErr != nil {
+		return nil, fmt.Errorf("failed to read metadata of %s: %w", key, readErr)
 	}
-	return strings.TrimSpace(out), nil
+	if !found {
+		return map[string]string{}, nil
+	}
+	return vcblobstore.DecodeMetadata(content)
 }
 
-func (repo Git) ListBlobKeys(ctx context.Context) ([]string, error) {
-	output, err := repo.ExecuteGitCommand([]string{"ls-tree", "-r", "HEAD", "--name-only"})
-	if err != nil {
-		return nil, err
+// GetBlobInfo returns the content of the blob along with its metadata attributes
+func (repo *Git) GetBlobInfo(ctx context.Context, key string) (vcblobstore.BlobInfo, error) {
+	canonicalKey, resolveErr := repo.ResolveAlias(ctx, key)
+	if resolveErr != nil {
+		return vcblobstore.BlobInfo{}, resolveErr
 	}
 
-	fileList := []string{}
-	outputLines := strings.Split(output, config.LineBreak)
-	for _, line := range outputLines {
-		trimmedLine := strings.TrimSpace(line)
-		if len(trimmedLine) > 0 {
-			fileList = append(fileList, trimmedLine)
-		}
+	content, getErr := repo.GetBlob(ctx, canonicalKey)
+	if getErr != nil {
+		return vcblobstore.BlobInfo{}, getErr
 	}
-	return fileList, nil
-}
 
-// GetVersionFor returns the commit ID of the blob specified by the method paramters.
-// Return empty string in case the file doesn't exist in the repository
-func (repo Git) GetVersionFor(ctx context.Context, key string) (string, error) {
-	path, pathErr := repo.pathToFile(key)
-	if pathErr != nil {
-		return "", pathErr
+	tree, treeErr := repo.entries(ctx)
+	if treeErr != nil {
+		return vcblobstore.BlobInfo{}, treeErr
+	}
+	metadata, metadataErr := repo.readMetadata(tree, canonicalKey)
+	if metadataErr != nil {
+		return vcblobstore.BlobInfo{}, metadataErr
 	}
 
-	printCommitIDArgs := []string{"log", "-n", "1", "--pretty=format:%H", "--", path}
-	output, execErr := repo.ExecuteGitCommand(printCommitIDArgs)
-	if execErr != nil {
-		return "", fmt.Errorf("failed to execute command to get last commit modifying %s: %w", key, execErr)
+	return vcblobstore.BlobInfo{
+		Key:      key,
+		Content:  content,
+		Metadata: metadata,
+		SHA256:   vcblobstore.ContentSHA256(content),
+	}, nil
+}
+
+// GetBlobWithChecksum returns the content of the blob along with its SHA-256 digest
+func (repo *Git) GetBlobWithChecksum(ctx context.Context, key string) ([]byte, string, error) {
+	content, err := repo.GetBlob(ctx, key)
+	if err != nil {
+		return nil, "", err
 	}
-	return output, nil
+	return content, vcblobstore.ContentSHA256(content), nil
 }
 
-func (repo Git) GetVersionMetadata(ctx context.Context, commitId string) (git.CommitMetadata, error) {
-	logger := repo.logger.With().Str("method", fmt.Sprintf("git: GetVersionMetadata: %s", commitId)).Logger()
+// UpdateBlobMetadata replaces the metadata attributes of the blob without rewriting its content
+func (repo *Git) UpdateBlobMetadata(ctx context.Context, key string, metadata map[string]string, modifiedBy string) error {
+	if keyErr := vcblobstore.ValidateBlobKey(repo.naming, key); keyErr != nil {
+		return keyErr
+	}
+	path, pathErr := repo.entryPath(key)
+	if pathErr != nil {
+		return pathErr
+	}
+	tree, treeErr := repo.entries(ctx)
+	if treeErr != nil {
+		return treeErr
+	}
+	_, found, statErr := tree.size(path)
+	if statErr != nil {
+		return fmt.Errorf("failed to update metadata of %s: %w", key, statErr)
+	}
+	if !found {
+		return fmt.Errorf("failed to update metadata of %s: %w", key, vcblobstore.ErrBlobNotFound)
+	}
 
-	printCommitMetadataArgs := []string{"show", "--quiet", "--format=fuller", "--date=format:%Y-%m-%dT%H:%M:%S%z"}
-	output, execErr := repo.ExecuteGitCommand(printCommitMetadataArgs)
-	if execErr != nil {
-		return git.CommitMetadata{}, fmt.Errorf("failed to get metadata from repo for commit %s: %w", commitId, execErr)
+	blobOperation := func(tree entryWriter) error {
+		return repo.writeMetadata(tree, key, metadata)
 	}
-	logger.Debug().Str("meta-data", output).Msg("raw metadata extracted")
-	commitMetadata, parseErr := git.ParseLocalCommitMetadata(output)
-	if parseErr != nil {
-		return commitMetadata, fmt.Errorf("failed to parse metadata from commit %s: %w", commitId, parseErr)
+
+	jobTextProvider := gitJobMessages{
+		fmt.Sprintf("update metadata of blob %s", key),
+		"blob metadata updated",
 	}
-	return commitMetadata, nil
+
+	err := repo.queue.enqueue(ctx, func(ctx context.Context) error {
+		tree, treeErr := repo.entries(ctx)
+		if treeErr != nil {
+			return treeErr
+		}
+		current, readErr := repo.readMetadata(tree, key)
+		if readErr != nil {
+			return readErr
+		}
+		if maps.Equal(current, metadata) {
+			// Nothing changes, so there is nothing to commit either
+			return nil
+		}
+		return repo.executeBlobManipulationJob(ctx, blobOperation, jobTextProvider, vcblobstore.Author{Name: modifiedBy})
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to update metadata of blob %s in git repository: %w", key, err)
+	}
+	repo.access.RecordWrite(key)
+	return nil
 }
 
-func (repo Git) createInitializeGitRepo() error {
-	var err error
-	var out string
+func (repo *Git) CopyBlob(ctx context.Context, sourceKey string, destinationKey string, modifiedBy string) error {
+	if keyErr := vcblobstore.ValidateBlobKey(repo.naming, destinationKey); keyErr != nil {
+		return keyErr
+	}
+	jobTextProvider := gitJobMessages{
+		"copy blob file",
+		"blob file version added",
+	}
 
-	cmds := []ExecCmdParams{
-		{Name: "rm", Args: []string{"-rf", repo.location}, Opts: nil},
-		{Name: "mkdir", Args: []string{"-p", repo.location}, Opts: nil},
-		{Name: "git", Args: []string{"init"}, Opts: &CmdOpts{Cwd: repo.location}},
+	sourcePath, srcPathErr := repo.entryPath(sourceKey)
+	if srcPathErr != nil {
+		return srcPathErr
+	}
+	destinationPath, destPathErr := repo.entryPath(destinationKey)
+	if destPathErr != nil {
+		return destPathErr
 	}
 
-	for _, cmd := range cmds {
-		out, err = ExecuteCommand(cmd, repo.logger)
-		println(out)
+	blobOperation := func(tree entryWriter) error {
+		content, found, readErr := tree.read(sourcePath)
+		if readErr == nil && !found {
+			readErr = vcblobstore.ErrBlobNotFound
+		}
+		if readErr != nil {
+			return fmt.Errorf("failed to read %s to copy it: %w", sourceKey, readErr)
+		}
+		mode, _, modeErr := tree.mode(sourcePath)
+		if modeErr != nil {
+			return modeErr
+		}
+		err := tree.write(destinationPath, content, mode)
 		if err != nil {
-			return fmt.Errorf("failed to create git repo at %s: %w", repo.location, err)
+			return fmt.Errorf("failed to copy file contents from %s to %s: %w", sourceKey, destinationKey, err)
+		}
+		metadata, metadataErr := repo.readMetadata(tree, sourceKey)
+		if metadataErr != nil {
+			return metadataErr
 		}
+		return repo.writeMetadata(tree, destinationKey, metadata)
 	}
 
+	err := repo.queue.enqueue(ctx, func(ctx context.Context) error {
+		return repo.executeBlobManipulationJob(ctx, blobOperation, jobTextProvider, vcblobstore.Author{Name: modifiedBy})
+	})
+
+	if err != nil {
+		repo.logger.Debug().Err(err).Msg("executeBlobManipulationJob failed while copying blob")
+		return fmt.Errorf("failed to copy blobfile from %s to %s to git repository at %s: %w", sourceKey, destinationKey, repo.location, err)
+	}
+	repo.access.RecordWrite(destinationKey)
 	return nil
 }
 
-func (repo Git) LocationHasRepo() bool {
-	if GitRepoLocationExists(repo.location) {
-		testCommand := ExecCmdParams{Name: "git", Args: []string{"init"}, Opts: &CmdOpts{Cwd: repo.location}}
-		outOrErr, err := ExecuteCommand(testCommand, repo.logger)
-		if err != nil {
-			if strings.Contains(outOrErr, "not a git repository") { // TODO: Is it really possible to get this error message here?
-				return false
-			}
-			panic(err)
-		}
-		return true
+func (repo *Git) GetBlob(ctx context.Context, key string) ([]byte, error) {
+	path, pathErr := repo.entryPath(key)
+	if pathErr != nil {
+		return nil, pathErr
 	}
-	return false
-}
 
-// Init initializes the Git repository if it already doesn't exist
-func (repo Git) initMaybe() error {
-	if !repo.LocationHasRepo() {
-		return repo.createInitializeGitRepo()
+	tree, treeErr := repo.entries(ctx)
+	if treeErr != nil {
+		return nil, treeErr
 	}
-	return nil
+	bytes, found, err := tree.read(path)
+	if err == nil && !found {
+		canonicalKey, resolveErr := repo.ResolveAlias(ctx, key)
+		if resolveErr != nil {
+			return nil, resolveErr
+		}
+		if canonicalKey != key {
+			return repo.GetBlob(ctx, canonicalKey)
+		}
+		return nil, fmt.Errorf("failed to read file %s from local git repo: %w", path, vcblobstore.ErrBlobNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s from local git repo: %w", path, err)
+	}
+	repo.access.RecordRead(key)
+	content, lfsErr := repo.resolveLFS(ctx, bytes)
+	if lfsErr != nil {
+		return nil, lfsErr
+	}
+	return repo.external.Resolve(ctx, content)
 }
 
-func (repo *Git) pathToFile(key string) (string, error) {
-	// if strings.Contains(key, "/") {
-	// 	return "", fmt.Errorf("invalid character in key: forward slash ('/')")
-	// }
-	return filepath.Join(repo.location, key), nil
+// HotKeys returns the topN most frequently accessed keys. It is empty unless access tracking is configured
+func (repo *Git) HotKeys(ctx context.Context, topN int) []vcblobstore.AccessStats {
+	return repo.access.HotKeys(topN)
 }
 
-func GitRepoLocationExists(location string) bool {
-	var err error
-	var fi os.FileInfo
+// CollectExternalGarbage deletes the objects of the external storage which are referenced by no version of any blob
+func (repo *Git) CollectExternalGarbage(ctx context.Context) ([]string, error) {
+	if repo.external == nil {
+		return []string{}, nil
+	}
+
+	output, logErr := repo.ExecuteGitCommand(ctx, []string{"log", "--all", "-p", "--format=", `-G^[{]"object":"`})
+	if logErr != nil {
+		return nil, fmt.Errorf("failed to look up pointers to external objects: %w", logErr)
+	}
+
+	referenced := map[string]bool{}
+	for _, line := range strings.Split(output, config.LineBreak) {
+		if !strings.HasPrefix(line, "+{") && !strings.HasPrefix(line, "-{") {
+			continue
+		}
+		if objectName, ok := vcblobstore.ExternalObjectOf([]byte(vcblobstore.ExternalPointerHeader + line[1:])); ok {
+			referenced[objectName] = true
+		}
+	}
+
+	return repo.external.CollectGarbage(ctx, referenced)
+}
+
+// aliasLookup returns the function looking up the target of an alias as of the last commit
+func (repo *Git) aliasLookup(ctx context.Context) func(key string) (string, bool, error) {
+	return func(key string) (string, bool, error) {
+		tree, treeErr := repo.entries(ctx)
+		if treeErr != nil {
+			return "", false, treeErr
+		}
+		return repo.lookupAlias(tree, key)
+	}
+}
+
+func (repo *Git) lookupAlias(tree entryReader, key string) (string, bool, error) {
+	path, pathErr := repo.entryPath(repo.naming.AliasPointerKey(key))
+	if pathErr != nil {
+		return "", false, pathErr
+	}
+
+	content, found, readErr := tree.read(path)
+	if readErr != nil {
+		return "", false, fmt.Errorf("failed to read alias pointer %s: %w", path, readErr)
+	}
+	if !found {
+		return "", false, nil
+	}
+
+	target, decodeErr := vcblobstore.DecodeAliasPointer(content)
+	if decodeErr != nil {
+		return "", false, fmt.Errorf("failed to read alias %s: %w", key, decodeErr)
+	}
+	return target, true, nil
+}
+
+// CreateAlias makes reads of the alias key redirect to target
+func (repo *Git) CreateAlias(ctx context.Context, alias string, target string, modifiedBy string) error {
+	loopErr := vcblobstore.CheckNewAlias(alias, target, repo.aliasLookup(ctx))
+	if loopErr != nil {
+		return fmt.Errorf("failed to create alias %s -> %s: %w", alias, target, loopErr)
+	}
+
+	pointer, encodeErr := vcblobstore.EncodeAliasPointer(target)
+	if encodeErr != nil {
+		return encodeErr
+	}
+
+	return repo.addBlob(ctx, vcblobstore.BlobInfo{
+		Key:        repo.naming.AliasPointerKey(alias),
+		Content:    pointer,
+		ModifiedBy: modifiedBy,
+	})
+}
+
+// ResolveAlias returns the canonical key the key redirects to (the key itself in case it is not an alias)
+func (repo *Git) ResolveAlias(ctx context.Context, key string) (string, error) {
+	return vcblobstore.ResolveAlias(key, repo.aliasLookup(ctx))
+}
+
+func (repo *Git) ListAliases(ctx context.Context) ([]vcblobstore.Alias, error) {
+	pointerKeys, listErr := repo.listKeys(ctx, repo.naming.AliasDirectory())
+	if listErr != nil {
+		return nil, fmt.Errorf("failed to list alias pointers: %w", listErr)
+	}
+
+	tree, treeErr := repo.entries(ctx)
+	if treeErr != nil {
+		return nil, treeErr
+	}
+	return repo.aliasesOf(tree, pointerKeys)
+}
+
+// ListAliasesAtVersion returns the aliases as of the version
+func (repo *Git) ListAliasesAtVersion(ctx context.Context, commitId string) ([]vcblobstore.Alias, error) {
+	if fetchErr := repo.ensureVersion(ctx, commitId); fetchErr != nil {
+		return nil, fetchErr
+	}
+	pointerKeys, listErr := repo.listKeysAt(ctx, commitId, repo.naming.AliasDirectory())
+	if listErr != nil {
+		return nil, fmt.Errorf("failed to list alias pointers at %s: %w", commitId, listErr)
+	}
+	return repo.aliasesOf(refTree{ctx, repo, commitId}, pointerKeys)
+}
+
+// aliasesOf returns the aliases the pointer entries of the tree define
+func (repo *Git) aliasesOf(tree entryReader, pointerKeys []string) ([]vcblobstore.Alias, error) {
+	aliases := []vcblobstore.Alias{}
+	for _, pointerKey := range pointerKeys {
+		alias := repo.naming.AliasFromPointerKey(pointerKey)
+		target, _, lookupErr := repo.lookupAlias(tree, alias)
+		if lookupErr != nil {
+			return nil, lookupErr
+		}
+		aliases = append(aliases, vcblobstore.Alias{Alias: alias, Target: target})
+	}
+	return aliases, nil
+}
+
+func (repo *Git) deleteBlob(tree entryWriter, key string) error {
+	path, pathErr := repo.entryPath(key)
+	if pathErr != nil {
+		return pathErr
+	}
+
+	removed, removeFileErr := tree.remove(path)
+	if removeFileErr != nil {
+		return fmt.Errorf("failed to remove blob %s: %w", key, removeFileErr)
+	}
+	if !removed {
+		return fmt.Errorf("failed to remove blob %s: %w", key, vcblobstore.ErrBlobNotFound)
+	}
+	return repo.writeMetadata(tree, key, nil)
+}
+
+func (repo *Git) DeleteBlob(ctx context.Context, key string, modifiedBy string) error {
+	if keyErr := vcblobstore.ValidateBlobKey(repo.naming, key); keyErr != nil {
+		return keyErr
+	}
+	blobOperation := func(tree entryWriter) error {
+		deletionError := repo.deleteBlob(tree, key)
+		return deletionError
+	}
+
+	jobTextProvider := gitJobMessages{
+		fmt.Sprintf("delete blob %s", key),
+		"blob deleted",
+	}
+
+	err := repo.queue.enqueue(ctx, func(ctx context.Context) error {
+		return repo.executeBlobManipulationJob(ctx, blobOperation, jobTextProvider, vcblobstore.Author{Name: modifiedBy})
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to remove blob %s from git repository: %w", key, err)
+	}
+	repo.access.RecordWrite(key)
+	return nil
+}
+
+// DeleteBlobs removes all the specified blobs in a single commit
+func (repo *Git) DeleteBlobs(ctx context.Context, keys []string, modifiedBy string) error {
+	for _, key := range keys {
+		if keyErr := vcblobstore.ValidateBlobKey(repo.naming, key); keyErr != nil {
+			return keyErr
+		}
+	}
+	blobOperation := func(tree entryWriter) error {
+		for _, key := range keys {
+			deletionError := repo.deleteBlob(tree, key)
+			if deletionError != nil {
+				return deletionError
+			}
+		}
+		return nil
+	}
+
+	jobTextProvider := gitJobMessages{
+		fmt.Sprintf("delete %d blobs", len(keys)),
+		fmt.Sprintf("%d blobs deleted", len(keys)),
+	}
+
+	err := repo.queue.enqueue(ctx, func(ctx context.Context) error {
+		return repo.executeBlobManipulationJob(ctx, blobOperation, jobTextProvider, vcblobstore.Author{Name: modifiedBy})
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to remove %d blobs from git repository: %w", len(keys), err)
+	}
+	repo.access.RecordWrite(keys...)
+	return nil
+}
+
+// RestoreBlob commits the content the blob had at the specified version as its new version
+func (repo *Git) RestoreBlob(ctx context.Context, key string, commitId string, modifiedBy string) error {
+	if keyErr := vcblobstore.ValidateBlobKey(repo.naming, key); keyErr != nil {
+		return keyErr
+	}
+	if fetchErr := repo.ensureVersion(ctx, commitId); fetchErr != nil {
+		return fetchErr
+	}
+	content, found, contentErr := repo.getBlobAtRef(ctx, key, commitId)
+	if contentErr == nil && !found {
+		contentErr = vcblobstore.ErrBlobNotFound
+	}
+	if contentErr != nil {
+		return fmt.Errorf("failed to restore blob %s to version %s: %w", key, commitId, contentErr)
+	}
+	path, pathErr := repo.entryPath(key)
+	if pathErr != nil {
+		return pathErr
+	}
+	// The blob gets back the mode of the version too, not the one of its current entry
+	mode, _, modeErr := refTree{ctx, repo, commitId}.mode(path)
+	if modeErr != nil {
+		return fmt.Errorf("failed to restore blob %s to version %s: %w", key, commitId, modeErr)
+	}
+
+	blobOperation := func(tree entryWriter) error {
+		return repo.createBlob(tree, key, content, mode)
+	}
+
+	jobTextProvider := gitJobMessages{
+		fmt.Sprintf("restore blob %s to version %s", key, commitId),
+		fmt.Sprintf("blob %s restored to version %s", key, commitId),
+	}
+
+	err := repo.queue.enqueue(ctx, func(ctx context.Context) error {
+		return repo.executeBlobManipulationJob(ctx, blobOperation, jobTextProvider, vcblobstore.Author{Name: modifiedBy})
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to restore blob %s to version %s in git repository: %w", key, commitId, err)
+	}
+	repo.access.RecordWrite(key)
+	return nil
+}
+
+func (repo *Git) renameBlob(tree entryWriter, oldKey string, newKey string) error {
+	oldPath, oldPathErr := repo.entryPath(oldKey)
+	if oldPathErr != nil {
+		return oldPathErr
+	}
+	newPath, newPathErr := repo.entryPath(newKey)
+	if newPathErr != nil {
+		return newPathErr
+	}
+
+	moveErr := moveEntry(tree, oldPath, newPath)
+	if moveErr != nil {
+		return fmt.Errorf("failed to rename blob %s: %w", oldKey, moveErr)
+	}
+
+	metadata, metadataErr := repo.readMetadata(tree, oldKey)
+	if metadataErr != nil {
+		return metadataErr
+	}
+	removeErr := repo.writeMetadata(tree, oldKey, nil)
+	if removeErr != nil {
+		return removeErr
+	}
+	return repo.writeMetadata(tree, newKey, metadata)
+}
+
+// moveEntry moves the entry to the new path. Git tells the move from a deletion and an addition by the content
+func moveEntry(tree entryWriter, oldPath string, newPath string) error {
+	content, found, readErr := tree.read(oldPath)
+	if readErr != nil {
+		return readErr
+	}
+	if !found {
+		return vcblobstore.ErrBlobNotFound
+	}
+	mode, _, modeErr := tree.mode(oldPath)
+	if modeErr != nil {
+		return modeErr
+	}
+	if writeErr := tree.write(newPath, content, mode); writeErr != nil {
+		return writeErr
+	}
+	_, removeErr := tree.remove(oldPath)
+	return removeErr
+}
+
+// RenameBlob moves the blob to a new key
+func (repo *Git) RenameBlob(ctx context.Context, oldKey string, newKey string, modifiedBy string) error {
+	for _, key := range []string{oldKey, newKey} {
+		if keyErr := vcblobstore.ValidateBlobKey(repo.naming, key); keyErr != nil {
+			return keyErr
+		}
+	}
+	blobOperation := func(tree entryWriter) error {
+		return repo.renameBlob(tree, oldKey, newKey)
+	}
+
+	jobTextProvider := gitJobMessages{
+		fmt.Sprintf("rename blob %s to %s", oldKey, newKey),
+		"blob renamed",
+	}
+
+	err := repo.queue.enqueue(ctx, func(ctx context.Context) error {
+		return repo.executeBlobManipulationJob(ctx, blobOperation, jobTextProvider, vcblobstore.Author{Name: modifiedBy})
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to rename blob %s to %s in git repository: %w", oldKey, newKey, err)
+	}
+	repo.access.RecordWrite(oldKey, newKey)
+	return nil
+}
+
+// SelfTest exercises writing, reading and deleting a probe blob and reports the outcome and the latency of each step
+func (repo *Git) SelfTest(ctx context.Context, modifiedBy string) vcblobstore.SelfTestReport {
+	return vcblobstore.RunSelfTest(ctx, repo, repo.naming, modifiedBy)
+}
+
+func (repo Git) CheckStatus() (bool, error) {
+	if repo.bare {
+		// Nothing but the commits change a bare repository
+		return true, nil
+	}
+	out, err := repo.ExecuteGitCommand(context.Background(), []string{"status"})
+	if err != nil {
+		return false, fmt.Errorf("failed to get current git commit: %w", err)
+	}
+	status := strings.TrimSpace(out)
+	return strings.Contains(status, cleanStatusMessageTail), nil
+}
+
+func (repo Git) GetStateID(ctx context.Context) (string, error) {
+	ref, refErr := repo.branchRef(ctx)
+	if refErr != nil {
+		return "", refErr
+	}
+	out, err := repo.ExecuteGitCommand(ctx, []string{"rev-parse", ref})
+	if err != nil {
+		return "", fmt.Errorf("failed to get current git commit: %w", err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// listedFile is a file listed by ls-tree
+type listedFile struct {
+	path       string
+	mode       vcblobstore.FileMode
+	objectType string
+	object     string
+}
+
+// parseListedFile parses an entry of the -z output of ls-tree. Unlike the default output, the paths are neither
+// quoted nor escaped, so those with tabs, quotes or non-ASCII characters come out verbatim
+func parseListedFile(entry string) (listedFile, bool) {
+	// <mode> SP <type> SP <object> TAB <file>
+	objectInfo, path, found := strings.Cut(entry, "\t")
+	fields := strings.Fields(objectInfo)
+	if !found || len(fields) < 3 || len(path) == 0 {
+		return listedFile{}, false
+	}
+	return listedFile{path: path, mode: vcblobstore.FileMode(fields[0]), objectType: fields[1], object: fields[2]}, true
+}
+
+// parseListedFiles parses the -z output of ls-tree: NUL terminated entries
+func parseListedFiles(output string) []listedFile {
+	files := []listedFile{}
+	for _, entry := range strings.Split(output, "\x00") {
+		if file, ok := parseListedFile(entry); ok {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// listFiles returns the files in the directory of the repository
+func (repo Git) listFiles(ctx context.Context, directory string) ([]listedFile, error) {
+	ref, refErr := repo.branchRef(ctx)
+	if refErr != nil {
+		return nil, refErr
+	}
+	return repo.listFilesAt(ctx, ref, directory)
+}
+
+// listFilesAt returns the files in the directory of the repository as of the ref
+func (repo Git) listFilesAt(ctx context.Context, ref string, directory string) ([]listedFile, error) {
+	args := []string{"ls-tree", "-r", "-z", ref}
+	if len(directory) > 0 {
+		args = append(args, "--", directory)
+	}
+
+	output, err := repo.ExecuteGitCommand(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return parseListedFiles(output), nil
+}
+
+// listPaths returns the paths of the files in the directory of the repository
+func (repo Git) listPaths(ctx context.Context, directory string) ([]string, error) {
+	files, err := repo.listFiles(ctx, directory)
+	if err != nil {
+		return nil, err
+	}
+
+	fileList := []string{}
+	for _, file := range files {
+		fileList = append(fileList, file.path)
+	}
+	return fileList, nil
+}
+
+// listKeys returns the keys in the directory. The files outside the sharded layout (if configured) are left out
+func (repo Git) listKeys(ctx context.Context, directory string) ([]string, error) {
+	ref, refErr := repo.branchRef(ctx)
+	if refErr != nil {
+		return nil, refErr
+	}
+	return repo.listKeysAt(ctx, ref, directory)
+}
+
+// listKeysAt returns the keys in the directory as of the ref
+func (repo Git) listKeysAt(ctx context.Context, ref string, directory string) ([]string, error) {
+	files, err := repo.listFilesAt(ctx, ref, repo.sharding.ListingDirectory(repo.naming, directory))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	for _, file := range files {
+		if key, ok := repo.sharding.KeyOf(repo.naming, file.path); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (repo Git) ListBlobKeys(ctx context.Context, opts vcblobstore.ListOptions) ([]string, error) {
+	files, err := repo.listFiles(ctx, repo.sharding.ListingDirectory(repo.naming, opts.Directory()))
+	if err != nil {
+		return nil, err
+	}
+
+	fileList := []string{}
+	for _, file := range files {
+		key, ok := repo.sharding.KeyOf(repo.naming, file.path)
+		if ok && opts.Matches(repo.naming, key) && opts.MatchesMode(file.mode) {
+			fileList = append(fileList, key)
+		}
+	}
+	return fileList, nil
+}
+
+// IterateBlobKeys lazily walks the keys of the repository as they are listed by git
+func (repo Git) IterateBlobKeys(ctx context.Context, opts vcblobstore.ListOptions) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		ref, refErr := repo.branchRef(ctx)
+		if refErr != nil {
+			yield("", refErr)
+			return
+		}
+		args := []string{"ls-tree", "-r", "-z", ref}
+		if directory := repo.sharding.ListingDirectory(repo.naming, opts.Directory()); len(directory) > 0 {
+			args = append(args, "--", directory)
+		}
+
+		stopped := false
+		streamErr := StreamCommandRecords(ctx, ExecCmdParams{
+			Name: repo.gitBinary(),
+			Args: repo.gitArgs(args),
+			Opts: &CmdOpts{Cwd: repo.location},
+		}, repo.logger, 0, func(entry string) bool {
+			if ctx.Err() != nil {
+				return false
+			}
+			file, listed := parseListedFile(entry)
+			if !listed {
+				return true
+			}
+			key, ok := repo.sharding.KeyOf(repo.naming, file.path)
+			if !ok || len(key) == 0 || !opts.Matches(repo.naming, key) || !opts.MatchesMode(file.mode) {
+				return true
+			}
+			if !yield(key, nil) {
+				stopped = true
+				return false
+			}
+			return true
+		})
+		if stopped {
+			return
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			yield("", ctxErr)
+			return
+		}
+		if streamErr != nil {
+			yield("", fmt.Errorf("failed to list blob keys: %w", streamErr))
+		}
+	}
+}
+
+// GetTree returns the hierarchy of directories and blobs under prefix down to depth levels (depth < 1 means no limit)
+func (repo Git) GetTree(ctx context.Context, prefix string, depth int) (*vcblobstore.TreeNode, error) {
+	ref, refErr := repo.branchRef(ctx)
+	if refErr != nil {
+		return nil, refErr
+	}
+	trimmedPrefix := repo.sharding.ListingDirectory(repo.naming, strings.Trim(prefix, "/"))
+	files, err := repo.listFilesAt(ctx, ref, trimmedPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tree under %s: %w", prefix, err)
+	}
+
+	entries := []vcblobstore.TreeEntry{}
+	for _, file := range files {
+		if file.objectType != "blob" {
+			continue
+		}
+		key, ok := repo.sharding.KeyOf(repo.naming, file.path)
+		if !ok {
+			continue
+		}
+		entries = append(entries, vcblobstore.TreeEntry{Path: key, BlobId: file.object})
+	}
+
+	return vcblobstore.BuildTree(repo.naming, prefix, depth, entries), nil
+}
+
+// GetVersionFor returns the commit ID of the blob specified by the method paramters.
+// Return empty string in case the file doesn't exist in the repository
+func (repo Git) GetVersionFor(ctx context.Context, key string) (string, error) {
+	path, pathErr := repo.entryPath(key)
+	if pathErr != nil {
+		return "", pathErr
+	}
+
+	ref, refErr := repo.branchRef(ctx)
+	if refErr != nil {
+		return "", refErr
+	}
+	printCommitIDArgs := []string{"log", "-n", "1", "--pretty=format:%H", ref, "--", path}
+	output, execErr := repo.ExecuteGitCommand(ctx, printCommitIDArgs)
+	if execErr != nil {
+		return "", fmt.Errorf("failed to execute command to get last commit modifying %s: %w", key, execErr)
+	}
+	return output, nil
+}
+
+// HeadBlob returns the size and the current version of the blob without reading its content.
+// The returned BlobHead has Exists set to false in case the blob isn't tracked in the repository
+func (repo Git) HeadBlob(ctx context.Context, key string) (vcblobstore.BlobHead, error) {
+	blobHead := vcblobstore.BlobHead{Key: key}
+
+	path, pathErr := repo.entryPath(key)
+	if pathErr != nil {
+		return blobHead, pathErr
+	}
+
+	tree, treeErr := repo.entries(ctx)
+	if treeErr != nil {
+		return blobHead, treeErr
+	}
+	if _, checkedOut := tree.(workTree); checkedOut {
+		output, execErr := repo.ExecuteGitCommand(ctx, []string{"ls-files", "--", path})
+		if execErr != nil {
+			return blobHead, fmt.Errorf("failed to execute command to check whether %s is tracked: %w", key, execErr)
+		}
+		if len(strings.TrimSpace(output)) == 0 {
+			return blobHead, nil
+		}
+	}
+
+	size, found, statErr := tree.size(path)
+	if statErr != nil {
+		return blobHead, fmt.Errorf("failed to stat file %s in local git repo: %w", path, statErr)
+	}
+	if !found {
+		return blobHead, nil
+	}
+	mode, _, modeErr := tree.mode(path)
+	if modeErr != nil {
+		return blobHead, modeErr
+	}
+
+	version, versionErr := repo.GetVersionFor(ctx, key)
+	if versionErr != nil {
+		return blobHead, versionErr
+	}
+
+	blobHead.Exists = true
+	blobHead.Size = size
+	blobHead.Version = version
+	blobHead.Mode = mode
+	return blobHead, nil
+}
+
+func (repo Git) GetVersionMetadata(ctx context.Context, commitId string) (git.CommitMetadata, error) {
+	logger := repo.logger.With().Str("method", fmt.Sprintf("git: GetVersionMetadata: %s", commitId)).Logger()
+
+	if fetchErr := repo.ensureVersion(ctx, commitId); fetchErr != nil {
+		return git.CommitMetadata{}, fetchErr
+	}
+	printCommitMetadataArgs := []string{"show", "--quiet", "--format=fuller", "--date=format:%Y-%m-%dT%H:%M:%S%z", commitId}
+	output, execErr := repo.ExecuteGitCommand(ctx, printCommitMetadataArgs)
+	if execErr != nil {
+		return git.CommitMetadata{}, fmt.Errorf("failed to get metadata from repo for commit %s: %w", commitId, execErr)
+	}
+	logger.Debug().Str("meta-data", output).Msg("raw metadata extracted")
+	commitMetadata, parseErr := git.ParseLocalCommitMetadata(output)
+	if parseErr != nil {
+		return commitMetadata, fmt.Errorf("failed to parse metadata from commit %s: %w", commitId, parseErr)
+	}
+	changes, changesErr := repo.listCommitChanges(ctx, commitId)
+	if changesErr != nil {
+		return git.CommitMetadata{}, changesErr
+	}
+	commitMetadata.Changes = changes
+	return commitMetadata, nil
+}
+
+// listVersionsFor returns the IDs of the commits of the selected branch which modified the blob, oldest first
+func (repo Git) listVersionsFor(ctx context.Context, key string) ([]string, error) {
+	ref, refErr := repo.branchRef(ctx)
+	if refErr != nil {
+		return nil, refErr
+	}
+	output, execErr := repo.ExecuteGitCommand(ctx, []string{"log", "--reverse", "--format=%H", ref, "--", repo.repoPath(key)})
+	if execErr != nil {
+		return nil, fmt.Errorf("failed to execute command to list commits modifying %s: %w", key, execErr)
+	}
+
+	versions := []string{}
+	for _, line := range strings.Split(output, config.LineBreak) {
+		trimmedLine := strings.TrimSpace(line)
+		if len(trimmedLine) > 0 {
+			versions = append(versions, trimmedLine)
+		}
+	}
+	return versions, nil
+}
+
+// GetBlobAtVersion returns the content of the blob as it existed at the specified commit
+func (repo Git) GetBlobAtVersion(ctx context.Context, key string, commitId string) ([]byte, error) {
+	if fetchErr := repo.ensureVersion(ctx, commitId); fetchErr != nil {
+		return nil, fetchErr
+	}
+	content, found, err := repo.getBlobAtRef(ctx, key, commitId)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("failed to read %s at version %s from local git repo: %w", key, commitId, vcblobstore.ErrBlobNotFound)
+	}
+	content, lfsErr := repo.resolveLFS(ctx, content)
+	if lfsErr != nil {
+		return nil, lfsErr
+	}
+	return repo.external.Resolve(ctx, content)
+}
+
+// GetBlobMetadataAtVersion returns the metadata of the blob as it was at the version
+func (repo *Git) GetBlobMetadataAtVersion(ctx context.Context, key string, commitId string) (map[string]string, error) {
+	if fetchErr := repo.ensureVersion(ctx, commitId); fetchErr != nil {
+		return nil, fetchErr
+	}
+	path, pathErr := repo.entryPath(key)
+	if pathErr != nil {
+		return nil, pathErr
+	}
+	tree := refTree{ctx, repo, commitId}
+	_, found, sizeErr := tree.size(path)
+	if sizeErr != nil {
+		return nil, fmt.Errorf("failed to look up %s at version %s: %w", key, commitId, sizeErr)
+	}
+	if !found {
+		return nil, fmt.Errorf("failed to read the metadata of %s at version %s from local git repo: %w", key, commitId, vcblobstore.ErrBlobNotFound)
+	}
+	return repo.readMetadata(tree, key)
+}
+
+// GetBlobModeAtVersion returns the file mode of the blob as it was at the version
+func (repo *Git) GetBlobModeAtVersion(ctx context.Context, key string, commitId string) (vcblobstore.FileMode, error) {
+	if fetchErr := repo.ensureVersion(ctx, commitId); fetchErr != nil {
+		return "", fetchErr
+	}
+	path, pathErr := repo.entryPath(key)
+	if pathErr != nil {
+		return "", pathErr
+	}
+	mode, found, modeErr := refTree{ctx, repo, commitId}.mode(path)
+	if modeErr != nil {
+		return "", fmt.Errorf("failed to look up %s at version %s: %w", key, commitId, modeErr)
+	}
+	if !found {
+		return "", fmt.Errorf("failed to read the mode of %s at version %s from local git repo: %w", key, commitId, vcblobstore.ErrBlobNotFound)
+	}
+	return mode, nil
+}
+
+// getBlobAtRef returns the content of the blob as of the specified ref and false in case the blob doesn't exist at that ref
+func (repo Git) getBlobAtRef(ctx context.Context, key string, ref string) ([]byte, bool, error) {
+	content, found, readErr := readObject(ctx, &repo, nil, fmt.Sprintf("%s:%s", ref, repo.repoPath(key)))
+	if readErr != nil {
+		return nil, false, fmt.Errorf("failed to read %s at %s from local git repo: %w", key, ref, readErr)
+	}
+	return content, found, nil
+}
+
+var diffFilterByOperation = map[vcblobstore.BlobOperation]string{
+	vcblobstore.BlobOperationCreate: "A",
+	vcblobstore.BlobOperationUpdate: "M",
+	vcblobstore.BlobOperationDelete: "D",
+}
+
+var operationByChangeStatus = map[string]vcblobstore.BlobOperation{
+	"A": vcblobstore.BlobOperationCreate,
+	"M": vcblobstore.BlobOperationUpdate,
+	"D": vcblobstore.BlobOperationDelete,
+}
+
+const (
+	logRecordSeparator = "\x1e"
+	logFieldSeparator  = "\x1f"
+)
+
+// GetBlobHistory returns the versions of the blob matching the filter, newest first
+func (repo Git) GetBlobHistory(ctx context.Context, key string, filter vcblobstore.HistoryFilter) ([]vcblobstore.BlobVersion, error) {
+	args := []string{"log", "--no-renames", "--name-status", "-z", "--format=%x1e%H%x1f%an <%ae>%x1f%aI%x1f%B%x1f"}
+	if len(filter.Author) > 0 || len(filter.MessageContains) > 0 {
+		args = append(args, "--fixed-strings")
+	}
+	if len(filter.Author) > 0 {
+		args = append(args, "--author="+filter.Author)
+	}
+	if len(filter.MessageContains) > 0 {
+		args = append(args, "--grep="+filter.MessageContains)
+	}
+	// Since and Until are left to filter.Matches: git log --since and --until compare the committer dates, which
+	// differ from the author dates e.g. for imported or rebased commits
+	if len(filter.Operations) > 0 {
+		diffFilter := ""
+		for _, operation := range filter.Operations {
+			diffFilter += diffFilterByOperation[operation]
+		}
+		args = append(args, "--diff-filter="+diffFilter)
+	}
+	ref, refErr := repo.branchRef(ctx)
+	if refErr != nil {
+		return nil, refErr
+	}
+	if len(filter.SinceVersion) > 0 {
+		ref = filter.SinceVersion + ".." + ref
+	}
+	args = append(args, ref, "--", repo.repoPath(key))
+
+	output, execErr := repo.ExecuteGitCommand(ctx, args)
+	if execErr != nil {
+		return nil, fmt.Errorf("failed to execute command to get the history of %s: %w", key, execErr)
+	}
+
+	versions := []vcblobstore.BlobVersion{}
+	for _, record := range strings.Split(output, logRecordSeparator) {
+		fields := strings.SplitN(record, logFieldSeparator, 5)
+		if len(fields) < 5 {
+			continue
+		}
+
+		authorDate, parseErr := time.Parse(time.RFC3339, fields[2])
+		if parseErr != nil {
+			return nil, fmt.Errorf("failed to parse author date of commit %s: %w", fields[0], parseErr)
+		}
+
+		version := vcblobstore.BlobVersion{
+			Version:    fields[0],
+			Author:     fields[1],
+			AuthorDate: authorDate,
+			Message:    strings.TrimSpace(fields[3]),
+		}
+		for _, change := range parseNameStatus(fields[4]) {
+			if change.path == repo.repoPath(key) {
+				version.Operation = operationByChangeStatus[change.status]
+			}
+		}
+
+		if filter.Matches(version) {
+			versions = append(versions, version)
+		}
+	}
+	return versions, nil
+}
+
+// ExportHistory writes every version of the specified blobs to w as a history bundle
+func (repo Git) ExportHistory(ctx context.Context, keys []string, w io.Writer) error {
+	records := []vcblobstore.HistoryRecord{}
+
+	for _, key := range keys {
+		versions, listErr := repo.listVersionsFor(ctx, key)
+		if listErr != nil {
+			return listErr
+		}
+
+		for _, version := range versions {
+			metadata, metadataErr := repo.GetVersionMetadata(ctx, version)
+			if metadataErr != nil {
+				return metadataErr
+			}
+
+			content, exists, contentErr := repo.getBlobAtRef(ctx, key, version)
+			if contentErr != nil {
+				return contentErr
+			}
+			if exists {
+				// The bundle carries the content itself: the pointers are of no use to the store importing it
+				var resolveErr error
+				if content, resolveErr = repo.resolveLFS(ctx, content); resolveErr != nil {
+					return resolveErr
+				}
+				if content, resolveErr = repo.external.Resolve(ctx, content); resolveErr != nil {
+					return resolveErr
+				}
+			}
+
+			records = append(records, vcblobstore.HistoryRecord{
+				Key:        key,
+				Version:    version,
+				Author:     metadata.Author,
+				AuthorDate: metadata.AuthorDate,
+				Message:    metadata.Message,
+				Deleted:    !exists,
+				Content:    content,
+			})
+		}
+	}
+
+	return vcblobstore.WriteHistory(w, records)
+}
+
+// ImportHistory replays the versions of a history bundle as new commits, preserving the order, the authors, the author
+// dates and the messages of the changes
+func (repo *Git) ImportHistory(ctx context.Context, r io.Reader) error {
+	return vcblobstore.ReadHistory(r, func(record vcblobstore.HistoryRecord) error {
+		ctx := vcblobstore.WithImportedRecord(ctx, record)
+		if record.Deleted {
+			return repo.DeleteBlob(ctx, record.Key, record.AuthorName())
+		}
+		return repo.AddBlob(ctx, vcblobstore.BlobInfo{
+			Key:         record.Key,
+			Content:     record.Content,
+			AuthorName:  record.AuthorName(),
+			AuthorEmail: record.AuthorEmail(),
+		})
+	})
+}
+
+func (repo Git) createInitializeGitRepo(ctx context.Context) error {
+	var err error
+	var out string
+
+	cmds := []ExecCmdParams{
+		{Name: "rm", Args: []string{"-rf", repo.location}, Opts: nil},
+		{Name: "mkdir", Args: []string{"-p", repo.location}, Opts: nil},
+		{Name: repo.gitBinary(), Args: repo.gitArgs(repo.initArgs()), Opts: &CmdOpts{Cwd: repo.location}},
+	}
+	if repo.hasRemote() {
+		cmds = cmds[:1]
+	}
+
+	for _, cmd := range cmds {
+		out, err = ExecuteCommand(ctx, cmd, repo.logger)
+		println(out)
+		if err != nil {
+			return fmt.Errorf("failed to create git repo at %s: %w", repo.location, err)
+		}
+	}
+
+	if repo.hasRemote() {
+		return repo.clone(ctx)
+	}
+	return nil
+}
+
+// LocationHasRepo tells whether the location of the repository holds a git repository. It fails if git can't tell
+func (repo Git) LocationHasRepo() (bool, error) {
+	return repo.locationHasRepo(context.Background())
+}
+
+func (repo Git) locationHasRepo(ctx context.Context) (bool, error) {
+	if GitRepoLocationExists(repo.location) {
+		testCommand := ExecCmdParams{Name: repo.gitBinary(), Args: repo.gitArgs(repo.initArgs()), Opts: &CmdOpts{Cwd: repo.location}}
+		outOrErr, err := ExecuteCommand(ctx, testCommand, repo.logger)
+		if err != nil {
+			if strings.Contains(outOrErr, "not a git repository") { // TODO: Is it really possible to get this error message here?
+				return false, nil
+			}
+			return false, fmt.Errorf("failed to probe git repository at %s: %w -> %s", repo.location, err, outOrErr)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+func (repo Git) initArgs() []string {
+	if repo.bare {
+		return []string{"init", "--bare"}
+	}
+	return []string{"init"}
+}
+
+// Init initializes the Git repository if it already doesn't exist
+func (repo Git) initMaybe(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	// Serialized with the blob manipulation jobs: concurrent "git init" probes and initializations would trip over each other
+	return repo.queue.enqueue(ctx, func(ctx context.Context) error {
+		hasRepo, probeErr := repo.locationHasRepo(ctx)
+		if probeErr != nil {
+			return probeErr
+		}
+		if !hasRepo {
+			if createErr := repo.createInitializeGitRepo(ctx); createErr != nil {
+				return createErr
+			}
+		}
+		return repo.installHooks()
+	})
+}
+
+func (repo *Git) pathToFile(key string) (string, error) {
+	if err := vcblobstore.ValidateKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(repo.location, filepath.FromSlash(repo.repoPath(key))), nil
+}
+
+// repoPath returns the path of the entry with the key relative to the root of the repository
+func (repo *Git) repoPath(key string) string {
+	return repo.sharding.PathOf(repo.naming, key)
+}
+
+// removeEmptyParents removes the directories containing the file, which became empty, up to the root of the repository
+func (repo *Git) removeEmptyParents(path string) error {
+	root := filepath.Clean(repo.location)
+	for directory := filepath.Dir(path); directory != root && strings.HasPrefix(directory, root); directory = filepath.Dir(directory) {
+		entries, readErr := os.ReadDir(directory)
+		if readErr != nil {
+			if os.IsNotExist(readErr) {
+				continue
+			}
+			return fmt.Errorf("failed to read directory %s: %w", directory, readErr)
+		}
+		if len(entries) > 0 {
+			return nil
+		}
+		if removeErr := os.Remove(directory); removeErr != nil {
+			return fmt.Errorf("failed to remove empty directory %s: %w", directory, removeErr)
+		}
+	}
+	return nil
+}
+
+func GitRepoLocationExists(location string) bool {
+	var err error
+	var fi os.FileInfo
 
 	fi, err = os.Stat(location)
 	if err != nil {
@@ -429,12 +1675,131 @@ func GitRepoLocationExists(location string) bool {
 
 type Config struct {
 	Location string
+	// Bare makes the repository at Location a bare one: the blobs are kept only in the object database, which
+	// halves the disk usage and leaves no working tree to get out of sync. Bare repositories can't have a remote
+	Bare bool
+	// GitBinary is the git executable to run, looked up in PATH by default
+	GitBinary string
+	// CommitterName and CommitterEmail, if set, identify the committer of the commits regardless of the git config
+	// of the host, e.g. in containers without one. The author of the commits is the user making the change
+	CommitterName  string
+	CommitterEmail string
+	// Branch, if set, is the branch the reads and writes go to instead of the one checked out. It is created from
+	// the latter on the first write. vcblobstore.WithBranch overrides it for a single call
+	Branch string
+	// Hooks selects the git hooks to run (HooksRepository by default), so that the behavior doesn't depend on the
+	// hooks set up on the host
+	Hooks HooksMode
+	// MaintenanceInterval, if set, is how often the repository is checked for housekeeping (packing loose objects,
+	// pruning, etc.), once StartMaintenance is called
+	MaintenanceInterval time.Duration
+	// Retention, if set, is the policy the history is pruned with every RetentionInterval, once StartPruning is
+	// called. Pruning rewrites the history, so it isn't available to working copies of a remote repository
+	Retention         vcblobstore.RetentionPolicy
+	RetentionInterval time.Duration
+	// QueueCapacity is the number of operations which may wait for their turn (128 by default). Further operations
+	// fail with ErrQueueFull
+	QueueCapacity int
+	// JobTimeout, if set, limits the time an operation may wait for its turn and run, unless its context has a deadline
+	JobTimeout time.Duration
+	// TextMode lists the key patterns of text blobs to normalize on write
+	TextMode vcblobstore.TextMode
+	// ExternalStorage, if set, receives the content of oversized blobs
+	ExternalStorage *vcblobstore.ExternalStorage
+	// LFS, if set, selects the blobs to store as Git LFS objects
+	LFS *vcblobstore.LFS
+	// AccessTracker, if set, counts the reads and writes of the keys
+	AccessTracker *vcblobstore.AccessTracker
+	// Naming decides the keys of the entries derived from the blobs (DefaultNaming if unset)
+	Naming vcblobstore.NamingStrategy
+	// Sharding, if set, spreads the blobs over hash prefix directories
+	Sharding *vcblobstore.KeySharding
+	// Reproducible makes the commits fully determined by their inputs: the author and commit dates are pinned to
+	// ReproducibleTimestamp (the Unix epoch if unset) and the committer is the author, so the same sequence of
+	// writes always yields byte-identical commits
+	Reproducible          bool
+	ReproducibleTimestamp time.Time
+	// RemoteURL, if set, is the (SSH, HTTPS or file) URL of the remote git repository the local repository is a
+	// working copy of. The working copy is cloned from it and every commit is pushed to RemoteBranch ("main" if unset)
+	RemoteURL    string
+	RemoteBranch string
+	// CloneDepth, if set, makes the working copy a shallow clone with this many commits of history. Older versions
+	// are fetched when they are read. The history queries see only the fetched commits
+	CloneDepth int
+	// CloneFilter, if set, makes the working copy a partial clone, e.g. "blob:none" fetches the content of the blobs
+	// only when they are read
+	CloneFilter string
+	// PullStrategy tells how the working copy catches up with the remote branch before each write (PullRebase by default)
+	PullStrategy PullStrategy
+	// PushInterval, if set, makes the commits accumulate locally and get pushed together at this interval, once
+	// StartPushing is called, instead of being pushed one by one. Push flushes them on demand. The commits failing
+	// to be pushed are kept for the next attempt, PushHealth reports the failures
+	PushInterval time.Duration
+	// PushOptions are sent with every push (git push -o), e.g. "ci.skip" or "merge_request.create" to a GitLab
+	// remote. The remote has to support push options, otherwise the pushes fail
+	PushOptions []string
+	// SkipCI marks the commits with vcblobstore.SkipCIMarker, so that the remote doesn't run CI pipelines for them.
+	// vcblobstore.WithSkipCI overrides it for a single write
+	SkipCI bool
+	// BeforeCommit, if set, is called once the changes of a write are staged, right before they are committed. Its
+	// error fails the write, which is rolled back like any other failed write: tests inject failures with it
+	BeforeCommit func(ctx context.Context) error
 }
 
-func NewLocalGitRepository(localConfig *Config, logger *zerolog.Logger) *Git {
+// NewLocalGitRepository creates the backend of the local repository described by the config. It fails with
+// ErrGitUnavailable if the git executable is missing or too old
+func NewLocalGitRepository(localConfig *Config, logger *zerolog.Logger) (*Git, error) {
 	git := Git{
 		location: localConfig.Location,
+		bare:     localConfig.Bare,
+		binary:   localConfig.GitBinary,
+		hooks:    localConfig.Hooks,
 		logger:   logger,
+		textMode: localConfig.TextMode,
+		external: localConfig.ExternalStorage,
+		lfs:      localConfig.LFS,
+		access:   localConfig.AccessTracker,
+		naming:   vcblobstore.NamingOrDefault(localConfig.Naming),
+		sharding: localConfig.Sharding,
+
+		storeMetadata: &vcblobstore.StoreMetadataCache{},
+		contentHashes: &vcblobstore.ContentHashCache{},
+
+		reproducible:          localConfig.Reproducible,
+		reproducibleTimestamp: localConfig.ReproducibleTimestamp,
+
+		remoteURL:    localConfig.RemoteURL,
+		remoteBranch: localConfig.RemoteBranch,
+		pushOptions:  localConfig.PushOptions,
+		pushInterval: localConfig.PushInterval,
+		pushStatus:   &pushStatus{},
+		pullStrategy: localConfig.PullStrategy,
+		skipCI:       localConfig.SkipCI,
+		beforeCommit: localConfig.BeforeCommit,
+		committer:    vcblobstore.Author{Name: localConfig.CommitterName, Email: localConfig.CommitterEmail},
+		cloneDepth:   localConfig.CloneDepth,
+		cloneFilter:  localConfig.CloneFilter,
+
+		branch:              localConfig.Branch,
+		maintenanceInterval: localConfig.MaintenanceInterval,
+		retention:           localConfig.Retention,
+		retentionInterval:   localConfig.RetentionInterval,
+	}
+	if len(git.remoteBranch) == 0 {
+		git.remoteBranch = defaultRemoteBranch
+	}
+	if len(git.pullStrategy) == 0 {
+		git.pullStrategy = PullRebase
+	}
+	if git.reproducible && git.reproducibleTimestamp.IsZero() {
+		git.reproducibleTimestamp = time.Unix(0, 0)
+	}
+	if git.bare && git.hasRemote() {
+		return nil, ErrBareRemote
+	}
+	if binaryErr := git.checkGitBinary(context.Background()); binaryErr != nil {
+		return nil, binaryErr
 	}
-	return &git
+	git.queue = newJobQueue(localConfig.QueueCapacity, localConfig.JobTimeout)
+	return &git, nil
 }
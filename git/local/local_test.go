@@ -23,8 +23,8 @@ func TestLocationDoesntExist(t *testing.T) {
 	removeRepoDir()
 	logger := zerolog.New(os.Stdout)
 	gitRepo := Git{location: localTestConfig.Location, logger: &logger}
-	hasRepo := gitRepo.LocationHasRepo()
-	if hasRepo {
-		t.Errorf("gitRepo.locationHasRepo() = %v; want false", hasRepo)
+	hasRepo, probeErr := gitRepo.LocationHasRepo()
+	if hasRepo || probeErr != nil {
+		t.Errorf("gitRepo.LocationHasRepo() = %v, %v; want false, nil", hasRepo, probeErr)
 	}
 }
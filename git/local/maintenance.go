@@ -0,0 +1,48 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// maintenanceCommandVersion is the first git with the maintenance command, older ones only have gc
+var maintenanceCommandVersion = GitVersion{2, 29, 0}
+
+// RunMaintenance does the housekeeping the repository needs, like packing the loose objects, if any. It is not queued
+// with the operations: git is prepared for writes concurrent with its housekeeping
+func (repo *Git) RunMaintenance(ctx context.Context) error {
+	args := []string{"maintenance", "run", "--auto"}
+	if repo.gitVersion.Less(maintenanceCommandVersion) {
+		args = []string{"gc", "--auto"}
+	}
+	started := time.Now()
+	out, err := repo.ExecuteGitCommand(ctx, args)
+	if err != nil {
+		return fmt.Errorf("failed to maintain git repository at %s: %w -> %s", repo.location, err, out)
+	}
+	repo.logger.Debug().Dur("duration", time.Since(started)).Msg("git maintenance done")
+	return nil
+}
+
+// StartMaintenance runs the maintenance every MaintenanceInterval until ctx is done.
+// It does nothing unless the interval is configured
+func (repo *Git) StartMaintenance(ctx context.Context) {
+	if repo.maintenanceInterval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(repo.maintenanceInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if maintenanceErr := repo.RunMaintenance(ctx); maintenanceErr != nil {
+					repo.logger.Error().Err(maintenanceErr).Msg("failed to maintain the repository")
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,195 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"vcblobstore"
+)
+
+// mergeTreeVersion is the first git able to merge without a working tree
+var mergeTreeVersion = GitVersion{2, 38, 0}
+
+// MergeBranch merges the branch from into the branch to without checking out either of them: the merge commits are
+// computed by git merge-tree. The working tree follows the branch checked out. A working copy brings both branches
+// up to date with the remote before and pushes the branch merged into after the merge
+func (repo *Git) MergeBranch(ctx context.Context, from string, to string, modifiedBy string) error {
+	strategy := vcblobstore.MergeStrategyOf(ctx)
+	err := repo.queue.enqueue(ctx, func(ctx context.Context) error {
+		fromRef, fromRefErr := repo.branchRef(vcblobstore.WithBranch(ctx, from))
+		if fromRefErr != nil {
+			return fromRefErr
+		}
+		toRef, toRefErr := repo.branchRef(vcblobstore.WithBranch(ctx, to))
+		if toRefErr != nil {
+			return toRefErr
+		}
+		for _, ref := range []string{fromRef, toRef} {
+			if syncErr := repo.syncBranch(ctx, ref); syncErr != nil {
+				return syncErr
+			}
+		}
+		fromCommit, fromExists := repo.refCommit(ctx, fromRef)
+		if !fromExists {
+			return fmt.Errorf("no such branch %s", from)
+		}
+		toCommit, toExists := repo.refCommit(ctx, toRef)
+		if !toExists {
+			return fmt.Errorf("no such branch %s", to)
+		}
+		if repo.isAncestor(ctx, fromCommit, toCommit) {
+			return nil
+		}
+
+		merged := fromCommit
+		fastForward := repo.isAncestor(ctx, toCommit, fromCommit)
+		switch {
+		case fastForward && strategy != vcblobstore.MergeCommit:
+		case strategy == vcblobstore.MergeFastForward:
+			return vcblobstore.ErrNotFastForward
+		default:
+			var mergeErr error
+			merged, mergeErr = repo.mergeCommit(ctx, from, to, fromCommit, toCommit, modifiedBy)
+			if mergeErr != nil {
+				return mergeErr
+			}
+		}
+
+		if out, updateErr := repo.ExecuteGitCommand(ctx, []string{"update-ref", toRef, merged, toCommit}); updateErr != nil {
+			return fmt.Errorf("%w: failed to update %s: %w -> %s", vcblobstore.ErrConflict, to, updateErr, out)
+		}
+		if toRef != "HEAD" {
+			return repo.pushBranch(ctx, toRef)
+		}
+		if !repo.bare {
+			if resetErr := (workTree{ctx: ctx, repo: repo}).resetTo(merged); resetErr != nil {
+				return resetErr
+			}
+		}
+		if !repo.pushesPeriodically() {
+			return repo.push(ctx)
+		}
+		return nil
+	})
+	repo.storeMetadata.Invalidate()
+
+	if err != nil {
+		return fmt.Errorf("failed to merge branch %s into %s in git repository at %s: %w", from, to, repo.location, err)
+	}
+	return nil
+}
+
+// syncBranch brings the branch of a working copy up to date with the remote
+func (repo *Git) syncBranch(ctx context.Context, ref string) error {
+	if ref == "HEAD" {
+		return repo.pull(ctx)
+	}
+	return repo.fetchBranch(ctx, ref)
+}
+
+// isAncestor tells whether the commit is an ancestor of (or the same as) the other one
+func (repo *Git) isAncestor(ctx context.Context, commit string, otherCommit string) bool {
+	_, err := repo.ExecuteGitCommand(ctx, []string{"merge-base", "--is-ancestor", commit, otherCommit})
+	return err == nil
+}
+
+// mergeCommit commits the merge of the commit of the branch from into the commit of the branch to and returns it.
+// The keys of the blobs the merge conflicts on are returned with a vcblobstore.MergeConflictError. The derived entries
+// (the attribute index, the store metadata) are merged by their meaning instead of line by line
+func (repo *Git) mergeCommit(ctx context.Context, from string, to string, fromCommit string, toCommit string, modifiedBy string) (string, error) {
+	if repo.gitVersion.Less(mergeTreeVersion) {
+		return "", fmt.Errorf("merge commits need git %s or newer", mergeTreeVersion)
+	}
+	out, mergeErr := repo.ExecuteGitCommand(ctx, []string{"merge-tree", "--write-tree", "--name-only", "--no-messages", "-z", toCommit, fromCommit})
+	fields := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+	var exitErr *exec.ExitError
+	if mergeErr != nil && (!errors.As(mergeErr, &exitErr) || exitErr.ExitCode() != 1 || len(fields) < 2) {
+		return "", fmt.Errorf("failed to merge %s into %s: %w -> %s", from, to, mergeErr, out)
+	}
+	tree := strings.TrimSpace(fields[0])
+
+	conflicts := vcblobstore.RepositoryVersion{}
+	for _, path := range fields[1:] {
+		if key, ok := repo.sharding.KeyOf(repo.naming, path); ok && !vcblobstore.IsDerivedEntry(repo.naming, key) {
+			conflicts.AddEntryChange(repo.naming, key, vcblobstore.BlobOperationUpdate)
+		}
+	}
+	if len(conflicts.Changes) > 0 {
+		keys := []string{}
+		for _, change := range conflicts.Changes {
+			keys = append(keys, change.Key)
+		}
+		return "", &vcblobstore.MergeConflictError{From: from, To: to, Keys: keys}
+	}
+
+	tree, derivedErr := repo.mergeDerivedEntries(ctx, tree, toCommit, fromCommit)
+	if derivedErr != nil {
+		return "", fmt.Errorf("failed to merge %s into %s: %w", from, to, derivedErr)
+	}
+
+	author := vcblobstore.Author{Name: modifiedBy}
+	env := append([]string{
+		"GIT_AUTHOR_NAME=" + author.Name,
+		"GIT_AUTHOR_EMAIL=" + authorEmail(author),
+	}, repo.commitEnv(author)...)
+	message := commitMessage(fmt.Sprintf("merge branch %s into %s", from, to), author)
+	commit, commitErr := repo.executeGitCommandWithEnv(ctx, []string{"commit-tree", tree, "-p", toCommit, "-p", fromCommit, "-m", message}, env)
+	if commitErr != nil {
+		return "", fmt.Errorf("failed to commit the merge of %s into %s: %w -> %s", from, to, commitErr, commit)
+	}
+	return strings.TrimSpace(commit), nil
+}
+
+// mergeDerivedEntries replaces the derived entries of the merged tree, which git merged line by line (or left with
+// conflict markers), with the ones vcblobstore.MergeDerivedEntry merges from the merge base and both commits
+func (repo *Git) mergeDerivedEntries(ctx context.Context, tree string, toCommit string, fromCommit string) (string, error) {
+	out, baseErr := repo.ExecuteGitCommand(ctx, []string{"merge-base", toCommit, fromCommit})
+	if baseErr != nil {
+		return "", fmt.Errorf("failed to find the merge base: %w -> %s", baseErr, out)
+	}
+	mergeBase := strings.TrimSpace(out)
+
+	indexDirectory, tempErr := os.MkdirTemp("", "vcblobstore-index-")
+	if tempErr != nil {
+		return "", fmt.Errorf("failed to create temporary index: %w", tempErr)
+	}
+	defer os.RemoveAll(indexDirectory)
+	index := bareIndex{ctx, repo, []string{"GIT_INDEX_FILE=" + filepath.Join(indexDirectory, "index")}}
+	if resetErr := index.resetTo(tree); resetErr != nil {
+		return "", resetErr
+	}
+
+	for _, key := range []string{repo.naming.AttributeIndexKey(), repo.naming.StoreMetadataKey()} {
+		contents := [][]byte{}
+		for _, commit := range []string{mergeBase, toCommit, fromCommit} {
+			content, _, readErr := refTree{ctx, repo, commit}.read(key)
+			if readErr != nil {
+				return "", readErr
+			}
+			contents = append(contents, content)
+		}
+		merged, mergeErr := vcblobstore.MergeDerivedEntry(repo.naming, key, contents[0], contents[1], contents[2])
+		if mergeErr != nil {
+			return "", mergeErr
+		}
+		if merged == nil {
+			if _, removeErr := index.remove(key); removeErr != nil {
+				return "", removeErr
+			}
+			continue
+		}
+		if writeErr := index.write(key, merged, ""); writeErr != nil {
+			return "", writeErr
+		}
+	}
+
+	out, writeErr := repo.executeGitCommandWithEnv(ctx, []string{"write-tree"}, index.env)
+	if writeErr != nil {
+		return "", fmt.Errorf("failed to write the merged tree: %w -> %s", writeErr, out)
+	}
+	return strings.TrimSpace(out), nil
+}
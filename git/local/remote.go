@@ -0,0 +1,244 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"vcblobstore"
+)
+
+const defaultRemoteBranch = "main"
+
+// PullStrategy tells how the working copy catches up with the remote branch before a write
+type PullStrategy string
+
+const (
+	// PullRebase rebases the local commits not pushed yet onto the remote branch
+	PullRebase PullStrategy = "rebase"
+	// PullFastForwardOnly fails the write with vcblobstore.ErrConflict if the local commits not pushed yet diverge
+	// from the remote branch
+	PullFastForwardOnly PullStrategy = "ff-only"
+	// PullNever leaves catching up to the rebase done when a push is rejected
+	PullNever PullStrategy = "never"
+)
+
+// maxPushAttempts is the number of times a push rejected because of concurrent writers is retried after rebasing
+const maxPushAttempts = 3
+
+func (repo *Git) hasRemote() bool {
+	return len(repo.remoteURL) > 0
+}
+
+func (repo *Git) pushesPeriodically() bool {
+	return repo.hasRemote() && repo.pushInterval > 0
+}
+
+// pushStatus keeps track of the periodic pushes failing one after the other
+type pushStatus struct {
+	mu       sync.Mutex
+	failures int
+	lastErr  error
+}
+
+func (status *pushStatus) record(err error) {
+	status.mu.Lock()
+	defer status.mu.Unlock()
+	if err == nil {
+		status.failures = 0
+		status.lastErr = nil
+		return
+	}
+	status.failures++
+	status.lastErr = err
+}
+
+// PushHealth returns nil unless the last push of the commits accumulated for the periodic push failed. The commits
+// are kept then and the push is retried at the next interval, the error tells how many attempts have failed so far
+func (repo *Git) PushHealth() error {
+	if repo.pushStatus == nil {
+		return nil
+	}
+	repo.pushStatus.mu.Lock()
+	defer repo.pushStatus.mu.Unlock()
+	if repo.pushStatus.lastErr == nil {
+		return nil
+	}
+	return fmt.Errorf("%d consecutive pushes failed, the last one with: %w", repo.pushStatus.failures, repo.pushStatus.lastErr)
+}
+
+// Push publishes the commits not pushed yet, e.g. those accumulated since the last periodic push
+func (repo *Git) Push(ctx context.Context) error {
+	if !repo.hasRemote() {
+		return nil
+	}
+	return repo.queue.enqueue(ctx, func(ctx context.Context) error {
+		out, countErr := repo.ExecuteGitCommand(ctx, []string{"rev-list", "--count", "origin/" + repo.remoteBranch + "..HEAD"})
+		if countErr == nil && strings.TrimSpace(out) == "0" {
+			repo.recordPush(nil)
+			return nil
+		}
+		pushErr := repo.push(ctx)
+		repo.recordPush(pushErr)
+		return pushErr
+	})
+}
+
+func (repo *Git) recordPush(err error) {
+	if repo.pushesPeriodically() && repo.pushStatus != nil {
+		repo.pushStatus.record(err)
+	}
+}
+
+// StartPushing pushes the pending commits every PushInterval until ctx is done, then one last time.
+// It does nothing unless both the remote and the interval are configured
+func (repo *Git) StartPushing(ctx context.Context) {
+	if !repo.pushesPeriodically() {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(repo.pushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				if pushErr := repo.Push(context.WithoutCancel(ctx)); pushErr != nil {
+					repo.logger.Error().Err(pushErr).Msg("failed to push the pending commits")
+				}
+				return
+			case <-ticker.C:
+				if pushErr := repo.Push(ctx); pushErr != nil {
+					repo.logger.Error().Err(pushErr).Msg("failed to push the pending commits")
+				}
+			}
+		}
+	}()
+}
+
+// clone creates the working copy of the repository from the remote
+func (repo Git) clone(ctx context.Context) error {
+	args := []string{"clone"}
+	heads, lsErr := ExecuteCommand(ctx, ExecCmdParams{Name: repo.gitBinary(), Args: []string{"ls-remote", "--heads", "--", repo.remoteURL, repo.remoteBranch}}, repo.logger)
+	if lsErr == nil && len(strings.TrimSpace(heads)) > 0 {
+		// The default branch of the remote may be a different one
+		args = append(args, "--branch", repo.remoteBranch)
+	}
+	if repo.cloneDepth > 0 {
+		args = append(args, fmt.Sprintf("--depth=%d", repo.cloneDepth))
+	}
+	if len(repo.cloneFilter) > 0 {
+		args = append(args, "--filter="+repo.cloneFilter)
+	}
+	args = append(args, "--", repo.remoteURL, repo.location)
+	out, err := ExecuteCommand(ctx, ExecCmdParams{Name: repo.gitBinary(), Args: repo.gitArgs(args)}, repo.logger)
+	if err != nil {
+		return fmt.Errorf("failed to clone %s to %s: %w -> %s", repo.remoteURL, repo.location, err, out)
+	}
+	return nil
+}
+
+func (repo *Git) pushArgs(refspec string) []string {
+	args := []string{"push"}
+	for _, option := range repo.pushOptions {
+		args = append(args, "-o", option)
+	}
+	return append(args, "origin", refspec)
+}
+
+// pull fetches the remote branch and brings the working copy up to date with it as the pull strategy says
+func (repo *Git) pull(ctx context.Context) error {
+	if !repo.hasRemote() || repo.pullStrategy == PullNever {
+		return nil
+	}
+	out, fetchErr := repo.ExecuteGitCommand(ctx, []string{"fetch", "origin", repo.remoteBranch})
+	if fetchErr != nil {
+		if strings.Contains(out, "couldn't find remote ref") {
+			// The remote branch is yet to be created by the first push
+			return nil
+		}
+		return fmt.Errorf("failed to fetch %s from %s: %w -> %s", repo.remoteBranch, repo.remoteURL, fetchErr, out)
+	}
+
+	remoteRef := "origin/" + repo.remoteBranch
+	if _, headErr := repo.ExecuteGitCommand(ctx, []string{"rev-parse", "--verify", "HEAD"}); headErr != nil {
+		// Nothing has been committed locally yet
+		if out, resetErr := repo.ExecuteGitCommand(ctx, []string{"reset", "--hard", remoteRef}); resetErr != nil {
+			return fmt.Errorf("failed to check out %s: %w -> %s", remoteRef, resetErr, out)
+		}
+		return nil
+	}
+
+	if repo.pullStrategy == PullFastForwardOnly {
+		if out, mergeErr := repo.ExecuteGitCommand(ctx, []string{"merge", "--ff-only", remoteRef}); mergeErr != nil {
+			return fmt.Errorf("%w: the local commits diverge from the remote branch %s: %w -> %s", vcblobstore.ErrConflict, repo.remoteBranch, mergeErr, out)
+		}
+		return nil
+	}
+	if out, rebaseErr := repo.ExecuteGitCommand(ctx, []string{"rebase", remoteRef}); rebaseErr != nil {
+		_, _ = repo.ExecuteGitCommand(context.WithoutCancel(ctx), []string{"rebase", "--abort"})
+		return fmt.Errorf("%w: failed to rebase onto the remote branch %s: %w -> %s", vcblobstore.ErrConflict, repo.remoteBranch, rebaseErr, out)
+	}
+	return nil
+}
+
+// ensureVersion fetches the commit from the remote if it is missing from a shallow clone. The objects missing from
+// a partial clone are fetched by git itself when they are needed
+func (repo *Git) ensureVersion(ctx context.Context, version string) error {
+	if !repo.hasRemote() || repo.cloneDepth <= 0 || strings.HasPrefix(version, "-") {
+		return nil
+	}
+	if _, existsErr := repo.ExecuteGitCommand(ctx, []string{"cat-file", "-e", version + "^{commit}"}); existsErr == nil {
+		return nil
+	}
+	out, fetchErr := repo.ExecuteGitCommand(ctx, []string{"fetch", fmt.Sprintf("--depth=%d", repo.cloneDepth), "origin", version})
+	if fetchErr == nil {
+		return nil
+	}
+	// Servers may refuse fetching a commit by its ID, the whole history has to be fetched then
+	out, unshallowErr := repo.ExecuteGitCommand(ctx, []string{"fetch", "--unshallow", "origin"})
+	if unshallowErr != nil {
+		return fmt.Errorf("failed to fetch version %s from %s: %w -> %s", version, repo.remoteURL, unshallowErr, out)
+	}
+	return nil
+}
+
+func isPushRejection(out string) bool {
+	return strings.Contains(out, "[rejected]") || strings.Contains(out, "non-fast-forward") || strings.Contains(out, "fetch first")
+}
+
+// push publishes the local commits to the remote branch. In case the push is rejected because somebody else pushed
+// meanwhile, the local commits are rebased onto the remote branch and the push is retried. If the commit of a write
+// can't be published after all, it is dropped, so that the working copy stays in sync with the remote and the write
+// fails. The commits accumulated for the periodic push have been acknowledged already, they are kept for the next
+// attempt instead
+func (repo *Git) push(ctx context.Context) error {
+	if !repo.hasRemote() {
+		return nil
+	}
+
+	var pushErr error
+	for attempt := 0; attempt < maxPushAttempts; attempt++ {
+		var out string
+		out, pushErr = repo.ExecuteGitCommand(ctx, repo.pushArgs("HEAD:refs/heads/"+repo.remoteBranch))
+		if pushErr == nil {
+			return nil
+		}
+		if !isPushRejection(out) {
+			pushErr = fmt.Errorf("%w -> %s", pushErr, out)
+			break
+		}
+
+		out, pullErr := repo.ExecuteGitCommand(ctx, []string{"pull", "--rebase", "origin", repo.remoteBranch})
+		if pullErr != nil {
+			_, _ = repo.ExecuteGitCommand(context.WithoutCancel(ctx), []string{"rebase", "--abort"})
+			pushErr = fmt.Errorf("%w: failed to rebase onto the remote branch %s: %w -> %s", vcblobstore.ErrConflict, repo.remoteBranch, pullErr, out)
+			break
+		}
+	}
+
+	if !repo.pushesPeriodically() {
+		_, _ = repo.ExecuteGitCommand(context.WithoutCancel(ctx), []string{"reset", "--hard", "origin/" + repo.remoteBranch})
+	}
+	return fmt.Errorf("failed to push to %s: %w", repo.remoteURL, pushErr)
+}
@@ -0,0 +1,172 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"vcblobstore"
+)
+
+// ErrRemotePruning is returned when pruning the history of a working copy, which would diverge from its remote
+var ErrRemotePruning = errors.New("the history of a working copy of a remote repository can't be pruned")
+
+// ErrNonLinearHistory is returned when pruning a history with merge commits: its versions have no single order to cut
+// at, and replaying its commits one after the other would drop the parents they were merged from
+var ErrNonLinearHistory = errors.New("a history with merge commits can't be pruned")
+
+// PruneHistory squashes the commits older than the oldest one the policy keeps (see squashHistory)
+func (repo *Git) PruneHistory(ctx context.Context, policy vcblobstore.RetentionPolicy) (vcblobstore.PruneReport, error) {
+	report, err := repo.squashHistory(ctx, func(ctx context.Context, versions []vcblobstore.RepositoryVersion) (int, error) {
+		return policy.Cutoff(versions, time.Now()), nil
+	})
+	if err != nil {
+		return report, fmt.Errorf("failed to prune the history of git repository at %s: %w", repo.location, err)
+	}
+	repo.logger.Info().Int("squashed", report.Squashed).Int("kept", report.Kept).Msg("history pruned")
+	return report, nil
+}
+
+// CompactHistory squashes the commits older than the commit of the state or tag (see squashHistory)
+func (repo *Git) CompactHistory(ctx context.Context, beforeStateID string) (vcblobstore.PruneReport, error) {
+	if len(beforeStateID) == 0 || strings.HasPrefix(beforeStateID, "-") {
+		return vcblobstore.PruneReport{}, fmt.Errorf("failed to compact the history of git repository at %s before state %q: invalid state", repo.location, beforeStateID)
+	}
+	report, err := repo.squashHistory(ctx, func(ctx context.Context, versions []vcblobstore.RepositoryVersion) (int, error) {
+		out, revErr := repo.ExecuteGitCommand(ctx, []string{"rev-parse", "--verify", "--quiet", beforeStateID + "^{commit}"})
+		if revErr != nil {
+			return 0, fmt.Errorf("no such state %s: %w", beforeStateID, revErr)
+		}
+		commit := strings.TrimSpace(out)
+		for index, version := range versions {
+			if version.Version == commit {
+				return index, nil
+			}
+		}
+		return 0, fmt.Errorf("no such state %s on the current branch", beforeStateID)
+	})
+	if err != nil {
+		return report, fmt.Errorf("failed to compact the history of git repository at %s before state %s: %w", repo.location, beforeStateID, err)
+	}
+	repo.logger.Info().Int("squashed", report.Squashed).Int("kept", report.Kept).Msg("history compacted")
+	return report, nil
+}
+
+// squashHistory squashes the commits before the index cutoffOf returns into a single root commit, replays the commits
+// from the cutoff on top of it with their authors, committers, dates and messages, then drops the unreachable
+// objects. The tags keep the commits they point to. Histories with merge commits are refused with ErrNonLinearHistory
+func (repo *Git) squashHistory(
+	ctx context.Context,
+	cutoffOf func(ctx context.Context, versions []vcblobstore.RepositoryVersion) (int, error),
+) (vcblobstore.PruneReport, error) {
+	report := vcblobstore.PruneReport{}
+	if repo.hasRemote() {
+		return report, ErrRemotePruning
+	}
+
+	err := repo.queue.enqueue(ctx, func(ctx context.Context) error {
+		head, hasHead := repo.headCommit(ctx)
+		if !hasHead {
+			return nil
+		}
+		out, mergesErr := repo.ExecuteGitCommand(ctx, []string{"rev-list", "--merges", "--max-count=1", head})
+		if mergesErr != nil {
+			return fmt.Errorf("failed to look for merge commits: %w -> %s", mergesErr, out)
+		}
+		if len(strings.TrimSpace(out)) > 0 {
+			return ErrNonLinearHistory
+		}
+		versions, listErr := repo.ListVersions(ctx, "", "")
+		if listErr != nil {
+			return listErr
+		}
+		report.StateID, report.Kept = head, len(versions)
+		cutoff, cutoffErr := cutoffOf(ctx, versions)
+		if cutoffErr != nil || cutoff < 2 {
+			return cutoffErr
+		}
+
+		last := versions[cutoff-1]
+		message := fmt.Sprintf("history squashed: %d versions up to %s", cutoff, last.Version)
+		authorName, authorEmail, _ := strings.Cut(last.Author, " <")
+		env := []string{
+			"GIT_AUTHOR_NAME=" + authorName,
+			"GIT_AUTHOR_EMAIL=" + strings.TrimSuffix(authorEmail, ">"),
+			"GIT_AUTHOR_DATE=" + last.AuthorDate.Format(time.RFC3339),
+		}
+		tip, squashErr := repo.executeGitCommandWithEnv(ctx, []string{"commit-tree", last.Version + "^{tree}", "-m", message}, env)
+		if squashErr != nil {
+			return fmt.Errorf("failed to squash the versions up to %s: %w -> %s", last.Version, squashErr, tip)
+		}
+		tip, replayErr := repo.replayCommits(ctx, last.Version+".."+head, strings.TrimSpace(tip))
+		if replayErr != nil {
+			return replayErr
+		}
+
+		if out, updateErr := repo.ExecuteGitCommand(ctx, []string{"update-ref", "HEAD", tip, head}); updateErr != nil {
+			return fmt.Errorf("%w: failed to update HEAD: %w -> %s", vcblobstore.ErrConflict, updateErr, out)
+		}
+		for _, args := range [][]string{{"reflog", "expire", "--expire=now", "--all"}, {"gc", "--prune=now", "--quiet"}} {
+			if out, cleanupErr := repo.ExecuteGitCommand(ctx, args); cleanupErr != nil {
+				return fmt.Errorf("failed to drop the squashed objects: %w -> %s", cleanupErr, out)
+			}
+		}
+		report = vcblobstore.PruneReport{Squashed: cutoff, Kept: len(versions) - cutoff, StateID: tip}
+		return nil
+	})
+	repo.storeMetadata.Invalidate()
+	return report, err
+}
+
+// replayCommits recreates the commits of the revision range, oldest first, on top of parent and returns the last one
+func (repo *Git) replayCommits(ctx context.Context, revisionRange string, parent string) (string, error) {
+	format := "--format=%x1e%T%x1f%an%x1f%ae%x1f%aI%x1f%cn%x1f%ce%x1f%cI%x1f%B"
+	output, logErr := repo.ExecuteGitCommand(ctx, []string{"log", "--reverse", format, revisionRange, "--"})
+	if logErr != nil {
+		return "", fmt.Errorf("failed to list the commits in %s: %w", revisionRange, logErr)
+	}
+	for _, record := range strings.Split(output, logRecordSeparator) {
+		fields := strings.SplitN(record, logFieldSeparator, 8)
+		if len(fields) < 8 {
+			continue
+		}
+		env := []string{
+			"GIT_AUTHOR_NAME=" + fields[1],
+			"GIT_AUTHOR_EMAIL=" + fields[2],
+			"GIT_AUTHOR_DATE=" + fields[3],
+			"GIT_COMMITTER_NAME=" + fields[4],
+			"GIT_COMMITTER_EMAIL=" + fields[5],
+			"GIT_COMMITTER_DATE=" + fields[6],
+		}
+		message := strings.TrimRight(fields[7], "\n") + "\n"
+		out, commitErr := repo.executeGitCommandWithInput(ctx, []string{"commit-tree", fields[0], "-p", parent, "-F", "-"}, env, []byte(message))
+		if commitErr != nil {
+			return "", fmt.Errorf("failed to replay commit of tree %s: %w -> %s", fields[0], commitErr, out)
+		}
+		parent = strings.TrimSpace(out)
+	}
+	return parent, nil
+}
+
+// StartPruning prunes the history with the retention policy every RetentionInterval until ctx is done.
+// It does nothing unless both are configured
+func (repo *Git) StartPruning(ctx context.Context) {
+	if repo.retentionInterval <= 0 || repo.retention == (vcblobstore.RetentionPolicy{}) {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(repo.retentionInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, pruneErr := repo.PruneHistory(ctx, repo.retention); pruneErr != nil {
+					repo.logger.Error().Err(pruneErr).Msg("failed to prune the history")
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,60 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"vcblobstore"
+)
+
+// RevertToState commits the tree of the commit on top of the last commit of the branch
+func (repo *Git) RevertToState(ctx context.Context, stateID string, modifiedBy string) error {
+	if len(stateID) == 0 || strings.HasPrefix(stateID, "-") {
+		return fmt.Errorf("failed to revert git repository at %s to state %q: invalid state", repo.location, stateID)
+	}
+
+	err := repo.queue.enqueue(ctx, func(ctx context.Context) error {
+		if fetchErr := repo.ensureVersion(ctx, stateID); fetchErr != nil {
+			return fetchErr
+		}
+		out, revErr := repo.ExecuteGitCommand(ctx, []string{"rev-parse", "--verify", "--quiet", stateID + "^{commit}"})
+		if revErr != nil {
+			return fmt.Errorf("no such state %s: %w", stateID, revErr)
+		}
+		commit := strings.TrimSpace(out)
+		ref, refErr := repo.branchRef(ctx)
+		if refErr != nil {
+			return refErr
+		}
+		if head, hasHead, _ := repo.parentCommit(ctx, ref); hasHead {
+			if same, sameErr := repo.sameTree(ctx, commit, head); sameErr != nil || same {
+				return sameErr
+			}
+		}
+
+		blobOperation := func(tree entryWriter) error {
+			return tree.resetTo(commit)
+		}
+		jobTextProvider := gitJobMessages{
+			"revert to state",
+			fmt.Sprintf("revert to state %s", commit),
+		}
+		return repo.executeBlobManipulationJob(ctx, blobOperation, jobTextProvider, vcblobstore.Author{Name: modifiedBy})
+	})
+	repo.storeMetadata.Invalidate()
+
+	if err != nil {
+		return fmt.Errorf("failed to revert git repository at %s to state %s: %w", repo.location, stateID, err)
+	}
+	return nil
+}
+
+// sameTree tells whether the two commits have the same tree
+func (repo *Git) sameTree(ctx context.Context, commit string, otherCommit string) (bool, error) {
+	out, revErr := repo.ExecuteGitCommand(ctx, []string{"rev-parse", commit + "^{tree}", otherCommit + "^{tree}"})
+	if revErr != nil {
+		return false, fmt.Errorf("failed to get the trees of %s and %s: %w -> %s", commit, otherCommit, revErr, out)
+	}
+	trees := strings.Fields(out)
+	return len(trees) == 2 && trees[0] == trees[1], nil
+}
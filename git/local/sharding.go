@@ -0,0 +1,51 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"vcblobstore"
+)
+
+// ShardKeys migrates the blobs of a flat (or differently sharded) layout to the configured sharding scheme
+// in a single commit and returns the number of blobs moved. Blobs already in place are left alone
+func (repo *Git) ShardKeys(ctx context.Context, modifiedBy string) (int, error) {
+	paths, listErr := repo.listPaths(ctx, "")
+	if listErr != nil {
+		return 0, fmt.Errorf("failed to list the blobs to shard: %w", listErr)
+	}
+
+	moves := map[string]string{}
+	for _, path := range paths {
+		if _, ok := repo.sharding.KeyOf(repo.naming, path); ok {
+			continue
+		}
+		// The blobs out of place are taken for flat ones: their path is their key
+		moves[path] = repo.repoPath(path)
+	}
+	if len(moves) == 0 {
+		return 0, nil
+	}
+
+	blobOperation := func(tree entryWriter) error {
+		for oldPath, newPath := range moves {
+			if moveErr := moveEntry(tree, oldPath, newPath); moveErr != nil {
+				return fmt.Errorf("failed to move %s to %s: %w", oldPath, newPath, moveErr)
+			}
+		}
+		return nil
+	}
+
+	jobTextProvider := gitJobMessages{
+		fmt.Sprintf("shard %d blobs", len(moves)),
+		fmt.Sprintf("%d blobs moved to sharded layout", len(moves)),
+	}
+
+	err := repo.queue.enqueue(ctx, func(ctx context.Context) error {
+		return repo.executeBlobManipulationJob(ctx, blobOperation, jobTextProvider, vcblobstore.Author{Name: modifiedBy})
+	})
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to shard the blobs of git repository at %s: %w", repo.location, err)
+	}
+	return len(moves), nil
+}
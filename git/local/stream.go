@@ -0,0 +1,105 @@
+package local
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"vcblobstore"
+)
+
+// AddBlobFromReader adds the blob with the content read from r without holding the content in memory: the content
+// is spooled to a temporary file in the git directory, which is then moved in place in the working tree (or stored in
+// the object database of a bare repository). Blobs whose content is transformed on write by the text mode, the
+// external storage or LFS are the exception: their content is read into memory and added by AddBlob
+func (repo *Git) AddBlobFromReader(ctx context.Context, blob vcblobstore.BlobInfo, r io.Reader) error {
+	key := blob.Key
+	if keyErr := vcblobstore.ValidateBlobKey(repo.naming, key); keyErr != nil {
+		return keyErr
+	}
+
+	if modeErr := vcblobstore.ValidateFileMode(blob.Mode); modeErr != nil {
+		return modeErr
+	}
+	path, pathErr := repo.pathToFile(key)
+	if pathErr != nil {
+		return pathErr
+	}
+
+	spooled, size, digest, spoolErr := repo.spool(r)
+	if spoolErr != nil {
+		return spoolErr
+	}
+	defer os.Remove(spooled)
+
+	if len(blob.SHA256) > 0 && digest != blob.SHA256 {
+		return fmt.Errorf("content of %s has SHA-256 %s instead of the expected %s: %w", key, digest, blob.SHA256, vcblobstore.ErrChecksumMismatch)
+	}
+	if repo.transformsOnWrite(key, size) {
+		content, readErr := os.ReadFile(spooled)
+		if readErr != nil {
+			return fmt.Errorf("failed to read the content of %s: %w", key, readErr)
+		}
+		blob.Content = content
+		return repo.AddBlob(ctx, blob)
+	}
+
+	blobOperation := func(tree entryWriter) error {
+		entryPath, entryPathErr := repo.entryPath(key)
+		if entryPathErr != nil {
+			return entryPathErr
+		}
+		repo.logger.Debug().Str("operation", fmt.Sprintf("move file in place of %s", entryPath)).Msg("operation starting")
+		if writeErr := tree.writeFile(entryPath, spooled, blob.Mode); writeErr != nil {
+			return fmt.Errorf("failed to create blobfile %s as %s: %w", key, path, writeErr)
+		}
+		if blob.Metadata == nil {
+			return nil
+		}
+		return repo.writeMetadata(tree, key, blob.Metadata)
+	}
+
+	jobTextProvider := gitJobMessages{
+		"add blob file from stream",
+		"blob file version added",
+	}
+
+	err := repo.queue.enqueue(ctx, func(ctx context.Context) error {
+		return repo.executeBlobManipulationJob(ctx, blobOperation, jobTextProvider, blob.Author())
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to add blobfile %v to git repository at %s: %w", path, repo.location, err)
+	}
+	repo.access.RecordWrite(key)
+	return nil
+}
+
+// spool copies the content read from r to a temporary file in the git directory and returns the path, the size and
+// the SHA-256 digest of the file
+func (repo *Git) spool(r io.Reader) (string, int64, string, error) {
+	file, createErr := os.CreateTemp(repo.gitDir(), "vcblobstore-stream-")
+	if createErr != nil {
+		return "", 0, "", fmt.Errorf("failed to create temporary file: %w", createErr)
+	}
+	digest := sha256.New()
+	size, copyErr := io.Copy(io.MultiWriter(file, digest), r)
+	closeErr := file.Close()
+	if copyErr != nil || closeErr != nil {
+		os.Remove(file.Name())
+		if copyErr != nil {
+			return "", 0, "", fmt.Errorf("failed to spool content: %w", copyErr)
+		}
+		return "", 0, "", fmt.Errorf("failed to spool content: %w", closeErr)
+	}
+	return file.Name(), size, hex.EncodeToString(digest.Sum(nil)), nil
+}
+
+// transformsOnWrite tells whether the content of the blob with the key and of the size is transformed on write
+func (repo *Git) transformsOnWrite(key string, size int64) bool {
+	return repo.textMode.Applies(key) ||
+		(repo.external != nil && size > int64(repo.external.Threshold)) ||
+		repo.lfs.Tracks(key, int(size))
+}
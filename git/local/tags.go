@@ -0,0 +1,94 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"vcblobstore"
+	"vcblobstore/git/local/config"
+)
+
+const tagsRefPrefix = "refs/tags/"
+
+// TagState creates a lightweight tag of the commit (the last one of the branch by default) and pushes it to the
+// remote, if any
+func (repo *Git) TagState(ctx context.Context, name string, stateID string) error {
+	if _, formatErr := repo.ExecuteGitCommand(ctx, []string{"check-ref-format", tagsRefPrefix + name}); formatErr != nil || strings.HasPrefix(name, "-") {
+		return fmt.Errorf("%s: %w", name, vcblobstore.ErrInvalidTagName)
+	}
+	target := stateID
+	if len(target) == 0 {
+		ref, refErr := repo.branchRef(ctx)
+		if refErr != nil {
+			return refErr
+		}
+		target = ref
+	}
+
+	err := repo.queue.enqueue(ctx, func(ctx context.Context) error {
+		if fetchErr := repo.ensureVersion(ctx, target); fetchErr != nil {
+			return fetchErr
+		}
+		commit, revErr := repo.ExecuteGitCommand(ctx, []string{"rev-parse", "--verify", "--quiet", target + "^{commit}"})
+		if revErr != nil {
+			return fmt.Errorf("no such state %s: %w", target, revErr)
+		}
+		out, tagErr := repo.ExecuteGitCommand(ctx, []string{"tag", name, strings.TrimSpace(commit)})
+		if tagErr != nil {
+			if strings.Contains(out, "already exists") {
+				return fmt.Errorf("%w: tag %s already exists", vcblobstore.ErrConflict, name)
+			}
+			return fmt.Errorf("failed to create tag %s: %w -> %s", name, tagErr, out)
+		}
+		if !repo.hasRemote() {
+			return nil
+		}
+		out, pushErr := repo.ExecuteGitCommand(ctx, []string{"push", "origin", tagsRefPrefix + name})
+		if pushErr != nil {
+			// The tag is only to exist if the remote has it
+			_, _ = repo.ExecuteGitCommand(context.WithoutCancel(ctx), []string{"tag", "-d", name})
+			if strings.Contains(out, "already exists") {
+				return fmt.Errorf("%w: tag %s already exists in %s", vcblobstore.ErrConflict, name, repo.remoteURL)
+			}
+			return fmt.Errorf("failed to push tag %s to %s: %w -> %s", name, repo.remoteURL, pushErr, out)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to tag state %s as %s in git repository at %s: %w", target, name, repo.location, err)
+	}
+	return nil
+}
+
+// ListTags returns the tags of the repository after fetching those of the remote, if any
+func (repo *Git) ListTags(ctx context.Context) ([]vcblobstore.Tag, error) {
+	if repo.hasRemote() {
+		args := []string{"fetch", "origin", tagsRefPrefix + "*:" + tagsRefPrefix + "*"}
+		if repo.cloneDepth > 0 {
+			args = append(args, fmt.Sprintf("--depth=%d", repo.cloneDepth))
+		}
+		if out, fetchErr := repo.ExecuteGitCommand(ctx, args); fetchErr != nil {
+			return nil, fmt.Errorf("failed to fetch the tags of %s: %w -> %s", repo.remoteURL, fetchErr, out)
+		}
+	}
+
+	out, listErr := repo.ExecuteGitCommand(ctx, []string{"for-each-ref", "--sort=refname", "--format=%(refname:strip=2)%1f%(objectname)%1f%(*objectname)", tagsRefPrefix})
+	if listErr != nil {
+		return nil, fmt.Errorf("failed to list the tags: %w -> %s", listErr, out)
+	}
+	tags := []vcblobstore.Tag{}
+	for _, line := range strings.Split(out, config.LineBreak) {
+		fields := strings.Split(strings.TrimSpace(line), logFieldSeparator)
+		if len(fields) < 3 {
+			continue
+		}
+		tag := vcblobstore.Tag{Name: fields[0], StateID: fields[1]}
+		if len(fields[2]) > 0 {
+			// Annotated tags point to the commit through the tag object
+			tag.StateID = fields[2]
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
@@ -0,0 +1,83 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"vcblobstore"
+)
+
+// VerifyRepository runs git fsck and, unless the repository is bare, checks that the working tree has neither
+// uncommitted changes nor untracked files. It runs as a job of the queue, so that the changes of the other jobs
+// aren't taken (and repaired) for problems
+func (repo *Git) VerifyRepository(ctx context.Context, opts vcblobstore.RepairOptions) (vcblobstore.RepositoryReport, error) {
+	report := vcblobstore.NewRepositoryReport()
+
+	err := repo.queue.enqueue(ctx, func(ctx context.Context) error {
+		objectsCheck, objectsErr := repo.checkObjects(ctx)
+		if objectsErr != nil {
+			return objectsErr
+		}
+		report.Add(objectsCheck)
+		if repo.bare {
+			return nil
+		}
+
+		changesCheck, changesErr := repo.checkWorkTree(ctx, "uncommitted changes",
+			[]string{"status", "--porcelain", "--untracked-files=no"}, opts.ResetChanges, []string{"reset", "--hard", "HEAD"})
+		if changesErr != nil {
+			return changesErr
+		}
+		report.Add(changesCheck)
+
+		untrackedCheck, untrackedErr := repo.checkWorkTree(ctx, "untracked files",
+			[]string{"ls-files", "--others"}, opts.RemoveUntracked, []string{"clean", "-qfdx"})
+		if untrackedErr != nil {
+			return untrackedErr
+		}
+		report.Add(untrackedCheck)
+		return nil
+	})
+
+	if err != nil {
+		return vcblobstore.RepositoryReport{}, fmt.Errorf("failed to verify git repository at %s: %w", repo.location, err)
+	}
+	return report, nil
+}
+
+// checkObjects checks the connectivity and the validity of the objects of the repository
+func (repo *Git) checkObjects(ctx context.Context) (vcblobstore.RepositoryCheck, error) {
+	out, fsckErr := repo.ExecuteGitCommand(ctx, []string{"fsck", "--no-progress", "--no-dangling"})
+	if ctx.Err() != nil {
+		return vcblobstore.RepositoryCheck{}, ctx.Err()
+	}
+	if fsckErr != nil {
+		return vcblobstore.RepositoryCheck{Name: "objects", Details: strings.TrimSpace(fmt.Sprintf("%v: %s", fsckErr, out))}, nil
+	}
+	return vcblobstore.RepositoryCheck{Name: "objects", Passed: true}, nil
+}
+
+// checkWorkTree reports the problems listed by the git command as the check with the name and, if repair is set,
+// runs the git command repairing them
+func (repo *Git) checkWorkTree(ctx context.Context, name string, listArgs []string, repair bool, repairArgs []string) (vcblobstore.RepositoryCheck, error) {
+	out, listErr := repo.ExecuteGitCommand(ctx, listArgs)
+	if listErr != nil {
+		return vcblobstore.RepositoryCheck{}, fmt.Errorf("failed to check %s: %w -> %s", name, listErr, out)
+	}
+	problems := strings.TrimSpace(out)
+	if len(problems) == 0 {
+		return vcblobstore.RepositoryCheck{Name: name, Passed: true}, nil
+	}
+
+	check := vcblobstore.RepositoryCheck{Name: name, Details: problems}
+	if !repair {
+		return check, nil
+	}
+	out, repairErr := repo.ExecuteGitCommand(ctx, repairArgs)
+	if repairErr != nil {
+		return vcblobstore.RepositoryCheck{}, fmt.Errorf("failed to repair %s: %w -> %s", name, repairErr, out)
+	}
+	check.Passed = true
+	check.Repaired = true
+	return check, nil
+}
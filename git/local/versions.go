@@ -0,0 +1,205 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"strings"
+	"time"
+	"vcblobstore"
+	"vcblobstore/git"
+)
+
+// ListVersions returns the commits made after fromStateID up to and including toStateID (the last commit of the
+// branch by default), oldest first
+func (repo *Git) ListVersions(ctx context.Context, fromStateID string, toStateID string) ([]vcblobstore.RepositoryVersion, error) {
+	revisionRange := toStateID
+	if len(revisionRange) == 0 {
+		ref, refErr := repo.branchRef(ctx)
+		if refErr != nil {
+			return nil, refErr
+		}
+		revisionRange = ref
+	}
+	if len(fromStateID) > 0 {
+		revisionRange = fromStateID + ".." + revisionRange
+	}
+	args := []string{"log", "--reverse", "--no-renames", "--name-status", "-z", versionLogFormat, revisionRange, "--"}
+
+	output, execErr := repo.ExecuteGitCommand(ctx, args)
+	if execErr != nil {
+		return nil, fmt.Errorf("failed to list the versions in %s: %w", revisionRange, execErr)
+	}
+
+	versions := []vcblobstore.RepositoryVersion{}
+	for _, record := range strings.Split(output, logRecordSeparator) {
+		version, ok, parseErr := repo.parseVersionRecord(record)
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		if ok {
+			versions = append(versions, version)
+		}
+	}
+	return versions, nil
+}
+
+// versionLogFormat is the git log format of the versions parsed by parseVersionRecord
+const versionLogFormat = "--format=%x1e%H%x1f%an <%ae>%x1f%aI%x1f%B%x1f"
+
+// parseVersionRecord parses a record of git log printed in versionLogFormat with the -z name-status of the changes. It
+// reports false for the (empty) text before the first record
+func (repo *Git) parseVersionRecord(record string) (vcblobstore.RepositoryVersion, bool, error) {
+	fields := strings.SplitN(record, logFieldSeparator, 5)
+	if len(fields) < 5 {
+		return vcblobstore.RepositoryVersion{}, false, nil
+	}
+
+	authorDate, parseErr := time.Parse(time.RFC3339, fields[2])
+	if parseErr != nil {
+		return vcblobstore.RepositoryVersion{}, false, fmt.Errorf("failed to parse author date of commit %s: %w", fields[0], parseErr)
+	}
+
+	version := vcblobstore.RepositoryVersion{
+		Version:    fields[0],
+		Author:     fields[1],
+		AuthorDate: authorDate,
+		Message:    strings.TrimSpace(fields[3]),
+		Changes:    []vcblobstore.KeyChange{},
+	}
+	repo.addNameStatusChanges(&version, fields[4])
+	return version, true, nil
+}
+
+// IterateVersionsSince yields the versions of the branch authored at or after since, oldest first, as git log
+// produces them. git only knows to bound the committer date, which is never earlier than the author date, so the
+// versions it prints are filtered by the author date on the way
+func (repo *Git) IterateVersionsSince(ctx context.Context, since time.Time) iter.Seq2[vcblobstore.RepositoryVersion, error] {
+	return func(yield func(vcblobstore.RepositoryVersion, error) bool) {
+		ref, refErr := repo.branchRef(ctx)
+		if refErr != nil {
+			yield(vcblobstore.RepositoryVersion{}, refErr)
+			return
+		}
+		args := []string{"log", "--reverse", "--no-renames", "--name-status", "-z", versionLogFormat}
+		if !since.IsZero() {
+			args = append(args, "--since="+since.Format(time.RFC3339))
+		}
+		args = append(args, ref, "--")
+
+		stopped := false
+		var recordErr error
+		streamErr := StreamCommandRecords(ctx, ExecCmdParams{
+			Name: repo.gitBinary(),
+			Args: repo.gitArgs(args),
+			Opts: &CmdOpts{Cwd: repo.location},
+		}, repo.logger, logRecordSeparator[0], func(record string) bool {
+			version, ok, parseErr := repo.parseVersionRecord(record)
+			if parseErr != nil {
+				recordErr = parseErr
+				return false
+			}
+			if !ok || version.AuthorDate.Before(since) {
+				return true
+			}
+			if !yield(version, nil) {
+				stopped = true
+				return false
+			}
+			return true
+		})
+		if stopped {
+			return
+		}
+		if recordErr != nil {
+			yield(vcblobstore.RepositoryVersion{}, recordErr)
+			return
+		}
+		if streamErr != nil {
+			yield(vcblobstore.RepositoryVersion{}, fmt.Errorf("failed to list the versions since %v: %w", since, streamErr))
+		}
+	}
+}
+
+// ListChangesBetween returns the net changes of the blobs between the commits as computed by git diff. An empty
+// fromStateID stands for the empty tree
+func (repo *Git) ListChangesBetween(ctx context.Context, fromStateID string, toStateID string) ([]vcblobstore.KeyChange, error) {
+	for _, version := range []string{fromStateID, toStateID} {
+		if fetchErr := repo.ensureVersion(ctx, version); fetchErr != nil {
+			return nil, fetchErr
+		}
+	}
+	from := fromStateID
+	if len(from) == 0 {
+		out, hashErr := repo.executeGitCommandWithInput(ctx, []string{"hash-object", "-t", "tree", "--stdin"}, nil, nil)
+		if hashErr != nil {
+			return nil, fmt.Errorf("failed to get the ID of the empty tree: %w -> %s", hashErr, out)
+		}
+		from = strings.TrimSpace(out)
+	}
+	to := toStateID
+	if len(to) == 0 {
+		ref, refErr := repo.branchRef(ctx)
+		if refErr != nil {
+			return nil, refErr
+		}
+		to = ref
+	}
+
+	output, execErr := repo.ExecuteGitCommand(ctx, []string{"diff", "--no-renames", "--name-status", "-z", from, to, "--"})
+	if execErr != nil {
+		return nil, fmt.Errorf("failed to list the changes between %s and %s: %w", from, to, execErr)
+	}
+
+	net := vcblobstore.RepositoryVersion{Changes: []vcblobstore.KeyChange{}}
+	repo.addNameStatusChanges(&net, output)
+	vcblobstore.SortKeyChanges(net.Changes)
+	return net.Changes, nil
+}
+
+// addNameStatusChanges records the changes of the blobs listed by the -z --name-status output of git
+func (repo *Git) addNameStatusChanges(version *vcblobstore.RepositoryVersion, output string) {
+	for _, change := range parseNameStatus(output) {
+		operation, known := operationByChangeStatus[change.status]
+		if !known {
+			continue
+		}
+		if key, ok := repo.sharding.KeyOf(repo.naming, change.path); ok {
+			version.AddEntryChange(repo.naming, key, operation)
+		}
+	}
+}
+
+// nameStatus is a file changed as listed by git --name-status
+type nameStatus struct {
+	status string
+	path   string
+}
+
+// parseNameStatus parses the -z --name-status output of git (without renames): NUL terminated status and path
+// pairs. Unlike the default output, the paths are neither quoted nor escaped, so those with tabs, quotes or non-ASCII
+// characters come out verbatim. The status may be preceded by the line break git log prints after the commit
+func parseNameStatus(output string) []nameStatus {
+	changes := []nameStatus{}
+	tokens := strings.Split(output, "\x00")
+	for index := 0; index+1 < len(tokens); index++ {
+		status := strings.TrimSpace(tokens[index])
+		if len(status) == 0 {
+			continue
+		}
+		changes = append(changes, nameStatus{status: status, path: tokens[index+1]})
+		index++
+	}
+	return changes
+}
+
+// listCommitChanges returns the changes of the blobs the commit made
+func (repo *Git) listCommitChanges(ctx context.Context, commitId string) ([]git.ChangedKey, error) {
+	output, execErr := repo.ExecuteGitCommand(ctx, []string{"diff-tree", "--root", "-r", "--no-commit-id", "--no-renames", "--name-status", "-z", commitId, "--"})
+	if execErr != nil {
+		return nil, fmt.Errorf("failed to list the changes of commit %s: %w", commitId, execErr)
+	}
+	version := vcblobstore.RepositoryVersion{}
+	repo.addNameStatusChanges(&version, output)
+	return version.ChangedKeys(), nil
+}
@@ -0,0 +1,144 @@
+package vcblobstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HistoryRecord is one version of a blob in a history export bundle.
+// The bundle is a stream of JSON lines ordered by author date, oldest first
+type HistoryRecord struct {
+	Key        string    `json:"key"`
+	Version    string    `json:"version"`
+	Author     string    `json:"author"`
+	AuthorDate time.Time `json:"authorDate"`
+	Message    string    `json:"message"`
+	Deleted    bool      `json:"deleted"`
+	Content    []byte    `json:"content,omitempty"`
+}
+
+// AuthorName returns the name part of the "Name <email>" formatted author
+func (record HistoryRecord) AuthorName() string {
+	name, _, _ := strings.Cut(record.Author, " <")
+	return name
+}
+
+// AuthorEmail returns the email part of the "Name <email>" formatted author
+func (record HistoryRecord) AuthorEmail() string {
+	_, email, _ := strings.Cut(record.Author, " <")
+	return strings.TrimSuffix(email, ">")
+}
+
+type importedRecordKey struct{}
+
+// WithImportedRecord makes the write made with the returned context keep the author date and the message of the
+// imported history record, instead of recording the time and the operation of the write. ImportHistory uses it
+func WithImportedRecord(ctx context.Context, record HistoryRecord) context.Context {
+	return context.WithValue(ctx, importedRecordKey{}, record)
+}
+
+// ImportedRecordOf returns the history record set with WithImportedRecord
+func ImportedRecordOf(ctx context.Context) (HistoryRecord, bool) {
+	record, imported := ctx.Value(importedRecordKey{}).(HistoryRecord)
+	return record, imported
+}
+
+// WriteHistory sorts the records by author date (keeping the relative order of records with equal dates)
+// and writes them to w as JSON lines
+func WriteHistory(w io.Writer, records []HistoryRecord) error {
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].AuthorDate.Before(records[j].AuthorDate)
+	})
+
+	encoder := json.NewEncoder(w)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("failed to write history record for %s@%s: %w", record.Key, record.Version, err)
+		}
+	}
+	return nil
+}
+
+// ReadHistory decodes the JSON lines of a history export bundle from r and passes each record to apply in order
+func ReadHistory(r io.Reader, apply func(record HistoryRecord) error) error {
+	decoder := json.NewDecoder(r)
+	for {
+		record := HistoryRecord{}
+		decodeErr := decoder.Decode(&record)
+		if errors.Is(decodeErr, io.EOF) {
+			return nil
+		}
+		if decodeErr != nil {
+			return fmt.Errorf("failed to read history record: %w", decodeErr)
+		}
+		if applyErr := apply(record); applyErr != nil {
+			return fmt.Errorf("failed to import history record for %s@%s: %w", record.Key, record.Version, applyErr)
+		}
+	}
+}
+
+type BlobOperation string
+
+const (
+	BlobOperationCreate BlobOperation = "create"
+	BlobOperationUpdate BlobOperation = "update"
+	BlobOperationDelete BlobOperation = "delete"
+)
+
+// BlobVersion is an entry of the history of a blob
+type BlobVersion struct {
+	Version    string
+	Operation  BlobOperation
+	Author     string
+	AuthorDate time.Time
+	Message    string
+}
+
+// HistoryFilter narrows down the versions returned by GetBlobHistory. Zero-valued fields don't filter
+type HistoryFilter struct {
+	// Author matches the name or the email of the author (substring)
+	Author string
+	// Since and Until bound the author dates of the versions
+	Since           time.Time
+	Until           time.Time
+	Operations      []BlobOperation
+	MessageContains string
+	// SinceVersion, if set, restricts the history to the versions made after it, e.g. to the ones
+	// made since the newest version seen by a previous call
+	SinceVersion string
+}
+
+func (filter HistoryFilter) MatchesOperation(operation BlobOperation) bool {
+	if len(filter.Operations) == 0 {
+		return true
+	}
+	for _, filterOperation := range filter.Operations {
+		if filterOperation == operation {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches reports whether the version satisfies every criterion of the filter
+func (filter HistoryFilter) Matches(version BlobVersion) bool {
+	if len(filter.Author) > 0 && !strings.Contains(version.Author, filter.Author) {
+		return false
+	}
+	if !filter.Since.IsZero() && version.AuthorDate.Before(filter.Since) {
+		return false
+	}
+	if !filter.Until.IsZero() && version.AuthorDate.After(filter.Until) {
+		return false
+	}
+	if len(filter.MessageContains) > 0 && !strings.Contains(version.Message, filter.MessageContains) {
+		return false
+	}
+	return filter.MatchesOperation(version.Operation)
+}
@@ -0,0 +1,1480 @@
+package journal
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"vcblobstore"
+	"vcblobstore/git"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	journalFileName  = "journal.jsonl"
+	objectsDirectory = "objects"
+	fastResetAuthor  = "vcblobstore"
+)
+
+// journalChange is the new state of a key in a version. An empty Object means the key was deleted
+type journalChange struct {
+	Key    string `json:"key"`
+	Object string `json:"object,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+}
+
+// journalEntry is a line of the journal: a version of the store. Version is the SHA-1 of the entry
+// (encoded with an empty Version), so it also covers the parent and thus the whole history before it
+type journalEntry struct {
+	Version string          `json:"version"`
+	Parent  string          `json:"parent,omitempty"`
+	Author  string          `json:"author"`
+	Date    time.Time       `json:"date"`
+	Message string          `json:"message"`
+	Changes []journalChange `json:"changes"`
+}
+
+type treeEntry struct {
+	object  string
+	size    int64
+	version string
+}
+
+// changeSet collects the changes of a version being written. A nil content removes the key
+type changeSet map[string][]byte
+
+// Journal is a backend for hosts without git: the content of the blobs is stored in files named after
+// their SHA-256 digest and the versions are recorded in an append-only JSON lines journal
+type Journal struct {
+	location string
+	logger   *zerolog.Logger
+	textMode vcblobstore.TextMode
+	external *vcblobstore.ExternalStorage
+	access   *vcblobstore.AccessTracker
+	naming   vcblobstore.NamingStrategy
+
+	storeMetadata *vcblobstore.StoreMetadataCache
+
+	mutex     sync.Mutex
+	loaded    bool
+	entries   []journalEntry
+	byVersion map[string]int
+	tree      map[string]treeEntry
+	// validSize is the length of the journal up to its last complete entry
+	validSize int64
+	// loadedSize is the length of the journal as it was last read or appended to, the remainder of an interrupted
+	// append included
+	loadedSize int64
+}
+
+var (
+	_ vcblobstore.Store         = (*Journal)(nil)
+	_ vcblobstore.Admin         = (*Journal)(nil)
+	_ vcblobstore.ChangeApplier = (*Journal)(nil)
+)
+
+func (store *Journal) String() string {
+	return fmt.Sprintf("Journal blob store at %s", store.location)
+}
+
+func (store *Journal) journalPath() string {
+	return filepath.Join(store.location, journalFileName)
+}
+
+func (store *Journal) objectPath(object string) string {
+	return filepath.Join(store.location, objectsDirectory, object[:2], object)
+}
+
+func (store *Journal) CreateRepository(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if mkdirErr := os.MkdirAll(filepath.Join(store.location, objectsDirectory), 0700); mkdirErr != nil {
+		return fmt.Errorf("failed to create journal store at %s: %w", store.location, mkdirErr)
+	}
+	journalFile, openErr := os.OpenFile(store.journalPath(), os.O_CREATE|os.O_WRONLY, 0600)
+	if openErr != nil {
+		return fmt.Errorf("failed to create journal at %s: %w", store.location, openErr)
+	}
+	return journalFile.Close()
+}
+
+func (store *Journal) ResetRepository(ctx context.Context) error {
+	deleteRepoErr := store.DeleteRepository(ctx)
+	if deleteRepoErr != nil {
+		return deleteRepoErr
+	}
+	return store.CreateRepository(ctx)
+}
+
+func (store *Journal) DeleteRepository(ctx context.Context) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.loaded = false
+	store.storeMetadata.Invalidate()
+	return os.RemoveAll(store.location)
+}
+
+// FastReset empties the store by deleting every key in a single version, keeping the journal
+func (store *Journal) FastReset(ctx context.Context) error {
+	err := store.write(ctx, vcblobstore.Author{Name: fastResetAuthor}, "repository reset", func(changes changeSet) error {
+		for key := range store.tree {
+			changes[key] = nil
+		}
+		return nil
+	})
+	store.storeMetadata.Invalidate()
+
+	if err != nil {
+		return fmt.Errorf("failed to reset journal store at %s: %w", store.location, err)
+	}
+	return nil
+}
+
+// load reads the journal unless it has already been read
+func (store *Journal) load() error {
+	if store.loaded {
+		return nil
+	}
+
+	journalFile, openErr := os.Open(store.journalPath())
+	if openErr != nil {
+		if os.IsNotExist(openErr) {
+			return fmt.Errorf("failed to open journal at %s: %w", store.location, vcblobstore.ErrRepoNotFound)
+		}
+		return fmt.Errorf("failed to open journal at %s: %w", store.location, openErr)
+	}
+	defer journalFile.Close()
+
+	store.entries = []journalEntry{}
+	store.byVersion = map[string]int{}
+	store.tree = map[string]treeEntry{}
+	store.validSize = 0
+
+	reader := bufio.NewReader(journalFile)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr == io.EOF {
+			// A last line without line break is the remainder of an interrupted append: it is ignored and overwritten by the next one
+			store.loadedSize = store.validSize + int64(len(line))
+			break
+		}
+		if readErr != nil {
+			return fmt.Errorf("failed to read journal at %s: %w", store.location, readErr)
+		}
+		entry := journalEntry{}
+		if jsonErr := json.Unmarshal(line, &entry); jsonErr != nil {
+			return fmt.Errorf("failed to parse entry #%d of journal at %s: %w", len(store.entries)+1, store.location, jsonErr)
+		}
+		store.apply(entry)
+		store.validSize += int64(len(line))
+	}
+
+	store.loaded = true
+	return nil
+}
+
+func (store *Journal) apply(entry journalEntry) {
+	store.byVersion[entry.Version] = len(store.entries)
+	store.entries = append(store.entries, entry)
+	for _, change := range entry.Changes {
+		if len(change.Object) == 0 {
+			delete(store.tree, change.Key)
+			continue
+		}
+		store.tree[change.Key] = treeEntry{object: change.Object, size: change.Size, version: entry.Version}
+	}
+}
+
+func (store *Journal) headVersion() string {
+	if len(store.entries) == 0 {
+		return ""
+	}
+	return store.entries[len(store.entries)-1].Version
+}
+
+func (store *Journal) writeObject(content []byte) (string, error) {
+	object := vcblobstore.ContentSHA256(content)
+	path := store.objectPath(object)
+	if _, statErr := os.Stat(path); statErr == nil {
+		return object, nil
+	}
+
+	if mkdirErr := os.MkdirAll(filepath.Dir(path), 0700); mkdirErr != nil {
+		return "", fmt.Errorf("failed to create directory for object %s: %w", object, mkdirErr)
+	}
+	tempFile, createErr := os.CreateTemp(filepath.Dir(path), object+".*")
+	if createErr != nil {
+		return "", fmt.Errorf("failed to create object %s: %w", object, createErr)
+	}
+	_, writeErr := tempFile.Write(content)
+	if writeErr == nil {
+		writeErr = tempFile.Sync()
+	}
+	closeErr := tempFile.Close()
+	if writeErr == nil {
+		writeErr = closeErr
+	}
+	if writeErr == nil {
+		writeErr = os.Rename(tempFile.Name(), path)
+	}
+	if writeErr != nil {
+		_ = os.Remove(tempFile.Name())
+		return "", fmt.Errorf("failed to write object %s: %w", object, writeErr)
+	}
+	return object, nil
+}
+
+func (store *Journal) readObject(object string) ([]byte, error) {
+	content, readErr := os.ReadFile(store.objectPath(object))
+	if readErr != nil {
+		return nil, fmt.Errorf("failed to read object %s: %w", object, readErr)
+	}
+	return content, nil
+}
+
+func authorOf(author vcblobstore.Author) string {
+	email := author.Email
+	if len(email) == 0 {
+		email = author.Name
+	}
+	return fmt.Sprintf("%s <%s>", author.Name, email)
+}
+
+// commit stores the new contents and appends the version made up of the actual changes to the locked journal file.
+// The version of an imported history record keeps its date and message
+func (store *Journal) commit(ctx context.Context, journalFile *os.File, author vcblobstore.Author, messageBase string, changes changeSet) error {
+	keys := make([]string, 0, len(changes))
+	for key := range changes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	entry := journalEntry{
+		Parent:  store.headVersion(),
+		Author:  authorOf(author),
+		Date:    time.Now(),
+		Message: messageBase + " by " + author.Name,
+		Changes: []journalChange{},
+	}
+	if record, imported := vcblobstore.ImportedRecordOf(ctx); imported {
+		if !record.AuthorDate.IsZero() {
+			entry.Date = record.AuthorDate
+		}
+		if len(record.Message) > 0 {
+			entry.Message = record.Message
+		}
+	}
+	for _, key := range keys {
+		current, exists := store.tree[key]
+		content := changes[key]
+		if content == nil {
+			if exists {
+				entry.Changes = append(entry.Changes, journalChange{Key: key})
+			}
+			continue
+		}
+		object, writeErr := store.writeObject(content)
+		if writeErr != nil {
+			return writeErr
+		}
+		if !exists || current.object != object {
+			entry.Changes = append(entry.Changes, journalChange{Key: key, Object: object, Size: int64(len(content))})
+		}
+	}
+	if len(entry.Changes) == 0 {
+		return nil
+	}
+
+	versionSource, marshalErr := json.Marshal(entry)
+	if marshalErr != nil {
+		return fmt.Errorf("failed to encode journal entry: %w", marshalErr)
+	}
+	versionHash := sha1.Sum(versionSource)
+	entry.Version = hex.EncodeToString(versionHash[:])
+	line, marshalErr := json.Marshal(entry)
+	if marshalErr != nil {
+		return fmt.Errorf("failed to encode journal entry: %w", marshalErr)
+	}
+
+	if appendErr := store.append(journalFile, append(line, '\n')); appendErr != nil {
+		return appendErr
+	}
+	store.apply(entry)
+	store.validSize += int64(len(line) + 1)
+	store.loadedSize = store.validSize
+	return nil
+}
+
+func (store *Journal) append(journalFile *os.File, line []byte) error {
+	if truncateErr := journalFile.Truncate(store.validSize); truncateErr != nil {
+		return fmt.Errorf("failed to truncate journal at %s: %w", store.location, truncateErr)
+	}
+	if _, writeErr := journalFile.WriteAt(line, store.validSize); writeErr != nil {
+		return fmt.Errorf("failed to append to journal at %s: %w", store.location, writeErr)
+	}
+	if syncErr := journalFile.Sync(); syncErr != nil {
+		return fmt.Errorf("failed to sync journal at %s: %w", store.location, syncErr)
+	}
+	return nil
+}
+
+// lockJournal opens the journal file for appending and locks it against the writers of other Journal instances
+// and processes. The journal is read again if it has changed since it was loaded, so that the entries appended
+// by others are neither missed nor truncated by the next append
+func (store *Journal) lockJournal() (*os.File, error) {
+	journalFile, openErr := os.OpenFile(store.journalPath(), os.O_WRONLY, 0600)
+	if openErr != nil {
+		if os.IsNotExist(openErr) {
+			return nil, fmt.Errorf("failed to open journal at %s: %w", store.location, vcblobstore.ErrRepoNotFound)
+		}
+		return nil, fmt.Errorf("failed to open journal at %s: %w", store.location, openErr)
+	}
+	if lockErr := lockFile(journalFile); lockErr != nil {
+		journalFile.Close()
+		return nil, fmt.Errorf("failed to lock journal at %s: %w", store.location, lockErr)
+	}
+	if store.loaded {
+		fileInfo, statErr := journalFile.Stat()
+		if statErr != nil {
+			journalFile.Close()
+			return nil, fmt.Errorf("failed to check journal at %s: %w", store.location, statErr)
+		}
+		if fileInfo.Size() != store.validSize {
+			store.loaded = false
+			store.storeMetadata.Invalidate()
+		}
+	}
+	return journalFile, nil
+}
+
+// write stages the changes of a version with stage and commits them as a single journal entry
+func (store *Journal) write(ctx context.Context, author vcblobstore.Author, messageBase string, stage func(changes changeSet) error) error {
+	logger := store.logger.With().Str("method", fmt.Sprintf("journal: %s", messageBase)).Logger()
+	if len(author.Name) == 0 {
+		logger.Warn().Msg("Modifying user is not specified")
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if branchErr := checkBranch(ctx); branchErr != nil {
+		return branchErr
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	journalFile, lockErr := store.lockJournal()
+	if lockErr != nil {
+		return lockErr
+	}
+	defer journalFile.Close()
+
+	if loadErr := store.load(); loadErr != nil {
+		return loadErr
+	}
+	changes := changeSet{}
+	if stageErr := stage(changes); stageErr != nil {
+		return fmt.Errorf("failed blob operation: %w", stageErr)
+	}
+	if commitErr := store.commit(ctx, journalFile, author, messageBase, changes); commitErr != nil {
+		logger.Debug().Err(commitErr).Msg("failed journal operation")
+		return commitErr
+	}
+	logger.Debug().Msg("Success")
+	return nil
+}
+
+// checkBranch rejects the branch selected with WithBranch: the journal keeps a single line of versions, which
+// a staged write would otherwise land in directly
+func checkBranch(ctx context.Context) error {
+	if branch := vcblobstore.BranchOf(ctx, ""); len(branch) > 0 {
+		return fmt.Errorf("failed to select branch %s of journal store: %w", branch, vcblobstore.ErrBranchesUnsupported)
+	}
+	return nil
+}
+
+// staged returns the content of the key as of the changes staged so far
+func (store *Journal) staged(changes changeSet, key string) ([]byte, bool, error) {
+	if content, changed := changes[key]; changed {
+		return content, content != nil, nil
+	}
+	return store.current(key)
+}
+
+func (store *Journal) current(key string) ([]byte, bool, error) {
+	entry, exists := store.tree[key]
+	if !exists {
+		return nil, false, nil
+	}
+	content, readErr := store.readObject(entry.object)
+	if readErr != nil {
+		return nil, false, readErr
+	}
+	return content, true, nil
+}
+
+// read runs the query on the journal loaded as of its last change
+func (store *Journal) read(ctx context.Context, query func() error) error {
+	if branchErr := checkBranch(ctx); branchErr != nil {
+		return branchErr
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if changedErr := store.forgetIfChanged(); changedErr != nil {
+		return changedErr
+	}
+	if loadErr := store.load(); loadErr != nil {
+		return loadErr
+	}
+	return query()
+}
+
+// forgetIfChanged makes the journal read again if it has changed since it was loaded, so that the readers see the
+// entries appended by other Journal instances and processes too
+func (store *Journal) forgetIfChanged() error {
+	if !store.loaded {
+		return nil
+	}
+	fileInfo, statErr := os.Stat(store.journalPath())
+	if statErr != nil && !os.IsNotExist(statErr) {
+		return fmt.Errorf("failed to check journal at %s: %w", store.location, statErr)
+	}
+	if statErr != nil || fileInfo.Size() != store.loadedSize {
+		store.loaded = false
+		store.storeMetadata.Invalidate()
+	}
+	return nil
+}
+
+// stageMetadata stages the sidecar and the attribute index entries of the blob metadata
+func (store *Journal) stageMetadata(changes changeSet, key string, metadata map[string]string) error {
+	sidecarKey := store.naming.MetadataSidecarKey(key)
+	if len(metadata) == 0 {
+		changes[sidecarKey] = nil
+	} else {
+		content, encodeErr := vcblobstore.EncodeMetadata(metadata)
+		if encodeErr != nil {
+			return encodeErr
+		}
+		changes[sidecarKey] = content
+	}
+
+	index, indexErr := store.stagedAttributeIndex(changes)
+	if indexErr != nil {
+		return indexErr
+	}
+	if !index.Update(key, metadata) {
+		return nil
+	}
+	content, encodeErr := index.Encode()
+	if encodeErr != nil {
+		return encodeErr
+	}
+	changes[store.naming.AttributeIndexKey()] = content
+	return nil
+}
+
+func (store *Journal) stagedAttributeIndex(changes changeSet) (vcblobstore.AttributeIndex, error) {
+	content, exists, readErr := store.staged(changes, store.naming.AttributeIndexKey())
+	if readErr != nil {
+		return nil, fmt.Errorf("failed to read attribute index: %w", readErr)
+	}
+	if !exists {
+		return vcblobstore.AttributeIndex{}, nil
+	}
+	return vcblobstore.DecodeAttributeIndex(content)
+}
+
+func (store *Journal) stagedMetadata(changes changeSet, key string) (map[string]string, error) {
+	content, exists, readErr := store.staged(changes, store.naming.MetadataSidecarKey(key))
+	if readErr != nil {
+		return nil, fmt.Errorf("failed to read metadata of %s: %w", key, readErr)
+	}
+	if !exists {
+		return map[string]string{}, nil
+	}
+	return vcblobstore.DecodeMetadata(content)
+}
+
+func (store *Journal) AddBlob(ctx context.Context, blob vcblobstore.BlobInfo) error {
+	key := blob.Key
+
+	if checksumErr := blob.VerifyChecksum(); checksumErr != nil {
+		return checksumErr
+	}
+	if keyErr := vcblobstore.ValidateBlobKey(store.naming, key); keyErr != nil {
+		return keyErr
+	}
+
+	content, textModeErr := store.textMode.Apply(key, blob.Content)
+	if textModeErr != nil {
+		return textModeErr
+	}
+	content, offloadErr := store.external.Offload(ctx, content)
+	if offloadErr != nil {
+		return offloadErr
+	}
+	if content == nil {
+		content = []byte{}
+	}
+
+	err := store.write(ctx, blob.Author(), "blob file version added", func(changes changeSet) error {
+		changes[key] = content
+		if blob.Metadata == nil {
+			return nil
+		}
+		return store.stageMetadata(changes, key, blob.Metadata)
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to add blob %s to journal store at %s: %w", key, store.location, err)
+	}
+	store.access.RecordWrite(key)
+	return nil
+}
+
+// QueryByAttributes returns the keys of the blobs whose metadata attributes match the selector
+func (store *Journal) QueryByAttributes(ctx context.Context, selector vcblobstore.AttributeSelector) ([]string, error) {
+	var keys []string
+	err := store.read(ctx, func() error {
+		index, indexErr := store.stagedAttributeIndex(changeSet{})
+		if indexErr != nil {
+			return indexErr
+		}
+		keys = index.Query(selector)
+		return nil
+	})
+	return keys, err
+}
+
+// GetStoreMetadata returns the document in which the store records its own configuration
+func (store *Journal) GetStoreMetadata(ctx context.Context) (vcblobstore.StoreMetadata, error) {
+	if branchErr := checkBranch(ctx); branchErr != nil {
+		return vcblobstore.StoreMetadata{}, branchErr
+	}
+	metadata := vcblobstore.DefaultStoreMetadata()
+	// The cache is looked up under read, which drops it if another instance has changed the journal since
+	err := store.read(ctx, func() error {
+		if cachedMetadata, cached := store.storeMetadata.Get(); cached {
+			metadata = cachedMetadata
+			return nil
+		}
+		content, exists, readErr := store.current(store.naming.StoreMetadataKey())
+		if readErr != nil {
+			return fmt.Errorf("failed to read store metadata: %w", readErr)
+		}
+		if !exists {
+			return nil
+		}
+		var decodeErr error
+		metadata, decodeErr = vcblobstore.DecodeStoreMetadata(content)
+		return decodeErr
+	})
+	if err != nil {
+		return vcblobstore.StoreMetadata{}, err
+	}
+
+	store.storeMetadata.Set(metadata)
+	return metadata, nil
+}
+
+// SetStoreMetadata records the document in which the store records its own configuration
+func (store *Journal) SetStoreMetadata(ctx context.Context, metadata vcblobstore.StoreMetadata, modifiedBy string) error {
+	content, encodeErr := vcblobstore.EncodeStoreMetadata(metadata)
+	if encodeErr != nil {
+		return encodeErr
+	}
+
+	err := store.write(ctx, vcblobstore.Author{Name: modifiedBy}, "store metadata set", func(changes changeSet) error {
+		changes[store.naming.StoreMetadataKey()] = content
+		return nil
+	})
+
+	if err != nil {
+		store.storeMetadata.Invalidate()
+		return fmt.Errorf("failed to set store metadata in journal store at %s: %w", store.location, err)
+	}
+	store.storeMetadata.Set(metadata)
+	return nil
+}
+
+// GetBlobInfo returns the content of the blob along with its metadata attributes
+func (store *Journal) GetBlobInfo(ctx context.Context, key string) (vcblobstore.BlobInfo, error) {
+	canonicalKey, resolveErr := store.ResolveAlias(ctx, key)
+	if resolveErr != nil {
+		return vcblobstore.BlobInfo{}, resolveErr
+	}
+
+	content, getErr := store.GetBlob(ctx, canonicalKey)
+	if getErr != nil {
+		return vcblobstore.BlobInfo{}, getErr
+	}
+
+	var metadata map[string]string
+	metadataErr := store.read(ctx, func() error {
+		var err error
+		metadata, err = store.stagedMetadata(changeSet{}, canonicalKey)
+		return err
+	})
+	if metadataErr != nil {
+		return vcblobstore.BlobInfo{}, metadataErr
+	}
+
+	return vcblobstore.BlobInfo{
+		Key:      key,
+		Content:  content,
+		Metadata: metadata,
+		SHA256:   vcblobstore.ContentSHA256(content),
+	}, nil
+}
+
+// GetBlobWithChecksum returns the content of the blob along with its SHA-256 digest
+func (store *Journal) GetBlobWithChecksum(ctx context.Context, key string) ([]byte, string, error) {
+	content, err := store.GetBlob(ctx, key)
+	if err != nil {
+		return nil, "", err
+	}
+	return content, vcblobstore.ContentSHA256(content), nil
+}
+
+// UpdateBlobMetadata replaces the metadata attributes of the blob without rewriting its content
+func (store *Journal) UpdateBlobMetadata(ctx context.Context, key string, metadata map[string]string, modifiedBy string) error {
+	if keyErr := vcblobstore.ValidateBlobKey(store.naming, key); keyErr != nil {
+		return keyErr
+	}
+	err := store.write(ctx, vcblobstore.Author{Name: modifiedBy}, "blob metadata updated", func(changes changeSet) error {
+		if _, exists := store.tree[key]; !exists {
+			return vcblobstore.ErrBlobNotFound
+		}
+		return store.stageMetadata(changes, key, metadata)
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to update metadata of blob %s in journal store: %w", key, err)
+	}
+	store.access.RecordWrite(key)
+	return nil
+}
+
+func (store *Journal) CopyBlob(ctx context.Context, sourceKey string, destinationKey string, modifiedBy string) error {
+	if keyErr := vcblobstore.ValidateBlobKey(store.naming, destinationKey); keyErr != nil {
+		return keyErr
+	}
+
+	err := store.write(ctx, vcblobstore.Author{Name: modifiedBy}, "blob file version added", func(changes changeSet) error {
+		content, exists, readErr := store.current(sourceKey)
+		if readErr != nil {
+			return readErr
+		}
+		if !exists {
+			return fmt.Errorf("failed to copy blob %s: %w", sourceKey, vcblobstore.ErrBlobNotFound)
+		}
+		changes[destinationKey] = content
+		metadata, metadataErr := store.stagedMetadata(changes, sourceKey)
+		if metadataErr != nil {
+			return metadataErr
+		}
+		return store.stageMetadata(changes, destinationKey, metadata)
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to copy blob from %s to %s in journal store at %s: %w", sourceKey, destinationKey, store.location, err)
+	}
+	store.access.RecordWrite(destinationKey)
+	return nil
+}
+
+func (store *Journal) GetBlob(ctx context.Context, key string) ([]byte, error) {
+	if keyErr := vcblobstore.ValidateKey(key); keyErr != nil {
+		return nil, keyErr
+	}
+
+	var content []byte
+	var exists bool
+	err := store.read(ctx, func() error {
+		var readErr error
+		content, exists, readErr = store.current(key)
+		return readErr
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob %s from journal store: %w", key, err)
+	}
+	if !exists {
+		canonicalKey, resolveErr := store.ResolveAlias(ctx, key)
+		if resolveErr != nil {
+			return nil, resolveErr
+		}
+		if canonicalKey != key {
+			return store.GetBlob(ctx, canonicalKey)
+		}
+		return nil, fmt.Errorf("failed to read blob %s from journal store: %w", key, vcblobstore.ErrBlobNotFound)
+	}
+	store.access.RecordRead(key)
+	return store.external.Resolve(ctx, content)
+}
+
+// HotKeys returns the topN most frequently accessed keys. It is empty unless access tracking is configured
+func (store *Journal) HotKeys(ctx context.Context, topN int) []vcblobstore.AccessStats {
+	return store.access.HotKeys(topN)
+}
+
+// FindDuplicates reports the groups of blobs with identical content. The objects being content addressed,
+// nothing needs to be hashed
+func (store *Journal) FindDuplicates(ctx context.Context) ([]vcblobstore.DuplicateGroup, error) {
+	hashes := []vcblobstore.ContentHash{}
+	err := store.read(ctx, func() error {
+		for key, entry := range store.tree {
+			if !store.naming.IsInternalKey(key) {
+				hashes = append(hashes, vcblobstore.ContentHash{Key: key, SHA256: entry.object, Size: entry.size})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vcblobstore.GroupDuplicates(hashes), nil
+}
+
+func (store *Journal) lookupAlias(key string) (string, bool, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if loadErr := store.load(); loadErr != nil {
+		return "", false, loadErr
+	}
+	return store.lookupStagedAlias(changeSet{}, key)
+}
+
+func (store *Journal) lookupStagedAlias(changes changeSet, key string) (string, bool, error) {
+	content, exists, readErr := store.staged(changes, store.naming.AliasPointerKey(key))
+	if readErr != nil {
+		return "", false, fmt.Errorf("failed to read alias pointer of %s: %w", key, readErr)
+	}
+	if !exists {
+		return "", false, nil
+	}
+
+	target, decodeErr := vcblobstore.DecodeAliasPointer(content)
+	if decodeErr != nil {
+		return "", false, fmt.Errorf("failed to read alias %s: %w", key, decodeErr)
+	}
+	return target, true, nil
+}
+
+// CreateAlias makes reads of the alias key redirect to target
+func (store *Journal) CreateAlias(ctx context.Context, alias string, target string, modifiedBy string) error {
+	pointer, encodeErr := vcblobstore.EncodeAliasPointer(target)
+	if encodeErr != nil {
+		return encodeErr
+	}
+
+	err := store.write(ctx, vcblobstore.Author{Name: modifiedBy}, "blob file version added", func(changes changeSet) error {
+		lookup := func(key string) (string, bool, error) {
+			return store.lookupStagedAlias(changes, key)
+		}
+		if loopErr := vcblobstore.CheckNewAlias(alias, target, lookup); loopErr != nil {
+			return loopErr
+		}
+		changes[store.naming.AliasPointerKey(alias)] = pointer
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to create alias %s -> %s: %w", alias, target, err)
+	}
+	return nil
+}
+
+// ResolveAlias returns the canonical key the key redirects to (the key itself in case it is not an alias)
+func (store *Journal) ResolveAlias(ctx context.Context, key string) (string, error) {
+	return vcblobstore.ResolveAlias(key, store.lookupAlias)
+}
+
+func (store *Journal) ListAliases(ctx context.Context) ([]vcblobstore.Alias, error) {
+	aliases := []vcblobstore.Alias{}
+	err := store.read(ctx, func() error {
+		for _, pointerKey := range store.sortedKeys() {
+			if !strings.HasPrefix(pointerKey, store.naming.AliasDirectory()) {
+				continue
+			}
+			alias := store.naming.AliasFromPointerKey(pointerKey)
+			target, _, lookupErr := store.lookupStagedAlias(changeSet{}, alias)
+			if lookupErr != nil {
+				return lookupErr
+			}
+			aliases = append(aliases, vcblobstore.Alias{Alias: alias, Target: target})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alias pointers: %w", err)
+	}
+	return aliases, nil
+}
+
+func (store *Journal) stageDeletion(changes changeSet, key string) error {
+	if _, exists := store.tree[key]; !exists {
+		return fmt.Errorf("failed to remove blob %s: %w", key, vcblobstore.ErrBlobNotFound)
+	}
+	changes[key] = nil
+	return store.stageMetadata(changes, key, nil)
+}
+
+func (store *Journal) DeleteBlob(ctx context.Context, key string, modifiedBy string) error {
+	if keyErr := vcblobstore.ValidateBlobKey(store.naming, key); keyErr != nil {
+		return keyErr
+	}
+	err := store.write(ctx, vcblobstore.Author{Name: modifiedBy}, "blob deleted", func(changes changeSet) error {
+		return store.stageDeletion(changes, key)
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to remove blob %s from journal store: %w", key, err)
+	}
+	store.access.RecordWrite(key)
+	return nil
+}
+
+// DeleteBlobs removes all the specified blobs in a single version
+func (store *Journal) DeleteBlobs(ctx context.Context, keys []string, modifiedBy string) error {
+	for _, key := range keys {
+		if keyErr := vcblobstore.ValidateBlobKey(store.naming, key); keyErr != nil {
+			return keyErr
+		}
+	}
+	err := store.write(ctx, vcblobstore.Author{Name: modifiedBy}, fmt.Sprintf("%d blobs deleted", len(keys)), func(changes changeSet) error {
+		for _, key := range keys {
+			if deletionErr := store.stageDeletion(changes, key); deletionErr != nil {
+				return deletionErr
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to remove %d blobs from journal store: %w", len(keys), err)
+	}
+	store.access.RecordWrite(keys...)
+	return nil
+}
+
+// ApplyChanges records the changes in a single version
+func (store *Journal) ApplyChanges(ctx context.Context, changes []vcblobstore.BlobChange, message string, author vcblobstore.Author) error {
+	contents, prepareErr := vcblobstore.PrepareChanges(ctx, changes, store.naming, store.textMode, store.external)
+	if prepareErr != nil {
+		return prepareErr
+	}
+
+	err := store.write(ctx, author, message, func(staged changeSet) error {
+		for index, change := range changes {
+			key := change.Blob.Key
+			if change.Delete {
+				if deletionErr := store.stageDeletion(staged, key); deletionErr != nil {
+					return deletionErr
+				}
+				continue
+			}
+			staged[key] = contents[index]
+			if change.Blob.Metadata == nil {
+				continue
+			}
+			if metadataErr := store.stageMetadata(staged, key, change.Blob.Metadata); metadataErr != nil {
+				return metadataErr
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to apply %d changes to journal store at %s: %w", len(changes), store.location, err)
+	}
+	store.access.RecordWrite(vcblobstore.ChangedKeys(changes)...)
+	return nil
+}
+
+// RestoreBlob records the content the blob had at the specified version as its new version
+func (store *Journal) RestoreBlob(ctx context.Context, key string, version string, modifiedBy string) error {
+	if keyErr := vcblobstore.ValidateBlobKey(store.naming, key); keyErr != nil {
+		return keyErr
+	}
+	err := store.write(ctx, vcblobstore.Author{Name: modifiedBy}, fmt.Sprintf("blob %s restored to version %s", key, version), func(changes changeSet) error {
+		content, found, contentErr := store.blobAtVersion(key, version)
+		if contentErr == nil && !found {
+			contentErr = vcblobstore.ErrBlobNotFound
+		}
+		if contentErr != nil {
+			return contentErr
+		}
+		changes[key] = content
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to restore blob %s to version %s in journal store: %w", key, version, err)
+	}
+	store.access.RecordWrite(key)
+	return nil
+}
+
+// RenameBlob moves the blob along with its metadata to a new key
+func (store *Journal) RenameBlob(ctx context.Context, oldKey string, newKey string, modifiedBy string) error {
+	for _, key := range []string{oldKey, newKey} {
+		if keyErr := vcblobstore.ValidateBlobKey(store.naming, key); keyErr != nil {
+			return keyErr
+		}
+	}
+
+	err := store.write(ctx, vcblobstore.Author{Name: modifiedBy}, "blob renamed", func(changes changeSet) error {
+		content, exists, readErr := store.current(oldKey)
+		if readErr != nil {
+			return readErr
+		}
+		if !exists {
+			return fmt.Errorf("failed to rename blob %s: %w", oldKey, vcblobstore.ErrBlobNotFound)
+		}
+		metadata, metadataErr := store.stagedMetadata(changes, oldKey)
+		if metadataErr != nil {
+			return metadataErr
+		}
+		changes[oldKey] = nil
+		changes[newKey] = content
+		if removeErr := store.stageMetadata(changes, oldKey, nil); removeErr != nil {
+			return removeErr
+		}
+		return store.stageMetadata(changes, newKey, metadata)
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to rename blob %s to %s in journal store: %w", oldKey, newKey, err)
+	}
+	store.access.RecordWrite(oldKey, newKey)
+	return nil
+}
+
+// SelfTest exercises writing, reading and deleting a probe blob and reports the outcome and the latency of each step
+func (store *Journal) SelfTest(ctx context.Context, modifiedBy string) vcblobstore.SelfTestReport {
+	return vcblobstore.RunSelfTest(ctx, store, store.naming, modifiedBy)
+}
+
+// CheckStatus reports whether the journal ends with a complete entry (i.e. no append was interrupted since the last one)
+func (store *Journal) CheckStatus() (bool, error) {
+	clean := false
+	err := store.read(context.Background(), func() error {
+		fileInfo, statErr := os.Stat(store.journalPath())
+		if statErr != nil {
+			return fmt.Errorf("failed to check journal at %s: %w", store.location, statErr)
+		}
+		clean = fileInfo.Size() == store.validSize
+		return nil
+	})
+	return clean, err
+}
+
+// GetStateID returns the last version of the store (empty if there is none yet)
+func (store *Journal) GetStateID(ctx context.Context) (string, error) {
+	var version string
+	err := store.read(ctx, func() error {
+		version = store.headVersion()
+		return nil
+	})
+	return version, err
+}
+
+func (store *Journal) sortedKeys() []string {
+	keys := make([]string, 0, len(store.tree))
+	for key := range store.tree {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (store *Journal) ListBlobKeys(ctx context.Context, opts vcblobstore.ListOptions) ([]string, error) {
+	keys := []string{}
+	err := store.read(ctx, func() error {
+		for _, key := range store.sortedKeys() {
+			// The journal keeps no file modes: its blobs are regular files
+			if opts.Matches(store.naming, key) && opts.MatchesMode(vcblobstore.FileModeRegular) {
+				keys = append(keys, key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// IterateBlobKeys walks the keys of the store as of the time of the call
+func (store *Journal) IterateBlobKeys(ctx context.Context, opts vcblobstore.ListOptions) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		keys, listErr := store.ListBlobKeys(ctx, opts)
+		if listErr != nil {
+			yield("", fmt.Errorf("failed to list blob keys: %w", listErr))
+			return
+		}
+		for _, key := range keys {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				yield("", ctxErr)
+				return
+			}
+			if !yield(key, nil) {
+				return
+			}
+		}
+	}
+}
+
+// GetTree returns the hierarchy of directories and blobs under prefix down to depth levels (depth < 1 means no limit)
+func (store *Journal) GetTree(ctx context.Context, prefix string, depth int) (*vcblobstore.TreeNode, error) {
+	entries := []vcblobstore.TreeEntry{}
+	err := store.read(ctx, func() error {
+		for _, key := range store.sortedKeys() {
+			entries = append(entries, vcblobstore.TreeEntry{Path: key, BlobId: store.tree[key].object})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tree under %s: %w", prefix, err)
+	}
+	return vcblobstore.BuildTree(store.naming, prefix, depth, entries), nil
+}
+
+// GetVersionFor returns the last version changing the key. Returns empty string in case the key has never existed
+func (store *Journal) GetVersionFor(ctx context.Context, key string) (string, error) {
+	if keyErr := vcblobstore.ValidateKey(key); keyErr != nil {
+		return "", keyErr
+	}
+
+	var version string
+	err := store.read(ctx, func() error {
+		for index := len(store.entries) - 1; index >= 0 && len(version) == 0; index-- {
+			for _, change := range store.entries[index].Changes {
+				if change.Key == key {
+					version = store.entries[index].Version
+					break
+				}
+			}
+		}
+		return nil
+	})
+	return version, err
+}
+
+// HeadBlob returns the size and the current version of the blob without reading its content.
+// The returned BlobHead has Exists set to false in case the blob doesn't exist
+func (store *Journal) HeadBlob(ctx context.Context, key string) (vcblobstore.BlobHead, error) {
+	blobHead := vcblobstore.BlobHead{Key: key}
+	if keyErr := vcblobstore.ValidateKey(key); keyErr != nil {
+		return blobHead, keyErr
+	}
+
+	err := store.read(ctx, func() error {
+		if entry, exists := store.tree[key]; exists {
+			blobHead.Exists = true
+			blobHead.Size = entry.size
+			blobHead.Mode = vcblobstore.FileModeRegular
+			blobHead.Version = entry.version
+		}
+		return nil
+	})
+	return blobHead, err
+}
+
+func (store *Journal) GetVersionMetadata(ctx context.Context, version string) (git.CommitMetadata, error) {
+	var metadata git.CommitMetadata
+	err := store.read(ctx, func() error {
+		index, found := store.byVersion[version]
+		if !found {
+			return fmt.Errorf("failed to get metadata of version %s: no such version", version)
+		}
+		existing := map[string]bool{}
+		for _, entry := range store.entries[:index] {
+			store.entryVersion(entry, existing)
+		}
+		entry := store.entries[index]
+		metadata = git.CommitMetadata{
+			Author:     entry.Author,
+			AuthorDate: entry.Date,
+			Commit:     entry.Author,
+			CommitDate: entry.Date,
+			Message:    entry.Message,
+			Changes:    store.entryVersion(entry, existing).ChangedKeys(),
+		}.PinOffsets()
+		return nil
+	})
+	return metadata, err
+}
+
+// blobAtVersion returns the raw content of the key as of the version and false in case it didn't exist then
+func (store *Journal) blobAtVersion(key string, version string) ([]byte, bool, error) {
+	last, found := store.byVersion[version]
+	if !found {
+		return nil, false, nil
+	}
+
+	object := ""
+	for _, entry := range store.entries[:last+1] {
+		for _, change := range entry.Changes {
+			if change.Key == key {
+				object = change.Object
+			}
+		}
+	}
+	if len(object) == 0 {
+		return nil, false, nil
+	}
+	content, readErr := store.readObject(object)
+	if readErr != nil {
+		return nil, false, readErr
+	}
+	return content, true, nil
+}
+
+// GetBlobAtVersion returns the content of the blob as it existed at the specified version
+func (store *Journal) GetBlobAtVersion(ctx context.Context, key string, version string) ([]byte, error) {
+	var content []byte
+	var found bool
+	err := store.read(ctx, func() error {
+		var readErr error
+		content, found, readErr = store.blobAtVersion(key, version)
+		return readErr
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("failed to read %s at version %s from journal store: %w", key, version, vcblobstore.ErrBlobNotFound)
+	}
+	return store.external.Resolve(ctx, content)
+}
+
+// objectsAtVersion returns the objects of the entries by key as of the version
+func (store *Journal) objectsAtVersion(version string) (map[string]string, error) {
+	last, found := store.byVersion[version]
+	if !found {
+		return nil, fmt.Errorf("no such version %s", version)
+	}
+	objects := map[string]string{}
+	for _, entry := range store.entries[:last+1] {
+		for _, change := range entry.Changes {
+			if len(change.Object) == 0 {
+				delete(objects, change.Key)
+				continue
+			}
+			objects[change.Key] = change.Object
+		}
+	}
+	return objects, nil
+}
+
+// GetBlobMetadataAtVersion returns the metadata of the blob as it was at the version
+func (store *Journal) GetBlobMetadataAtVersion(ctx context.Context, key string, version string) (map[string]string, error) {
+	metadata := map[string]string{}
+	err := store.read(ctx, func() error {
+		objects, versionErr := store.objectsAtVersion(version)
+		if versionErr != nil {
+			return versionErr
+		}
+		if _, exists := objects[key]; !exists {
+			return vcblobstore.ErrBlobNotFound
+		}
+		object, hasMetadata := objects[store.naming.MetadataSidecarKey(key)]
+		if !hasMetadata {
+			return nil
+		}
+		content, readErr := store.readObject(object)
+		if readErr != nil {
+			return readErr
+		}
+		var decodeErr error
+		metadata, decodeErr = vcblobstore.DecodeMetadata(content)
+		return decodeErr
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the metadata of %s at version %s from journal store: %w", key, version, err)
+	}
+	return metadata, nil
+}
+
+// GetBlobModeAtVersion returns the file mode of the blob as it was at the version. The journal keeps no modes, so
+// every blob is a regular file
+func (store *Journal) GetBlobModeAtVersion(ctx context.Context, key string, version string) (vcblobstore.FileMode, error) {
+	err := store.read(ctx, func() error {
+		objects, versionErr := store.objectsAtVersion(version)
+		if versionErr != nil {
+			return versionErr
+		}
+		if _, exists := objects[key]; !exists {
+			return vcblobstore.ErrBlobNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read the mode of %s at version %s from journal store: %w", key, version, err)
+	}
+	return vcblobstore.FileModeRegular, nil
+}
+
+// ListAliasesAtVersion returns the aliases as of the version
+func (store *Journal) ListAliasesAtVersion(ctx context.Context, version string) ([]vcblobstore.Alias, error) {
+	aliases := []vcblobstore.Alias{}
+	err := store.read(ctx, func() error {
+		objects, versionErr := store.objectsAtVersion(version)
+		if versionErr != nil {
+			return versionErr
+		}
+		pointerKeys := []string{}
+		for key := range objects {
+			if strings.HasPrefix(key, store.naming.AliasDirectory()) {
+				pointerKeys = append(pointerKeys, key)
+			}
+		}
+		sort.Strings(pointerKeys)
+		for _, pointerKey := range pointerKeys {
+			content, readErr := store.readObject(objects[pointerKey])
+			if readErr != nil {
+				return readErr
+			}
+			target, decodeErr := vcblobstore.DecodeAliasPointer(content)
+			if decodeErr != nil {
+				return decodeErr
+			}
+			aliases = append(aliases, vcblobstore.Alias{Alias: store.naming.AliasFromPointerKey(pointerKey), Target: target})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alias pointers at version %s: %w", version, err)
+	}
+	return aliases, nil
+}
+
+// versionsOf returns the entries changing the key, oldest first
+func (store *Journal) versionsOf(key string) []journalEntry {
+	entries := []journalEntry{}
+	for _, entry := range store.entries {
+		for _, change := range entry.Changes {
+			if change.Key == key {
+				entries = append(entries, journalEntry{
+					Version: entry.Version,
+					Author:  entry.Author,
+					Date:    entry.Date,
+					Message: entry.Message,
+					Changes: []journalChange{change},
+				})
+				break
+			}
+		}
+	}
+	return entries
+}
+
+// GetBlobHistory returns the versions of the blob matching the filter, newest first
+func (store *Journal) GetBlobHistory(ctx context.Context, key string, filter vcblobstore.HistoryFilter) ([]vcblobstore.BlobVersion, error) {
+	versions := []vcblobstore.BlobVersion{}
+	err := store.read(ctx, func() error {
+		sinceIndex := -1
+		if len(filter.SinceVersion) > 0 {
+			index, found := store.byVersion[filter.SinceVersion]
+			if !found {
+				return fmt.Errorf("no such version %s", filter.SinceVersion)
+			}
+			sinceIndex = index
+		}
+		existed := false
+		for _, entry := range store.versionsOf(key) {
+			version := vcblobstore.BlobVersion{
+				Version:    entry.Version,
+				Operation:  vcblobstore.BlobOperationUpdate,
+				Author:     entry.Author,
+				AuthorDate: entry.Date,
+				Message:    entry.Message,
+			}
+			switch {
+			case len(entry.Changes[0].Object) == 0:
+				version.Operation = vcblobstore.BlobOperationDelete
+				existed = false
+			case !existed:
+				version.Operation = vcblobstore.BlobOperationCreate
+				existed = true
+			}
+			if store.byVersion[entry.Version] <= sinceIndex {
+				continue
+			}
+			if filter.Matches(version) {
+				versions = append([]vcblobstore.BlobVersion{version}, versions...)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the history of %s: %w", key, err)
+	}
+	return versions, nil
+}
+
+// ListVersions returns the versions made after fromStateID up to and including toStateID, oldest first
+func (store *Journal) ListVersions(ctx context.Context, fromStateID string, toStateID string) ([]vcblobstore.RepositoryVersion, error) {
+	versions := []vcblobstore.RepositoryVersion{}
+	err := store.read(ctx, func() error {
+		fromIndex, toIndex := -1, len(store.entries)-1
+		if len(fromStateID) > 0 {
+			index, found := store.byVersion[fromStateID]
+			if !found {
+				return fmt.Errorf("no such version %s", fromStateID)
+			}
+			fromIndex = index
+		}
+		if len(toStateID) > 0 {
+			index, found := store.byVersion[toStateID]
+			if !found {
+				return fmt.Errorf("no such version %s", toStateID)
+			}
+			toIndex = index
+		}
+
+		existing := map[string]bool{}
+		for index, entry := range store.entries[:toIndex+1] {
+			version := store.entryVersion(entry, existing)
+			if index > fromIndex {
+				versions = append(versions, version)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the versions from %s to %s: %w", fromStateID, toStateID, err)
+	}
+	return versions, nil
+}
+
+// entryVersion returns the version recorded by the entry. existing holds the keys present before the entry and is updated with its changes
+func (store *Journal) entryVersion(entry journalEntry, existing map[string]bool) vcblobstore.RepositoryVersion {
+	version := vcblobstore.RepositoryVersion{
+		Version:    entry.Version,
+		Author:     entry.Author,
+		AuthorDate: entry.Date,
+		Message:    entry.Message,
+		Changes:    []vcblobstore.KeyChange{},
+	}
+	for _, change := range entry.Changes {
+		operation := vcblobstore.BlobOperationUpdate
+		switch {
+		case len(change.Object) == 0:
+			operation = vcblobstore.BlobOperationDelete
+			delete(existing, change.Key)
+		case !existing[change.Key]:
+			operation = vcblobstore.BlobOperationCreate
+			existing[change.Key] = true
+		}
+		version.AddEntryChange(store.naming, change.Key, operation)
+	}
+	return version
+}
+
+// ListChangesBetween returns the net changes of the blobs between the states, folded from the versions in between
+func (store *Journal) ListChangesBetween(ctx context.Context, fromStateID string, toStateID string) ([]vcblobstore.KeyChange, error) {
+	versions, listErr := store.ListVersions(ctx, fromStateID, toStateID)
+	if listErr != nil {
+		return nil, listErr
+	}
+	return vcblobstore.NetChanges(versions), nil
+}
+
+// ExportHistory writes every version of the specified blobs to w as a history bundle
+func (store *Journal) ExportHistory(ctx context.Context, keys []string, w io.Writer) error {
+	records := []vcblobstore.HistoryRecord{}
+	err := store.read(ctx, func() error {
+		for _, key := range keys {
+			for _, entry := range store.versionsOf(key) {
+				record := vcblobstore.HistoryRecord{
+					Key:        key,
+					Version:    entry.Version,
+					Author:     entry.Author,
+					AuthorDate: entry.Date,
+					Message:    entry.Message,
+					Deleted:    len(entry.Changes[0].Object) == 0,
+				}
+				if !record.Deleted {
+					content, readErr := store.readObject(entry.Changes[0].Object)
+					if readErr != nil {
+						return readErr
+					}
+					// The bundle carries the content itself: the pointers are of no use to the store importing it
+					if record.Content, readErr = store.external.Resolve(ctx, content); readErr != nil {
+						return readErr
+					}
+				}
+				records = append(records, record)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return vcblobstore.WriteHistory(w, records)
+}
+
+// ImportHistory replays the versions of a history bundle as new versions, preserving the order, the authors, the dates
+// and the messages of the changes
+func (store *Journal) ImportHistory(ctx context.Context, r io.Reader) error {
+	return vcblobstore.ReadHistory(r, func(record vcblobstore.HistoryRecord) error {
+		ctx := vcblobstore.WithImportedRecord(ctx, record)
+		if record.Deleted {
+			return store.DeleteBlob(ctx, record.Key, record.AuthorName())
+		}
+		return store.AddBlob(ctx, vcblobstore.BlobInfo{
+			Key:         record.Key,
+			Content:     record.Content,
+			AuthorName:  record.AuthorName(),
+			AuthorEmail: record.AuthorEmail(),
+		})
+	})
+}
+
+type Config struct {
+	Location string
+	// TextMode lists the key patterns of text blobs to normalize on write
+	TextMode vcblobstore.TextMode
+	// ExternalStorage, if set, receives the content of oversized blobs
+	ExternalStorage *vcblobstore.ExternalStorage
+	// AccessTracker, if set, counts the reads and writes of the keys
+	AccessTracker *vcblobstore.AccessTracker
+	// Naming decides the keys of the entries derived from the blobs (DefaultNaming if unset)
+	Naming vcblobstore.NamingStrategy
+}
+
+func NewJournalStore(journalConfig *Config, logger *zerolog.Logger) *Journal {
+	return &Journal{
+		location: journalConfig.Location,
+		logger:   logger,
+		textMode: journalConfig.TextMode,
+		external: journalConfig.ExternalStorage,
+		access:   journalConfig.AccessTracker,
+		naming:   vcblobstore.NamingOrDefault(journalConfig.Naming),
+
+		storeMetadata: &vcblobstore.StoreMetadataCache{},
+	}
+}
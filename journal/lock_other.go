@@ -0,0 +1,11 @@
+//go:build !unix
+
+package journal
+
+import "os"
+
+// lockFile is a no-op where advisory file locks are not available: the writers of other processes are only
+// detected by the journal having changed its size since it was read
+func lockFile(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,40 @@
+package vcblobstore
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ValidateKey checks that the key is a clean, relative, slash-separated path which stays within the repository:
+// no empty, "." or ".." segments, no leading or trailing slash and no reference to the .git directory
+func ValidateKey(key string) error {
+	if len(key) == 0 {
+		return fmt.Errorf("empty key: %w", ErrInvalidKey)
+	}
+	if strings.ContainsAny(key, "\\\x00") {
+		return fmt.Errorf("invalid character in key %q: %w", key, ErrInvalidKey)
+	}
+	if path.Clean(key) != key || path.IsAbs(key) {
+		return fmt.Errorf("key %q is not a clean relative path: %w", key, ErrInvalidKey)
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == ".." || segment == ".git" {
+			return fmt.Errorf("key %q contains the forbidden segment %q: %w", key, segment, ErrInvalidKey)
+		}
+	}
+	return nil
+}
+
+// ValidateBlobKey checks the key of a blob the caller writes: a valid key (see ValidateKey) outside the internal
+// entries of the store (metadata sidecars, alias pointers, etc.), which only the store itself writes. The probe blobs
+// of the self-test are the only blobs written under the internal keys
+func ValidateBlobKey(naming NamingStrategy, key string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	if naming.IsInternalKey(key) && !strings.HasPrefix(key, naming.ProbeKey("")) {
+		return fmt.Errorf("key %q is reserved for the internal entries of the store: %w", key, ErrInvalidKey)
+	}
+	return nil
+}
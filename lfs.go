@@ -0,0 +1,134 @@
+package vcblobstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// lfsPointerVersion starts the pointer files committed in place of the content of Git LFS objects
+const lfsPointerVersion = "version https://git-lfs.github.com/spec/v1\n"
+
+var ErrLFSObjectMissing = errors.New("LFS object missing")
+
+// LFSPointer identifies a Git LFS object by the SHA-256 digest and the size of its content
+type LFSPointer struct {
+	OID  string
+	Size int64
+}
+
+// Encode returns the pointer file of the object as Git LFS writes it
+func (pointer LFSPointer) Encode() []byte {
+	return []byte(fmt.Sprintf("%soid sha256:%s\nsize %d\n", lfsPointerVersion, pointer.OID, pointer.Size))
+}
+
+// ParseLFSPointer decodes the pointer file and returns false for any other content
+func ParseLFSPointer(content []byte) (LFSPointer, bool) {
+	if !bytes.HasPrefix(content, []byte(lfsPointerVersion)) || len(content) > 1024 {
+		return LFSPointer{}, false
+	}
+	pointer := LFSPointer{}
+	for _, line := range strings.Split(strings.TrimPrefix(string(content), lfsPointerVersion), "\n") {
+		name, value, _ := strings.Cut(line, " ")
+		switch name {
+		case "oid":
+			oid, isSHA256 := strings.CutPrefix(value, "sha256:")
+			if !isSHA256 || !isLFSOID(oid) {
+				return LFSPointer{}, false
+			}
+			pointer.OID = oid
+		case "size":
+			size, parseErr := strconv.ParseInt(value, 10, 64)
+			if parseErr != nil || size < 0 {
+				return LFSPointer{}, false
+			}
+			pointer.Size = size
+		}
+	}
+	return pointer, len(pointer.OID) > 0
+}
+
+// isLFSOID tells whether the OID is a SHA-256 digest in lowercase hex, as the spec requires: the object stores
+// build paths and URLs of it
+func isLFSOID(oid string) bool {
+	if len(oid) != 64 {
+		return false
+	}
+	for _, char := range oid {
+		if !('0' <= char && char <= '9' || 'a' <= char && char <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// LFSObjectStore is where a backend keeps the Git LFS objects, e.g. the LFS storage of the git server
+type LFSObjectStore interface {
+	Upload(ctx context.Context, pointer LFSPointer, content []byte) error
+	// Download fails with ErrLFSObjectMissing if the store doesn't have the object
+	Download(ctx context.Context, pointer LFSPointer) ([]byte, error)
+}
+
+// LFS selects the blobs to store as Git LFS objects: those larger than Threshold bytes (unless it is 0)
+// and those with keys matching any of the Patterns (path.Match syntax)
+type LFS struct {
+	Threshold int
+	Patterns  []string
+}
+
+// Tracks tells whether the blob with the key and the content of the size is stored as an LFS object
+func (lfs *LFS) Tracks(key string, size int) bool {
+	if lfs == nil {
+		return false
+	}
+	if lfs.Threshold > 0 && size > lfs.Threshold {
+		return true
+	}
+	for _, pattern := range lfs.Patterns {
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Offload uploads the content of tracked blobs to the object store and returns the pointer file to commit instead.
+// The content of other blobs, as well as pointers to external objects, are returned as is
+func (lfs *LFS) Offload(ctx context.Context, objects LFSObjectStore, key string, content []byte) ([]byte, error) {
+	if !lfs.Tracks(key, len(content)) || IsExternalPointer(content) {
+		return content, nil
+	}
+	if _, isPointer := ParseLFSPointer(content); isPointer {
+		return content, nil
+	}
+
+	pointer := LFSPointer{OID: ContentSHA256(content), Size: int64(len(content))}
+	if err := objects.Upload(ctx, pointer, content); err != nil {
+		return nil, fmt.Errorf("failed to upload LFS object %s of %s: %w", pointer.OID, key, err)
+	}
+	return pointer.Encode(), nil
+}
+
+// Resolve returns the content of the LFS object the pointer file refers to. Other content is returned as is
+func (lfs *LFS) Resolve(ctx context.Context, objects LFSObjectStore, content []byte) ([]byte, error) {
+	if lfs == nil {
+		return content, nil
+	}
+	pointer, isPointer := ParseLFSPointer(content)
+	if !isPointer {
+		return content, nil
+	}
+
+	object, downloadErr := objects.Download(ctx, pointer)
+	if downloadErr != nil {
+		return nil, fmt.Errorf("failed to download LFS object %s: %w", pointer.OID, downloadErr)
+	}
+	if ContentSHA256(object) != pointer.OID || int64(len(object)) != pointer.Size {
+		return nil, fmt.Errorf("failed to verify LFS object %s: %w", pointer.OID, ErrExternalObjectCorrupted)
+	}
+	return object, nil
+}
@@ -0,0 +1,304 @@
+package vcblobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// LoadOperation is an operation the load generator issues against the store
+type LoadOperation string
+
+const (
+	LoadOperationRead   LoadOperation = "read"
+	LoadOperationWrite  LoadOperation = "write"
+	LoadOperationDelete LoadOperation = "delete"
+	LoadOperationList   LoadOperation = "list"
+)
+
+// KeyDistribution decides how often the keys of the key space are picked
+type KeyDistribution string
+
+const (
+	KeyDistributionUniform KeyDistribution = "uniform"
+	// KeyDistributionZipf picks a few hot keys most of the time, like most real-life workloads do
+	KeyDistributionZipf KeyDistribution = "zipf"
+)
+
+// LoadProfile describes the simulated workload. The load stops after Operations operations or after
+// Duration, whichever comes first (at least one of them has to be set)
+type LoadProfile struct {
+	Operations int
+	Duration   time.Duration
+	// Concurrency is the number of concurrent clients (1 if unset)
+	Concurrency int
+	// Mix holds the relative weight of the operations, e.g. {read: 9, write: 1}
+	Mix map[LoadOperation]int
+	// KeyCount is the size of the key space, the keys being KeyPrefix followed by their index
+	KeyCount     int
+	KeyPrefix    string
+	Distribution KeyDistribution
+	// MinBlobSize and MaxBlobSize bound the size of the written blobs (MaxBlobSize defaults to MinBlobSize)
+	MinBlobSize int
+	MaxBlobSize int
+	// RatePerSecond, if set, caps the rate of the operations across all clients (e.g. to stay below a rate limit)
+	RatePerSecond float64
+	// Preload writes every key of the key space before the measurement, so that the reads don't miss
+	Preload    bool
+	Seed       int64
+	ModifiedBy string
+}
+
+// LoadOperationReport holds the statistics of an operation. Misses are the reads and deletes of absent keys
+type LoadOperationReport struct {
+	Operation  LoadOperation
+	Count      int
+	Errors     int
+	Misses     int
+	P50        time.Duration
+	P90        time.Duration
+	P99        time.Duration
+	Max        time.Duration
+	Throughput float64
+}
+
+// LoadReport is the outcome of a simulation, the operations ordered by name
+type LoadReport struct {
+	Elapsed    time.Duration
+	Total      int
+	Errors     int
+	Throughput float64
+	Operations []LoadOperationReport
+}
+
+type loadSample struct {
+	operation LoadOperation
+	latency   time.Duration
+	err       error
+}
+
+var errInvalidLoadProfile = errors.New("invalid load profile")
+
+func (profile LoadProfile) validate() error {
+	switch {
+	case profile.Operations <= 0 && profile.Duration <= 0:
+		return fmt.Errorf("%w: neither the number of operations nor the duration is set", errInvalidLoadProfile)
+	case profile.KeyCount <= 0:
+		return fmt.Errorf("%w: the key space is empty", errInvalidLoadProfile)
+	}
+	totalWeight := 0
+	for operation, weight := range profile.Mix {
+		switch operation {
+		case LoadOperationRead, LoadOperationWrite, LoadOperationDelete, LoadOperationList:
+		default:
+			return fmt.Errorf("%w: unknown operation %s", errInvalidLoadProfile, operation)
+		}
+		totalWeight += weight
+	}
+	if totalWeight <= 0 {
+		return fmt.Errorf("%w: the operation mix is empty", errInvalidLoadProfile)
+	}
+	return nil
+}
+
+// loadClient is the state of a simulated client. Each has its own random source, so that the clients don't contend for one
+type loadClient struct {
+	profile    LoadProfile
+	random     *rand.Rand
+	zipf       *rand.Zipf
+	operations []LoadOperation
+}
+
+func newLoadClient(profile LoadProfile, seed int64) *loadClient {
+	client := &loadClient{profile: profile, random: rand.New(rand.NewSource(seed))}
+	if profile.Distribution == KeyDistributionZipf && profile.KeyCount > 1 {
+		client.zipf = rand.NewZipf(client.random, 1.1, 1, uint64(profile.KeyCount-1))
+	}
+	operations := make([]LoadOperation, 0, len(profile.Mix))
+	for operation := range profile.Mix {
+		operations = append(operations, operation)
+	}
+	sort.Slice(operations, func(i, j int) bool { return operations[i] < operations[j] })
+	for _, operation := range operations {
+		for i := 0; i < profile.Mix[operation]; i++ {
+			client.operations = append(client.operations, operation)
+		}
+	}
+	return client
+}
+
+func (client *loadClient) nextOperation() LoadOperation {
+	return client.operations[client.random.Intn(len(client.operations))]
+}
+
+func (client *loadClient) nextKey() string {
+	index := 0
+	if client.zipf != nil {
+		index = int(client.zipf.Uint64())
+	} else {
+		index = client.random.Intn(client.profile.KeyCount)
+	}
+	return client.profile.KeyPrefix + strconv.Itoa(index)
+}
+
+func (client *loadClient) nextContent() []byte {
+	size := client.profile.MinBlobSize
+	if client.profile.MaxBlobSize > size {
+		size += client.random.Intn(client.profile.MaxBlobSize - size + 1)
+	}
+	content := make([]byte, size)
+	client.random.Read(content)
+	return content
+}
+
+func (client *loadClient) execute(ctx context.Context, store Store, operation LoadOperation) error {
+	switch operation {
+	case LoadOperationRead:
+		_, err := store.GetBlob(ctx, client.nextKey())
+		return err
+	case LoadOperationWrite:
+		return store.AddBlob(ctx, BlobInfo{Key: client.nextKey(), Content: client.nextContent(), ModifiedBy: client.profile.ModifiedBy})
+	case LoadOperationDelete:
+		return store.DeleteBlob(ctx, client.nextKey(), client.profile.ModifiedBy)
+	default:
+		_, err := store.ListBlobKeys(ctx, ListOptions{Prefix: client.profile.KeyPrefix})
+		return err
+	}
+}
+
+// RunLoad replays the workload described by the profile against the store and reports the throughput and the
+// latency percentiles of the operations. It returns early, with the report of what has been done, if ctx is done
+func RunLoad(ctx context.Context, store Store, profile LoadProfile) (LoadReport, error) {
+	if err := profile.validate(); err != nil {
+		return LoadReport{}, err
+	}
+	if profile.Concurrency < 1 {
+		profile.Concurrency = 1
+	}
+
+	if profile.Preload {
+		preloader := newLoadClient(profile, profile.Seed)
+		for index := 0; index < profile.KeyCount; index++ {
+			blob := BlobInfo{Key: profile.KeyPrefix + strconv.Itoa(index), Content: preloader.nextContent(), ModifiedBy: profile.ModifiedBy}
+			if err := store.AddBlob(ctx, blob); err != nil {
+				return LoadReport{}, fmt.Errorf("failed to preload %s: %w", blob.Key, err)
+			}
+		}
+	}
+
+	loadCtx := ctx
+	if profile.Duration > 0 {
+		var cancel context.CancelFunc
+		loadCtx, cancel = context.WithTimeout(ctx, profile.Duration)
+		defer cancel()
+	}
+
+	var ticker *time.Ticker
+	if profile.RatePerSecond > 0 {
+		ticker = time.NewTicker(time.Duration(float64(time.Second) / profile.RatePerSecond))
+		defer ticker.Stop()
+	}
+
+	var mutex sync.Mutex
+	issued := 0
+	samples := []loadSample{}
+	// claim reserves the next operation, waiting for the rate limiter if any
+	claim := func() bool {
+		if ticker != nil {
+			select {
+			case <-ticker.C:
+			case <-loadCtx.Done():
+				return false
+			}
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		if loadCtx.Err() != nil || (profile.Operations > 0 && issued >= profile.Operations) {
+			return false
+		}
+		issued++
+		return true
+	}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for clientIndex := 0; clientIndex < profile.Concurrency; clientIndex++ {
+		wg.Add(1)
+		go func(client *loadClient) {
+			defer wg.Done()
+			for claim() {
+				operation := client.nextOperation()
+				operationStart := time.Now()
+				err := client.execute(loadCtx, store, operation)
+				if err != nil && errors.Is(loadCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+					// The end of the run interrupted the operation: it didn't fail, it just didn't complete
+					continue
+				}
+				sample := loadSample{operation: operation, latency: time.Since(operationStart), err: err}
+				mutex.Lock()
+				samples = append(samples, sample)
+				mutex.Unlock()
+			}
+		}(newLoadClient(profile, profile.Seed+int64(clientIndex)+1))
+	}
+	wg.Wait()
+
+	return buildLoadReport(samples, time.Since(start)), nil
+}
+
+func percentile(sortedLatencies []time.Duration, p float64) time.Duration {
+	if len(sortedLatencies) == 0 {
+		return 0
+	}
+	index := int(float64(len(sortedLatencies)-1) * p)
+	return sortedLatencies[index]
+}
+
+func buildLoadReport(samples []loadSample, elapsed time.Duration) LoadReport {
+	report := LoadReport{Elapsed: elapsed, Operations: []LoadOperationReport{}}
+	latencies := map[LoadOperation][]time.Duration{}
+	reports := map[LoadOperation]*LoadOperationReport{}
+
+	for _, sample := range samples {
+		operationReport, found := reports[sample.operation]
+		if !found {
+			operationReport = &LoadOperationReport{Operation: sample.operation}
+			reports[sample.operation] = operationReport
+		}
+		operationReport.Count++
+		report.Total++
+		switch {
+		case errors.Is(sample.err, ErrBlobNotFound):
+			operationReport.Misses++
+		case sample.err != nil:
+			operationReport.Errors++
+			report.Errors++
+		}
+		latencies[sample.operation] = append(latencies[sample.operation], sample.latency)
+	}
+
+	for operation, operationReport := range reports {
+		sorted := latencies[operation]
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		operationReport.P50 = percentile(sorted, 0.5)
+		operationReport.P90 = percentile(sorted, 0.9)
+		operationReport.P99 = percentile(sorted, 0.99)
+		operationReport.Max = sorted[len(sorted)-1]
+		if elapsed > 0 {
+			operationReport.Throughput = float64(operationReport.Count) / elapsed.Seconds()
+		}
+		report.Operations = append(report.Operations, *operationReport)
+	}
+	sort.Slice(report.Operations, func(i, j int) bool {
+		return report.Operations[i].Operation < report.Operations[j].Operation
+	})
+	if elapsed > 0 {
+		report.Throughput = float64(report.Total) / elapsed.Seconds()
+	}
+	return report
+}
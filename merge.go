@@ -0,0 +1,166 @@
+package vcblobstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// MergeStrategy tells how a branch is merged into another one
+type MergeStrategy string
+
+const (
+	// MergeAuto fast-forwards the target branch if it can, and makes a merge commit otherwise
+	MergeAuto MergeStrategy = ""
+	// MergeFastForward only fast-forwards the target branch and fails with ErrNotFastForward if it can't
+	MergeFastForward MergeStrategy = "fast-forward"
+	// MergeCommit makes a merge commit even if the target branch could be fast-forwarded
+	MergeCommit MergeStrategy = "merge-commit"
+)
+
+// ErrNotFastForward signals that the branches diverged, so the target branch can't be fast-forwarded
+var ErrNotFastForward = fmt.Errorf("%w: not a fast-forward", ErrConflict)
+
+// MergeConflictError is an ErrConflict listing the keys of the blobs changed differently on the merged branches
+type MergeConflictError struct {
+	From string
+	To   string
+	Keys []string
+}
+
+func (err *MergeConflictError) Error() string {
+	return fmt.Sprintf("%s: merging %s into %s conflicts on %s", ErrConflict, err.From, err.To, strings.Join(err.Keys, ", "))
+}
+
+func (err *MergeConflictError) Unwrap() error {
+	return ErrConflict
+}
+
+// BranchMerger is implemented by the backends able to merge their branches, e.g. to promote the blobs staged on a
+// branch to the production one
+type BranchMerger interface {
+	// MergeBranch merges the branch from into the branch to as the strategy selected with WithMergeStrategy says.
+	// Merging a branch already merged is a no-op
+	MergeBranch(ctx context.Context, from string, to string, modifiedBy string) error
+}
+
+type mergeStrategyKey struct{}
+
+// WithMergeStrategy selects the strategy of the merges made with the returned context (MergeAuto by default)
+func WithMergeStrategy(ctx context.Context, strategy MergeStrategy) context.Context {
+	return context.WithValue(ctx, mergeStrategyKey{}, strategy)
+}
+
+// MergeStrategyOf returns the strategy selected with WithMergeStrategy
+func MergeStrategyOf(ctx context.Context) MergeStrategy {
+	strategy, _ := ctx.Value(mergeStrategyKey{}).(MergeStrategy)
+	return strategy
+}
+
+// IsDerivedEntry tells whether the key is that of an entry every write rewrites as a whole, e.g. the attribute index.
+// Merging branches, such entries are merged by their meaning rather than line by line
+func IsDerivedEntry(naming NamingStrategy, key string) bool {
+	return key == naming.AttributeIndexKey() || key == naming.StoreMetadataKey()
+}
+
+// MergeDerivedEntry returns the content of the derived entry merged from its content as of the merge base and on both
+// branches, nil meaning the entry is missing. It fails with ErrConflict if the entry changed differently on both
+// branches in a way that can't be merged, e.g. the same store metadata attribute set to different values
+func MergeDerivedEntry(naming NamingStrategy, key string, base []byte, ours []byte, theirs []byte) ([]byte, error) {
+	switch {
+	case bytes.Equal(ours, theirs) || bytes.Equal(base, theirs):
+		return ours, nil
+	case bytes.Equal(base, ours):
+		return theirs, nil
+	case key == naming.AttributeIndexKey():
+		indexes := []AttributeIndex{}
+		for _, content := range [][]byte{base, ours, theirs} {
+			index := AttributeIndex{}
+			if content != nil {
+				var decodeErr error
+				if index, decodeErr = DecodeAttributeIndex(content); decodeErr != nil {
+					return nil, decodeErr
+				}
+			}
+			indexes = append(indexes, index)
+		}
+		return MergeAttributeIndexes(indexes[0], indexes[1], indexes[2]).Encode()
+	case key == naming.StoreMetadataKey():
+		return mergeStoreMetadata(base, ours, theirs)
+	default:
+		return nil, fmt.Errorf("%w: %s is no derived entry", ErrConflict, key)
+	}
+}
+
+// MergeAttributeIndexes returns the attribute index of the merge of two branches: the labels of the keys added or
+// removed on either branch since their merge base are added or removed
+func MergeAttributeIndexes(base AttributeIndex, ours AttributeIndex, theirs AttributeIndex) AttributeIndex {
+	has := func(index AttributeIndex, label string, key string) bool {
+		_, found := slices.BinarySearch(index[label], key)
+		return found
+	}
+	merged := AttributeIndex{}
+	for _, index := range []AttributeIndex{ours, theirs} {
+		for label, keys := range index {
+			for _, key := range keys {
+				kept := has(ours, label, key) && has(theirs, label, key)
+				if !kept && has(base, label, key) {
+					continue
+				}
+				position, found := slices.BinarySearch(merged[label], key)
+				if !found {
+					merged[label] = slices.Insert(merged[label], position, key)
+				}
+			}
+		}
+	}
+	return merged
+}
+
+// mergeStoreMetadata merges the store metadata documents attribute by attribute
+func mergeStoreMetadata(base []byte, ours []byte, theirs []byte) ([]byte, error) {
+	documents := []map[string]json.RawMessage{}
+	for _, content := range [][]byte{base, ours, theirs} {
+		document := map[string]json.RawMessage{}
+		if content != nil {
+			if err := json.Unmarshal(content, &document); err != nil {
+				return nil, fmt.Errorf("failed to decode store metadata: %w", err)
+			}
+		}
+		documents = append(documents, document)
+	}
+	equal := func(value json.RawMessage, other json.RawMessage) bool {
+		var compacted, otherCompacted bytes.Buffer
+		if json.Compact(&compacted, value) != nil || json.Compact(&otherCompacted, other) != nil {
+			return bytes.Equal(value, other)
+		}
+		return bytes.Equal(compacted.Bytes(), otherCompacted.Bytes())
+	}
+
+	merged := map[string]json.RawMessage{}
+	for _, document := range documents[1:] {
+		for name := range document {
+			baseValue, ourValue, theirValue := documents[0][name], documents[1][name], documents[2][name]
+			switch {
+			case equal(ourValue, theirValue) || equal(baseValue, theirValue):
+				if ourValue != nil {
+					merged[name] = ourValue
+				}
+			case equal(baseValue, ourValue):
+				if theirValue != nil {
+					merged[name] = theirValue
+				}
+			default:
+				return nil, fmt.Errorf("%w: the store metadata attribute %s changed differently on both branches", ErrConflict, name)
+			}
+		}
+	}
+	content, marshalErr := json.Marshal(merged)
+	if marshalErr != nil {
+		return nil, fmt.Errorf("failed to encode store metadata: %w", marshalErr)
+	}
+	return content, nil
+}
@@ -0,0 +1,30 @@
+package vcblobstore
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// MetadataKeyPrefix is the prefix of the keys of the sidecar entries holding the metadata attributes of the blobs
+const MetadataKeyPrefix = InternalKeyPrefix + "metadata/"
+
+// MetadataSidecarKey returns the key of the sidecar entry holding the metadata attributes of the blob
+func MetadataSidecarKey(key string) string {
+	return MetadataKeyPrefix + key
+}
+
+func EncodeMetadata(metadata map[string]string) ([]byte, error) {
+	content, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode blob metadata: %w", err)
+	}
+	return content, nil
+}
+
+func DecodeMetadata(content []byte) (map[string]string, error) {
+	metadata := map[string]string{}
+	if err := json.Unmarshal(content, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to decode blob metadata: %w", err)
+	}
+	return metadata, nil
+}
@@ -0,0 +1,208 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+	"vcblobstore"
+
+	"github.com/rs/zerolog"
+)
+
+// SecondaryFailureMode decides the outcome of writes which succeed in the primary store, but fail in the secondary one
+type SecondaryFailureMode string
+
+const (
+	// BestEffort lets the write succeed and records the divergence
+	BestEffort SecondaryFailureMode = "best-effort"
+	// Strict fails the write (the primary store keeping the change) and records the divergence
+	Strict SecondaryFailureMode = "strict"
+)
+
+var ErrDiverged = errors.New("mirror diverged")
+
+// Divergence describes a key whose state differs, or may differ, between the two stores
+type Divergence struct {
+	Key       string
+	Operation string
+	// Reason is the error of the secondary write or, for the divergences found by Compare, what differs
+	Reason string
+	At     time.Time
+}
+
+// Store is a Store decorator applying every write to the primary store, then to the secondary one, e.g. local
+// git and GitLab. The reads are served by the primary store. The writes failing in the secondary store are
+// recorded as divergences until a later write of the key succeeds in both
+type Store struct {
+	vcblobstore.Store
+	secondary vcblobstore.Store
+	mode      SecondaryFailureMode
+
+	mutex       sync.Mutex
+	divergences map[string]Divergence
+}
+
+var _ vcblobstore.Store = (*Store)(nil)
+
+func New(primary vcblobstore.Store, secondary vcblobstore.Store, mode SecondaryFailureMode) *Store {
+	return &Store{
+		Store:       primary,
+		secondary:   secondary,
+		mode:        mode,
+		divergences: map[string]Divergence{},
+	}
+}
+
+func (store *Store) String() string {
+	return fmt.Sprintf("mirror(%s, %s)", store.Store.String(), store.secondary.String())
+}
+
+// Divergences returns the divergences recorded since the creation of the store, ordered by key
+func (store *Store) Divergences() []Divergence {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	divergences := make([]Divergence, 0, len(store.divergences))
+	for _, divergence := range store.divergences {
+		divergences = append(divergences, divergence)
+	}
+	sort.Slice(divergences, func(i, j int) bool { return divergences[i].Key < divergences[j].Key })
+	return divergences
+}
+
+// mirror applies the write already applied to the primary store (with primaryErr) to the secondary store
+func (store *Store) mirror(ctx context.Context, operation string, keys []string, primaryErr error, write func(target vcblobstore.Store) error) error {
+	if primaryErr != nil {
+		return primaryErr
+	}
+
+	secondaryErr := write(store.secondary)
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if secondaryErr == nil {
+		for _, key := range keys {
+			delete(store.divergences, key)
+		}
+		return nil
+	}
+
+	now := time.Now()
+	for _, key := range keys {
+		store.divergences[key] = Divergence{Key: key, Operation: operation, Reason: secondaryErr.Error(), At: now}
+	}
+	zerolog.Ctx(ctx).Warn().Err(secondaryErr).Str("operation", operation).Strs("keys", keys).Msg("mirror diverged")
+	if store.mode == Strict {
+		return fmt.Errorf("failed to mirror %s of %v: %w: %w", operation, keys, ErrDiverged, secondaryErr)
+	}
+	return nil
+}
+
+func (store *Store) CreateRepository(ctx context.Context) error {
+	return store.mirror(ctx, "CreateRepository", nil, store.Store.CreateRepository(ctx), func(target vcblobstore.Store) error {
+		return target.CreateRepository(ctx)
+	})
+}
+
+func (store *Store) AddBlob(ctx context.Context, blob vcblobstore.BlobInfo) error {
+	return store.mirror(ctx, "AddBlob", []string{blob.Key}, store.Store.AddBlob(ctx, blob), func(target vcblobstore.Store) error {
+		return target.AddBlob(ctx, blob)
+	})
+}
+
+func (store *Store) UpdateBlobMetadata(ctx context.Context, key string, metadata map[string]string, modifiedBy string) error {
+	return store.mirror(ctx, "UpdateBlobMetadata", []string{key}, store.Store.UpdateBlobMetadata(ctx, key, metadata, modifiedBy), func(target vcblobstore.Store) error {
+		return target.UpdateBlobMetadata(ctx, key, metadata, modifiedBy)
+	})
+}
+
+func (store *Store) DeleteBlob(ctx context.Context, key string, modifiedBy string) error {
+	return store.mirror(ctx, "DeleteBlob", []string{key}, store.Store.DeleteBlob(ctx, key, modifiedBy), func(target vcblobstore.Store) error {
+		return target.DeleteBlob(ctx, key, modifiedBy)
+	})
+}
+
+func (store *Store) DeleteBlobs(ctx context.Context, keys []string, modifiedBy string) error {
+	return store.mirror(ctx, "DeleteBlobs", keys, store.Store.DeleteBlobs(ctx, keys, modifiedBy), func(target vcblobstore.Store) error {
+		return target.DeleteBlobs(ctx, keys, modifiedBy)
+	})
+}
+
+func (store *Store) CopyBlob(ctx context.Context, sourceKey string, destinationKey string, modifiedBy string) error {
+	return store.mirror(ctx, "CopyBlob", []string{destinationKey}, store.Store.CopyBlob(ctx, sourceKey, destinationKey, modifiedBy), func(target vcblobstore.Store) error {
+		return target.CopyBlob(ctx, sourceKey, destinationKey, modifiedBy)
+	})
+}
+
+func (store *Store) RenameBlob(ctx context.Context, oldKey string, newKey string, modifiedBy string) error {
+	return store.mirror(ctx, "RenameBlob", []string{oldKey, newKey}, store.Store.RenameBlob(ctx, oldKey, newKey, modifiedBy), func(target vcblobstore.Store) error {
+		return target.RenameBlob(ctx, oldKey, newKey, modifiedBy)
+	})
+}
+
+// RestoreBlob restores the version of the primary store. As the versions of the two stores differ, the restored
+// blob is written to the secondary store as a new version
+func (store *Store) RestoreBlob(ctx context.Context, key string, commitId string, modifiedBy string) error {
+	return store.mirror(ctx, "RestoreBlob", []string{key}, store.Store.RestoreBlob(ctx, key, commitId, modifiedBy), func(target vcblobstore.Store) error {
+		blob, getErr := store.Store.GetBlobInfo(ctx, key)
+		if getErr != nil {
+			return fmt.Errorf("failed to read restored blob %s: %w", key, getErr)
+		}
+		blob.ModifiedBy = modifiedBy
+		return target.AddBlob(ctx, blob)
+	})
+}
+
+func (store *Store) CreateAlias(ctx context.Context, alias string, target string, modifiedBy string) error {
+	return store.mirror(ctx, "CreateAlias", []string{alias}, store.Store.CreateAlias(ctx, alias, target, modifiedBy), func(secondary vcblobstore.Store) error {
+		return secondary.CreateAlias(ctx, alias, target, modifiedBy)
+	})
+}
+
+// Compare checks the keys matching opts in both stores and returns those missing from either store
+// or differing in content, ordered by key
+func (store *Store) Compare(ctx context.Context, opts vcblobstore.ListOptions) ([]Divergence, error) {
+	primaryKeys, primaryErr := store.Store.ListBlobKeys(ctx, opts)
+	if primaryErr != nil {
+		return nil, fmt.Errorf("failed to list keys of primary store: %w", primaryErr)
+	}
+	secondaryKeys, secondaryErr := store.secondary.ListBlobKeys(ctx, opts)
+	if secondaryErr != nil {
+		return nil, fmt.Errorf("failed to list keys of secondary store: %w", secondaryErr)
+	}
+
+	inSecondary := map[string]bool{}
+	for _, key := range secondaryKeys {
+		inSecondary[key] = true
+	}
+
+	now := time.Now()
+	divergences := []Divergence{}
+	for _, key := range primaryKeys {
+		if !inSecondary[key] {
+			divergences = append(divergences, Divergence{Key: key, Operation: "Compare", Reason: "missing from secondary store", At: now})
+			continue
+		}
+		delete(inSecondary, key)
+
+		_, primaryChecksum, primaryGetErr := store.Store.GetBlobWithChecksum(ctx, key)
+		if primaryGetErr != nil {
+			return nil, fmt.Errorf("failed to read %s from primary store: %w", key, primaryGetErr)
+		}
+		_, secondaryChecksum, secondaryGetErr := store.secondary.GetBlobWithChecksum(ctx, key)
+		if secondaryGetErr != nil {
+			return nil, fmt.Errorf("failed to read %s from secondary store: %w", key, secondaryGetErr)
+		}
+		if primaryChecksum != secondaryChecksum {
+			divergences = append(divergences, Divergence{Key: key, Operation: "Compare", Reason: "content differs", At: now})
+		}
+	}
+	for key := range inSecondary {
+		divergences = append(divergences, Divergence{Key: key, Operation: "Compare", Reason: "missing from primary store", At: now})
+	}
+
+	sort.Slice(divergences, func(i, j int) bool { return divergences[i].Key < divergences[j].Key })
+	return divergences, nil
+}
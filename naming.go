@@ -0,0 +1,67 @@
+package vcblobstore
+
+import "strings"
+
+// NamingStrategy decides the keys of the entries the store derives from the keys of the blobs (metadata sidecars,
+// alias pointers, etc.), so that deployments with their own key conventions can keep these clear of their blobs
+type NamingStrategy interface {
+	// IsInternalKey tells whether the key belongs to an entry of the store rather than to a blob.
+	// Internal keys are left out of the key listings
+	IsInternalKey(key string) bool
+	MetadataSidecarKey(key string) string
+	// AliasDirectory is the directory (with trailing slash) holding the pointer entries of the aliases
+	AliasDirectory() string
+	AliasPointerKey(alias string) string
+	AliasFromPointerKey(pointerKey string) string
+	AttributeIndexKey() string
+	ProbeKey(id string) string
+	StoreMetadataKey() string
+}
+
+// PrefixNaming keeps every derived entry under the Root prefix (which is expected to end with a slash)
+type PrefixNaming struct {
+	Root string
+}
+
+// DefaultNaming keeps the derived entries under InternalKeyPrefix
+var DefaultNaming NamingStrategy = PrefixNaming{Root: InternalKeyPrefix}
+
+// NamingOrDefault returns DefaultNaming for a nil strategy, so that the backends can leave the strategy unconfigured
+func NamingOrDefault(naming NamingStrategy) NamingStrategy {
+	if naming == nil {
+		return DefaultNaming
+	}
+	return naming
+}
+
+func (naming PrefixNaming) IsInternalKey(key string) bool {
+	return strings.HasPrefix(key, naming.Root)
+}
+
+func (naming PrefixNaming) MetadataSidecarKey(key string) string {
+	return naming.Root + "metadata/" + key
+}
+
+func (naming PrefixNaming) AliasDirectory() string {
+	return naming.Root + "aliases/"
+}
+
+func (naming PrefixNaming) AliasPointerKey(alias string) string {
+	return naming.AliasDirectory() + alias
+}
+
+func (naming PrefixNaming) AliasFromPointerKey(pointerKey string) string {
+	return strings.TrimPrefix(pointerKey, naming.AliasDirectory())
+}
+
+func (naming PrefixNaming) AttributeIndexKey() string {
+	return naming.Root + "attribute-index.json"
+}
+
+func (naming PrefixNaming) ProbeKey(id string) string {
+	return naming.Root + "probe/" + id
+}
+
+func (naming PrefixNaming) StoreMetadataKey() string {
+	return naming.Root + "store.json"
+}
@@ -0,0 +1,201 @@
+package vcblobstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// RedactionMask replaces the sensitive values in the content served to unprivileged readers
+const RedactionMask = "***"
+
+// RedactionRule selects the sensitive values in the blobs whose key matches KeyPattern (a path.Match
+// pattern, empty matching every key). JSONPaths are dot separated field paths into JSON content with "*"
+// matching any field or array element (e.g. "users.*.password"); every match of Patterns is masked in any content
+type RedactionRule struct {
+	KeyPattern string
+	JSONPaths  []string
+	Patterns   []*regexp.Regexp
+}
+
+func (rule RedactionRule) matches(key string) bool {
+	if len(rule.KeyPattern) == 0 {
+		return true
+	}
+	matched, err := path.Match(rule.KeyPattern, key)
+	return err == nil && matched
+}
+
+// Authorizer tells whether the principal making the call (as carried by ctx) has the permission
+type Authorizer func(ctx context.Context, permission string) bool
+
+// Redactor is a Store decorator masking the sensitive values in the content read by principals lacking
+// the permission. Content the JSON rules can't be applied to isn't served at all rather than unredacted
+type Redactor struct {
+	Store
+	authorize  Authorizer
+	permission string
+	rules      []RedactionRule
+}
+
+// NewRedactor creates the decorator. With a nil authorize every reader is considered unprivileged
+func NewRedactor(store Store, authorize Authorizer, permission string, rules ...RedactionRule) *Redactor {
+	return &Redactor{
+		Store:      store,
+		authorize:  authorize,
+		permission: permission,
+		rules:      rules,
+	}
+}
+
+func (redactor *Redactor) privileged(ctx context.Context) bool {
+	return redactor.authorize != nil && redactor.authorize(ctx, redactor.permission)
+}
+
+// Redact masks the sensitive values of the content of the blob regardless of the principal
+func (redactor *Redactor) Redact(key string, content []byte) ([]byte, error) {
+	for _, rule := range redactor.rules {
+		if !rule.matches(key) {
+			continue
+		}
+		if len(rule.JSONPaths) > 0 {
+			redacted, err := redactJSON(content, rule.JSONPaths)
+			if err != nil {
+				return nil, fmt.Errorf("failed to redact %s: %w", key, err)
+			}
+			content = redacted
+		}
+		for _, pattern := range rule.Patterns {
+			content = pattern.ReplaceAll(content, []byte(RedactionMask))
+		}
+	}
+	return content, nil
+}
+
+func redactJSON(content []byte, paths []string) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON content: %w", err)
+	}
+	for _, jsonPath := range paths {
+		document = maskJSONPath(document, strings.Split(jsonPath, "."))
+	}
+	redacted, err := json.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal redacted JSON content: %w", err)
+	}
+	return redacted, nil
+}
+
+func maskJSONPath(node interface{}, segments []string) interface{} {
+	if len(segments) == 0 {
+		return RedactionMask
+	}
+	segment, rest := segments[0], segments[1:]
+	switch typed := node.(type) {
+	case map[string]interface{}:
+		for field, value := range typed {
+			if segment == "*" || segment == field {
+				typed[field] = maskJSONPath(value, rest)
+			}
+		}
+	case []interface{}:
+		for index, value := range typed {
+			if segment == "*" || segment == fmt.Sprint(index) {
+				typed[index] = maskJSONPath(value, rest)
+			}
+		}
+	}
+	return node
+}
+
+func (redactor *Redactor) redactFor(ctx context.Context, key string, content []byte) ([]byte, error) {
+	if redactor.privileged(ctx) {
+		return content, nil
+	}
+	return redactor.Redact(key, content)
+}
+
+func (redactor *Redactor) GetBlob(ctx context.Context, key string) ([]byte, error) {
+	content, err := redactor.Store.GetBlob(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return redactor.redactFor(ctx, key, content)
+}
+
+func (redactor *Redactor) GetBlobInfo(ctx context.Context, key string) (BlobInfo, error) {
+	blob, err := redactor.Store.GetBlobInfo(ctx, key)
+	if err != nil || redactor.privileged(ctx) {
+		return blob, err
+	}
+	redacted, redactErr := redactor.Redact(key, blob.Content)
+	if redactErr != nil {
+		return BlobInfo{}, redactErr
+	}
+	blob.Content = redacted
+	blob.SHA256 = ContentSHA256(redacted)
+	return blob, nil
+}
+
+// GetBlobWithChecksum returns the checksum of the content as served, so that it verifies the redacted content
+func (redactor *Redactor) GetBlobWithChecksum(ctx context.Context, key string) ([]byte, string, error) {
+	content, checksum, err := redactor.Store.GetBlobWithChecksum(ctx, key)
+	if err != nil || redactor.privileged(ctx) {
+		return content, checksum, err
+	}
+	redacted, redactErr := redactor.Redact(key, content)
+	if redactErr != nil {
+		return nil, "", redactErr
+	}
+	return redacted, ContentSHA256(redacted), nil
+}
+
+func (redactor *Redactor) GetBlobAtVersion(ctx context.Context, key string, commitId string) ([]byte, error) {
+	content, err := redactor.Store.GetBlobAtVersion(ctx, key, commitId)
+	if err != nil {
+		return nil, err
+	}
+	return redactor.redactFor(ctx, key, content)
+}
+
+// ExportHistory writes the history bundle of the wrapped store (which has to be an Admin) with the content of every
+// version redacted for unprivileged readers
+func (redactor *Redactor) ExportHistory(ctx context.Context, keys []string, w io.Writer) error {
+	admin, ok := redactor.Store.(Admin)
+	if !ok {
+		return fmt.Errorf("store %s can't export history: %w", redactor.Store, errors.ErrUnsupported)
+	}
+	if redactor.privileged(ctx) {
+		return admin.ExportHistory(ctx, keys, w)
+	}
+
+	bundle := bytes.Buffer{}
+	if exportErr := admin.ExportHistory(ctx, keys, &bundle); exportErr != nil {
+		return exportErr
+	}
+	records := []HistoryRecord{}
+	readErr := ReadHistory(&bundle, func(record HistoryRecord) error {
+		if !record.Deleted {
+			redacted, redactErr := redactor.Redact(record.Key, record.Content)
+			if redactErr != nil {
+				return redactErr
+			}
+			record.Content = redacted
+		}
+		records = append(records, record)
+		return nil
+	})
+	if readErr != nil {
+		return readErr
+	}
+	return WriteHistory(w, records)
+}
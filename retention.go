@@ -0,0 +1,61 @@
+package vcblobstore
+
+import (
+	"context"
+	"time"
+)
+
+// RetentionPolicy tells which versions to keep when the history is pruned: the last KeepVersions versions of every
+// blob and the versions newer than KeepFor, whichever is set. The versions older than the oldest one to keep are
+// squashed into a single version holding the state they led to, so the current content of every blob is kept.
+// The zero policy keeps every version
+type RetentionPolicy struct {
+	KeepVersions int
+	KeepFor      time.Duration
+}
+
+// Cutoff returns the index of the oldest of the versions (oldest first) the policy keeps as of now
+func (policy RetentionPolicy) Cutoff(versions []RepositoryVersion, now time.Time) int {
+	if policy.KeepVersions <= 0 && policy.KeepFor <= 0 {
+		return 0
+	}
+	cutoff := len(versions)
+	if policy.KeepFor > 0 {
+		for index := len(versions) - 1; index >= 0 && versions[index].AuthorDate.After(now.Add(-policy.KeepFor)); index-- {
+			cutoff = index
+		}
+	}
+	if policy.KeepVersions > 0 {
+		kept := map[string]int{}
+		for index := len(versions) - 1; index >= 0; index-- {
+			for _, change := range versions[index].Changes {
+				if kept[change.Key] < policy.KeepVersions {
+					kept[change.Key]++
+					cutoff = min(cutoff, index)
+				}
+			}
+		}
+	}
+	return cutoff
+}
+
+// PruneReport tells the outcome of pruning the history: the number of versions squashed into one and the number of
+// versions kept as they were, and the new state of the repository
+type PruneReport struct {
+	Squashed int
+	Kept     int
+	StateID  string
+}
+
+// HistoryPruner is implemented by the backends able to rewrite their history to drop the versions a retention
+// policy doesn't keep. Pruning changes the IDs of the versions kept, so the state IDs recorded earlier become invalid.
+//
+// The GitLab backend isn't one: the REST API of GitLab can't rewrite the history of a branch. A GitLab repository
+// is pruned by squashing it out of band: prune a clone of it with the local backend (configured without a remote)
+// and force-push the result to the main branch, with its protection lifted for the time of the push
+type HistoryPruner interface {
+	PruneHistory(ctx context.Context, policy RetentionPolicy) (PruneReport, error)
+	// CompactHistory squashes the versions older than the state into a single baseline version, keeping the
+	// clones small and the history queries fast for long-lived stores
+	CompactHistory(ctx context.Context, beforeStateID string) (PruneReport, error)
+}
@@ -0,0 +1,10 @@
+package vcblobstore
+
+import "context"
+
+// StateReverter is implemented by the backends able to bring every blob back to an earlier state at once
+type StateReverter interface {
+	// RevertToState makes a new version whose blobs (with their metadata) are those of the state or tag. The history
+	// is kept: the versions made since the state stay readable. Reverting to the current state is a no-op
+	RevertToState(ctx context.Context, stateID string, modifiedBy string) error
+}
@@ -0,0 +1,79 @@
+package vcblobstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ProbeKeyPrefix is the prefix of the keys of the blobs written by the self-test under DefaultNaming
+const ProbeKeyPrefix = InternalKeyPrefix + "probe/"
+
+// SelfTestTarget is the set of operations exercised by the self-test
+type SelfTestTarget interface {
+	AddBlob(ctx context.Context, blob BlobInfo) error
+	GetBlob(ctx context.Context, key string) ([]byte, error)
+	DeleteBlob(ctx context.Context, key string, modifiedBy string) error
+}
+
+type SelfTestStep struct {
+	Operation string
+	Duration  time.Duration
+	Err       error
+}
+
+// SelfTestReport is the diagnosis produced by the self-test
+type SelfTestReport struct {
+	ProbeKey string
+	Steps    []SelfTestStep
+	Healthy  bool
+}
+
+// RunSelfTest writes, reads back and deletes a probe blob keyed according to naming, measuring the latency of each step.
+// The steps after the first failing one are skipped
+func RunSelfTest(ctx context.Context, target SelfTestTarget, naming NamingStrategy, modifiedBy string) SelfTestReport {
+	probeKey := naming.ProbeKey(strconv.FormatInt(time.Now().UnixNano(), 10))
+	probeContent := []byte(fmt.Sprintf("vcblobstore self-test probe %s", probeKey))
+
+	report := SelfTestReport{ProbeKey: probeKey, Healthy: true}
+
+	steps := []struct {
+		operation string
+		run       func() error
+	}{
+		{"write", func() error {
+			return target.AddBlob(ctx, BlobInfo{Key: probeKey, Content: probeContent, ModifiedBy: modifiedBy})
+		}},
+		{"read", func() error {
+			content, err := target.GetBlob(ctx, probeKey)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(content, probeContent) {
+				return fmt.Errorf("probe content read back differs from the one written")
+			}
+			return nil
+		}},
+		{"delete", func() error {
+			return target.DeleteBlob(ctx, probeKey, modifiedBy)
+		}},
+	}
+
+	for _, step := range steps {
+		start := time.Now()
+		err := step.run()
+		report.Steps = append(report.Steps, SelfTestStep{
+			Operation: step.operation,
+			Duration:  time.Since(start),
+			Err:       err,
+		})
+		if err != nil {
+			report.Healthy = false
+			break
+		}
+	}
+
+	return report
+}
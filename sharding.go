@@ -0,0 +1,76 @@
+package vcblobstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+const defaultShardWidth = 2
+
+// KeySharding spreads the blobs of a flat key space over hash prefix directories, e.g. "icon" is stored as
+// "3f/a1/icon" with two levels of two hex digits, which keeps the directories small enough for git to handle
+// efficiently. The backends translate between keys and paths, so the callers keep using the plain keys.
+// The internal entries of the store are not sharded. A nil *KeySharding (or zero Levels) leaves the keys as they are
+type KeySharding struct {
+	Levels int
+	// Width is the number of hex digits of the hash naming the directories of a level (2 if unset)
+	Width int
+}
+
+func (sharding *KeySharding) enabled() bool {
+	return sharding != nil && sharding.Levels > 0
+}
+
+func (sharding *KeySharding) width() int {
+	if sharding.Width <= 0 {
+		return defaultShardWidth
+	}
+	return sharding.Width
+}
+
+func (sharding *KeySharding) shardPrefix(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	hexHash := hex.EncodeToString(hash[:])
+	width := sharding.width()
+	prefix := strings.Builder{}
+	for level := 0; level < sharding.Levels && (level+1)*width <= len(hexHash); level++ {
+		prefix.WriteString(hexHash[level*width : (level+1)*width])
+		prefix.WriteString("/")
+	}
+	return prefix.String()
+}
+
+// PathOf returns the path in the repository of the entry with the key
+func (sharding *KeySharding) PathOf(naming NamingStrategy, key string) string {
+	if !sharding.enabled() || naming.IsInternalKey(key) {
+		return key
+	}
+	return sharding.shardPrefix(key) + key
+}
+
+// KeyOf returns the key of the entry at the path in the repository and false in case the path
+// is not where PathOf would put the entry (e.g. it is still in the flat layout)
+func (sharding *KeySharding) KeyOf(naming NamingStrategy, path string) (string, bool) {
+	if !sharding.enabled() || naming.IsInternalKey(path) {
+		return path, true
+	}
+	segments := strings.SplitN(path, "/", sharding.Levels+1)
+	if len(segments) <= sharding.Levels {
+		return "", false
+	}
+	key := segments[sharding.Levels]
+	if sharding.shardPrefix(key)+key != path {
+		return "", false
+	}
+	return key, true
+}
+
+// ListingDirectory returns the directory of the repository to list for the keys in directory. As the shard
+// directories come first in the paths, only the directories of internal keys can be narrowed down
+func (sharding *KeySharding) ListingDirectory(naming NamingStrategy, directory string) string {
+	if !sharding.enabled() || naming.IsInternalKey(directory) || naming.IsInternalKey(directory+"/") {
+		return directory
+	}
+	return ""
+}
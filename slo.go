@@ -0,0 +1,283 @@
+package vcblobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrInvalidObjective is returned for an SLO objective which can't be tracked
+var ErrInvalidObjective = errors.New("invalid SLO objective")
+
+// SLOObjective is the service level objective of an operation of the store, e.g. 99% of the GetBlob calls
+// succeed in less than 500ms. A call is bad if it fails or, with LatencyThreshold set, if it takes longer than that
+type SLOObjective struct {
+	Operation        string
+	Target           float64
+	LatencyThreshold time.Duration
+	// Window is the sliding window of the calls the objective is evaluated on, e.g. 28 days. With no window, every
+	// call since the tracking started (or was reset) counts
+	Window time.Duration
+}
+
+// SLOStatus is the state of an objective based on the calls observed in its window
+type SLOStatus struct {
+	Objective SLOObjective
+	Total     int64
+	Failures  int64
+	SlowCalls int64
+	// SuccessRatio is the ratio of the good calls (1 if there were no calls at all)
+	SuccessRatio float64
+	// BurnRate is the pace at which the error budget is consumed: 1 uses up exactly the budget, above 1 exhausts it early
+	BurnRate float64
+	Healthy  bool
+}
+
+type sloCounters struct {
+	total     int64
+	failures  int64
+	slowCalls int64
+}
+
+// sloWindowBuckets is the number of buckets the window of an objective is divided into: the calls expire from the
+// window a bucket at a time
+const sloWindowBuckets = 60
+
+type sloBucket struct {
+	start time.Time
+	sloCounters
+}
+
+// sloWindow counts the calls of an operation in buckets of consecutive periods, oldest first
+type sloWindow struct {
+	buckets []sloBucket
+}
+
+// current returns the counters of the bucket the call made at now falls into
+func (window *sloWindow) current(now time.Time, objective SLOObjective) *sloCounters {
+	last := len(window.buckets) - 1
+	if last < 0 || (objective.Window > 0 && now.Sub(window.buckets[last].start) >= objective.Window/sloWindowBuckets) {
+		window.buckets = append(window.buckets, sloBucket{start: now})
+		last++
+	}
+	return &window.buckets[last].sloCounters
+}
+
+// sum drops the buckets which slid out of the window by now and sums the rest
+func (window *sloWindow) sum(now time.Time, objective SLOObjective) sloCounters {
+	if objective.Window > 0 {
+		expired := 0
+		for expired < len(window.buckets) && now.Sub(window.buckets[expired].start) >= objective.Window {
+			expired++
+		}
+		window.buckets = window.buckets[expired:]
+	}
+	sum := sloCounters{}
+	for _, bucket := range window.buckets {
+		sum.total += bucket.total
+		sum.failures += bucket.failures
+		sum.slowCalls += bucket.slowCalls
+	}
+	return sum
+}
+
+// sloOperations are the operations the SLOTracker observes
+var sloOperations = map[string]bool{
+	"GetBlob": true, "GetBlobInfo": true, "GetBlobWithChecksum": true, "GetBlobAtVersion": true, "QueryByAttributes": true,
+	"HeadBlob": true, "GetTree": true, "AddBlob": true, "UpdateBlobMetadata": true, "DeleteBlob": true, "DeleteBlobs": true,
+	"ListBlobKeys": true, "CopyBlob": true, "RenameBlob": true, "RestoreBlob": true, "CreateAlias": true,
+	"ResolveAlias": true, "ListAliases": true,
+}
+
+// SLOTracker is a Store decorator classifying the calls of the tracked operations against their objectives.
+// Errors which are no failure of the store according to ClassifyFailure (e.g. ErrBlobNotFound or a cancelled
+// context) don't consume the error budget
+type SLOTracker struct {
+	Store
+	objectives map[string]SLOObjective
+	mutex      sync.Mutex
+	windows    map[string]*sloWindow
+}
+
+// NewSLOTracker returns the tracker of the objectives, each of which must be of a distinct operation the tracker
+// observes, with a target in (0, 1]
+func NewSLOTracker(store Store, objectives ...SLOObjective) (*SLOTracker, error) {
+	tracker := &SLOTracker{
+		Store:      store,
+		objectives: map[string]SLOObjective{},
+		windows:    map[string]*sloWindow{},
+	}
+	for _, objective := range objectives {
+		if !sloOperations[objective.Operation] {
+			return nil, fmt.Errorf("operation %q isn't tracked: %w", objective.Operation, ErrInvalidObjective)
+		}
+		if _, listed := tracker.objectives[objective.Operation]; listed {
+			return nil, fmt.Errorf("operation %s has more than one objective: %w", objective.Operation, ErrInvalidObjective)
+		}
+		if objective.Target <= 0 || objective.Target > 1 || objective.Window < 0 {
+			return nil, fmt.Errorf("objective of %s has target %v and window %v: %w", objective.Operation, objective.Target, objective.Window, ErrInvalidObjective)
+		}
+		tracker.objectives[objective.Operation] = objective
+		tracker.windows[objective.Operation] = &sloWindow{}
+	}
+	return tracker, nil
+}
+
+func (tracker *SLOTracker) observe(operation string, start time.Time, err error) {
+	objective, tracked := tracker.objectives[operation]
+	if !tracked {
+		return
+	}
+	if _, failure := ClassifyFailure(err); err != nil && !failure {
+		return
+	}
+	now := time.Now()
+	elapsed := now.Sub(start)
+
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	counters := tracker.windows[operation].current(now, objective)
+	counters.total++
+	switch {
+	case err != nil:
+		counters.failures++
+	case objective.LatencyThreshold > 0 && elapsed > objective.LatencyThreshold:
+		counters.slowCalls++
+	}
+}
+
+// Status returns the state of every objective, ordered by operation
+func (tracker *SLOTracker) Status() []SLOStatus {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	now := time.Now()
+	statuses := []SLOStatus{}
+	for operation, objective := range tracker.objectives {
+		counters := tracker.windows[operation].sum(now, objective)
+		status := SLOStatus{
+			Objective:    objective,
+			Total:        counters.total,
+			Failures:     counters.failures,
+			SlowCalls:    counters.slowCalls,
+			SuccessRatio: 1,
+		}
+		if counters.total > 0 {
+			badRatio := float64(counters.failures+counters.slowCalls) / float64(counters.total)
+			status.SuccessRatio = 1 - badRatio
+			if objective.Target < 1 {
+				status.BurnRate = badRatio / (1 - objective.Target)
+			} else if badRatio > 0 {
+				status.BurnRate = 1
+			}
+		}
+		status.Healthy = status.SuccessRatio >= objective.Target
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Objective.Operation < statuses[j].Objective.Operation
+	})
+	return statuses
+}
+
+// Reset starts the tracking over, dropping the calls observed so far
+func (tracker *SLOTracker) Reset() {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	for operation := range tracker.windows {
+		tracker.windows[operation] = &sloWindow{}
+	}
+}
+
+func (tracker *SLOTracker) GetBlob(ctx context.Context, key string) (content []byte, err error) {
+	defer func(start time.Time) { tracker.observe("GetBlob", start, err) }(time.Now())
+	return tracker.Store.GetBlob(ctx, key)
+}
+
+func (tracker *SLOTracker) GetBlobInfo(ctx context.Context, key string) (blob BlobInfo, err error) {
+	defer func(start time.Time) { tracker.observe("GetBlobInfo", start, err) }(time.Now())
+	return tracker.Store.GetBlobInfo(ctx, key)
+}
+
+func (tracker *SLOTracker) GetBlobWithChecksum(ctx context.Context, key string) (content []byte, checksum string, err error) {
+	defer func(start time.Time) { tracker.observe("GetBlobWithChecksum", start, err) }(time.Now())
+	return tracker.Store.GetBlobWithChecksum(ctx, key)
+}
+
+func (tracker *SLOTracker) GetBlobAtVersion(ctx context.Context, key string, commitId string) (content []byte, err error) {
+	defer func(start time.Time) { tracker.observe("GetBlobAtVersion", start, err) }(time.Now())
+	return tracker.Store.GetBlobAtVersion(ctx, key, commitId)
+}
+
+func (tracker *SLOTracker) QueryByAttributes(ctx context.Context, selector AttributeSelector) (keys []string, err error) {
+	defer func(start time.Time) { tracker.observe("QueryByAttributes", start, err) }(time.Now())
+	return tracker.Store.QueryByAttributes(ctx, selector)
+}
+
+func (tracker *SLOTracker) HeadBlob(ctx context.Context, key string) (head BlobHead, err error) {
+	defer func(start time.Time) { tracker.observe("HeadBlob", start, err) }(time.Now())
+	return tracker.Store.HeadBlob(ctx, key)
+}
+
+func (tracker *SLOTracker) GetTree(ctx context.Context, prefix string, depth int) (tree *TreeNode, err error) {
+	defer func(start time.Time) { tracker.observe("GetTree", start, err) }(time.Now())
+	return tracker.Store.GetTree(ctx, prefix, depth)
+}
+
+func (tracker *SLOTracker) AddBlob(ctx context.Context, blob BlobInfo) (err error) {
+	defer func(start time.Time) { tracker.observe("AddBlob", start, err) }(time.Now())
+	return tracker.Store.AddBlob(ctx, blob)
+}
+
+func (tracker *SLOTracker) UpdateBlobMetadata(ctx context.Context, key string, metadata map[string]string, modifiedBy string) (err error) {
+	defer func(start time.Time) { tracker.observe("UpdateBlobMetadata", start, err) }(time.Now())
+	return tracker.Store.UpdateBlobMetadata(ctx, key, metadata, modifiedBy)
+}
+
+func (tracker *SLOTracker) DeleteBlob(ctx context.Context, key string, modifiedBy string) (err error) {
+	defer func(start time.Time) { tracker.observe("DeleteBlob", start, err) }(time.Now())
+	return tracker.Store.DeleteBlob(ctx, key, modifiedBy)
+}
+
+func (tracker *SLOTracker) DeleteBlobs(ctx context.Context, keys []string, modifiedBy string) (err error) {
+	defer func(start time.Time) { tracker.observe("DeleteBlobs", start, err) }(time.Now())
+	return tracker.Store.DeleteBlobs(ctx, keys, modifiedBy)
+}
+
+func (tracker *SLOTracker) ListBlobKeys(ctx context.Context, opts ListOptions) (keys []string, err error) {
+	defer func(start time.Time) { tracker.observe("ListBlobKeys", start, err) }(time.Now())
+	return tracker.Store.ListBlobKeys(ctx, opts)
+}
+
+func (tracker *SLOTracker) CopyBlob(ctx context.Context, sourceKey string, destinationKey string, modifiedBy string) (err error) {
+	defer func(start time.Time) { tracker.observe("CopyBlob", start, err) }(time.Now())
+	return tracker.Store.CopyBlob(ctx, sourceKey, destinationKey, modifiedBy)
+}
+
+func (tracker *SLOTracker) RenameBlob(ctx context.Context, oldKey string, newKey string, modifiedBy string) (err error) {
+	defer func(start time.Time) { tracker.observe("RenameBlob", start, err) }(time.Now())
+	return tracker.Store.RenameBlob(ctx, oldKey, newKey, modifiedBy)
+}
+
+func (tracker *SLOTracker) RestoreBlob(ctx context.Context, key string, commitId string, modifiedBy string) (err error) {
+	defer func(start time.Time) { tracker.observe("RestoreBlob", start, err) }(time.Now())
+	return tracker.Store.RestoreBlob(ctx, key, commitId, modifiedBy)
+}
+
+func (tracker *SLOTracker) CreateAlias(ctx context.Context, alias string, target string, modifiedBy string) (err error) {
+	defer func(start time.Time) { tracker.observe("CreateAlias", start, err) }(time.Now())
+	return tracker.Store.CreateAlias(ctx, alias, target, modifiedBy)
+}
+
+func (tracker *SLOTracker) ResolveAlias(ctx context.Context, key string) (target string, err error) {
+	defer func(start time.Time) { tracker.observe("ResolveAlias", start, err) }(time.Now())
+	return tracker.Store.ResolveAlias(ctx, key)
+}
+
+func (tracker *SLOTracker) ListAliases(ctx context.Context) (aliases []Alias, err error) {
+	defer func(start time.Time) { tracker.observe("ListAliases", start, err) }(time.Now())
+	return tracker.Store.ListAliases(ctx)
+}
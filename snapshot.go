@@ -0,0 +1,101 @@
+package vcblobstore
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SnapshotSource is the set of operations ExportSnapshot reads the blobs of a state with
+type SnapshotSource interface {
+	GetStateID(ctx context.Context) (string, error)
+	ListChangesBetween(ctx context.Context, fromStateID string, toStateID string) ([]KeyChange, error)
+	GetBlobAtVersion(ctx context.Context, key string, commitId string) ([]byte, error)
+}
+
+// SnapshotWriter receives the blobs of a snapshot
+type SnapshotWriter interface {
+	WriteBlob(key string, content []byte) error
+}
+
+// ExportSnapshot writes every blob as of the state (or tag) to dest and returns the state exported, the current one
+// if stateID is empty. Every blob is read at that state, so the snapshot is consistent however the store changes
+// meanwhile. The metadata of the blobs isn't exported
+func ExportSnapshot(ctx context.Context, source SnapshotSource, stateID string, dest SnapshotWriter) (string, error) {
+	if len(stateID) == 0 {
+		current, stateErr := source.GetStateID(ctx)
+		if stateErr != nil {
+			return "", fmt.Errorf("failed to get the current state: %w", stateErr)
+		}
+		stateID = current
+	}
+
+	blobs, listErr := source.ListChangesBetween(ctx, "", stateID)
+	if listErr != nil {
+		return "", fmt.Errorf("failed to list the blobs of %s: %w", stateID, listErr)
+	}
+	for _, blob := range blobs {
+		content, getErr := source.GetBlobAtVersion(ctx, blob.Key, stateID)
+		if getErr != nil {
+			return "", fmt.Errorf("failed to read %s at %s: %w", blob.Key, stateID, getErr)
+		}
+		if writeErr := dest.WriteBlob(blob.Key, content); writeErr != nil {
+			return "", fmt.Errorf("failed to write %s to the snapshot of %s: %w", blob.Key, stateID, writeErr)
+		}
+	}
+	return stateID, nil
+}
+
+// DirectorySnapshot writes the blobs of a snapshot as the files of the directory at the paths of their keys
+type DirectorySnapshot string
+
+func (directory DirectorySnapshot) WriteBlob(key string, content []byte) error {
+	if keyErr := ValidateKey(key); keyErr != nil {
+		return keyErr
+	}
+	file := filepath.Join(string(directory), filepath.FromSlash(key))
+	if mkdirErr := os.MkdirAll(filepath.Dir(file), 0700); mkdirErr != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", key, mkdirErr)
+	}
+	return os.WriteFile(file, content, 0600)
+}
+
+// ArchiveSnapshot writes the blobs of a snapshot to a tar.gz archive, which Close completes
+type ArchiveSnapshot struct {
+	gzipWriter *gzip.Writer
+	tarWriter  *tar.Writer
+	modTime    time.Time
+}
+
+// NewArchiveSnapshot returns the writer of the archive of a snapshot to w
+func NewArchiveSnapshot(w io.Writer) *ArchiveSnapshot {
+	gzipWriter := gzip.NewWriter(w)
+	return &ArchiveSnapshot{gzipWriter: gzipWriter, tarWriter: tar.NewWriter(gzipWriter), modTime: time.Now()}
+}
+
+func (archive *ArchiveSnapshot) WriteBlob(key string, content []byte) error {
+	header := &tar.Header{Name: key, Mode: 0600, Size: int64(len(content)), ModTime: archive.modTime, Typeflag: tar.TypeReg}
+	if headerErr := archive.tarWriter.WriteHeader(header); headerErr != nil {
+		return fmt.Errorf("failed to write archive header of %s: %w", key, headerErr)
+	}
+	if _, writeErr := archive.tarWriter.Write(content); writeErr != nil {
+		return fmt.Errorf("failed to write %s to the archive: %w", key, writeErr)
+	}
+	return nil
+}
+
+// Close writes the end of the archive. It doesn't close the underlying writer
+func (archive *ArchiveSnapshot) Close() error {
+	if closeErr := archive.tarWriter.Close(); closeErr != nil {
+		return fmt.Errorf("failed to complete the archive: %w", closeErr)
+	}
+	if closeErr := archive.gzipWriter.Close(); closeErr != nil {
+		return fmt.Errorf("failed to complete the archive: %w", closeErr)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package vcblobstore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// NetChanges folds the changes of the versions, oldest first, into the net change of every key between the state
+// preceding the first version and the state of the last one, sorted by key. A blob created and deleted in between is
+// left out, a blob deleted and created again counts as updated
+func NetChanges(versions []RepositoryVersion) []KeyChange {
+	firstOperation := map[string]BlobOperation{}
+	lastOperation := map[string]BlobOperation{}
+	for _, version := range versions {
+		for _, change := range version.Changes {
+			if _, seen := firstOperation[change.Key]; !seen {
+				firstOperation[change.Key] = change.Operation
+			}
+			lastOperation[change.Key] = change.Operation
+		}
+	}
+
+	changes := []KeyChange{}
+	for key, first := range firstOperation {
+		existedBefore := first != BlobOperationCreate
+		existsAfter := lastOperation[key] != BlobOperationDelete
+		switch {
+		case !existedBefore && !existsAfter:
+			continue
+		case !existedBefore:
+			changes = append(changes, KeyChange{Key: key, Operation: BlobOperationCreate})
+		case !existsAfter:
+			changes = append(changes, KeyChange{Key: key, Operation: BlobOperationDelete})
+		default:
+			changes = append(changes, KeyChange{Key: key, Operation: BlobOperationUpdate})
+		}
+	}
+	SortKeyChanges(changes)
+	return changes
+}
+
+// SortKeyChanges sorts the changes by key
+func SortKeyChanges(changes []KeyChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+}
+
+// ChangeFeed is the outcome of ListChangesSince
+type ChangeFeed struct {
+	// StateID is the state the changes lead to, to be recorded for the next call
+	StateID string
+	Changes []KeyChange
+}
+
+// ListChangesSince returns the net changes of the blobs since the state recorded by the previous call (since the
+// beginning if stateID is empty) together with the current state, e.g. to replicate the store into a downstream
+// system by polling. The current state is pinned first, so that concurrent writes are picked up by the next call
+func ListChangesSince(ctx context.Context, store Admin, stateID string) (ChangeFeed, error) {
+	current, stateErr := store.GetStateID(ctx)
+	if stateErr != nil {
+		return ChangeFeed{}, fmt.Errorf("failed to get the current state: %w", stateErr)
+	}
+	if current == stateID {
+		return ChangeFeed{StateID: current, Changes: []KeyChange{}}, nil
+	}
+	changes, listErr := store.ListChangesBetween(ctx, stateID, current)
+	if listErr != nil {
+		return ChangeFeed{}, fmt.Errorf("failed to list the changes since %s: %w", stateID, listErr)
+	}
+	return ChangeFeed{StateID: current, Changes: changes}, nil
+}
@@ -0,0 +1,58 @@
+package vcblobstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"iter"
+	"vcblobstore/git"
+)
+
+// Store is the blob storage API implemented by every backend, so that applications can accept any of them
+type Store interface {
+	fmt.Stringer
+	CreateRepository(ctx context.Context) error
+	GetBlob(ctx context.Context, key string) ([]byte, error)
+	GetBlobInfo(ctx context.Context, key string) (BlobInfo, error)
+	GetBlobWithChecksum(ctx context.Context, key string) ([]byte, string, error)
+	GetBlobAtVersion(ctx context.Context, key string, commitId string) ([]byte, error)
+	QueryByAttributes(ctx context.Context, selector AttributeSelector) ([]string, error)
+	HeadBlob(ctx context.Context, key string) (BlobHead, error)
+	GetTree(ctx context.Context, prefix string, depth int) (*TreeNode, error)
+	AddBlob(ctx context.Context, blob BlobInfo) error
+	UpdateBlobMetadata(ctx context.Context, key string, metadata map[string]string, modifiedBy string) error
+	DeleteBlob(ctx context.Context, key string, modifiedBy string) error
+	DeleteBlobs(ctx context.Context, keys []string, modifiedBy string) error
+	ListBlobKeys(ctx context.Context, opts ListOptions) ([]string, error)
+	IterateBlobKeys(ctx context.Context, opts ListOptions) iter.Seq2[string, error]
+	CopyBlob(ctx context.Context, sourceKey string, destinationKey string, modifiedBy string) error
+	RenameBlob(ctx context.Context, oldKey string, newKey string, modifiedBy string) error
+	RestoreBlob(ctx context.Context, key string, commitId string, modifiedBy string) error
+	CreateAlias(ctx context.Context, alias string, target string, modifiedBy string) error
+	ResolveAlias(ctx context.Context, key string) (string, error)
+	ListAliases(ctx context.Context) ([]Alias, error)
+}
+
+// Admin groups the repository management, versioning and history operations every backend implements
+type Admin interface {
+	GetBlobHistory(ctx context.Context, key string, filter HistoryFilter) ([]BlobVersion, error)
+	ExportHistory(ctx context.Context, keys []string, w io.Writer) error
+	ImportHistory(ctx context.Context, r io.Reader) error
+	ResetRepository(ctx context.Context) error
+	FastReset(ctx context.Context) error
+	DeleteRepository(ctx context.Context) error
+	CheckStatus() (bool, error)
+	SelfTest(ctx context.Context, modifiedBy string) SelfTestReport
+	GetStateID(ctx context.Context) (string, error)
+	GetVersionFor(ctx context.Context, key string) (string, error)
+	GetVersionMetadata(ctx context.Context, commitId string) (git.CommitMetadata, error)
+	GetStoreMetadata(ctx context.Context) (StoreMetadata, error)
+	SetStoreMetadata(ctx context.Context, metadata StoreMetadata, modifiedBy string) error
+	FindDuplicates(ctx context.Context) ([]DuplicateGroup, error)
+	// ListVersions returns the versions made after fromStateID up to and including toStateID, oldest first.
+	// An empty fromStateID starts at the first version, an empty toStateID ends at the current one
+	ListVersions(ctx context.Context, fromStateID string, toStateID string) ([]RepositoryVersion, error)
+	// ListChangesBetween returns the net changes of the blobs between the states, sorted by key. The empty state IDs
+	// stand for the same states as with ListVersions
+	ListChangesBetween(ctx context.Context, fromStateID string, toStateID string) ([]KeyChange, error)
+}
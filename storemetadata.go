@@ -0,0 +1,107 @@
+package vcblobstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"sync"
+)
+
+// StoreSchemaVersion is the version of the layout of the internal entries written by this version of the library
+const StoreSchemaVersion = 1
+
+// StoreMetadata is the document in which the store records its own configuration
+type StoreMetadata struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	Owner         string `json:"owner,omitempty"`
+	// RetentionDefaults holds the retention settings of the blobs which have none of their own (e.g. "maxVersions": "10")
+	RetentionDefaults map[string]string `json:"retentionDefaults,omitempty"`
+	FeatureFlags      map[string]bool   `json:"featureFlags,omitempty"`
+	// Extensions keeps the attributes unknown to this version of the library, so that they survive being read and written back
+	Extensions map[string]json.RawMessage `json:"-"`
+}
+
+// DefaultStoreMetadata is the metadata of stores which have never had theirs set
+func DefaultStoreMetadata() StoreMetadata {
+	return StoreMetadata{SchemaVersion: StoreSchemaVersion}
+}
+
+func (metadata StoreMetadata) clone() StoreMetadata {
+	metadata.RetentionDefaults = maps.Clone(metadata.RetentionDefaults)
+	metadata.FeatureFlags = maps.Clone(metadata.FeatureFlags)
+	metadata.Extensions = maps.Clone(metadata.Extensions)
+	return metadata
+}
+
+func EncodeStoreMetadata(metadata StoreMetadata) ([]byte, error) {
+	known, marshalErr := json.Marshal(metadata)
+	if marshalErr != nil {
+		return nil, fmt.Errorf("failed to encode store metadata: %w", marshalErr)
+	}
+	if len(metadata.Extensions) == 0 {
+		return known, nil
+	}
+
+	document := map[string]json.RawMessage{}
+	if err := json.Unmarshal(known, &document); err != nil {
+		return nil, fmt.Errorf("failed to encode store metadata: %w", err)
+	}
+	for name, value := range metadata.Extensions {
+		if _, isKnown := document[name]; !isKnown {
+			document[name] = value
+		}
+	}
+	content, err := json.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode store metadata: %w", err)
+	}
+	return content, nil
+}
+
+func DecodeStoreMetadata(content []byte) (StoreMetadata, error) {
+	metadata := StoreMetadata{}
+	if err := json.Unmarshal(content, &metadata); err != nil {
+		return metadata, fmt.Errorf("failed to decode store metadata: %w", err)
+	}
+
+	document := map[string]json.RawMessage{}
+	if err := json.Unmarshal(content, &document); err != nil {
+		return metadata, fmt.Errorf("failed to decode store metadata: %w", err)
+	}
+	for _, name := range []string{"schemaVersion", "owner", "retentionDefaults", "featureFlags"} {
+		delete(document, name)
+	}
+	if len(document) > 0 {
+		metadata.Extensions = document
+	}
+	return metadata, nil
+}
+
+// StoreMetadataCache keeps the store metadata document last read or written by a backend
+type StoreMetadataCache struct {
+	mutex    sync.Mutex
+	metadata *StoreMetadata
+}
+
+func (cache *StoreMetadataCache) Get() (StoreMetadata, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if cache.metadata == nil {
+		return StoreMetadata{}, false
+	}
+	return cache.metadata.clone(), true
+}
+
+func (cache *StoreMetadataCache) Set(metadata StoreMetadata) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cached := metadata.clone()
+	cache.metadata = &cached
+}
+
+// Invalidate drops the cached document, e.g. after the repository has been reset
+func (cache *StoreMetadataCache) Invalidate() {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.metadata = nil
+}
@@ -0,0 +1,12 @@
+package vcblobstore
+
+import (
+	"context"
+	"io"
+)
+
+// StreamWriter is implemented by the stores which can write the content of blobs without holding it in memory
+type StreamWriter interface {
+	// AddBlobFromReader works as AddBlob with the content read from r. The Content field of the blob is ignored
+	AddBlobFromReader(ctx context.Context, blob BlobInfo, r io.Reader) error
+}
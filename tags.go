@@ -0,0 +1,24 @@
+package vcblobstore
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrInvalidTagName = errors.New("invalid tag name")
+
+// Tag names a state of the repository, e.g. a release of the blobs. The name of the tag can be passed as a version
+// to GetBlobAtVersion and the other operations reading a past state
+type Tag struct {
+	Name    string
+	StateID string
+}
+
+// Tagger is implemented by the backends able to name states of the repository
+type Tagger interface {
+	// TagState names the state (the current one if stateID is empty). Tags are immutable: ErrConflict is returned
+	// if the name is taken
+	TagState(ctx context.Context, name string, stateID string) error
+	// ListTags returns the tags sorted by name
+	ListTags(ctx context.Context) ([]Tag, error)
+}
@@ -1,12 +1,23 @@
 package test
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
+	"maps"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 	"vcblobstore"
+	"vcblobstore/faulty"
 	"vcblobstore/git"
 	"vcblobstore/git/gitlab"
 
@@ -15,26 +26,9 @@ import (
 
 type TestBlobstoreClientFactory func() (TestBlobstoreClient, error)
 
-type BlobstoreRepository interface {
-	fmt.Stringer
-	CreateRepository(ctx context.Context) error
-	GetBlob(ctx context.Context, key string) ([]byte, error)
-	AddBlob(ctx context.Context, blob vcblobstore.BlobInfo) error
-	DeleteBlob(ctx context.Context, key string, modifiedBy string) error
-}
-
-type vcblobstoreManagement interface {
-	ResetRepository(ctx context.Context) error
-	DeleteRepository(ctx context.Context) error
-	CheckStatus() (bool, error)
-	GetStateID(ctx context.Context) (string, error)
-	GetVersionFor(ctx context.Context, key string) (string, error)
-	GetVersionMetadata(ctx context.Context, commitId string) (git.CommitMetadata, error)
-}
-
 type TestBlobstoreClient interface {
-	BlobstoreRepository
-	vcblobstoreManagement
+	vcblobstore.Store
+	vcblobstore.Admin
 }
 
 type TestBlobstoreController struct {
@@ -126,10 +120,737 @@ func (s *BlobstoreTestSuite) TestAcceptsNewBlobWhenNotEmpty() {
 	s.NotEqual(firstSha1, secondSha1)
 }
 
+func (s *BlobstoreTestSuite) TestAuthorNameAndEmail() {
+	blob := CloneBlob(TestData[0])
+	blob.AuthorName = "Zazie Lalochère"
+	blob.AuthorEmail = "zazie@metro.example"
+	s.NoError(s.RepoController.repo.AddBlob(s.Ctx, blob))
+
+	version, versionErr := s.RepoController.repo.GetVersionFor(s.Ctx, blob.Key)
+	s.NoError(versionErr)
+	meta, metaErr := s.RepoController.repo.GetVersionMetadata(s.Ctx, version)
+	s.NoError(metaErr)
+	s.Equal("Zazie Lalochère <zazie@metro.example>", meta.Author)
+}
+
+func (s *BlobstoreTestSuite) TestHeadBlob() {
+	blob := TestData[0]
+
+	head, headErr := s.RepoController.repo.HeadBlob(s.Ctx, blob.Key)
+	s.NoError(headErr)
+	s.False(head.Exists)
+
+	addErr := s.RepoController.repo.AddBlob(s.Ctx, blob)
+	s.NoError(addErr)
+
+	version, versionErr := s.RepoController.repo.GetVersionFor(s.Ctx, blob.Key)
+	s.NoError(versionErr)
+
+	head, headErr = s.RepoController.repo.HeadBlob(s.Ctx, blob.Key)
+	s.NoError(headErr)
+	s.True(head.Exists)
+	s.Equal(int64(len(blob.Content)), head.Size)
+	s.Equal(version, head.Version)
+}
+
+func (s *BlobstoreTestSuite) TestDeleteBlobs() {
+	for _, blob := range TestData {
+		s.NoError(s.RepoController.repo.AddBlob(s.Ctx, blob))
+	}
+	s.NoError(s.RepoController.repo.AddBlob(s.Ctx, createTestBlob("keeper", "ux")))
+	stateBeforeDelete, stateErr := s.RepoController.repo.GetStateID(s.Ctx)
+	s.NoError(stateErr)
+
+	s.NoError(s.RepoController.repo.DeleteBlobs(s.Ctx, []string{TestData[0].Key, TestData[1].Key}, "cleaner"))
+
+	keys, listErr := s.RepoController.repo.ListBlobKeys(s.Ctx, vcblobstore.ListOptions{})
+	s.NoError(listErr)
+	s.Equal([]string{"keeper"}, keys)
+	internalKeys := []string{"keeper", vcblobstore.DefaultNaming.AttributeIndexKey()}
+	s.ErrorIs(s.RepoController.repo.DeleteBlobs(s.Ctx, internalKeys, "cleaner"), vcblobstore.ErrInvalidKey)
+
+	history, historyErr := s.RepoController.repo.GetBlobHistory(s.Ctx, TestData[0].Key, vcblobstore.HistoryFilter{})
+	s.NoError(historyErr)
+	otherHistory, otherHistoryErr := s.RepoController.repo.GetBlobHistory(s.Ctx, TestData[1].Key, vcblobstore.HistoryFilter{})
+	s.NoError(otherHistoryErr)
+	s.Equal(history[0].Version, otherHistory[0].Version)
+	s.NotEqual(stateBeforeDelete, history[0].Version)
+	s.AssertBlobstoreCleanStatus()
+}
+
+func (s *BlobstoreTestSuite) TestCopyBlob() {
+	blob := TestData[0]
+	copyKey := blob.Key + "-copy"
+
+	addErr := s.RepoController.repo.AddBlob(s.Ctx, blob)
+	s.NoError(addErr)
+
+	copyErr := s.RepoController.repo.CopyBlob(s.Ctx, blob.Key, copyKey, blob.ModifiedBy)
+	s.NoError(copyErr)
+
+	original, getOriginalErr := s.RepoController.repo.GetBlob(s.Ctx, blob.Key)
+	s.NoError(getOriginalErr)
+	s.Equal(blob.Content, original)
+
+	copied, getCopyErr := s.RepoController.repo.GetBlob(s.Ctx, copyKey)
+	s.NoError(getCopyErr)
+	s.Equal(blob.Content, copied)
+	s.AssertBlobstoreCleanStatus()
+}
+
+func (s *BlobstoreTestSuite) TestRenameBlob() {
+	blob := TestData[0]
+	newKey := "renamed/" + blob.Key
+
+	addErr := s.RepoController.repo.AddBlob(s.Ctx, blob)
+	s.NoError(addErr)
+
+	renameErr := s.RepoController.repo.RenameBlob(s.Ctx, blob.Key, newKey, blob.ModifiedBy)
+	s.NoError(renameErr)
+
+	oldHead, oldHeadErr := s.RepoController.repo.HeadBlob(s.Ctx, blob.Key)
+	s.NoError(oldHeadErr)
+	s.False(oldHead.Exists)
+
+	content, getErr := s.RepoController.repo.GetBlob(s.Ctx, newKey)
+	s.NoError(getErr)
+	s.Equal(blob.Content, content)
+	s.AssertBlobstoreCleanStatus()
+}
+
+func (s *BlobstoreTestSuite) TestGetTree() {
+	for _, key := range []string{"icons/small/metro-zazie", "icons/large/metro-zazie", "icons/README", "LICENSE"} {
+		s.NoError(s.RepoController.repo.AddBlob(s.Ctx, createTestBlob(key, "ux")))
+	}
+
+	tree, err := s.RepoController.repo.GetTree(s.Ctx, "icons", 1)
+	s.NoError(err)
+	s.Equal("icons", tree.Path)
+	s.Equal(3, len(tree.Children))
+	s.Equal("README", tree.Children[0].Name)
+	s.Equal(vcblobstore.TreeNodeBlob, tree.Children[0].Type)
+	s.NotEmpty(tree.Children[0].BlobId)
+	s.Equal("icons/large", tree.Children[1].Path)
+	s.Equal(vcblobstore.TreeNodeDirectory, tree.Children[1].Type)
+	s.Empty(tree.Children[1].Children)
+
+	tree, err = s.RepoController.repo.GetTree(s.Ctx, "", 0)
+	s.NoError(err)
+	s.Equal(2, len(tree.Children))
+	s.Equal("icons/small/metro-zazie", tree.Children[1].Children[2].Children[0].Path)
+}
+
+func (s *BlobstoreTestSuite) TestExportImportHistory() {
+	blob := TestData[0]
+	otherBlob := TestData[1]
+	updatedBlob := CloneBlob(blob)
+	updatedBlob.Content = randomBytes(512)
+	updatedBlob.ModifiedBy = "designer"
+
+	s.NoError(s.RepoController.repo.AddBlob(s.Ctx, blob))
+	s.NoError(s.RepoController.repo.AddBlob(s.Ctx, otherBlob))
+	s.NoError(s.RepoController.repo.AddBlob(s.Ctx, updatedBlob))
+
+	bundle := bytes.Buffer{}
+	s.NoError(s.RepoController.repo.ExportHistory(s.Ctx, []string{blob.Key}, &bundle))
+
+	records := []vcblobstore.HistoryRecord{}
+	s.NoError(vcblobstore.ReadHistory(bytes.NewReader(bundle.Bytes()), func(record vcblobstore.HistoryRecord) error {
+		records = append(records, record)
+		return nil
+	}))
+	s.Equal(2, len(records))
+	s.Equal(blob.Content, records[0].Content)
+	s.Equal("designer", records[1].AuthorName())
+
+	for index := range records {
+		records[index].AuthorDate = time.Date(2020, time.March, index+1, 12, 0, 0, 0, time.UTC)
+	}
+	bundle.Reset()
+	s.NoError(vcblobstore.WriteHistory(&bundle, records))
+
+	s.NoError(s.RepoController.repo.ResetRepository(s.Ctx))
+	s.NoError(s.RepoController.repo.ImportHistory(s.Ctx, &bundle))
+
+	content, getErr := s.RepoController.repo.GetBlob(s.Ctx, blob.Key)
+	s.NoError(getErr)
+	s.Equal(updatedBlob.Content, content)
+	otherHead, otherHeadErr := s.RepoController.repo.HeadBlob(s.Ctx, otherBlob.Key)
+	s.NoError(otherHeadErr)
+	s.False(otherHead.Exists)
+	history, historyErr := s.RepoController.repo.GetBlobHistory(s.Ctx, blob.Key, vcblobstore.HistoryFilter{})
+	s.NoError(historyErr)
+	s.Equal(2, len(history))
+	s.Equal(records[0].Message, history[1].Message)
+	s.Equal(records[1].Message, history[0].Message)
+	if _, datesLost := s.RepoController.repo.(*gitlab.Gitlab); !datesLost {
+		s.True(records[0].AuthorDate.Equal(history[1].AuthorDate))
+		s.True(records[1].AuthorDate.Equal(history[0].AuthorDate))
+
+		// The versions were committed now, but authored in March 2020
+		filter := vcblobstore.HistoryFilter{Since: records[1].AuthorDate, Until: records[1].AuthorDate.Add(time.Hour)}
+		history, historyErr = s.RepoController.repo.GetBlobHistory(s.Ctx, blob.Key, filter)
+		s.NoError(historyErr)
+		s.Equal(1, len(history))
+		s.Equal(records[1].Message, history[0].Message)
+	}
+}
+
+func (s *BlobstoreTestSuite) TestListBlobKeysWithPrefix() {
+	for _, key := range []string{"icons/small/metro-zazie", "icons/large/metro-zazie", "iconography", "LICENSE"} {
+		s.NoError(s.RepoController.repo.AddBlob(s.Ctx, createTestBlob(key, "ux")))
+	}
+
+	keys, err := s.RepoController.repo.ListBlobKeys(s.Ctx, vcblobstore.ListOptions{Prefix: "icons/"})
+	s.NoError(err)
+	s.ElementsMatch([]string{"icons/small/metro-zazie", "icons/large/metro-zazie"}, keys)
+
+	keys, err = s.RepoController.repo.ListBlobKeys(s.Ctx, vcblobstore.ListOptions{Prefix: "icon"})
+	s.NoError(err)
+	s.ElementsMatch([]string{"icons/small/metro-zazie", "icons/large/metro-zazie", "iconography"}, keys)
+
+	keys, err = s.RepoController.repo.ListBlobKeys(s.Ctx, vcblobstore.ListOptions{Prefix: "icons/sm"})
+	s.NoError(err)
+	s.Equal([]string{"icons/small/metro-zazie"}, keys)
+}
+
+func (s *BlobstoreTestSuite) TestIterateBlobKeys() {
+	for _, key := range []string{"icons/small/metro-zazie", "icons/large/metro-zazie", "LICENSE"} {
+		s.NoError(s.RepoController.repo.AddBlob(s.Ctx, createTestBlob(key, "ux")))
+	}
+	s.NoError(s.RepoController.repo.CreateAlias(s.Ctx, "legacy", "LICENSE", "ux"))
+
+	keys := []string{}
+	for key, err := range s.RepoController.repo.IterateBlobKeys(s.Ctx, vcblobstore.ListOptions{}) {
+		s.NoError(err)
+		keys = append(keys, key)
+	}
+	s.ElementsMatch([]string{"icons/small/metro-zazie", "icons/large/metro-zazie", "LICENSE"}, keys)
+
+	keys = []string{}
+	for key, err := range s.RepoController.repo.IterateBlobKeys(s.Ctx, vcblobstore.ListOptions{Prefix: "icons/"}) {
+		s.NoError(err)
+		keys = append(keys, key)
+		break
+	}
+	s.Equal(1, len(keys))
+}
+
+func (s *BlobstoreTestSuite) TestGetBlobAtVersion() {
+	blob := TestData[0]
+	updatedBlob := CloneBlob(blob)
+	updatedBlob.Content = randomBytes(1024)
+
+	s.NoError(s.RepoController.repo.AddBlob(s.Ctx, blob))
+	firstVersion, firstVersionErr := s.RepoController.repo.GetVersionFor(s.Ctx, blob.Key)
+	s.NoError(firstVersionErr)
+	s.NoError(s.RepoController.repo.AddBlob(s.Ctx, updatedBlob))
+	s.NoError(s.RepoController.repo.AddBlob(s.Ctx, TestData[1]))
+	secondVersion, secondVersionErr := s.RepoController.repo.GetStateID(s.Ctx)
+	s.NoError(secondVersionErr)
+
+	content, err := s.RepoController.repo.GetBlobAtVersion(s.Ctx, blob.Key, firstVersion)
+	s.NoError(err)
+	s.Equal(blob.Content, content)
+
+	content, err = s.RepoController.repo.GetBlobAtVersion(s.Ctx, blob.Key, secondVersion)
+	s.NoError(err)
+	s.Equal(updatedBlob.Content, content)
+
+	_, err = s.RepoController.repo.GetBlobAtVersion(s.Ctx, TestData[1].Key, firstVersion)
+	s.ErrorIs(err, vcblobstore.ErrBlobNotFound)
+}
+
+func (s *BlobstoreTestSuite) TestRestoreBlob() {
+	blob := TestData[0]
+	updatedBlob := CloneBlob(blob)
+	updatedBlob.Content = randomBytes(1024)
+
+	s.NoError(s.RepoController.repo.AddBlob(s.Ctx, blob))
+	firstVersion, firstVersionErr := s.RepoController.repo.GetVersionFor(s.Ctx, blob.Key)
+	s.NoError(firstVersionErr)
+	s.NoError(s.RepoController.repo.DeleteBlob(s.Ctx, blob.Key, blob.ModifiedBy))
+
+	s.NoError(s.RepoController.repo.RestoreBlob(s.Ctx, blob.Key, firstVersion, "restorer"))
+	content, getErr := s.RepoController.repo.GetBlob(s.Ctx, blob.Key)
+	s.NoError(getErr)
+	s.Equal(blob.Content, content)
+
+	restoredVersion, restoredVersionErr := s.RepoController.repo.GetVersionFor(s.Ctx, blob.Key)
+	s.NoError(restoredVersionErr)
+	s.NotEqual(firstVersion, restoredVersion)
+	meta, metaErr := s.RepoController.repo.GetVersionMetadata(s.Ctx, restoredVersion)
+	s.NoError(metaErr)
+	s.Contains(meta.Message, firstVersion)
+	internalKey := vcblobstore.DefaultNaming.AttributeIndexKey()
+	s.ErrorIs(s.RepoController.repo.RestoreBlob(s.Ctx, internalKey, firstVersion, "restorer"), vcblobstore.ErrInvalidKey)
+	s.AssertBlobstoreCleanStatus()
+}
+
+func (s *BlobstoreTestSuite) TestGetBlobHistory() {
+	blob := TestData[0]
+	updatedBlob := CloneBlob(blob)
+	updatedBlob.Content = randomBytes(1024)
+	updatedBlob.ModifiedBy = "designer"
+	timeBeforeChanges := time.Now().Add(-time.Minute)
+
+	s.NoError(s.RepoController.repo.AddBlob(s.Ctx, blob))
+	s.NoError(s.RepoController.repo.AddBlob(s.Ctx, TestData[1]))
+	s.NoError(s.RepoController.repo.AddBlob(s.Ctx, updatedBlob))
+	s.NoError(s.RepoController.repo.DeleteBlob(s.Ctx, blob.Key, blob.ModifiedBy))
+
+	history, err := s.RepoController.repo.GetBlobHistory(s.Ctx, blob.Key, vcblobstore.HistoryFilter{})
+	s.NoError(err)
+	s.Equal(3, len(history))
+	s.Equal(vcblobstore.BlobOperationDelete, history[0].Operation)
+	s.Equal(vcblobstore.BlobOperationUpdate, history[1].Operation)
+	s.Equal(vcblobstore.BlobOperationCreate, history[2].Operation)
+
+	history, err = s.RepoController.repo.GetBlobHistory(s.Ctx, blob.Key, vcblobstore.HistoryFilter{Author: "designer"})
+	s.NoError(err)
+	s.Equal(1, len(history))
+	s.Equal(vcblobstore.BlobOperationUpdate, history[0].Operation)
+
+	history, err = s.RepoController.repo.GetBlobHistory(s.Ctx, blob.Key, vcblobstore.HistoryFilter{
+		Since:      timeBeforeChanges,
+		Operations: []vcblobstore.BlobOperation{vcblobstore.BlobOperationCreate, vcblobstore.BlobOperationDelete},
+	})
+	s.NoError(err)
+	s.Equal(2, len(history))
+
+	history, err = s.RepoController.repo.GetBlobHistory(s.Ctx, blob.Key, vcblobstore.HistoryFilter{Until: timeBeforeChanges})
+	s.NoError(err)
+	s.Empty(history)
+
+	allVersions, err := s.RepoController.repo.GetBlobHistory(s.Ctx, blob.Key, vcblobstore.HistoryFilter{})
+	s.NoError(err)
+	history, err = s.RepoController.repo.GetBlobHistory(s.Ctx, blob.Key, vcblobstore.HistoryFilter{SinceVersion: allVersions[2].Version})
+	s.NoError(err)
+	s.Equal(allVersions[:2], history)
+}
+
+func (s *BlobstoreTestSuite) TestBlobMetadata() {
+	blob := CloneBlob(TestData[0])
+	blob.Metadata = map[string]string{"content-type": "image/svg+xml", "origin": "designer"}
+	s.NoError(s.RepoController.repo.AddBlob(s.Ctx, blob))
+
+	blobInfo, getErr := s.RepoController.repo.GetBlobInfo(s.Ctx, blob.Key)
+	s.NoError(getErr)
+	s.Equal(blob.Content, blobInfo.Content)
+	s.Equal(blob.Metadata, blobInfo.Metadata)
+
+	rewritten := CloneBlob(blob)
+	rewritten.Content = []byte("rewritten")
+	rewritten.Metadata = nil
+	s.NoError(s.RepoController.repo.AddBlob(s.Ctx, rewritten))
+	blobInfo, getErr = s.RepoController.repo.GetBlobInfo(s.Ctx, blob.Key)
+	s.NoError(getErr)
+	s.Equal(rewritten.Content, blobInfo.Content)
+	s.Equal(blob.Metadata, blobInfo.Metadata)
+
+	s.NoError(s.RepoController.repo.RenameBlob(s.Ctx, blob.Key, "renamed", blob.ModifiedBy))
+	blobInfo, getErr = s.RepoController.repo.GetBlobInfo(s.Ctx, "renamed")
+	s.NoError(getErr)
+	s.Equal(blob.Metadata, blobInfo.Metadata)
+
+	rewritten.Key = "renamed"
+	rewritten.Metadata = map[string]string{}
+	s.NoError(s.RepoController.repo.AddBlob(s.Ctx, rewritten))
+	blobInfo, getErr = s.RepoController.repo.GetBlobInfo(s.Ctx, "renamed")
+	s.NoError(getErr)
+	s.Empty(blobInfo.Metadata)
+
+	s.NoError(s.RepoController.repo.DeleteBlob(s.Ctx, "renamed", blob.ModifiedBy))
+	keys, listErr := s.RepoController.repo.ListBlobKeys(s.Ctx, vcblobstore.ListOptions{})
+	s.NoError(listErr)
+	s.Empty(keys)
+	tree, treeErr := s.RepoController.repo.GetTree(s.Ctx, vcblobstore.MetadataKeyPrefix, 0)
+	s.NoError(treeErr)
+	s.Empty(tree.Children)
+}
+
+func (s *BlobstoreTestSuite) TestUpdateBlobMetadata() {
+	blob := CloneBlob(TestData[0])
+	blob.Metadata = map[string]string{"content-type": "image/svg+xml"}
+	s.NoError(s.RepoController.repo.AddBlob(s.Ctx, blob))
+	blobVersion, versionErr := s.RepoController.repo.GetVersionFor(s.Ctx, blob.Key)
+	s.NoError(versionErr)
+
+	updatedMetadata := map[string]string{"content-type": "image/svg+xml", "tier": "gold"}
+	s.NoError(s.RepoController.repo.UpdateBlobMetadata(s.Ctx, blob.Key, updatedMetadata, "classifier"))
+
+	blobInfo, getErr := s.RepoController.repo.GetBlobInfo(s.Ctx, blob.Key)
+	s.NoError(getErr)
+	s.Equal(blob.Content, blobInfo.Content)
+	s.Equal(updatedMetadata, blobInfo.Metadata)
+	state, stateErr := s.RepoController.repo.GetStateID(s.Ctx)
+	s.NoError(stateErr)
+	s.NoError(s.RepoController.repo.UpdateBlobMetadata(s.Ctx, blob.Key, maps.Clone(updatedMetadata), "classifier"))
+	unchangedState, stateErr := s.RepoController.repo.GetStateID(s.Ctx)
+	s.NoError(stateErr)
+	s.Equal(state, unchangedState)
+
+	history, historyErr := s.RepoController.repo.GetBlobHistory(s.Ctx, blob.Key, vcblobstore.HistoryFilter{})
+	s.NoError(historyErr)
+	s.Equal(1, len(history))
+	s.Equal(blobVersion, history[0].Version)
+
+	notFoundErr := s.RepoController.repo.UpdateBlobMetadata(s.Ctx, "no-such-blob", updatedMetadata, "classifier")
+	s.ErrorIs(notFoundErr, vcblobstore.ErrBlobNotFound)
+}
+
+func (s *BlobstoreTestSuite) TestQueryByAttributes() {
+	labels := map[string]map[string]string{
+		"invoice-template":  {"team": "payments", "tier": "gold"},
+		"receipt-template":  {"team": "payments", "tier": "silver"},
+		"welcome-template":  {"team": "growth", "tier": "gold"},
+		"untagged-template": nil,
+	}
+	for key, metadata := range labels {
+		blob := createTestBlob(key, "ux")
+		blob.Metadata = metadata
+		s.NoError(s.RepoController.repo.AddBlob(s.Ctx, blob))
+	}
+
+	selector, parseErr := vcblobstore.ParseAttributeSelector("team=payments, tier=gold")
+	s.NoError(parseErr)
+	keys, queryErr := s.RepoController.repo.QueryByAttributes(s.Ctx, selector)
+	s.NoError(queryErr)
+	s.Equal([]string{"invoice-template"}, keys)
+
+	keys, queryErr = s.RepoController.repo.QueryByAttributes(s.Ctx, vcblobstore.AttributeSelector{"tier": "gold"})
+	s.NoError(queryErr)
+	s.Equal([]string{"invoice-template", "welcome-template"}, keys)
+
+	s.NoError(s.RepoController.repo.UpdateBlobMetadata(s.Ctx, "welcome-template", map[string]string{"team": "growth"}, "classifier"))
+	s.NoError(s.RepoController.repo.RenameBlob(s.Ctx, "invoice-template", "invoice", "ux"))
+	keys, queryErr = s.RepoController.repo.QueryByAttributes(s.Ctx, vcblobstore.AttributeSelector{"tier": "gold"})
+	s.NoError(queryErr)
+	s.Equal([]string{"invoice"}, keys)
+
+	s.NoError(s.RepoController.repo.DeleteBlob(s.Ctx, "invoice", "ux"))
+	keys, queryErr = s.RepoController.repo.QueryByAttributes(s.Ctx, vcblobstore.AttributeSelector{"tier": "gold"})
+	s.NoError(queryErr)
+	s.Empty(keys)
+}
+
+func (s *BlobstoreTestSuite) TestAliases() {
+	blob := TestData[0]
+	s.NoError(s.RepoController.repo.AddBlob(s.Ctx, blob))
+
+	s.NoError(s.RepoController.repo.CreateAlias(s.Ctx, "legacy", blob.Key, blob.ModifiedBy))
+	s.NoError(s.RepoController.repo.CreateAlias(s.Ctx, "ancient", "legacy", blob.ModifiedBy))
+
+	canonicalKey, resolveErr := s.RepoController.repo.ResolveAlias(s.Ctx, "ancient")
+	s.NoError(resolveErr)
+	s.Equal(blob.Key, canonicalKey)
+
+	content, getErr := s.RepoController.repo.GetBlob(s.Ctx, "ancient")
+	s.NoError(getErr)
+	s.Equal(blob.Content, content)
+
+	loopErr := s.RepoController.repo.CreateAlias(s.Ctx, "legacy", "ancient", blob.ModifiedBy)
+	s.ErrorIs(loopErr, vcblobstore.ErrAliasLoop)
+
+	aliases, listErr := s.RepoController.repo.ListAliases(s.Ctx)
+	s.NoError(listErr)
+	s.ElementsMatch([]vcblobstore.Alias{{Alias: "legacy", Target: blob.Key}, {Alias: "ancient", Target: "legacy"}}, aliases)
+
+	keys, listKeysErr := s.RepoController.repo.ListBlobKeys(s.Ctx, vcblobstore.ListOptions{})
+	s.NoError(listKeysErr)
+	s.Equal([]string{blob.Key}, keys)
+}
+
+func (s *BlobstoreTestSuite) TestSelfTest() {
+	report := s.RepoController.repo.SelfTest(s.Ctx, "doctor")
+	s.True(report.Healthy)
+	s.Equal(3, len(report.Steps))
+	for _, step := range report.Steps {
+		s.NoError(step.Err)
+	}
+	s.AssertBlobstoreCleanStatus()
+
+	head, headErr := s.RepoController.repo.HeadBlob(s.Ctx, report.ProbeKey)
+	s.NoError(headErr)
+	s.False(head.Exists)
+}
+
+func (s *BlobstoreTestSuite) TestFindDuplicates() {
+	original := createTestBlob("icons/original", "ux")
+	duplicate := CloneBlob(original)
+	duplicate.Key = "backup/original"
+	unique := createTestBlob("icons/unique", "ux")
+	for _, blob := range []vcblobstore.BlobInfo{original, duplicate, unique} {
+		s.NoError(s.RepoController.repo.AddBlob(s.Ctx, blob))
+	}
+
+	groups, findErr := s.RepoController.repo.FindDuplicates(s.Ctx)
+	s.NoError(findErr)
+	s.Len(groups, 1)
+	s.Equal(vcblobstore.DuplicateGroup{
+		SHA256: vcblobstore.ContentSHA256(original.Content),
+		Size:   int64(len(original.Content)),
+		Keys:   []string{duplicate.Key, original.Key},
+	}, groups[0])
+
+	s.NoError(s.RepoController.repo.DeleteBlob(s.Ctx, duplicate.Key, "ux"))
+	groups, findErr = s.RepoController.repo.FindDuplicates(s.Ctx)
+	s.NoError(findErr)
+	s.Empty(groups)
+}
+
+func (s *BlobstoreTestSuite) TestListVersions() {
+	repo := s.RepoController.repo
+	s.NoError(repo.AddBlob(s.Ctx, createTestBlob("changelog/seed", "ux")))
+	fromState, stateErr := repo.GetStateID(s.Ctx)
+	s.NoError(stateErr)
+
+	first := createTestBlob("changelog/first", "ux")
+	second := createTestBlob("changelog/*second_`quoted`", "ux")
+	s.NoError(repo.AddBlob(s.Ctx, first))
+	s.NoError(repo.AddBlob(s.Ctx, second))
+	s.NoError(repo.UpdateBlobMetadata(s.Ctx, first.Key, map[string]string{"owner": "ux"}, "ux"))
+	s.NoError(repo.DeleteBlob(s.Ctx, second.Key, "ux"))
+
+	versions, listErr := repo.ListVersions(s.Ctx, fromState, "")
+	s.NoError(listErr)
+	changes := [][]vcblobstore.KeyChange{}
+	for _, version := range versions {
+		changes = append(changes, version.Changes)
+	}
+	s.Equal([][]vcblobstore.KeyChange{
+		{{Key: first.Key, Operation: vcblobstore.BlobOperationCreate}},
+		{{Key: second.Key, Operation: vcblobstore.BlobOperationCreate}},
+		{{Key: first.Key, Operation: vcblobstore.BlobOperationUpdate}},
+		{{Key: second.Key, Operation: vcblobstore.BlobOperationDelete}},
+	}, changes)
+
+	versions, listErr = repo.ListVersions(s.Ctx, versions[0].Version, versions[2].Version)
+	s.NoError(listErr)
+	s.Len(versions, 2)
+
+	changelog, changelogErr := vcblobstore.GenerateChangelog(s.Ctx, repo, fromState, "", vcblobstore.ChangelogMarkdown)
+	s.NoError(changelogErr)
+	s.Contains(string(changelog), "2 created, 1 updated, 1 deleted")
+	s.Contains(string(changelog), "| changelog/\\*second\\_\\`quoted\\` | delete |")
+}
+
+func (s *BlobstoreTestSuite) TestListChangesBetween() {
+	repo := s.RepoController.repo
+	kept := createTestBlob("statediff/kept", "ux")
+	removed := createTestBlob("statediff/removed", "ux")
+	s.NoError(repo.AddBlob(s.Ctx, kept))
+	s.NoError(repo.AddBlob(s.Ctx, removed))
+	fromState, stateErr := repo.GetStateID(s.Ctx)
+	s.NoError(stateErr)
+
+	transient := createTestBlob("statediff/transient", "ux")
+	s.NoError(repo.AddBlob(s.Ctx, createTestBlob("statediff/added", "ux")))
+	s.NoError(repo.AddBlob(s.Ctx, transient))
+	s.NoError(repo.UpdateBlobMetadata(s.Ctx, kept.Key, map[string]string{"owner": "ux"}, "ux"))
+	s.NoError(repo.DeleteBlob(s.Ctx, removed.Key, "ux"))
+	s.NoError(repo.DeleteBlob(s.Ctx, transient.Key, "ux"))
+	toState, stateErr := repo.GetStateID(s.Ctx)
+	s.NoError(stateErr)
+	s.NoError(repo.AddBlob(s.Ctx, createTestBlob("statediff/later", "ux")))
+
+	changes, listErr := repo.ListChangesBetween(s.Ctx, fromState, toState)
+	s.NoError(listErr)
+	s.Equal([]vcblobstore.KeyChange{
+		{Key: "statediff/added", Operation: vcblobstore.BlobOperationCreate},
+		{Key: kept.Key, Operation: vcblobstore.BlobOperationUpdate},
+		{Key: removed.Key, Operation: vcblobstore.BlobOperationDelete},
+	}, changes)
+
+	changes, listErr = repo.ListChangesBetween(s.Ctx, "", "")
+	s.NoError(listErr)
+	s.Equal([]vcblobstore.KeyChange{
+		{Key: "statediff/added", Operation: vcblobstore.BlobOperationCreate},
+		{Key: kept.Key, Operation: vcblobstore.BlobOperationCreate},
+		{Key: "statediff/later", Operation: vcblobstore.BlobOperationCreate},
+	}, changes)
+}
+
+func (s *BlobstoreTestSuite) TestListChangesSince() {
+	repo := s.RepoController.repo
+	s.NoError(repo.AddBlob(s.Ctx, createTestBlob("feed/first", "ux")))
+
+	feed, feedErr := vcblobstore.ListChangesSince(s.Ctx, repo, "")
+	s.NoError(feedErr)
+	s.Equal([]vcblobstore.KeyChange{{Key: "feed/first", Operation: vcblobstore.BlobOperationCreate}}, feed.Changes)
+
+	s.NoError(repo.AddBlob(s.Ctx, createTestBlob("feed/second", "ux")))
+	s.NoError(repo.DeleteBlob(s.Ctx, "feed/first", "ux"))
+	feed, feedErr = vcblobstore.ListChangesSince(s.Ctx, repo, feed.StateID)
+	s.NoError(feedErr)
+	s.Equal([]vcblobstore.KeyChange{
+		{Key: "feed/first", Operation: vcblobstore.BlobOperationDelete},
+		{Key: "feed/second", Operation: vcblobstore.BlobOperationCreate},
+	}, feed.Changes)
+	currentState, stateErr := repo.GetStateID(s.Ctx)
+	s.NoError(stateErr)
+	s.Equal(currentState, feed.StateID)
+
+	feed, feedErr = vcblobstore.ListChangesSince(s.Ctx, repo, feed.StateID)
+	s.NoError(feedErr)
+	s.Empty(feed.Changes)
+	s.Equal(currentState, feed.StateID)
+}
+
+func (s *BlobstoreTestSuite) TestExportSnapshot() {
+	repo := s.RepoController.repo
+	kept := createTestBlob("snapshot/kept", "ux")
+	changed := createTestBlob("snapshot/changed", "ux")
+	changed.Metadata = map[string]string{"owner": "ux"}
+	s.NoError(repo.AddBlob(s.Ctx, kept))
+	s.NoError(repo.AddBlob(s.Ctx, changed))
+	state, stateErr := repo.GetStateID(s.Ctx)
+	s.NoError(stateErr)
+	s.NoError(repo.AddBlob(s.Ctx, createTestBlob(changed.Key, "ux")))
+	s.NoError(repo.DeleteBlob(s.Ctx, kept.Key, "ux"))
+
+	directory := s.T().TempDir()
+	exported, exportErr := vcblobstore.ExportSnapshot(s.Ctx, repo, state, vcblobstore.DirectorySnapshot(directory))
+	s.NoError(exportErr)
+	s.Equal(state, exported)
+	for _, blob := range []vcblobstore.BlobInfo{kept, changed} {
+		content, readErr := os.ReadFile(filepath.Join(directory, filepath.FromSlash(blob.Key)))
+		s.NoError(readErr)
+		s.Equal(blob.Content, content)
+	}
+
+	var archive bytes.Buffer
+	writer := vcblobstore.NewArchiveSnapshot(&archive)
+	current, exportErr := vcblobstore.ExportSnapshot(s.Ctx, repo, "", writer)
+	s.NoError(exportErr)
+	s.NoError(writer.Close())
+	currentState, stateErr := repo.GetStateID(s.Ctx)
+	s.NoError(stateErr)
+	s.Equal(currentState, current)
+	uncompressed, gzipErr := gzip.NewReader(&archive)
+	s.NoError(gzipErr)
+	names := []string{}
+	tarReader := tar.NewReader(uncompressed)
+	for {
+		header, nextErr := tarReader.Next()
+		if nextErr == io.EOF {
+			break
+		}
+		s.NoError(nextErr)
+		names = append(names, header.Name)
+	}
+	s.Equal([]string{changed.Key}, names)
+}
+
+func (s *BlobstoreTestSuite) TestVersionMetadataChanges() {
+	repo := s.RepoController.repo
+	blob := createTestBlob("touched/blob", "ux")
+	changesOfLastVersion := func() []git.ChangedKey {
+		version, stateErr := repo.GetStateID(s.Ctx)
+		s.NoError(stateErr)
+		meta, metaErr := repo.GetVersionMetadata(s.Ctx, version)
+		s.NoError(metaErr)
+		return meta.Changes
+	}
+
+	s.NoError(repo.AddBlob(s.Ctx, blob))
+	s.Equal([]git.ChangedKey{{Key: blob.Key, Operation: string(vcblobstore.BlobOperationCreate)}}, changesOfLastVersion())
+
+	s.NoError(repo.AddBlob(s.Ctx, createTestBlob(blob.Key, "ux")))
+	s.Equal([]git.ChangedKey{{Key: blob.Key, Operation: string(vcblobstore.BlobOperationUpdate)}}, changesOfLastVersion())
+
+	s.NoError(repo.UpdateBlobMetadata(s.Ctx, blob.Key, map[string]string{"owner": "ux"}, "ux"))
+	s.Equal([]git.ChangedKey{{Key: blob.Key, Operation: string(vcblobstore.BlobOperationUpdate)}}, changesOfLastVersion())
+
+	s.NoError(repo.DeleteBlob(s.Ctx, blob.Key, "ux"))
+	s.Equal([]git.ChangedKey{{Key: blob.Key, Operation: string(vcblobstore.BlobOperationDelete)}}, changesOfLastVersion())
+}
+
+func (s *BlobstoreTestSuite) TestExportAuditLog() {
+	repo := s.RepoController.repo
+	s.NoError(repo.AddBlob(s.Ctx, createTestBlob("audited/first", "ux")))
+	s.NoError(repo.DeleteBlob(s.Ctx, "audited/first", "auditor"))
+	last, stateErr := repo.GetStateID(s.Ctx)
+	s.NoError(stateErr)
+
+	jsonl := bytes.Buffer{}
+	s.NoError(vcblobstore.ExportAuditLog(s.Ctx, repo, time.Time{}, &jsonl, vcblobstore.AuditLogJSONL))
+	lines := strings.Split(strings.TrimSpace(jsonl.String()), "\n")
+	record := vcblobstore.AuditRecord{}
+	s.NoError(json.Unmarshal([]byte(lines[len(lines)-1]), &record))
+	s.Equal(last, record.Version)
+	s.Contains(record.Author, "auditor")
+	s.Equal([]vcblobstore.KeyChange{{Key: "audited/first", Operation: vcblobstore.BlobOperationDelete}}, record.Changes)
+
+	csvLog := bytes.Buffer{}
+	s.NoError(vcblobstore.ExportAuditLog(s.Ctx, repo, time.Time{}, &csvLog, vcblobstore.AuditLogCSV))
+	rows, csvErr := csv.NewReader(&csvLog).ReadAll()
+	s.NoError(csvErr)
+	s.Equal(len(lines)+1, len(rows))
+	s.Equal("version", rows[0][0])
+	s.Equal(last, rows[len(rows)-1][0])
+	s.Equal("delete:audited/first", rows[len(rows)-1][3])
+
+	recent := bytes.Buffer{}
+	s.NoError(vcblobstore.ExportAuditLog(s.Ctx, repo, record.Time, &recent, vcblobstore.AuditLogJSONL))
+	recentLines := strings.Split(strings.TrimSpace(recent.String()), "\n")
+	s.LessOrEqual(len(recentLines), len(lines))
+	s.Equal(lines[len(lines)-1], recentLines[len(recentLines)-1])
+	for _, line := range recentLines {
+		recentRecord := vcblobstore.AuditRecord{}
+		s.NoError(json.Unmarshal([]byte(line), &recentRecord))
+		s.False(recentRecord.Time.Before(record.Time))
+	}
+
+	future := bytes.Buffer{}
+	s.NoError(vcblobstore.ExportAuditLog(s.Ctx, repo, time.Now().Add(time.Hour), &future, vcblobstore.AuditLogJSONL))
+	s.Empty(future.String())
+	s.Error(vcblobstore.ExportAuditLog(s.Ctx, repo, time.Time{}, &future, "xml"))
+}
+
 func (s *BlobstoreTestSuite) TestRemainsConsistentAfterUpdatingBlobFails() {
+	blob := TestData[0]
+	s.NoError(s.RepoController.repo.AddBlob(s.Ctx, blob))
+
+	store := faulty.Wrap(s.RepoController.repo, faulty.FaultPlan{{Operation: "AddBlob", OnCall: 1}})
+	update := blob
+	update.Content = []byte("updated content")
+	s.ErrorIs(store.AddBlob(s.Ctx, update), faulty.ErrInjected)
+
+	content, getErr := store.GetBlob(s.Ctx, blob.Key)
+	s.NoError(getErr)
+	s.Equal(blob.Content, content)
+	s.AssertBlobstoreCleanStatus()
+
+	s.NoError(store.AddBlob(s.Ctx, update))
+	content, getErr = store.GetBlob(s.Ctx, blob.Key)
+	s.NoError(getErr)
+	s.Equal(update.Content, content)
+	s.Equal(2, store.Calls("AddBlob"))
+	s.AssertBlobstoreCleanStatus()
 }
 
 func (s *BlobstoreTestSuite) TestRemainsConsistentAfterDeletingBlobFails() {
+	blob := TestData[0]
+	s.NoError(s.RepoController.repo.AddBlob(s.Ctx, blob))
+
+	store := faulty.Wrap(s.RepoController.repo, faulty.FaultPlan{
+		{Operation: "DeleteBlob", OnCall: 1},
+		{Operation: "DeleteBlob", Latency: 10 * time.Millisecond},
+	})
+	s.ErrorIs(store.DeleteBlob(s.Ctx, blob.Key, blob.ModifiedBy), faulty.ErrInjected)
+
+	content, getErr := store.GetBlob(s.Ctx, blob.Key)
+	s.NoError(getErr)
+	s.Equal(blob.Content, content)
+	s.AssertBlobstoreCleanStatus()
+
+	start := time.Now()
+	s.NoError(store.DeleteBlob(s.Ctx, blob.Key, blob.ModifiedBy))
+	s.GreaterOrEqual(time.Since(start), 10*time.Millisecond)
+	_, getErr = store.GetBlob(s.Ctx, blob.Key)
+	s.ErrorIs(getErr, vcblobstore.ErrBlobNotFound)
+	s.AssertBlobstoreCleanStatus()
 }
 
 var DefaultBlobstoreController = TestBlobstoreController{
@@ -138,16 +859,23 @@ var DefaultBlobstoreController = TestBlobstoreController{
 	},
 }
 
+var JournalBlobstoreController = TestBlobstoreController{
+	repoFactory: func() (TestBlobstoreClient, error) {
+		return NewJournalTestStore(journalTestConfig)
+	},
+}
+
 var gitlabTestConfig gitlab.Config
 
 func BlobstoreProvidersToTest() []TestBlobstoreController {
 	fmt.Printf(">>>>>>>>>>>> LOCAL_GIT_ONLY: %v\n", os.Getenv("LOCAL_GIT_ONLY"))
 	if len(os.Getenv("LOCAL_GIT_ONLY")) > 0 {
-		return []TestBlobstoreController{DefaultBlobstoreController}
+		return []TestBlobstoreController{DefaultBlobstoreController, JournalBlobstoreController}
 	}
 
 	return []TestBlobstoreController{
 		DefaultBlobstoreController,
+		JournalBlobstoreController,
 		{
 			repoFactory: func() (TestBlobstoreClient, error) {
 				repo, createClientErr := NewGitlabTestRepoClient(&gitlabTestConfig)
@@ -159,3 +887,94 @@ func BlobstoreProvidersToTest() []TestBlobstoreController {
 		},
 	}
 }
+
+func (s *BlobstoreTestSuite) TestTypedErrors() {
+	_, err := s.RepoController.repo.GetBlob(s.Ctx, "no-such-blob")
+	s.ErrorIs(err, vcblobstore.ErrBlobNotFound)
+
+	err = s.RepoController.repo.DeleteBlob(s.Ctx, "no-such-blob", "ux")
+	s.ErrorIs(err, vcblobstore.ErrBlobNotFound)
+}
+
+func (s *BlobstoreTestSuite) TestChecksum() {
+	blob := CloneBlob(TestData[0])
+	blob.SHA256 = vcblobstore.ContentSHA256(TestData[1].Content)
+	s.ErrorIs(s.RepoController.repo.AddBlob(s.Ctx, blob), vcblobstore.ErrChecksumMismatch)
+
+	blob.SHA256 = vcblobstore.ContentSHA256(blob.Content)
+	s.NoError(s.RepoController.repo.AddBlob(s.Ctx, blob))
+
+	content, checksum, err := s.RepoController.repo.GetBlobWithChecksum(s.Ctx, blob.Key)
+	s.NoError(err)
+	s.Equal(blob.Content, content)
+	s.Equal(blob.SHA256, checksum)
+}
+
+func (s *BlobstoreTestSuite) TestStoreMetadata() {
+	metadata, err := s.RepoController.repo.GetStoreMetadata(s.Ctx)
+	s.NoError(err)
+	s.Equal(vcblobstore.DefaultStoreMetadata(), metadata)
+
+	metadata.Owner = "ux"
+	metadata.RetentionDefaults = map[string]string{"maxVersions": "10"}
+	metadata.FeatureFlags = map[string]bool{"textMode": true}
+	metadata.Extensions = map[string]json.RawMessage{"layout": json.RawMessage(`{"shards":2}`)}
+	s.NoError(s.RepoController.repo.SetStoreMetadata(s.Ctx, metadata, "ux"))
+
+	stored, err := s.RepoController.repo.GetStoreMetadata(s.Ctx)
+	s.NoError(err)
+	s.Equal(metadata, stored)
+
+	content, encodeErr := vcblobstore.EncodeStoreMetadata(metadata)
+	s.NoError(encodeErr)
+	decoded, decodeErr := vcblobstore.DecodeStoreMetadata(content)
+	s.NoError(decodeErr)
+	s.Equal(metadata, decoded)
+
+	keys, listErr := s.RepoController.repo.ListBlobKeys(s.Ctx, vcblobstore.ListOptions{})
+	s.NoError(listErr)
+	s.Empty(keys)
+}
+
+func (s *BlobstoreTestSuite) TestFastReset() {
+	s.NoError(s.RepoController.repo.FastReset(s.Ctx))
+
+	blob := TestData[0]
+	s.NoError(s.RepoController.repo.AddBlob(s.Ctx, blob))
+	s.NoError(s.RepoController.repo.CreateAlias(s.Ctx, "fast-reset-alias", blob.Key, "ux"))
+
+	s.NoError(s.RepoController.repo.FastReset(s.Ctx))
+
+	keys, listErr := s.RepoController.repo.ListBlobKeys(s.Ctx, vcblobstore.ListOptions{})
+	s.NoError(listErr)
+	s.Empty(keys)
+	aliases, aliasesErr := s.RepoController.repo.ListAliases(s.Ctx)
+	s.NoError(aliasesErr)
+	s.Empty(aliases)
+	s.AssertBlobstoreCleanStatus()
+
+	s.NoError(s.RepoController.repo.AddBlob(s.Ctx, blob))
+}
+
+func (s *BlobstoreTestSuite) TestCreateRepositoryIsIdempotent() {
+	blob := TestData[0]
+	s.NoError(s.RepoController.repo.AddBlob(s.Ctx, blob))
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = s.RepoController.repo.CreateRepository(s.Ctx)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		s.NoError(err)
+	}
+
+	content, getErr := s.RepoController.repo.GetBlob(s.Ctx, blob.Key)
+	s.NoError(getErr)
+	s.Equal(blob.Content, content)
+}
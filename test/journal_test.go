@@ -0,0 +1,205 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"vcblobstore"
+	"vcblobstore/faulty"
+	"vcblobstore/journal"
+
+	"github.com/stretchr/testify/suite"
+)
+
+var journalTestConfig = &journal.Config{
+	Location: filepath.Join(os.Getenv("HOME"), "tmp", "versioned-vcblobstore", "test-journal"),
+}
+
+type journalTestSuite struct {
+	suite.Suite
+	store *journal.Journal
+	ctx   context.Context
+}
+
+func TestJournalTestSuite(t *testing.T) {
+	store, createStoreErr := NewJournalTestStore(journalTestConfig)
+	if createStoreErr != nil {
+		panic(createStoreErr)
+	}
+	suite.Run(t, &journalTestSuite{store: store})
+}
+
+func (testSuite *journalTestSuite) BeforeTest(suiteName string, testName string) {
+	testSuite.ctx = context.Background()
+	if resetErr := testSuite.store.ResetRepository(testSuite.ctx); resetErr != nil {
+		panic(resetErr)
+	}
+}
+
+func (testSuite *journalTestSuite) TestReopen() {
+	blob := createTestBlob("icons/journal", "ux")
+	testSuite.NoError(testSuite.store.AddBlob(testSuite.ctx, blob))
+	version, versionErr := testSuite.store.GetStateID(testSuite.ctx)
+	testSuite.NoError(versionErr)
+
+	reopened, reopenErr := NewJournalTestStore(journalTestConfig)
+	testSuite.NoError(reopenErr)
+	content, getErr := reopened.GetBlob(testSuite.ctx, blob.Key)
+	testSuite.NoError(getErr)
+	testSuite.Equal(blob.Content, content)
+	reopenedVersion, reopenedVersionErr := reopened.GetStateID(testSuite.ctx)
+	testSuite.NoError(reopenedVersionErr)
+	testSuite.Equal(version, reopenedVersion)
+}
+
+func (testSuite *journalTestSuite) TestInterruptedAppend() {
+	blob := createTestBlob("icons/journal", "ux")
+	testSuite.NoError(testSuite.store.AddBlob(testSuite.ctx, blob))
+
+	journalFile, openErr := os.OpenFile(filepath.Join(journalTestConfig.Location, "journal.jsonl"), os.O_APPEND|os.O_WRONLY, 0600)
+	testSuite.NoError(openErr)
+	_, writeErr := journalFile.WriteString(`{"version":"torn`)
+	testSuite.NoError(writeErr)
+	testSuite.NoError(journalFile.Close())
+
+	reopened, reopenErr := NewJournalTestStore(journalTestConfig)
+	testSuite.NoError(reopenErr)
+	clean, statusErr := reopened.CheckStatus()
+	testSuite.NoError(statusErr)
+	testSuite.False(clean)
+
+	otherBlob := createTestBlob("icons/other", "ux")
+	testSuite.NoError(reopened.AddBlob(testSuite.ctx, otherBlob))
+	clean, statusErr = reopened.CheckStatus()
+	testSuite.NoError(statusErr)
+	testSuite.True(clean)
+
+	keys, listErr := reopened.ListBlobKeys(testSuite.ctx, vcblobstore.ListOptions{})
+	testSuite.NoError(listErr)
+	testSuite.Equal([]string{blob.Key, otherBlob.Key}, keys)
+}
+
+func (testSuite *journalTestSuite) TestConcurrentInstances() {
+	first := createTestBlob("icons/first", "ux")
+	testSuite.NoError(testSuite.store.AddBlob(testSuite.ctx, first))
+
+	other, otherErr := NewJournalTestStore(journalTestConfig)
+	testSuite.NoError(otherErr)
+	_, getErr := other.GetBlob(testSuite.ctx, first.Key)
+	testSuite.NoError(getErr)
+	second := createTestBlob("icons/second", "ux")
+	testSuite.NoError(testSuite.store.AddBlob(testSuite.ctx, second))
+
+	// The other instance picks up the entry appended meanwhile instead of overwriting it
+	third := createTestBlob("icons/third", "ux")
+	testSuite.NoError(other.AddBlob(testSuite.ctx, third))
+	reopened, reopenErr := NewJournalTestStore(journalTestConfig)
+	testSuite.NoError(reopenErr)
+	keys, listErr := reopened.ListBlobKeys(testSuite.ctx, vcblobstore.ListOptions{})
+	testSuite.NoError(listErr)
+	testSuite.Equal([]string{first.Key, second.Key, third.Key}, keys)
+	clean, statusErr := reopened.CheckStatus()
+	testSuite.NoError(statusErr)
+	testSuite.True(clean)
+}
+
+func (testSuite *journalTestSuite) TestReadsEntriesOfOtherInstances() {
+	first := createTestBlob("icons/first", "ux")
+	testSuite.NoError(testSuite.store.AddBlob(testSuite.ctx, first))
+	reader, readerErr := NewJournalTestStore(journalTestConfig)
+	testSuite.NoError(readerErr)
+	keys, listErr := reader.ListBlobKeys(testSuite.ctx, vcblobstore.ListOptions{})
+	testSuite.NoError(listErr)
+	testSuite.Equal([]string{first.Key}, keys)
+	_, metadataErr := reader.GetStoreMetadata(testSuite.ctx)
+	testSuite.NoError(metadataErr)
+
+	// The instance which only reads sees the entries the other one appends meanwhile
+	second := createTestBlob("icons/second", "ux")
+	testSuite.NoError(testSuite.store.AddBlob(testSuite.ctx, second))
+	metadata := vcblobstore.DefaultStoreMetadata()
+	metadata.Owner = "other-instance"
+	testSuite.NoError(testSuite.store.SetStoreMetadata(testSuite.ctx, metadata, "ux"))
+	keys, listErr = reader.ListBlobKeys(testSuite.ctx, vcblobstore.ListOptions{})
+	testSuite.NoError(listErr)
+	testSuite.Equal([]string{first.Key, second.Key}, keys)
+	content, getErr := reader.GetBlob(testSuite.ctx, second.Key)
+	testSuite.NoError(getErr)
+	testSuite.Equal(second.Content, content)
+	state, stateErr := testSuite.store.GetStateID(testSuite.ctx)
+	testSuite.NoError(stateErr)
+	readerState, readerStateErr := reader.GetStateID(testSuite.ctx)
+	testSuite.NoError(readerStateErr)
+	testSuite.Equal(state, readerState)
+	readMetadata, metadataErr := reader.GetStoreMetadata(testSuite.ctx)
+	testSuite.NoError(metadataErr)
+	testSuite.Equal(metadata.Owner, readMetadata.Owner)
+}
+
+func (testSuite *journalTestSuite) TestRepoNotFound() {
+	testSuite.NoError(testSuite.store.DeleteRepository(testSuite.ctx))
+	_, err := testSuite.store.GetBlob(testSuite.ctx, "anything")
+	testSuite.ErrorIs(err, vcblobstore.ErrRepoNotFound)
+}
+
+func (testSuite *journalTestSuite) TestRejectsSelectedBranch() {
+	blob := createTestBlob("icons/journal", "ux")
+	testSuite.NoError(testSuite.store.AddBlob(testSuite.ctx, blob))
+
+	staging := vcblobstore.WithBranch(testSuite.ctx, "staging")
+	staged := createTestBlob(blob.Key, "ux")
+	testSuite.ErrorIs(testSuite.store.AddBlob(staging, staged), vcblobstore.ErrBranchesUnsupported)
+	testSuite.ErrorIs(testSuite.store.AddBlob(staging, staged), errors.ErrUnsupported)
+	_, getErr := testSuite.store.GetBlob(staging, blob.Key)
+	testSuite.ErrorIs(getErr, vcblobstore.ErrBranchesUnsupported)
+
+	content, getErr := testSuite.store.GetBlob(testSuite.ctx, blob.Key)
+	testSuite.NoError(getErr)
+	testSuite.Equal(blob.Content, content)
+}
+
+func (testSuite *journalTestSuite) TestLoadGenerator() {
+	report, runErr := vcblobstore.RunLoad(testSuite.ctx, testSuite.store, vcblobstore.LoadProfile{
+		Operations:   40,
+		Concurrency:  4,
+		Mix:          map[vcblobstore.LoadOperation]int{vcblobstore.LoadOperationRead: 3, vcblobstore.LoadOperationWrite: 1},
+		KeyCount:     8,
+		KeyPrefix:    "load/",
+		Distribution: vcblobstore.KeyDistributionZipf,
+		MinBlobSize:  16,
+		MaxBlobSize:  64,
+		Preload:      true,
+		ModifiedBy:   "loadgen",
+	})
+	testSuite.NoError(runErr)
+	testSuite.Equal(40, report.Total)
+	testSuite.Equal(0, report.Errors)
+	testSuite.Len(report.Operations, 2)
+	testSuite.Equal(vcblobstore.LoadOperationRead, report.Operations[0].Operation)
+	testSuite.Equal(0, report.Operations[0].Misses)
+	testSuite.LessOrEqual(report.Operations[0].P50, report.Operations[0].P99)
+
+	// The operations still running when the run ends aren't errors
+	slowStore := faulty.Wrap(testSuite.store, faulty.FaultPlan{{Operation: "GetBlob", Latency: 40 * time.Millisecond}})
+	report, runErr = vcblobstore.RunLoad(testSuite.ctx, slowStore, vcblobstore.LoadProfile{
+		Duration:    100 * time.Millisecond,
+		Concurrency: 2,
+		Mix:         map[vcblobstore.LoadOperation]int{vcblobstore.LoadOperationRead: 1},
+		KeyCount:    8,
+		KeyPrefix:   "load/",
+	})
+	testSuite.NoError(runErr)
+	testSuite.Less(report.Total, slowStore.Calls("GetBlob"))
+	testSuite.Equal(0, report.Errors)
+
+	_, invalidErr := vcblobstore.RunLoad(testSuite.ctx, testSuite.store, vcblobstore.LoadProfile{KeyCount: 1})
+	testSuite.Error(invalidErr)
+}
+
+func NewJournalTestStore(conf *journal.Config) (*journal.Journal, error) {
+	testLogger := createTestLogger()
+	return journal.NewJournalStore(conf, &testLogger), nil
+}
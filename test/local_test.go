@@ -1,13 +1,26 @@
 package test
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
 	"context"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
+	"vcblobstore"
+	"vcblobstore/composite"
+	"vcblobstore/faulty"
 	"vcblobstore/git"
 	"vcblobstore/git/local"
+	"vcblobstore/journal"
+	"vcblobstore/mirror"
 
 	"github.com/stretchr/testify/suite"
 )
@@ -65,7 +78,9 @@ func (testSuite *localGitRepoTestSuite) TestLocationHasRepo() {
 	if err != nil {
 		panic(err)
 	}
-	testSuite.Equal(true, testSuite.gitRepoClient.LocationHasRepo())
+	hasRepo, probeErr := testSuite.gitRepoClient.LocationHasRepo()
+	testSuite.NoError(probeErr)
+	testSuite.True(hasRepo)
 }
 
 func (testSuite *localGitRepoTestSuite) TestParseCommitMetadata() {
@@ -78,26 +93,1759 @@ CommitDate: 2022-10-31T15:30:17+0100
 	
     [DEV] distributed git access`
 
-	authorDate, authorDateErr := time.Parse(time.RFC3339, "2022-10-09T13:42:12+02:00")
-	testSuite.Nil(authorDateErr)
-	commitDate, commitDateErr := time.Parse(time.RFC3339, "2022-10-31T15:30:17+01:00")
-	testSuite.Nil(commitDateErr)
+	authorDate := time.Date(2022, 10, 9, 13, 42, 12, 0, time.FixedZone("+02:00", 2*3600))
+	commitDate := time.Date(2022, 10, 31, 15, 30, 17, 0, time.FixedZone("+01:00", 3600))
 
 	expectedOutput := git.CommitMetadata{
-		Author:     "Kovács, Péter <peter.dunay.kovacs@gmail.com>",
-		AuthorDate: authorDate,
-		Commit:     "Péter Kovács <peter.dunay.kovacs@gmail.com>",
-		CommitDate: commitDate,
-		Message:    "[DEV] distributed git access",
+		Author:       "Kovács, Péter <peter.dunay.kovacs@gmail.com>",
+		AuthorDate:   authorDate,
+		AuthorOffset: 2 * 3600,
+		Commit:       "Péter Kovács <peter.dunay.kovacs@gmail.com>",
+		CommitDate:   commitDate,
+		CommitOffset: 3600,
+		Message:      "[DEV] distributed git access",
 	}
 
 	commitMetadata, parseErr := git.ParseLocalCommitMetadata(testInput)
 	testSuite.Nil(parseErr)
 	testSuite.Equal(expectedOutput, commitMetadata)
+
+	// The original offsets survive converting the dates to another zone
+	commitMetadata.AuthorDate = commitMetadata.AuthorDate.UTC()
+	testSuite.Equal("2022-10-09 13:42:12 +02:00", commitMetadata.FormatAuthorDate(git.DisplayTimeLayout))
+	testSuite.Equal("2022-10-31 15:30:17 +01:00", commitMetadata.FormatCommitDate(git.DisplayTimeLayout))
+}
+
+func (testSuite *localGitRepoTestSuite) TestHierarchicalKeys() {
+	blob := createTestBlob("icons/small/metro-zazie", "ux")
+	testSuite.NoError(testSuite.gitRepoClient.AddBlob(testSuite.ctx, blob))
+	testSuite.NoError(testSuite.gitRepoClient.AddBlob(testSuite.ctx, createTestBlob("icons/README", "ux")))
+
+	content, getErr := testSuite.gitRepoClient.GetBlob(testSuite.ctx, blob.Key)
+	testSuite.NoError(getErr)
+	testSuite.Equal(blob.Content, content)
+
+	testSuite.NoError(testSuite.gitRepoClient.DeleteBlob(testSuite.ctx, blob.Key, "ux"))
+	_, statErr := os.Stat(filepath.Join(localTestConfig.Location, "icons", "small"))
+	testSuite.True(os.IsNotExist(statErr))
+	_, statErr = os.Stat(filepath.Join(localTestConfig.Location, "icons"))
+	testSuite.NoError(statErr)
+
+	internalKey := vcblobstore.DefaultNaming.AttributeIndexKey()
+	for _, invalidKey := range []string{"../outside", "icons/../../outside", "/etc/passwd", ".git/config", "icons//small", "icons/", internalKey} {
+		addErr := testSuite.gitRepoClient.AddBlob(testSuite.ctx, createTestBlob(invalidKey, "ux"))
+		testSuite.ErrorIs(addErr, vcblobstore.ErrInvalidKey, invalidKey)
+	}
+	testSuite.ErrorIs(testSuite.gitRepoClient.DeleteBlob(testSuite.ctx, internalKey, "ux"), vcblobstore.ErrInvalidKey)
+	testSuite.ErrorIs(testSuite.gitRepoClient.RenameBlob(testSuite.ctx, "icons/README", internalKey, "ux"), vcblobstore.ErrInvalidKey)
+}
+
+func (testSuite *localGitRepoTestSuite) TestTextModeNormalization() {
+	repo, createRepoErr := NewLocalGitTestRepo(&local.Config{
+		Location: localTestConfig.Location,
+		TextMode: vcblobstore.TextMode{
+			{Pattern: "configs/*.yaml", NormalizeLineEndings: true, RequireUTF8: true},
+		},
+	})
+	testSuite.NoError(createRepoErr)
+
+	textBlob := vcblobstore.BlobInfo{Key: "configs/app.yaml", Content: []byte("name: zazie\r\nline: metro\r\n"), ModifiedBy: "ux"}
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, textBlob))
+	content, getErr := repo.GetBlob(testSuite.ctx, textBlob.Key)
+	testSuite.NoError(getErr)
+	testSuite.Equal("name: zazie\nline: metro\n", string(content))
+
+	invalidBlob := vcblobstore.BlobInfo{Key: "configs/broken.yaml", Content: []byte{0xff, 0xfe, '\r', '\n'}, ModifiedBy: "ux"}
+	testSuite.ErrorIs(repo.AddBlob(testSuite.ctx, invalidBlob), vcblobstore.ErrInvalidTextContent)
+
+	binaryBlob := vcblobstore.BlobInfo{Key: "configs/logo.png", Content: []byte{0xff, '\r', '\n'}, ModifiedBy: "ux"}
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, binaryBlob))
+	content, getErr = repo.GetBlob(testSuite.ctx, binaryBlob.Key)
+	testSuite.NoError(getErr)
+	testSuite.Equal(binaryBlob.Content, content)
+}
+
+func (testSuite *localGitRepoTestSuite) TestRepoNotFound() {
+	testSuite.NoError(testSuite.gitRepoClient.DeleteRepository(testSuite.ctx))
+	_, err := testSuite.gitRepoClient.ListBlobKeys(testSuite.ctx, vcblobstore.ListOptions{})
+	testSuite.ErrorIs(err, vcblobstore.ErrRepoNotFound)
+}
+
+func (testSuite *localGitRepoTestSuite) TestExternalStorage() {
+	objectStore := vcblobstore.DirectoryObjectStore{Root: testSuite.T().TempDir()}
+	repo, createRepoErr := NewLocalGitTestRepo(&local.Config{
+		Location:        localTestConfig.Location,
+		ExternalStorage: &vcblobstore.ExternalStorage{Store: objectStore, Threshold: 16},
+	})
+	testSuite.NoError(createRepoErr)
+
+	largeBlob := vcblobstore.BlobInfo{Key: "large", Content: randomBytes(64), ModifiedBy: "ux"}
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, largeBlob))
+	committed, readErr := os.ReadFile(filepath.Join(localTestConfig.Location, largeBlob.Key))
+	testSuite.NoError(readErr)
+	testSuite.True(vcblobstore.IsExternalPointer(committed))
+	content, getErr := repo.GetBlob(testSuite.ctx, largeBlob.Key)
+	testSuite.NoError(getErr)
+	testSuite.Equal(largeBlob.Content, content)
+
+	smallBlob := vcblobstore.BlobInfo{Key: "small", Content: []byte("tiny"), ModifiedBy: "ux"}
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, smallBlob))
+	committed, readErr = os.ReadFile(filepath.Join(localTestConfig.Location, smallBlob.Key))
+	testSuite.NoError(readErr)
+	testSuite.Equal(smallBlob.Content, committed)
+
+	updatedBlob := vcblobstore.BlobInfo{Key: largeBlob.Key, Content: randomBytes(64), ModifiedBy: "ux"}
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, updatedBlob))
+	testSuite.NoError(objectStore.Put(testSuite.ctx, "stray", []byte("stray")))
+
+	deleted, gcErr := repo.CollectExternalGarbage(testSuite.ctx)
+	testSuite.NoError(gcErr)
+	testSuite.Equal([]string{"stray"}, deleted)
+	objects, listErr := objectStore.List(testSuite.ctx)
+	testSuite.NoError(listErr)
+	testSuite.Len(objects, 2)
+
+	bundle := bytes.Buffer{}
+	testSuite.NoError(repo.ExportHistory(testSuite.ctx, []string{largeBlob.Key}, &bundle))
+	imported, createStoreErr := NewJournalTestStore(&journal.Config{Location: filepath.Join(testSuite.T().TempDir(), "imported")})
+	testSuite.NoError(createStoreErr)
+	testSuite.NoError(imported.CreateRepository(testSuite.ctx))
+	testSuite.NoError(imported.ImportHistory(testSuite.ctx, &bundle))
+	content, getErr = imported.GetBlob(testSuite.ctx, largeBlob.Key)
+	testSuite.NoError(getErr)
+	testSuite.Equal(updatedBlob.Content, content)
+}
+
+func (testSuite *localGitRepoTestSuite) TestLFS() {
+	repo, createRepoErr := NewLocalGitTestRepo(&local.Config{
+		Location: localTestConfig.Location,
+		LFS:      &vcblobstore.LFS{Threshold: 16, Patterns: []string{"lfs/*.psd"}},
+	})
+	testSuite.NoError(createRepoErr)
+
+	largeBlob := vcblobstore.BlobInfo{Key: "lfs/large", Content: randomBytes(64), ModifiedBy: "ux"}
+	patternBlob := vcblobstore.BlobInfo{Key: "lfs/logo.psd", Content: randomBytes(8), ModifiedBy: "ux"}
+	smallBlob := vcblobstore.BlobInfo{Key: "lfs/small", Content: randomBytes(8), ModifiedBy: "ux"}
+	for _, blob := range []vcblobstore.BlobInfo{largeBlob, patternBlob, smallBlob} {
+		testSuite.NoError(repo.AddBlob(testSuite.ctx, blob))
+		content, getErr := repo.GetBlob(testSuite.ctx, blob.Key)
+		testSuite.NoError(getErr)
+		testSuite.Equal(blob.Content, content)
+	}
+
+	committed, readErr := os.ReadFile(filepath.Join(localTestConfig.Location, largeBlob.Key))
+	testSuite.NoError(readErr)
+	pointer, isPointer := vcblobstore.ParseLFSPointer(committed)
+	testSuite.True(isPointer)
+	testSuite.Equal(vcblobstore.LFSPointer{OID: vcblobstore.ContentSHA256(largeBlob.Content), Size: 64}, pointer)
+	for _, oid := range []string{"../../../../" + strings.Repeat("a", 52), strings.ToUpper(pointer.OID), pointer.OID[:63]} {
+		_, isPointer = vcblobstore.ParseLFSPointer(vcblobstore.LFSPointer{OID: oid, Size: 64}.Encode())
+		testSuite.False(isPointer, oid)
+	}
+	committed, readErr = os.ReadFile(filepath.Join(localTestConfig.Location, smallBlob.Key))
+	testSuite.NoError(readErr)
+	testSuite.Equal(smallBlob.Content, committed)
+
+	attributes, readErr := os.ReadFile(filepath.Join(localTestConfig.Location, ".git", "info", "attributes"))
+	testSuite.NoError(readErr)
+	testSuite.Contains(string(attributes), "/lfs/large filter=lfs diff=lfs merge=lfs -text\n")
+	testSuite.Contains(string(attributes), "/lfs/logo.psd filter=lfs diff=lfs merge=lfs -text\n")
+	testSuite.NotContains(string(attributes), "/lfs/small ")
+
+	version, versionErr := repo.GetVersionFor(testSuite.ctx, largeBlob.Key)
+	testSuite.NoError(versionErr)
+	content, getErr := repo.GetBlobAtVersion(testSuite.ctx, largeBlob.Key, version)
+	testSuite.NoError(getErr)
+	testSuite.Equal(largeBlob.Content, content)
+
+	bundle := bytes.Buffer{}
+	testSuite.NoError(repo.ExportHistory(testSuite.ctx, []string{largeBlob.Key}, &bundle))
+	importedLocation := filepath.Join(testSuite.T().TempDir(), "imported")
+	imported, createRepoErr := NewLocalGitTestRepo(&local.Config{Location: importedLocation, LFS: &vcblobstore.LFS{Threshold: 16}})
+	testSuite.NoError(createRepoErr)
+	testSuite.NoError(imported.CreateRepository(testSuite.ctx))
+	testSuite.NoError(imported.ImportHistory(testSuite.ctx, &bundle))
+	content, getErr = imported.GetBlob(testSuite.ctx, largeBlob.Key)
+	testSuite.NoError(getErr)
+	testSuite.Equal(largeBlob.Content, content)
+	committed, readErr = os.ReadFile(filepath.Join(importedLocation, largeBlob.Key))
+	testSuite.NoError(readErr)
+	testSuite.Equal(pointer.Encode(), committed)
+
+	oid := pointer.OID
+	testSuite.NoError(os.Remove(filepath.Join(localTestConfig.Location, ".git", "lfs", "objects", oid[0:2], oid[2:4], oid)))
+	_, getErr = repo.GetBlob(testSuite.ctx, largeBlob.Key)
+	testSuite.ErrorIs(getErr, vcblobstore.ErrLFSObjectMissing)
+}
+
+func (testSuite *localGitRepoTestSuite) TestSkipCI() {
+	repo, createRepoErr := NewLocalGitTestRepo(&local.Config{
+		Location: localTestConfig.Location,
+		SkipCI:   true,
+	})
+	testSuite.NoError(createRepoErr)
+
+	skipped := vcblobstore.BlobInfo{Key: "ci/skipped", Content: randomBytes(8), ModifiedBy: "ux"}
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, skipped))
+	triggering := vcblobstore.BlobInfo{Key: "ci/triggering", Content: randomBytes(8), ModifiedBy: "ux"}
+	testSuite.NoError(repo.AddBlob(vcblobstore.WithSkipCI(testSuite.ctx, false), triggering))
+
+	for key, marked := range map[string]bool{skipped.Key: true, triggering.Key: false} {
+		version, versionErr := repo.GetVersionFor(testSuite.ctx, key)
+		testSuite.NoError(versionErr)
+		metadata, metadataErr := repo.GetVersionMetadata(testSuite.ctx, version)
+		testSuite.NoError(metadataErr)
+		testSuite.Equal(marked, strings.Contains(metadata.Message, vcblobstore.SkipCIMarker), key)
+	}
+}
+
+func (testSuite *localGitRepoTestSuite) TestExportAll() {
+	repo, createRepoErr := NewLocalGitTestRepo(&local.Config{Location: localTestConfig.Location})
+	testSuite.NoError(createRepoErr)
+	blob := vcblobstore.BlobInfo{Key: "archive/blob", Content: randomBytes(16), ModifiedBy: "ux"}
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, blob))
+
+	var archive bytes.Buffer
+	testSuite.NoError(repo.ExportAll(testSuite.ctx, &archive))
+	uncompressed, gzipErr := gzip.NewReader(&archive)
+	testSuite.NoError(gzipErr)
+	files := map[string][]byte{}
+	tarReader := tar.NewReader(uncompressed)
+	for {
+		header, nextErr := tarReader.Next()
+		if nextErr == io.EOF {
+			break
+		}
+		testSuite.NoError(nextErr)
+		content, readErr := io.ReadAll(tarReader)
+		testSuite.NoError(readErr)
+		files[header.Name] = content
+	}
+	testSuite.Equal(blob.Content, files[blob.Key])
+
+	testSuite.Error(repo.ExportAllAt(testSuite.ctx, "--output=/tmp/archive", &archive))
+}
+
+func (testSuite *localGitRepoTestSuite) TestCommitterIdentity() {
+	repo, createRepoErr := NewLocalGitTestRepo(&local.Config{
+		Location:       localTestConfig.Location,
+		CommitterName:  "Blob Service",
+		CommitterEmail: "blobs@example.com",
+	})
+	testSuite.NoError(createRepoErr)
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, vcblobstore.BlobInfo{Key: "committer/blob", Content: randomBytes(8), ModifiedBy: "ux"}))
+
+	out, logErr := repo.ExecuteGitCommand(testSuite.ctx, []string{"log", "-1", "--format=%an|%cn <%ce>"})
+	testSuite.NoError(logErr)
+	testSuite.Equal("ux|Blob Service <blobs@example.com>", strings.TrimSpace(out))
+}
+
+func (testSuite *localGitRepoTestSuite) TestHotKeys() {
+	repo, createRepoErr := NewLocalGitTestRepo(&local.Config{
+		Location:      localTestConfig.Location,
+		AccessTracker: vcblobstore.NewAccessTracker(),
+	})
+	testSuite.NoError(createRepoErr)
+
+	cold := createTestBlob("cold", "ux")
+	hot := createTestBlob("hot", "ux")
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, cold))
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, hot))
+	for i := 0; i < 3; i++ {
+		_, getErr := repo.GetBlob(testSuite.ctx, hot.Key)
+		testSuite.NoError(getErr)
+	}
+
+	testSuite.Equal([]vcblobstore.AccessStats{{Key: hot.Key, Reads: 3, Writes: 1}}, repo.HotKeys(testSuite.ctx, 1))
+	testSuite.Len(repo.HotKeys(testSuite.ctx, 0), 2)
+	testSuite.Empty(testSuite.gitRepoClient.HotKeys(testSuite.ctx, 0))
+}
+
+func (testSuite *localGitRepoTestSuite) TestCancelledContext() {
+	ctx, cancel := context.WithCancel(testSuite.ctx)
+	cancel()
+
+	blob := createTestBlob("cancelled", "ux")
+	testSuite.ErrorIs(testSuite.gitRepoClient.AddBlob(ctx, blob), context.Canceled)
+	_, listErr := testSuite.gitRepoClient.ListBlobKeys(ctx, vcblobstore.ListOptions{})
+	testSuite.ErrorIs(listErr, context.Canceled)
+
+	keys, listErr := testSuite.gitRepoClient.ListBlobKeys(testSuite.ctx, vcblobstore.ListOptions{})
+	testSuite.NoError(listErr)
+	testSuite.NotContains(keys, blob.Key)
+}
+
+func (testSuite *localGitRepoTestSuite) TestReproducibleCommits() {
+	blobs := []vcblobstore.BlobInfo{createTestBlob("reproducible/a", "ux"), createTestBlob("reproducible/b", "ux")}
+
+	stateIDs := []string{}
+	for i := 0; i < 2; i++ {
+		repo, createRepoErr := NewLocalGitTestRepo(&local.Config{
+			Location:     filepath.Join(testSuite.T().TempDir(), "repo"),
+			Reproducible: true,
+		})
+		testSuite.NoError(createRepoErr)
+		testSuite.NoError(repo.CreateRepository(testSuite.ctx))
+		for _, blob := range blobs {
+			testSuite.NoError(repo.AddBlob(testSuite.ctx, blob))
+		}
+		stateID, stateErr := repo.GetStateID(testSuite.ctx)
+		testSuite.NoError(stateErr)
+		stateIDs = append(stateIDs, stateID)
+	}
+
+	testSuite.Equal(stateIDs[0], stateIDs[1])
+}
+
+func (testSuite *localGitRepoTestSuite) TestNamingStrategy() {
+	naming := vcblobstore.PrefixNaming{Root: "_system/"}
+	repo, createRepoErr := NewLocalGitTestRepo(&local.Config{
+		Location: localTestConfig.Location,
+		Naming:   naming,
+	})
+	testSuite.NoError(createRepoErr)
+
+	blob := createTestBlob("named", "ux")
+	blob.Metadata = map[string]string{"team": "ux"}
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, blob))
+	testSuite.NoError(repo.CreateAlias(testSuite.ctx, "named-alias", blob.Key, "ux"))
+
+	_, statErr := os.Stat(filepath.Join(localTestConfig.Location, naming.MetadataSidecarKey(blob.Key)))
+	testSuite.NoError(statErr)
+	_, statErr = os.Stat(filepath.Join(localTestConfig.Location, naming.AliasPointerKey("named-alias")))
+	testSuite.NoError(statErr)
+
+	keys, listErr := repo.ListBlobKeys(testSuite.ctx, vcblobstore.ListOptions{})
+	testSuite.NoError(listErr)
+	testSuite.Contains(keys, blob.Key)
+	for _, key := range keys {
+		testSuite.False(naming.IsInternalKey(key), key)
+	}
+	aliases, aliasesErr := repo.ListAliases(testSuite.ctx)
+	testSuite.NoError(aliasesErr)
+	testSuite.Contains(aliases, vcblobstore.Alias{Alias: "named-alias", Target: blob.Key})
+}
+
+func (testSuite *localGitRepoTestSuite) TestSLOTracker() {
+	tracker, trackerErr := vcblobstore.NewSLOTracker(testSuite.gitRepoClient,
+		vcblobstore.SLOObjective{Operation: "GetBlob", Target: 0.99, LatencyThreshold: time.Minute},
+		vcblobstore.SLOObjective{Operation: "AddBlob", Target: 0.9},
+	)
+	testSuite.NoError(trackerErr)
+
+	blob := createTestBlob("slo", "ux")
+	testSuite.NoError(tracker.AddBlob(testSuite.ctx, blob))
+	testSuite.Error(tracker.AddBlob(testSuite.ctx, createTestBlob("../outside", "ux")))
+	_, getErr := tracker.GetBlob(testSuite.ctx, blob.Key)
+	testSuite.NoError(getErr)
+	_, getErr = tracker.GetBlob(testSuite.ctx, "no-such-blob")
+	testSuite.ErrorIs(getErr, vcblobstore.ErrBlobNotFound)
+
+	statuses := tracker.Status()
+	testSuite.Len(statuses, 2)
+	testSuite.Equal("AddBlob", statuses[0].Objective.Operation)
+	testSuite.Equal(int64(1), statuses[0].Total)
+	testSuite.True(statuses[0].Healthy)
+	testSuite.Equal("GetBlob", statuses[1].Objective.Operation)
+	testSuite.Equal(int64(1), statuses[1].Total)
+	testSuite.Equal(float64(1), statuses[1].SuccessRatio)
+	testSuite.Equal(float64(0), statuses[1].BurnRate)
+
+	tracker.Reset()
+	testSuite.Equal(int64(0), tracker.Status()[1].Total)
+
+	_, trackerErr = vcblobstore.NewSLOTracker(testSuite.gitRepoClient, vcblobstore.SLOObjective{Operation: "GetBlobs", Target: 0.99})
+	testSuite.ErrorIs(trackerErr, vcblobstore.ErrInvalidObjective)
+	_, trackerErr = vcblobstore.NewSLOTracker(testSuite.gitRepoClient, vcblobstore.SLOObjective{Operation: "GetBlob", Target: 99})
+	testSuite.ErrorIs(trackerErr, vcblobstore.ErrInvalidObjective)
+
+	windowed, trackerErr := vcblobstore.NewSLOTracker(testSuite.gitRepoClient,
+		vcblobstore.SLOObjective{Operation: "GetBlob", Target: 0.5, Window: 300 * time.Millisecond},
+		vcblobstore.SLOObjective{Operation: "HeadBlob", Target: 0.5, LatencyThreshold: time.Nanosecond, Window: 300 * time.Millisecond})
+	testSuite.NoError(trackerErr)
+	_, headErr := windowed.HeadBlob(testSuite.ctx, blob.Key)
+	testSuite.NoError(headErr)
+	testSuite.Equal(float64(2), windowed.Status()[1].BurnRate)
+	time.Sleep(400 * time.Millisecond)
+	_, getErr = windowed.GetBlob(testSuite.ctx, blob.Key)
+	testSuite.NoError(getErr)
+	// The slow call slid out of the window
+	statuses = windowed.Status()
+	testSuite.Equal(int64(1), statuses[0].Total)
+	testSuite.Equal(int64(0), statuses[1].Total)
+	testSuite.Equal(float64(0), statuses[1].BurnRate)
+	testSuite.True(statuses[1].Healthy)
+}
+
+type redactionPrivilegeKey struct{}
+
+func (testSuite *localGitRepoTestSuite) TestRedactor() {
+	redactor := vcblobstore.NewRedactor(testSuite.gitRepoClient,
+		func(ctx context.Context, permission string) bool {
+			return ctx.Value(redactionPrivilegeKey{}) == permission
+		},
+		"read-secrets",
+		vcblobstore.RedactionRule{KeyPattern: "redaction/*.json", JSONPaths: []string{"users.*.password"}},
+		vcblobstore.RedactionRule{Patterns: []*regexp.Regexp{regexp.MustCompile(`token-[0-9]+`)}},
+	)
+
+	blob := vcblobstore.BlobInfo{
+		Key:        "redaction/users.json",
+		Content:    []byte(`{"users":[{"name":"jane","password":"secret"}],"note":"token-123"}`),
+		ModifiedBy: "ux",
+	}
+	testSuite.NoError(testSuite.gitRepoClient.AddBlob(testSuite.ctx, blob))
+
+	redacted, getErr := redactor.GetBlob(testSuite.ctx, blob.Key)
+	testSuite.NoError(getErr)
+	testSuite.JSONEq(`{"users":[{"name":"jane","password":"***"}],"note":"***"}`, string(redacted))
+
+	content, checksum, checksumErr := redactor.GetBlobWithChecksum(testSuite.ctx, blob.Key)
+	testSuite.NoError(checksumErr)
+	testSuite.Equal(vcblobstore.ContentSHA256(content), checksum)
+
+	privilegedCtx := context.WithValue(testSuite.ctx, redactionPrivilegeKey{}, "read-secrets")
+	original, privilegedErr := redactor.GetBlob(privilegedCtx, blob.Key)
+	testSuite.NoError(privilegedErr)
+	testSuite.Equal(blob.Content, original)
+
+	// The history bundle carries the content of every version
+	bundle := bytes.Buffer{}
+	testSuite.NoError(redactor.ExportHistory(testSuite.ctx, []string{blob.Key}, &bundle))
+	records := []vcblobstore.HistoryRecord{}
+	testSuite.NoError(vcblobstore.ReadHistory(&bundle, func(record vcblobstore.HistoryRecord) error {
+		records = append(records, record)
+		return nil
+	}))
+	testSuite.Equal(1, len(records))
+	testSuite.JSONEq(`{"users":[{"name":"jane","password":"***"}],"note":"***"}`, string(records[0].Content))
+	bundle.Reset()
+	testSuite.NoError(redactor.ExportHistory(privilegedCtx, []string{blob.Key}, &bundle))
+	testSuite.Contains(bundle.String(), base64.StdEncoding.EncodeToString(blob.Content))
+
+	invalid := vcblobstore.BlobInfo{Key: "redaction/invalid.json", Content: []byte("not json"), ModifiedBy: "ux"}
+	testSuite.NoError(testSuite.gitRepoClient.AddBlob(testSuite.ctx, invalid))
+	_, invalidErr := redactor.GetBlob(testSuite.ctx, invalid.Key)
+	testSuite.Error(invalidErr)
+}
+
+func (testSuite *localGitRepoTestSuite) TestKeySharding() {
+	location := filepath.Join(testSuite.T().TempDir(), "sharded")
+	flat, flatErr := NewLocalGitTestRepo(&local.Config{Location: location})
+	testSuite.NoError(flatErr)
+	testSuite.NoError(flat.CreateRepository(testSuite.ctx))
+	blobs := []vcblobstore.BlobInfo{createTestBlob("icons/flat", "ux"), createTestBlob("readme", "ux")}
+	for _, blob := range blobs {
+		testSuite.NoError(flat.AddBlob(testSuite.ctx, blob))
+	}
+
+	sharding := &vcblobstore.KeySharding{Levels: 2}
+	sharded, shardedErr := NewLocalGitTestRepo(&local.Config{Location: location, Sharding: sharding})
+	testSuite.NoError(shardedErr)
+	keys, listErr := sharded.ListBlobKeys(testSuite.ctx, vcblobstore.ListOptions{})
+	testSuite.NoError(listErr)
+	testSuite.Empty(keys)
+
+	moved, shardErr := sharded.ShardKeys(testSuite.ctx, "migrator")
+	testSuite.NoError(shardErr)
+	testSuite.Equal(2, moved)
+	moved, shardErr = sharded.ShardKeys(testSuite.ctx, "migrator")
+	testSuite.NoError(shardErr)
+	testSuite.Equal(0, moved)
+
+	testSuite.NoError(sharded.AddBlob(testSuite.ctx, createTestBlob("icons/new", "ux")))
+	keys, listErr = sharded.ListBlobKeys(testSuite.ctx, vcblobstore.ListOptions{Prefix: "icons/"})
+	testSuite.NoError(listErr)
+	testSuite.ElementsMatch([]string{"icons/flat", "icons/new"}, keys)
+
+	for _, blob := range blobs {
+		content, getErr := sharded.GetBlob(testSuite.ctx, blob.Key)
+		testSuite.NoError(getErr)
+		testSuite.Equal(blob.Content, content)
+		_, statErr := os.Stat(filepath.Join(location, filepath.FromSlash(sharding.PathOf(vcblobstore.DefaultNaming, blob.Key))))
+		testSuite.NoError(statErr)
+	}
+	history, historyErr := sharded.GetBlobHistory(testSuite.ctx, "icons/new", vcblobstore.HistoryFilter{})
+	testSuite.NoError(historyErr)
+	testSuite.Len(history, 1)
+
+	tree, treeErr := sharded.GetTree(testSuite.ctx, "icons", 0)
+	testSuite.NoError(treeErr)
+	testSuite.Len(tree.Children, 2)
+}
+
+func (testSuite *localGitRepoTestSuite) TestRemoteRepository() {
+	remoteLocation := filepath.Join(testSuite.T().TempDir(), "remote.git")
+	_, initErr := local.ExecuteCommand(testSuite.ctx, local.ExecCmdParams{Name: "git", Args: []string{"init", "--bare", remoteLocation}}, &localGitRepoTestLogger)
+	testSuite.NoError(initErr)
+
+	clients := []*local.Git{}
+	for _, name := range []string{"first", "second"} {
+		client, createRepoErr := NewLocalGitTestRepo(&local.Config{
+			Location:  filepath.Join(testSuite.T().TempDir(), name),
+			RemoteURL: remoteLocation,
+		})
+		testSuite.NoError(createRepoErr)
+		testSuite.NoError(client.CreateRepository(testSuite.ctx))
+		clients = append(clients, client)
+	}
+
+	// The second client pushes on top of a remote branch it hasn't seen yet, which requires a rebase
+	testSuite.NoError(clients[0].AddBlob(testSuite.ctx, createTestBlob("from-first", "ux")))
+	testSuite.NoError(clients[1].AddBlob(testSuite.ctx, createTestBlob("from-second", "ux")))
+
+	out, logErr := local.ExecuteCommand(testSuite.ctx, local.ExecCmdParams{
+		Name: "git",
+		Args: []string{"--git-dir", remoteLocation, "ls-tree", "-r", "--name-only", "main"},
+	}, &localGitRepoTestLogger)
+	testSuite.NoError(logErr)
+	testSuite.Contains(out, "from-first")
+	testSuite.Contains(out, "from-second")
+}
+
+func (testSuite *localGitRepoTestSuite) TestPullBeforeWrite() {
+	remoteLocation := filepath.Join(testSuite.T().TempDir(), "remote.git")
+	_, initErr := local.ExecuteCommand(testSuite.ctx, local.ExecCmdParams{Name: "git", Args: []string{"init", "--bare", remoteLocation}}, &localGitRepoTestLogger)
+	testSuite.NoError(initErr)
+	newClient := func(name string, pushInterval time.Duration) *local.Git {
+		client, createRepoErr := NewLocalGitTestRepo(&local.Config{
+			Location:     filepath.Join(testSuite.T().TempDir(), name),
+			RemoteURL:    remoteLocation,
+			PullStrategy: local.PullFastForwardOnly,
+			PushInterval: pushInterval,
+		})
+		testSuite.NoError(createRepoErr)
+		testSuite.NoError(client.CreateRepository(testSuite.ctx))
+		return client
+	}
+	first := newClient("first", 0)
+	second := newClient("second", 0)
+	lagging := newClient("lagging", time.Hour)
+
+	testSuite.NoError(first.AddBlob(testSuite.ctx, createTestBlob("from-first", "ux")))
+	testSuite.NoError(second.AddBlob(testSuite.ctx, createTestBlob("from-second", "ux")))
+	_, getErr := second.GetBlob(testSuite.ctx, "from-first")
+	testSuite.NoError(getErr)
+
+	testSuite.NoError(lagging.AddBlob(testSuite.ctx, createTestBlob("unpushed", "ux")))
+	testSuite.NoError(first.AddBlob(testSuite.ctx, createTestBlob("diverging", "ux")))
+	addErr := lagging.AddBlob(testSuite.ctx, createTestBlob("conflicting", "ux"))
+	testSuite.ErrorIs(addErr, vcblobstore.ErrConflict)
+}
+
+func (testSuite *localGitRepoTestSuite) TestShallowClone() {
+	remoteLocation := filepath.Join(testSuite.T().TempDir(), "remote.git")
+	_, initErr := local.ExecuteCommand(testSuite.ctx, local.ExecCmdParams{Name: "git", Args: []string{"init", "--bare", remoteLocation}}, &localGitRepoTestLogger)
+	testSuite.NoError(initErr)
+	full, createRepoErr := NewLocalGitTestRepo(&local.Config{
+		Location:  filepath.Join(testSuite.T().TempDir(), "full"),
+		RemoteURL: remoteLocation,
+	})
+	testSuite.NoError(createRepoErr)
+	testSuite.NoError(full.CreateRepository(testSuite.ctx))
+	oldBlob := createTestBlob("shallow/old", "ux")
+	testSuite.NoError(full.AddBlob(testSuite.ctx, oldBlob))
+	oldVersion, versionErr := full.GetVersionFor(testSuite.ctx, oldBlob.Key)
+	testSuite.NoError(versionErr)
+	testSuite.NoError(full.AddBlob(testSuite.ctx, createTestBlob("shallow/new", "ux")))
+
+	shallow, createRepoErr := NewLocalGitTestRepo(&local.Config{
+		Location:    filepath.Join(testSuite.T().TempDir(), "shallow"),
+		RemoteURL:   "file://" + remoteLocation,
+		CloneDepth:  1,
+		CloneFilter: "blob:none",
+	})
+	testSuite.NoError(createRepoErr)
+	testSuite.NoError(shallow.CreateRepository(testSuite.ctx))
+	out, countErr := shallow.ExecuteGitCommand(testSuite.ctx, []string{"rev-list", "--count", "HEAD"})
+	testSuite.NoError(countErr)
+	testSuite.Equal("1", strings.TrimSpace(out))
+
+	content, getErr := shallow.GetBlobAtVersion(testSuite.ctx, oldBlob.Key, oldVersion)
+	testSuite.NoError(getErr)
+	testSuite.Equal(oldBlob.Content, content)
+}
+
+func (testSuite *localGitRepoTestSuite) TestPeriodicPush() {
+	remoteLocation := filepath.Join(testSuite.T().TempDir(), "remote.git")
+	_, initErr := local.ExecuteCommand(testSuite.ctx, local.ExecCmdParams{Name: "git", Args: []string{"init", "--bare", remoteLocation}}, &localGitRepoTestLogger)
+	testSuite.NoError(initErr)
+	client, createRepoErr := NewLocalGitTestRepo(&local.Config{
+		Location:     filepath.Join(testSuite.T().TempDir(), "client"),
+		RemoteURL:    remoteLocation,
+		PushInterval: time.Hour,
+	})
+	testSuite.NoError(createRepoErr)
+	testSuite.NoError(client.CreateRepository(testSuite.ctx))
+
+	remoteFiles := func() string {
+		out, _ := local.ExecuteCommand(testSuite.ctx, local.ExecCmdParams{
+			Name: "git",
+			Args: []string{"--git-dir", remoteLocation, "ls-tree", "-r", "--name-only", "main"},
+		}, &localGitRepoTestLogger)
+		return out
+	}
+
+	testSuite.NoError(client.AddBlob(testSuite.ctx, createTestBlob("pending", "ux")))
+	testSuite.NotContains(remoteFiles(), "pending")
+
+	ctx, cancel := context.WithCancel(testSuite.ctx)
+	client.StartPushing(ctx)
+	cancel()
+	testSuite.Eventually(func() bool {
+		return strings.Contains(remoteFiles(), "pending")
+	}, 5*time.Second, 50*time.Millisecond)
+	testSuite.NoError(client.Push(testSuite.ctx))
+
+	// A failing periodic push keeps the acknowledged commits for the next attempt
+	unreachable := remoteLocation + ".unreachable"
+	testSuite.NoError(client.AddBlob(testSuite.ctx, createTestBlob("kept", "ux")))
+	testSuite.NoError(os.Rename(remoteLocation, unreachable))
+	testSuite.Error(client.Push(testSuite.ctx))
+	testSuite.Error(client.Push(testSuite.ctx))
+	testSuite.ErrorContains(client.PushHealth(), "2 consecutive pushes failed")
+	_, getErr := client.GetBlob(testSuite.ctx, "kept")
+	testSuite.NoError(getErr)
+
+	testSuite.NoError(os.Rename(unreachable, remoteLocation))
+	testSuite.NoError(client.Push(testSuite.ctx))
+	testSuite.NoError(client.PushHealth())
+	testSuite.Contains(remoteFiles(), "kept")
+}
+
+func (testSuite *localGitRepoTestSuite) TestMaintenance() {
+	repo, createRepoErr := NewLocalGitTestRepo(&local.Config{
+		Location:            filepath.Join(testSuite.T().TempDir(), "maintained"),
+		MaintenanceInterval: 10 * time.Millisecond,
+	})
+	testSuite.NoError(createRepoErr)
+	testSuite.NoError(repo.CreateRepository(testSuite.ctx))
+	blob := createTestBlob("maintained", "ux")
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, blob))
+
+	ctx, cancel := context.WithCancel(testSuite.ctx)
+	repo.StartMaintenance(ctx)
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	testSuite.NoError(repo.RunMaintenance(testSuite.ctx))
+	content, getErr := repo.GetBlob(testSuite.ctx, blob.Key)
+	testSuite.NoError(getErr)
+	testSuite.Equal(blob.Content, content)
+}
+
+func (testSuite *localGitRepoTestSuite) TestBareRepository() {
+	location := filepath.Join(testSuite.T().TempDir(), "bare")
+	repo, createRepoErr := NewLocalGitTestRepo(&local.Config{Location: location, Bare: true})
+	testSuite.NoError(createRepoErr)
+	testSuite.NoError(repo.CreateRepository(testSuite.ctx))
+
+	blob := createTestBlob("bare/blob", "ux")
+	blob.Metadata = map[string]string{"kind": "bare"}
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, blob))
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, createTestBlob("bare/other", "ux")))
+
+	out, checkErr := repo.ExecuteGitCommand(testSuite.ctx, []string{"rev-parse", "--is-bare-repository"})
+	testSuite.NoError(checkErr)
+	testSuite.Equal("true", strings.TrimSpace(out))
+	testSuite.NoDirExists(filepath.Join(location, "bare"))
+
+	info, getErr := repo.GetBlobInfo(testSuite.ctx, blob.Key)
+	testSuite.NoError(getErr)
+	testSuite.Equal(blob.Content, info.Content)
+	testSuite.Equal(blob.Metadata, info.Metadata)
+	head, headErr := repo.HeadBlob(testSuite.ctx, blob.Key)
+	testSuite.NoError(headErr)
+	testSuite.True(head.Exists)
+	testSuite.Equal(int64(len(blob.Content)), head.Size)
+	keys, listErr := repo.ListBlobKeys(testSuite.ctx, vcblobstore.ListOptions{})
+	testSuite.NoError(listErr)
+	testSuite.Equal([]string{"bare/blob", "bare/other"}, keys)
+
+	testSuite.NoError(repo.RenameBlob(testSuite.ctx, blob.Key, "bare/renamed", "ux"))
+	renamed, getErr := repo.GetBlobInfo(testSuite.ctx, "bare/renamed")
+	testSuite.NoError(getErr)
+	testSuite.Equal(blob.Content, renamed.Content)
+	testSuite.Equal(blob.Metadata, renamed.Metadata)
+	_, getErr = repo.GetBlob(testSuite.ctx, blob.Key)
+	testSuite.ErrorIs(getErr, vcblobstore.ErrBlobNotFound)
+
+	testSuite.NoError(repo.DeleteBlob(testSuite.ctx, "bare/renamed", "ux"))
+	testSuite.ErrorIs(repo.DeleteBlob(testSuite.ctx, "bare/renamed", "ux"), vcblobstore.ErrBlobNotFound)
+	testSuite.ErrorIs(repo.DeleteBlob(testSuite.ctx, "bare/oth*", "ux"), vcblobstore.ErrBlobNotFound)
+	cancelled, cancel := context.WithCancel(testSuite.ctx)
+	cancel()
+	_, getErr = repo.GetBlob(cancelled, "bare/other")
+	testSuite.ErrorIs(getErr, context.Canceled)
+	testSuite.NotErrorIs(getErr, vcblobstore.ErrBlobNotFound)
+	keys, listErr = repo.ListBlobKeys(testSuite.ctx, vcblobstore.ListOptions{})
+	testSuite.NoError(listErr)
+	testSuite.Equal([]string{"bare/other"}, keys)
+
+	_, remoteErr := NewLocalGitTestRepo(&local.Config{Location: location, Bare: true, RemoteURL: location})
+	testSuite.ErrorIs(remoteErr, local.ErrBareRemote)
+}
+
+func (testSuite *localGitRepoTestSuite) TestCommitStagesOnlyTheChangedEntries() {
+	location := filepath.Join(testSuite.T().TempDir(), "staging")
+	repo, createRepoErr := NewLocalGitTestRepo(&local.Config{Location: location})
+	testSuite.NoError(createRepoErr)
+	testSuite.NoError(repo.CreateRepository(testSuite.ctx))
+	testSuite.NoError(os.WriteFile(filepath.Join(location, "stray"), []byte("stray"), 0600))
+
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, createTestBlob("staged", "ux")))
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, createTestBlob("staged", "ux")))
+
+	tree, lsErr := repo.ExecuteGitCommand(testSuite.ctx, []string{"ls-tree", "-r", "--name-only", "HEAD"})
+	testSuite.NoError(lsErr)
+	testSuite.Equal("staged\n", tree)
+	status, statusErr := repo.ExecuteGitCommand(testSuite.ctx, []string{"status", "--porcelain"})
+	testSuite.NoError(statusErr)
+	testSuite.Equal("?? stray\n", status)
+	count, countErr := repo.ExecuteGitCommand(testSuite.ctx, []string{"rev-list", "--count", "HEAD"})
+	testSuite.NoError(countErr)
+	testSuite.Equal("2", strings.TrimSpace(count))
+}
+
+func (testSuite *localGitRepoTestSuite) TestRollbackAfterStaging() {
+	failing := false
+	repo, createRepoErr := NewLocalGitTestRepo(&local.Config{
+		Location: filepath.Join(testSuite.T().TempDir(), "rollback"),
+		BeforeCommit: func(ctx context.Context) error {
+			if failing {
+				return faulty.ErrInjected
+			}
+			return nil
+		},
+	})
+	testSuite.NoError(createRepoErr)
+	testSuite.NoError(repo.CreateRepository(testSuite.ctx))
+	blob := createTestBlob("rolled-back", "ux")
+	blob.Metadata = map[string]string{"team": "payments"}
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, blob))
+	state, stateErr := repo.GetStateID(testSuite.ctx)
+	testSuite.NoError(stateErr)
+
+	failing = true
+	update := createTestBlob(blob.Key, "ux")
+	update.Metadata = map[string]string{"team": "growth"}
+	testSuite.ErrorIs(repo.AddBlob(testSuite.ctx, update), faulty.ErrInjected)
+	testSuite.ErrorIs(repo.AddBlob(testSuite.ctx, createTestBlob("rolled-back-new", "ux")), faulty.ErrInjected)
+	testSuite.ErrorIs(repo.DeleteBlob(testSuite.ctx, blob.Key, "ux"), faulty.ErrInjected)
+
+	current, stateErr := repo.GetStateID(testSuite.ctx)
+	testSuite.NoError(stateErr)
+	testSuite.Equal(state, current)
+	status, statusErr := repo.ExecuteGitCommand(testSuite.ctx, []string{"status", "--porcelain", "--untracked-files=all"})
+	testSuite.NoError(statusErr)
+	testSuite.Empty(status)
+	info, getErr := repo.GetBlobInfo(testSuite.ctx, blob.Key)
+	testSuite.NoError(getErr)
+	testSuite.Equal(blob.Content, info.Content)
+	testSuite.Equal(blob.Metadata, info.Metadata)
+
+	failing = false
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, update))
+	info, getErr = repo.GetBlobInfo(testSuite.ctx, blob.Key)
+	testSuite.NoError(getErr)
+	testSuite.Equal(update.Content, info.Content)
+}
+
+func (testSuite *localGitRepoTestSuite) TestHooks() {
+	newRepo := func(name string, hooks local.HooksMode) (*local.Git, string) {
+		location := filepath.Join(testSuite.T().TempDir(), name)
+		repo, createRepoErr := NewLocalGitTestRepo(&local.Config{Location: location, Hooks: hooks})
+		testSuite.NoError(createRepoErr)
+		testSuite.NoError(repo.CreateRepository(testSuite.ctx))
+		rejectingHook := []byte("#!/bin/sh\necho rejected >&2\nexit 1\n")
+		testSuite.NoError(os.WriteFile(filepath.Join(location, ".git", "hooks", "pre-commit"), rejectingHook, 0700))
+		return repo, location
+	}
+
+	repo, _ := newRepo("repository-hooks", local.HooksRepository)
+	testSuite.ErrorIs(repo.AddBlob(testSuite.ctx, createTestBlob("hooked", "ux")), local.ErrHookRejected)
+
+	repo, _ = newRepo("disabled-hooks", local.HooksDisabled)
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, createTestBlob("hooked", "ux")))
+
+	repo, location := newRepo("message-hooks", local.HooksRepository)
+	hooksDirectory := filepath.Join(location, ".git", "hooks")
+	testSuite.NoError(os.Remove(filepath.Join(hooksDirectory, "pre-commit")))
+	messageHook := []byte("#!/bin/sh\ngrep -q rejected \"$1\" && exit 1\necho 'Reviewed-by: hook' >>\"$1\"\n")
+	testSuite.NoError(os.WriteFile(filepath.Join(hooksDirectory, "commit-msg"), messageHook, 0700))
+	postCommitHook := []byte("#!/bin/sh\ngit rev-parse HEAD >post-commit\n")
+	testSuite.NoError(os.WriteFile(filepath.Join(hooksDirectory, "post-commit"), postCommitHook, 0700))
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, createTestBlob("hooked", "ux")))
+	versions, listErr := repo.ListVersions(testSuite.ctx, "", "")
+	testSuite.NoError(listErr)
+	testSuite.Contains(versions[len(versions)-1].Message, "Reviewed-by: hook")
+	postCommit, readErr := os.ReadFile(filepath.Join(location, "post-commit"))
+	testSuite.NoError(readErr)
+	testSuite.Equal(versions[len(versions)-1].Version, strings.TrimSpace(string(postCommit)))
+	testSuite.NoError(os.Remove(filepath.Join(location, "post-commit")))
+	rejected := createTestBlob("hooked", "ux")
+	rejected.ModifiedBy = "rejected"
+	testSuite.ErrorIs(repo.AddBlob(testSuite.ctx, rejected), local.ErrHookRejected)
+
+	repo, location = newRepo("validation-hooks", local.HooksValidation)
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, createTestBlob("hooked", "ux")))
+	testSuite.NoError(os.WriteFile(filepath.Join(location, `invalid\key`), []byte("invalid"), 0600))
+	_, addErr := repo.ExecuteGitCommand(testSuite.ctx, []string{"add", "-A"})
+	testSuite.NoError(addErr)
+	out, commitErr := repo.ExecuteGitCommand(testSuite.ctx, []string{"-c", "user.name=ux", "-c", "user.email=ux", "commit", "-m", "invalid key"})
+	testSuite.Error(commitErr)
+	testSuite.Contains(out, "vcblobstore: invalid key")
 }
 
 func NewLocalGitTestRepo(conf *local.Config) (*local.Git, error) {
 	testLogger := createTestLogger()
-	repo := local.NewLocalGitRepository(conf, &testLogger)
-	return repo, nil
+	return local.NewLocalGitRepository(conf, &testLogger)
+}
+
+func (testSuite *localGitRepoTestSuite) TestFileMode() {
+	for _, bare := range []bool{false, true} {
+		location := filepath.Join(testSuite.T().TempDir(), fmt.Sprintf("modes-%t", bare))
+		repo, createRepoErr := NewLocalGitTestRepo(&local.Config{Location: location, Bare: bare})
+		testSuite.NoError(createRepoErr)
+		testSuite.NoError(repo.CreateRepository(testSuite.ctx))
+
+		blob := createTestBlob("scripts/run", "ux")
+		blob.Mode = vcblobstore.FileModeExecutable
+		testSuite.NoError(repo.AddBlob(testSuite.ctx, blob))
+		testSuite.NoError(repo.AddBlob(testSuite.ctx, createTestBlob("scripts/data", "ux")))
+		invalid := createTestBlob("scripts/invalid", "ux")
+		invalid.Mode = "120000"
+		testSuite.ErrorIs(repo.AddBlob(testSuite.ctx, invalid), vcblobstore.ErrInvalidFileMode)
+
+		out, lsErr := repo.ExecuteGitCommand(testSuite.ctx, []string{"ls-tree", "HEAD", "--", "scripts/run"})
+		testSuite.NoError(lsErr)
+		testSuite.True(strings.HasPrefix(out, "100755 "), out)
+		head, headErr := repo.HeadBlob(testSuite.ctx, blob.Key)
+		testSuite.NoError(headErr)
+		testSuite.Equal(vcblobstore.FileModeExecutable, head.Mode)
+		keys, listErr := repo.ListBlobKeys(testSuite.ctx, vcblobstore.ListOptions{Mode: vcblobstore.FileModeExecutable})
+		testSuite.NoError(listErr)
+		testSuite.Equal([]string{"scripts/run"}, keys)
+
+		// Updates without a mode keep the mode of the blob
+		testSuite.NoError(repo.AddBlob(testSuite.ctx, createTestBlob(blob.Key, "ux")))
+		head, headErr = repo.HeadBlob(testSuite.ctx, blob.Key)
+		testSuite.NoError(headErr)
+		testSuite.Equal(vcblobstore.FileModeExecutable, head.Mode)
+		executableVersion, versionErr := repo.GetVersionFor(testSuite.ctx, blob.Key)
+		testSuite.NoError(versionErr)
+
+		blob.Mode = vcblobstore.FileModeRegular
+		testSuite.NoError(repo.AddBlob(testSuite.ctx, blob))
+		head, headErr = repo.HeadBlob(testSuite.ctx, blob.Key)
+		testSuite.NoError(headErr)
+		testSuite.Equal(vcblobstore.FileModeRegular, head.Mode)
+
+		testSuite.NoError(repo.RestoreBlob(testSuite.ctx, blob.Key, executableVersion, "ux"))
+		head, headErr = repo.HeadBlob(testSuite.ctx, blob.Key)
+		testSuite.NoError(headErr)
+		testSuite.Equal(vcblobstore.FileModeExecutable, head.Mode)
+	}
+}
+
+func (testSuite *localGitRepoTestSuite) TestAddBlobFromReader() {
+	for _, bare := range []bool{false, true} {
+		location := filepath.Join(testSuite.T().TempDir(), fmt.Sprintf("stream-%t", bare))
+		repo, createRepoErr := NewLocalGitTestRepo(&local.Config{Location: location, Bare: bare})
+		testSuite.NoError(createRepoErr)
+		testSuite.NoError(repo.CreateRepository(testSuite.ctx))
+
+		blob := createTestBlob("streamed/blob", "ux")
+		blob.Metadata = map[string]string{"kind": "streamed"}
+		blob.Mode = vcblobstore.FileModeExecutable
+		testSuite.NoError(repo.AddBlobFromReader(testSuite.ctx, blob, bytes.NewReader(blob.Content)))
+
+		info, getErr := repo.GetBlobInfo(testSuite.ctx, blob.Key)
+		testSuite.NoError(getErr)
+		testSuite.Equal(blob.Content, info.Content)
+		testSuite.Equal(blob.Metadata, info.Metadata)
+		head, headErr := repo.HeadBlob(testSuite.ctx, blob.Key)
+		testSuite.NoError(headErr)
+		testSuite.Equal(vcblobstore.FileModeExecutable, head.Mode)
+
+		mismatching := createTestBlob(blob.Key, "ux")
+		mismatching.SHA256 = vcblobstore.ContentSHA256([]byte("other"))
+		testSuite.ErrorIs(repo.AddBlobFromReader(testSuite.ctx, mismatching, bytes.NewReader(mismatching.Content)), vcblobstore.ErrChecksumMismatch)
+
+		gitDir := location
+		if !bare {
+			gitDir = filepath.Join(location, ".git")
+		}
+		spooled, globErr := filepath.Glob(filepath.Join(gitDir, "vcblobstore-stream-*"))
+		testSuite.NoError(globErr)
+		testSuite.Empty(spooled)
+	}
+}
+
+func (testSuite *localGitRepoTestSuite) TestVerifyRepository() {
+	location := filepath.Join(testSuite.T().TempDir(), "verified")
+	repo, createRepoErr := NewLocalGitTestRepo(&local.Config{Location: location})
+	testSuite.NoError(createRepoErr)
+	testSuite.NoError(repo.CreateRepository(testSuite.ctx))
+	blob := createTestBlob("verified", "ux")
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, blob))
+
+	report, verifyErr := repo.VerifyRepository(testSuite.ctx, vcblobstore.RepairOptions{})
+	testSuite.NoError(verifyErr)
+	testSuite.True(report.Healthy)
+	testSuite.Len(report.Checks, 3)
+
+	testSuite.NoError(os.WriteFile(filepath.Join(location, "verified"), []byte("changed"), 0600))
+	testSuite.NoError(os.WriteFile(filepath.Join(location, "stray"), []byte("stray"), 0600))
+	report, verifyErr = repo.VerifyRepository(testSuite.ctx, vcblobstore.RepairOptions{})
+	testSuite.NoError(verifyErr)
+	testSuite.False(report.Healthy)
+	testSuite.True(report.Checks[0].Passed)
+	testSuite.False(report.Checks[1].Passed)
+	testSuite.Contains(report.Checks[1].Details, "verified")
+	testSuite.False(report.Checks[2].Passed)
+	testSuite.Contains(report.Checks[2].Details, "stray")
+
+	report, verifyErr = repo.VerifyRepository(testSuite.ctx, vcblobstore.RepairOptions{ResetChanges: true, RemoveUntracked: true})
+	testSuite.NoError(verifyErr)
+	testSuite.True(report.Healthy)
+	testSuite.True(report.Checks[1].Repaired)
+	testSuite.True(report.Checks[2].Repaired)
+	content, getErr := repo.GetBlob(testSuite.ctx, blob.Key)
+	testSuite.NoError(getErr)
+	testSuite.Equal(blob.Content, content)
+	testSuite.NoFileExists(filepath.Join(location, "stray"))
+}
+
+func (testSuite *localGitRepoTestSuite) TestDiffBlob() {
+	location := filepath.Join(testSuite.T().TempDir(), "diffed")
+	repo, createRepoErr := NewLocalGitTestRepo(&local.Config{Location: location})
+	testSuite.NoError(createRepoErr)
+	testSuite.NoError(repo.CreateRepository(testSuite.ctx))
+
+	versions := []string{}
+	for _, content := range []string{"first\nsecond\n", "first\nchanged\n"} {
+		blob := createTestBlob("diffed/text", "ux")
+		blob.Content = []byte(content)
+		testSuite.NoError(repo.AddBlob(testSuite.ctx, blob))
+		version, versionErr := repo.GetStateID(testSuite.ctx)
+		testSuite.NoError(versionErr)
+		versions = append(versions, version)
+	}
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, createTestBlob("diffed/binary", "ux")))
+	binaryBlob := createTestBlob("diffed/binary", "ux")
+	binaryBlob.Content = append([]byte{0}, binaryBlob.Content...)
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, binaryBlob))
+	last, versionErr := repo.GetStateID(testSuite.ctx)
+	testSuite.NoError(versionErr)
+
+	diff, diffErr := repo.DiffBlob(testSuite.ctx, "diffed/text", versions[0], versions[1])
+	testSuite.NoError(diffErr)
+	testSuite.False(diff.Binary)
+	testSuite.Equal(int64(13), diff.FromSize)
+	testSuite.Equal(int64(14), diff.ToSize)
+	testSuite.True(strings.HasPrefix(diff.Patch, "--- a/diffed/text\n+++ b/diffed/text\n@@"), diff.Patch)
+	testSuite.Contains(diff.Patch, "-second\n+changed\n")
+
+	diff, diffErr = repo.DiffBlob(testSuite.ctx, "diffed/binary", versions[1], last)
+	testSuite.NoError(diffErr)
+	testSuite.True(diff.Binary)
+	testSuite.Empty(diff.Patch)
+	testSuite.Equal(int64(-1), diff.FromSize)
+	testSuite.Equal(int64(len(binaryBlob.Content)), diff.ToSize)
+	testSuite.Contains(diff.Summary(), "created")
+
+	_, diffErr = repo.DiffBlob(testSuite.ctx, "diffed/none", versions[0], last)
+	testSuite.ErrorIs(diffErr, vcblobstore.ErrBlobNotFound)
+}
+
+func (testSuite *localGitRepoTestSuite) TestTags() {
+	location := filepath.Join(testSuite.T().TempDir(), "tagged")
+	repo, createRepoErr := NewLocalGitTestRepo(&local.Config{Location: location})
+	testSuite.NoError(createRepoErr)
+	testSuite.NoError(repo.CreateRepository(testSuite.ctx))
+
+	released := createTestBlob("tagged", "ux")
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, released))
+	releasedState, stateErr := repo.GetStateID(testSuite.ctx)
+	testSuite.NoError(stateErr)
+	testSuite.NoError(repo.TagState(testSuite.ctx, "release-1", ""))
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, createTestBlob("tagged", "ux")))
+	testSuite.NoError(repo.TagState(testSuite.ctx, "first", releasedState))
+
+	testSuite.ErrorIs(repo.TagState(testSuite.ctx, "release-1", ""), vcblobstore.ErrConflict)
+	testSuite.ErrorIs(repo.TagState(testSuite.ctx, "invalid..name", ""), vcblobstore.ErrInvalidTagName)
+
+	tags, listErr := repo.ListTags(testSuite.ctx)
+	testSuite.NoError(listErr)
+	testSuite.Equal([]vcblobstore.Tag{{Name: "first", StateID: releasedState}, {Name: "release-1", StateID: releasedState}}, tags)
+	content, getErr := repo.GetBlobAtVersion(testSuite.ctx, released.Key, "release-1")
+	testSuite.NoError(getErr)
+	testSuite.Equal(released.Content, content)
+
+	// The tags are shared through the remote
+	remoteLocation := filepath.Join(testSuite.T().TempDir(), "tagged.git")
+	_, initErr := local.ExecuteCommand(testSuite.ctx, local.ExecCmdParams{Name: "git", Args: []string{"init", "--bare", remoteLocation}}, &localGitRepoTestLogger)
+	testSuite.NoError(initErr)
+	clients := []*local.Git{}
+	for _, name := range []string{"first-client", "second-client"} {
+		client, clientErr := NewLocalGitTestRepo(&local.Config{Location: filepath.Join(testSuite.T().TempDir(), name), RemoteURL: remoteLocation})
+		testSuite.NoError(clientErr)
+		testSuite.NoError(client.CreateRepository(testSuite.ctx))
+		clients = append(clients, client)
+	}
+	testSuite.NoError(clients[0].AddBlob(testSuite.ctx, released))
+	testSuite.NoError(clients[0].TagState(testSuite.ctx, "shared", ""))
+	sharedState, stateErr := clients[0].GetStateID(testSuite.ctx)
+	testSuite.NoError(stateErr)
+	tags, listErr = clients[1].ListTags(testSuite.ctx)
+	testSuite.NoError(listErr)
+	testSuite.Equal([]vcblobstore.Tag{{Name: "shared", StateID: sharedState}}, tags)
+	testSuite.ErrorIs(clients[1].TagState(testSuite.ctx, "shared", sharedState), vcblobstore.ErrConflict)
+}
+
+func (testSuite *localGitRepoTestSuite) TestRevertToState() {
+	for _, bare := range []bool{false, true} {
+		location := filepath.Join(testSuite.T().TempDir(), fmt.Sprintf("reverted-%t", bare))
+		repo, createRepoErr := NewLocalGitTestRepo(&local.Config{Location: location, Bare: bare})
+		testSuite.NoError(createRepoErr)
+		testSuite.NoError(repo.CreateRepository(testSuite.ctx))
+
+		updated := createTestBlob("reverted/updated", "ux")
+		updated.Metadata = map[string]string{"kind": "original"}
+		deleted := createTestBlob("reverted/deleted", "ux")
+		deleted.Metadata = map[string]string{"kind": "deleted"}
+		testSuite.NoError(repo.AddBlob(testSuite.ctx, updated))
+		testSuite.NoError(repo.AddBlob(testSuite.ctx, deleted))
+		state, stateErr := repo.GetStateID(testSuite.ctx)
+		testSuite.NoError(stateErr)
+
+		update := createTestBlob(updated.Key, "ux")
+		update.Metadata = map[string]string{"kind": "imported"}
+		testSuite.NoError(repo.AddBlob(testSuite.ctx, update))
+		testSuite.NoError(repo.DeleteBlob(testSuite.ctx, deleted.Key, "ux"))
+		testSuite.NoError(repo.AddBlob(testSuite.ctx, createTestBlob("reverted/created", "ux")))
+
+		testSuite.NoError(repo.RevertToState(testSuite.ctx, state, "ux"))
+		for _, blob := range []vcblobstore.BlobInfo{updated, deleted} {
+			info, getErr := repo.GetBlobInfo(testSuite.ctx, blob.Key)
+			testSuite.NoError(getErr)
+			testSuite.Equal(blob.Content, info.Content)
+			testSuite.Equal(blob.Metadata, info.Metadata)
+		}
+		_, getErr := repo.GetBlob(testSuite.ctx, "reverted/created")
+		testSuite.ErrorIs(getErr, vcblobstore.ErrBlobNotFound)
+		if !bare {
+			clean, statusErr := repo.CheckStatus()
+			testSuite.NoError(statusErr)
+			testSuite.True(clean)
+		}
+
+		revertedState, stateErr := repo.GetStateID(testSuite.ctx)
+		testSuite.NoError(stateErr)
+		testSuite.NotEqual(state, revertedState)
+		testSuite.NoError(repo.RevertToState(testSuite.ctx, state, "ux"))
+		unchangedState, stateErr := repo.GetStateID(testSuite.ctx)
+		testSuite.NoError(stateErr)
+		testSuite.Equal(revertedState, unchangedState)
+	}
+}
+
+func (testSuite *localGitRepoTestSuite) TestGetBlobAnnotations() {
+	location := filepath.Join(testSuite.T().TempDir(), "annotated")
+	repo, createRepoErr := NewLocalGitTestRepo(&local.Config{Location: location})
+	testSuite.NoError(createRepoErr)
+	testSuite.NoError(repo.CreateRepository(testSuite.ctx))
+
+	first := createTestBlob("annotated/first", "ux")
+	first.AuthorName = "Zazie Lalochère"
+	first.AuthorEmail = "zazie@metro.example"
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, first))
+	firstVersion, versionErr := repo.GetStateID(testSuite.ctx)
+	testSuite.NoError(versionErr)
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, createTestBlob("annotated/second", "ux")))
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, createTestBlob("annotated/deleted", "ux")))
+	testSuite.NoError(repo.DeleteBlob(testSuite.ctx, "annotated/deleted", "ux"))
+	secondVersion, versionErr := repo.GetVersionFor(testSuite.ctx, "annotated/second")
+	testSuite.NoError(versionErr)
+
+	annotations, annotateErr := repo.GetBlobAnnotations(testSuite.ctx, []string{"annotated/second", "annotated/first", "annotated/deleted", "annotated/none"})
+	testSuite.NoError(annotateErr)
+	testSuite.Equal(4, len(annotations))
+	testSuite.Equal("annotated/second", annotations[0].Key)
+	testSuite.Equal(secondVersion, annotations[0].Version)
+	testSuite.Equal(firstVersion, annotations[1].Version)
+	testSuite.Equal("Zazie Lalochère <zazie@metro.example>", annotations[1].Author)
+	testSuite.False(annotations[1].AuthorDate.IsZero())
+	testSuite.Equal(vcblobstore.BlobAnnotation{Key: "annotated/deleted"}, annotations[2])
+	testSuite.Equal(vcblobstore.BlobAnnotation{Key: "annotated/none"}, annotations[3])
+}
+
+func (testSuite *localGitRepoTestSuite) TestChangesOfKeysGitWouldQuote() {
+	location := filepath.Join(testSuite.T().TempDir(), "quoted")
+	repo, createRepoErr := NewLocalGitTestRepo(&local.Config{Location: location})
+	testSuite.NoError(createRepoErr)
+	testSuite.NoError(repo.CreateRepository(testSuite.ctx))
+
+	keys := []string{"quoted/ünïcode", "quoted/tab\there", "quoted/\"quotes\""}
+	for _, key := range keys {
+		testSuite.NoError(repo.AddBlob(testSuite.ctx, createTestBlob(key, "ux")))
+	}
+
+	versions, listErr := repo.ListVersions(testSuite.ctx, "", "")
+	testSuite.NoError(listErr)
+	for index, key := range keys {
+		testSuite.Equal([]vcblobstore.KeyChange{{Key: key, Operation: vcblobstore.BlobOperationCreate}}, versions[len(versions)-len(keys)+index].Changes)
+	}
+
+	annotations, annotateErr := repo.GetBlobAnnotations(testSuite.ctx, keys)
+	testSuite.NoError(annotateErr)
+	history, historyErr := repo.GetBlobHistory(testSuite.ctx, keys[0], vcblobstore.HistoryFilter{})
+	testSuite.NoError(historyErr)
+	testSuite.Equal(1, len(history))
+	testSuite.Equal(vcblobstore.BlobOperationCreate, history[0].Operation)
+	for index, annotation := range annotations {
+		testSuite.Equal(versions[len(versions)-len(keys)+index].Version, annotation.Version)
+	}
+
+	metadata, metadataErr := repo.GetVersionMetadata(testSuite.ctx, versions[len(versions)-1].Version)
+	testSuite.NoError(metadataErr)
+	testSuite.Equal([]git.ChangedKey{{Key: keys[2], Operation: string(vcblobstore.BlobOperationCreate)}}, metadata.Changes)
+}
+
+func (testSuite *localGitRepoTestSuite) TestListingKeysGitWouldQuote() {
+	location := filepath.Join(testSuite.T().TempDir(), "quoted")
+	repo, createRepoErr := NewLocalGitTestRepo(&local.Config{Location: location})
+	testSuite.NoError(createRepoErr)
+	testSuite.NoError(repo.CreateRepository(testSuite.ctx))
+
+	keys := []string{"\"quotes\"", "café", "tab\there"}
+	blob := createTestBlob("", "ux")
+	for _, key := range keys {
+		blob.Key = key
+		testSuite.NoError(repo.AddBlob(testSuite.ctx, blob))
+	}
+	testSuite.NoError(repo.CreateAlias(testSuite.ctx, "ünïcode alias", keys[1], "ux"))
+
+	listed, listErr := repo.ListBlobKeys(testSuite.ctx, vcblobstore.ListOptions{})
+	testSuite.NoError(listErr)
+	testSuite.ElementsMatch(keys, listed)
+	iterated := []string{}
+	for key, iterateErr := range repo.IterateBlobKeys(testSuite.ctx, vcblobstore.ListOptions{}) {
+		testSuite.NoError(iterateErr)
+		iterated = append(iterated, key)
+	}
+	testSuite.ElementsMatch(keys, iterated)
+
+	aliases, aliasesErr := repo.ListAliases(testSuite.ctx)
+	testSuite.NoError(aliasesErr)
+	testSuite.Equal([]vcblobstore.Alias{{Alias: "ünïcode alias", Target: keys[1]}}, aliases)
+
+	duplicates, duplicatesErr := repo.FindDuplicates(testSuite.ctx)
+	testSuite.NoError(duplicatesErr)
+	testSuite.Equal(1, len(duplicates))
+	testSuite.ElementsMatch(keys, duplicates[0].Keys)
+
+	blob.Key = "dir/ünïcode\tfile"
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, blob))
+	tree, treeErr := repo.GetTree(testSuite.ctx, "dir", 0)
+	testSuite.NoError(treeErr)
+	testSuite.Equal(1, len(tree.Children))
+	testSuite.Equal("ünïcode\tfile", tree.Children[0].Name)
+	testSuite.Equal(blob.Key, tree.Children[0].Path)
+	testSuite.Equal(vcblobstore.TreeNodeBlob, tree.Children[0].Type)
+}
+
+func (testSuite *localGitRepoTestSuite) TestPruneHistory() {
+	location := filepath.Join(testSuite.T().TempDir(), "pruned")
+	repo, createRepoErr := NewLocalGitTestRepo(&local.Config{Location: location})
+	testSuite.NoError(createRepoErr)
+	testSuite.NoError(repo.CreateRepository(testSuite.ctx))
+
+	latest := createTestBlob("pruned/a", "ux")
+	for _, blob := range []vcblobstore.BlobInfo{createTestBlob("pruned/a", "ux"), createTestBlob("pruned/a", "ux"), createTestBlob("pruned/b", "ux"), latest} {
+		testSuite.NoError(repo.AddBlob(testSuite.ctx, blob))
+	}
+	before, listErr := repo.ListVersions(testSuite.ctx, "", "")
+	testSuite.NoError(listErr)
+
+	report, pruneErr := repo.PruneHistory(testSuite.ctx, vcblobstore.RetentionPolicy{KeepVersions: 1})
+	testSuite.NoError(pruneErr)
+	testSuite.Equal(2, report.Squashed)
+	testSuite.Equal(2, report.Kept)
+
+	after, listErr := repo.ListVersions(testSuite.ctx, "", "")
+	testSuite.NoError(listErr)
+	testSuite.Equal(3, len(after))
+	testSuite.Equal(report.StateID, after[2].Version)
+	testSuite.Equal(before[2].Author, after[1].Author)
+	testSuite.Equal(before[2].AuthorDate, after[1].AuthorDate)
+	testSuite.Equal(before[2].Message, after[1].Message)
+	testSuite.Equal(before[3].Changes, after[2].Changes)
+	content, getErr := repo.GetBlob(testSuite.ctx, latest.Key)
+	testSuite.NoError(getErr)
+	testSuite.Equal(latest.Content, content)
+	history, historyErr := repo.GetBlobHistory(testSuite.ctx, latest.Key, vcblobstore.HistoryFilter{})
+	testSuite.NoError(historyErr)
+	testSuite.Equal(2, len(history))
+	clean, statusErr := repo.CheckStatus()
+	testSuite.NoError(statusErr)
+	testSuite.True(clean)
+
+	report, pruneErr = repo.PruneHistory(testSuite.ctx, vcblobstore.RetentionPolicy{KeepVersions: 1})
+	testSuite.NoError(pruneErr)
+	testSuite.Equal(0, report.Squashed)
+	testSuite.Equal(after[2].Version, report.StateID)
+
+	out, branchErr := repo.ExecuteGitCommand(testSuite.ctx, []string{"symbolic-ref", "--short", "HEAD"})
+	testSuite.NoError(branchErr)
+	mergeCtx := vcblobstore.WithMergeStrategy(testSuite.ctx, vcblobstore.MergeCommit)
+	testSuite.NoError(repo.AddBlob(vcblobstore.WithBranch(testSuite.ctx, "side"), createTestBlob("pruned/side", "ux")))
+	testSuite.NoError(repo.MergeBranch(mergeCtx, "side", strings.TrimSpace(out), "tester"))
+	_, pruneErr = repo.PruneHistory(testSuite.ctx, vcblobstore.RetentionPolicy{KeepVersions: 1})
+	testSuite.ErrorIs(pruneErr, local.ErrNonLinearHistory)
+
+	remoteRepo, createRepoErr := NewLocalGitTestRepo(&local.Config{Location: filepath.Join(testSuite.T().TempDir(), "copy"), RemoteURL: location})
+	testSuite.NoError(createRepoErr)
+	_, pruneErr = remoteRepo.PruneHistory(testSuite.ctx, vcblobstore.RetentionPolicy{KeepVersions: 1})
+	testSuite.ErrorIs(pruneErr, local.ErrRemotePruning)
+}
+
+func (testSuite *localGitRepoTestSuite) TestCompactHistory() {
+	location := filepath.Join(testSuite.T().TempDir(), "compacted")
+	repo, createRepoErr := NewLocalGitTestRepo(&local.Config{Location: location})
+	testSuite.NoError(createRepoErr)
+	testSuite.NoError(repo.CreateRepository(testSuite.ctx))
+
+	for _, key := range []string{"compacted/a", "compacted/b", "compacted/c", "compacted/d"} {
+		testSuite.NoError(repo.AddBlob(testSuite.ctx, createTestBlob(key, "ux")))
+	}
+	before, listErr := repo.ListVersions(testSuite.ctx, "", "")
+	testSuite.NoError(listErr)
+
+	report, compactErr := repo.CompactHistory(testSuite.ctx, before[2].Version)
+	testSuite.NoError(compactErr)
+	testSuite.Equal(vcblobstore.PruneReport{Squashed: 2, Kept: 2, StateID: report.StateID}, report)
+
+	after, listErr := repo.ListVersions(testSuite.ctx, "", "")
+	testSuite.NoError(listErr)
+	testSuite.Equal(3, len(after))
+	testSuite.Equal([]vcblobstore.KeyChange{
+		{Key: "compacted/a", Operation: vcblobstore.BlobOperationCreate},
+		{Key: "compacted/b", Operation: vcblobstore.BlobOperationCreate},
+	}, after[0].Changes)
+	testSuite.Equal(before[2].Changes, after[1].Changes)
+	testSuite.Equal(before[3].Message, after[2].Message)
+	keys, listKeysErr := repo.ListBlobKeys(testSuite.ctx, vcblobstore.ListOptions{})
+	testSuite.NoError(listKeysErr)
+	testSuite.Equal(4, len(keys))
+
+	_, compactErr = repo.CompactHistory(testSuite.ctx, before[2].Version)
+	testSuite.Error(compactErr)
+}
+
+func (testSuite *localGitRepoTestSuite) TestBranchWrites() {
+	location := filepath.Join(testSuite.T().TempDir(), "branched")
+	repo, createRepoErr := NewLocalGitTestRepo(&local.Config{Location: location})
+	testSuite.NoError(createRepoErr)
+	testSuite.NoError(repo.CreateRepository(testSuite.ctx))
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, createTestBlob("branched/main", "ux")))
+	mainState, stateErr := repo.GetStateID(testSuite.ctx)
+	testSuite.NoError(stateErr)
+
+	featureCtx := vcblobstore.WithBranch(testSuite.ctx, "feature")
+	featureBlob := createTestBlob("branched/feature", "ux")
+	testSuite.NoError(repo.AddBlob(featureCtx, featureBlob))
+	testSuite.NoError(repo.DeleteBlob(featureCtx, "branched/main", "ux"))
+
+	keys, listErr := repo.ListBlobKeys(featureCtx, vcblobstore.ListOptions{})
+	testSuite.NoError(listErr)
+	testSuite.Equal([]string{"branched/feature"}, keys)
+	content, getErr := repo.GetBlob(featureCtx, featureBlob.Key)
+	testSuite.NoError(getErr)
+	testSuite.Equal(featureBlob.Content, content)
+
+	keys, listErr = repo.ListBlobKeys(testSuite.ctx, vcblobstore.ListOptions{})
+	testSuite.NoError(listErr)
+	testSuite.Equal([]string{"branched/main"}, keys)
+	state, stateErr := repo.GetStateID(testSuite.ctx)
+	testSuite.NoError(stateErr)
+	testSuite.Equal(mainState, state)
+	head, headErr := repo.HeadBlob(testSuite.ctx, featureBlob.Key)
+	testSuite.NoError(headErr)
+	testSuite.False(head.Exists)
+	clean, statusErr := repo.CheckStatus()
+	testSuite.NoError(statusErr)
+	testSuite.True(clean)
+
+	history, historyErr := repo.GetBlobHistory(featureCtx, featureBlob.Key, vcblobstore.HistoryFilter{})
+	testSuite.NoError(historyErr)
+	testSuite.Equal(1, len(history))
+	history, historyErr = repo.GetBlobHistory(featureCtx, featureBlob.Key, vcblobstore.HistoryFilter{SinceVersion: mainState})
+	testSuite.NoError(historyErr)
+	testSuite.Equal(1, len(history))
+	versions, versionsErr := repo.ListVersions(featureCtx, mainState, "")
+	testSuite.NoError(versionsErr)
+	testSuite.Equal(2, len(versions))
+	changes, changesErr := repo.ListChangesBetween(featureCtx, mainState, "")
+	testSuite.NoError(changesErr)
+	testSuite.Equal([]vcblobstore.KeyChange{
+		{Key: "branched/feature", Operation: vcblobstore.BlobOperationCreate},
+		{Key: "branched/main", Operation: vcblobstore.BlobOperationDelete},
+	}, changes)
+	annotations, annotationsErr := repo.GetBlobAnnotations(featureCtx, []string{featureBlob.Key})
+	testSuite.NoError(annotationsErr)
+	testSuite.Equal(versions[0].Version, annotations[0].Version)
+	bundle := bytes.Buffer{}
+	testSuite.NoError(repo.ExportHistory(featureCtx, []string{featureBlob.Key}, &bundle))
+	records := []vcblobstore.HistoryRecord{}
+	testSuite.NoError(vcblobstore.ReadHistory(&bundle, func(record vcblobstore.HistoryRecord) error {
+		records = append(records, record)
+		return nil
+	}))
+	testSuite.Equal(1, len(records))
+	testSuite.Equal(featureBlob.Content, records[0].Content)
+	testSuite.NoError(repo.RevertToState(featureCtx, mainState, "ux"))
+	keys, listErr = repo.ListBlobKeys(featureCtx, vcblobstore.ListOptions{})
+	testSuite.NoError(listErr)
+	testSuite.Equal([]string{"branched/main"}, keys)
+	testSuite.NoError(repo.AddBlob(featureCtx, featureBlob))
+	testSuite.NoError(repo.DeleteBlob(featureCtx, "branched/main", "ux"))
+
+	_, getErr = repo.GetBlob(vcblobstore.WithBranch(testSuite.ctx, "feature~1"), featureBlob.Key)
+	testSuite.ErrorIs(getErr, vcblobstore.ErrInvalidBranchName)
+	testSuite.ErrorIs(repo.AddBlob(vcblobstore.WithBranch(testSuite.ctx, "feature~1"), featureBlob), vcblobstore.ErrInvalidBranchName)
+
+	featureRepo, createRepoErr := NewLocalGitTestRepo(&local.Config{Location: location, Branch: "feature"})
+	testSuite.NoError(createRepoErr)
+	head, headErr = featureRepo.HeadBlob(testSuite.ctx, featureBlob.Key)
+	testSuite.NoError(headErr)
+	testSuite.True(head.Exists)
+
+	copyRepo, createRepoErr := NewLocalGitTestRepo(&local.Config{Location: filepath.Join(testSuite.T().TempDir(), "copy"), RemoteURL: location})
+	testSuite.NoError(createRepoErr)
+	testSuite.NoError(copyRepo.CreateRepository(testSuite.ctx))
+	testSuite.NoError(copyRepo.AddBlob(featureCtx, createTestBlob("branched/pushed", "ux")))
+	keys, listErr = repo.ListBlobKeys(featureCtx, vcblobstore.ListOptions{})
+	testSuite.NoError(listErr)
+	testSuite.Equal([]string{"branched/feature", "branched/pushed"}, keys)
+}
+
+func (testSuite *localGitRepoTestSuite) TestMergeBranch() {
+	location := filepath.Join(testSuite.T().TempDir(), "merged")
+	repo, createRepoErr := NewLocalGitTestRepo(&local.Config{Location: location})
+	testSuite.NoError(createRepoErr)
+	testSuite.NoError(repo.CreateRepository(testSuite.ctx))
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, createTestBlob("merged/base", "ux")))
+	out, branchErr := repo.ExecuteGitCommand(testSuite.ctx, []string{"symbolic-ref", "--short", "HEAD"})
+	testSuite.NoError(branchErr)
+	production := strings.TrimSpace(out)
+	stagingCtx := vcblobstore.WithBranch(testSuite.ctx, "staging")
+
+	staged := createTestBlob("merged/staged", "ux")
+	testSuite.NoError(repo.AddBlob(stagingCtx, staged))
+	stagingState, stateErr := repo.GetStateID(stagingCtx)
+	testSuite.NoError(stateErr)
+	fastForwardCtx := vcblobstore.WithMergeStrategy(testSuite.ctx, vcblobstore.MergeFastForward)
+	testSuite.NoError(repo.MergeBranch(fastForwardCtx, "staging", production, "promoter"))
+	state, stateErr := repo.GetStateID(testSuite.ctx)
+	testSuite.NoError(stateErr)
+	testSuite.Equal(stagingState, state)
+	content, getErr := repo.GetBlob(testSuite.ctx, staged.Key)
+	testSuite.NoError(getErr)
+	testSuite.Equal(staged.Content, content)
+	clean, statusErr := repo.CheckStatus()
+	testSuite.NoError(statusErr)
+	testSuite.True(clean)
+	testSuite.NoError(repo.MergeBranch(testSuite.ctx, "staging", production, "promoter"))
+
+	testSuite.NoError(repo.AddBlob(stagingCtx, createTestBlob("merged/second", "ux")))
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, createTestBlob("merged/hotfix", "ux")))
+	testSuite.ErrorIs(repo.MergeBranch(fastForwardCtx, "staging", production, "promoter"), vcblobstore.ErrNotFastForward)
+	testSuite.NoError(repo.MergeBranch(testSuite.ctx, "staging", production, "promoter"))
+	keys, listErr := repo.ListBlobKeys(testSuite.ctx, vcblobstore.ListOptions{})
+	testSuite.NoError(listErr)
+	testSuite.Equal([]string{"merged/base", "merged/hotfix", "merged/second", "merged/staged"}, keys)
+	out, logErr := repo.ExecuteGitCommand(testSuite.ctx, []string{"log", "-1", "--format=%P%x1f%an%x1f%s"})
+	testSuite.NoError(logErr)
+	fields := strings.Split(strings.TrimSpace(out), "\x1f")
+	testSuite.Equal(2, len(strings.Fields(fields[0])))
+	testSuite.Equal("promoter", fields[1])
+
+	testSuite.NoError(repo.AddBlob(stagingCtx, createTestBlob(staged.Key, "ux")))
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, createTestBlob(staged.Key, "ux")))
+	mergeErr := repo.MergeBranch(testSuite.ctx, "staging", production, "promoter")
+	testSuite.ErrorIs(mergeErr, vcblobstore.ErrConflict)
+	conflict := &vcblobstore.MergeConflictError{}
+	testSuite.ErrorAs(mergeErr, &conflict)
+	testSuite.Equal([]string{staged.Key}, conflict.Keys)
+	clean, statusErr = repo.CheckStatus()
+	testSuite.NoError(statusErr)
+	testSuite.True(clean)
+	resolved := createTestBlob(staged.Key, "ux")
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, resolved))
+	testSuite.NoError(repo.AddBlob(stagingCtx, resolved))
+	testSuite.NoError(repo.MergeBranch(testSuite.ctx, "staging", production, "promoter"))
+
+	for _, labelled := range []struct {
+		ctx context.Context
+		key string
+	}{{stagingCtx, "merged/labelled-staged"}, {testSuite.ctx, "merged/labelled-hotfix"}} {
+		blob := createTestBlob(labelled.key, "ux")
+		blob.Metadata = map[string]string{"team": "payments"}
+		testSuite.NoError(repo.AddBlob(labelled.ctx, blob))
+	}
+	testSuite.NoError(repo.MergeBranch(testSuite.ctx, "staging", production, "promoter"))
+	keys, queryErr := repo.QueryByAttributes(testSuite.ctx, vcblobstore.AttributeSelector{"team": "payments"})
+	testSuite.NoError(queryErr)
+	testSuite.Equal([]string{"merged/labelled-hotfix", "merged/labelled-staged"}, keys)
+}
+
+func (testSuite *localGitRepoTestSuite) TestCompositeStore() {
+	primary, primaryErr := NewLocalGitTestRepo(&local.Config{Location: filepath.Join(testSuite.T().TempDir(), "primary")})
+	testSuite.NoError(primaryErr)
+	testSuite.NoError(primary.CreateRepository(testSuite.ctx))
+	secondary, secondaryErr := NewJournalTestStore(&journal.Config{Location: filepath.Join(testSuite.T().TempDir(), "secondary")})
+	testSuite.NoError(secondaryErr)
+	testSuite.NoError(secondary.CreateRepository(testSuite.ctx))
+
+	migrated := createTestBlob("icons/migrated", "ux")
+	legacy := createTestBlob("icons/legacy", "ux")
+	testSuite.NoError(primary.AddBlob(testSuite.ctx, migrated))
+	testSuite.NoError(secondary.AddBlob(testSuite.ctx, legacy))
+
+	store := composite.New(faulty.Wrap(primary, faulty.FaultPlan{{Operation: "GetBlob", OnCall: 1}}), secondary).WithBackfill("migration")
+	content, getErr := store.GetBlob(testSuite.ctx, migrated.Key)
+	testSuite.ErrorIs(getErr, vcblobstore.ErrBlobNotFound)
+	content, getErr = store.GetBlob(testSuite.ctx, migrated.Key)
+	testSuite.NoError(getErr)
+	testSuite.Equal(migrated.Content, content)
+
+	content, getErr = store.GetBlob(testSuite.ctx, legacy.Key)
+	testSuite.NoError(getErr)
+	testSuite.Equal(legacy.Content, content)
+	store.Wait()
+	content, getErr = primary.GetBlob(testSuite.ctx, legacy.Key)
+	testSuite.NoError(getErr)
+	testSuite.Equal(legacy.Content, content)
+
+	keys, listErr := store.ListBlobKeys(testSuite.ctx, vcblobstore.ListOptions{Prefix: "icons/"})
+	testSuite.NoError(listErr)
+	testSuite.Equal([]string{legacy.Key, migrated.Key}, keys)
+
+	testSuite.NoError(store.DeleteBlob(testSuite.ctx, legacy.Key, "ux"))
+	_, getErr = store.GetBlob(testSuite.ctx, legacy.Key)
+	testSuite.ErrorIs(getErr, vcblobstore.ErrBlobNotFound)
+}
+
+func (testSuite *localGitRepoTestSuite) TestDegradingStore() {
+	blob := createTestBlob("degradation", "ux")
+	testSuite.NoError(testSuite.gitRepoClient.AddBlob(testSuite.ctx, blob))
+
+	flaky := faulty.Wrap(testSuite.gitRepoClient, faulty.FaultPlan{
+		{Operation: "GetBlob", OnCall: 2, Err: vcblobstore.ErrRateLimited},
+		{Operation: "GetBlob", OnCall: 3, Err: vcblobstore.ErrConflict},
+		{Operation: "AddBlob", OnCall: 1, Err: vcblobstore.ErrRateLimited},
+	})
+	store := vcblobstore.NewDegradingStore(flaky, vcblobstore.DegradationPolicy{
+		Actions: map[vcblobstore.FailureClass]vcblobstore.DegradationAction{
+			vcblobstore.FailureRateLimited: vcblobstore.DegradeServeStale,
+			vcblobstore.FailureBackendDown: vcblobstore.DegradeQueue,
+		},
+	})
+
+	content, getErr := store.GetBlob(testSuite.ctx, blob.Key)
+	testSuite.NoError(getErr)
+	content, getErr = store.GetBlob(testSuite.ctx, blob.Key)
+	testSuite.NoError(getErr)
+	testSuite.Equal(blob.Content, content)
+	_, getErr = store.GetBlob(testSuite.ctx, blob.Key)
+	testSuite.ErrorIs(getErr, vcblobstore.ErrConflict)
+
+	// Rate limited writes are not queued by the policy
+	update := createTestBlob(blob.Key, "ux")
+	testSuite.ErrorIs(store.AddBlob(testSuite.ctx, update), vcblobstore.ErrRateLimited)
+
+	dropped := []string{}
+	queueing := vcblobstore.NewDegradingStore(faulty.Wrap(testSuite.gitRepoClient, faulty.FaultPlan{
+		{Operation: "AddBlob", OnCall: 1},
+		{Operation: "AddBlob", OnCall: 2},
+		{Operation: "DeleteBlob", OnCall: 1},
+	}), vcblobstore.DegradationPolicy{
+		Actions: map[vcblobstore.FailureClass]vcblobstore.DegradationAction{vcblobstore.FailureBackendDown: vcblobstore.DegradeQueue},
+		OnDropped: func(operation string, err error) {
+			dropped = append(dropped, operation)
+		},
+	})
+	testSuite.ErrorIs(queueing.AddBlob(testSuite.ctx, update), vcblobstore.ErrWriteQueued)
+	testSuite.Equal(1, queueing.Pending())
+	second := createTestBlob("degradation-2", "ux")
+	testSuite.ErrorIs(queueing.AddBlob(testSuite.ctx, second), vcblobstore.ErrWriteQueued)
+	testSuite.Equal(2, queueing.Pending())
+	// Writing replays the queued writes first
+	testSuite.ErrorIs(queueing.DeleteBlob(testSuite.ctx, "no-such-blob", "ux"), vcblobstore.ErrWriteQueued)
+	testSuite.Equal(1, queueing.Pending())
+
+	testSuite.NoError(queueing.Flush(testSuite.ctx))
+	testSuite.Equal(0, queueing.Pending())
+	testSuite.Equal([]string{"DeleteBlob"}, dropped)
+	for _, written := range []vcblobstore.BlobInfo{update, second} {
+		content, getErr = testSuite.gitRepoClient.GetBlob(testSuite.ctx, written.Key)
+		testSuite.NoError(getErr)
+		testSuite.Equal(written.Content, content)
+	}
+}
+
+func (testSuite *localGitRepoTestSuite) TestDegradingStoreBranches() {
+	repo, createRepoErr := NewLocalGitTestRepo(&local.Config{Location: filepath.Join(testSuite.T().TempDir(), "degraded")})
+	testSuite.NoError(createRepoErr)
+	testSuite.NoError(repo.CreateRepository(testSuite.ctx))
+	featureCtx := vcblobstore.WithBranch(testSuite.ctx, "feature")
+	blob := createTestBlob("degradation", "ux")
+	testSuite.NoError(repo.AddBlob(testSuite.ctx, blob))
+	testSuite.NoError(repo.AddBlob(featureCtx, createTestBlob(blob.Key, "ux")))
+
+	store := vcblobstore.NewDegradingStore(faulty.Wrap(repo, faulty.FaultPlan{
+		{Operation: "GetBlob", OnCall: 2, Err: vcblobstore.ErrRateLimited},
+		{Operation: "AddBlob", OnCall: 1},
+	}), vcblobstore.DegradationPolicy{
+		Actions: map[vcblobstore.FailureClass]vcblobstore.DegradationAction{
+			vcblobstore.FailureRateLimited: vcblobstore.DegradeServeStale,
+			vcblobstore.FailureBackendDown: vcblobstore.DegradeQueue,
+		},
+	})
+
+	// The content last seen on main is no stale copy of the blob on the feature branch
+	content, getErr := store.GetBlob(testSuite.ctx, blob.Key)
+	testSuite.NoError(getErr)
+	testSuite.Equal(blob.Content, content)
+	_, getErr = store.GetBlob(featureCtx, blob.Key)
+	testSuite.ErrorIs(getErr, vcblobstore.ErrRateLimited)
+
+	// The queued write is replayed to the branch it was made to, whatever the context of the flush
+	queued := createTestBlob("degradation-queued", "ux")
+	testSuite.ErrorIs(store.AddBlob(featureCtx, queued), vcblobstore.ErrWriteQueued)
+	testSuite.NoError(store.Flush(testSuite.ctx))
+	content, getErr = repo.GetBlob(featureCtx, queued.Key)
+	testSuite.NoError(getErr)
+	testSuite.Equal(queued.Content, content)
+	head, headErr := repo.HeadBlob(testSuite.ctx, queued.Key)
+	testSuite.NoError(headErr)
+	testSuite.False(head.Exists)
+}
+
+func (testSuite *localGitRepoTestSuite) TestCachingStore() {
+	blob := createTestBlob("cached", "ux")
+	testSuite.NoError(testSuite.gitRepoClient.AddBlob(testSuite.ctx, blob))
+	other := createTestBlob("cached-2", "ux")
+	testSuite.NoError(testSuite.gitRepoClient.AddBlob(testSuite.ctx, other))
+
+	counting := faulty.Wrap(testSuite.gitRepoClient, faulty.FaultPlan{})
+	store := vcblobstore.NewCachingStore(counting, vcblobstore.CacheConfig{CapacityBytes: int64(len(blob.Content))})
+
+	for range 3 {
+		content, getErr := store.GetBlob(testSuite.ctx, blob.Key)
+		testSuite.NoError(getErr)
+		testSuite.Equal(blob.Content, content)
+	}
+	testSuite.Equal(1, counting.Calls("GetBlob"))
+
+	// Reading the other blob evicts the first one, which is read cold again
+	_, getErr := store.GetBlob(testSuite.ctx, other.Key)
+	testSuite.NoError(getErr)
+	_, getErr = store.GetBlob(testSuite.ctx, blob.Key)
+	testSuite.NoError(getErr)
+	testSuite.Equal(3, counting.Calls("GetBlob"))
+
+	update := createTestBlob(blob.Key, "ux")
+	testSuite.NoError(store.AddBlob(testSuite.ctx, update))
+	content, getErr := store.GetBlob(testSuite.ctx, blob.Key)
+	testSuite.NoError(getErr)
+	testSuite.Equal(update.Content, content)
+
+	stats := store.Stats()
+	testSuite.Equal(int64(2), stats.WarmReads)
+	testSuite.Equal(int64(4), stats.ColdReads)
+	testSuite.Equal(int64(2), stats.Evictions)
+	testSuite.Equal(2, stats.WorkingSetKeys)
+
+	recommendation := store.Recommend()
+	testSuite.Greater(recommendation.CapacityBytes, int64(len(blob.Content)))
+	testSuite.Equal([]string{blob.Key}, recommendation.PinCandidates)
+	testSuite.GreaterOrEqual(recommendation.TTL, time.Second)
+
+	store.ResetStats()
+	testSuite.Equal(0, store.Stats().WorkingSetKeys)
+
+	// The callers get copies they may modify without corrupting the cache
+	store = vcblobstore.NewCachingStore(counting, vcblobstore.CacheConfig{})
+	labelled := createTestBlob("cached-labelled", "ux")
+	labelled.Metadata = map[string]string{"team": "payments"}
+	testSuite.NoError(store.AddBlob(testSuite.ctx, labelled))
+	for range 2 {
+		info, infoErr := store.GetBlobInfo(testSuite.ctx, labelled.Key)
+		testSuite.NoError(infoErr)
+		testSuite.Equal(labelled.Content, info.Content)
+		testSuite.Equal(labelled.Metadata, info.Metadata)
+		info.Content[0] ^= 0xff
+		info.Metadata["team"] = "growth"
+	}
+
+	// The branches are cached apart
+	staging := vcblobstore.WithBranch(testSuite.ctx, "staging")
+	staged := createTestBlob(labelled.Key, "ux")
+	testSuite.NoError(store.AddBlob(staging, staged))
+	for range 2 {
+		content, getErr = store.GetBlob(staging, labelled.Key)
+		testSuite.NoError(getErr)
+		testSuite.Equal(staged.Content, content)
+		content, getErr = store.GetBlob(testSuite.ctx, labelled.Key)
+		testSuite.NoError(getErr)
+		testSuite.Equal(labelled.Content, content)
+	}
+}
+
+func (testSuite *localGitRepoTestSuite) TestMirror() {
+	primary, primaryErr := NewLocalGitTestRepo(&local.Config{Location: filepath.Join(testSuite.T().TempDir(), "primary")})
+	testSuite.NoError(primaryErr)
+	secondary, secondaryErr := NewJournalTestStore(&journal.Config{Location: filepath.Join(testSuite.T().TempDir(), "secondary")})
+	testSuite.NoError(secondaryErr)
+	flakySecondary := faulty.Wrap(secondary, faulty.FaultPlan{{Operation: "AddBlob", OnCall: 2}})
+
+	store := mirror.New(primary, flakySecondary, mirror.BestEffort)
+	testSuite.NoError(store.CreateRepository(testSuite.ctx))
+	mirrored := createTestBlob("mirrored", "ux")
+	diverging := createTestBlob("diverging", "ux")
+	testSuite.NoError(store.AddBlob(testSuite.ctx, mirrored))
+	testSuite.NoError(store.AddBlob(testSuite.ctx, diverging))
+
+	divergences := store.Divergences()
+	testSuite.Len(divergences, 1)
+	testSuite.Equal(diverging.Key, divergences[0].Key)
+	compared, compareErr := store.Compare(testSuite.ctx, vcblobstore.ListOptions{})
+	testSuite.NoError(compareErr)
+	testSuite.Len(compared, 1)
+	testSuite.Equal("missing from secondary store", compared[0].Reason)
+
+	diverging.Content = []byte("rewritten")
+	testSuite.NoError(store.AddBlob(testSuite.ctx, diverging))
+	testSuite.Empty(store.Divergences())
+	compared, compareErr = store.Compare(testSuite.ctx, vcblobstore.ListOptions{})
+	testSuite.NoError(compareErr)
+	testSuite.Empty(compared)
+
+	strict := mirror.New(primary, faulty.Wrap(secondary, faulty.FaultPlan{{Operation: "DeleteBlob"}}), mirror.Strict)
+	testSuite.ErrorIs(strict.DeleteBlob(testSuite.ctx, mirrored.Key, "ux"), mirror.ErrDiverged)
+	_, getErr := primary.GetBlob(testSuite.ctx, mirrored.Key)
+	testSuite.ErrorIs(getErr, vcblobstore.ErrBlobNotFound)
+}
+
+func (testSuite *localGitRepoTestSuite) TestPromote() {
+	dev, devErr := NewLocalGitTestRepo(&local.Config{Location: filepath.Join(testSuite.T().TempDir(), "dev")})
+	testSuite.NoError(devErr)
+	staging, stagingErr := NewJournalTestStore(&journal.Config{Location: filepath.Join(testSuite.T().TempDir(), "staging")})
+	testSuite.NoError(stagingErr)
+	prod, prodErr := NewLocalGitTestRepo(&local.Config{Location: filepath.Join(testSuite.T().TempDir(), "prod")})
+	testSuite.NoError(prodErr)
+	for _, store := range []vcblobstore.Store{dev, staging, prod} {
+		testSuite.NoError(store.CreateRepository(testSuite.ctx))
+	}
+	topology, topologyErr := vcblobstore.NewTopology(
+		vcblobstore.Environment{Name: "dev", Store: dev},
+		vcblobstore.Environment{Name: "staging", Store: staging},
+		vcblobstore.Environment{Name: "prod", Store: prod},
+	)
+	testSuite.NoError(topologyErr)
+
+	settings := createTestBlob("settings", "ux")
+	settings.Metadata = map[string]string{"owner": "platform"}
+	testSuite.NoError(dev.AddBlob(testSuite.ctx, settings))
+	testSuite.NoError(dev.CreateAlias(testSuite.ctx, "current-settings", settings.Key, "ux"))
+	testSuite.NoError(staging.AddBlob(testSuite.ctx, createTestBlob("obsolete", "ux")))
+	testSuite.NoError(prod.AddBlob(testSuite.ctx, createTestBlob("obsolete", "ux")))
+	state, stateErr := dev.GetStateID(testSuite.ctx)
+	testSuite.NoError(stateErr)
+
+	promotion, promoteErr := topology.Promote(testSuite.ctx, "dev", "staging", state, "release")
+	testSuite.NoError(promoteErr)
+	testSuite.Equal([]string{settings.Key}, promotion.Written)
+	testSuite.Equal([]string{"obsolete"}, promotion.Deleted)
+	testSuite.Equal([]string{"current-settings"}, promotion.Aliased)
+	aliasTarget, resolveErr := staging.ResolveAlias(testSuite.ctx, "current-settings")
+	testSuite.NoError(resolveErr)
+	testSuite.Equal(settings.Key, aliasTarget)
+	promoted, getErr := staging.GetBlobInfo(testSuite.ctx, settings.Key)
+	testSuite.NoError(getErr)
+	testSuite.Equal(settings.Content, promoted.Content)
+	testSuite.Equal(settings.Metadata, promoted.Metadata)
+	keys, listErr := staging.ListBlobKeys(testSuite.ctx, vcblobstore.ListOptions{})
+	testSuite.NoError(listErr)
+	testSuite.Equal([]string{settings.Key}, keys)
+
+	stagingState, stagingStateErr := staging.GetStateID(testSuite.ctx)
+	testSuite.NoError(stagingStateErr)
+	promotedVersion, versionErr := staging.GetVersionFor(testSuite.ctx, settings.Key)
+	testSuite.NoError(versionErr)
+	provenance, metadataErr := staging.GetVersionMetadata(testSuite.ctx, promotedVersion)
+	testSuite.NoError(metadataErr)
+	testSuite.Contains(provenance.Message, promotion.Provenance)
+
+	promotion, promoteErr = topology.Promote(testSuite.ctx, "dev", "staging", state, "release")
+	testSuite.NoError(promoteErr)
+	testSuite.Empty(promotion.Written)
+	testSuite.Empty(promotion.Aliased)
+
+	// What gets promoted is the reviewed state, not what dev went through since
+	testSuite.NoError(dev.AddBlob(testSuite.ctx, createTestBlob("unreviewed", "ux")))
+	testSuite.NoError(dev.UpdateBlobMetadata(testSuite.ctx, settings.Key, map[string]string{"owner": "nobody"}, "ux"))
+	promotion, promoteErr = topology.Promote(testSuite.ctx, "dev", "prod", state, "release")
+	testSuite.NoError(promoteErr)
+	testSuite.Equal([]string{settings.Key}, promotion.Written)
+	testSuite.Equal([]string{"obsolete"}, promotion.Deleted)
+	testSuite.Equal([]string{"current-settings"}, promotion.Aliased)
+	promoted, getErr = prod.GetBlobInfo(testSuite.ctx, settings.Key)
+	testSuite.NoError(getErr)
+	testSuite.Equal(settings.Metadata, promoted.Metadata)
+
+	promotion, promoteErr = topology.Promote(testSuite.ctx, "staging", "prod", stagingState, "release")
+	testSuite.NoError(promoteErr)
+	testSuite.Empty(promotion.Written)
+	testSuite.Empty(promotion.Deleted)
+	testSuite.Empty(promotion.Aliased)
+	_, promoteErr = topology.Promote(testSuite.ctx, "prod", "staging", state, "release")
+	testSuite.ErrorIs(promoteErr, vcblobstore.ErrInvalidPromotion)
+}
+
+func (testSuite *localGitRepoTestSuite) TestPromoteMode() {
+	dev, devErr := NewLocalGitTestRepo(&local.Config{Location: filepath.Join(testSuite.T().TempDir(), "dev")})
+	testSuite.NoError(devErr)
+	prod, prodErr := NewLocalGitTestRepo(&local.Config{Location: filepath.Join(testSuite.T().TempDir(), "prod")})
+	testSuite.NoError(prodErr)
+	for _, store := range []vcblobstore.Store{dev, prod} {
+		testSuite.NoError(store.CreateRepository(testSuite.ctx))
+	}
+	topology, topologyErr := vcblobstore.NewTopology(
+		vcblobstore.Environment{Name: "dev", Store: dev},
+		vcblobstore.Environment{Name: "prod", Store: prod},
+	)
+	testSuite.NoError(topologyErr)
+
+	script := createTestBlob("deploy.sh", "ux")
+	testSuite.NoError(prod.AddBlob(testSuite.ctx, script))
+	script.Mode = vcblobstore.FileModeExecutable
+	testSuite.NoError(dev.AddBlob(testSuite.ctx, script))
+	state, stateErr := dev.GetStateID(testSuite.ctx)
+	testSuite.NoError(stateErr)
+
+	// Only the mode differs
+	promotion, promoteErr := topology.Promote(testSuite.ctx, "dev", "prod", state, "release")
+	testSuite.NoError(promoteErr)
+	testSuite.Equal([]string{script.Key}, promotion.Written)
+	head, headErr := prod.HeadBlob(testSuite.ctx, script.Key)
+	testSuite.NoError(headErr)
+	testSuite.Equal(vcblobstore.FileModeExecutable, head.Mode)
+
+	promotion, promoteErr = topology.Promote(testSuite.ctx, "dev", "prod", state, "release")
+	testSuite.NoError(promoteErr)
+	testSuite.Empty(promotion.Written)
 }
@@ -1,8 +1,9 @@
 package test
 
 import (
-	"vcblobstore"
 	"crypto/rand"
+	"maps"
+	"vcblobstore"
 )
 
 func createTestBlob(key string, modifiedBy string) vcblobstore.BlobInfo {
@@ -17,9 +18,13 @@ func CloneBlob(blob vcblobstore.BlobInfo) vcblobstore.BlobInfo {
 	contentClone := make([]byte, len(blob.Content))
 	copy(contentClone, blob.Content)
 	return vcblobstore.BlobInfo{
-		Key:        blob.Key,
-		Content:    contentClone,
-		ModifiedBy: blob.ModifiedBy,
+		Key:         blob.Key,
+		Content:     contentClone,
+		ModifiedBy:  blob.ModifiedBy,
+		AuthorName:  blob.AuthorName,
+		AuthorEmail: blob.AuthorEmail,
+		Metadata:    maps.Clone(blob.Metadata),
+		SHA256:      blob.SHA256,
 	}
 }
 
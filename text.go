@@ -0,0 +1,57 @@
+package vcblobstore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"unicode/utf8"
+)
+
+var ErrInvalidTextContent = errors.New("invalid text content")
+
+// TextModeRule makes the blobs with keys matching Pattern (path.Match syntax, e.g. "configs/*.yaml") be treated as text on write
+type TextModeRule struct {
+	Pattern string
+	// NormalizeLineEndings converts CRLF and lone CR line endings to LF
+	NormalizeLineEndings bool
+	// RequireUTF8 rejects content which is not valid UTF-8
+	RequireUTF8 bool
+}
+
+// TextMode is an ordered list of rules; the first rule with a matching pattern applies
+type TextMode []TextModeRule
+
+// Applies tells whether a rule applies to the blob with the key. An invalid pattern counts as applying, so that Apply
+// reports it
+func (textMode TextMode) Applies(key string) bool {
+	for _, rule := range textMode {
+		if matches, matchErr := path.Match(rule.Pattern, key); matches || matchErr != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply returns the content normalized according to the first rule matching the key (the content as is, if none matches)
+func (textMode TextMode) Apply(key string, content []byte) ([]byte, error) {
+	for _, rule := range textMode {
+		matches, matchErr := path.Match(rule.Pattern, key)
+		if matchErr != nil {
+			return nil, fmt.Errorf("invalid text mode pattern %s: %w", rule.Pattern, matchErr)
+		}
+		if !matches {
+			continue
+		}
+
+		if rule.RequireUTF8 && !utf8.Valid(content) {
+			return nil, fmt.Errorf("content of %s is not valid UTF-8: %w", key, ErrInvalidTextContent)
+		}
+		if rule.NormalizeLineEndings {
+			content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+			content = bytes.ReplaceAll(content, []byte("\r"), []byte("\n"))
+		}
+		return content, nil
+	}
+	return content, nil
+}
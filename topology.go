@@ -0,0 +1,276 @@
+package vcblobstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+
+	"github.com/rs/zerolog"
+)
+
+var ErrUnknownEnvironment = errors.New("unknown environment")
+
+var ErrInvalidPromotion = errors.New("invalid promotion")
+
+// Environment is a store of a promotion path, e.g. the configuration store of staging
+type Environment struct {
+	Name  string
+	Store Store
+}
+
+// Topology describes related stores ordered along their promotion path, e.g. dev → staging → prod
+type Topology struct {
+	environments []Environment
+}
+
+func NewTopology(environments ...Environment) (*Topology, error) {
+	names := map[string]bool{}
+	for _, environment := range environments {
+		if len(environment.Name) == 0 || environment.Store == nil {
+			return nil, fmt.Errorf("environment %q is incomplete: %w", environment.Name, ErrInvalidPromotion)
+		}
+		if names[environment.Name] {
+			return nil, fmt.Errorf("environment %s is listed twice: %w", environment.Name, ErrInvalidPromotion)
+		}
+		names[environment.Name] = true
+	}
+	return &Topology{environments: environments}, nil
+}
+
+// Environments returns the environments in promotion order
+func (topology *Topology) Environments() []Environment {
+	return append([]Environment{}, topology.environments...)
+}
+
+func (topology *Topology) indexOf(name string) (int, error) {
+	for index, environment := range topology.environments {
+		if environment.Name == name {
+			return index, nil
+		}
+	}
+	return -1, fmt.Errorf("%s: %w", name, ErrUnknownEnvironment)
+}
+
+func (topology *Topology) Environment(name string) (Environment, error) {
+	index, err := topology.indexOf(name)
+	if err != nil {
+		return Environment{}, err
+	}
+	return topology.environments[index], nil
+}
+
+// Next returns the environment the named one promotes to and false if it is the last one
+func (topology *Topology) Next(name string) (Environment, bool) {
+	index, err := topology.indexOf(name)
+	if err != nil || index == len(topology.environments)-1 {
+		return Environment{}, false
+	}
+	return topology.environments[index+1], true
+}
+
+// Promotion records what a promotion did. Provenance is the message of the version it created in the target
+type Promotion struct {
+	From       string
+	To         string
+	StateID    string
+	Provenance string
+	Written    []string
+	Deleted    []string
+	// Aliased lists the aliases created or redirected in the target
+	Aliased []string
+}
+
+// PastStateReader is implemented by the stores which can read a past state in full: the blobs with their metadata
+// and modes and the aliases as they were then
+type PastStateReader interface {
+	SnapshotSource
+	// GetBlobMetadataAtVersion returns the metadata of the blob at the version, ErrBlobNotFound if it didn't exist then
+	GetBlobMetadataAtVersion(ctx context.Context, key string, commitId string) (map[string]string, error)
+	// GetBlobModeAtVersion returns the file mode of the blob at the version, ErrBlobNotFound if it didn't exist then
+	GetBlobModeAtVersion(ctx context.Context, key string, commitId string) (FileMode, error)
+	ListAliasesAtVersion(ctx context.Context, commitId string) ([]Alias, error)
+}
+
+// Promote makes the blobs (and their metadata) of the toEnv store exactly those of the fromEnv store at stateID (so
+// that what gets promoted is what has been reviewed, whatever fromEnv went through since) and creates the aliases
+// fromEnv had then. The fromEnv store has to be a PastStateReader. The blob changes are recorded as a single version
+// with the provenance in its message if the target store is a ChangeApplier, one by one otherwise; the aliases are
+// created after them. The aliases of toEnv missing from the state are left in place, since a Store can't remove an
+// alias. toEnv has to come after fromEnv in the topology
+func (topology *Topology) Promote(ctx context.Context, fromEnv string, toEnv string, stateID string, modifiedBy string) (Promotion, error) {
+	logger := zerolog.Ctx(ctx).With().Str("method", "Promote").Str("from", fromEnv).Str("to", toEnv).Str("stateID", stateID).Logger()
+
+	fromIndex, fromErr := topology.indexOf(fromEnv)
+	if fromErr != nil {
+		return Promotion{}, fromErr
+	}
+	toIndex, toErr := topology.indexOf(toEnv)
+	if toErr != nil {
+		return Promotion{}, toErr
+	}
+	if toIndex <= fromIndex {
+		return Promotion{}, fmt.Errorf("%s doesn't promote to %s: %w", fromEnv, toEnv, ErrInvalidPromotion)
+	}
+	source := topology.environments[fromIndex].Store
+	target := topology.environments[toIndex].Store
+
+	sourceBlobs, sourceAliases, readErr := readState(ctx, source, stateID)
+	if readErr != nil {
+		return Promotion{}, fmt.Errorf("failed to read state %s of %s: %w", stateID, fromEnv, readErr)
+	}
+
+	promotion := Promotion{
+		From:       fromEnv,
+		To:         toEnv,
+		StateID:    stateID,
+		Provenance: fmt.Sprintf("promote %s@%s to %s", fromEnv, stateID, toEnv),
+		Written:    []string{},
+		Deleted:    []string{},
+		Aliased:    []string{},
+	}
+	changes, diffErr := diffState(ctx, target, sourceBlobs)
+	if diffErr != nil {
+		return Promotion{}, fmt.Errorf("failed to compare %s with %s: %w", toEnv, fromEnv, diffErr)
+	}
+	aliases, aliasDiffErr := diffAliases(ctx, target, sourceAliases)
+	if aliasDiffErr != nil {
+		return Promotion{}, fmt.Errorf("failed to compare the aliases of %s with %s: %w", toEnv, fromEnv, aliasDiffErr)
+	}
+	if len(changes) == 0 && len(aliases) == 0 {
+		logger.Info().Msg("Nothing to promote")
+		return promotion, nil
+	}
+
+	author := Author{Name: modifiedBy}
+	applier, atomic := target.(ChangeApplier)
+	switch {
+	case len(changes) == 0:
+		// Only the aliases differ
+	case atomic:
+		if applyErr := applier.ApplyChanges(ctx, changes, promotion.Provenance, author); applyErr != nil {
+			return Promotion{}, fmt.Errorf("failed to promote %s@%s to %s: %w", fromEnv, stateID, toEnv, applyErr)
+		}
+	default:
+		logger.Warn().Msg("Target store can't apply changes atomically, promoting blob by blob")
+		for _, change := range changes {
+			var writeErr error
+			if change.Delete {
+				writeErr = target.DeleteBlob(ctx, change.Blob.Key, modifiedBy)
+			} else {
+				writeErr = target.AddBlob(ctx, change.Blob)
+			}
+			if writeErr != nil {
+				return Promotion{}, fmt.Errorf("failed to promote %s of %s@%s to %s: %w", change.Blob.Key, fromEnv, stateID, toEnv, writeErr)
+			}
+		}
+	}
+
+	for _, change := range changes {
+		if change.Delete {
+			promotion.Deleted = append(promotion.Deleted, change.Blob.Key)
+		} else {
+			promotion.Written = append(promotion.Written, change.Blob.Key)
+		}
+	}
+	for _, alias := range aliases {
+		if aliasErr := target.CreateAlias(ctx, alias.Alias, alias.Target, modifiedBy); aliasErr != nil {
+			return Promotion{}, fmt.Errorf("failed to promote alias %s of %s@%s to %s: %w", alias.Alias, fromEnv, stateID, toEnv, aliasErr)
+		}
+		promotion.Aliased = append(promotion.Aliased, alias.Alias)
+	}
+	logger.Info().Int("writtenCount", len(promotion.Written)).Int("deletedCount", len(promotion.Deleted)).Int("aliasedCount", len(promotion.Aliased)).Msg("State promoted")
+	return promotion, nil
+}
+
+// readState returns the blobs and the aliases of the store as of stateID
+func readState(ctx context.Context, store Store, stateID string) (map[string]BlobInfo, []Alias, error) {
+	reader, ok := store.(PastStateReader)
+	if !ok {
+		return nil, nil, fmt.Errorf("store %s can't read its past states: %w", store, ErrInvalidPromotion)
+	}
+
+	changes, listErr := reader.ListChangesBetween(ctx, "", stateID)
+	if listErr != nil {
+		return nil, nil, listErr
+	}
+	blobs := map[string]BlobInfo{}
+	for _, change := range changes {
+		content, getErr := reader.GetBlobAtVersion(ctx, change.Key, stateID)
+		if getErr != nil {
+			return nil, nil, getErr
+		}
+		metadata, metadataErr := reader.GetBlobMetadataAtVersion(ctx, change.Key, stateID)
+		if metadataErr != nil {
+			return nil, nil, metadataErr
+		}
+		mode, modeErr := reader.GetBlobModeAtVersion(ctx, change.Key, stateID)
+		if modeErr != nil {
+			return nil, nil, modeErr
+		}
+		blobs[change.Key] = BlobInfo{Key: change.Key, Content: content, Metadata: metadata, Mode: mode}
+	}
+	aliases, aliasesErr := reader.ListAliasesAtVersion(ctx, stateID)
+	if aliasesErr != nil {
+		return nil, nil, aliasesErr
+	}
+	return blobs, aliases, nil
+}
+
+// diffAliases returns the aliases to create in the store for it to have the specified ones
+func diffAliases(ctx context.Context, store Store, aliases []Alias) ([]Alias, error) {
+	current, listErr := store.ListAliases(ctx)
+	if listErr != nil {
+		return nil, listErr
+	}
+	targets := map[string]string{}
+	for _, alias := range current {
+		targets[alias.Alias] = alias.Target
+	}
+	missing := []Alias{}
+	for _, alias := range aliases {
+		if target, exists := targets[alias.Alias]; !exists || target != alias.Target {
+			missing = append(missing, alias)
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i].Alias < missing[j].Alias })
+	return missing, nil
+}
+
+// diffState returns the changes making the blobs of the store the specified ones
+func diffState(ctx context.Context, store Store, blobs map[string]BlobInfo) ([]BlobChange, error) {
+	keys, listErr := store.ListBlobKeys(ctx, ListOptions{})
+	if listErr != nil {
+		return nil, listErr
+	}
+	changes := []BlobChange{}
+	present := map[string]bool{}
+	for _, key := range keys {
+		present[key] = true
+		blob, wanted := blobs[key]
+		if !wanted {
+			changes = append(changes, BlobChange{Blob: BlobInfo{Key: key}, Delete: true})
+			continue
+		}
+		current, getErr := store.GetBlobInfo(ctx, key)
+		if getErr != nil {
+			return nil, getErr
+		}
+		currentHead, headErr := store.HeadBlob(ctx, key)
+		if headErr != nil {
+			return nil, headErr
+		}
+		if !bytes.Equal(current.Content, blob.Content) || !maps.Equal(current.Metadata, blob.Metadata) || currentHead.Mode != blob.Mode {
+			changes = append(changes, BlobChange{Blob: blob})
+		}
+	}
+	for key, blob := range blobs {
+		if !present[key] {
+			changes = append(changes, BlobChange{Blob: blob})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Blob.Key < changes[j].Blob.Key })
+	return changes, nil
+}
@@ -0,0 +1,97 @@
+package vcblobstore
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+type TreeNodeType string
+
+const (
+	TreeNodeDirectory TreeNodeType = "tree"
+	TreeNodeBlob      TreeNodeType = "blob"
+)
+
+// TreeNode is a directory or a blob in the hierarchical view of the key space
+type TreeNode struct {
+	Name     string
+	Path     string
+	Type     TreeNodeType
+	BlobId   string
+	Children []*TreeNode
+}
+
+// TreeEntry is a blob item of the flat, recursive tree listing provided by the backends
+type TreeEntry struct {
+	Path   string
+	BlobId string
+}
+
+// BuildTree assembles the hierarchy rooted at prefix from the flat list of entries, leaving out internal keys according to naming.
+// Directories deeper than depth levels below the root are included without their children; depth < 1 means no limit
+func BuildTree(naming NamingStrategy, prefix string, depth int, entries []TreeEntry) *TreeNode {
+	rootPath := strings.Trim(prefix, "/")
+	root := &TreeNode{
+		Name: path.Base(rootPath),
+		Path: rootPath,
+		Type: TreeNodeDirectory,
+	}
+	if len(rootPath) == 0 {
+		root.Name = ""
+	}
+
+	directories := map[string]*TreeNode{rootPath: root}
+
+	for _, entry := range entries {
+		if naming.IsInternalKey(entry.Path) && !naming.IsInternalKey(rootPath+"/") {
+			continue
+		}
+
+		relativePath := entry.Path
+		if len(rootPath) > 0 {
+			if !strings.HasPrefix(entry.Path, rootPath+"/") {
+				continue
+			}
+			relativePath = strings.TrimPrefix(entry.Path, rootPath+"/")
+		}
+
+		segments := strings.Split(relativePath, "/")
+		parent := root
+		currentPath := rootPath
+		for level, segment := range segments {
+			currentPath = path.Join(currentPath, segment)
+			if depth > 0 && level >= depth {
+				break
+			}
+			if level == len(segments)-1 {
+				parent.Children = append(parent.Children, &TreeNode{
+					Name:   segment,
+					Path:   currentPath,
+					Type:   TreeNodeBlob,
+					BlobId: entry.BlobId,
+				})
+				break
+			}
+			directory, found := directories[currentPath]
+			if !found {
+				directory = &TreeNode{
+					Name: segment,
+					Path: currentPath,
+					Type: TreeNodeDirectory,
+				}
+				directories[currentPath] = directory
+				parent.Children = append(parent.Children, directory)
+			}
+			parent = directory
+		}
+	}
+
+	for _, directory := range directories {
+		sort.Slice(directory.Children, func(i, j int) bool {
+			return directory.Children[i].Name < directory.Children[j].Name
+		})
+	}
+
+	return root
+}
@@ -0,0 +1,46 @@
+package vcblobstore
+
+import "context"
+
+// RepairOptions select the repairs of the problems VerifyRepository finds. The backends ignore the repairs which
+// don't apply to them
+type RepairOptions struct {
+	// ResetChanges discards the uncommitted changes of the index and the working tree (git reset --hard)
+	ResetChanges bool
+	// RemoveUntracked removes the untracked files of the working tree (git clean)
+	RemoveUntracked bool
+}
+
+// RepositoryCheck is the outcome of a check of VerifyRepository
+type RepositoryCheck struct {
+	Name   string
+	Passed bool
+	// Details describes the problem found, if any
+	Details string
+	// Repaired tells that the problem found was repaired, after which the check passed
+	Repaired bool
+}
+
+// RepositoryReport is the outcome of VerifyRepository
+type RepositoryReport struct {
+	Checks  []RepositoryCheck
+	Healthy bool
+}
+
+// Add records the outcome of the check
+func (report *RepositoryReport) Add(check RepositoryCheck) {
+	report.Checks = append(report.Checks, check)
+	report.Healthy = report.Healthy && check.Passed
+}
+
+// NewRepositoryReport returns a healthy report without checks
+func NewRepositoryReport() RepositoryReport {
+	return RepositoryReport{Healthy: true}
+}
+
+// RepositoryVerifier is implemented by the backends able to check the integrity of their repository
+type RepositoryVerifier interface {
+	// VerifyRepository checks the repository, repairing the problems found as selected by opts. The problems are
+	// reported, errors are returned only if the checks couldn't be carried out
+	VerifyRepository(ctx context.Context, opts RepairOptions) (RepositoryReport, error)
+}